@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/batch"
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/noninteractive"
+	"github.com/google/generative-ai-go/genai"
+	"github.com/spf13/cobra"
+)
+
+var batchCmd = &cobra.Command{
+	Use:   "batch",
+	Short: "Run many independent prompts from a JSON Lines file",
+	Long: `Runs each prompt of a JSON Lines input as a non-interactive prompt of its own
+and writes a result per line to the output as it finishes:
+
+  {"id": "review-17", "prompt": "Classify the sentiment of: ..."}
+
+  {"id":"review-17","status":"ok","response":"positive","attempts":1,"durationNs":...}
+
+Items without an id are identified by their line number. The prompts run
+without tools, several at a time, and no more than --rpm start per minute;
+those the API rate limits are retried with backoff. The status of each item
+is reported on stderr, and the command fails if any item failed.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inputPath, _ := cmd.Flags().GetString("input")
+		outputPath, _ := cmd.Flags().GetString("output")
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		rpm, _ := cmd.Flags().GetInt("rpm")
+		retries, _ := cmd.Flags().GetInt("retries")
+		if concurrency < 1 {
+			return fmt.Errorf("the concurrency must be at least 1")
+		}
+
+		var input io.Reader = os.Stdin
+		if inputPath != "-" {
+			f, err := os.Open(inputPath)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			input = f
+		}
+		items, err := batch.Load(input)
+		if err != nil {
+			return fmt.Errorf("invalid input: %w", err)
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		client, model, err := newModel(ctx, cmd, cfg)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		out := cmd.OutOrStdout()
+		if outputPath != "" && outputPath != "-" {
+			f, err := os.Create(outputPath)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+		enc := json.NewEncoder(out)
+		status := cmd.ErrOrStderr()
+		done, failed := 0, 0
+		var writeErr error
+		batch.Run(ctx, items, batch.Options{Concurrency: concurrency, RequestsPerMinute: rpm, Retries: retries},
+			func(ctx context.Context, prompt string) (string, error) {
+				// Each prompt gets a model of its own, since a conversation
+				// adjusts its model, and no tools, since nobody watches.
+				m := *model
+				m.Tools = []*genai.Tool{}
+				result, err := noninteractive.Converse(ctx, cfg, nil, &m, []genai.Part{genai.Text(prompt)}, nil)
+				if err != nil {
+					return "", err
+				}
+				return result.Response, nil
+			},
+			func(r batch.Result) {
+				done++
+				if r.Status == batch.StatusOK {
+					fmt.Fprintf(status, "[%d/%d] ok %s (%s)\n", done, len(items), r.ID, r.Time.Round(100*time.Millisecond))
+				} else {
+					failed++
+					fmt.Fprintf(status, "[%d/%d] error %s: %s\n", done, len(items), r.ID, r.Error)
+				}
+				if err := enc.Encode(r); err != nil && writeErr == nil {
+					writeErr = fmt.Errorf("failed to write the results: %w", err)
+				}
+			})
+		if writeErr != nil {
+			return writeErr
+		}
+		fmt.Fprintf(status, "%d ok, %d failed\n", len(items)-failed, failed)
+		if failed > 0 {
+			return fmt.Errorf("%d of %d prompts failed", failed, len(items))
+		}
+		return nil
+	},
+}
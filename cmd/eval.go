@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/eval"
+	"github.com/google-gemini/gemini-cli-go/pkg/noninteractive"
+	"github.com/google-gemini/gemini-cli-go/pkg/tools"
+	"github.com/google/generative-ai-go/genai"
+	"github.com/spf13/cobra"
+)
+
+var evalCmd = &cobra.Command{
+	Use:   "eval <suite.yaml>",
+	Short: "Run a suite of prompts and check the responses",
+	Long: `Runs each case of a YAML suite as a non-interactive prompt, in a temporary copy
+of its fixture workspace, and checks the response and the files left behind:
+
+  timeout: 2m
+  cases:
+    - name: adds a changelog entry
+      workspace: fixtures/app      # relative to the suite
+      prompt: /changelog fix the login redirect
+      expect:
+        - regex: (?i)changelog
+        - not_regex: (?i)sorry
+        - file: CHANGELOG.md
+          contains: login redirect
+    - name: answers in JSON
+      prompt: List three colors as a JSON array of strings.
+      expect:
+        - json_schema: {type: array, items: {type: string}, minItems: 3}
+
+Prompts starting with a slash run the custom commands of the workspace. Tool
+calls are approved without confirmation. The command fails if a case fails.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		suite, err := eval.Load(args[0])
+		if err != nil {
+			return err
+		}
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		client, model, err := newModel(ctx, cmd, cfg)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		outputFormat, _ := cmd.Flags().GetString("output-format")
+		out := cmd.OutOrStdout()
+		results := suite.Run(ctx, cfg, func(ctx context.Context, ws *tools.Workspace, prompt string) (string, error) {
+			result, err := noninteractive.Converse(ctx, cfg, ws, model, []genai.Part{genai.Text(prompt)}, nil)
+			if err != nil {
+				return "", err
+			}
+			return result.Response, nil
+		}, func(r eval.Result) {
+			if outputFormat == "json" {
+				return
+			}
+			switch {
+			case r.Passed:
+				fmt.Fprintf(out, "PASS %s (%s)\n", r.Name, r.Time.Round(100*time.Millisecond))
+			case r.Error != "":
+				fmt.Fprintf(out, "ERROR %s: %s\n", r.Name, r.Error)
+			default:
+				fmt.Fprintf(out, "FAIL %s (%s)\n", r.Name, r.Time.Round(100*time.Millisecond))
+				for _, f := range r.Failed {
+					fmt.Fprintf(out, "    - %s\n", f)
+				}
+			}
+		})
+
+		passed := 0
+		for _, r := range results {
+			if r.Passed {
+				passed++
+			}
+		}
+		if outputFormat == "json" {
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(results); err != nil {
+				return err
+			}
+		} else {
+			fmt.Fprintf(out, "\n%d passed, %d failed\n", passed, len(suite.Cases)-passed)
+		}
+		if passed < len(suite.Cases) {
+			return fmt.Errorf("%d of %d cases failed", len(suite.Cases)-passed, len(suite.Cases))
+		}
+		return nil
+	},
+}
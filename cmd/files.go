@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/auth"
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/uploads"
+	"github.com/google/generative-ai-go/genai"
+	"github.com/spf13/cobra"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+var filesCmd = &cobra.Command{
+	Use:   "files",
+	Short: "Manage files uploaded with the Gemini Files API",
+	Long: `Manages the files uploaded with the Gemini Files API, which keeps them for
+48 hours. Files attached with @path that are larger than the requests can
+take are uploaded, and attaching the same content again reuses the upload.`,
+}
+
+var filesUploadCmd = &cobra.Command{
+	Use:   "upload <path>...",
+	Short: "Upload files, or reuse earlier uploads of them",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		client, err := newFilesClient(ctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		uploader, err := uploads.New(client)
+		if err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+		for _, path := range args {
+			r, reused, err := uploader.Upload(ctx, path)
+			if err != nil {
+				return err
+			}
+			verb := "Uploaded"
+			if reused {
+				verb = "Already uploaded"
+			}
+			fmt.Fprintf(out, "%s %s as %s (%s), expiring %s\n", verb, path, r.Name, r.URI, r.Expires.Local().Format(time.DateTime))
+		}
+		return nil
+	},
+}
+
+var filesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the uploaded files",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		client, err := newFilesClient(ctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		uploader, err := uploads.New(client)
+		if err != nil {
+			return err
+		}
+		records, err := uploader.Records()
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		n := 0
+		it := client.ListFiles(ctx)
+		for {
+			f, err := it.Next()
+			if errors.Is(err, iterator.Done) {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("failed to list the files: %w", err)
+			}
+			if n == 0 {
+				fmt.Fprintln(w, "NAME\tTYPE\tSIZE\tEXPIRES\tSOURCE")
+			}
+			n++
+			// The local path is known for the files uploaded from here.
+			source := f.DisplayName
+			if r, ok := records[f.Name]; ok {
+				source = r.Path
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", f.Name, f.MIMEType, formatSize(f.SizeBytes), f.ExpirationTime.Local().Format(time.DateTime), source)
+		}
+		if n == 0 {
+			fmt.Fprintln(out, "No files uploaded.")
+			return nil
+		}
+		return w.Flush()
+	},
+}
+
+var filesDeleteCmd = &cobra.Command{
+	Use:   "delete <name>...",
+	Short: "Delete uploaded files",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		client, err := newFilesClient(ctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		uploader, err := uploads.New(client)
+		if err != nil {
+			return err
+		}
+		for _, name := range args {
+			if err := client.DeleteFile(ctx, name); err != nil {
+				return fmt.Errorf("failed to delete %s: %w", name, err)
+			}
+			if err := uploader.Forget(name); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Deleted %s\n", name)
+		}
+		return nil
+	},
+}
+
+// newFilesClient returns a client authenticated as the settings select.
+func newFilesClient(ctx context.Context) (*genai.Client, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	authType := "oauth2"
+	if cfg.Security != nil && cfg.Security.Auth != nil && cfg.Security.Auth.SelectedType != "" {
+		authType = cfg.Security.Auth.SelectedType
+	}
+	authenticator, _, err := auth.NewAuthenticator(authType)
+	if err != nil {
+		return nil, err
+	}
+	if err := authenticator.Authenticate(); err != nil {
+		return nil, fmt.Errorf("authentication failed: %w", err)
+	}
+	token, err := authenticator.GetToken()
+	if err != nil {
+		return nil, err
+	}
+	client, err := genai.NewClient(ctx, option.WithAPIKey(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	return client, nil
+}
+
+// formatSize formats a size in bytes for people.
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/auth"
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/imagegen"
+	"github.com/google-gemini/gemini-cli-go/pkg/tui"
+	"github.com/google/generative-ai-go/genai"
+	"github.com/spf13/cobra"
+)
+
+var imageCmd = &cobra.Command{
+	Use:   "image",
+	Short: "Generate images with the Imagen models",
+}
+
+var imageGenerateCmd = &cobra.Command{
+	Use:   "generate <prompt>",
+	Short: "Generate images from a prompt and save them",
+	Long: `Generates images from a prompt with an Imagen model and saves them to the
+output file, or to numbered files next to it (out-1.png, out-2.png, ...) when
+more than one is generated. Terminals supporting the kitty, iTerm2 or sixel
+graphics protocols also show the images; others print their paths.
+
+The images are generated with ` + imagegen.DefaultModel + ` unless --model names another
+image generation model.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		count, _ := cmd.Flags().GetInt("count")
+		aspectRatio, _ := cmd.Flags().GetString("aspect-ratio")
+		size, _ := cmd.Flags().GetString("size")
+		// The model flag names an image model here, not a Gemini one.
+		modelName, _ := cmd.Flags().GetString("model")
+		if count < 1 {
+			return fmt.Errorf("the number of images must be at least 1")
+		}
+		req := imagegen.Request{Prompt: args[0], Count: count, AspectRatio: aspectRatio, Size: size}
+		if err := req.Check(); err != nil {
+			return err
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		authType := "oauth2"
+		if cfg.Security != nil && cfg.Security.Auth != nil && cfg.Security.Auth.SelectedType != "" {
+			authType = cfg.Security.Auth.SelectedType
+		}
+		authenticator, _, err := auth.NewAuthenticator(authType)
+		if err != nil {
+			return err
+		}
+		if err := authenticator.Authenticate(); err != nil {
+			return fmt.Errorf("authentication failed: %w", err)
+		}
+		token, err := authenticator.GetToken()
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		fmt.Fprintf(cmd.ErrOrStderr(), "Generating %s...\n", plural(max(count, 1), "image"))
+		client := &imagegen.Client{APIKey: token, Model: modelName}
+		images, err := client.Generate(ctx, req)
+		if err != nil {
+			return err
+		}
+		paths, err := saveImages(output, images)
+		if err != nil {
+			return err
+		}
+		showImages(cmd.OutOrStdout(), paths, images, isTerminal(cmd.OutOrStdout()))
+		return nil
+	},
+}
+
+// saveImages writes images to output, or to output with the number of
+// each image before its extension if there are several, and returns their
+// paths. An output without an extension gets that of the image type.
+func saveImages(output string, images []genai.Blob) ([]string, error) {
+	var paths []string
+	for i, img := range images {
+		base, ext := output, filepath.Ext(output)
+		if ext == "" {
+			ext = ".png"
+			if exts, _ := mime.ExtensionsByType(img.MIMEType); len(exts) > 0 {
+				ext = exts[len(exts)-1]
+			}
+		} else {
+			base = strings.TrimSuffix(output, ext)
+		}
+		path := base + ext
+		if len(images) > 1 {
+			path = fmt.Sprintf("%s-%d%s", base, i+1, ext)
+		}
+		if dir := filepath.Dir(path); dir != "." {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return paths, err
+			}
+		}
+		if err := os.WriteFile(path, img.Data, 0o644); err != nil {
+			return paths, fmt.Errorf("failed to save the image: %w", err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// showImages prints the path of each saved image, preceded by the image
+// itself if inline is set and the terminal can draw it.
+func showImages(out io.Writer, paths []string, images []genai.Blob, inline bool) {
+	for i, path := range paths {
+		if inline {
+			if seq, ok := tui.InlineImage(images[i].Data, 0); ok {
+				fmt.Fprintln(out, seq)
+			}
+		}
+		fmt.Fprintf(out, "Saved %s\n", path)
+	}
+}
+
+// isTerminal reports whether out is a terminal.
+func isTerminal(out io.Writer) bool {
+	f, ok := out.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// plural returns n and noun, in the plural unless n is 1.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
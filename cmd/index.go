@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/index"
+	"github.com/spf13/cobra"
+)
+
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Manage the semantic index used by the semantic_search tool",
+}
+
+var indexBuildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Embed all files of the current directory into a new semantic index",
+	Long: `Splits the text files of the current directory into chunks, embeds them with the
+Gemini embedding model and stores them in ~/.gemini/tmp/<project-hash>/index.
+Files ignored by git are skipped. Use "gemini index update" to embed only the
+files changed since.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runIndex(cmd, func(ctx context.Context, root string, e index.Embedder, progress func(int, int)) (*index.Index, index.Stats, error) {
+			ix, stats, err := index.Build(ctx, root, e, nil, progress)
+			if err != nil {
+				return nil, stats, err
+			}
+			return ix, stats, ix.Save(root)
+		})
+	},
+}
+
+var indexUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Embed the files added or changed since the semantic index was built",
+	Long: `Compares the content hashes of the files of the current directory with those
+recorded in the semantic index, embeds the new and changed files and drops the
+removed ones. Interactive sessions do this in the background unless
+tools.index.disableAutoUpdate is set.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runIndex(cmd, index.Update)
+	},
+}
+
+// runIndex builds or updates the index of the working directory with build
+// and reports the outcome.
+func runIndex(cmd *cobra.Command, build func(context.Context, string, index.Embedder, func(int, int)) (*index.Index, index.Stats, error)) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	root, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+	ix, stats, err := build(ctx, root, index.NewGeminiEmbedder(cfg), func(done, total int) {
+		fmt.Fprintf(cmd.ErrOrStderr(), "\rEmbedded %d/%d chunks", done, total)
+	})
+	if stats.Embedded > 0 {
+		fmt.Fprintln(cmd.ErrOrStderr())
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Indexed %d files in %d chunks with %s: %d chunks embedded, %d removed files dropped\n",
+		stats.Files, stats.Chunks, ix.Model, stats.Embedded, stats.Removed)
+	return nil
+}
@@ -1,38 +1,451 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/mcp"
+	"github.com/google-gemini/gemini-cli-go/pkg/tools"
 	"github.com/spf13/cobra"
 )
 
 var mcpCmd = &cobra.Command{
 	Use:   "mcp",
 	Short: "Manage MCP servers",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("mcp command")
-	},
+	Long: `Manages the Model Context Protocol servers in the mcpServers setting. Servers
+with a command are started as child processes and spoken to over stdio; those
+with an httpUrl are reached over HTTP. Use "gemini mcp test <name>" to connect
+to a server and list its tools.`,
 }
 
 var mcpAddCmd = &cobra.Command{
 	Use:   "add <name> <commandOrUrl> [args...]",
 	Short: "Add a server",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("mcp add command")
+	Long: `Adds a server to the mcpServers setting of the project (.gemini/settings.toml)
+or, with --scope user, of the user (~/.gemini/settings.toml). With the stdio
+transport, the server is started with the command and args; with sse or http,
+it is reached at the URL. A server of the same name in that scope is
+replaced.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		scopeName, _ := cmd.Flags().GetString("scope")
+		scope, err := mcpScope(scopeName)
+		if err != nil {
+			return err
+		}
+		name := args[0]
+		server, err := mcpServerFlags(cmd, args[1], args[2:])
+		if err != nil {
+			return err
+		}
+		if err := server.Validate(name); err != nil {
+			return err
+		}
+
+		settings, err := config.LoadScope(scope)
+		if err != nil {
+			return fmt.Errorf("failed to load the %s settings: %w", scopeName, err)
+		}
+		_, replaced := settings.MCPServers[name]
+		if settings.MCPServers == nil {
+			settings.MCPServers = map[string]config.MCPServer{}
+		}
+		settings.MCPServers[name] = server
+		if err := config.SaveScope(scope, settings); err != nil {
+			return fmt.Errorf("failed to save the %s settings: %w", scopeName, err)
+		}
+		verb := "Added"
+		if replaced {
+			verb = "Updated"
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "%s MCP server %q in the %s settings (%s).\n", verb, name, scopeName, transportName(server))
+		return nil
 	},
 }
 
 var mcpRemoveCmd = &cobra.Command{
 	Use:   "remove <name>",
 	Short: "Remove a server",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("mcp remove command")
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		scopeName, _ := cmd.Flags().GetString("scope")
+		scope, err := mcpScope(scopeName)
+		if err != nil {
+			return err
+		}
+		name := args[0]
+		settings, err := config.LoadScope(scope)
+		if err != nil {
+			return fmt.Errorf("failed to load the %s settings: %w", scopeName, err)
+		}
+		if _, ok := settings.MCPServers[name]; !ok {
+			return fmt.Errorf("no MCP server named %q is configured in the %s settings", name, scopeName)
+		}
+		delete(settings.MCPServers, name)
+		if err := config.SaveScope(scope, settings); err != nil {
+			return fmt.Errorf("failed to save the %s settings: %w", scopeName, err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Removed MCP server %q from the %s settings.\n", name, scopeName)
+		return nil
 	},
 }
 
 var mcpListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all configured MCP servers",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("mcp list command")
+	Long: `Lists the servers of the mcpServers settings with their transport, and
+connects to each to report whether it is reachable. Servers left out by
+mcp.allowed or mcp.excluded are listed as disabled.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		out := cmd.OutOrStdout()
+		if len(cfg.MCPServers) == 0 {
+			fmt.Fprintln(out, "No MCP servers are configured.")
+			return nil
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		enabled := tools.MCPServers(cfg)
+		names := slices.Sorted(maps.Keys(cfg.MCPServers))
+		statuses := make([]string, len(names))
+		var wg sync.WaitGroup
+		for i, name := range names {
+			if _, ok := enabled[name]; !ok {
+				statuses[i] = "disabled"
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				statuses[i] = mcpStatus(ctx, name, cfg.MCPServers[name])
+			}()
+		}
+		wg.Wait()
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tTRANSPORT\tTARGET\tSTATUS")
+		for i, name := range names {
+			server := cfg.MCPServers[name]
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, transportName(server), transportTarget(server), statuses[i])
+		}
+		return w.Flush()
 	},
 }
+
+// mcpScope returns the settings scope --scope names.
+func mcpScope(name string) (config.Scope, error) {
+	switch name {
+	case "user":
+		return config.UserScope, nil
+	case "project":
+		return config.WorkspaceScope, nil
+	}
+	return 0, fmt.Errorf("invalid --scope %q, use user or project", name)
+}
+
+// mcpServerFlags returns the server mcp add configures from its target,
+// the command or URL, the arguments after it and its flags.
+func mcpServerFlags(cmd *cobra.Command, target string, args []string) (config.MCPServer, error) {
+	var server config.MCPServer
+	transport, _ := cmd.Flags().GetString("transport")
+	env, _ := cmd.Flags().GetStringArray("env")
+	headers, _ := cmd.Flags().GetStringArray("header")
+	switch transport {
+	case "stdio":
+		server.Command = target
+		server.Args = args
+		if len(headers) > 0 {
+			return server, fmt.Errorf("--header only applies to the sse and http transports")
+		}
+	case "sse", "http":
+		if len(args) > 0 {
+			return server, fmt.Errorf("the %s transport takes a URL and no arguments", transport)
+		}
+		if len(env) > 0 {
+			return server, fmt.Errorf("--env only applies to the stdio transport")
+		}
+		if transport == "sse" {
+			server.URL = target
+		} else {
+			server.HTTPURL = target
+		}
+	default:
+		return server, fmt.Errorf("invalid --transport %q, use stdio, sse or http", transport)
+	}
+
+	for _, e := range env {
+		k, v, ok := strings.Cut(e, "=")
+		if !ok {
+			return server, fmt.Errorf("invalid --env %q, use KEY=value", e)
+		}
+		if server.Env == nil {
+			server.Env = map[string]string{}
+		}
+		server.Env[k] = v
+	}
+	for _, h := range headers {
+		k, v, ok := strings.Cut(h, ":")
+		if !ok {
+			return server, fmt.Errorf("invalid --header %q, use \"Name: value\"", h)
+		}
+		if server.Headers == nil {
+			server.Headers = map[string]string{}
+		}
+		server.Headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	server.Timeout, _ = cmd.Flags().GetInt("timeout")
+	server.Trust, _ = cmd.Flags().GetBool("trust")
+	server.Description, _ = cmd.Flags().GetString("description")
+	include, _ := cmd.Flags().GetStringArray("include-tools")
+	server.IncludeTools = splitList(include)
+	exclude, _ := cmd.Flags().GetStringArray("exclude-tools")
+	server.ExcludeTools = splitList(exclude)
+	return server, nil
+}
+
+// splitList splits comma-separated flag values into their items.
+func splitList(values []string) []string {
+	var items []string
+	for _, v := range values {
+		for item := range strings.SplitSeq(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// transportName returns the transport of server as mcp add names it.
+func transportName(server config.MCPServer) string {
+	switch {
+	case server.Command != "":
+		return "stdio"
+	case server.HTTPURL != "":
+		return "http"
+	}
+	return "sse"
+}
+
+// transportTarget returns the command line or URL of server.
+func transportTarget(server config.MCPServer) string {
+	switch {
+	case server.Command != "":
+		return strings.Join(append([]string{server.Command}, server.Args...), " ")
+	case server.HTTPURL != "":
+		return server.HTTPURL
+	}
+	return server.URL
+}
+
+// mcpStatus connects to the named server and reports whether it could.
+func mcpStatus(ctx context.Context, name string, server config.MCPServer) string {
+	client, err := mcp.Connect(ctx, name, server)
+	if errors.Is(err, mcp.ErrUnauthorized) {
+		return "needs authorization (gemini mcp auth " + name + ")"
+	}
+	if err != nil {
+		return "disconnected: " + firstLine(err.Error())
+	}
+	client.Close()
+	return "connected"
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+var mcpLogsCmd = &cobra.Command{
+	Use:   "logs <name>",
+	Short: "Show the stderr output of an MCP server",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		lines, _ := cmd.Flags().GetInt("lines")
+		follow, _ := cmd.Flags().GetBool("follow")
+		if lines <= 0 {
+			return fmt.Errorf("the number of lines must be at least 1")
+		}
+
+		tail, err := mcp.Tail(args[0], lines)
+		if err != nil && !(follow && errors.Is(err, mcp.ErrNoLogs)) {
+			return err
+		}
+		for _, line := range tail {
+			fmt.Fprintln(cmd.OutOrStdout(), line)
+		}
+		if !follow {
+			return nil
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		return mcp.Follow(ctx, args[0], cmd.OutOrStdout())
+	},
+}
+
+var mcpTestCmd = &cobra.Command{
+	Use:   "test <name> [tool]",
+	Short: "Connect to a server, list its tools and optionally call one",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		var toolArgs map[string]any
+		if raw, _ := cmd.Flags().GetString("args"); raw != "" {
+			if len(args) < 2 {
+				return fmt.Errorf("--args requires a tool name")
+			}
+			if err := json.Unmarshal([]byte(raw), &toolArgs); err != nil {
+				return fmt.Errorf("--args must be a JSON object: %w", err)
+			}
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		server, ok := cfg.MCPServers[name]
+		if !ok {
+			return fmt.Errorf("no MCP server named %q is configured", name)
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		out := cmd.OutOrStdout()
+
+		client, err := mcp.Connect(mcp.WithAuthorization(ctx, func(_, authURL string) {
+			showAuthURL(cmd.ErrOrStderr(), name, authURL)
+		}), name, server)
+		if err != nil {
+			if path, pathErr := mcp.LogPath(name); pathErr == nil && server.Command != "" {
+				return fmt.Errorf("%w\nserver stderr is logged to %s", err, path)
+			}
+			return err
+		}
+		defer client.Close()
+		fmt.Fprintf(out, "Connected to %s (%s %s)\n", name, client.ServerInfo.Name, client.ServerInfo.Version)
+
+		tools, err := client.ListTools(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "\nTools (%d):\n", len(tools))
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, t := range tools {
+			desc, _, _ := strings.Cut(t.Description, "\n")
+			fmt.Fprintf(w, "  %s\t%s\n", t.Name, desc)
+		}
+		w.Flush()
+
+		if len(args) < 2 {
+			return nil
+		}
+		tool := args[1]
+		if !slices.ContainsFunc(tools, func(t mcp.Tool) bool { return t.Name == tool }) {
+			return fmt.Errorf("server %q has no tool %q", name, tool)
+		}
+
+		result, err := client.CallTool(ctx, tool, toolArgs)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "\nResult of %s:\n", tool)
+		printToolResult(out, result)
+		if result.IsError {
+			return fmt.Errorf("tool %q reported an error", tool)
+		}
+		return nil
+	},
+}
+
+var mcpAuthCmd = &cobra.Command{
+	Use:   "auth <name>",
+	Short: "Authorize with a server that requires OAuth",
+	Long: `Authorizes the CLI with a remote server that requires OAuth. The authorization
+page of the server opens in the browser, and the token issued once you
+authorize is stored in ~/.gemini/mcp-oauth-tokens.json and sent to the server
+from then on. Interactive sessions ask you to authorize as they connect to
+such a server; this command does it ahead of time, or again. With --logout,
+the stored token is forgotten instead.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if logout, _ := cmd.Flags().GetBool("logout"); logout {
+			if err := mcp.Deauthorize(name); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Forgot the token of MCP server %q.\n", name)
+			return nil
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		server, ok := cfg.MCPServers[name]
+		if !ok {
+			return fmt.Errorf("no MCP server named %q is configured", name)
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		err = mcp.Authorize(ctx, name, server, func(authURL string) {
+			showAuthURL(cmd.ErrOrStderr(), name, authURL)
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Authorized with MCP server %q.\n", name)
+		return nil
+	},
+}
+
+// showAuthURL tells the user on w where to authorize with the named server,
+// should the browser not open.
+func showAuthURL(w io.Writer, name, authURL string) {
+	fmt.Fprintf(w, "MCP server %s requires authorization. Opening your browser; if it does not open, visit:\n%s\n", name, authURL)
+}
+
+// printToolResult prints text content as is and summarizes binary content.
+func printToolResult(w io.Writer, result *mcp.CallToolResult) {
+	for _, c := range result.Content {
+		switch c.Type {
+		case "text":
+			fmt.Fprintln(w, c.Text)
+		case "image", "audio":
+			size := base64.StdEncoding.DecodedLen(len(c.Data))
+			fmt.Fprintf(w, "[%s %s, about %d bytes]\n", c.Type, c.MimeType, size)
+		default:
+			fmt.Fprintf(w, "[%s]\n%s\n", c.Type, indentJSON(c.Resource))
+		}
+	}
+	if len(result.StructuredContent) > 0 {
+		fmt.Fprintf(w, "Structured content:\n%s\n", indentJSON(result.StructuredContent))
+	}
+}
+
+func indentJSON(raw json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
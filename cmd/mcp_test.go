@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/spf13/pflag"
+)
+
+func TestMCPAddListRemove(t *testing.T) {
+	restore := config.SetUserHomeDirForTesting(t.TempDir(), nil)
+	defer restore()
+	t.Chdir(t.TempDir())
+
+	run := func(args ...string) (string, error) {
+		t.Helper()
+		// The subcommands are shared, so the flags of earlier runs are
+		// reset.
+		for _, c := range mcpCmd.Commands() {
+			c.Flags().VisitAll(func(f *pflag.Flag) {
+				if v, ok := f.Value.(pflag.SliceValue); ok {
+					v.Replace(nil)
+				} else {
+					f.Value.Set(f.DefValue)
+				}
+				f.Changed = false
+			})
+		}
+		var out bytes.Buffer
+		rootCmd.SetOut(&out)
+		rootCmd.SetErr(&out)
+		rootCmd.SetArgs(args)
+		err := rootCmd.Execute()
+		return out.String(), err
+	}
+
+	if _, err := run("mcp", "add", "files", "npx", "--env", "ROOT=/tmp", "--include-tools", "read,write", "--", "server", "--verbose"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run("mcp", "add", "--scope", "user", "-t", "http", "-H", "Authorization: Bearer $TOKEN", "remote", "https://example.com/mcp"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run("mcp", "add", "-t", "sse", "bad", "https://example.com/sse", "extra"); err == nil {
+		t.Error("expected arguments to be refused for the sse transport")
+	}
+
+	project, err := config.LoadScope(config.WorkspaceScope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := project.MCPServers["files"]
+	if files.Command != "npx" || strings.Join(files.Args, " ") != "server --verbose" || files.Env["ROOT"] != "/tmp" || strings.Join(files.IncludeTools, ",") != "read,write" {
+		t.Errorf("project server = %+v", files)
+	}
+	user, err := config.LoadScope(config.UserScope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remote := user.MCPServers["remote"]; remote.HTTPURL != "https://example.com/mcp" || remote.Headers["Authorization"] != "Bearer $TOKEN" {
+		t.Errorf("user server = %+v", remote)
+	}
+
+	if _, err := run("mcp", "remove", "remote"); err == nil {
+		t.Error("expected removing a server of another scope to fail")
+	}
+	out, err := run("mcp", "remove", "--scope", "user", "remote")
+	if err != nil || !strings.Contains(out, `Removed MCP server "remote"`) {
+		t.Errorf("remove = %q, %v", out, err)
+	}
+	user, _ = config.LoadScope(config.UserScope)
+	if _, ok := user.MCPServers["remote"]; ok {
+		t.Error("expected the server to be removed from the user settings")
+	}
+
+	project.MCP = &config.MCPSettings{Excluded: []string{"files"}}
+	if err := config.SaveScope(config.WorkspaceScope, project); err != nil {
+		t.Fatal(err)
+	}
+	out, err = run("mcp", "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "files") || !strings.Contains(out, "npx server --verbose") || !strings.Contains(out, "disabled") {
+		t.Errorf("list = %q, want the disabled stdio server", out)
+	}
+
+	if _, err := run("mcp", "logs", "files", "-n", "-1"); err == nil || !strings.Contains(err.Error(), "at least 1") {
+		t.Errorf("logs -n -1 = %v, want a usage error", err)
+	}
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/noninteractive"
+	"github.com/google-gemini/gemini-cli-go/pkg/tools"
+	"github.com/spf13/cobra"
+)
+
+var replCmd = &cobra.Command{
+	Use:   "repl",
+	Short: "Start an interactive session",
+	Long: `Starts an interactive session, as gemini does without a prompt.
+
+With --plain, the session reads prompts line by line and prints the
+responses as plain text, without the full-screen UI, for serial consoles,
+Emacs shell buffers, CI debug sessions and other terminals the UI renders
+poorly in. End a line with \ to continue the prompt on the next one; /help
+lists the commands.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if plain, _ := cmd.Flags().GetBool("plain"); !plain {
+			return cmd.Root().RunE(cmd, nil)
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		approval, err := approvalMode(cmd)
+		if err != nil {
+			return err
+		}
+		allowedTools, _ := cmd.Flags().GetStringArray("allowed-tools")
+		if err := tools.CheckToolNames(allowedTools, tools.MCPServers(cfg)); err != nil {
+			return fmt.Errorf("invalid --allowed-tools: %w", err)
+		}
+		if allowedServers, _ := cmd.Flags().GetStringArray("allowed-mcp-server-names"); len(allowedServers) > 0 {
+			if cfg.MCP == nil {
+				cfg.MCP = &config.MCPSettings{}
+			}
+			cfg.MCP.Allowed = allowedServers
+		}
+		if browserTools, _ := cmd.Flags().GetBool("enable-browser-tools"); browserTools {
+			enableBrowserTools(cfg)
+		}
+
+		ctx := cmd.Context()
+		client, model, err := newModel(ctx, cmd, cfg)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		return noninteractive.REPL(ctx, cfg, model, os.Stdin, cmd.OutOrStdout(), approval, allowedTools)
+	},
+}
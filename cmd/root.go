@@ -4,14 +4,26 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"time"
 
-	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google-gemini/gemini-cli-go/pkg/apilog"
+	"github.com/google-gemini/gemini-cli-go/pkg/audio"
 	"github.com/google-gemini/gemini-cli-go/pkg/auth"
+	"github.com/google-gemini/gemini-cli-go/pkg/batch"
 	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/debugserver"
+	"github.com/google-gemini/gemini-cli-go/pkg/generation"
 	"github.com/google-gemini/gemini-cli-go/pkg/noninteractive"
 	"github.com/google-gemini/gemini-cli-go/pkg/sandbox"
+	"github.com/google-gemini/gemini-cli-go/pkg/seed"
+	"github.com/google-gemini/gemini-cli-go/pkg/shutdown"
+	"github.com/google-gemini/gemini-cli-go/pkg/startup"
+	"github.com/google-gemini/gemini-cli-go/pkg/thinking"
+	"github.com/google-gemini/gemini-cli-go/pkg/tools"
 	"github.com/google-gemini/gemini-cli-go/pkg/tui"
 	"github.com/google-gemini/gemini-cli-go/pkg/updatechecker"
 	"github.com/google/generative-ai-go/genai"
@@ -23,6 +35,10 @@ import (
 var exit = os.Exit
 var rootCmd *cobra.Command
 
+// shutdownTimeout bounds how long flushing sessions and stopping child
+// processes may delay exit.
+const shutdownTimeout = 5 * time.Second
+
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print the version number of gemini-cli",
@@ -38,16 +54,31 @@ func newRootCmd() *cobra.Command {
 		Long:  `A command-line interface for Google's Gemini API.`,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			if sandbox.IsInsideSandbox() {
-				return nil
+				// The CLI outside passes the flags it parsed, which
+				// the arguments of this one leave out.
+				opts, err := sandbox.InheritedOptions()
+				if err != nil {
+					return err
+				}
+				if opts != nil {
+					if err := applySandboxOptions(cmd, opts); err != nil {
+						return err
+					}
+				}
+				return startDebugServer(cmd)
 			}
 
+			endLoad := startup.Begin("load settings")
 			cfg, err := config.Load()
+			endLoad()
 			if err != nil {
 				// We can't use the logger here because it's not initialized yet.
 				fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
 				// Continue without config...
 			}
 
+			endSandbox := startup.Begin("sandbox decision")
+			defer endSandbox()
 			var sandboxOption any
 			if cmd.Flags().Changed("sandbox") {
 				sandboxOption, _ = cmd.Flags().GetBool("sandbox")
@@ -62,27 +93,90 @@ func newRootCmd() *cobra.Command {
 				sandboxImageOption = cfg.Tools.SandboxImage
 			}
 
+			if cfg != nil && cfg.Tools != nil && cfg.Tools.SandboxScope == sandbox.ScopeTools {
+				// The workspace starts the sandbox for the tools. It reads
+				// the settings again, so the flags are passed on through
+				// the environment variables that override them.
+				if cmd.Flags().Changed("sandbox") {
+					os.Setenv("GEMINI_SANDBOX", fmt.Sprint(sandboxOption))
+				}
+				if cmd.Flags().Changed("sandbox-image") {
+					os.Setenv("GEMINI_SANDBOX_IMAGE", sandboxImageOption)
+				}
+				return startDebugServer(cmd)
+			}
+
 			sandboxCfg, err := sandbox.LoadConfig(sandboxOption, sandboxImageOption)
 			if err != nil {
 				return fmt.Errorf("failed to load sandbox config: %w", err)
 			}
 
 			if sandboxCfg != nil {
-				if err := sandbox.Start(sandboxCfg, os.Args[1:]); err != nil {
+				if cfg != nil {
+					sandboxCfg.ApplySettings(cfg.Tools)
+				}
+				endSandbox()
+				if err := sandbox.Start(sandboxCfg, sandboxOptions(cmd, args)); err != nil {
 					return fmt.Errorf("failed to start sandbox: %w", err)
 				}
 				exit(0)
 			}
 
-			return nil
+			// Only the CLI that runs the command serves the profiles,
+			// not one that started it in a sandbox.
+			return startDebugServer(cmd)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// Load configuration at the beginning
+			endLoad := startup.Begin("load settings")
 			cfg, err := config.Load()
+			endLoad()
 			if err != nil {
 				// We can't use the logger here because it's not initialized yet.
 				fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
 				// Continue without config...
+				cfg = &config.Settings{}
+			}
+
+			browserTools, _ := cmd.Flags().GetBool("enable-browser-tools")
+			approval, err := approvalMode(cmd)
+			if err != nil {
+				return err
+			}
+			allowedServers, _ := cmd.Flags().GetStringArray("allowed-mcp-server-names")
+			if len(allowedServers) > 0 && cfg != nil {
+				if cfg.MCP == nil {
+					cfg.MCP = &config.MCPSettings{}
+				}
+				cfg.MCP.Allowed = allowedServers
+			}
+			allowedTools, _ := cmd.Flags().GetStringArray("allowed-tools")
+			if err := tools.CheckToolNames(allowedTools, tools.MCPServers(cfg)); err != nil {
+				return fmt.Errorf("invalid --allowed-tools: %w", err)
+			}
+			audioFiles, _ := cmd.Flags().GetStringArray("audio")
+			var attachments []genai.Part
+			for _, path := range audioFiles {
+				blob, err := audio.Load(path)
+				if err != nil {
+					return fmt.Errorf("invalid --audio: %w", err)
+				}
+				attachments = append(attachments, blob)
+			}
+
+			if jsonRPC, _ := cmd.Flags().GetBool("jsonrpc"); jsonRPC {
+				// stdin carries the requests, so it is no prompt.
+				ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+				defer stop()
+				client, model, err := newModel(ctx, cmd, cfg)
+				if err != nil {
+					return err
+				}
+				defer client.Close()
+				if browserTools {
+					enableBrowserTools(cfg)
+				}
+				return noninteractive.ServeJSONRPC(ctx, cfg, model, os.Stdin, os.Stdout, approval, allowedTools)
 			}
 
 			// Non-interactive mode is triggered by providing args, or the --prompt flag
@@ -110,19 +204,38 @@ func newRootCmd() *cobra.Command {
 					disableUpdateNag = true
 				}
 
+				if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+					return fmt.Errorf("--dry-run needs a prompt")
+				}
+
+				generationSettings, err := generationFlags(cmd, cfg)
+				if err != nil {
+					return err
+				}
+				m := tui.InitialModel().WithApprovalMode(approval).WithAllowedTools(allowedTools).WithAllowedMCPServers(allowedServers).WithGeneration(generationSettings).WithAttachments(attachments)
+				if cmd.Flags().Changed("thinking-budget") {
+					budget, _ := cmd.Flags().GetInt32("thinking-budget")
+					m = m.WithThinkingBudget(budget)
+				}
+				if browserTools {
+					m = m.WithBrowserTools()
+				}
+				if debugAPI, _ := cmd.Flags().GetBool("debug-api"); debugAPI {
+					m = m.WithAPIDebug()
+				}
+				if inline, _ := cmd.Flags().GetBool("inline"); inline {
+					m = m.WithInline()
+				}
+				p := tui.NewProgram(m)
 				if !disableUpdateNag {
-					p := tea.NewProgram(tui.InitialModel())
 					go func() {
 						release, err := updatechecker.CheckForUpdates()
 						if err == nil && release != nil {
 							p.Send(tui.UpdateAvailableMsg(release))
 						}
 					}()
-					tui.Start(p)
-				} else {
-					p := tea.NewProgram(tui.InitialModel())
-					tui.Start(p)
 				}
+				tui.Start(p)
 
 				return nil
 			}
@@ -130,48 +243,26 @@ func newRootCmd() *cobra.Command {
 			// Proceed with non-interactive mode
 			ctx := context.Background()
 
-			// Get auth type from config, default to oauth2
-			authType := "oauth2"
-			if cfg.Security != nil && cfg.Security.Auth != nil && cfg.Security.Auth.SelectedType != "" {
-				authType = cfg.Security.Auth.SelectedType
-			}
-
-			// Authenticate
-			authenticator, err := auth.NewAuthenticator(authType)
+			client, model, err := newModel(ctx, cmd, cfg)
 			if err != nil {
 				return err
 			}
-			if err := authenticator.Authenticate(); err != nil {
-				return fmt.Errorf("authentication failed: %w", err)
-			}
-			token, err := authenticator.GetToken()
-			if err != nil {
-				return err
-			}
-
-			// Get model from config or flag
-			modelName, _ := cmd.Flags().GetString("model")
-			if modelName == "" && cfg.Model != nil && cfg.Model.Name != "" {
-				modelName = cfg.Model.Name
-			}
-			if modelName == "" {
-				modelName = "gemini-pro" // A sensible default
-			}
-
-			// Create the client
-			client, err := genai.NewClient(ctx, option.WithAPIKey(token))
-			if err != nil {
-				return fmt.Errorf("failed to create client: %w", err)
-			}
 			defer client.Close()
 
-			model := client.GenerativeModel(modelName)
-
 			// Get output format
 			outputFormat, _ := cmd.Flags().GetString("output-format")
 
+			if browserTools && cfg != nil {
+				enableBrowserTools(cfg)
+			}
+
+			parts := append([]genai.Part{genai.Text(prompt)}, attachments...)
+			if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+				return noninteractive.DryRun(ctx, cfg, model, parts, outputFormat, allowedTools)
+			}
+
 			// Call the new non-interactive runner
-			return noninteractive.Run(ctx, cfg, model, prompt, outputFormat)
+			return noninteractive.Run(ctx, cfg, model, parts, outputFormat, approval, allowedTools)
 		},
 	}
 
@@ -187,14 +278,27 @@ func newRootCmd() *cobra.Command {
 	cmd.PersistentFlags().String("approval-mode", "default", "Set the approval mode (`default`, `auto_edit`, `yolo`)")
 	cmd.PersistentFlags().BoolP("checkpointing", "c", false, "Enable checkpointing of file edits")
 	cmd.PersistentFlags().Bool("experimental-acp", false, "Start the agent in ACP mode")
-	cmd.PersistentFlags().StringArray("allowed-mcp-server-names", []string{}, "Allowed MCP server names")
-	cmd.PersistentFlags().StringArray("allowed-tools", []string{}, "Tools that are allowed to run without confirmation")
+	cmd.PersistentFlags().Bool("jsonrpc", false, "Let a program drive the conversation with JSON-RPC requests on stdin (sendPrompt, cancel, approveTool and getHistory), one per line, answered on stdout")
+	cmd.PersistentFlags().StringArray("allowed-mcp-server-names", []string{}, "The only MCP servers to connect to (see mcp.allowed)")
+	cmd.PersistentFlags().StringArray("allowed-tools", []string{}, "The only tools the model may use; the others are disabled (see tools.core and tools.exclude). run_shell_command(git) allows only the commands starting with git, and runs them without asking")
 	cmd.PersistentFlags().StringArrayP("extensions", "e", []string{}, "A list of extensions to use")
 	cmd.PersistentFlags().BoolP("list-extensions", "l", false, "List all available extensions and exit")
 	cmd.PersistentFlags().StringArray("include-directories", []string{}, "Additional directories to include in the workspace")
 	cmd.PersistentFlags().Bool("screen-reader", false, "Enable screen reader mode")
+	cmd.PersistentFlags().Bool("inline", false, "Run the interactive UI without the alternate screen, printing the conversation into the terminal's scrollback")
+	cmd.PersistentFlags().Bool("enable-browser-tools", false, "Let the model drive a headless browser through a Playwright MCP server")
+	cmd.PersistentFlags().Bool("cache", false, "Reuse the responses of identical non-interactive requests (see model.responseCache.ttl)")
+	cmd.PersistentFlags().Int32("seed", 0, "The sampling seed, for reproducible non-interactive runs (see model.seed)")
+	cmd.PersistentFlags().Int32("thinking-budget", 0, "The tokens 2.5 series models may spend thinking, 0 to turn it off or -1 to let the model decide (see model.thinkingBudget and model.reasoningEffort)")
+	cmd.PersistentFlags().StringArray("audio", []string{}, "An audio file (WAV, MP3, AIFF, AAC, OGG or FLAC) to send with the prompt")
+	cmd.PersistentFlags().StringArray("stop", []string{}, "A sequence that ends the model's responses, up to 5 (see model.generation.stopSequences)")
+	cmd.PersistentFlags().String("response-prefix", "", "Text the responses to your prompts start with, which the model continues (see model.generation.responsePrefix)")
+	cmd.PersistentFlags().Bool("debug-api", false, "Log the raw API requests and responses, with secrets redacted, to ~/.gemini/logs/api-debug.log (toggle with /debug-api)")
+	cmd.PersistentFlags().Bool("dry-run", false, "Print the request a prompt would send, with token counts, instead of sending it")
 	cmd.PersistentFlags().StringP("output-format", "o", "text", "The format of the CLI output (`text`, `json`)")
 	cmd.PersistentFlags().Bool("disable-update-nag", false, "Disable the update notification")
+	cmd.PersistentFlags().String("debug-server", "", "Serve pprof profiles at /debug/pprof/ and expvar runtime metrics at /debug/vars on this localhost address, such as localhost:6060")
+	cmd.PersistentFlags().Bool("profile-startup", false, "Print on exit how long the phases of starting took, such as loading the settings, authenticating and the first token, to stderr")
 
 	// Add extensions commands
 	cmd.AddCommand(extensionsCmd)
@@ -207,33 +311,259 @@ func newRootCmd() *cobra.Command {
 	extensionsCmd.AddCommand(extensionsLinkCmd)
 	extensionsCmd.AddCommand(extensionsNewCmd)
 
+	cmd.AddCommand(sandboxProxyCmd)
+	sandboxProxyCmd.Flags().String("listen", ":8877", "The address to listen on")
+	sandboxProxyCmd.Flags().StringArray("allow", []string{}, "A host the sandbox may reach, such as *.npmjs.org")
+
 	// Add mcp commands
 	cmd.AddCommand(mcpCmd)
 	mcpCmd.AddCommand(mcpAddCmd)
 	mcpCmd.AddCommand(mcpRemoveCmd)
 	mcpCmd.AddCommand(mcpListCmd)
+	mcpCmd.AddCommand(mcpLogsCmd)
+	mcpCmd.AddCommand(mcpTestCmd)
+	mcpCmd.AddCommand(mcpAuthCmd)
 
-	mcpAddCmd.Flags().StringP("scope", "s", "project", "Configuration scope (user or project)")
+	// -s and -e are the persistent --sandbox and --extensions, so the scope
+	// and env have no shorthands.
+	mcpAddCmd.Flags().String("scope", "project", "Configuration scope (user or project)")
 	mcpAddCmd.Flags().StringP("transport", "t", "stdio", "Transport type (stdio, sse, or http)")
-	mcpAddCmd.Flags().StringArrayP("env", "e", []string{}, "Environment variables for stdio transport")
-	mcpAddCmd.Flags().StringArrayP("header", "H", []string{}, "HTTP headers for sse and http transports")
+	mcpAddCmd.Flags().StringArray("env", []string{}, "Environment variables for stdio transport, as KEY=value")
+	mcpAddCmd.Flags().StringArrayP("header", "H", []string{}, "HTTP headers for sse and http transports, as \"Name: value\"")
 	mcpAddCmd.Flags().Int("timeout", 0, "Connection timeout in milliseconds")
 	mcpAddCmd.Flags().Bool("trust", false, "Trust the server and bypass tool call confirmations")
 	mcpAddCmd.Flags().String("description", "", "A description for the server")
 	mcpAddCmd.Flags().StringArray("include-tools", []string{}, "A comma-separated list of tools to include")
 	mcpAddCmd.Flags().StringArray("exclude-tools", []string{}, "A comma-separated list of tools to exclude")
 
-	mcpRemoveCmd.Flags().StringP("scope", "s", "project", "Configuration scope (user or project)")
+	mcpRemoveCmd.Flags().String("scope", "project", "Configuration scope (user or project)")
+
+	mcpLogsCmd.Flags().IntP("lines", "n", 50, "Number of lines to show")
+	mcpLogsCmd.Flags().BoolP("follow", "f", false, "Keep printing new output until interrupted")
+
+	mcpTestCmd.Flags().String("args", "", "Tool arguments as a JSON object")
+
+	mcpAuthCmd.Flags().Bool("logout", false, "Forget the stored token instead")
+
+	// Add index commands
+	cmd.AddCommand(indexCmd)
+	indexCmd.AddCommand(indexBuildCmd)
+	indexCmd.AddCommand(indexUpdateCmd)
+
+	// Add eval command
+	cmd.AddCommand(evalCmd)
+
+	// Add image commands
+	cmd.AddCommand(imageCmd)
+	imageCmd.AddCommand(imageGenerateCmd)
+	// -o is the persistent --output-format, so the output has no shorthand.
+	imageGenerateCmd.Flags().String("output", "image.png", "The file to save the image to; several images are numbered")
+	imageGenerateCmd.Flags().IntP("count", "n", 1, "The number of images to generate, up to 4")
+	imageGenerateCmd.Flags().String("aspect-ratio", "", "The `ratio` of the width to the height of the images: 1:1, 3:4, 4:3, 9:16 or 16:9")
+	imageGenerateCmd.Flags().String("size", "", "The resolution of the images, 1K or 2K, for the models that support it")
+
+	// Add batch command
+	cmd.AddCommand(batchCmd)
+	// -i and -o are persistent flags, so these have no shorthands.
+	batchCmd.Flags().String("input", "-", "The JSON Lines file of prompts, - for stdin")
+	batchCmd.Flags().String("output", "", "The JSON Lines file to write the results to, stdout if not set")
+	batchCmd.Flags().Int("concurrency", 4, "How many prompts run at once")
+	batchCmd.Flags().Int("rpm", 0, "How many requests may start per minute, 0 for no limit")
+	batchCmd.Flags().Int("retries", batch.DefaultRetries, "How many times a rate limited prompt is retried")
+
+	// Add files commands
+	cmd.AddCommand(filesCmd)
+	filesCmd.AddCommand(filesUploadCmd)
+	filesCmd.AddCommand(filesListCmd)
+	filesCmd.AddCommand(filesDeleteCmd)
+
+	// Add sessions commands
+	cmd.AddCommand(sessionsCmd)
+	sessionsCmd.AddCommand(sessionsImportCmd)
+
+	// Add repl command
+	cmd.AddCommand(replCmd)
+	replCmd.Flags().Bool("plain", false, "Read prompts line by line and print plain text, without the full-screen UI")
 
 	return cmd
 }
 
+// startDebugServer serves the profiles and metrics on the address of
+// --debug-server, if it is set, and tells the user where on stderr.
+func startDebugServer(cmd *cobra.Command) error {
+	addr, _ := cmd.Flags().GetString("debug-server")
+	if addr == "" {
+		return nil
+	}
+	listening, err := debugserver.Start(addr)
+	if err != nil {
+		return fmt.Errorf("invalid --debug-server: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Serving profiles at http://%s/debug/pprof/ and metrics at http://%s/debug/vars\n", listening, listening)
+	return nil
+}
+
+// approvalMode returns the approval mode --approval-mode or --yolo selects.
+func approvalMode(cmd *cobra.Command) (tools.ApprovalMode, error) {
+	mode, _ := cmd.Flags().GetString("approval-mode")
+	if yolo, _ := cmd.Flags().GetBool("yolo"); yolo {
+		if cmd.Flags().Changed("approval-mode") && mode != string(tools.ApprovalYolo) {
+			return "", fmt.Errorf("--yolo cannot be used with --approval-mode=%s", mode)
+		}
+		return tools.ApprovalYolo, nil
+	}
+	return tools.ParseApprovalMode(mode)
+}
+
+// newModel authenticates and returns a client and the model selected by
+// the --model flag or the settings. It records the --model, --seed,
+// --thinking-budget, --cache, --stop and --response-prefix flags in
+// cfg.Model, which the non-interactive runner reads.
+func newModel(ctx context.Context, cmd *cobra.Command, cfg *config.Settings) (*genai.Client, *genai.GenerativeModel, error) {
+	// Get auth type from config, default to oauth2
+	authType := "oauth2"
+	if cfg.Security != nil && cfg.Security.Auth != nil && cfg.Security.Auth.SelectedType != "" {
+		authType = cfg.Security.Auth.SelectedType
+	}
+
+	// Authenticate
+	endAuth := startup.Begin("authenticate")
+	defer endAuth()
+	authenticator, _, err := auth.NewAuthenticator(authType)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := authenticator.Authenticate(); err != nil {
+		return nil, nil, fmt.Errorf("authentication failed: %w", err)
+	}
+	token, err := authenticator.GetToken()
+	if err != nil {
+		return nil, nil, err
+	}
+	endAuth()
+
+	// Get model from config or flag
+	modelName, _ := cmd.Flags().GetString("model")
+	if modelName == "" && cfg.Model != nil && cfg.Model.Name != "" {
+		modelName = cfg.Model.Name
+	}
+	if modelName == "" {
+		modelName = "gemini-pro" // A sensible default
+	}
+
+	// The runner keys cached responses by the resolved model name.
+	if cfg.Model == nil {
+		cfg.Model = &config.ModelSettings{}
+	}
+	cfg.Model.Name = modelName
+	if useCache, _ := cmd.Flags().GetBool("cache"); useCache {
+		if cfg.Model.ResponseCache == nil {
+			cfg.Model.ResponseCache = &config.ResponseCacheSettings{}
+		}
+		cfg.Model.ResponseCache.Enabled = true
+	}
+	if cmd.Flags().Changed("seed") {
+		seedValue, _ := cmd.Flags().GetInt32("seed")
+		cfg.Model.Seed = &seedValue
+	}
+	if cmd.Flags().Changed("thinking-budget") {
+		budget, _ := cmd.Flags().GetInt32("thinking-budget")
+		cfg.Model.ThinkingBudget = &budget
+	}
+	budget, think, err := thinking.Budget(cfg.Model)
+	if err != nil {
+		return nil, nil, err
+	}
+	generationSettings, err := generationFlags(cmd, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Create the client
+	clientOptions := []option.ClientOption{option.WithAPIKey(token)}
+	var transport http.RoundTripper = http.DefaultTransport
+	if debugAPI, _ := cmd.Flags().GetBool("debug-api"); debugAPI {
+		path, err := apilog.DefaultPath()
+		if err != nil {
+			return nil, nil, err
+		}
+		apiLog := apilog.New(path)
+		apiLog.SetEnabled(true)
+		transport = apiLog.Transport(transport)
+		fmt.Fprintf(os.Stderr, "Logging the API traffic to %s\n", path)
+	}
+	if cfg.Model.Seed != nil {
+		transport = seed.Transport(transport, *cfg.Model.Seed)
+	}
+	if think {
+		transport = thinking.Transport(transport, budget)
+	}
+	if generationSettings.ResponsePrefix != "" {
+		transport = generation.Transport(transport)
+	}
+	if transport != http.DefaultTransport {
+		clientOptions = append(clientOptions, auth.ClientOption(token, transport))
+	}
+	endClient := startup.Begin("create client")
+	client, err := genai.NewClient(ctx, clientOptions...)
+	endClient()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	model := client.GenerativeModel(modelName)
+	generation.Apply(model, generationSettings)
+	return client, model, nil
+}
+
+// enableBrowserTools turns the browser tools on in cfg, as
+// --enable-browser-tools asks.
+func enableBrowserTools(cfg *config.Settings) {
+	if cfg.Tools == nil {
+		cfg.Tools = &config.ToolsSettings{}
+	}
+	if cfg.Tools.Browser == nil {
+		cfg.Tools.Browser = &config.BrowserSettings{}
+	}
+	cfg.Tools.Browser.Enabled = true
+}
+
+// generationFlags records the --stop and --response-prefix flags in
+// cfg.Model.Generation, and returns the generation settings.
+func generationFlags(cmd *cobra.Command, cfg *config.Settings) (*config.GenerationSettings, error) {
+	if cfg.Model == nil {
+		cfg.Model = &config.ModelSettings{}
+	}
+	if cfg.Model.Generation == nil {
+		cfg.Model.Generation = &config.GenerationSettings{}
+	}
+	g := cfg.Model.Generation
+	if cmd.Flags().Changed("stop") {
+		g.StopSequences, _ = cmd.Flags().GetStringArray("stop")
+	}
+	if cmd.Flags().Changed("response-prefix") {
+		g.ResponsePrefix, _ = cmd.Flags().GetString("response-prefix")
+	}
+	if err := generation.Check(g); err != nil {
+		return nil, fmt.Errorf("invalid stop sequences: %w", err)
+	}
+	return g, nil
+}
+
 func init() {
 	rootCmd = newRootCmd()
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	executed, err := rootCmd.ExecuteC()
+	if executed != nil {
+		if profile, _ := executed.Flags().GetBool("profile-startup"); profile {
+			startup.Report(os.Stderr, executed.CommandPath())
+		}
+	}
+	if shutdownErr := shutdown.Run(shutdownTimeout); shutdownErr != nil {
+		fmt.Fprintf(os.Stderr, "error during shutdown: %v\n", shutdownErr)
+	}
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
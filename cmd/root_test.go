@@ -3,10 +3,13 @@ package cmd
 import (
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 func TestPromptResolution(t *testing.T) {
@@ -90,4 +93,37 @@ func TestPromptResolution(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+// TestBrokenSettings ensures that settings which cannot be read are warned
+// about rather than crashing the start of the TUI.
+func TestBrokenSettings(t *testing.T) {
+	home := t.TempDir()
+	defer config.SetUserHomeDirForTesting(home, nil)()
+	t.Chdir(t.TempDir())
+	if err := os.MkdirAll(filepath.Join(home, ".gemini"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".gemini", "settings.toml"), []byte("model = [broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stdin, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	originalStdin := os.Stdin
+	os.Stdin = stdin
+	defer func() { os.Stdin = originalStdin }()
+
+	// More stop sequences than the API takes end the start of the TUI once
+	// the flags were read along with the settings.
+	stop := rootCmd.Flags().Lookup("stop")
+	defer func() {
+		stop.Value.(pflag.SliceValue).Replace(nil)
+		stop.Changed = false
+	}()
+	rootCmd.SetArgs([]string{"--stop", "a", "--stop", "b", "--stop", "c", "--stop", "d", "--stop", "e", "--stop", "f"})
+	if err := rootCmd.Execute(); err == nil || !strings.Contains(err.Error(), "invalid stop sequences") {
+		t.Errorf("Execute() = %v, want the stop sequence refused", err)
+	}
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/sandbox"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// sandboxProxyCmd runs the allowlist proxy of a restricted sandbox, in a
+// container of its own started by the sandbox.
+var sandboxProxyCmd = &cobra.Command{
+	Use:    "sandbox-proxy",
+	Short:  "Serve the allowlist proxy of a restricted sandbox",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		listen, _ := cmd.Flags().GetString("listen")
+		allowed, _ := cmd.Flags().GetStringArray("allow")
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return sandbox.ServeProxy(ctx, listen, allowed)
+	},
+}
+
+// sandboxOptions returns the command line of cmd as parsed, for the CLI in
+// the sandbox to continue with.
+func sandboxOptions(cmd *cobra.Command, args []string) *sandbox.Options {
+	opts := &sandbox.Options{Args: args, Flags: map[string][]string{}}
+	for c := cmd; c.HasParent(); c = c.Parent() {
+		opts.Command = append([]string{c.Name()}, opts.Command...)
+	}
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if list, ok := f.Value.(pflag.SliceValue); ok {
+			opts.Flags[f.Name] = list.GetSlice()
+		} else {
+			opts.Flags[f.Name] = []string{f.Value.String()}
+		}
+	})
+	return opts
+}
+
+// applySandboxOptions sets the flags of cmd to the options the CLI outside
+// the sandbox resolved.
+func applySandboxOptions(cmd *cobra.Command, opts *sandbox.Options) error {
+	for name, values := range opts.Flags {
+		f := cmd.Flags().Lookup(name)
+		if f == nil {
+			return fmt.Errorf("unknown flag --%s in %s", name, sandbox.OptionsEnv)
+		}
+		var err error
+		if list, ok := f.Value.(pflag.SliceValue); ok {
+			err = list.Replace(values)
+		} else if len(values) != 1 {
+			err = fmt.Errorf("want one value, got %d", len(values))
+		} else {
+			err = f.Value.Set(values[0])
+		}
+		if err != nil {
+			return fmt.Errorf("invalid flag --%s in %s: %w", name, sandbox.OptionsEnv, err)
+		}
+		f.Changed = true
+	}
+	return nil
+}
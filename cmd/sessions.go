@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/session"
+	"github.com/spf13/cobra"
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Manage saved sessions",
+}
+
+var sessionsImportCmd = &cobra.Command{
+	Use:   "import <path>...",
+	Short: "Import the sessions and checkpoints of the Node gemini-cli",
+	Long: `Imports conversations the Node gemini-cli saved into the sessions of the
+current project: the sessions it records in ~/.gemini/tmp/<project-hash>/chats
+and the checkpoints /chat save writes to
+~/.gemini/tmp/<project-hash>/checkpoint-<tag>.json. Given a directory, imports
+the session-*.json and checkpoint-*.json files in it. Importing a
+conversation again replaces the earlier import.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		var paths []string
+		for _, arg := range args {
+			info, err := os.Stat(arg)
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				paths = append(paths, arg)
+				continue
+			}
+			entries, err := os.ReadDir(arg)
+			if err != nil {
+				return err
+			}
+			found := false
+			for _, e := range entries {
+				name := e.Name()
+				if !e.IsDir() && strings.HasSuffix(name, ".json") && (strings.HasPrefix(name, "session-") || strings.HasPrefix(name, "checkpoint-")) {
+					paths = append(paths, filepath.Join(arg, name))
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("%s has no sessions or checkpoints", arg)
+			}
+		}
+
+		out := cmd.OutOrStdout()
+		for _, path := range paths {
+			dest, n, err := importSession(cmd.Context(), path, wd)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			fmt.Fprintf(out, "Imported %s as %s (%d messages)\n", path, dest, n)
+		}
+		return nil
+	},
+}
+
+// importSession imports the Node CLI conversation at path into the
+// sessions of the project rooted at projectRoot, returning where it was
+// saved and how many messages it has.
+func importSession(ctx context.Context, path, projectRoot string) (string, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", 0, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", 0, err
+	}
+	s, err := session.ImportNode(data, projectRoot, info.ModTime())
+	if err != nil {
+		return "", 0, err
+	}
+	dest, err := s.Path()
+	if err != nil {
+		return "", 0, err
+	}
+	if err := s.Save(ctx); err != nil {
+		return "", 0, err
+	}
+	return dest, len(s.Messages), nil
+}
@@ -0,0 +1,218 @@
+// Package apilog logs the raw traffic between the CLI and the Gemini API
+// for debugging prompts: request bodies, response chunks as they stream in
+// and the function call JSON they carry. API keys, tokens and other secrets
+// are redacted before anything is written.
+package apilog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+// redacted replaces secrets in the log.
+const redacted = "REDACTED"
+
+// secretHeaders are the headers whose values are never logged.
+var secretHeaders = []string{"Authorization", "X-Goog-Api-Key", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+
+// secretFields matches JSON string fields whose names suggest a secret.
+var secretFields = regexp.MustCompile(`("(?i:[a-z_]*(?:api_?key|token|secret|password|credentials?))"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+// DefaultPath returns the file --debug-api logs to.
+func DefaultPath() (string, error) {
+	dir, err := config.LogDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "api-debug.log"), nil
+}
+
+// Logger appends the traffic of the transports it wraps to a file while it
+// is enabled. It can be toggled at any time.
+type Logger struct {
+	path    string
+	enabled atomic.Bool
+	seq     atomic.Int64
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// New returns a disabled logger writing to path.
+func New(path string) *Logger {
+	return &Logger{path: path}
+}
+
+// Path returns the file the logger writes to.
+func (l *Logger) Path() string { return l.path }
+
+// Enabled reports whether traffic is being logged.
+func (l *Logger) Enabled() bool { return l.enabled.Load() }
+
+// SetEnabled starts or stops logging. Requests in flight keep being logged
+// until they complete.
+func (l *Logger) SetEnabled(enabled bool) { l.enabled.Store(enabled) }
+
+// Close closes the log file.
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// Transport returns a round tripper logging the traffic it sends through
+// base while the logger is enabled.
+func (l *Logger) Transport(base http.RoundTripper) http.RoundTripper {
+	return &transport{base: base, log: l}
+}
+
+// write appends an entry to the log, opening it first if needed. Failing to
+// log must not fail the request, so errors are only reported on stderr.
+func (l *Logger) write(entry string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not open the API log: %v\n", err)
+			return
+		}
+		// The log holds prompts and file contents; keep it private.
+		f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not open the API log: %v\n", err)
+			return
+		}
+		l.f = f
+	}
+	if _, err := io.WriteString(l.f, entry); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not write the API log: %v\n", err)
+	}
+}
+
+type transport struct {
+	base http.RoundTripper
+	log  *Logger
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.log.Enabled() {
+		return t.base.RoundTrip(req)
+	}
+	id := t.log.seq.Add(1)
+	start := time.Now()
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	}
+	var entry strings.Builder
+	fmt.Fprintf(&entry, "=== #%d %s %s %s\n", id, start.Format(time.RFC3339Nano), req.Method, redactURL(req.URL))
+	writeHeaders(&entry, req.Header)
+	entry.WriteString("\n")
+	entry.WriteString(formatBody(body))
+	t.log.write(entry.String())
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		t.log.write(fmt.Sprintf("--- #%d failed after %s: %v\n\n", id, time.Since(start).Round(time.Millisecond), err))
+		return nil, err
+	}
+	entry.Reset()
+	fmt.Fprintf(&entry, "--- #%d %s after %s\n", id, resp.Status, time.Since(start).Round(time.Millisecond))
+	writeHeaders(&entry, resp.Header)
+	t.log.write(entry.String())
+	resp.Body = &loggedBody{ReadCloser: resp.Body, log: t.log, id: id, start: start}
+	return resp, nil
+}
+
+// loggedBody logs a response body chunk by chunk as the client reads it,
+// so that streamed responses show up as they arrive.
+type loggedBody struct {
+	io.ReadCloser
+	log   *Logger
+	id    int64
+	start time.Time
+	done  bool
+}
+
+func (b *loggedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.log.write(fmt.Sprintf("<<< #%d +%s\n%s\n", b.id, time.Since(b.start).Round(time.Millisecond), redact(string(p[:n]))))
+	}
+	if err != nil && !b.done {
+		b.done = true
+		if err == io.EOF {
+			b.log.write(fmt.Sprintf("--- #%d done after %s\n\n", b.id, time.Since(b.start).Round(time.Millisecond)))
+		} else {
+			b.log.write(fmt.Sprintf("--- #%d read failed after %s: %v\n\n", b.id, time.Since(b.start).Round(time.Millisecond), err))
+		}
+	}
+	return n, err
+}
+
+func writeHeaders(w io.Writer, h http.Header) {
+	for name, values := range h {
+		for _, v := range values {
+			for _, secret := range secretHeaders {
+				if strings.EqualFold(name, secret) {
+					v = redacted
+				}
+			}
+			fmt.Fprintf(w, "%s: %s\n", name, v)
+		}
+	}
+}
+
+// redactURL hides the key query parameter.
+func redactURL(u *url.URL) string {
+	q := u.Query()
+	if q.Has("key") {
+		q.Set("key", redacted)
+		c := *u
+		c.RawQuery = q.Encode()
+		return c.String()
+	}
+	return u.String()
+}
+
+// formatBody indents JSON bodies for reading and redacts them.
+func formatBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var indented bytes.Buffer
+	if json.Indent(&indented, body, "", "  ") == nil {
+		body = indented.Bytes()
+	}
+	return redact(string(body)) + "\n\n"
+}
+
+func redact(s string) string {
+	return secretFields.ReplaceAllString(s, `$1"`+redacted+`"`)
+}
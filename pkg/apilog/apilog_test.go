@@ -0,0 +1,77 @@
+package apilog
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"apiKey":"sk-live"`) {
+			t.Errorf("the request body was not passed on as is: %s", body)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"candidates":[{"content":{"parts":[{"functionCall":{"name":"read_file","args":{"path":"a.go"}}}]}}]}`+"\n\n")
+		w.(http.Flusher).Flush()
+		fmt.Fprint(w, `data: {"candidates":[{"content":{"parts":[{"text":"done"}]}}]}`+"\n\n")
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "logs", "api.log")
+	l := New(path)
+	defer l.Close()
+	client := &http.Client{Transport: l.Transport(http.DefaultTransport)}
+	send := func() {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/v1beta/models/gemini-pro:streamGenerateContent?alt=sse&key=AIza-secret",
+			strings.NewReader(`{"contents":[{"parts":[{"text":"hi"}]}],"apiKey":"sk-live"}`))
+		req.Header.Set("X-Goog-Api-Key", "AIza-secret")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	send()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Expected nothing logged while disabled, got %v", err)
+	}
+
+	l.SetEnabled(true)
+	send()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := string(data)
+	for _, want := range []string{
+		"=== #1 ", "POST ", "key=REDACTED", "X-Goog-Api-Key: REDACTED",
+		"\"text\": \"hi\"", `"apiKey": "REDACTED"`,
+		"--- #1 200 OK", `"functionCall":{"name":"read_file","args":{"path":"a.go"}}`, `"text":"done"`, "--- #1 done",
+	} {
+		if !strings.Contains(log, want) {
+			t.Errorf("log lacks %q:\n%s", want, log)
+		}
+	}
+	if strings.Contains(log, "AIza-secret") || strings.Contains(log, "sk-live") {
+		t.Errorf("log leaks a secret:\n%s", log)
+	}
+	if info, err := os.Stat(path); err == nil && runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("log mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	l.SetEnabled(false)
+	send()
+	if after, _ := os.ReadFile(path); len(after) != len(data) {
+		t.Error("Expected nothing logged after disabling")
+	}
+}
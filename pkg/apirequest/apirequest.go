@@ -0,0 +1,35 @@
+// Package apirequest rewrites the bodies of the generation requests the
+// client library sends, for the settings it does not expose, such as the
+// seed, the thinking budget, the response prefix and spoken responses.
+package apirequest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// IsGeneration reports whether req is a generateContent or
+// streamGenerateContent request with a body.
+func IsGeneration(req *http.Request) bool {
+	return req.Body != nil && req.Method == http.MethodPost && strings.HasSuffix(strings.ToLower(req.URL.Path), "generatecontent")
+}
+
+// RewriteBody returns a copy of req with its body rewritten by rewrite. A
+// body rewrite fails on, such as one that is not JSON, is sent as it is.
+func RewriteBody(req *http.Request, rewrite func(body []byte) ([]byte, error)) (*http.Request, error) {
+	req = req.Clone(req.Context())
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if body, err := rewrite(data); err == nil {
+		data = body
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil }
+	req.ContentLength = int64(len(data))
+	return req, nil
+}
@@ -0,0 +1,48 @@
+package apirequest
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestIsGeneration(t *testing.T) {
+	for url, want := range map[string]bool{
+		"https://example.com/v1beta/models/gemini-pro:generateContent":       true,
+		"https://example.com/v1beta/models/gemini-pro:streamGenerateContent": true,
+		"https://example.com/v1beta/models/gemini-pro:countTokens":           false,
+	} {
+		req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader("{}"))
+		if got := IsGeneration(req); got != want {
+			t.Errorf("IsGeneration(%s) = %v, want %v", url, got, want)
+		}
+	}
+}
+
+func TestRewriteBody(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/v1beta/models/gemini-pro:generateContent", strings.NewReader(`{"a":1}`))
+	rewritten, err := RewriteBody(req, func(body []byte) ([]byte, error) {
+		return []byte(`{"a":2}`), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(rewritten.Body)
+	again, _ := rewritten.GetBody()
+	retried, _ := io.ReadAll(again)
+	if string(body) != `{"a":2}` || string(retried) != `{"a":2}` || rewritten.ContentLength != 7 {
+		t.Errorf("body = %s, retried %s, length %d; want the rewritten body", body, retried, rewritten.ContentLength)
+	}
+
+	// A body that cannot be rewritten is sent as it is.
+	req, _ = http.NewRequest(http.MethodPost, "https://example.com/v1beta/models/gemini-pro:generateContent", strings.NewReader("not json"))
+	kept, err := RewriteBody(req, func([]byte) ([]byte, error) { return nil, errors.New("invalid") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(kept.Body); string(body) != "not json" {
+		t.Errorf("body = %s, want it unchanged", body)
+	}
+}
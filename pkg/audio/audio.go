@@ -0,0 +1,202 @@
+// Package audio attaches audio files to prompts, and asks models that can
+// speak for spoken responses, which it saves as WAV files and plays. The
+// API accepts generationConfig.responseModalities and speechConfig, but the
+// client library does not expose them, so they are added to the request
+// bodies on their way out.
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/apirequest"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// MaxInlineSize is the size of the largest audio file sent in a request,
+// which the API caps at 20 MB along with the rest of it.
+const MaxInlineSize = 20 << 20
+
+// DefaultVoice is the prebuilt voice of spoken responses.
+const DefaultVoice = "Kore"
+
+// mimeTypes are the audio formats the API accepts, by file extension.
+var mimeTypes = map[string]string{
+	".wav":  "audio/wav",
+	".mp3":  "audio/mp3",
+	".aif":  "audio/aiff",
+	".aiff": "audio/aiff",
+	".aac":  "audio/aac",
+	".ogg":  "audio/ogg",
+	".flac": "audio/flac",
+}
+
+// Load reads the audio file at path for sending in a prompt.
+func Load(path string) (genai.Blob, error) {
+	mimeType, ok := mimeTypes[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return genai.Blob{}, fmt.Errorf("%s: unsupported audio format (want WAV, MP3, AIFF, AAC, OGG or FLAC)", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return genai.Blob{}, err
+	}
+	if info.Size() > MaxInlineSize {
+		return genai.Blob{}, fmt.Errorf("%s: %d bytes, more than the %d a request can take", path, info.Size(), MaxInlineSize)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return genai.Blob{}, err
+	}
+	return genai.Blob{MIMEType: mimeType, Data: data}, nil
+}
+
+// IsAudio reports whether blob holds audio.
+func IsAudio(blob genai.Blob) bool {
+	return strings.HasPrefix(strings.ToLower(blob.MIMEType), "audio/")
+}
+
+type speechKey struct{}
+
+// WithSpeech returns a context whose generation requests ask for a spoken
+// response in voice, when sent through Transport.
+func WithSpeech(ctx context.Context, voice string) context.Context {
+	if voice == "" {
+		voice = DefaultVoice
+	}
+	return context.WithValue(ctx, speechKey{}, voice)
+}
+
+// Transport returns a round tripper that asks for spoken responses in the
+// generation requests it passes to base whose context was made by
+// WithSpeech. Their tools are dropped, which the speech models refuse.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return &transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	voice, _ := req.Context().Value(speechKey{}).(string)
+	if voice == "" || !apirequest.IsGeneration(req) {
+		return t.base.RoundTrip(req)
+	}
+
+	req, err := apirequest.RewriteBody(req, func(body []byte) ([]byte, error) { return withSpeech(body, voice) })
+	if err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// withSpeech sets generationConfig.responseModalities and speechConfig in a
+// request body and drops its tools, leaving the rest as it is.
+func withSpeech(body []byte, voice string) ([]byte, error) {
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, err
+	}
+	config := map[string]json.RawMessage{}
+	if raw, ok := request["generationConfig"]; ok {
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+	}
+	config["responseModalities"], _ = json.Marshal([]string{"AUDIO"})
+	config["speechConfig"], _ = json.Marshal(map[string]any{
+		"voiceConfig": map[string]any{"prebuiltVoiceConfig": map[string]string{"voiceName": voice}},
+	})
+	var err error
+	if request["generationConfig"], err = json.Marshal(config); err != nil {
+		return nil, err
+	}
+	delete(request, "tools")
+	delete(request, "toolConfig")
+	return json.Marshal(request)
+}
+
+// WAV returns the audio of blob as a WAV file. The models speak in raw
+// 16-bit PCM, such as audio/L16;codec=pcm;rate=24000, which gets a WAV
+// header; other formats are returned as they are.
+func WAV(blob genai.Blob) ([]byte, string, error) {
+	mediaType, params, err := mime.ParseMediaType(blob.MIMEType)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid audio type %q: %w", blob.MIMEType, err)
+	}
+	if mediaType != "audio/l16" && mediaType != "audio/pcm" {
+		ext := ".bin"
+		if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+			ext = exts[len(exts)-1]
+		}
+		return blob.Data, ext, nil
+	}
+	rate := 24000
+	if r, err := strconv.Atoi(params["rate"]); err == nil && r > 0 {
+		rate = r
+	}
+	const channels, bits = 1, 16
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(36+len(blob.Data)))
+	b.WriteString("WAVEfmt ")
+	for _, v := range []any{
+		uint32(16), uint16(1), uint16(channels), uint32(rate),
+		uint32(rate * channels * bits / 8), uint16(channels * bits / 8), uint16(bits),
+	} {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(len(blob.Data)))
+	b.Write(blob.Data)
+	return b.Bytes(), ".wav", nil
+}
+
+// Save writes the audio of blob to a temp file, as WAV if the model spoke
+// in raw PCM, and returns its path.
+func Save(blob genai.Blob) (string, error) {
+	data, ext, err := WAV(blob)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp("", "gemini-audio-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// players are the commands Play tries, in order.
+var players = [][]string{
+	{"afplay"},
+	{"paplay"},
+	{"aplay", "-q"},
+	{"ffplay", "-nodisp", "-autoexit", "-loglevel", "quiet"},
+}
+
+// Play plays the audio file at path with the first player installed, and
+// waits for it to finish.
+func Play(ctx context.Context, path string) error {
+	for _, p := range players {
+		if _, err := exec.LookPath(p[0]); err != nil {
+			continue
+		}
+		return exec.CommandContext(ctx, p[0], append(p[1:], path)...).Run()
+	}
+	return fmt.Errorf("no audio player found (tried afplay, paplay, aplay and ffplay)")
+}
@@ -0,0 +1,99 @@
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/auth"
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "memo.MP3")
+	os.WriteFile(path, []byte("ID3"), 0644)
+	blob, err := Load(path)
+	if err != nil || blob.MIMEType != "audio/mp3" || string(blob.Data) != "ID3" {
+		t.Errorf("Load = %v, %v", blob, err)
+	}
+	if _, err := Load(filepath.Join(dir, "memo.txt")); err == nil {
+		t.Error("Expected an error for a file that is not audio")
+	}
+}
+
+func TestTransportAsksForSpeech(t *testing.T) {
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		request = nil
+		json.Unmarshal(data, &request)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(w, `{"candidates":[{"content":{"parts":[{"inlineData":{"mimeType":"audio/L16;codec=pcm;rate=24000","data":"AAA="}}]}}]}`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey("key"), option.WithEndpoint(server.URL),
+		auth.ClientOption("key", Transport(http.DefaultTransport)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	model := client.GenerativeModel("gemini-2.5-flash-preview-tts")
+	model.Tools = []*genai.Tool{{FunctionDeclarations: []*genai.FunctionDeclaration{{Name: "f"}}}}
+
+	if _, err := model.GenerateContent(ctx, genai.Text("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if request["tools"] == nil {
+		t.Errorf("Expected a request without speech to be left alone, got %v", request)
+	}
+
+	resp, err := model.GenerateContent(WithSpeech(ctx, "Puck"), genai.Text("hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, _ := request["generationConfig"].(map[string]any)
+	modalities, _ := json.Marshal(config["responseModalities"])
+	speech, _ := json.Marshal(config["speechConfig"])
+	if string(modalities) != `["AUDIO"]` || string(speech) != `{"voiceConfig":{"prebuiltVoiceConfig":{"voiceName":"Puck"}}}` {
+		t.Errorf("generationConfig = %v", config)
+	}
+	if request["tools"] != nil {
+		t.Errorf("Expected the tools to be dropped, got %v", request["tools"])
+	}
+	if blob, ok := resp.Candidates[0].Content.Parts[0].(genai.Blob); !ok || !IsAudio(blob) {
+		t.Errorf("Expected an audio response, got %v", resp.Candidates[0].Content.Parts)
+	}
+}
+
+func TestWAV(t *testing.T) {
+	pcm := []byte{1, 2, 3, 4}
+	data, ext, err := WAV(genai.Blob{MIMEType: "audio/L16;codec=pcm;rate=16000", Data: pcm})
+	if err != nil || ext != ".wav" {
+		t.Fatalf("WAV = %q, %v", ext, err)
+	}
+	if len(data) != 44+len(pcm) || string(data[:4]) != "RIFF" || string(data[8:16]) != "WAVEfmt " || string(data[36:40]) != "data" {
+		t.Fatalf("Expected a WAV header, got %q", data[:44])
+	}
+	if rate := binary.LittleEndian.Uint32(data[24:28]); rate != 16000 {
+		t.Errorf("sample rate = %d", rate)
+	}
+	if size := binary.LittleEndian.Uint32(data[40:44]); size != uint32(len(pcm)) {
+		t.Errorf("data size = %d", size)
+	}
+
+	data, ext, err = WAV(genai.Blob{MIMEType: "audio/mpeg", Data: pcm})
+	if err != nil || string(data) != string(pcm) {
+		t.Errorf("Expected other formats to be kept, got %q, %q, %v", data, ext, err)
+	}
+}
@@ -0,0 +1,25 @@
+package auth
+
+import (
+	"net/http"
+
+	"google.golang.org/api/option"
+)
+
+// ClientOption returns a client option sending the requests of a Gemini
+// client through transport. A client given its own HTTP client ignores
+// option.WithAPIKey, so the key is added to each request here.
+func ClientOption(apiKey string, transport http.RoundTripper) option.ClientOption {
+	return option.WithHTTPClient(&http.Client{Transport: &keyTransport{base: transport, apiKey: apiKey}})
+}
+
+type keyTransport struct {
+	base   http.RoundTripper
+	apiKey string
+}
+
+func (t *keyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("x-goog-api-key", t.apiKey)
+	return t.base.RoundTrip(req)
+}
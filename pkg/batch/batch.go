@@ -0,0 +1,218 @@
+// Package batch runs many independent prompts, as gemini batch does for
+// bulk classification and transformation: several at a time, no faster
+// than a rate limit allows, retrying those the API turns away for going
+// too fast.
+package batch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// Statuses of results.
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+// DefaultRetries is how many times a prompt the API rate limited is
+// retried unless Options say otherwise.
+const DefaultRetries = 3
+
+// retryDelay is the wait before the first retry of a rate limited prompt,
+// which doubles with each retry.
+var retryDelay = 5 * time.Second
+
+// Item is a prompt of the input, a JSON object on a line of its own:
+//
+//	{"id": "review-17", "prompt": "Classify the sentiment of: ..."}
+type Item struct {
+	// ID identifies the item in the results; it defaults to its line
+	// number.
+	ID     string `json:"id"`
+	Prompt string `json:"prompt"`
+}
+
+// Result is the outcome of an item.
+type Result struct {
+	ID       string        `json:"id"`
+	Status   string        `json:"status"`
+	Response string        `json:"response,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Attempts int           `json:"attempts"`
+	Time     time.Duration `json:"durationNs"`
+}
+
+// Options control how items are run.
+type Options struct {
+	// Concurrency is how many items run at once, at least 1.
+	Concurrency int
+	// RequestsPerMinute bounds how many attempts start per minute; 0 means
+	// no bound.
+	RequestsPerMinute int
+	// Retries is how many times an item the API rate limited is retried.
+	Retries int
+}
+
+// Converse sends prompt and returns the model's response.
+type Converse func(ctx context.Context, prompt string) (string, error)
+
+// Load reads the items of a JSON Lines input. Blank lines are skipped.
+func Load(r io.Reader) ([]Item, error) {
+	var items []Item
+	seen := map[string]int{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var item Item
+		if err := json.Unmarshal([]byte(text), &item); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if strings.TrimSpace(item.Prompt) == "" {
+			return nil, fmt.Errorf("line %d: no prompt", line)
+		}
+		if item.ID == "" {
+			item.ID = strconv.Itoa(line)
+		}
+		if first, ok := seen[item.ID]; ok {
+			return nil, fmt.Errorf("line %d: id %q is already used on line %d", line, item.ID, first)
+		}
+		seen[item.ID] = line
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, errors.New("the input has no prompts")
+	}
+	return items, nil
+}
+
+// Run runs items with converse as opts allow, passing each result to
+// report as it is known, one at a time, and returns the results in the
+// order of the items. Once ctx is done, the items not started yet fail.
+func Run(ctx context.Context, items []Item, opts Options, converse Converse, report func(Result)) []Result {
+	results := make([]Result, len(items))
+	limit := newLimiter(opts.RequestsPerMinute)
+	var (
+		wg       sync.WaitGroup
+		reportMu sync.Mutex
+	)
+	next := make(chan int)
+	for range max(opts.Concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = runItem(ctx, items[i], opts.Retries, limit, converse)
+				if report != nil {
+					reportMu.Lock()
+					report(results[i])
+					reportMu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range items {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
+
+// runItem runs item, retrying it up to retries times while the API rate
+// limits it.
+func runItem(ctx context.Context, item Item, retries int, limit *limiter, converse Converse) Result {
+	start := time.Now()
+	r := Result{ID: item.ID}
+	delay := retryDelay
+	for {
+		r.Attempts++
+		var response string
+		err := limit.wait(ctx)
+		if err == nil {
+			response, err = converse(ctx, item.Prompt)
+		}
+		if err == nil {
+			r.Status, r.Response = StatusOK, response
+			break
+		}
+		if r.Attempts > retries || !rateLimited(err) {
+			r.Status, r.Error = StatusError, err.Error()
+			break
+		}
+		select {
+		case <-ctx.Done():
+			r.Status, r.Error = StatusError, ctx.Err().Error()
+			r.Time = time.Since(start)
+			return r
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+	r.Time = time.Since(start)
+	return r
+}
+
+// rateLimited reports whether err is the API turning a request away for
+// exceeding a quota.
+func rateLimited(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusTooManyRequests
+}
+
+// limiter spaces the attempts evenly so that no more than a rate start
+// per minute.
+type limiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// newLimiter returns a limiter to perMinute attempts per minute, or nil
+// for none.
+func newLimiter(perMinute int) *limiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &limiter{interval: time.Minute / time.Duration(perMinute)}
+}
+
+// wait waits for the turn of an attempt.
+func (l *limiter) wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	l.mu.Lock()
+	at := time.Now()
+	if l.next.After(at) {
+		at = l.next
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Until(at)):
+		return nil
+	}
+}
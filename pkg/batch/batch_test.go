@@ -0,0 +1,117 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestLoad(t *testing.T) {
+	items, err := Load(strings.NewReader(`{"id": "a", "prompt": "first"}
+
+{"prompt": "second"}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0] != (Item{ID: "a", Prompt: "first"}) || items[1] != (Item{ID: "3", Prompt: "second"}) {
+		t.Errorf("items = %v", items)
+	}
+
+	for input, want := range map[string]string{
+		`{"prompt": }`: "line 1",
+		`{"id": "a"}`:  "line 1: no prompt",
+		"{\"prompt\": \"x\"}\n{\"id\": \"1\", \"prompt\": \"y\"}": `id "1" is already used on line 1`,
+		"\n": "no prompts",
+	} {
+		if _, err := Load(strings.NewReader(input)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Load(%q) = %v, want an error containing %q", input, err, want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	var items []Item
+	for i := range 10 {
+		items = append(items, Item{ID: fmt.Sprint(i), Prompt: fmt.Sprint("prompt ", i)})
+	}
+	var running, most atomic.Int32
+	var reported []string
+	results := Run(context.Background(), items, Options{Concurrency: 3}, func(ctx context.Context, prompt string) (string, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for m := most.Load(); n > m && !most.CompareAndSwap(m, n); m = most.Load() {
+		}
+		time.Sleep(10 * time.Millisecond)
+		if prompt == "prompt 4" {
+			return "", errors.New("blocked")
+		}
+		return strings.ToUpper(prompt), nil
+	}, func(r Result) { reported = append(reported, r.ID) })
+
+	if got := most.Load(); got != 3 {
+		t.Errorf("Expected 3 prompts at once, got at most %d", got)
+	}
+	if len(reported) != len(items) {
+		t.Errorf("reported %v", reported)
+	}
+	for i, r := range results {
+		switch {
+		case r.ID != items[i].ID:
+			t.Errorf("results[%d] is for item %s", i, r.ID)
+		case i == 4 && (r.Status != StatusError || r.Error != "blocked"):
+			t.Errorf("results[4] = %+v, want the error", r)
+		case i != 4 && (r.Status != StatusOK || r.Response != fmt.Sprint("PROMPT ", i)):
+			t.Errorf("results[%d] = %+v", i, r)
+		}
+	}
+}
+
+func TestRunRetriesRateLimited(t *testing.T) {
+	defer func(d time.Duration) { retryDelay = d }(retryDelay)
+	retryDelay = time.Millisecond
+
+	var mu sync.Mutex
+	calls := map[string]int{}
+	converse := func(ctx context.Context, prompt string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[prompt]++
+		if prompt == "busy" || calls[prompt] < 3 {
+			return "", fmt.Errorf("sending: %w", &googleapi.Error{Code: http.StatusTooManyRequests, Message: "quota exceeded"})
+		}
+		return "done", nil
+	}
+	results := Run(context.Background(), []Item{{ID: "1", Prompt: "eventually"}, {ID: "2", Prompt: "busy"}},
+		Options{Retries: 2}, converse, nil)
+	if r := results[0]; r.Status != StatusOK || r.Attempts != 3 {
+		t.Errorf("results[0] = %+v, want success on the third attempt", r)
+	}
+	if r := results[1]; r.Status != StatusError || r.Attempts != 3 || !strings.Contains(r.Error, "quota exceeded") {
+		t.Errorf("results[1] = %+v, want the error after 3 attempts", r)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(600) // one every 100ms
+	start := time.Now()
+	for range 3 {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d < 200*time.Millisecond {
+		t.Errorf("3 attempts started within %s, want them 100ms apart", d)
+	}
+	if newLimiter(0) != nil {
+		t.Error("Expected no limiter without a rate")
+	}
+}
@@ -0,0 +1,136 @@
+package browser
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/mcp"
+	"github.com/google-gemini/gemini-cli-go/pkg/shutdown"
+)
+
+// serverName names the browser's MCP server in logs, e.g. for /mcp logs.
+const serverName = "browser"
+
+// DefaultServer runs the Playwright MCP server in a headless, isolated
+// browser profile.
+var DefaultServer = config.MCPServer{
+	Command: "npx",
+	Args:    []string{"-y", "@playwright/mcp@latest", "--headless", "--isolated"},
+}
+
+// Session drives a browser through a Playwright-compatible MCP server,
+// which offers the browser_navigate, browser_take_screenshot and
+// browser_evaluate tools. The server is started on first use.
+type Session struct {
+	server config.MCPServer
+
+	mu     sync.Mutex
+	client *mcp.Client
+}
+
+// New returns a session using the server configured in tools.browser.server,
+// or DefaultServer.
+func New(settings *config.BrowserSettings) *Session {
+	server := DefaultServer
+	if settings != nil && settings.Server != nil {
+		server = *settings.Server
+	}
+	return &Session{server: server}
+}
+
+// Screenshot is a captured image of the page.
+type Screenshot struct {
+	Data     []byte
+	MimeType string
+}
+
+// Navigate opens url and returns the server's description of the loaded
+// page, such as its title.
+func (s *Session) Navigate(ctx context.Context, url string) (string, error) {
+	result, err := s.call(ctx, "browser_navigate", map[string]any{"url": url})
+	if err != nil {
+		return "", err
+	}
+	return text(result), nil
+}
+
+// Screenshot captures the visible part of the page.
+func (s *Session) Screenshot(ctx context.Context) (*Screenshot, error) {
+	result, err := s.call(ctx, "browser_take_screenshot", nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range result.Content {
+		if c.Type == "image" {
+			data, err := base64.StdEncoding.DecodeString(c.Data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid screenshot data: %w", err)
+			}
+			return &Screenshot{Data: data, MimeType: c.MimeType}, nil
+		}
+	}
+	return nil, errors.New("the browser server returned no image")
+}
+
+// Text returns the rendered text of the elements matching a CSS selector,
+// or of the whole page if selector is empty.
+func (s *Session) Text(ctx context.Context, selector string) (string, error) {
+	if selector == "" {
+		selector = "body"
+	}
+	script := fmt.Sprintf(`() => Array.from(document.querySelectorAll(%q), e => e.innerText).join("\n")`, selector)
+	result, err := s.call(ctx, "browser_evaluate", map[string]any{"function": script})
+	if err != nil {
+		return "", err
+	}
+	return text(result), nil
+}
+
+// Close stops the browser server, if it was started.
+func (s *Session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client == nil {
+		return nil
+	}
+	err := s.client.Close()
+	s.client = nil
+	return err
+}
+
+func (s *Session) call(ctx context.Context, tool string, args map[string]any) (*mcp.CallToolResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client == nil {
+		client, err := mcp.Connect(ctx, serverName, s.server)
+		if err != nil {
+			return nil, fmt.Errorf("could not start the browser server: %w", err)
+		}
+		s.client = client
+		shutdown.Register("stop the browser", func(context.Context) error { return s.Close() })
+	}
+	result, err := s.client.CallTool(ctx, tool, args)
+	if err != nil {
+		return nil, err
+	}
+	if result.IsError {
+		return nil, fmt.Errorf("%s failed: %s", tool, text(result))
+	}
+	return result, nil
+}
+
+// text joins the text content of a result.
+func text(result *mcp.CallToolResult) string {
+	var parts []string
+	for _, c := range result.Content {
+		if c.Type == "text" {
+			parts = append(parts, c.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,76 @@
+package browser
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/mcp"
+)
+
+// fakeServer answers like a Playwright MCP server over streamable HTTP,
+// recording the tool calls it receives.
+func fakeServer(t *testing.T, calls *[]string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params struct {
+				Name      string         `json:"name"`
+				Arguments map[string]any `json:"arguments"`
+			} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ID == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		var result any
+		switch req.Method {
+		case "initialize":
+			result = map[string]any{"protocolVersion": "2025-06-18", "serverInfo": map[string]string{"name": "playwright"}}
+		case "tools/call":
+			*calls = append(*calls, req.Params.Name)
+			switch req.Params.Name {
+			case "browser_navigate":
+				result = mcp.CallToolResult{Content: []mcp.Content{{Type: "text", Text: "Page Title: " + req.Params.Arguments["url"].(string)}}}
+			case "browser_take_screenshot":
+				result = mcp.CallToolResult{Content: []mcp.Content{{Type: "image", MimeType: "image/png", Data: base64.StdEncoding.EncodeToString([]byte("png"))}}}
+			case "browser_evaluate":
+				result = mcp.CallToolResult{Content: []mcp.Content{{Type: "text", Text: req.Params.Arguments["function"].(string)}}}
+			default:
+				result = mcp.CallToolResult{IsError: true, Content: []mcp.Content{{Type: "text", Text: "unknown tool"}}}
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSession(t *testing.T) {
+	var calls []string
+	srv := fakeServer(t, &calls)
+	s := New(&config.BrowserSettings{Server: &config.MCPServer{HTTPURL: srv.URL}})
+	defer s.Close()
+	ctx := context.Background()
+
+	if page, err := s.Navigate(ctx, "http://localhost:3000"); err != nil || page != "Page Title: http://localhost:3000" {
+		t.Errorf("Navigate = %q, %v", page, err)
+	}
+	if shot, err := s.Screenshot(ctx); err != nil || string(shot.Data) != "png" || shot.MimeType != "image/png" {
+		t.Errorf("Screenshot = %+v, %v", shot, err)
+	}
+	if text, err := s.Text(ctx, "#app"); err != nil || !strings.Contains(text, `querySelectorAll("#app")`) {
+		t.Errorf("Text = %q, %v", text, err)
+	}
+	if strings.Join(calls, ",") != "browser_navigate,browser_take_screenshot,browser_evaluate" {
+		t.Errorf("calls = %q", calls)
+	}
+}
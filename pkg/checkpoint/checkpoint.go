@@ -0,0 +1,134 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+const checkpointsDirName = "checkpoints"
+
+// File is the state of a file before a change.
+type File struct {
+	Path    string `json:"path"`
+	Existed bool   `json:"existed"`
+	Content string `json:"content,omitempty"`
+	Mode    uint32 `json:"mode,omitempty"`
+}
+
+// Checkpoint records the files a set of changes touched, as they were before,
+// so that /restore can undo the changes.
+type Checkpoint struct {
+	ID          string    `json:"id"`
+	Time        time.Time `json:"time"`
+	Description string    `json:"description"`
+	Files       []File    `json:"files"`
+}
+
+// New returns a checkpoint with an ID derived from the current time.
+func New(description string, files []File) *Checkpoint {
+	now := time.Now()
+	return &Checkpoint{
+		ID:          now.Format("20060102-150405.000"),
+		Time:        now,
+		Description: description,
+		Files:       files,
+	}
+}
+
+func dir(projectRoot string) (string, error) {
+	tmp, err := config.ProjectTempDir(projectRoot)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(tmp, checkpointsDirName), nil
+}
+
+// Save writes c to ~/.gemini/tmp/<project-hash>/checkpoints.
+func Save(projectRoot string, c *Checkpoint) error {
+	d, err := dir(projectRoot)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d, 0755); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(d, c.ID+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Delete removes a checkpoint.
+func Delete(projectRoot, id string) error {
+	d, err := dir(projectRoot)
+	if err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(d, id+".json"))
+}
+
+// List returns the project's checkpoints, newest first.
+func List(projectRoot string) ([]*Checkpoint, error) {
+	d, err := dir(projectRoot)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(d)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []*Checkpoint
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok {
+			continue
+		}
+		c, err := Load(projectRoot, id)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Time.After(list[j].Time) })
+	return list, nil
+}
+
+// Load reads the checkpoint with the given ID.
+func Load(projectRoot, id string) (*Checkpoint, error) {
+	d, err := dir(projectRoot)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return nil, fmt.Errorf("invalid checkpoint ID %q", id)
+	}
+	data, err := os.ReadFile(filepath.Join(d, id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no checkpoint %q", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var c Checkpoint
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("checkpoint %s: %w", id, err)
+	}
+	return &c, nil
+}
@@ -0,0 +1,104 @@
+// Package commands loads custom slash commands defined in TOML files, as in
+// the Node CLI: ~/.gemini/commands/git/commit.toml defines /git:commit, and
+// commands in <project>/.gemini/commands override user commands of the same
+// name.
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+// Command is a custom slash command.
+type Command struct {
+	// Name is the command name without the leading slash, e.g. "git:commit".
+	Name        string
+	Description string
+	Prompt      string
+	// Path is the file the command was loaded from.
+	Path string
+}
+
+// file is the format of a command file.
+type file struct {
+	Prompt      string `toml:"prompt"`
+	Description string `toml:"description"`
+}
+
+// Load returns the user and project commands, sorted by name. Files that
+// cannot be parsed are skipped and reported in the returned error, so that
+// one bad file does not disable every command.
+func Load(projectRoot string) ([]*Command, error) {
+	var dirs []string
+	if userDir, err := config.UserDir(); err == nil {
+		dirs = append(dirs, filepath.Join(userDir, "commands"))
+	}
+	dirs = append(dirs, filepath.Join(projectRoot, ".gemini", "commands"))
+
+	byName := map[string]*Command{}
+	var errs []error
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() || filepath.Ext(path) != ".toml" {
+				return nil
+			}
+			c, err := loadFile(dir, path)
+			if err != nil {
+				errs = append(errs, err)
+				return nil
+			}
+			byName[c.Name] = c
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	cmds := make([]*Command, 0, len(byName))
+	for _, c := range byName {
+		cmds = append(cmds, c)
+	}
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].Name < cmds[j].Name })
+	return cmds, errors.Join(errs...)
+}
+
+func loadFile(dir, path string) (*Command, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f file
+	if err := toml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if strings.TrimSpace(f.Prompt) == "" {
+		return nil, fmt.Errorf("%s: missing prompt", path)
+	}
+	if _, err := parse(f.Prompt); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	rel, _ := filepath.Rel(dir, strings.TrimSuffix(path, ".toml"))
+	segments := strings.Split(filepath.ToSlash(rel), "/")
+	for i, s := range segments {
+		// Colons separate namespaces, so they cannot appear in a segment.
+		segments[i] = strings.ReplaceAll(s, ":", "_")
+	}
+	name := strings.Join(segments, ":")
+	return &Command{Name: name, Description: f.Description, Prompt: f.Prompt, Path: path}, nil
+}
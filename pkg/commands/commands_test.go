@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+func writeCommand(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	home, project := t.TempDir(), t.TempDir()
+	restore := config.SetUserHomeDirForTesting(home, nil)
+	defer restore()
+
+	writeCommand(t, filepath.Join(home, ".gemini", "commands", "review.toml"),
+		"description = \"User review\"\nprompt = \"Review {{args}}\"")
+	writeCommand(t, filepath.Join(home, ".gemini", "commands", "git", "commit.toml"),
+		"prompt = \"Write a commit message for !{git diff --staged}\"")
+	writeCommand(t, filepath.Join(project, ".gemini", "commands", "review.toml"),
+		"description = \"Project review\"\nprompt = \"Review carefully\"")
+	writeCommand(t, filepath.Join(project, ".gemini", "commands", "broken.toml"),
+		"prompt = \"!{echo {unbalanced}\"")
+
+	cmds, err := Load(project)
+	if err == nil || !strings.Contains(err.Error(), "broken.toml") {
+		t.Errorf("Load() error = %v, want an error naming broken.toml", err)
+	}
+	var names []string
+	for _, c := range cmds {
+		names = append(names, c.Name)
+	}
+	if got := strings.Join(names, ","); got != "git:commit,review" {
+		t.Fatalf("loaded %s, want git:commit,review", got)
+	}
+	if cmds[1].Description != "Project review" {
+		t.Errorf("project command should override the user command, got %q", cmds[1].Description)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		prompt string
+		want   string
+	}{
+		{"a\n!{echo {x}", "unclosed shell block at line 2, column 1"},
+		{"!{echo !{date}}", "nested shell block at line 1, column 8"},
+		{"x !{  }", "empty shell block"},
+	}
+	for _, tt := range tests {
+		if _, err := parse(tt.prompt); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parse(%q) error = %v, want %q", tt.prompt, err, tt.want)
+		}
+	}
+	if segs, err := parse("files: !{ls {a,b}.go} done"); err != nil || len(segs) != 3 || segs[1].text != "ls {a,b}.go" {
+		t.Errorf("balanced braces should be allowed, got %+v, %v", segs, err)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	c := &Command{Name: "grep", Prompt: "Explain matches of {{args}}:\n!{grep -r {{args}} .}\n!{false}"}
+	inv, err := c.Prepare("it's")
+	if err != nil {
+		t.Fatalf("Prepare() failed: %v", err)
+	}
+	if len(inv.Shell) != 2 || inv.Shell[0] != `grep -r 'it'\''s' .` {
+		t.Fatalf("Shell = %q, want the arguments quoted", inv.Shell)
+	}
+
+	prompt, err := inv.Expand(context.Background(), func(ctx context.Context, command string) (string, int, error) {
+		if command == "false" {
+			return "", 1, nil
+		}
+		return strings.Repeat("x", maxShellOutput+10) + "\n", 0, nil
+	})
+	if err != nil {
+		t.Fatalf("Expand() failed: %v", err)
+	}
+	for _, want := range []string{"Explain matches of it's:\n", "[output truncated: 10 more bytes]", "[Shell command exited with code 1]"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("expanded prompt does not contain %q", want)
+		}
+	}
+
+	// Without {{args}}, the invocation is appended.
+	inv, _ = (&Command{Name: "plan", Prompt: "Make a plan."}).Prepare("for the refactor")
+	prompt, _ = inv.Expand(context.Background(), nil)
+	if prompt != "Make a plan.\n\n/plan for the refactor" {
+		t.Errorf("expanded prompt = %q", prompt)
+	}
+}
@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+const (
+	// argsPlaceholder is replaced by the arguments of the command.
+	argsPlaceholder = "{{args}}"
+	// maxShellOutput caps the output of each !{...} block interpolated into
+	// the prompt, so a noisy command cannot flood the context.
+	maxShellOutput = 32 * 1024
+)
+
+// segment is literal prompt text or, if shell is set, a !{...} block.
+type segment struct {
+	text  string
+	shell bool
+}
+
+// parse splits a prompt into literal text and !{...} shell blocks. Braces
+// inside a block must be balanced, as in !{echo {a,b}}; blocks cannot be
+// nested.
+func parse(prompt string) ([]segment, error) {
+	var segs []segment
+	rest := prompt
+	for {
+		start := strings.Index(rest, "!{")
+		if start < 0 {
+			segs = append(segs, segment{text: rest})
+			return segs, nil
+		}
+		segs = append(segs, segment{text: rest[:start]})
+
+		depth, end := 1, -1
+		for i := start + 2; i < len(rest) && end < 0; i++ {
+			switch {
+			case strings.HasPrefix(rest[i:], "!{"):
+				return nil, fmt.Errorf("nested shell block at %s: !{...} cannot contain another !{...}",
+					position(prompt, len(prompt)-len(rest)+i))
+			case rest[i] == '{':
+				depth++
+			case rest[i] == '}':
+				depth--
+				if depth == 0 {
+					end = i
+				}
+			}
+		}
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed shell block at %s: missing } (braces inside !{...} must be balanced)",
+				position(prompt, len(prompt)-len(rest)+start))
+		}
+		cmd := strings.TrimSpace(rest[start+2 : end])
+		if cmd == "" {
+			return nil, fmt.Errorf("empty shell block at %s", position(prompt, len(prompt)-len(rest)+start))
+		}
+		segs = append(segs, segment{text: cmd, shell: true})
+		rest = rest[end+1:]
+	}
+}
+
+// position describes a byte offset in the prompt for error messages.
+func position(prompt string, offset int) string {
+	before := prompt[:offset]
+	line := strings.Count(before, "\n") + 1
+	col := offset - strings.LastIndex(before, "\n")
+	return fmt.Sprintf("line %d, column %d", line, col)
+}
+
+// Invocation is a command called with arguments, ready to be expanded into
+// the prompt sent to the model.
+type Invocation struct {
+	// Shell holds the commands of the !{...} blocks, with the arguments
+	// substituted, in the order they run.
+	Shell []string
+
+	segs []segment
+}
+
+// Prepare substitutes args into the command's prompt. Inside !{...} blocks
+// the arguments are shell-quoted. If the prompt does not use {{args}}, the
+// invocation is appended to it instead, as in the Node CLI.
+func (c *Command) Prepare(args string) (*Invocation, error) {
+	segs, err := parse(c.Prompt)
+	if err != nil {
+		return nil, err
+	}
+	args = strings.TrimSpace(args)
+
+	uses := strings.Contains(c.Prompt, argsPlaceholder)
+	inv := &Invocation{}
+	for _, s := range segs {
+		if s.shell {
+			s.text = strings.ReplaceAll(s.text, argsPlaceholder, shellQuote(args))
+			inv.Shell = append(inv.Shell, s.text)
+		} else {
+			s.text = strings.ReplaceAll(s.text, argsPlaceholder, args)
+		}
+		inv.segs = append(inv.segs, s)
+	}
+	if !uses && args != "" {
+		inv.segs = append(inv.segs, segment{text: "\n\n/" + c.Name + " " + args})
+	}
+	return inv, nil
+}
+
+// RunFunc runs a shell command, returning its output and exit code.
+type RunFunc func(ctx context.Context, command string) (output string, exitCode int, err error)
+
+// Expand runs the shell blocks with run and returns the prompt with their
+// output interpolated.
+func (inv *Invocation) Expand(ctx context.Context, run RunFunc) (string, error) {
+	var b strings.Builder
+	for _, s := range inv.segs {
+		if !s.shell {
+			b.WriteString(s.text)
+			continue
+		}
+		out, code, err := run(ctx, s.text)
+		if err != nil {
+			return "", fmt.Errorf("!{%s}: %w", s.text, err)
+		}
+		out = strings.TrimRight(out, "\n")
+		if len(out) > maxShellOutput {
+			out = fmt.Sprintf("%s\n[output truncated: %d more bytes]",
+				strings.ToValidUTF8(out[:maxShellOutput], ""), len(out)-maxShellOutput)
+		}
+		b.WriteString(out)
+		if code != 0 {
+			fmt.Fprintf(&b, "\n[Shell command exited with code %d]", code)
+		}
+	}
+	return b.String(), nil
+}
+
+// shellQuote quotes s as a single shell word.
+func shellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
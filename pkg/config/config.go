@@ -48,6 +48,10 @@ type UISettings struct {
 	ShowLineNumbers    bool                  `json:"showLineNumbers,omitempty"`
 	ShowCitations      bool                  `json:"showCitations,omitempty"`
 	CustomWittyPhrases []string              `json:"customWittyPhrases,omitempty"`
+	InlineImages       bool                  `json:"inlineImages,omitempty"`
+	// Locale selects the language of the UI, e.g. "de". It defaults to
+	// LC_ALL, LC_MESSAGES or LANG.
+	Locale string `json:"locale,omitempty"`
 	Accessibility      *AccessibilitySettings `json:"accessibility,omitempty"`
 }
 
@@ -87,6 +91,40 @@ type ModelSettings struct {
 	SummarizeToolOutput  map[string]any `json:"summarizeToolOutput,omitempty"` // Using 'any' for now.
 	ChatCompression      any            `json:"chatCompression,omitempty"`      // Using 'any' for now.
 	SkipNextSpeakerCheck bool           `json:"skipNextSpeakerCheck,omitempty"`
+	// ResponseCache reuses the responses of identical non-interactive
+	// requests, as --cache does.
+	ResponseCache *ResponseCacheSettings `json:"responseCache,omitempty"`
+	// Seed makes sampling repeatable as far as the API allows, as --seed
+	// does.
+	Seed *int32 `json:"seed,omitempty"`
+	// ThinkingBudget is how many tokens the 2.5 series models may spend
+	// thinking, as --thinking-budget sets: 0 turns thinking off and -1
+	// lets the model decide.
+	ThinkingBudget *int32 `json:"thinkingBudget,omitempty"`
+	// ReasoningEffort sets the thinking budget by name instead: none,
+	// low, medium, high or dynamic.
+	ReasoningEffort string `json:"reasoningEffort,omitempty"`
+	// Generation are parameters of every generation request.
+	Generation *GenerationSettings `json:"generation,omitempty"`
+}
+
+// GenerationSettings are parameters of the generation requests the client
+// library does not set from elsewhere.
+type GenerationSettings struct {
+	// StopSequences end a response where the model outputs one of them, as
+	// --stop sets. The API accepts up to 5.
+	StopSequences []string `json:"stopSequences,omitempty"`
+	// ResponsePrefix is text the responses to the user's prompts start
+	// with, which the model continues, as --response-prefix sets.
+	ResponsePrefix string `json:"responsePrefix,omitempty"`
+}
+
+// ResponseCacheSettings configures the local cache of model responses.
+type ResponseCacheSettings struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// TTL is how long a response is reused, as a duration such as "12h".
+	// It defaults to 24h.
+	TTL string `json:"ttl,omitempty"`
 }
 
 // ContextSettings represents the settings for managing context provided to the model.
@@ -109,20 +147,93 @@ type FileFilteringSettings struct {
 
 // ToolsSettings represents the settings for built-in and custom tools.
 type ToolsSettings struct {
-	Sandbox                     any            `json:"sandbox,omitempty"` // bool or string
-	SandboxImage                string         `json:"sandboxImage,omitempty"`
-	Shell                       *ShellSettings `json:"shell,omitempty"`
-	AutoAccept                  bool           `json:"autoAccept,omitempty"`
-	Core                        []string       `json:"core,omitempty"`
-	Allowed                     []string       `json:"allowed,omitempty"`
-	Exclude                     []string       `json:"exclude,omitempty"`
-	DiscoveryCommand            string         `json:"discoveryCommand,omitempty"`
-	CallCommand                 string         `json:"callCommand,omitempty"`
-	UseRipgrep                  bool           `json:"useRipgrep,omitempty"`
-	EnableToolOutputTruncation  bool           `json:"enableToolOutputTruncation,omitempty"`
-	TruncateToolOutputThreshold int            `json:"truncateToolOutputThreshold,omitempty"`
-	TruncateToolOutputLines     int            `json:"truncateToolOutputLines,omitempty"`
-	EnableMessageBusIntegration bool           `json:"enableMessageBusIntegration,omitempty"`
+	Sandbox                     any              `json:"sandbox,omitempty"` // bool or string
+	SandboxImage                string           `json:"sandboxImage,omitempty"`
+	Shell                       *ShellSettings   `json:"shell,omitempty"`
+	HTTP                        *HTTPSettings    `json:"http,omitempty"`
+	Browser                     *BrowserSettings `json:"browser,omitempty"`
+	LSP                         *LSPSettings     `json:"lsp,omitempty"`
+	Index                       *IndexSettings   `json:"index,omitempty"`
+	AutoAccept                  bool             `json:"autoAccept,omitempty"`
+	// Core, if not empty, are the only tools enabled; "run_shell_command(git)"
+	// enables the shell for the commands starting with git only.
+	Core                        []string         `json:"core,omitempty"`
+	// Allowed are tools that run without asking, the shell commands
+	// starting with a prefix such as "run_shell_command(go test)", or a
+	// single command quoted, as in `run_shell_command("go test ./...")`,
+	// which answering "always allow" to a confirmation adds.
+	Allowed                     []string         `json:"allowed,omitempty"`
+	Exclude                     []string         `json:"exclude,omitempty"`
+	DiscoveryCommand            string           `json:"discoveryCommand,omitempty"`
+	CallCommand                 string           `json:"callCommand,omitempty"`
+	UseRipgrep                  bool             `json:"useRipgrep,omitempty"`
+	EnableToolOutputTruncation  bool             `json:"enableToolOutputTruncation,omitempty"`
+	TruncateToolOutputThreshold int              `json:"truncateToolOutputThreshold,omitempty"`
+	TruncateToolOutputLines     int              `json:"truncateToolOutputLines,omitempty"`
+	EnableMessageBusIntegration bool             `json:"enableMessageBusIntegration,omitempty"`
+	// MaxFileSize is the size in bytes of the largest file read_file reads.
+	MaxFileSize int64 `json:"maxFileSize,omitempty"`
+	// DeclarationBudget is the estimated number of tokens the tool
+	// declarations may take in each request. Over it, they are pruned:
+	// descriptions are shortened, and the largest tools are declared without
+	// parameters until the model asks for them. 0 means no limit.
+	DeclarationBudget int `json:"declarationBudget,omitempty"`
+	// SandboxScope is "tools" to run only shell commands and file changes in
+	// the sandbox, keeping the CLI on the host, rather than the whole CLI.
+	SandboxScope string `json:"sandboxScope,omitempty"`
+	// SandboxReadOnly mounts the workspace read-only in the sandbox
+	// container. Its changes go to an overlay directory instead and are
+	// offered as a patch when the sandbox exits. It needs docker or podman
+	// and the default sandbox scope.
+	SandboxReadOnly bool `json:"sandboxReadOnly,omitempty"`
+	// SandboxRuntime is the OCI runtime of the sandbox container, such as
+	// runsc for gVisor.
+	SandboxRuntime string `json:"sandboxRuntime,omitempty"`
+	// SandboxRunArgs are extra flags for running the sandbox container, as
+	// --flag=value.
+	SandboxRunArgs []string `json:"sandboxRunArgs,omitempty"`
+	// SandboxNetwork is the network of the sandbox: "open" (the default),
+	// "none", or "restricted" to the hosts in SandboxAllowedHosts through a
+	// proxy. Restricted needs docker or podman.
+	SandboxNetwork string `json:"sandboxNetwork,omitempty"`
+	// SandboxAllowedHosts are the hosts a restricted sandbox may reach, such
+	// as "proxy.golang.org" or "*.npmjs.org". A host without a port matches
+	// any port.
+	SandboxAllowedHosts []string `json:"sandboxAllowedHosts,omitempty"`
+	// WebFetch configures the web_fetch tool.
+	WebFetch *WebFetchSettings `json:"webFetch,omitempty"`
+	// WebSearch configures the google_web_search tool.
+	WebSearch *WebSearchSettings `json:"webSearch,omitempty"`
+	// Limits bound the resources of tool calls, by tool name. The limits
+	// under "*" apply to every tool without a limit of its own.
+	Limits map[string]LimitSettings `json:"limits,omitempty"`
+	// Plugins configures the tool plugins in ~/.gemini/tools.
+	Plugins *PluginSettings `json:"plugins,omitempty"`
+}
+
+// PluginSettings configures the tool plugins in ~/.gemini/tools.
+type PluginSettings struct {
+	// WASMOnly loads only the plugins compiled to WebAssembly, which run
+	// sandboxed, and none of the executables, which run as the user.
+	WASMOnly bool `json:"wasmOnly,omitempty"`
+}
+
+// LimitSettings bound the resources a tool call may use. Zero fields are
+// unlimited.
+type LimitSettings struct {
+	// Timeout is the time in milliseconds a call may take.
+	Timeout int `json:"timeout,omitempty"`
+	// MaxOutputBytes is the size of the largest response, as JSON.
+	MaxOutputBytes int `json:"maxOutputBytes,omitempty"`
+	// OutputBytes and OutputTokens are the budget of a response, as JSON.
+	// Over it, the longest texts in the response, such as file contents or
+	// command output, lose their middle, keeping their head and tail.
+	OutputBytes  int `json:"outputBytes,omitempty"`
+	OutputTokens int `json:"outputTokens,omitempty"`
+	// CPUSeconds and MemoryMB limit the CPU time and address space of shell
+	// commands, on Linux. MemoryMB also limits the memory of WASM plugins.
+	CPUSeconds int `json:"cpuSeconds,omitempty"`
+	MemoryMB   int `json:"memoryMB,omitempty"`
 }
 
 // ShellSettings represents the settings for shell execution.
@@ -130,6 +241,73 @@ type ShellSettings struct {
 	EnableInteractiveShell bool   `json:"enableInteractiveShell,omitempty"`
 	Pager                  string `json:"pager,omitempty"`
 	ShowColor              bool   `json:"showColor,omitempty"`
+	// EnvAllowlist adds host environment variables passed to shell commands.
+	EnvAllowlist []string `json:"envAllowlist,omitempty"`
+	// OutputEncoding is the encoding of command output that is not UTF-8,
+	// such as "windows-1252". It is detected when unset.
+	OutputEncoding string `json:"outputEncoding,omitempty"`
+}
+
+// HTTPSettings represents the settings for the http_request tool.
+type HTTPSettings struct {
+	// AllowedHosts are hosts requests may be sent to without asking, such as
+	// "localhost:8080" or "*.corp.example". A host without a port matches
+	// any port.
+	AllowedHosts []string `json:"allowedHosts,omitempty"`
+	// MaxResponseBytes caps the response body returned to the model.
+	MaxResponseBytes int `json:"maxResponseBytes,omitempty"`
+}
+
+// WebFetchSettings represents the settings for the web_fetch tool.
+type WebFetchSettings struct {
+	// MaxLength caps the text of a page returned to the model, in bytes.
+	MaxLength int `json:"maxLength,omitempty"`
+	// Proxy is the URL of the proxy pages are fetched through. Without it,
+	// HTTPS_PROXY, HTTP_PROXY and NO_PROXY apply.
+	Proxy string `json:"proxy,omitempty"`
+}
+
+// WebSearchSettings represents the settings for the google_web_search
+// tool.
+type WebSearchSettings struct {
+	// Model is the Gemini model answering searches, by default
+	// gemini-2.5-flash.
+	Model string `json:"model,omitempty"`
+}
+
+// BrowserSettings represents the settings for the browser tools.
+type BrowserSettings struct {
+	// Enabled offers the browser tools, as --enable-browser-tools does.
+	Enabled bool `json:"enabled,omitempty"`
+	// Server is the Playwright-compatible MCP server driving the browser.
+	// It defaults to npx @playwright/mcp in headless mode.
+	Server *MCPServer `json:"server,omitempty"`
+}
+
+// IndexSettings configures the semantic index built by `gemini index build`.
+type IndexSettings struct {
+	// EmbeddingModel is the Gemini embedding model, by default
+	// text-embedding-004.
+	EmbeddingModel string `json:"embeddingModel,omitempty"`
+	// DisableAutoUpdate stops interactive sessions from keeping an existing
+	// index up to date in the background.
+	DisableAutoUpdate bool `json:"disableAutoUpdate,omitempty"`
+}
+
+// LSPSettings represents the settings for the code intelligence tools.
+type LSPSettings struct {
+	// Servers are the language servers by name. They replace the built-in
+	// servers of the same name (gopls, typescript and pyright).
+	Servers map[string]LSPServer `json:"servers,omitempty"`
+}
+
+// LSPServer represents a language server run over stdio.
+type LSPServer struct {
+	Command string   `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+	// Extensions are the file extensions the server handles, such as ".go".
+	Extensions            []string       `json:"extensions,omitempty"`
+	InitializationOptions map[string]any `json:"initializationOptions,omitempty"`
 }
 
 // MCPSettings represents the settings for Model Context Protocol (MCP) servers.
@@ -139,9 +317,48 @@ type MCPSettings struct {
 	Excluded      []string `json:"excluded,omitempty"`
 }
 
-// MCPServer represents the configuration for an MCP server.
+// MCPServer represents the configuration for an MCP server. Exactly one of
+// Command (stdio), URL (SSE) or HTTPURL (streamable HTTP) selects the
+// transport. Args, Env, Cwd and Headers may reference environment variables
+// as $VAR or ${VAR}; see Expand.
 type MCPServer struct {
-	// This will be defined based on gemini-cli-core
+	Command string            `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	Cwd     string            `json:"cwd,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	HTTPURL string            `json:"httpUrl,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Timeout bounds each request, in milliseconds.
+	Timeout int `json:"timeout,omitempty"`
+	// StartupTimeout bounds starting the server and the initialization
+	// handshake, in milliseconds.
+	StartupTimeout int      `json:"startupTimeout,omitempty"`
+	Trust          bool     `json:"trust,omitempty"`
+	Description    string   `json:"description,omitempty"`
+	IncludeTools   []string `json:"includeTools,omitempty"`
+	ExcludeTools   []string `json:"excludeTools,omitempty"`
+	// OAuth configures authorizing with a remote server that requires it.
+	// Without it, what is needed is discovered from the server.
+	OAuth *MCPOAuth `json:"oauth,omitempty"`
+}
+
+// MCPOAuth are the OAuth 2.0 settings of a remote MCP server. Those left
+// unset are discovered from the metadata of the server and of its
+// authorization server, and a client is registered if no ClientID is set.
+type MCPOAuth struct {
+	// Enabled authorizes before connecting when there is no token, rather
+	// than once the server refuses the connection.
+	Enabled          bool     `json:"enabled,omitempty"`
+	ClientID         string   `json:"clientId,omitempty"`
+	ClientSecret     string   `json:"clientSecret,omitempty"`
+	AuthorizationURL string   `json:"authorizationUrl,omitempty"`
+	TokenURL         string   `json:"tokenUrl,omitempty"`
+	Scopes           []string `json:"scopes,omitempty"`
+	// RedirectURI is where the browser returns to after authorizing,
+	// http://localhost:7777/oauth/callback by default. The CLI listens on
+	// its host and port.
+	RedirectURI string `json:"redirectUri,omitempty"`
 }
 
 // SecuritySettings represents the security-related settings.
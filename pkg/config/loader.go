@@ -1,8 +1,11 @@
 package config
 
 import (
+	"crypto/sha256"
 	"dario.cat/mergo"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"golang.org/x/oauth2"
 	"os"
 	"path/filepath"
@@ -16,6 +19,8 @@ const (
 	settingsFileName           = "settings.toml"
 	deprecatedSettingsDir      = ".config/gemini"
 	deprecatedSettingsFileName = "settings.json"
+	tmpDirName                 = "tmp"
+	logsDirName                = "logs"
 )
 
 var userHomeDir = os.UserHomeDir
@@ -113,6 +118,9 @@ func readSettingsFile(path string) (*Settings, error) {
 			return nil, err
 		}
 	}
+	if err := validateMCPServers(settings.MCPServers); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
 
 	return &settings, nil
 }
@@ -157,6 +165,93 @@ func SaveUserSettings(settings *Settings) error {
 	return encoder.Encode(settings)
 }
 
+// Scope selects one of the settings files that Load merges.
+type Scope int
+
+const (
+	UserScope Scope = iota
+	WorkspaceScope
+)
+
+func (s Scope) String() string {
+	if s == WorkspaceScope {
+		return "workspace"
+	}
+	return "user"
+}
+
+// LoadScope reads the settings of a single scope, without merging.
+func LoadScope(scope Scope) (*Settings, error) {
+	if scope == UserScope {
+		return loadUserSettings()
+	}
+	return loadWorkspaceSettings()
+}
+
+// SaveScope writes the settings of a single scope. Workspace settings go to
+// the nearest .gemini directory, created in the working directory if there
+// is none.
+func SaveScope(scope Scope, settings *Settings) error {
+	if scope == UserScope {
+		return SaveUserSettings(settings)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	configDir, found := findUpDir(wd, settingsDirName)
+	if !found {
+		configDir = filepath.Join(wd, settingsDirName)
+		if err := os.MkdirAll(configDir, 0755); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.Create(filepath.Join(configDir, settingsFileName))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return toml.NewEncoder(file).Encode(settings)
+}
+
+// ProjectHash identifies a project by the SHA-256 of its root directory,
+// matching the Node CLI's layout of ~/.gemini/tmp.
+func ProjectHash(projectRoot string) string {
+	sum := sha256.Sum256([]byte(projectRoot))
+	return hex.EncodeToString(sum[:])
+}
+
+// ProjectTempDir returns the directory under ~/.gemini/tmp holding data for
+// the given project, such as recorded sessions.
+func ProjectTempDir(projectRoot string) (string, error) {
+	homeDir, err := userHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, settingsDirName, tmpDirName, ProjectHash(projectRoot)), nil
+}
+
+// UserDir returns the user's ~/.gemini directory.
+func UserDir() (string, error) {
+	homeDir, err := userHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, settingsDirName), nil
+}
+
+// LogDir returns the directory under ~/.gemini holding log files, such as
+// the stderr output of MCP servers.
+func LogDir() (string, error) {
+	homeDir, err := userHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, settingsDirName, logsDirName), nil
+}
+
 const oauthCredsFileName = "oauth_creds.json"
 
 // LoadToken loads the OAuth2 token from the dedicated credentials file.
@@ -0,0 +1,242 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	envNamePattern    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	headerNamePattern = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+)
+
+// validateMCPServers checks the mcpServers settings, naming each invalid
+// field.
+func validateMCPServers(servers map[string]MCPServer) error {
+	names := make([]string, 0, len(servers))
+	for name := range servers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var errs []error
+	for _, name := range names {
+		errs = append(errs, servers[name].Validate(name))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks the configuration of the named server. Every problem is
+// reported, each prefixed with the path of its field, such as
+// mcpServers.github.headers.
+func (s MCPServer) Validate(name string) error {
+	var errs []error
+	fail := func(field, format string, args ...any) {
+		path := "mcpServers." + name
+		if field != "" {
+			path += "." + field
+		}
+		errs = append(errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+	}
+
+	var transports []string
+	for _, t := range []struct{ field, value string }{{"command", s.Command}, {"url", s.URL}, {"httpUrl", s.HTTPURL}} {
+		if t.value != "" {
+			transports = append(transports, t.field)
+		}
+	}
+	switch len(transports) {
+	case 0:
+		fail("", "set command for a stdio server, or url or httpUrl for a remote one")
+	case 1:
+	default:
+		fail("", "%s are exclusive, set only one", strings.Join(transports, " and "))
+	}
+
+	for _, u := range []struct{ field, value string }{{"url", s.URL}, {"httpUrl", s.HTTPURL}} {
+		if u.value == "" {
+			continue
+		}
+		parsed, err := url.Parse(u.value)
+		switch {
+		case err != nil:
+			fail(u.field, "invalid URL: %v", err)
+		case parsed.Scheme != "http" && parsed.Scheme != "https":
+			fail(u.field, "%q must be an http or https URL", u.value)
+		case parsed.Host == "":
+			fail(u.field, "%q has no host", u.value)
+		}
+	}
+
+	if s.Command == "" {
+		if len(s.Args) > 0 {
+			fail("args", "only applies to stdio servers, which set command")
+		}
+		if len(s.Env) > 0 {
+			fail("env", "only applies to stdio servers, which set command")
+		}
+		if s.Cwd != "" {
+			fail("cwd", "only applies to stdio servers, which set command")
+		}
+	} else if len(s.Headers) > 0 {
+		fail("headers", "only apply to remote servers, which set url or httpUrl")
+	}
+
+	for k, v := range s.Env {
+		if !envNamePattern.MatchString(k) {
+			fail("env", "%q is not a valid variable name", k)
+		}
+		if _, err := checkVars(v); err != nil {
+			fail("env."+k, "%v", err)
+		}
+	}
+	for k, v := range s.Headers {
+		if !headerNamePattern.MatchString(k) {
+			fail("headers", "%q is not a valid header name", k)
+		}
+		if _, err := checkVars(v); err != nil {
+			fail("headers."+k, "%v", err)
+		}
+	}
+	for i, arg := range s.Args {
+		if _, err := checkVars(arg); err != nil {
+			fail(fmt.Sprintf("args[%d]", i), "%v", err)
+		}
+	}
+	if _, err := checkVars(s.Cwd); err != nil {
+		fail("cwd", "%v", err)
+	}
+
+	if s.OAuth != nil {
+		if s.Command != "" {
+			fail("oauth", "only applies to remote servers, which set url or httpUrl")
+		}
+		for _, u := range []struct{ field, value string }{{"authorizationUrl", s.OAuth.AuthorizationURL}, {"tokenUrl", s.OAuth.TokenURL}, {"redirectUri", s.OAuth.RedirectURI}} {
+			if u.value == "" {
+				continue
+			}
+			if parsed, err := url.Parse(u.value); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				fail("oauth."+u.field, "%q must be an http or https URL", u.value)
+			}
+		}
+	}
+
+	if s.Timeout < 0 {
+		fail("timeout", "must not be negative, got %d", s.Timeout)
+	}
+	if s.StartupTimeout < 0 {
+		fail("startupTimeout", "must not be negative, got %d", s.StartupTimeout)
+	}
+	return errors.Join(errs...)
+}
+
+// Expand returns the configuration of the named server with the
+// environment variables referenced in its args, env, cwd and headers, as
+// $VAR or ${VAR}, replaced by their values. A reference to an unset
+// variable is an error, rather than silently sending an empty credential.
+func (s MCPServer) Expand(name string) (MCPServer, error) {
+	var errs []error
+	expand := func(field, value string) string {
+		out, err := expandVars(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("mcpServers.%s.%s: %w", name, field, err))
+		}
+		return out
+	}
+
+	out := s
+	out.Args = nil
+	for i, arg := range s.Args {
+		out.Args = append(out.Args, expand(fmt.Sprintf("args[%d]", i), arg))
+	}
+	out.Cwd = expand("cwd", s.Cwd)
+	if s.Env != nil {
+		out.Env = map[string]string{}
+		for k, v := range s.Env {
+			out.Env[k] = expand("env."+k, v)
+		}
+	}
+	if s.Headers != nil {
+		out.Headers = map[string]string{}
+		for k, v := range s.Headers {
+			out.Headers[k] = expand("headers."+k, v)
+		}
+	}
+	return out, errors.Join(errs...)
+}
+
+// expandVars replaces $VAR and ${VAR} in s with the values of the
+// environment variables. $$ stands for a literal $, as does a $ that does
+// not start a reference.
+func expandVars(s string) (string, error) {
+	names, err := checkVars(s)
+	if err != nil {
+		return "", err
+	}
+	for _, name := range names {
+		if _, ok := os.LookupEnv(name); !ok {
+			return "", fmt.Errorf("$%s is not set", name)
+		}
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		name, n := varAt(s, i)
+		switch {
+		case n == 0:
+			b.WriteByte(s[i])
+		case name == "$":
+			b.WriteByte('$')
+		default:
+			b.WriteString(os.Getenv(name))
+		}
+		i += max(n-1, 0)
+	}
+	return b.String(), nil
+}
+
+// checkVars returns the names of the variables s references, or an error
+// for a malformed ${...} reference.
+func checkVars(s string) ([]string, error) {
+	var names []string
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' {
+			continue
+		}
+		if strings.HasPrefix(s[i:], "${") {
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed ${ in %q", s)
+			}
+			if name := s[i+2 : i+end]; !envNamePattern.MatchString(name) {
+				return nil, fmt.Errorf("%q is not a valid variable name", name)
+			}
+		}
+		name, n := varAt(s, i)
+		if n > 0 && name != "$" {
+			names = append(names, name)
+		}
+		i += max(n-1, 0)
+	}
+	return names, nil
+}
+
+var varPattern = regexp.MustCompile(`^\$(?:\$|\{([A-Za-z_][A-Za-z0-9_]*)\}|([A-Za-z_][A-Za-z0-9_]*))`)
+
+// varAt returns the variable referenced at s[i] and the length of the
+// reference, "$" for an escaped dollar, or a length of 0 if there is none.
+func varAt(s string, i int) (string, int) {
+	m := varPattern.FindStringSubmatch(s[i:])
+	switch {
+	case m == nil:
+		return "", 0
+	case m[1] != "":
+		return m[1], len(m[0])
+	case m[2] != "":
+		return m[2], len(m[0])
+	}
+	return "$", 2
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateMCPServer(t *testing.T) {
+	tests := []struct {
+		server MCPServer
+		want   []string
+	}{
+		{MCPServer{Command: "npx", Args: []string{"-y", "${PKG}"}, Env: map[string]string{"TOKEN": "$GITHUB_TOKEN"}, Cwd: "$HOME/src"}, nil},
+		{MCPServer{HTTPURL: "https://example.com/mcp", Headers: map[string]string{"Authorization": "Bearer ${TOKEN}"}, StartupTimeout: 5000}, nil},
+		{MCPServer{}, []string{"mcpServers.s: set command"}},
+		{MCPServer{Command: "x", HTTPURL: "https://example.com"}, []string{"mcpServers.s: command and httpUrl are exclusive"}},
+		{MCPServer{URL: "example.com/sse"}, []string{`mcpServers.s.url: "example.com/sse" must be an http or https URL`}},
+		{MCPServer{HTTPURL: "http://"}, []string{`mcpServers.s.httpUrl: "http://" has no host`}},
+		{MCPServer{URL: "https://example.com", Env: map[string]string{"A": "b"}, Cwd: "/tmp"}, []string{
+			"mcpServers.s.env: only applies to stdio servers", "mcpServers.s.cwd: only applies to stdio servers"}},
+		{MCPServer{Command: "x", Headers: map[string]string{"A": "b"}}, []string{"mcpServers.s.headers: only apply to remote servers"}},
+		{MCPServer{Command: "x", Env: map[string]string{"BAD-NAME": "1", "OK": "${UNCLOSED"}}, []string{
+			`mcpServers.s.env: "BAD-NAME" is not a valid variable name`, "mcpServers.s.env.OK: unclosed ${"}},
+		{MCPServer{HTTPURL: "https://example.com", Headers: map[string]string{"X Y": "1", "Z": "${1X}"}}, []string{
+			`mcpServers.s.headers: "X Y" is not a valid header name`, `mcpServers.s.headers.Z: "1X" is not a valid variable name`}},
+		{MCPServer{HTTPURL: "https://example.com", OAuth: &MCPOAuth{Enabled: true, TokenURL: "https://auth.example.com/token"}}, nil},
+		{MCPServer{Command: "x", OAuth: &MCPOAuth{RedirectURI: "localhost:7777"}}, []string{
+			"mcpServers.s.oauth: only applies to remote servers", `mcpServers.s.oauth.redirectUri: "localhost:7777" must be an http or https URL`}},
+		{MCPServer{Command: "x", Timeout: -1, StartupTimeout: -2}, []string{
+			"mcpServers.s.timeout: must not be negative", "mcpServers.s.startupTimeout: must not be negative"}},
+	}
+	for _, tt := range tests {
+		err := tt.server.Validate("s")
+		if len(tt.want) == 0 {
+			if err != nil {
+				t.Errorf("Validate(%+v) = %v, want no error", tt.server, err)
+			}
+			continue
+		}
+		for _, want := range tt.want {
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("Validate(%+v) = %v, want %q", tt.server, err, want)
+			}
+		}
+	}
+}
+
+func TestExpandMCPServer(t *testing.T) {
+	t.Setenv("MCP_TEST_TOKEN", "secret")
+	t.Setenv("MCP_TEST_DIR", "/srv")
+
+	server := MCPServer{
+		Command: "run",
+		Args:    []string{"--dir=${MCP_TEST_DIR}/data", "cost: $$5", "$ alone"},
+		Env:     map[string]string{"TOKEN": "$MCP_TEST_TOKEN"},
+		Cwd:     "$MCP_TEST_DIR",
+	}
+	got, err := server.Expand("s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got.Args, "|") != "--dir=/srv/data|cost: $5|$ alone" || got.Env["TOKEN"] != "secret" || got.Cwd != "/srv" {
+		t.Errorf("Expand() = %+v", got)
+	}
+	if server.Env["TOKEN"] != "$MCP_TEST_TOKEN" {
+		t.Error("Expand() modified the original settings")
+	}
+
+	remote := MCPServer{HTTPURL: "https://example.com", Headers: map[string]string{"Authorization": "Bearer ${MCP_TEST_UNSET}"}}
+	if _, err := remote.Expand("s"); err == nil || err.Error() != "mcpServers.s.headers.Authorization: $MCP_TEST_UNSET is not set" {
+		t.Errorf("Expand() error = %v, want the unset variable named", err)
+	}
+}
+
+func TestLoadValidatesMCPServers(t *testing.T) {
+	home := t.TempDir()
+	restore := SetUserHomeDirForTesting(home, nil)
+	defer restore()
+	t.Chdir(t.TempDir())
+
+	path := filepath.Join(home, settingsDirName, settingsFileName)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("[mcpServers.github]\nhttpUrl = \"api.github.com\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "settings.toml: mcpServers.github.httpUrl:") {
+		t.Errorf("Load() error = %v, want the invalid field named", err)
+	}
+}
@@ -0,0 +1,64 @@
+// Package debugserver serves the profiles of net/http/pprof and the
+// runtime metrics of expvar over HTTP, for --debug-server, so that the
+// memory growth of long sessions can be profiled while they run.
+package debugserver
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/shutdown"
+)
+
+var (
+	publish sync.Once
+	started = time.Now()
+)
+
+// Start serves the profiles at /debug/pprof/ and the metrics at
+// /debug/vars on addr, until the CLI exits, and returns the address it
+// listens on. The profiles reveal the conversation, so addr must be a
+// loopback address; one with only a port listens on localhost.
+func Start(addr string) (net.Addr, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("%s is not a loopback address; the debug server only listens on localhost", addr)
+	}
+	l, err := net.Listen("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+
+	publish.Do(func() {
+		// memstats and cmdline are published by expvar itself.
+		expvar.Publish("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
+		expvar.Publish("uptimeSeconds", expvar.Func(func() any { return time.Since(started).Seconds() }))
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(l)
+	shutdown.Register("stop the debug server", func(ctx context.Context) error {
+		return srv.Shutdown(ctx)
+	})
+	return l.Addr(), nil
+}
@@ -0,0 +1,55 @@
+package debugserver
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestStart(t *testing.T) {
+	addr, err := Start("localhost:0")
+	if err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	base := "http://" + addr.String()
+
+	resp, err := http.Get(base + "/debug/vars")
+	if err != nil {
+		t.Fatalf("GET /debug/vars failed: %v", err)
+	}
+	var vars map[string]json.RawMessage
+	err = json.NewDecoder(resp.Body).Decode(&vars)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("/debug/vars is not JSON: %v", err)
+	}
+	for _, name := range []string{"memstats", "goroutines", "uptimeSeconds"} {
+		if _, ok := vars[name]; !ok {
+			t.Errorf("/debug/vars has no %s", name)
+		}
+	}
+
+	resp, err = http.Get(base + "/debug/pprof/heap?debug=1")
+	if err != nil {
+		t.Fatalf("GET /debug/pprof/heap failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(body) == 0 {
+		t.Errorf("GET /debug/pprof/heap = %s, want the heap profile", resp.Status)
+	}
+
+	// A second server publishes the variables only once.
+	if _, err := Start(":0"); err != nil {
+		t.Errorf("Start(:0) failed: %v", err)
+	}
+}
+
+func TestStartRefusesOtherAddresses(t *testing.T) {
+	for _, addr := range []string{"0.0.0.0:0", "example.com:6060", "6060"} {
+		if _, err := Start(addr); err == nil {
+			t.Errorf("Start(%q) succeeded, want an error", addr)
+		}
+	}
+}
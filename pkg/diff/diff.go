@@ -0,0 +1,250 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Op is the kind of an edit.
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Edit is a run of tokens that is kept, inserted or deleted.
+type Edit struct {
+	Op   Op
+	Text string
+}
+
+// Lines diffs a and b line by line. Each edit holds a single line, without
+// its newline.
+func Lines(a, b string) []Edit {
+	return compute(splitLines(a), splitLines(b), false)
+}
+
+// Words diffs a and b word by word, treating runs of letters and digits, runs
+// of whitespace and single punctuation characters as tokens. Adjacent edits
+// with the same Op are merged.
+func Words(a, b string) []Edit {
+	return compute(tokenize(a), tokenize(b), true)
+}
+
+// Unified renders the differences between a and b as unified diff hunks with
+// the given number of context lines, without file headers. It returns "" if
+// a and b are equal.
+func Unified(a, b string, context int) string {
+	edits := Lines(a, b)
+
+	var out strings.Builder
+	for start := 0; start < len(edits); {
+		// Find the next change and the end of its hunk: changes closer
+		// than 2*context lines share a hunk.
+		first := start
+		for first < len(edits) && edits[first].Op == Equal {
+			first++
+		}
+		if first == len(edits) {
+			break
+		}
+		end, equal := first, 0
+		for i := first; i < len(edits) && equal <= 2*context; i++ {
+			if edits[i].Op == Equal {
+				equal++
+			} else {
+				equal, end = 0, i+1
+			}
+		}
+		from := max(first-context, start)
+		to := min(end+context, len(edits))
+
+		aLine, bLine := 1, 1
+		for _, e := range edits[:from] {
+			if e.Op != Insert {
+				aLine++
+			}
+			if e.Op != Delete {
+				bLine++
+			}
+		}
+		var aCount, bCount int
+		var body strings.Builder
+		for _, e := range edits[from:to] {
+			switch e.Op {
+			case Equal:
+				aCount++
+				bCount++
+				body.WriteString(" " + e.Text + "\n")
+			case Delete:
+				aCount++
+				body.WriteString("-" + e.Text + "\n")
+			case Insert:
+				bCount++
+				body.WriteString("+" + e.Text + "\n")
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n%s", hunkRange(aLine, aCount), hunkRange(bLine, bCount), body.String())
+		start = to
+	}
+	return out.String()
+}
+
+// hunkRange formats the start and length of a hunk side. An empty side
+// starts at the line before it.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+func tokenize(s string) []string {
+	var tokens []string
+	class := func(r rune) int {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			return 1
+		case unicode.IsSpace(r):
+			return 2
+		}
+		return 0
+	}
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		j := i + 1
+		if c := class(runes[i]); c != 0 {
+			for j < len(runes) && class(runes[j]) == c {
+				j++
+			}
+		}
+		tokens = append(tokens, string(runes[i:j]))
+		i = j
+	}
+	return tokens
+}
+
+// compute diffs two token sequences with Myers' algorithm, after trimming
+// their common prefix and suffix.
+func compute(a, b []string, merge bool) []Edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []Edit
+	add := func(op Op, text string) {
+		if n := len(edits); merge && n > 0 && edits[n-1].Op == op {
+			edits[n-1].Text += text
+			return
+		}
+		edits = append(edits, Edit{Op: op, Text: text})
+	}
+	for _, t := range a[:prefix] {
+		add(Equal, t)
+	}
+	for _, e := range myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		add(e.Op, e.Text)
+	}
+	for _, t := range a[len(a)-suffix:] {
+		add(Equal, t)
+	}
+	return edits
+}
+
+// myers returns the shortest edit script from a to b, deletions before
+// insertions within each change.
+func myers(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		var edits []Edit
+		for _, t := range a {
+			edits = append(edits, Edit{Delete, t})
+		}
+		for _, t := range b {
+			edits = append(edits, Edit{Insert, t})
+		}
+		return edits
+	}
+
+	// v[k+offset] is the furthest x reached on diagonal k; trace keeps a
+	// copy per edit distance for backtracking.
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	var trace [][]int
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+				x = v[k+1+offset]
+			} else {
+				x = v[k-1+offset] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+offset] = x
+			if x >= n && y >= m {
+				trace = append(trace, append([]int(nil), v...))
+				break search
+			}
+		}
+	}
+
+	var reversed []Edit
+	x, y := n, m
+	for d := len(trace) - 2; d >= 0 && (x > 0 || y > 0); d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[prevK+offset]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, Edit{Equal, a[x]})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			reversed = append(reversed, Edit{Insert, b[y]})
+		} else {
+			x--
+			reversed = append(reversed, Edit{Delete, a[x]})
+		}
+	}
+
+	edits := make([]Edit, 0, len(reversed))
+	for i := len(reversed) - 1; i >= 0; i-- {
+		edits = append(edits, reversed[i])
+	}
+	return edits
+}
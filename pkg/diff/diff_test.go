@@ -0,0 +1,70 @@
+package diff
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// sides reconstructs both inputs from an edit script.
+func sides(edits []Edit, sep string) (string, string) {
+	var a, b []string
+	for _, e := range edits {
+		if e.Op != Insert {
+			a = append(a, e.Text)
+		}
+		if e.Op != Delete {
+			b = append(b, e.Text)
+		}
+	}
+	return strings.Join(a, sep), strings.Join(b, sep)
+}
+
+func TestLinesRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randomText := func() string {
+		lines := make([]string, r.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a' + r.Intn(4)))
+		}
+		return strings.Join(lines, "\n")
+	}
+	for i := 0; i < 200; i++ {
+		a, b := randomText(), randomText()
+		edits := Lines(a, b)
+		if gotA, gotB := sides(edits, "\n"); gotA != a || gotB != b {
+			t.Fatalf("Lines(%q, %q) does not reproduce its inputs: %+v", a, b, edits)
+		}
+	}
+}
+
+func TestWords(t *testing.T) {
+	edits := Words("return x + 1, nil", "return x + 2, err")
+	want := []Edit{
+		{Equal, "return x + "}, {Delete, "1"}, {Insert, "2"}, {Equal, ", "}, {Delete, "nil"}, {Insert, "err"},
+	}
+	if len(edits) != len(want) {
+		t.Fatalf("Words() = %+v, want %+v", edits, want)
+	}
+	for i := range want {
+		if edits[i] != want[i] {
+			t.Errorf("edit %d = %+v, want %+v", i, edits[i], want[i])
+		}
+	}
+}
+
+func TestUnified(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+	want := "@@ -1,6 +1,6 @@\n 1\n 2\n-3\n+three\n 4\n 5\n 6\n" +
+		"@@ -10,3 +10,4 @@\n 10\n 11\n 12\n+13\n"
+	if got := Unified(a, b, 3); got != want {
+		t.Errorf("Unified() =\n%s\nwant\n%s", got, want)
+	}
+	if got := Unified("", "new\n", 3); got != "@@ -0,0 +1 @@\n+new\n" {
+		t.Errorf("Unified() of a new file = %q", got)
+	}
+	if got := Unified(a, a, 3); got != "" {
+		t.Errorf("Unified() of equal inputs = %q, want empty", got)
+	}
+}
@@ -0,0 +1,283 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DevNull is the file name of the missing side of a created or deleted file.
+const DevNull = "/dev/null"
+
+// FilePatch is the part of a unified diff that changes one file.
+type FilePatch struct {
+	OldName string // DevNull for a created file
+	NewName string // DevNull for a deleted file
+	Hunks   []*Hunk
+}
+
+// Hunk is a unified diff hunk.
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	// Lines are the hunk's lines, each starting with ' ', '-' or '+'.
+	Lines []string
+	// NoNewline is set if the new side lacks a final newline.
+	NoNewline bool
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// Parse parses a unified diff spanning one or more files, as produced by
+// diff -u or git diff. Text outside of file patches is ignored.
+func Parse(patch string) ([]*FilePatch, error) {
+	var (
+		files []*FilePatch
+		file  *FilePatch
+		hunk  *Hunk
+	)
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		// Lines belong to the current hunk until its counts are reached.
+		if hunk != nil && (hunk.OldLines > countOld(hunk) || hunk.NewLines > countNew(hunk)) {
+			switch {
+			case line == "" && i < len(lines)-1:
+				// Some generators drop the space of empty context lines.
+				hunk.Lines = append(hunk.Lines, " ")
+				continue
+			case line != "" && strings.ContainsRune(" -+", rune(line[0])):
+				hunk.Lines = append(hunk.Lines, line)
+				continue
+			case strings.HasPrefix(line, `\`):
+				continue
+			}
+			return nil, fmt.Errorf("line %d: hunk of %s ends early: expected %d more old and %d more new lines",
+				i+1, file.Name(), hunk.OldLines-countOld(hunk), hunk.NewLines-countNew(hunk))
+		}
+
+		switch {
+		case strings.HasPrefix(line, `\`) && hunk != nil:
+			// "\ No newline at end of file" after the last line of a side.
+			if last := hunk.Lines[len(hunk.Lines)-1]; last[0] != '-' {
+				hunk.NoNewline = true
+			}
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			file = &FilePatch{OldName: headerName(line[4:]), NewName: headerName(lines[i+1][4:])}
+			files = append(files, file)
+			hunk = nil
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if file == nil {
+				return nil, fmt.Errorf("line %d: hunk without a ---/+++ file header", i+1)
+			}
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("line %d: malformed hunk header %q", i+1, line)
+			}
+			hunk = &Hunk{
+				OldStart: atoi(m[1]), OldLines: count(m[2]),
+				NewStart: atoi(m[3]), NewLines: count(m[4]),
+			}
+			file.Hunks = append(file.Hunks, hunk)
+		default:
+			hunk = nil
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no file patches found; expected ---/+++ headers followed by @@ hunks")
+	}
+	for _, f := range files {
+		if len(f.Hunks) == 0 {
+			return nil, fmt.Errorf("%s: no hunks", f.Name())
+		}
+	}
+	return files, nil
+}
+
+// headerName extracts the file name from a ---/+++ header, dropping a
+// timestamp and git's a/ and b/ prefixes.
+func headerName(header string) string {
+	name, _, _ := strings.Cut(header, "\t")
+	name = strings.TrimSpace(name)
+	if unquoted, err := strconv.Unquote(name); err == nil {
+		name = unquoted
+	}
+	if name == DevNull {
+		return name
+	}
+	if strings.HasPrefix(name, "a/") || strings.HasPrefix(name, "b/") {
+		name = name[2:]
+	}
+	return name
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// count parses a hunk length, which defaults to 1 when omitted.
+func count(s string) int {
+	if s == "" {
+		return 1
+	}
+	return atoi(s)
+}
+
+func countOld(h *Hunk) int {
+	n := 0
+	for _, l := range h.Lines {
+		if l[0] != '+' {
+			n++
+		}
+	}
+	return n
+}
+
+func countNew(h *Hunk) int {
+	n := 0
+	for _, l := range h.Lines {
+		if l[0] != '-' {
+			n++
+		}
+	}
+	return n
+}
+
+// Name returns the file the patch applies to.
+func (f *FilePatch) Name() string {
+	if f.NewName != DevNull {
+		return f.NewName
+	}
+	return f.OldName
+}
+
+// Apply applies the patch to content. A hunk that does not match at its
+// stated line is looked for elsewhere in the file, and if that fails too,
+// with up to fuzz lines of leading and trailing context ignored, like
+// patch(1). Hunks must apply in order and must not overlap.
+func (f *FilePatch) Apply(content string, fuzz int) (string, error) {
+	result, _, err := f.ApplyNotes(content, fuzz)
+	return result, err
+}
+
+// ApplyNotes is Apply, but also returns notes on the hunks that did not
+// apply exactly as stated, as patch(1) prints them, e.g. "hunk 2 applied
+// at line 14 (offset 3 lines)".
+func (f *FilePatch) ApplyNotes(content string, fuzz int) (string, []string, error) {
+	var notes []string
+	lines := splitLines(content)
+	var out []string
+	pos := 0   // next unconsumed line of content
+	delta := 0 // offset of the previous hunk from its stated position
+	for i, h := range f.Hunks {
+		near := h.OldStart - 1 + delta
+		if h.OldLines == 0 {
+			// A pure insertion goes after line OldStart.
+			near++
+		}
+		var old, new []string
+		at, expected, trim := -1, near, 0
+		for ; trim <= fuzz && at < 0; trim++ {
+			front, back := leadingContext(h, trim), trailingContext(h, trim)
+			o, n := h.sides()
+			if trim > 0 && front+back >= len(o) {
+				break
+			}
+			o, n = o[front:len(o)-back], n[front:len(n)-back]
+			expected = near + front
+			if at = find(lines, o, pos, expected); at >= 0 {
+				old, new = o, n
+			}
+		}
+		if at < 0 {
+			return "", nil, fmt.Errorf("%s: hunk %d (@@ -%d,%d +%d,%d @@) does not match the current content",
+				f.Name(), i+1, h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+		}
+		// The loop counted one past the fuzz it found the hunk with.
+		if trim--; at != expected || trim > 0 {
+			note := fmt.Sprintf("hunk %d applied at line %d", i+1, at+1)
+			if at != expected {
+				note += fmt.Sprintf(" (offset %d lines)", at-expected)
+			}
+			if trim > 0 {
+				note += fmt.Sprintf(" with fuzz %d", trim)
+			}
+			notes = append(notes, note)
+		}
+		out = append(out, lines[pos:at]...)
+		out = append(out, new...)
+		pos = at + len(old)
+		delta += at - expected
+	}
+	out = append(out, lines[pos:]...)
+
+	result := strings.Join(out, "\n")
+	if len(out) > 0 && !(f.Hunks[len(f.Hunks)-1].NoNewline && pos == len(lines)) {
+		result += "\n"
+	}
+	return result, notes, nil
+}
+
+// sides returns the old and new lines of a hunk, without prefixes.
+func (h *Hunk) sides() (old, new []string) {
+	for _, l := range h.Lines {
+		switch l[0] {
+		case ' ':
+			old = append(old, l[1:])
+			new = append(new, l[1:])
+		case '-':
+			old = append(old, l[1:])
+		case '+':
+			new = append(new, l[1:])
+		}
+	}
+	return old, new
+}
+
+// leadingContext returns how many of the first n lines of a hunk are
+// context, which is what fuzz may ignore.
+func leadingContext(h *Hunk, n int) int {
+	i := 0
+	for i < n && i < len(h.Lines) && h.Lines[i][0] == ' ' {
+		i++
+	}
+	return i
+}
+
+func trailingContext(h *Hunk, n int) int {
+	i := 0
+	for i < n && i < len(h.Lines) && h.Lines[len(h.Lines)-1-i][0] == ' ' {
+		i++
+	}
+	return i
+}
+
+// find returns the index of the first line of want in lines at or after
+// from, searching outwards from near. Lines are compared ignoring trailing
+// whitespace. It returns -1 if want is not found.
+func find(lines, want []string, from, near int) int {
+	matches := func(at int) bool {
+		if at < from || at+len(want) > len(lines) {
+			return false
+		}
+		for i, w := range want {
+			if strings.TrimRight(lines[at+i], " \t") != strings.TrimRight(w, " \t") {
+				return false
+			}
+		}
+		return true
+	}
+	near = max(near, from)
+	for d := 0; near-d >= from || near+d <= len(lines); d++ {
+		if matches(near - d) {
+			return near - d
+		}
+		if d > 0 && matches(near+d) {
+			return near + d
+		}
+	}
+	return -1
+}
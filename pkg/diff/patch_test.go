@@ -0,0 +1,101 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	patch := "diff --git a/main.go b/main.go\n" +
+		"--- a/main.go\n+++ b/main.go\n" +
+		"@@ -1,3 +1,3 @@\n package main\n-var x = 1\n+var x = 2\n \n" +
+		"--- /dev/null\n+++ b/notes.txt\t2024-01-01 00:00:00\n" +
+		"@@ -0,0 +1 @@\n+hello\n\\ No newline at end of file\n"
+	files, err := Parse(patch)
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	if len(files) != 2 || files[0].Name() != "main.go" || files[1].OldName != DevNull || files[1].Name() != "notes.txt" {
+		t.Fatalf("Parse() = %+v", files)
+	}
+	if h := files[0].Hunks[0]; len(h.Lines) != 4 || h.Lines[3] != " " {
+		t.Errorf("Expected an empty context line without its space to be kept, got %q", h.Lines)
+	}
+	if !files[1].Hunks[0].NoNewline {
+		t.Error("Expected the missing final newline to be recorded")
+	}
+
+	for _, bad := range []string{
+		"no patch here",
+		"--- a/x\n+++ b/x\n@@ -1,3 +1,3 @@\n a\n-b\nc\n",
+		"@@ -1 +1 @@\n-a\n+b\n",
+	} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) should fail", bad)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	content := "a\nb\nc\nd\ne\nf\ng\nh\n"
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+		note  string
+	}{
+		{
+			"exact",
+			"--- a/f\n+++ b/f\n@@ -2,3 +2,3 @@\n b\n-c\n+C\n d\n",
+			"a\nb\nC\nd\ne\nf\ng\nh\n",
+
+			"",
+		},
+		{
+			"offset",
+			"--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n e\n-f\n+F\n g\n",
+			"a\nb\nc\nd\ne\nF\ng\nh\n",
+			"hunk 1 applied at line 5 (offset 4 lines)",
+		},
+		{
+			"fuzz",
+			"--- a/f\n+++ b/f\n@@ -3,5 +3,5 @@\n x\n d\n-e\n+E\n f\n y\n",
+			"a\nb\nc\nd\nE\nf\ng\nh\n",
+			"hunk 1 applied at line 4 with fuzz 1",
+		},
+		{
+			"insertion",
+			"--- a/f\n+++ b/f\n@@ -8,0 +9 @@\n+i\n",
+			"a\nb\nc\nd\ne\nf\ng\nh\ni\n",
+
+			"",
+		},
+		{
+			"no final newline",
+			"--- a/f\n+++ b/f\n@@ -7,2 +7,2 @@\n g\n-h\n+H\n\\ No newline at end of file\n",
+			"a\nb\nc\nd\ne\nf\ng\nH",
+
+			"",
+		},
+	}
+	for _, tt := range tests {
+		files, err := Parse(tt.patch)
+		if err != nil {
+			t.Fatalf("%s: Parse() failed: %v", tt.name, err)
+		}
+		got, notes, err := files[0].ApplyNotes(content, 2)
+		if err != nil {
+			t.Errorf("%s: ApplyNotes() failed: %v", tt.name, err)
+		} else if got != tt.want {
+			t.Errorf("%s: ApplyNotes() = %q, want %q", tt.name, got, tt.want)
+		}
+		if note := strings.Join(notes, "\n"); note != tt.note {
+			t.Errorf("%s: notes = %q, want %q", tt.name, note, tt.note)
+		}
+	}
+
+	files, _ := Parse("--- a/f\n+++ b/f\n@@ -2,3 +2,3 @@\n b\n-x\n+y\n d\n")
+	if _, err := files[0].Apply(content, 2); err == nil || !strings.Contains(err.Error(), "hunk 1") {
+		t.Errorf("Expected a mismatching hunk to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package editor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// preset is how to run a well-known editor so that it blocks until the file
+// is closed, and how to open it on a diff.
+type preset struct {
+	wait []string
+	diff []string // nil if the editor cannot show diffs
+}
+
+var presets = map[string]preset{
+	"code":    {wait: []string{"--wait"}, diff: []string{"--wait", "--diff"}},
+	"cursor":  {wait: []string{"--wait"}, diff: []string{"--wait", "--diff"}},
+	"zed":     {wait: []string{"--wait"}},
+	"vim":     {diff: []string{"-d"}},
+	"nvim":    {diff: []string{"-d"}},
+	"vi":      {},
+	"nano":    {},
+	"emacs":   {},
+	"notepad": {},
+}
+
+// detectOrder is the order in which installed editors are looked for when
+// none is configured.
+func detectOrder() []string {
+	if runtime.GOOS == "windows" {
+		return []string{"code", "notepad"}
+	}
+	return []string{"code", "nvim", "vim", "nano", "vi"}
+}
+
+// Editor is a resolved editor command.
+type Editor struct {
+	// Name is the configured name, or the name of the detected editor.
+	Name string
+	// Detected is set when no editor was configured and Name was found by
+	// looking for installed editors.
+	Detected bool
+	argv     []string
+	preset   *preset
+}
+
+// Resolve returns the editor for preferred, which is the name of a known
+// editor (code, vim, nano, ...) or a custom command such as
+// "subl -n -w". If preferred is empty, $VISUAL and $EDITOR are used, and
+// failing those the first installed known editor.
+func Resolve(preferred string) (*Editor, error) {
+	e := &Editor{Name: strings.TrimSpace(preferred)}
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if e.Name == "" {
+			e.Name = strings.TrimSpace(os.Getenv(env))
+		}
+	}
+	if e.Name == "" {
+		name, err := Detect()
+		if err != nil {
+			return nil, err
+		}
+		e.Name, e.Detected = name, true
+	}
+
+	e.argv = strings.Fields(e.Name)
+	if p, ok := presets[e.Name]; ok {
+		e.preset = &p
+	}
+	if _, err := exec.LookPath(e.argv[0]); err != nil {
+		return nil, fmt.Errorf("editor %q is not installed: %w", e.argv[0], err)
+	}
+	return e, nil
+}
+
+// Detect returns the first installed known editor.
+func Detect() (string, error) {
+	for _, name := range detectOrder() {
+		if _, err := exec.LookPath(name); err == nil {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no editor found; set general.preferredEditor or $EDITOR")
+}
+
+// Edit returns the command that opens path.
+func (e *Editor) Edit(path string) *exec.Cmd {
+	args := append([]string{}, e.argv[1:]...)
+	if e.preset != nil {
+		args = append(args, e.preset.wait...)
+	}
+	return exec.Command(e.argv[0], append(args, path)...)
+}
+
+// Diff returns the command that opens path side by side with original, for
+// editing path. Editors that cannot show diffs just open path.
+func (e *Editor) Diff(original, path string) *exec.Cmd {
+	if e.preset == nil || e.preset.diff == nil {
+		return e.Edit(path)
+	}
+	args := append(append([]string{}, e.argv[1:]...), e.preset.diff...)
+	return exec.Command(e.argv[0], append(args, original, path)...)
+}
+
+// TempFile writes content to a new temporary file named after name, so that
+// the editor picks the right syntax, and returns its path.
+func TempFile(name, content string) (string, error) {
+	f, err := os.CreateTemp("", "gemini-*-"+filepath.Base(name))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
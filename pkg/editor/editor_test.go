@@ -0,0 +1,76 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeEditors puts executables with the given names on an otherwise empty
+// PATH.
+func fakeEditors(t *testing.T, names ...string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake editors are shell scripts")
+	}
+	dir := t.TempDir()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "")
+}
+
+func TestResolve(t *testing.T) {
+	fakeEditors(t, "code", "vim", "nano", "subl")
+
+	tests := []struct {
+		preferred string
+		edit      string
+		diff      string
+	}{
+		{"code", "code --wait b", "code --wait --diff a b"},
+		{"vim", "vim b", "vim -d a b"},
+		{"nano", "nano b", "nano b"},
+		{"subl -n -w", "subl -n -w b", "subl -n -w b"},
+	}
+	for _, tt := range tests {
+		e, err := Resolve(tt.preferred)
+		if err != nil {
+			t.Fatalf("Resolve(%q) failed: %v", tt.preferred, err)
+		}
+		if got := strings.Join(e.Edit("b").Args, " "); got != tt.edit {
+			t.Errorf("Resolve(%q).Edit = %q, want %q", tt.preferred, got, tt.edit)
+		}
+		if got := strings.Join(e.Diff("a", "b").Args, " "); got != tt.diff {
+			t.Errorf("Resolve(%q).Diff = %q, want %q", tt.preferred, got, tt.diff)
+		}
+	}
+
+	if _, err := Resolve("emacs"); err == nil {
+		t.Error("Expected an error for an editor that is not installed")
+	}
+}
+
+func TestResolveDetects(t *testing.T) {
+	fakeEditors(t, "nano", "vim")
+	e, err := Resolve("")
+	if err != nil || e.Name != "vim" || !e.Detected {
+		t.Fatalf("Resolve(\"\") = %+v, %v, want detected vim", e, err)
+	}
+
+	t.Setenv("EDITOR", "nano")
+	if e, err := Resolve(""); err != nil || e.Name != "nano" || e.Detected {
+		t.Errorf("Expected $EDITOR to be used, got %+v, %v", e, err)
+	}
+
+	fakeEditors(t)
+	if _, err := Resolve(""); err == nil {
+		t.Error("Expected an error when no editor is installed")
+	}
+}
@@ -0,0 +1,91 @@
+package eval
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+func (e Expectation) validate() error {
+	kinds := 0
+	for _, set := range []bool{e.Regex != "", e.NotRegex != "", e.JSONSchema != nil, e.File != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return errNoKind
+	}
+	if e.File == "" && (e.Exists != nil || e.Contains != "" || e.Matches != "") {
+		return errors.New("exists, contains and matches apply to file expectations")
+	}
+	for _, pattern := range []string{e.Regex, e.NotRegex, e.Matches} {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check returns why response and the files in root fail the expectation,
+// or nil.
+func (e Expectation) check(response, root string) error {
+	switch {
+	case e.Regex != "":
+		if !regexp.MustCompile(e.Regex).MatchString(response) {
+			return fmt.Errorf("the response does not match %q", e.Regex)
+		}
+	case e.NotRegex != "":
+		if m := regexp.MustCompile(e.NotRegex).FindString(response); m != "" {
+			return fmt.Errorf("the response matches %q: %q", e.NotRegex, m)
+		}
+	case e.JSONSchema != nil:
+		var v any
+		if err := json.Unmarshal([]byte(stripFence(response)), &v); err != nil {
+			return fmt.Errorf("the response is not JSON: %v", err)
+		}
+		if err := validate(e.JSONSchema, v, "$"); err != nil {
+			return fmt.Errorf("the response does not match the schema: %v", err)
+		}
+	default:
+		return e.checkFile(root)
+	}
+	return nil
+}
+
+func (e Expectation) checkFile(root string) error {
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(e.File)))
+	exists := err == nil
+	if e.Exists != nil && !*e.Exists {
+		if exists {
+			return fmt.Errorf("%s exists", e.File)
+		}
+		return nil
+	}
+	if !exists {
+		return fmt.Errorf("%s: %v", e.File, errors.Unwrap(err))
+	}
+	if e.Contains != "" && !strings.Contains(string(data), e.Contains) {
+		return fmt.Errorf("%s does not contain %q", e.File, e.Contains)
+	}
+	if e.Matches != "" && !regexp.MustCompile(e.Matches).Match(data) {
+		return fmt.Errorf("%s does not match %q", e.File, e.Matches)
+	}
+	return nil
+}
+
+var fence = regexp.MustCompile("(?s)^```[\\w-]*\\n(.*?)\\n?```$")
+
+// stripFence returns the content of a response that is a single Markdown
+// code block.
+func stripFence(response string) string {
+	response = strings.TrimSpace(response)
+	if m := fence.FindStringSubmatch(response); m != nil {
+		return m[1]
+	}
+	return response
+}
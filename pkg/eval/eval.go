@@ -0,0 +1,268 @@
+// Package eval runs suites of prompts against the model and checks the
+// responses and the files the agent leaves behind, so that users can
+// regression-test their context files and custom commands with
+// `gemini eval <suite.yaml>`.
+//
+// A suite looks like:
+//
+//	timeout: 2m
+//	cases:
+//	  - name: adds a changelog entry
+//	    workspace: fixtures/app    # copied to a temporary directory
+//	    prompt: /changelog fix the login redirect
+//	    expect:
+//	      - regex: (?i)changelog
+//	      - file: CHANGELOG.md
+//	        contains: login redirect
+//	  - name: answers in JSON
+//	    prompt: List three colors as a JSON array of strings.
+//	    expect:
+//	      - json_schema: {type: array, items: {type: string}, minItems: 3}
+package eval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/commands"
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/tools"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultTimeout bounds a case unless the suite sets a timeout.
+const DefaultTimeout = 5 * time.Minute
+
+// Suite is a set of cases.
+type Suite struct {
+	// Timeout bounds each case, as a duration such as "90s".
+	Timeout string `yaml:"timeout"`
+	Cases   []Case `yaml:"cases"`
+
+	// dir is the directory of the suite file, against which workspaces are
+	// resolved.
+	dir     string
+	timeout time.Duration
+}
+
+// Case is a prompt and the expectations on its outcome.
+type Case struct {
+	Name string `yaml:"name"`
+	// Prompt is sent as is, or runs a custom command if it starts with
+	// "/name".
+	Prompt string `yaml:"prompt"`
+	// Workspace is a fixture directory, relative to the suite, copied to a
+	// temporary directory the case runs in. Without it, the case runs in an
+	// empty directory.
+	Workspace string        `yaml:"workspace"`
+	Expect    []Expectation `yaml:"expect"`
+}
+
+// Expectation is one check. Exactly one of Regex, NotRegex, JSONSchema and
+// File is set.
+type Expectation struct {
+	// Regex must match the response; NotRegex must not.
+	Regex    string `yaml:"regex"`
+	NotRegex string `yaml:"not_regex"`
+	// JSONSchema must validate the response, parsed as JSON once stripped
+	// of a Markdown code fence.
+	JSONSchema map[string]any `yaml:"json_schema"`
+	// File is a path in the case's workspace. It must exist, unless Exists
+	// is false, and contain Contains and match Matches if they are set.
+	File     string `yaml:"file"`
+	Exists   *bool  `yaml:"exists"`
+	Contains string `yaml:"contains"`
+	Matches  string `yaml:"matches"`
+}
+
+// Load reads and validates a suite file.
+func Load(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Suite
+	dec := yaml.NewDecoder(strings.NewReader(string(data)))
+	dec.KnownFields(true)
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	s.dir = filepath.Dir(path)
+	s.timeout = DefaultTimeout
+	if s.Timeout != "" {
+		if s.timeout, err = time.ParseDuration(s.Timeout); err != nil || s.timeout <= 0 {
+			return nil, fmt.Errorf("%s: invalid timeout %q", path, s.Timeout)
+		}
+	}
+	if len(s.Cases) == 0 {
+		return nil, fmt.Errorf("%s: the suite has no cases", path)
+	}
+	for i, c := range s.Cases {
+		if c.Name == "" {
+			s.Cases[i].Name = fmt.Sprintf("case %d", i+1)
+		}
+		if strings.TrimSpace(c.Prompt) == "" {
+			return nil, fmt.Errorf("%s: %s has no prompt", path, s.Cases[i].Name)
+		}
+		for j, e := range c.Expect {
+			if err := e.validate(); err != nil {
+				return nil, fmt.Errorf("%s: %s, expectation %d: %w", path, s.Cases[i].Name, j+1, err)
+			}
+		}
+	}
+	return &s, nil
+}
+
+// Converse sends prompt with the tools working in ws and returns the
+// model's response.
+type Converse func(ctx context.Context, ws *tools.Workspace, prompt string) (string, error)
+
+// Result is the outcome of a case.
+type Result struct {
+	Name   string        `json:"name"`
+	Passed bool          `json:"passed"`
+	Error  string        `json:"error,omitempty"`
+	Failed []string      `json:"failed,omitempty"`
+	Time   time.Duration `json:"durationNs"`
+}
+
+// Run runs the cases one after the other with converse, passing each
+// result to report as it is known. Changes the agent makes are applied
+// without confirmation, since cases run in temporary directories.
+func (s *Suite) Run(ctx context.Context, settings *config.Settings, converse Converse, report func(Result)) []Result {
+	var results []Result
+	for _, c := range s.Cases {
+		start := time.Now()
+		r := Result{Name: c.Name}
+		failures, err := s.runCase(ctx, settings, c, converse)
+		r.Time = time.Since(start)
+		switch {
+		case err != nil:
+			r.Error = err.Error()
+		default:
+			r.Failed = failures
+			r.Passed = len(failures) == 0
+		}
+		if report != nil {
+			report(r)
+		}
+		results = append(results, r)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return results
+}
+
+func (s *Suite) runCase(ctx context.Context, settings *config.Settings, c Case, converse Converse) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	root, err := os.MkdirTemp("", "gemini-eval-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(root)
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return nil, err
+	}
+	if c.Workspace != "" {
+		if err := copyDir(filepath.Join(s.dir, c.Workspace), root); err != nil {
+			return nil, fmt.Errorf("copying the workspace: %w", err)
+		}
+	}
+
+	ws, err := tools.NewWorkspaceAt(root, settings)
+	if err != nil {
+		return nil, err
+	}
+	// Temporary directories would only clutter the usage records.
+	ws.Usage = nil
+	ws.Confirm = func(context.Context, string, string) (bool, error) { return true, nil }
+
+	prompt, err := expandCommand(ctx, ws, c.Prompt)
+	if err != nil {
+		return nil, err
+	}
+	response, err := converse(ctx, ws, prompt)
+	if err != nil {
+		return nil, err
+	}
+	var failures []string
+	for _, e := range c.Expect {
+		if err := e.check(response, root); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	return failures, nil
+}
+
+// expandCommand returns the prompt of the custom command prompt invokes, if
+// it starts with a slash, and prompt otherwise.
+func expandCommand(ctx context.Context, ws *tools.Workspace, prompt string) (string, error) {
+	if !strings.HasPrefix(prompt, "/") {
+		return prompt, nil
+	}
+	name, args, _ := strings.Cut(strings.TrimPrefix(prompt, "/"), " ")
+	cmds, err := commands.Load(ws.Roots[0])
+	for _, c := range cmds {
+		if c.Name != name {
+			continue
+		}
+		inv, err := c.Prepare(args)
+		if err != nil {
+			return "", fmt.Errorf("/%s: %w", name, err)
+		}
+		return inv.Expand(ctx, func(ctx context.Context, command string) (string, int, error) {
+			return tools.RunShell(ctx, ws, ws.Roots[0], command, nil)
+		})
+	}
+	if err != nil {
+		return "", fmt.Errorf("no custom command /%s: %w", name, err)
+	}
+	return "", fmt.Errorf("no custom command /%s", name)
+}
+
+// copyDir copies the regular files and directories under src to dst.
+func copyDir(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", src)
+	}
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, 0755)
+		case d.Type().IsRegular():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(target, data, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+var errNoKind = errors.New("set one of regex, not_regex, json_schema and file")
@@ -0,0 +1,165 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/tools"
+	"github.com/google/generative-ai-go/genai"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		suite string
+		want  string
+	}{
+		{"cases: []", "no cases"},
+		{"cases:\n  - name: a", "a has no prompt"},
+		{"timeout: soon\ncases:\n  - prompt: hi", `invalid timeout "soon"`},
+		{"cases:\n  - prompt: hi\n    expect:\n      - {}", "case 1, expectation 1: set one of"},
+		{"cases:\n  - prompt: hi\n    expect:\n      - {regex: a, file: b}", "set one of"},
+		{"cases:\n  - prompt: hi\n    expect:\n      - {regex: a, contains: b}", "apply to file expectations"},
+		{"cases:\n  - prompt: hi\n    expect:\n      - {regex: '('}", "missing closing )"},
+		{"cases:\n  - prompt: hi\n    expected: []", "field expected not found"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "suite.yaml")
+		writeFile(t, path, tt.suite)
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Load(%q) error = %v, want %q", tt.suite, err, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "notes", "a.txt"), "version: 1.2.3\n")
+	no := false
+	schema := map[string]any{
+		"type":                 "object",
+		"required":             []any{"colors"},
+		"additionalProperties": false,
+		"properties": map[string]any{
+			"colors": map[string]any{"type": "array", "minItems": 2, "items": map[string]any{"enum": []any{"red", "green"}}},
+			"count":  map[string]any{"type": "integer", "maximum": 5},
+		},
+	}
+	tests := []struct {
+		expect   Expectation
+		response string
+		want     string
+	}{
+		{Expectation{Regex: `(?i)hello`}, "Hello!", ""},
+		{Expectation{Regex: `bye`}, "Hello!", `does not match "bye"`},
+		{Expectation{NotRegex: `(?i)sorry`}, "Sorry, I can't", `matches "(?i)sorry": "Sorry"`},
+		{Expectation{JSONSchema: schema}, "```json\n{\"colors\": [\"red\", \"green\"], \"count\": 2}\n```", ""},
+		{Expectation{JSONSchema: schema}, `{"colors": ["red"]}`, "$.colors: fewer than 2 items"},
+		{Expectation{JSONSchema: schema}, `{"colors": ["red", "blue"]}`, `$.colors[1]: blue is not one of`},
+		{Expectation{JSONSchema: schema}, `{"colors": ["red", "red"], "count": 2.5}`, "$.count: want type integer, got number"},
+		{Expectation{JSONSchema: schema}, `{"colors": ["red", "red"], "size": 1}`, `unexpected property "size"`},
+		{Expectation{JSONSchema: schema}, `{}`, `missing property "colors"`},
+		{Expectation{JSONSchema: schema}, `colors: red`, "not JSON"},
+		{Expectation{File: "notes/a.txt", Contains: "1.2.3", Matches: `(?m)^version: \d`}, "", ""},
+		{Expectation{File: "notes/a.txt", Contains: "2.0"}, "", `notes/a.txt does not contain "2.0"`},
+		{Expectation{File: "notes/b.txt"}, "", "notes/b.txt: no such file"},
+		{Expectation{File: "notes/b.txt", Exists: &no}, "", ""},
+		{Expectation{File: "notes/a.txt", Exists: &no}, "", "notes/a.txt exists"},
+	}
+	for _, tt := range tests {
+		err := tt.expect.check(tt.response, root)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("check(%+v, %q) = %v, want no failure", tt.expect, tt.response, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("check(%+v, %q) = %v, want %q", tt.expect, tt.response, err, tt.want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	home := t.TempDir()
+	restore := config.SetUserHomeDirForTesting(home, nil)
+	defer restore()
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "fixture", "README.md"), "# App\n")
+	writeFile(t, filepath.Join(dir, "fixture", ".gemini", "commands", "note.toml"),
+		"prompt = \"Write {{args}} to NOTES.md. The readme starts with !{head -n 1 README.md}\"")
+	writeFile(t, filepath.Join(dir, "suite.yaml"), `
+cases:
+  - name: runs the command
+    workspace: fixture
+    prompt: /note release notes
+    expect:
+      - regex: "Write release notes to NOTES.md. The readme starts with # App"
+      - file: NOTES.md
+        contains: release notes
+      - file: README.md
+  - name: starts from a clean directory
+    prompt: Is there a note?
+    expect:
+      - file: NOTES.md
+      - regex: "yes"
+  - name: unknown command
+    prompt: /missing
+`)
+	suite, err := Load(filepath.Join(dir, "suite.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The fake model echoes the prompt and, for /note, writes the file
+	// through the shell tool.
+	var roots []string
+	converse := func(ctx context.Context, ws *tools.Workspace, prompt string) (string, error) {
+		roots = append(roots, ws.Roots[0])
+		if strings.HasPrefix(prompt, "Write") {
+			resp := tools.ExecuteToolCall(ctx, ws, &genai.FunctionCall{
+				Name: tools.ShellToolName,
+				Args: map[string]any{"command": "echo 'release notes' > NOTES.md"},
+			})
+			if out := resp.(*genai.FunctionResponse).Response; out["exit_code"] != 0 {
+				return "", errors.New("the shell tool failed")
+			}
+		}
+		return prompt, nil
+	}
+	var reported []string
+	results := suite.Run(context.Background(), nil, converse, func(r Result) { reported = append(reported, r.Name) })
+
+	if len(results) != 3 || len(reported) != 3 {
+		t.Fatalf("got %d results and %d reports, want 3", len(results), len(reported))
+	}
+	if r := results[0]; !r.Passed {
+		t.Errorf("first case failed: %+v", r)
+	}
+	if r := results[1]; r.Passed || len(r.Failed) != 2 || !strings.Contains(r.Failed[0], "NOTES.md") {
+		t.Errorf("second case should fail both expectations, got %+v", r)
+	}
+	if r := results[2]; r.Passed || !strings.Contains(r.Error, "no custom command /missing") {
+		t.Errorf("third case should report the unknown command, got %+v", r)
+	}
+	for _, root := range roots {
+		if _, err := os.Stat(root); !os.IsNotExist(err) {
+			t.Errorf("the workspace %s was not removed", root)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "fixture", "NOTES.md")); !os.IsNotExist(err) {
+		t.Error("the fixture was modified")
+	}
+}
@@ -0,0 +1,148 @@
+package eval
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"unicode/utf8"
+)
+
+// validate checks v, decoded from JSON, against the subset of JSON Schema
+// suites need: type, enum, const, properties, required,
+// additionalProperties: false, items, the length and size bounds, minimum,
+// maximum and pattern. path locates v in error messages.
+func validate(schema map[string]any, v any, path string) error {
+	if t, ok := schema["type"]; ok {
+		types, ok := t.([]any)
+		if !ok {
+			types = []any{t}
+		}
+		if !slices.ContainsFunc(types, func(t any) bool { return hasType(v, fmt.Sprint(t)) }) {
+			return fmt.Errorf("%s: want type %v, got %s", path, t, typeOf(v))
+		}
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		if !slices.ContainsFunc(enum, func(e any) bool { return equal(e, v) }) {
+			return fmt.Errorf("%s: %v is not one of %v", path, v, enum)
+		}
+	}
+	if c, ok := schema["const"]; ok && !equal(c, v) {
+		return fmt.Errorf("%s: want %v, got %v", path, c, v)
+	}
+
+	switch v := v.(type) {
+	case string:
+		n := utf8.RuneCountInString(v)
+		if min, ok := number(schema["minLength"]); ok && float64(n) < min {
+			return fmt.Errorf("%s: shorter than %v characters", path, min)
+		}
+		if max, ok := number(schema["maxLength"]); ok && float64(n) > max {
+			return fmt.Errorf("%s: longer than %v characters", path, max)
+		}
+		if p, ok := schema["pattern"].(string); ok {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return fmt.Errorf("%s: pattern: %v", path, err)
+			}
+			if !re.MatchString(v) {
+				return fmt.Errorf("%s: %q does not match %q", path, v, p)
+			}
+		}
+	case float64:
+		if min, ok := number(schema["minimum"]); ok && v < min {
+			return fmt.Errorf("%s: %v is less than %v", path, v, min)
+		}
+		if max, ok := number(schema["maximum"]); ok && v > max {
+			return fmt.Errorf("%s: %v is greater than %v", path, v, max)
+		}
+	case []any:
+		if min, ok := number(schema["minItems"]); ok && float64(len(v)) < min {
+			return fmt.Errorf("%s: fewer than %v items", path, min)
+		}
+		if max, ok := number(schema["maxItems"]); ok && float64(len(v)) > max {
+			return fmt.Errorf("%s: more than %v items", path, max)
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				if err := validate(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]any:
+		if required, ok := schema["required"].([]any); ok {
+			for _, name := range required {
+				if _, ok := v[fmt.Sprint(name)]; !ok {
+					return fmt.Errorf("%s: missing property %q", path, name)
+				}
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		for name, value := range v {
+			sub, ok := properties[name].(map[string]any)
+			if !ok {
+				if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+					return fmt.Errorf("%s: unexpected property %q", path, name)
+				}
+				continue
+			}
+			if err := validate(sub, value, path+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func hasType(v any, t string) bool {
+	switch t {
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	}
+	return typeOf(v) == t
+}
+
+func typeOf(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// number converts the numbers YAML decodes to float64, as JSON decodes
+// them.
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// equal compares a value from the schema, decoded from YAML, with one from
+// the response, decoded from JSON.
+func equal(schema, v any) bool {
+	if n, ok := number(schema); ok {
+		f, ok := v.(float64)
+		return ok && f == n
+	}
+	return reflect.DeepEqual(schema, v)
+}
@@ -0,0 +1,168 @@
+// Package finish recognizes model responses that ended without a usable
+// answer, such as empty or truncated ones, and decides how to recover from
+// them.
+package finish
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// MaxRetries is how many times a prompt is retried or continued after
+// responses with problems before they are reported as they are.
+const MaxRetries = 2
+
+// ContinuePrompt asks the model to go on with a truncated response.
+const ContinuePrompt = "Your last response was cut off. Continue exactly where it stopped, without repeating anything."
+
+// malformedFunctionCall is the finish reason of responses whose function
+// call the API could not parse. The client library predates it.
+const malformedFunctionCall genai.FinishReason = 10
+
+// Problem is what is wrong with a response.
+type Problem int
+
+const (
+	// None means the response is usable.
+	None Problem = iota
+	// Empty means the response has no candidate, or no content in it.
+	Empty
+	// Truncated means the response stopped at the output token limit.
+	Truncated
+	// MalformedCall means the model produced a function call that could
+	// not be parsed.
+	MalformedCall
+)
+
+// String describes p to the user.
+func (p Problem) String() string {
+	switch p {
+	case Empty:
+		return "The model returned an empty response."
+	case Truncated:
+		return "The response was cut off at the output token limit."
+	case MalformedCall:
+		return "The model produced a malformed function call."
+	}
+	return "The response is complete."
+}
+
+// Recovery is how to go on after a response.
+type Recovery int
+
+const (
+	// Accept uses the response as it is.
+	Accept Recovery = iota
+	// Continue keeps the response and sends ContinuePrompt.
+	Continue
+	// Resend drops the response and sends the request again, with the
+	// parameters changed by Adjust.
+	Resend
+)
+
+// Check returns the problem with resp, the first candidate of which is the
+// one the chat history keeps.
+func Check(resp *genai.GenerateContentResponse) Problem {
+	if resp == nil || len(resp.Candidates) == 0 {
+		return Empty
+	}
+	switch c := resp.Candidates[0]; {
+	case c.FinishReason == genai.FinishReasonMaxTokens:
+		return Truncated
+	case c.FinishReason == malformedFunctionCall:
+		return MalformedCall
+	case !hasContent(c):
+		return Empty
+	}
+	return None
+}
+
+// Plan returns the problem with resp and how to recover from it, after
+// retries earlier retries of the same prompt.
+func Plan(resp *genai.GenerateContentResponse, retries int) (Problem, Recovery) {
+	p := Check(resp)
+	switch {
+	case p == None || retries >= MaxRetries:
+		return p, Accept
+	case p == Truncated && hasContent(resp.Candidates[0]) && !hasCalls(resp.Candidates[0]):
+		return p, Continue
+	case p == Truncated && hasCalls(resp.Candidates[0]):
+		// The calls are complete; their results let the model go on.
+		return p, Accept
+	}
+	return p, Resend
+}
+
+// Adjust changes the parameters of model for resending a request that got
+// a response with problem p, and returns the function that restores them:
+// a truncated response without content gets twice the output token limit,
+// if one is set, and the other problems are retried at temperature 0.
+func Adjust(model *genai.GenerativeModel, p Problem) (restore func()) {
+	maxTokens, temperature := model.MaxOutputTokens, model.Temperature
+	restore = func() { model.MaxOutputTokens, model.Temperature = maxTokens, temperature }
+	if p == Truncated && maxTokens != nil {
+		model.SetMaxOutputTokens(*maxTokens * 2)
+		return restore
+	}
+	model.SetTemperature(0)
+	return restore
+}
+
+// reasonNames are the API names of the finish reasons.
+var reasonNames = map[genai.FinishReason]string{
+	genai.FinishReasonStop:       "STOP",
+	genai.FinishReasonMaxTokens:  "MAX_TOKENS",
+	genai.FinishReasonSafety:     "SAFETY",
+	genai.FinishReasonRecitation: "RECITATION",
+	genai.FinishReasonOther:      "OTHER",
+	malformedFunctionCall:        "MALFORMED_FUNCTION_CALL",
+}
+
+// Reason describes why resp ended, for reports of problems, e.g.
+// "finish reason MAX_TOKENS".
+func Reason(resp *genai.GenerateContentResponse) string {
+	if resp == nil || len(resp.Candidates) == 0 {
+		return "no candidates"
+	}
+	r := resp.Candidates[0].FinishReason
+	if r == genai.FinishReasonUnspecified {
+		return "no finish reason"
+	}
+	if name, ok := reasonNames[r]; ok {
+		return "finish reason " + name
+	}
+	return fmt.Sprintf("finish reason %d", r)
+}
+
+// Report describes problem p with resp to the user.
+func Report(p Problem, resp *genai.GenerateContentResponse) string {
+	return fmt.Sprintf("%s (%s)", strings.TrimSuffix(p.String(), "."), Reason(resp))
+}
+
+// hasContent reports whether c has a part other than empty text.
+func hasContent(c *genai.Candidate) bool {
+	if c.Content == nil {
+		return false
+	}
+	for _, p := range c.Content.Parts {
+		if t, ok := p.(genai.Text); !ok || strings.TrimSpace(string(t)) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCalls reports whether c calls functions.
+func hasCalls(c *genai.Candidate) bool {
+	if c.Content == nil {
+		return false
+	}
+	for _, p := range c.Content.Parts {
+		if _, ok := p.(genai.FunctionCall); ok {
+			return true
+		}
+	}
+	return false
+}
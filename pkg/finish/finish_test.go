@@ -0,0 +1,70 @@
+package finish
+
+import (
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+func TestPlan(t *testing.T) {
+	response := func(reason genai.FinishReason, parts ...genai.Part) *genai.GenerateContentResponse {
+		c := &genai.Candidate{FinishReason: reason}
+		if parts != nil {
+			c.Content = &genai.Content{Parts: parts}
+		}
+		return &genai.GenerateContentResponse{Candidates: []*genai.Candidate{c}}
+	}
+	tests := []struct {
+		name     string
+		resp     *genai.GenerateContentResponse
+		retries  int
+		problem  Problem
+		recovery Recovery
+	}{
+		{"complete", response(genai.FinishReasonStop, genai.Text("hi")), 0, None, Accept},
+		{"no candidates", &genai.GenerateContentResponse{}, 0, Empty, Resend},
+		{"blank text", response(genai.FinishReasonStop, genai.Text(" ")), 0, Empty, Resend},
+		{"truncated text", response(genai.FinishReasonMaxTokens, genai.Text("half")), 0, Truncated, Continue},
+		{"truncated before any text", response(genai.FinishReasonMaxTokens), 0, Truncated, Resend},
+		{"truncated after a call", response(genai.FinishReasonMaxTokens, genai.FunctionCall{Name: "ls"}), 0, Truncated, Accept},
+		{"malformed call", response(malformedFunctionCall), 1, MalformedCall, Resend},
+		{"out of retries", response(malformedFunctionCall), MaxRetries, MalformedCall, Accept},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problem, recovery := Plan(tt.resp, tt.retries)
+			if problem != tt.problem || recovery != tt.recovery {
+				t.Errorf("Plan() = %v, %v, want %v, %v", problem, recovery, tt.problem, tt.recovery)
+			}
+		})
+	}
+}
+
+func TestAdjust(t *testing.T) {
+	model := &genai.GenerativeModel{}
+	model.SetMaxOutputTokens(100)
+	restore := Adjust(model, Truncated)
+	if *model.MaxOutputTokens != 200 || model.Temperature != nil {
+		t.Errorf("Adjust(Truncated) set max tokens %d, temperature %v, want 200 and unset", *model.MaxOutputTokens, model.Temperature)
+	}
+	restore()
+	if *model.MaxOutputTokens != 100 {
+		t.Errorf("restore set max tokens %d, want 100", *model.MaxOutputTokens)
+	}
+
+	restore = Adjust(model, Empty)
+	if model.Temperature == nil || *model.Temperature != 0 {
+		t.Errorf("Adjust(Empty) set temperature %v, want 0", model.Temperature)
+	}
+	restore()
+	if model.Temperature != nil {
+		t.Errorf("restore left temperature %v, want it unset", *model.Temperature)
+	}
+}
+
+func TestReport(t *testing.T) {
+	resp := &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{FinishReason: genai.FinishReasonMaxTokens}}}
+	if got, want := Report(Truncated, resp), "The response was cut off at the output token limit (finish reason MAX_TOKENS)"; got != want {
+		t.Errorf("Report() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,123 @@
+// Package generation applies the generation settings of model.generation:
+// stop sequences, and a prefix that the responses to the user's prompts
+// start with. The API takes the prefix as the start of the model's turn at
+// the end of the request, which a chat session cannot send, so it is added
+// to the request bodies on their way out.
+package generation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/apirequest"
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// MaxStopSequences is the most stop sequences the API accepts.
+const MaxStopSequences = 5
+
+// Settings returns the generation settings of m, which may be nil.
+func Settings(m *config.ModelSettings) *config.GenerationSettings {
+	if m == nil || m.Generation == nil {
+		return &config.GenerationSettings{}
+	}
+	return m.Generation
+}
+
+// Check reports settings the API would refuse.
+func Check(g *config.GenerationSettings) error {
+	if len(g.StopSequences) > MaxStopSequences {
+		return fmt.Errorf("%d stop sequences, but the API accepts at most %d", len(g.StopSequences), MaxStopSequences)
+	}
+	for _, s := range g.StopSequences {
+		if s == "" {
+			return fmt.Errorf("empty stop sequence")
+		}
+	}
+	return nil
+}
+
+// Apply sets the stop sequences of g on model.
+func Apply(model *genai.GenerativeModel, g *config.GenerationSettings) {
+	if len(g.StopSequences) > 0 {
+		model.StopSequences = g.StopSequences
+	}
+}
+
+type prefixKey struct{}
+
+// WithPrefix returns a context whose generation requests ask for a response
+// starting with prefix, when sent through Transport.
+func WithPrefix(ctx context.Context, prefix string) context.Context {
+	if prefix == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, prefixKey{}, prefix)
+}
+
+// AddPrefix records in c, the response to a request with prefix, the
+// prefix the model continued, which the response leaves out.
+func AddPrefix(c *genai.Content, prefix string) {
+	if c == nil || prefix == "" {
+		return
+	}
+	if len(c.Parts) > 0 {
+		if text, ok := c.Parts[0].(genai.Text); ok {
+			c.Parts[0] = genai.Text(prefix) + text
+			return
+		}
+	}
+	c.Parts = append([]genai.Part{genai.Text(prefix)}, c.Parts...)
+}
+
+// Transport returns a round tripper that ends the generation requests it
+// passes to base with the prefix of their context, if any, as the start of
+// the model's turn.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return &transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	prefix, _ := req.Context().Value(prefixKey{}).(string)
+	if prefix == "" || !apirequest.IsGeneration(req) {
+		return t.base.RoundTrip(req)
+	}
+
+	req, err := apirequest.RewriteBody(req, func(body []byte) ([]byte, error) { return withPrefix(body, prefix) })
+	if err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// withPrefix appends a model turn of prefix to the contents of a request
+// body ending with a user turn, leaving the rest as it is.
+func withPrefix(body []byte, prefix string) ([]byte, error) {
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, err
+	}
+	var contents []map[string]any
+	if err := json.Unmarshal(request["contents"], &contents); err != nil {
+		return nil, err
+	}
+	if len(contents) == 0 || contents[len(contents)-1]["role"] != "user" {
+		return body, nil
+	}
+	contents = append(contents, map[string]any{
+		"role":  "model",
+		"parts": []map[string]string{{"text": prefix}},
+	})
+	var err error
+	if request["contents"], err = json.Marshal(contents); err != nil {
+		return nil, err
+	}
+	return json.Marshal(request)
+}
@@ -0,0 +1,85 @@
+package generation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/auth"
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+)
+
+func TestTransportSendsPrefix(t *testing.T) {
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		request = nil
+		json.Unmarshal(data, &request)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(w, `[{"candidates":[{"content":{"role":"model","parts":[{"text":" world"}]},"finishReason":"STOP"}]}]`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey("key"), option.WithEndpoint(server.URL),
+		auth.ClientOption("key", Transport(http.DefaultTransport)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	model := client.GenerativeModel("gemini-pro")
+	Apply(model, &config.GenerationSettings{StopSequences: []string{"END"}})
+	chat := model.StartChat()
+
+	if _, err := chat.SendMessage(WithPrefix(ctx, "Hello"), genai.Text("hi")); err != nil {
+		t.Fatal(err)
+	}
+	contents, _ := request["contents"].([]any)
+	if len(contents) != 2 {
+		t.Fatalf("contents = %v, want the prompt and the prefix", contents)
+	}
+	want := map[string]any{"role": "model", "parts": []any{map[string]any{"text": "Hello"}}}
+	if !reflect.DeepEqual(contents[1], want) {
+		t.Errorf("last content = %v, want %v", contents[1], want)
+	}
+	generationConfig, _ := request["generationConfig"].(map[string]any)
+	if !reflect.DeepEqual(generationConfig["stopSequences"], []any{"END"}) {
+		t.Errorf("generationConfig = %v, want the stop sequence", generationConfig)
+	}
+
+	AddPrefix(chat.History[len(chat.History)-1], "Hello")
+	if got := chat.History[len(chat.History)-1].Parts[0]; got != genai.Text("Hello world") {
+		t.Errorf("response in history = %q, want the prefix added", got)
+	}
+
+	if _, err := chat.SendMessage(ctx, genai.Text("again")); err != nil {
+		t.Fatal(err)
+	}
+	if contents, _ := request["contents"].([]any); len(contents) != 3 {
+		t.Errorf("contents = %v, want no prefix without one in the context", contents)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		stop    []string
+		wantErr bool
+	}{
+		{nil, false},
+		{[]string{"a", "b", "c", "d", "e"}, false},
+		{[]string{"a", "b", "c", "d", "e", "f"}, true},
+		{[]string{""}, true},
+	}
+	for _, tt := range tests {
+		if err := Check(&config.GenerationSettings{StopSequences: tt.stop}); (err != nil) != tt.wantErr {
+			t.Errorf("Check(%q) = %v, want error %v", tt.stop, err, tt.wantErr)
+		}
+	}
+}
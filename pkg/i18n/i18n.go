@@ -0,0 +1,87 @@
+package i18n
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+)
+
+var (
+	// supported are the locales with a catalog; the first is the fallback.
+	supported = []language.Tag{language.English, language.German, language.Spanish, language.French, language.Japanese}
+	matcher   = language.NewMatcher(supported)
+
+	mu      sync.RWMutex
+	current = language.English
+	printer = message.NewPrinter(language.English, message.Catalog(newCatalog()))
+)
+
+// newCatalog builds the catalog from the translation tables. Messages are
+// keyed by their English format string.
+func newCatalog() catalog.Catalog {
+	b := catalog.NewBuilder(catalog.Fallback(language.English))
+	for tag, messages := range translations {
+		for key, msg := range messages {
+			if err := b.SetString(tag, key, msg); err != nil {
+				panic(err)
+			}
+		}
+	}
+	return b
+}
+
+// SetLocale selects the language of UI strings. An empty locale is taken
+// from LC_ALL, LC_MESSAGES or LANG, in that order. Unsupported locales fall
+// back to English.
+func SetLocale(locale string) {
+	if locale == "" {
+		locale = envLocale()
+	}
+	tag, _, _ := matcher.Match(language.Make(normalize(locale)))
+	base, _ := tag.Base()
+	tag = language.Make(base.String())
+
+	mu.Lock()
+	defer mu.Unlock()
+	current = tag
+	printer = message.NewPrinter(tag, message.Catalog(newCatalog()))
+}
+
+// Locale returns the selected language.
+func Locale() language.Tag {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// T formats a UI string in the selected language. format is the English
+// message, which is also used when there is no translation.
+func T(format string, args ...any) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return printer.Sprintf(format, args...)
+}
+
+func envLocale() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// normalize turns a POSIX locale such as "de_DE.UTF-8@euro" into a BCP 47
+// tag. "C" and "POSIX" mean English.
+func normalize(locale string) string {
+	locale, _, _ = strings.Cut(locale, ".")
+	locale, _, _ = strings.Cut(locale, "@")
+	if locale == "C" || locale == "POSIX" {
+		return "en"
+	}
+	return strings.ReplaceAll(locale, "_", "-")
+}
@@ -0,0 +1,71 @@
+package i18n
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+func TestSetLocale(t *testing.T) {
+	defer SetLocale("en")
+
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"de_DE.UTF-8", "Projekt: gemini"},
+		{"fr-CA", "Projet : gemini"},
+		{"pt_BR", "Project: gemini"},
+		{"C", "Project: gemini"},
+	}
+	for _, tt := range tests {
+		SetLocale(tt.locale)
+		if got := T("Project: %s", "gemini"); got != tt.want {
+			t.Errorf("SetLocale(%q): T() = %q, want %q", tt.locale, got, tt.want)
+		}
+	}
+
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+	t.Setenv("LANG", "es_ES.UTF-8")
+	SetLocale("")
+	if Locale() != language.Spanish {
+		t.Errorf("Expected LANG to select Spanish, got %v", Locale())
+	}
+	if got := T("No translation for this"); got != "No translation for this" {
+		t.Errorf("Untranslated strings should be passed through, got %q", got)
+	}
+
+	SetLocale("ja")
+	if got := T("Run %d shell command(s) for /%s? (y/n)", 2, "deploy"); !strings.HasPrefix(got, "/deploy のシェルコマンド 2 件") {
+		t.Errorf("Expected reordered arguments, got %q", got)
+	}
+}
+
+var verbPattern = regexp.MustCompile(`%(?:\[\d+\])?[a-zA-Z%]`)
+
+// verbs returns the formatting verbs of a message, ignoring argument
+// indexes and order.
+func verbs(format string) string {
+	var found []string
+	for _, v := range verbPattern.FindAllString(format, -1) {
+		found = append(found, v[len(v)-1:])
+	}
+	sort.Strings(found)
+	return strings.Join(found, "")
+}
+
+// TestTranslationsKeepVerbs catches translations that drop or change a
+// formatting verb.
+func TestTranslationsKeepVerbs(t *testing.T) {
+	for tag, messages := range translations {
+		for key, msg := range messages {
+			if verbs(key) != verbs(msg) {
+				t.Errorf("%v translation of %q has verbs %q, want %q", tag, key, verbs(msg), verbs(key))
+			}
+		}
+	}
+}
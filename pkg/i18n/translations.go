@@ -0,0 +1,602 @@
+package i18n
+
+import "golang.org/x/text/language"
+
+// translations maps the English UI strings to their translations. Key names
+// (Esc, Tab, Enter, Ctrl+...) and the y/n/m/a answers stay in English because
+// they are what the user types.
+var translations = map[language.Tag]map[string]string{
+	language.German: {
+		// Startup screen and input.
+		"Tips for getting started:":                      "Tipps für den Einstieg:",
+		"1. Ask questions, edit files, or run commands.": "1. Stellen Sie Fragen, bearbeiten Sie Dateien oder führen Sie Befehle aus.",
+		"2. Be specific for the best results.":           "2. Formulieren Sie genau, um die besten Ergebnisse zu erhalten.",
+		"3. /help for more information.":                 "3. /help für weitere Informationen.",
+		"Using: %d GEMINI.md files":                      "Verwendet: %d GEMINI.md-Dateien",
+		"Type your message or @path/to/file":             "Nachricht eingeben oder @pfad/zur/datei",
+
+		// Footer.
+		"Project: %s":                       "Projekt: %s",
+		"Sandbox: %s":                       "Sandbox: %s",
+		"Active":                            "Aktiv",
+		"Inactive":                          "Inaktiv",
+		"Model: %s":                         "Modell: %s",
+		"context: %d%% used (%s/%s tokens)": "Kontext: %d%% belegt (%s/%s Tokens)",
+		" - run /compress":                  " - /compress ausführen",
+
+		// Help.
+		"Available Commands:":                                           "Verfügbare Befehle:",
+		"Custom Commands:":                                              "Eigene Befehle:",
+		"MCP Prompts:":                                                  "MCP-Prompts:",
+		"Show this help message":                                        "Diese Hilfe anzeigen",
+		"Search the conversation (n/N to navigate)":                     "Die Unterhaltung durchsuchen (n/N zum Navigieren)",
+		"Replace the conversation history with a summary":               "Den Verlauf durch eine Zusammenfassung ersetzen",
+		"Show the stderr output of an MCP server":                       "Die stderr-Ausgabe eines MCP-Servers anzeigen",
+		"List the available tools (/tools desc <name> to describe one)": "Verfügbare Tools auflisten (/tools desc <name> beschreibt eines)",
+		"Edit user and workspace settings":                              "Benutzer- und Arbeitsbereichseinstellungen bearbeiten",
+		"List checkpoints (/restore <id> to undo file changes)":         "Checkpoints auflisten (/restore <id> macht Dateiänderungen rückgängig)",
+		"Summarize tool usage and suggest configuration fixes":          "Tool-Nutzung zusammenfassen und Konfigurationskorrekturen vorschlagen",
+		"Show the model or switch to another, keeping the conversation": "Das Modell anzeigen oder zu einem anderen wechseln, ohne die Unterhaltung zu verlieren",
+		"Log the raw API traffic to a file":                             "Den rohen API-Verkehr in eine Datei protokollieren",
+		"Exit the application":                                          "Die Anwendung beenden",
+		"Show or hide pasted text":                                      "Eingefügten Text ein- oder ausblenden",
+		"Select a code block to copy, save or apply":                    "Einen Codeblock zum Kopieren, Speichern oder Anwenden auswählen",
+		"Edit the input in your preferred editor":                       "Die Eingabe im bevorzugten Editor bearbeiten",
+		"Scroll the conversation":                                       "Die Unterhaltung scrollen",
+		"Complete commands, arguments and @paths":                       "Befehle, Argumente und @Pfade vervollständigen",
+		"Add a file to the context":                                     "Eine Datei zum Kontext hinzufügen",
+
+		// Completion.
+		"Show help":                          "Hilfe anzeigen",
+		"Search the conversation":            "Die Unterhaltung durchsuchen",
+		"Replace the history with a summary": "Den Verlauf zusammenfassen",
+		"Inspect MCP servers":                "MCP-Server untersuchen",
+		"List the available tools":           "Verfügbare Tools auflisten",
+		"Edit settings":                      "Einstellungen bearbeiten",
+		"Undo file changes":                  "Dateiänderungen rückgängig machen",
+		"Show tool usage insights":           "Einblicke in die Tool-Nutzung anzeigen",
+		"Switch the model":                   "Das Modell wechseln",
+		"Log the API traffic":                "Den API-Verkehr protokollieren",
+		"Start logging":                      "Protokollierung starten",
+		"Stop logging":                       "Protokollierung beenden",
+
+		// Prompts and status lines.
+		"Match %d of %d for %q (n/N to navigate, Esc to close)":                             "Treffer %d von %d für %q (n/N zum Navigieren, Esc zum Schließen)",
+		"Block [%d] %s: c copy, s save as %s, a apply to workspace, ↑/↓ select, Esc cancel": "Block [%d] %s: c kopieren, s speichern als %s, a im Arbeitsbereich anwenden, ↑/↓ auswählen, Esc abbrechen",
+		"Create %s (%d lines)? (y/n, m to modify)":                                          "%s erstellen (%d Zeilen)? (y/n, m zum Ändern)",
+		"Overwrite %s (%d lines)? (y/n, m to modify)":                                       "%s überschreiben (%d Zeilen)? (y/n, m zum Ändern)",
+		"Run %d shell command(s) for /%s? (y/n)":                                            "%d Shell-Befehl(e) für /%s ausführen? (y/n)",
+		"Cancelled.":                                                                        "Abgebrochen.",
+		"No checkpoints. They are saved before file changes are applied when general.checkpointing.enabled is set.": "Keine Checkpoints. Sie werden vor Dateiänderungen gespeichert, wenn general.checkpointing.enabled gesetzt ist.",
+		"Checkpoints (restore one with /restore <id>):":                                                             "Checkpoints (mit /restore <id> wiederherstellen):",
+		"The files already match checkpoint %s.":                                                                    "Die Dateien entsprechen bereits Checkpoint %s.",
+		"Restore %d file(s) to checkpoint %s? (y/n)":                                                                "%d Datei(en) auf Checkpoint %s zurücksetzen? (y/n)",
+		"Restored checkpoint %s.":                                                                                   "Checkpoint %s wiederhergestellt.",
+		"No tool usage recorded for this project yet.":                                                              "Für dieses Projekt wurde noch keine Tool-Nutzung aufgezeichnet.",
+		"Tool usage since %s (%d calls):":                                                                           "Tool-Nutzung seit %s (%d Aufrufe):",
+		"calls":                                                                                                     "Aufrufe",
+		"failed":                                                                                                    "fehlgeschlagen",
+		"retried":                                                                                                   "wiederholt",
+		"No recurring problems found.":                                                                              "Keine wiederkehrenden Probleme gefunden.",
+		"Suggestions:":                                                                                              "Vorschläge:",
+		"Usage: /debug-api [on|off]":                                                                                "Verwendung: /debug-api [on|off]",
+		"Logging the API traffic to %s.":                                                                            "Der API-Verkehr wird in %s protokolliert.",
+		"Stopped logging the API traffic.":                                                                          "Die Protokollierung des API-Verkehrs wurde beendet.",
+		"Using %s. Switch with /model <name>.":                                                                      "Verwendet %s. Wechseln mit /model <name>.",
+		"Already using %s.":                                                                                         "%s wird bereits verwendet.",
+		"Cannot switch models before the client is initialized.":                                                    "Das Modell kann erst gewechselt werden, wenn der Client initialisiert ist.",
+		"Switching to %s...":                                                                                        "Wechsel zu %s...",
+		"Switched to %s. The conversation did not fit its context window and was summarized.":   "Zu %s gewechselt. Die Unterhaltung passte nicht in sein Kontextfenster und wurde zusammengefasst.",
+		"Switched to %s. %d part(s) of the conversation it cannot take were replaced by notes.": "Zu %s gewechselt. %d Teil(e) der Unterhaltung, die es nicht verarbeiten kann, wurden durch Hinweise ersetzt.",
+		"Switched to %s.": "Zu %s gewechselt.",
+		"Show the token counts of the largest messages": "Die Tokenzahlen der größten Nachrichten anzeigen",
+		"Show or hide the token counts of messages":     "Die Tokenzahlen der Nachrichten ein- oder ausblenden",
+		"Show what uses the context":                    "Zeigen, was den Kontext belegt",
+		"%s tokens":                                     "%s Tokens",
+		"~%s tokens":                                    "~%s Tokens",
+		"No messages in the context yet.":               "Noch keine Nachrichten im Kontext.",
+		"%d message(s), %s tokens.":                     "%d Nachricht(en), %s Tokens.",
+		"The history uses %s of %s tokens.":             "Der Verlauf belegt %s von %s Tokens.",
+		"Largest messages:":                             "Größte Nachrichten:",
+		"You":                                           "Sie",
+		"/compress summarizes the older messages and keeps the most recent ones.": "/compress fasst die älteren Nachrichten zusammen und behält die neuesten.",
+		"Request cancelled. Press Ctrl+C again to quit.":                          "Anfrage abgebrochen. Erneut Ctrl+C drücken zum Beenden.",
+		"Settings (%s scope, Tab to switch)":                                      "Einstellungen (Bereich %s, Tab zum Wechseln)",
+		"user":                                                                    "Benutzer",
+		"workspace":                                                               "Arbeitsbereich",
+		"↑/↓ select, Enter/Space change, Tab switch scope, Esc close": "↑/↓ auswählen, Enter/Leertaste ändern, Tab Bereich wechseln, Esc schließen",
+		"Enter to save, Esc to cancel":                                "Enter zum Speichern, Esc zum Abbrechen",
+		"Update available! %s -> %s. To update, run: %s":              "Update verfügbar! %s -> %s. Zum Aktualisieren ausführen: %s",
+		"Regenerate the last response, or n of them to pick from":     "Die letzte Antwort neu erzeugen, oder n zur Auswahl",
+		"Regenerate the last response":                                "Die letzte Antwort neu erzeugen",
+		"Usage: /retry [number of responses, 1-%d]":                   "Verwendung: /retry [Anzahl der Antworten, 1-%d]",
+		"Cannot retry before the client is initialized.":              "Erneutes Erzeugen ist erst möglich, wenn der Client initialisiert ist.",
+		"Nothing to retry.":                                           "Nichts neu zu erzeugen.",
+		"Regenerating the last response...":                           "Die letzte Antwort wird neu erzeugt...",
+		"Generating %d responses...":                                  "%d Antworten werden erzeugt...",
+		"Response %d of %d:":                                          "Antwort %d von %d:",
+		"(empty response)":                                            "(leere Antwort)",
+		"(calls %s)":                                                  "(ruft %s auf)",
+		"Kept the previous response.":                                 "Die vorherige Antwort wurde beibehalten.",
+		"Continuing with response %d.":                                "Weiter mit Antwort %d.",
+		"Pick a response with 1-%d, or Esc to keep the previous one":  "Antwort mit 1-%d wählen, oder Esc, um die vorherige zu behalten",
+		"The response is incomplete: %v":                              "Die Antwort ist unvollständig: %v",
+		"Continue the incomplete response? (y/n)":                     "Die unvollständige Antwort fortsetzen? (y/n)",
+		"Continue":                  "Fortsetzen",
+		"Plan (%d of %d done)":      "Plan (%d von %d erledigt)",
+		"Spoken responses are off.": "Gesprochene Antworten sind aus.",
+		"Spoken responses are on, in the %s voice. They need a model that can speak, such as %s (see /model).": "Gesprochene Antworten sind an, mit der Stimme %s. Sie brauchen ein Modell, das sprechen kann, etwa %s (siehe /model).",
+		"Speak the responses":                                          "Die Antworten sprechen",
+		"Stop speaking them":                                           "Nicht mehr sprechen",
+		"Received audio (%s) but could not save it: %v":                "Audio (%s) empfangen, aber konnte es nicht speichern: %v",
+		"Audio saved to %s":                                            "Audio gespeichert unter %s",
+		"Could not play the audio: %v":                                 "Konnte das Audio nicht abspielen: %v",
+		"Could not connect to some MCP servers: %v":                    "Konnte keine Verbindung zu einigen MCP-Servern herstellen: %v",
+		"Could not update the semantic index: %v":                      "Konnte den semantischen Index nicht aktualisieren: %v",
+		"Have the responses spoken (experimental)":                     "Die Antworten sprechen lassen (experimentell)",
+		"Ctrl+F to type into it":                                       "Strg+F, um einzugeben",
+		"Ctrl+F to return to the input":                                "Strg+F, um zur Eingabe zurückzukehren",
+		"Running: %s":                                                  "Läuft: %s",
+		"Typing into the command; Ctrl+F returns to the input":         "Eingabe geht an den Befehl; Strg+F kehrt zur Eingabe zurück",
+		"Type into the shell command running in a terminal":            "In den Shell-Befehl tippen, der in einem Terminal läuft",
+		"List the background commands":                                 "Hintergrundbefehle auflisten",
+		"List, inspect or stop the commands running in the background": "Die im Hintergrund laufenden Befehle auflisten, ansehen oder beenden",
+		"No commands run in the background.":                           "Im Hintergrund laufen keine Befehle.",
+		"Background commands:":                                         "Hintergrundbefehle:",
+		"Usage: /ps [output|kill <id>]":                                "Verwendung: /ps [output|kill <id>]",
+		"There is no background command %d.":                           "Es gibt keinen Hintergrundbefehl %d.",
+		"(no output)":                                                  "(keine Ausgabe)",
+		"Background command %d already exited with code %d.":           "Hintergrundbefehl %d wurde bereits mit Code %d beendet.",
+		"Could not stop background command %d: %v":                     "Hintergrundbefehl %d konnte nicht beendet werden: %v",
+		"Stopped background command %d.":                               "Hintergrundbefehl %d beendet.",
+		"running for %s":                                               "läuft seit %s",
+		"exited with code %d":                                          "beendet mit Code %d",
+		"%d: PID %d, %s: %s":                                           "%d: PID %d, %s: %s",
+		"(y/n, a to always allow)":                                     "(y/n, a für immer erlauben)",
+		"Could not save %s to tools.allowed: %v":                       "%s konnte nicht in tools.allowed gespeichert werden: %v",
+		"Added %s to tools.allowed in the user settings.":              "%s wurde in den Benutzereinstellungen zu tools.allowed hinzugefügt.",
+		"Ran %s.":                              "%s ausgeführt.",
+		"Approved by the %s approval mode: %s": "Vom Freigabemodus %s genehmigt: %s",
+	},
+	language.Spanish: {
+		"Tips for getting started:":                      "Consejos para empezar:",
+		"1. Ask questions, edit files, or run commands.": "1. Haz preguntas, edita archivos o ejecuta comandos.",
+		"2. Be specific for the best results.":           "2. Sé específico para obtener los mejores resultados.",
+		"3. /help for more information.":                 "3. /help para más información.",
+		"Using: %d GEMINI.md files":                      "Usando: %d archivos GEMINI.md",
+		"Type your message or @path/to/file":             "Escribe tu mensaje o @ruta/al/archivo",
+
+		"Project: %s":                       "Proyecto: %s",
+		"Sandbox: %s":                       "Sandbox: %s",
+		"Active":                            "Activo",
+		"Inactive":                          "Inactivo",
+		"Model: %s":                         "Modelo: %s",
+		"context: %d%% used (%s/%s tokens)": "contexto: %d%% usado (%s/%s tokens)",
+		" - run /compress":                  " - ejecuta /compress",
+
+		"Available Commands:":                                           "Comandos disponibles:",
+		"Custom Commands:":                                              "Comandos personalizados:",
+		"MCP Prompts:":                                                  "Prompts de MCP:",
+		"Show this help message":                                        "Muestra esta ayuda",
+		"Search the conversation (n/N to navigate)":                     "Busca en la conversación (n/N para navegar)",
+		"Replace the conversation history with a summary":               "Sustituye el historial por un resumen",
+		"Show the stderr output of an MCP server":                       "Muestra la salida stderr de un servidor MCP",
+		"List the available tools (/tools desc <name> to describe one)": "Lista las herramientas disponibles (/tools desc <nombre> describe una)",
+		"Edit user and workspace settings":                              "Edita la configuración de usuario y del espacio de trabajo",
+		"List checkpoints (/restore <id> to undo file changes)":         "Lista los puntos de control (/restore <id> deshace cambios en archivos)",
+		"Summarize tool usage and suggest configuration fixes":          "Resume el uso de herramientas y sugiere correcciones de configuración",
+		"Show the model or switch to another, keeping the conversation": "Muestra el modelo o cambia a otro, conservando la conversación",
+		"Log the raw API traffic to a file":                             "Registra el tráfico de la API sin procesar en un archivo",
+		"Exit the application":                                          "Sale de la aplicación",
+		"Show or hide pasted text":                                      "Muestra u oculta el texto pegado",
+		"Select a code block to copy, save or apply":                    "Selecciona un bloque de código para copiarlo, guardarlo o aplicarlo",
+		"Edit the input in your preferred editor":                       "Edita la entrada en tu editor preferido",
+		"Scroll the conversation":                                       "Desplaza la conversación",
+		"Complete commands, arguments and @paths":                       "Completa comandos, argumentos y @rutas",
+		"Add a file to the context":                                     "Añade un archivo al contexto",
+
+		"Show help":                          "Muestra la ayuda",
+		"Search the conversation":            "Busca en la conversación",
+		"Replace the history with a summary": "Resume el historial",
+		"Inspect MCP servers":                "Inspecciona los servidores MCP",
+		"List the available tools":           "Lista las herramientas disponibles",
+		"Edit settings":                      "Edita la configuración",
+		"Undo file changes":                  "Deshace cambios en archivos",
+		"Show tool usage insights":           "Muestra información sobre el uso de herramientas",
+		"Switch the model":                   "Cambia el modelo",
+		"Log the API traffic":                "Registra el tráfico de la API",
+		"Start logging":                      "Iniciar el registro",
+		"Stop logging":                       "Detener el registro",
+
+		"Match %d of %d for %q (n/N to navigate, Esc to close)":                             "Coincidencia %d de %d para %q (n/N para navegar, Esc para cerrar)",
+		"Block [%d] %s: c copy, s save as %s, a apply to workspace, ↑/↓ select, Esc cancel": "Bloque [%d] %s: c copiar, s guardar como %s, a aplicar al espacio de trabajo, ↑/↓ seleccionar, Esc cancelar",
+		"Create %s (%d lines)? (y/n, m to modify)":                                          "¿Crear %s (%d líneas)? (y/n, m para modificar)",
+		"Overwrite %s (%d lines)? (y/n, m to modify)":                                       "¿Sobrescribir %s (%d líneas)? (y/n, m para modificar)",
+		"Run %d shell command(s) for /%s? (y/n)":                                            "¿Ejecutar %d comando(s) de shell para /%s? (y/n)",
+		"Cancelled.":                                                                        "Cancelado.",
+		"No checkpoints. They are saved before file changes are applied when general.checkpointing.enabled is set.": "No hay puntos de control. Se guardan antes de aplicar cambios en archivos si general.checkpointing.enabled está activado.",
+		"Checkpoints (restore one with /restore <id>):":                                                             "Puntos de control (restaura uno con /restore <id>):",
+		"The files already match checkpoint %s.":                                                                    "Los archivos ya coinciden con el punto de control %s.",
+		"Restore %d file(s) to checkpoint %s? (y/n)":                                                                "¿Restaurar %d archivo(s) al punto de control %s? (y/n)",
+		"Restored checkpoint %s.":                                                                                   "Punto de control %s restaurado.",
+		"No tool usage recorded for this project yet.":                                                              "Aún no se ha registrado uso de herramientas en este proyecto.",
+		"Tool usage since %s (%d calls):":                                                                           "Uso de herramientas desde %s (%d llamadas):",
+		"calls":                                                                                                     "llamadas",
+		"failed":                                                                                                    "fallidas",
+		"retried":                                                                                                   "repetidas",
+		"No recurring problems found.":                                                                              "No se encontraron problemas recurrentes.",
+		"Suggestions:":                                                                                              "Sugerencias:",
+		"Usage: /debug-api [on|off]":                                                                                "Uso: /debug-api [on|off]",
+		"Logging the API traffic to %s.":                                                                            "Registrando el tráfico de la API en %s.",
+		"Stopped logging the API traffic.":                                                                          "Se dejó de registrar el tráfico de la API.",
+		"Using %s. Switch with /model <name>.":                                                                      "Usando %s. Cambia con /model <nombre>.",
+		"Already using %s.":                                                                                         "Ya se está usando %s.",
+		"Cannot switch models before the client is initialized.":                                                    "No se puede cambiar de modelo antes de inicializar el cliente.",
+		"Switching to %s...":                                                                                        "Cambiando a %s...",
+		"Switched to %s. The conversation did not fit its context window and was summarized.":   "Cambiado a %s. La conversación no cabía en su ventana de contexto y se resumió.",
+		"Switched to %s. %d part(s) of the conversation it cannot take were replaced by notes.": "Cambiado a %s. %d parte(s) de la conversación que no admite se reemplazaron por notas.",
+		"Switched to %s.": "Cambiado a %s.",
+		"Show the token counts of the largest messages": "Muestra los tokens de los mensajes más grandes",
+		"Show or hide the token counts of messages":     "Muestra u oculta los tokens de los mensajes",
+		"Show what uses the context":                    "Muestra qué ocupa el contexto",
+		"%s tokens":                                     "%s tokens",
+		"~%s tokens":                                    "~%s tokens",
+		"No messages in the context yet.":               "Todavía no hay mensajes en el contexto.",
+		"%d message(s), %s tokens.":                     "%d mensaje(s), %s tokens.",
+		"The history uses %s of %s tokens.":             "El historial usa %s de %s tokens.",
+		"Largest messages:":                             "Mensajes más grandes:",
+		"You":                                           "Tú",
+		"/compress summarizes the older messages and keeps the most recent ones.": "/compress resume los mensajes antiguos y conserva los más recientes.",
+		"Request cancelled. Press Ctrl+C again to quit.":                          "Solicitud cancelada. Pulsa Ctrl+C de nuevo para salir.",
+		"Settings (%s scope, Tab to switch)":                                      "Configuración (ámbito %s, Tab para cambiar)",
+		"user":                                                                    "usuario",
+		"workspace":                                                               "espacio de trabajo",
+		"↑/↓ select, Enter/Space change, Tab switch scope, Esc close": "↑/↓ seleccionar, Enter/Espacio cambiar, Tab cambiar ámbito, Esc cerrar",
+		"Enter to save, Esc to cancel":                                "Enter para guardar, Esc para cancelar",
+		"Update available! %s -> %s. To update, run: %s":              "¡Actualización disponible! %s -> %s. Para actualizar, ejecuta: %s",
+		"Regenerate the last response, or n of them to pick from":     "Regenera la última respuesta, o n para elegir",
+		"Regenerate the last response":                                "Regenera la última respuesta",
+		"Usage: /retry [number of responses, 1-%d]":                   "Uso: /retry [número de respuestas, 1-%d]",
+		"Cannot retry before the client is initialized.":              "No se puede regenerar antes de que el cliente se inicialice.",
+		"Nothing to retry.":                                           "No hay nada que regenerar.",
+		"Regenerating the last response...":                           "Regenerando la última respuesta...",
+		"Generating %d responses...":                                  "Generando %d respuestas...",
+		"Response %d of %d:":                                          "Respuesta %d de %d:",
+		"(empty response)":                                            "(respuesta vacía)",
+		"(calls %s)":                                                  "(llama a %s)",
+		"Kept the previous response.":                                 "Se mantuvo la respuesta anterior.",
+		"Continuing with response %d.":                                "Continuando con la respuesta %d.",
+		"Pick a response with 1-%d, or Esc to keep the previous one":  "Elige una respuesta con 1-%d, o Esc para mantener la anterior",
+		"The response is incomplete: %v":                              "La respuesta está incompleta: %v",
+		"Continue the incomplete response? (y/n)":                     "¿Continuar la respuesta incompleta? (y/n)",
+		"Continue":                  "Continuar",
+		"Plan (%d of %d done)":      "Plan (%d de %d hechos)",
+		"Spoken responses are off.": "Las respuestas habladas están desactivadas.",
+		"Spoken responses are on, in the %s voice. They need a model that can speak, such as %s (see /model).": "Las respuestas habladas están activadas, con la voz %s. Necesitan un modelo que pueda hablar, como %s (ver /model).",
+		"Speak the responses":                                          "Leer las respuestas en voz alta",
+		"Stop speaking them":                                           "Dejar de leerlas",
+		"Received audio (%s) but could not save it: %v":                "Se recibió audio (%s) pero no se pudo guardar: %v",
+		"Audio saved to %s":                                            "Audio guardado en %s",
+		"Could not play the audio: %v":                                 "No se pudo reproducir el audio: %v",
+		"Could not connect to some MCP servers: %v":                    "No se pudo conectar con algunos servidores MCP: %v",
+		"Could not update the semantic index: %v":                      "No se pudo actualizar el índice semántico: %v",
+		"Have the responses spoken (experimental)":                     "Escuchar las respuestas habladas (experimental)",
+		"Ctrl+F to type into it":                                       "Ctrl+F para escribir en él",
+		"Ctrl+F to return to the input":                                "Ctrl+F para volver a la entrada",
+		"Running: %s":                                                  "Ejecutando: %s",
+		"Typing into the command; Ctrl+F returns to the input":         "Escribiendo en el comando; Ctrl+F vuelve a la entrada",
+		"Type into the shell command running in a terminal":            "Escribir en el comando de shell que se ejecuta en un terminal",
+		"List the background commands":                                 "Listar los comandos en segundo plano",
+		"List, inspect or stop the commands running in the background": "Listar, inspeccionar o detener los comandos que se ejecutan en segundo plano",
+		"No commands run in the background.":                           "No hay comandos en segundo plano.",
+		"Background commands:":                                         "Comandos en segundo plano:",
+		"Usage: /ps [output|kill <id>]":                                "Uso: /ps [output|kill <id>]",
+		"There is no background command %d.":                           "No existe el comando en segundo plano %d.",
+		"(no output)":                                                  "(sin salida)",
+		"Background command %d already exited with code %d.":           "El comando en segundo plano %d ya terminó con el código %d.",
+		"Could not stop background command %d: %v":                     "No se pudo detener el comando en segundo plano %d: %v",
+		"Stopped background command %d.":                               "Se detuvo el comando en segundo plano %d.",
+		"running for %s":                                               "en ejecución desde hace %s",
+		"exited with code %d":                                          "terminó con el código %d",
+		"%d: PID %d, %s: %s":                                           "%d: PID %d, %s: %s",
+		"(y/n, a to always allow)":                                     "(y/n, a para permitir siempre)",
+		"Could not save %s to tools.allowed: %v":                       "No se pudo guardar %s en tools.allowed: %v",
+		"Added %s to tools.allowed in the user settings.":              "Se añadió %s a tools.allowed en la configuración del usuario.",
+		"Ran %s.":                              "Se ejecutó %s.",
+		"Approved by the %s approval mode: %s": "Aprobado por el modo de aprobación %s: %s",
+	},
+	language.French: {
+		"Tips for getting started:":                      "Conseils pour commencer :",
+		"1. Ask questions, edit files, or run commands.": "1. Posez des questions, modifiez des fichiers ou exécutez des commandes.",
+		"2. Be specific for the best results.":           "2. Soyez précis pour obtenir les meilleurs résultats.",
+		"3. /help for more information.":                 "3. /help pour plus d'informations.",
+		"Using: %d GEMINI.md files":                      "Utilise : %d fichiers GEMINI.md",
+		"Type your message or @path/to/file":             "Saisissez votre message ou @chemin/du/fichier",
+
+		"Project: %s":                       "Projet : %s",
+		"Sandbox: %s":                       "Bac à sable : %s",
+		"Active":                            "Actif",
+		"Inactive":                          "Inactif",
+		"Model: %s":                         "Modèle : %s",
+		"context: %d%% used (%s/%s tokens)": "contexte : %d %% utilisé (%s/%s jetons)",
+		" - run /compress":                  " - lancez /compress",
+
+		"Available Commands:":                                           "Commandes disponibles :",
+		"Custom Commands:":                                              "Commandes personnalisées :",
+		"MCP Prompts:":                                                  "Prompts MCP :",
+		"Show this help message":                                        "Affiche cette aide",
+		"Search the conversation (n/N to navigate)":                     "Recherche dans la conversation (n/N pour naviguer)",
+		"Replace the conversation history with a summary":               "Remplace l'historique par un résumé",
+		"Show the stderr output of an MCP server":                       "Affiche la sortie stderr d'un serveur MCP",
+		"List the available tools (/tools desc <name> to describe one)": "Liste les outils disponibles (/tools desc <nom> en décrit un)",
+		"Edit user and workspace settings":                              "Modifie les paramètres utilisateur et de l'espace de travail",
+		"List checkpoints (/restore <id> to undo file changes)":         "Liste les points de contrôle (/restore <id> annule des modifications de fichiers)",
+		"Summarize tool usage and suggest configuration fixes":          "Résume l'utilisation des outils et suggère des corrections de configuration",
+		"Show the model or switch to another, keeping the conversation": "Affiche le modèle ou passe à un autre, en conservant la conversation",
+		"Log the raw API traffic to a file":                             "Journalise le trafic brut de l'API dans un fichier",
+		"Exit the application":                                          "Quitte l'application",
+		"Show or hide pasted text":                                      "Affiche ou masque le texte collé",
+		"Select a code block to copy, save or apply":                    "Sélectionne un bloc de code à copier, enregistrer ou appliquer",
+		"Edit the input in your preferred editor":                       "Modifie la saisie dans votre éditeur préféré",
+		"Scroll the conversation":                                       "Fait défiler la conversation",
+		"Complete commands, arguments and @paths":                       "Complète les commandes, arguments et @chemins",
+		"Add a file to the context":                                     "Ajoute un fichier au contexte",
+
+		"Show help":                          "Affiche l'aide",
+		"Search the conversation":            "Recherche dans la conversation",
+		"Replace the history with a summary": "Résume l'historique",
+		"Inspect MCP servers":                "Inspecte les serveurs MCP",
+		"List the available tools":           "Liste les outils disponibles",
+		"Edit settings":                      "Modifie les paramètres",
+		"Undo file changes":                  "Annule des modifications de fichiers",
+		"Show tool usage insights":           "Affiche un aperçu de l'utilisation des outils",
+		"Switch the model":                   "Change de modèle",
+		"Log the API traffic":                "Journalise le trafic de l'API",
+		"Start logging":                      "Démarrer la journalisation",
+		"Stop logging":                       "Arrêter la journalisation",
+
+		"Match %d of %d for %q (n/N to navigate, Esc to close)":                             "Résultat %d sur %d pour %q (n/N pour naviguer, Esc pour fermer)",
+		"Block [%d] %s: c copy, s save as %s, a apply to workspace, ↑/↓ select, Esc cancel": "Bloc [%d] %s : c copier, s enregistrer sous %s, a appliquer à l'espace de travail, ↑/↓ sélectionner, Esc annuler",
+		"Create %s (%d lines)? (y/n, m to modify)":                                          "Créer %s (%d lignes) ? (y/n, m pour modifier)",
+		"Overwrite %s (%d lines)? (y/n, m to modify)":                                       "Écraser %s (%d lignes) ? (y/n, m pour modifier)",
+		"Run %d shell command(s) for /%s? (y/n)":                                            "Exécuter %d commande(s) shell pour /%s ? (y/n)",
+		"Cancelled.":                                                                        "Annulé.",
+		"No checkpoints. They are saved before file changes are applied when general.checkpointing.enabled is set.": "Aucun point de contrôle. Ils sont enregistrés avant l'application de modifications si general.checkpointing.enabled est activé.",
+		"Checkpoints (restore one with /restore <id>):":                                                             "Points de contrôle (restaurez-en un avec /restore <id>) :",
+		"The files already match checkpoint %s.":                                                                    "Les fichiers correspondent déjà au point de contrôle %s.",
+		"Restore %d file(s) to checkpoint %s? (y/n)":                                                                "Restaurer %d fichier(s) au point de contrôle %s ? (y/n)",
+		"Restored checkpoint %s.":                                                                                   "Point de contrôle %s restauré.",
+		"No tool usage recorded for this project yet.":                                                              "Aucune utilisation d'outil enregistrée pour ce projet.",
+		"Tool usage since %s (%d calls):":                                                                           "Utilisation des outils depuis le %s (%d appels) :",
+		"calls":                                                                                                     "appels",
+		"failed":                                                                                                    "échecs",
+		"retried":                                                                                                   "répétés",
+		"No recurring problems found.":                                                                              "Aucun problème récurrent trouvé.",
+		"Suggestions:":                                                                                              "Suggestions :",
+		"Usage: /debug-api [on|off]":                                                                                "Utilisation : /debug-api [on|off]",
+		"Logging the API traffic to %s.":                                                                            "Le trafic de l'API est journalisé dans %s.",
+		"Stopped logging the API traffic.":                                                                          "La journalisation du trafic de l'API est arrêtée.",
+		"Using %s. Switch with /model <name>.":                                                                      "Utilise %s. Changez avec /model <nom>.",
+		"Already using %s.":                                                                                         "%s est déjà utilisé.",
+		"Cannot switch models before the client is initialized.":                                                    "Impossible de changer de modèle avant l'initialisation du client.",
+		"Switching to %s...":                                                                                        "Passage à %s...",
+		"Switched to %s. The conversation did not fit its context window and was summarized.":   "Passé à %s. La conversation ne tenait pas dans sa fenêtre de contexte et a été résumée.",
+		"Switched to %s. %d part(s) of the conversation it cannot take were replaced by notes.": "Passé à %s. %d partie(s) de la conversation qu'il ne prend pas en charge ont été remplacées par des notes.",
+		"Switched to %s.": "Passé à %s.",
+		"Show the token counts of the largest messages": "Affiche le nombre de tokens des plus gros messages",
+		"Show or hide the token counts of messages":     "Affiche ou masque le nombre de tokens des messages",
+		"Show what uses the context":                    "Montre ce qui occupe le contexte",
+		"%s tokens":                                     "%s tokens",
+		"~%s tokens":                                    "~%s tokens",
+		"No messages in the context yet.":               "Aucun message dans le contexte pour l'instant.",
+		"%d message(s), %s tokens.":                     "%d message(s), %s tokens.",
+		"The history uses %s of %s tokens.":             "L'historique utilise %s tokens sur %s.",
+		"Largest messages:":                             "Plus gros messages :",
+		"You":                                           "Vous",
+		"/compress summarizes the older messages and keeps the most recent ones.": "/compress résume les messages les plus anciens et garde les plus récents.",
+		"Request cancelled. Press Ctrl+C again to quit.":                          "Requête annulée. Appuyez à nouveau sur Ctrl+C pour quitter.",
+		"Settings (%s scope, Tab to switch)":                                      "Paramètres (portée %s, Tab pour changer)",
+		"user":                                                                    "utilisateur",
+		"workspace":                                                               "espace de travail",
+		"↑/↓ select, Enter/Space change, Tab switch scope, Esc close": "↑/↓ sélectionner, Entrée/Espace modifier, Tab changer de portée, Esc fermer",
+		"Enter to save, Esc to cancel":                                "Entrée pour enregistrer, Esc pour annuler",
+		"Update available! %s -> %s. To update, run: %s":              "Mise à jour disponible ! %s -> %s. Pour mettre à jour, lancez : %s",
+		"Regenerate the last response, or n of them to pick from":     "Régénère la dernière réponse, ou n au choix",
+		"Regenerate the last response":                                "Régénère la dernière réponse",
+		"Usage: /retry [number of responses, 1-%d]":                   "Utilisation : /retry [nombre de réponses, 1-%d]",
+		"Cannot retry before the client is initialized.":              "Impossible de régénérer avant l'initialisation du client.",
+		"Nothing to retry.":                                           "Rien à régénérer.",
+		"Regenerating the last response...":                           "Régénération de la dernière réponse...",
+		"Generating %d responses...":                                  "Génération de %d réponses...",
+		"Response %d of %d:":                                          "Réponse %d sur %d :",
+		"(empty response)":                                            "(réponse vide)",
+		"(calls %s)":                                                  "(appelle %s)",
+		"Kept the previous response.":                                 "Réponse précédente conservée.",
+		"Continuing with response %d.":                                "Suite avec la réponse %d.",
+		"Pick a response with 1-%d, or Esc to keep the previous one":  "Choisissez une réponse avec 1-%d, ou Esc pour garder la précédente",
+		"The response is incomplete: %v":                              "La réponse est incomplète : %v",
+		"Continue the incomplete response? (y/n)":                     "Poursuivre la réponse incomplète ? (y/n)",
+		"Continue":                  "Continuer",
+		"Plan (%d of %d done)":      "Plan (%d sur %d terminées)",
+		"Spoken responses are off.": "Les réponses parlées sont désactivées.",
+		"Spoken responses are on, in the %s voice. They need a model that can speak, such as %s (see /model).": "Les réponses parlées sont activées, avec la voix %s. Elles nécessitent un modèle capable de parler, comme %s (voir /model).",
+		"Speak the responses":                                          "Lire les réponses à voix haute",
+		"Stop speaking them":                                           "Ne plus les lire",
+		"Received audio (%s) but could not save it: %v":                "Audio reçu (%s) mais impossible de l'enregistrer : %v",
+		"Audio saved to %s":                                            "Audio enregistré dans %s",
+		"Could not play the audio: %v":                                 "Impossible de lire l'audio : %v",
+		"Could not connect to some MCP servers: %v":                    "Impossible de se connecter à certains serveurs MCP : %v",
+		"Could not update the semantic index: %v":                      "Impossible de mettre à jour l'index sémantique : %v",
+		"Have the responses spoken (experimental)":                     "Faire lire les réponses à voix haute (expérimental)",
+		"Ctrl+F to type into it":                                       "Ctrl+F pour y saisir",
+		"Ctrl+F to return to the input":                                "Ctrl+F pour revenir à la saisie",
+		"Running: %s":                                                  "En cours : %s",
+		"Typing into the command; Ctrl+F returns to the input":         "Saisie dans la commande ; Ctrl+F revient à la saisie",
+		"Type into the shell command running in a terminal":            "Saisir dans la commande shell exécutée dans un terminal",
+		"List the background commands":                                 "Lister les commandes en arrière-plan",
+		"List, inspect or stop the commands running in the background": "Lister, inspecter ou arrêter les commandes exécutées en arrière-plan",
+		"No commands run in the background.":                           "Aucune commande ne s'exécute en arrière-plan.",
+		"Background commands:":                                         "Commandes en arrière-plan :",
+		"Usage: /ps [output|kill <id>]":                                "Utilisation : /ps [output|kill <id>]",
+		"There is no background command %d.":                           "Il n'y a pas de commande en arrière-plan %d.",
+		"(no output)":                                                  "(aucune sortie)",
+		"Background command %d already exited with code %d.":           "La commande en arrière-plan %d s'est déjà terminée avec le code %d.",
+		"Could not stop background command %d: %v":                     "Impossible d'arrêter la commande en arrière-plan %d : %v",
+		"Stopped background command %d.":                               "Commande en arrière-plan %d arrêtée.",
+		"running for %s":                                               "en cours depuis %s",
+		"exited with code %d":                                          "terminée avec le code %d",
+		"%d: PID %d, %s: %s":                                           "%d : PID %d, %s : %s",
+		"(y/n, a to always allow)":                                     "(y/n, a pour toujours autoriser)",
+		"Could not save %s to tools.allowed: %v":                       "Impossible d'enregistrer %s dans tools.allowed : %v",
+		"Added %s to tools.allowed in the user settings.":              "%s a été ajouté à tools.allowed dans les paramètres utilisateur.",
+		"Ran %s.":                              "%s exécuté.",
+		"Approved by the %s approval mode: %s": "Approuvé par le mode d'approbation %s : %s",
+	},
+	language.Japanese: {
+		"Tips for getting started:":                      "はじめに:",
+		"1. Ask questions, edit files, or run commands.": "1. 質問、ファイルの編集、コマンドの実行ができます。",
+		"2. Be specific for the best results.":           "2. 具体的に指示すると、よりよい結果が得られます。",
+		"3. /help for more information.":                 "3. 詳しくは /help を参照してください。",
+		"Using: %d GEMINI.md files":                      "使用中: GEMINI.md ファイル %d 件",
+		"Type your message or @path/to/file":             "メッセージまたは @path/to/file を入力",
+
+		"Project: %s":                       "プロジェクト: %s",
+		"Sandbox: %s":                       "サンドボックス: %s",
+		"Active":                            "有効",
+		"Inactive":                          "無効",
+		"Model: %s":                         "モデル: %s",
+		"context: %d%% used (%s/%s tokens)": "コンテキスト: %d%% 使用 (%s/%s トークン)",
+		" - run /compress":                  " - /compress を実行してください",
+
+		"Available Commands:":                                           "利用可能なコマンド:",
+		"Custom Commands:":                                              "カスタムコマンド:",
+		"MCP Prompts:":                                                  "MCP プロンプト:",
+		"Show this help message":                                        "このヘルプを表示",
+		"Search the conversation (n/N to navigate)":                     "会話を検索 (n/N で移動)",
+		"Replace the conversation history with a summary":               "会話履歴を要約に置き換える",
+		"Show the stderr output of an MCP server":                       "MCP サーバーの stderr 出力を表示",
+		"List the available tools (/tools desc <name> to describe one)": "利用可能なツールを一覧表示 (/tools desc <name> で説明を表示)",
+		"Edit user and workspace settings":                              "ユーザーとワークスペースの設定を編集",
+		"List checkpoints (/restore <id> to undo file changes)":         "チェックポイントを一覧表示 (/restore <id> でファイルの変更を元に戻す)",
+		"Summarize tool usage and suggest configuration fixes":          "ツールの使用状況を要約し、設定の修正を提案",
+		"Show the model or switch to another, keeping the conversation": "モデルを表示、または会話を保ったまま別のモデルに切り替え",
+		"Log the raw API traffic to a file":                             "API の生の通信をファイルに記録",
+		"Exit the application":                                          "アプリケーションを終了",
+		"Show or hide pasted text":                                      "貼り付けたテキストの表示を切り替え",
+		"Select a code block to copy, save or apply":                    "コードブロックを選択してコピー、保存、適用",
+		"Edit the input in your preferred editor":                       "入力を好みのエディタで編集",
+		"Scroll the conversation":                                       "会話をスクロール",
+		"Complete commands, arguments and @paths":                       "コマンド、引数、@パスを補完",
+		"Add a file to the context":                                     "ファイルをコンテキストに追加",
+
+		"Show help":                          "ヘルプを表示",
+		"Search the conversation":            "会話を検索",
+		"Replace the history with a summary": "履歴を要約に置き換える",
+		"Inspect MCP servers":                "MCP サーバーを調べる",
+		"List the available tools":           "利用可能なツールを一覧表示",
+		"Edit settings":                      "設定を編集",
+		"Undo file changes":                  "ファイルの変更を元に戻す",
+		"Show tool usage insights":           "ツール使用状況の分析を表示",
+		"Switch the model":                   "モデルを切り替え",
+		"Log the API traffic":                "API の通信を記録",
+		"Start logging":                      "記録を開始",
+		"Stop logging":                       "記録を停止",
+
+		"Match %d of %d for %q (n/N to navigate, Esc to close)":                             "%[3]q の一致 %[1]d / %[2]d 件 (n/N で移動、Esc で閉じる)",
+		"Block [%d] %s: c copy, s save as %s, a apply to workspace, ↑/↓ select, Esc cancel": "ブロック [%d] %s: c コピー、s %s として保存、a ワークスペースに適用、↑/↓ 選択、Esc キャンセル",
+		"Create %s (%d lines)? (y/n, m to modify)":                                          "%s を作成しますか (%d 行)? (y/n、m で修正)",
+		"Overwrite %s (%d lines)? (y/n, m to modify)":                                       "%s を上書きしますか (%d 行)? (y/n、m で修正)",
+		"Run %d shell command(s) for /%s? (y/n)":                                            "/%[2]s のシェルコマンド %[1]d 件を実行しますか? (y/n)",
+		"Cancelled.":                                                                        "キャンセルしました。",
+		"No checkpoints. They are saved before file changes are applied when general.checkpointing.enabled is set.": "チェックポイントはありません。general.checkpointing.enabled が設定されていると、ファイルの変更前に保存されます。",
+		"Checkpoints (restore one with /restore <id>):":                                                             "チェックポイント (/restore <id> で復元):",
+		"The files already match checkpoint %s.":                                                                    "ファイルはすでにチェックポイント %s と一致しています。",
+		"Restore %d file(s) to checkpoint %s? (y/n)":                                                                "%[1]d 個のファイルをチェックポイント %[2]s に復元しますか? (y/n)",
+		"Restored checkpoint %s.":                                                                                   "チェックポイント %s を復元しました。",
+		"No tool usage recorded for this project yet.":                                                              "このプロジェクトにはまだツールの使用記録がありません。",
+		"Tool usage since %s (%d calls):":                                                                           "%s 以降のツール使用状況 (%d 回の呼び出し):",
+		"calls":                                                                                                     "回",
+		"failed":                                                                                                    "回失敗",
+		"retried":                                                                                                   "回再試行",
+		"No recurring problems found.":                                                                              "繰り返し発生している問題は見つかりませんでした。",
+		"Suggestions:":                                                                                              "提案:",
+		"Usage: /debug-api [on|off]":                                                                                "使い方: /debug-api [on|off]",
+		"Logging the API traffic to %s.":                                                                            "API の通信を %s に記録しています。",
+		"Stopped logging the API traffic.":                                                                          "API の通信の記録を停止しました。",
+		"Using %s. Switch with /model <name>.":                                                                      "%s を使用中です。/model <名前> で切り替えます。",
+		"Already using %s.":                                                                                         "すでに %s を使用しています。",
+		"Cannot switch models before the client is initialized.":                                                    "クライアントの初期化前にモデルを切り替えることはできません。",
+		"Switching to %s...":                                                                                        "%s に切り替えています...",
+		"Switched to %s. The conversation did not fit its context window and was summarized.":   "%s に切り替えました。会話がコンテキストウィンドウに収まらなかったため要約しました。",
+		"Switched to %s. %d part(s) of the conversation it cannot take were replaced by notes.": "%s に切り替えました。対応していない会話の %d 個の部分をメモに置き換えました。",
+		"Switched to %s.": "%s に切り替えました。",
+		"Show the token counts of the largest messages": "最も大きいメッセージのトークン数を表示",
+		"Show or hide the token counts of messages":     "メッセージのトークン数を表示/非表示",
+		"Show what uses the context":                    "コンテキストの使用内訳を表示",
+		"%s tokens":                                     "%s トークン",
+		"~%s tokens":                                    "約 %s トークン",
+		"No messages in the context yet.":               "コンテキストにはまだメッセージがありません。",
+		"%d message(s), %s tokens.":                     "%d 件のメッセージ、%s トークン。",
+		"The history uses %s of %s tokens.":             "履歴は %s / %s トークンを使用しています。",
+		"Largest messages:":                             "最も大きいメッセージ:",
+		"You":                                           "あなた",
+		"/compress summarizes the older messages and keeps the most recent ones.": "/compress は古いメッセージを要約し、最新のメッセージを残します。",
+		"Request cancelled. Press Ctrl+C again to quit.":                          "リクエストをキャンセルしました。終了するにはもう一度 Ctrl+C を押してください。",
+		"Settings (%s scope, Tab to switch)":                                      "設定 (スコープ: %s、Tab で切り替え)",
+		"user":                                                                    "ユーザー",
+		"workspace":                                                               "ワークスペース",
+		"↑/↓ select, Enter/Space change, Tab switch scope, Esc close": "↑/↓ 選択、Enter/Space 変更、Tab スコープ切り替え、Esc 閉じる",
+		"Enter to save, Esc to cancel":                                "Enter で保存、Esc でキャンセル",
+		"Update available! %s -> %s. To update, run: %s":              "アップデートがあります! %s -> %s。更新するには次を実行してください: %s",
+		"Regenerate the last response, or n of them to pick from":     "最後の応答を再生成 (n 個から選択も可能)",
+		"Regenerate the last response":                                "最後の応答を再生成",
+		"Usage: /retry [number of responses, 1-%d]":                   "使い方: /retry [応答の数, 1-%d]",
+		"Cannot retry before the client is initialized.":              "クライアントの初期化前には再生成できません。",
+		"Nothing to retry.":                                           "再生成するものがありません。",
+		"Regenerating the last response...":                           "最後の応答を再生成しています...",
+		"Generating %d responses...":                                  "%d 個の応答を生成しています...",
+		"Response %d of %d:":                                          "応答 %d / %d:",
+		"(empty response)":                                            "(空の応答)",
+		"(calls %s)":                                                  "(%s を呼び出し)",
+		"Kept the previous response.":                                 "以前の応答を維持しました。",
+		"Continuing with response %d.":                                "応答 %d で続行します。",
+		"Pick a response with 1-%d, or Esc to keep the previous one":  "1-%d で応答を選択、Esc で以前の応答を維持",
+		"The response is incomplete: %v":                              "応答が不完全です: %v",
+		"Continue the incomplete response? (y/n)":                     "不完全な応答を続けますか? (y/n)",
+		"Continue":                  "続ける",
+		"Plan (%d of %d done)":      "計画 (%d / %d 完了)",
+		"Spoken responses are off.": "音声応答はオフです。",
+		"Spoken responses are on, in the %s voice. They need a model that can speak, such as %s (see /model).": "音声応答はオンです（音声: %s）。%s など音声を出力できるモデルが必要です（/model を参照）。",
+		"Speak the responses":                                          "応答を読み上げる",
+		"Stop speaking them":                                           "読み上げを止める",
+		"Received audio (%s) but could not save it: %v":                "音声 (%s) を受信しましたが保存できませんでした: %v",
+		"Audio saved to %s":                                            "音声を %s に保存しました",
+		"Could not play the audio: %v":                                 "音声を再生できませんでした: %v",
+		"Could not connect to some MCP servers: %v":                    "一部の MCP サーバーに接続できませんでした: %v",
+		"Could not update the semantic index: %v":                      "セマンティックインデックスを更新できませんでした: %v",
+		"Have the responses spoken (experimental)":                     "応答を音声で聞く (実験的)",
+		"Ctrl+F to type into it":                                       "Ctrl+F で入力",
+		"Ctrl+F to return to the input":                                "Ctrl+F で入力欄に戻る",
+		"Running: %s":                                                  "実行中: %s",
+		"Typing into the command; Ctrl+F returns to the input":         "コマンドに入力中。Ctrl+F で入力欄に戻ります",
+		"Type into the shell command running in a terminal":            "端末で実行中のシェルコマンドに入力",
+		"List the background commands":                                 "バックグラウンドのコマンドを一覧表示",
+		"List, inspect or stop the commands running in the background": "バックグラウンドで実行中のコマンドを一覧表示・確認・停止",
+		"No commands run in the background.":                           "バックグラウンドで実行中のコマンドはありません。",
+		"Background commands:":                                         "バックグラウンドのコマンド:",
+		"Usage: /ps [output|kill <id>]":                                "使い方: /ps [output|kill <id>]",
+		"There is no background command %d.":                           "バックグラウンドのコマンド %d はありません。",
+		"(no output)":                                                  "(出力なし)",
+		"Background command %d already exited with code %d.":           "バックグラウンドのコマンド %d はコード %d で既に終了しています。",
+		"Could not stop background command %d: %v":                     "バックグラウンドのコマンド %d を停止できませんでした: %v",
+		"Stopped background command %d.":                               "バックグラウンドのコマンド %d を停止しました。",
+		"running for %s":                                               "%s 実行中",
+		"exited with code %d":                                          "コード %d で終了",
+		"%d: PID %d, %s: %s":                                           "%d: PID %d、%s: %s",
+		"(y/n, a to always allow)":                                     "(y/n、a で常に許可)",
+		"Could not save %s to tools.allowed: %v":                       "%s を tools.allowed に保存できませんでした: %v",
+		"Added %s to tools.allowed in the user settings.":              "ユーザー設定の tools.allowed に %s を追加しました。",
+		"Ran %s.":                              "%s を実行しました。",
+		"Approved by the %s approval mode: %s": "承認モード %s により承認されました: %s",
+	},
+}
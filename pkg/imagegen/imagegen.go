@@ -0,0 +1,187 @@
+// Package imagegen generates images from a prompt with the Imagen models
+// of the Gemini API, which the client library does not support.
+package imagegen
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// DefaultModel is the image generation model used unless another is given.
+const DefaultModel = "imagen-3.0-generate-002"
+
+// DefaultEndpoint is the base URL of the Gemini API.
+const DefaultEndpoint = "https://generativelanguage.googleapis.com"
+
+// MaxCount is the most images a request may generate.
+const MaxCount = 4
+
+// AspectRatios are the shapes of image the models generate.
+var AspectRatios = []string{"1:1", "3:4", "4:3", "9:16", "16:9"}
+
+// Sizes are the resolutions of image the models generate, on their longer
+// side. Only some models accept a size.
+var Sizes = []string{"1K", "2K"}
+
+// Request is what to generate. Unset fields take the model's defaults.
+type Request struct {
+	Prompt      string
+	Count       int
+	AspectRatio string
+	Size        string
+}
+
+// Check reports whether the model accepts r.
+func (r Request) Check() error {
+	if strings.TrimSpace(r.Prompt) == "" {
+		return fmt.Errorf("the prompt must not be empty")
+	}
+	if r.Count < 0 || r.Count > MaxCount {
+		return fmt.Errorf("the number of images must be between 1 and %d, not %d", MaxCount, r.Count)
+	}
+	if r.AspectRatio != "" && !slices.Contains(AspectRatios, r.AspectRatio) {
+		return fmt.Errorf("unsupported aspect ratio %q, use one of %s", r.AspectRatio, strings.Join(AspectRatios, ", "))
+	}
+	if r.Size != "" && !slices.Contains(Sizes, r.Size) {
+		return fmt.Errorf("unsupported size %q, use one of %s", r.Size, strings.Join(Sizes, ", "))
+	}
+	return nil
+}
+
+// Client sends requests to an image generation model.
+type Client struct {
+	// APIKey authenticates the requests.
+	APIKey string
+	// Model is the model to use, DefaultModel if empty.
+	Model string
+	// Endpoint is the base URL of the API, DefaultEndpoint if empty.
+	Endpoint string
+	// HTTPClient sends the requests, http.DefaultClient if nil.
+	HTTPClient *http.Client
+}
+
+type predictRequest struct {
+	Instances  []instance `json:"instances"`
+	Parameters parameters `json:"parameters"`
+}
+
+type instance struct {
+	Prompt string `json:"prompt"`
+}
+
+type parameters struct {
+	SampleCount     int    `json:"sampleCount,omitempty"`
+	AspectRatio     string `json:"aspectRatio,omitempty"`
+	SampleImageSize string `json:"sampleImageSize,omitempty"`
+}
+
+type predictResponse struct {
+	Predictions []struct {
+		BytesBase64Encoded string `json:"bytesBase64Encoded"`
+		MIMEType           string `json:"mimeType"`
+		// RAIFilteredReason explains why an image was withheld.
+		RAIFilteredReason string `json:"raiFilteredReason"`
+	} `json:"predictions"`
+}
+
+// Generate generates the images r asks for. Images withheld by the
+// model's safety filters are left out; if all are, the error says why.
+func (c *Client) Generate(ctx context.Context, r Request) ([]genai.Blob, error) {
+	if err := r.Check(); err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(predictRequest{
+		Instances:  []instance{{Prompt: r.Prompt}},
+		Parameters: parameters{SampleCount: r.Count, AspectRatio: r.AspectRatio, SampleImageSize: r.Size},
+	})
+	if err != nil {
+		return nil, err
+	}
+	model, endpoint, client := c.Model, c.Endpoint, c.HTTPClient
+	if model == "" {
+		model = DefaultModel
+	}
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf("%s/v1beta/models/%s:predict", strings.TrimSuffix(endpoint, "/"), strings.TrimPrefix(model, "models/")),
+		bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", c.APIKey)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("image generation failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("image generation failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("image generation failed: %s: %s", resp.Status, apiError(data))
+	}
+
+	var pr predictResponse
+	if err := json.Unmarshal(data, &pr); err != nil {
+		return nil, fmt.Errorf("image generation failed: malformed response: %w", err)
+	}
+	var (
+		images   []genai.Blob
+		filtered []string
+	)
+	for _, p := range pr.Predictions {
+		if p.BytesBase64Encoded == "" {
+			if p.RAIFilteredReason != "" {
+				filtered = append(filtered, p.RAIFilteredReason)
+			}
+			continue
+		}
+		img, err := base64.StdEncoding.DecodeString(p.BytesBase64Encoded)
+		if err != nil {
+			return nil, fmt.Errorf("image generation failed: malformed image: %w", err)
+		}
+		mimeType := p.MIMEType
+		if mimeType == "" {
+			mimeType = http.DetectContentType(img)
+		}
+		images = append(images, genai.Blob{MIMEType: mimeType, Data: img})
+	}
+	if len(images) == 0 {
+		if len(filtered) > 0 {
+			return nil, fmt.Errorf("the model withheld the images: %s", strings.Join(filtered, "; "))
+		}
+		return nil, fmt.Errorf("the model returned no images; try rephrasing the prompt")
+	}
+	return images, nil
+}
+
+// apiError returns the message of the error body of a failed request, or
+// the body itself if it has none.
+func apiError(body []byte) string {
+	var e struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &e) == nil && e.Error.Message != "" {
+		return e.Error.Message
+	}
+	return strings.TrimSpace(string(body))
+}
@@ -0,0 +1,103 @@
+package imagegen
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	var (
+		path, key string
+		request   map[string]any
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, key = r.URL.Path, r.Header.Get("x-goog-api-key")
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &request)
+		fmt.Fprintln(w, `{"predictions":[
+			{"bytesBase64Encoded":"aW1hZ2UgMQ==","mimeType":"image/png"},
+			{"raiFilteredReason":"filtered"},
+			{"bytesBase64Encoded":"aW1hZ2UgMg=="}]}`)
+	}))
+	defer server.Close()
+
+	c := &Client{APIKey: "key", Endpoint: server.URL}
+	images, err := c.Generate(context.Background(), Request{Prompt: "a cat", Count: 3, AspectRatio: "16:9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "/v1beta/models/"+DefaultModel+":predict" || key != "key" {
+		t.Errorf("request sent to %s with key %q", path, key)
+	}
+	want := map[string]any{
+		"instances":  []any{map[string]any{"prompt": "a cat"}},
+		"parameters": map[string]any{"sampleCount": 3.0, "aspectRatio": "16:9"},
+	}
+	if !reflect.DeepEqual(request, want) {
+		t.Errorf("request = %v, want %v", request, want)
+	}
+	if len(images) != 2 {
+		t.Fatalf("got %d images, want the 2 not filtered", len(images))
+	}
+	if string(images[0].Data) != "image 1" || images[0].MIMEType != "image/png" {
+		t.Errorf("images[0] = %q (%s)", images[0].Data, images[0].MIMEType)
+	}
+	// The type of an image without one is detected from its content.
+	if images[1].MIMEType != "text/plain; charset=utf-8" {
+		t.Errorf("images[1] has type %s", images[1].MIMEType)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{name: "API error", status: http.StatusBadRequest, body: `{"error":{"message":"Imagen is not available"}}`, want: "400 Bad Request: Imagen is not available"},
+		{name: "all filtered", status: http.StatusOK, body: `{"predictions":[{"raiFilteredReason":"unsafe prompt"}]}`, want: "withheld the images: unsafe prompt"},
+		{name: "no images", status: http.StatusOK, body: `{}`, want: "returned no images"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprintln(w, tt.body)
+			}))
+			defer server.Close()
+
+			c := &Client{APIKey: "key", Endpoint: server.URL}
+			_, err := c.Generate(context.Background(), Request{Prompt: "a cat"})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		req     Request
+		wantErr bool
+	}{
+		{req: Request{Prompt: "a cat"}},
+		{req: Request{Prompt: "a cat", Count: 4, AspectRatio: "9:16", Size: "2K"}},
+		{req: Request{Prompt: " "}, wantErr: true},
+		{req: Request{Prompt: "a cat", Count: 5}, wantErr: true},
+		{req: Request{Prompt: "a cat", AspectRatio: "2:1"}, wantErr: true},
+		{req: Request{Prompt: "a cat", Size: "4K"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := tt.req.Check(); (err != nil) != tt.wantErr {
+			t.Errorf("Check(%+v) = %v, want error %v", tt.req, err, tt.wantErr)
+		}
+	}
+}
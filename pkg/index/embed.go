@@ -0,0 +1,108 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/auth"
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+)
+
+// DefaultEmbeddingModel is used unless tools.index.embeddingModel is set.
+const DefaultEmbeddingModel = "text-embedding-004"
+
+// Embedder computes embedding vectors.
+type Embedder interface {
+	// Model names the model, so that vectors of different models are not
+	// compared.
+	Model() string
+	EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error)
+	EmbedQuery(ctx context.Context, text string) ([]float32, error)
+}
+
+// GeminiEmbedder computes embeddings with the Gemini API. It authenticates
+// on first use, with the configured authentication type.
+type GeminiEmbedder struct {
+	settings *config.Settings
+	model    string
+
+	mu     sync.Mutex
+	client *genai.Client
+}
+
+// NewGeminiEmbedder returns an embedder using the model configured in
+// settings.
+func NewGeminiEmbedder(settings *config.Settings) *GeminiEmbedder {
+	model := DefaultEmbeddingModel
+	if settings != nil && settings.Tools != nil && settings.Tools.Index != nil && settings.Tools.Index.EmbeddingModel != "" {
+		model = settings.Tools.Index.EmbeddingModel
+	}
+	return &GeminiEmbedder{settings: settings, model: model}
+}
+
+func (g *GeminiEmbedder) Model() string { return g.model }
+
+func (g *GeminiEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	em, err := g.embeddingModel(ctx, genai.TaskTypeRetrievalDocument)
+	if err != nil {
+		return nil, err
+	}
+	b := em.NewBatch()
+	for _, t := range texts {
+		b.AddContent(genai.Text(t))
+	}
+	resp, err := em.BatchEmbedContents(ctx, b)
+	if err != nil {
+		return nil, fmt.Errorf("embedding failed: %w", err)
+	}
+	vectors := make([][]float32, len(resp.Embeddings))
+	for i, e := range resp.Embeddings {
+		vectors[i] = e.Values
+	}
+	return vectors, nil
+}
+
+func (g *GeminiEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	em, err := g.embeddingModel(ctx, genai.TaskTypeRetrievalQuery)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := em.EmbedContent(ctx, genai.Text(text))
+	if err != nil {
+		return nil, fmt.Errorf("embedding failed: %w", err)
+	}
+	if resp.Embedding == nil {
+		return nil, fmt.Errorf("embedding failed: empty response")
+	}
+	return resp.Embedding.Values, nil
+}
+
+func (g *GeminiEmbedder) embeddingModel(ctx context.Context, task genai.TaskType) (*genai.EmbeddingModel, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.client == nil {
+		authType := "oauth2"
+		if s := g.settings; s != nil && s.Security != nil && s.Security.Auth != nil && s.Security.Auth.SelectedType != "" {
+			authType = s.Security.Auth.SelectedType
+		}
+		authenticator, _, err := auth.NewAuthenticator(authType)
+		if err != nil {
+			return nil, err
+		}
+		token, err := authenticator.GetToken()
+		if err != nil {
+			return nil, fmt.Errorf("authentication failed: %w", err)
+		}
+		client, err := genai.NewClient(ctx, option.WithAPIKey(token))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create client: %w", err)
+		}
+		g.client = client
+	}
+	em := g.client.EmbeddingModel(g.model)
+	em.TaskType = task
+	return em, nil
+}
@@ -0,0 +1,306 @@
+// Package index maintains a local semantic index of a project: its text
+// files split into chunks of lines, each with an embedding vector, so that
+// code can be retrieved by meaning rather than by name.
+//
+// The index is a gob file in ~/.gemini/tmp/<project-hash>/index. It records
+// the SHA-256 of every file it covers, so that updates embed only the files
+// whose content changed.
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+const (
+	// chunkLines is the number of lines per chunk, and chunkOverlap the
+	// number of lines consecutive chunks share.
+	chunkLines   = 60
+	chunkOverlap = 10
+	// maxChunkBytes caps the text of a chunk sent for embedding.
+	maxChunkBytes = 8000
+	// maxFileBytes is the size of the largest file indexed.
+	maxFileBytes = 1 << 20
+	// batchSize is the number of chunks embedded per request.
+	batchSize = 100
+)
+
+// ErrNotBuilt is returned by Load when the project has no index.
+var ErrNotBuilt = errors.New("the project has no semantic index; run `gemini index build` first")
+
+// Chunk is a span of lines of a file and its embedding.
+type Chunk struct {
+	// Path is relative to the project root, with forward slashes.
+	Path      string
+	StartLine int
+	EndLine   int
+	Text      string
+	Vector    []float32
+}
+
+// Index is the semantic index of a project.
+type Index struct {
+	// Model is the embedding model the vectors come from.
+	Model string
+	Built time.Time
+	// Files maps the indexed files to the SHA-256 of their content.
+	Files  map[string]string
+	Chunks []Chunk
+}
+
+// Stats describes a build.
+type Stats struct {
+	Files    int
+	Chunks   int
+	Embedded int
+	// Removed counts the files of the previous index that are gone.
+	Removed int
+}
+
+// Result is a chunk matching a query, with its cosine similarity.
+type Result struct {
+	Chunk
+	Score float64
+}
+
+func path(root string) (string, error) {
+	tmp, err := config.ProjectTempDir(root)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(tmp, "index", "semantic.gob"), nil
+}
+
+// Load reads the index of the project at root.
+func Load(root string) (*Index, error) {
+	p, err := path(root)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotBuilt
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var ix Index
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(&ix); err != nil {
+		return nil, fmt.Errorf("the semantic index is corrupt, rebuild it with `gemini index build`: %w", err)
+	}
+	return &ix, nil
+}
+
+// Save writes the index of the project at root.
+func (ix *Index) Save(root string) error {
+	p, err := path(root)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(ix); err != nil {
+		return err
+	}
+	// A temporary file of its own, so that saves of the same index by
+	// other sessions do not write into it.
+	f, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+// Build indexes the text files of the project at root, reusing the chunks
+// of previous, which may be nil, for unchanged files. progress, if not nil,
+// is called after each batch of embeddings.
+func Build(ctx context.Context, root string, e Embedder, previous *Index, progress func(done, total int)) (*Index, Stats, error) {
+	var stats Stats
+	files, err := listFiles(root)
+	if err != nil {
+		return nil, stats, err
+	}
+	reusable := map[string][]Chunk{}
+	if previous != nil && previous.Model == e.Model() {
+		for _, c := range previous.Chunks {
+			reusable[c.Path] = append(reusable[c.Path], c)
+		}
+	}
+
+	ix := &Index{Model: e.Model(), Built: time.Now(), Files: map[string]string{}}
+	var pending []int // chunks to embed, by index in ix.Chunks
+	for _, rel := range files {
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil || len(data) > maxFileBytes || bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])
+		ix.Files[rel] = hash
+		stats.Files++
+		if previous != nil && previous.Files[rel] == hash && reusable[rel] != nil {
+			ix.Chunks = append(ix.Chunks, reusable[rel]...)
+			continue
+		}
+		for _, c := range split(rel, string(data)) {
+			pending = append(pending, len(ix.Chunks))
+			ix.Chunks = append(ix.Chunks, c)
+		}
+	}
+
+	for start := 0; start < len(pending); start += batchSize {
+		batch := pending[start:min(start+batchSize, len(pending))]
+		texts := make([]string, len(batch))
+		for i, n := range batch {
+			texts[i] = ix.Chunks[n].Path + "\n" + ix.Chunks[n].Text
+		}
+		vectors, err := e.EmbedDocuments(ctx, texts)
+		if err != nil {
+			return nil, stats, err
+		}
+		if len(vectors) != len(batch) {
+			return nil, stats, fmt.Errorf("expected %d embeddings, got %d", len(batch), len(vectors))
+		}
+		for i, n := range batch {
+			ix.Chunks[n].Vector = vectors[i]
+		}
+		stats.Embedded += len(batch)
+		if progress != nil {
+			progress(stats.Embedded, len(pending))
+		}
+	}
+	stats.Chunks = len(ix.Chunks)
+	if previous != nil {
+		for rel := range previous.Files {
+			if _, ok := ix.Files[rel]; !ok {
+				stats.Removed++
+			}
+		}
+	}
+	return ix, stats, nil
+}
+
+// Search returns the k chunks most similar to the query vector.
+func (ix *Index) Search(query []float32, k int) []Result {
+	var results []Result
+	for _, c := range ix.Chunks {
+		results = append(results, Result{Chunk: c, Score: cosine(query, c.Vector)})
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	return results[:min(k, len(results))]
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// split divides content into overlapping chunks of lines.
+func split(rel, content string) []Chunk {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var chunks []Chunk
+	for start := 0; start < len(lines); start += chunkLines - chunkOverlap {
+		end := min(start+chunkLines, len(lines))
+		text := strings.Join(lines[start:end], "")
+		if len(text) > maxChunkBytes {
+			text = strings.ToValidUTF8(text[:maxChunkBytes], "")
+		}
+		if strings.TrimSpace(text) != "" {
+			chunks = append(chunks, Chunk{Path: rel, StartLine: start + 1, EndLine: end, Text: text})
+		}
+		if end == len(lines) {
+			break
+		}
+	}
+	return chunks
+}
+
+// skippedDirs are not indexed when the project is not a git repository.
+var skippedDirs = map[string]bool{"node_modules": true, "vendor": true, "dist": true, "build": true, "target": true}
+
+// listFiles returns the files to index, relative to root: those git tracks
+// or would track, or else all files outside hidden and dependency
+// directories.
+func listFiles(root string) ([]string, error) {
+	cmd := exec.Command("git", "ls-files", "--cached", "--others", "--exclude-standard", "-z")
+	cmd.Dir = root
+	if out, err := cmd.Output(); err == nil {
+		var files []string
+		for _, name := range strings.Split(string(out), "\x00") {
+			if name != "" {
+				files = append(files, name)
+			}
+		}
+		sort.Strings(files)
+		return files, nil
+	}
+
+	var files []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if p != root && (strings.HasPrefix(name, ".") || skippedDirs[name]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(name, ".") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return nil
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,194 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// wordEmbedder embeds texts as the counts of a few words.
+type wordEmbedder struct {
+	embedded int
+}
+
+var words = []string{"retry", "backoff", "parse", "token", "render"}
+
+func (e *wordEmbedder) Model() string { return "words" }
+
+func (e *wordEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	var vectors [][]float32
+	for _, t := range texts {
+		v, _ := e.EmbedQuery(ctx, t)
+		vectors = append(vectors, v)
+	}
+	e.embedded += len(texts)
+	return vectors, nil
+}
+
+func (e *wordEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	v := make([]float32, len(words))
+	for i, w := range words {
+		v[i] = float32(strings.Count(text, w))
+	}
+	return v, nil
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBuildAndSearch(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"client/retry.go":         "// retry with exponential backoff\nfunc retry() {}\n",
+		"parser/parse.go":         "// parse a token stream\nfunc parse() {}\n",
+		"ui/render.go":            "func render() {}\n",
+		"node_modules/x/index.js": "retry retry retry\n",
+		".hidden/secret.txt":      "token\n",
+		"logo.png":                "\x89PNG\x00\x00",
+	})
+
+	e := &wordEmbedder{}
+	if _, err := Load(root); !errors.Is(err, ErrNotBuilt) {
+		t.Fatalf("Load before building = %v, want ErrNotBuilt", err)
+	}
+	ix, stats, err := Build(context.Background(), root, e, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Files != 3 || stats.Embedded != 3 {
+		t.Errorf("stats = %+v, want 3 files embedded", stats)
+	}
+	if err := ix.Save(root); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, _ := e.EmbedQuery(context.Background(), "backoff")
+	results := loaded.Search(query, 2)
+	if len(results) != 2 || results[0].Path != "client/retry.go" || results[0].StartLine != 1 || results[0].EndLine != 2 {
+		t.Errorf("Search = %+v", results)
+	}
+
+	// Only the changed file is embedded again.
+	writeFiles(t, root, map[string]string{"ui/render.go": "func render(token) {}\n"})
+	e.embedded = 0
+	_, stats, err = Build(context.Background(), root, e, loaded, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.embedded != 1 || stats.Chunks != 3 {
+		t.Errorf("rebuild embedded %d chunks of %d, want 1 of 3", e.embedded, stats.Chunks)
+	}
+}
+
+func TestSplitOverlaps(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 130; i++ {
+		b.WriteString("line\n")
+	}
+	chunks := split("a.txt", b.String())
+	var spans [][2]int
+	for _, c := range chunks {
+		spans = append(spans, [2]int{c.StartLine, c.EndLine})
+	}
+	want := [][2]int{{1, 60}, {51, 110}, {101, 130}}
+	if len(spans) != len(want) {
+		t.Fatalf("spans = %v, want %v", spans, want)
+	}
+	for i := range want {
+		if spans[i] != want[i] {
+			t.Errorf("spans = %v, want %v", spans, want)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.go": "retry\n", "b.go": "parse\n"})
+	e := &wordEmbedder{}
+	ctx := context.Background()
+
+	// Without an index, Update builds one.
+	if _, stats, err := Update(ctx, root, e, nil); err != nil || stats.Embedded != 2 {
+		t.Fatalf("first Update = %+v, %v", stats, err)
+	}
+	built, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, stats, err := Update(ctx, root, e, nil); err != nil || stats.Embedded != 0 {
+		t.Errorf("Update without changes = %+v, %v", stats, err)
+	}
+	if again, _ := Load(root); !again.Built.Equal(built.Built) {
+		t.Error("Update without changes saved the index")
+	}
+
+	os.Remove(filepath.Join(root, "b.go"))
+	writeFiles(t, root, map[string]string{"c.go": "token\n"})
+	_, stats, err := Update(ctx, root, e, nil)
+	if err != nil || stats.Embedded != 1 || stats.Removed != 1 || stats.Files != 2 {
+		t.Errorf("Update = %+v, %v; want c.go embedded and b.go removed", stats, err)
+	}
+	ix, _ := Load(root)
+	for _, c := range ix.Chunks {
+		if c.Path == "b.go" {
+			t.Error("b.go is still indexed")
+		}
+	}
+}
+
+func TestSaveConcurrently(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.go": "retry\n"})
+	ix, _, err := Build(context.Background(), root, &wordEmbedder{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sessions saving the same index at once each write a file of their
+	// own, so none of them fails or leaves a mix of the others behind.
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = ix.Save(root)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := Load(root); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := path(root)
+	entries, _ := os.ReadDir(filepath.Dir(p))
+	if len(entries) != 1 || entries[0].Name() != "semantic.gob" {
+		t.Errorf("index directory holds %v, want only the index", entries)
+	}
+}
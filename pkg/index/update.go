@@ -0,0 +1,50 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"time"
+)
+
+// DefaultUpdateInterval is how often Watch updates the index.
+const DefaultUpdateInterval = 5 * time.Minute
+
+// Update brings the index of the project at root up to date, embedding only
+// the files added or changed since it was last built and dropping removed
+// ones. Without an index, it builds one. It is saved only if something
+// changed.
+func Update(ctx context.Context, root string, e Embedder, progress func(done, total int)) (*Index, Stats, error) {
+	previous, err := Load(root)
+	if err != nil && !errors.Is(err, ErrNotBuilt) {
+		return nil, Stats{}, err
+	}
+	ix, stats, err := Build(ctx, root, e, previous, progress)
+	if err != nil {
+		return nil, stats, err
+	}
+	if previous != nil && previous.Model == ix.Model && maps.Equal(previous.Files, ix.Files) {
+		return previous, stats, nil
+	}
+	return ix, stats, ix.Save(root)
+}
+
+// Watch updates the index of the project at root now and then every
+// interval, until ctx is done. Projects without an index are left alone:
+// building one is up to the user. Failures are reported to failed.
+func Watch(ctx context.Context, root string, e Embedder, interval time.Duration, failed func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := Load(root); err == nil {
+			if _, _, err := Update(ctx, root, e, nil); err != nil && ctx.Err() == nil {
+				failed(err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
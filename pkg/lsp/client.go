@@ -0,0 +1,346 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+// shutdownTimeout is how long a server gets to exit after being asked to.
+const shutdownTimeout = 3 * time.Second
+
+// message is any JSON-RPC message: a request, a response or a
+// notification.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  any              `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *ResponseError   `json:"error,omitempty"`
+}
+
+// ResponseError is an error returned by the server.
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("language server error %d: %s", e.Code, e.Message)
+}
+
+// Client is a connection to a language server running over stdio.
+type Client struct {
+	Name string
+
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan *message
+	opened  map[string]int // document versions
+	done    chan struct{}
+	err     error
+}
+
+// Start starts a language server for the project at root and performs the
+// initialize handshake.
+func Start(ctx context.Context, name string, server config.LSPServer, root string) (*Client, error) {
+	cmd := exec.Command(server.Command, server.Args...)
+	cmd.Dir = root
+	cmd.Stderr = io.Discard
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("could not start the %s language server: %w", name, err)
+	}
+	c := &Client{
+		Name:    name,
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: map[int64]chan *message{},
+		opened:  map[string]int{},
+		done:    make(chan struct{}),
+	}
+	go c.read(bufio.NewReader(stdout))
+
+	params := map[string]any{
+		"processId": os.Getpid(),
+		"clientInfo": map[string]string{
+			"name": "gemini-cli",
+		},
+		"rootUri": PathToURI(root),
+		"workspaceFolders": []map[string]string{
+			{"uri": PathToURI(root), "name": name},
+		},
+		"capabilities": map[string]any{
+			"textDocument": map[string]any{
+				"definition": map[string]any{"linkSupport": true},
+				"references": map[string]any{},
+				"rename":     map[string]any{"prepareSupport": false},
+				"synchronization": map[string]any{
+					"didSave": false,
+				},
+			},
+			"workspace": map[string]any{
+				"workspaceEdit":    map[string]any{"documentChanges": true},
+				"workspaceFolders": true,
+				"configuration":    true,
+			},
+		},
+	}
+	if server.InitializationOptions != nil {
+		params["initializationOptions"] = server.InitializationOptions
+	}
+	if err := c.call(ctx, "initialize", params, nil); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("initializing the %s language server: %w", name, err)
+	}
+	if err := c.notify("initialized", map[string]any{}); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Definition returns where the symbol at pos in the file at path is
+// defined.
+func (c *Client) Definition(ctx context.Context, path string, pos Position) ([]Location, error) {
+	var raw json.RawMessage
+	if err := c.positionRequest(ctx, "textDocument/definition", path, pos, nil, &raw); err != nil {
+		return nil, err
+	}
+	return locations(raw)
+}
+
+// References returns the references to the symbol at pos, including its
+// declaration.
+func (c *Client) References(ctx context.Context, path string, pos Position) ([]Location, error) {
+	var raw json.RawMessage
+	extra := map[string]any{"context": map[string]bool{"includeDeclaration": true}}
+	if err := c.positionRequest(ctx, "textDocument/references", path, pos, extra, &raw); err != nil {
+		return nil, err
+	}
+	return locations(raw)
+}
+
+// Rename returns the edits renaming the symbol at pos to newName.
+func (c *Client) Rename(ctx context.Context, path string, pos Position, newName string) (*WorkspaceEdit, error) {
+	var edit *WorkspaceEdit
+	extra := map[string]any{"newName": newName}
+	if err := c.positionRequest(ctx, "textDocument/rename", path, pos, extra, &edit); err != nil {
+		return nil, err
+	}
+	if edit == nil {
+		return nil, errors.New("the language server found nothing to rename at that position")
+	}
+	return edit, nil
+}
+
+func (c *Client) positionRequest(ctx context.Context, method, path string, pos Position, extra map[string]any, result any) error {
+	if err := c.sync(path); err != nil {
+		return err
+	}
+	params := map[string]any{
+		"textDocument": map[string]string{"uri": PathToURI(path)},
+		"position":     pos,
+	}
+	for k, v := range extra {
+		params[k] = v
+	}
+	return c.call(ctx, method, params, result)
+}
+
+// sync sends the current content of the file at path to the server,
+// opening the document the first time.
+func (c *Client) sync(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	version, opened := c.opened[path]
+	c.opened[path] = version + 1
+	c.mu.Unlock()
+
+	uri := PathToURI(path)
+	if !opened {
+		return c.notify("textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{"uri": uri, "languageId": languageID(path), "version": version + 1, "text": string(data)},
+		})
+	}
+	return c.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": version + 1},
+		"contentChanges": []map[string]string{{"text": string(data)}},
+	})
+}
+
+// Close shuts the server down, killing it if it does not exit in time.
+func (c *Client) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if c.call(ctx, "shutdown", nil, nil) == nil {
+		c.notify("exit", nil)
+	}
+	c.stdin.Close()
+	exited := make(chan error, 1)
+	go func() { exited <- c.cmd.Wait() }()
+	select {
+	case <-exited:
+	case <-ctx.Done():
+		c.cmd.Process.Kill()
+		<-exited
+	}
+	return nil
+}
+
+func (c *Client) call(ctx context.Context, method string, params, result any) error {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := c.nextID
+	ch := make(chan *message, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	rawID := json.RawMessage(strconv.FormatInt(id, 10))
+	if err := c.write(&message{ID: &rawID, Method: method, Params: params}); err != nil {
+		return err
+	}
+	select {
+	case msg := <-ch:
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result == nil || len(msg.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(msg.Result, result)
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		c.notify("$/cancelRequest", map[string]int64{"id": id})
+		return ctx.Err()
+	}
+}
+
+func (c *Client) notify(method string, params any) error {
+	return c.write(&message{Method: method, Params: params})
+}
+
+func (c *Client) write(msg *message) error {
+	msg.JSONRPC = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := fmt.Fprintf(c.stdin, "Content-Length: %d\r\n\r\n%s", len(data), data); err != nil {
+		return fmt.Errorf("writing to the %s language server: %w", c.Name, err)
+	}
+	return nil
+}
+
+// read dispatches the messages from the server until it exits.
+func (c *Client) read(r *bufio.Reader) {
+	var err error
+	for {
+		var data []byte
+		if data, err = readMessage(r); err != nil {
+			break
+		}
+		var msg message
+		if json.Unmarshal(data, &msg) != nil {
+			continue
+		}
+		switch {
+		case msg.ID != nil && msg.Method != "":
+			c.reply(&msg)
+		case msg.ID != nil:
+			id, _ := strconv.ParseInt(string(*msg.ID), 10, 64)
+			c.mu.Lock()
+			ch := c.pending[id]
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- &msg
+			}
+		}
+	}
+	c.mu.Lock()
+	c.err = fmt.Errorf("the %s language server exited: %w", c.Name, err)
+	c.mu.Unlock()
+	close(c.done)
+}
+
+// reply answers a request from the server. Configuration requests get an
+// empty configuration per item; anything else gets a null result, which
+// suffices for progress and capability registration.
+func (c *Client) reply(req *message) {
+	var result any
+	if req.Method == "workspace/configuration" {
+		var params struct {
+			Items []any `json:"items"`
+		}
+		if data, err := json.Marshal(req.Params); err == nil {
+			json.Unmarshal(data, &params)
+		}
+		result = make([]any, len(params.Items))
+	}
+	data, _ := json.Marshal(result)
+	c.write(&message{ID: req.ID, Result: data})
+}
+
+// readMessage reads a message framed by a Content-Length header.
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("invalid Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("message without Content-Length")
+	}
+	data := make([]byte, length)
+	_, err := io.ReadFull(r, data)
+	return data, err
+}
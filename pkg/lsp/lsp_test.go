@@ -0,0 +1,187 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+// TestMain lets the test binary act as a language server for the tests.
+func TestMain(m *testing.M) {
+	if os.Getenv("LSP_TEST_SERVER") == "1" {
+		serveFake()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// serveFake answers definition, references and rename requests with
+// positions on the first line of the requested document.
+func serveFake() {
+	r := bufio.NewReader(os.Stdin)
+	send := func(msg map[string]any) {
+		msg["jsonrpc"] = "2.0"
+		data, _ := json.Marshal(msg)
+		fmt.Printf("Content-Length: %d\r\n\r\n%s", len(data), data)
+	}
+	for {
+		data, err := readMessage(r)
+		if err != nil {
+			return
+		}
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params struct {
+				TextDocument struct {
+					URI string `json:"uri"`
+				} `json:"textDocument"`
+				NewName string `json:"newName"`
+			} `json:"params"`
+		}
+		json.Unmarshal(data, &req)
+		uri := req.Params.TextDocument.URI
+		at := func(line, start, end int) map[string]any {
+			return map[string]any{
+				"start": map[string]int{"line": line, "character": start},
+				"end":   map[string]int{"line": line, "character": end},
+			}
+		}
+		var result any
+		switch req.Method {
+		case "initialize":
+			// Ask the client something first, as gopls does.
+			send(map[string]any{"id": "cfg", "method": "workspace/configuration", "params": map[string]any{"items": []any{map[string]any{}}}})
+			result = map[string]any{"capabilities": map[string]any{}}
+		case "textDocument/definition":
+			result = []map[string]any{{"targetUri": uri, "targetRange": at(0, 0, 20), "targetSelectionRange": at(0, 5, 8)}}
+		case "textDocument/references":
+			result = []map[string]any{{"uri": uri, "range": at(0, 5, 8)}, {"uri": uri, "range": at(1, 1, 4)}}
+		case "textDocument/rename":
+			result = map[string]any{"changes": map[string]any{uri: []map[string]any{
+				{"range": at(0, 5, 8), "newText": req.Params.NewName},
+				{"range": at(1, 1, 4), "newText": req.Params.NewName},
+			}}}
+		case "exit":
+			return
+		}
+		if req.ID != nil && req.Method != "" {
+			send(map[string]any{"id": req.ID, "result": result})
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	t.Setenv("LSP_TEST_SERVER", "1")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte("func foo() {}\n\tfoo()\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	m := NewManager(dir, &config.LSPSettings{Servers: map[string]config.LSPServer{
+		"gopls": {Command: os.Args[0], Extensions: []string{".go"}},
+	}})
+	defer m.Close()
+	c, err := m.ClientFor(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pos := Position{Line: 1, Character: 1}
+
+	defs, err := c.Definition(ctx, path, pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) != 1 || defs[0].URI != PathToURI(path) || defs[0].Range.Start != (Position{0, 5}) {
+		t.Errorf("Definition = %+v", defs)
+	}
+
+	refs, err := c.References(ctx, path, pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 2 {
+		t.Errorf("References = %+v", refs)
+	}
+
+	edit, err := c.Rename(ctx, path, pos, "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	edits, err := edit.FileEdits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ApplyEdits("func foo() {}\n\tfoo()\n", edits[path])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "func bar() {}\n\tbar()\n"; got != want {
+		t.Errorf("renamed content = %q, want %q", got, want)
+	}
+
+	if again, err := m.ClientFor(ctx, path); err != nil || again != c {
+		t.Errorf("ClientFor started another server: %v", err)
+	}
+}
+
+func TestClientForUnknownExtension(t *testing.T) {
+	m := NewManager(t.TempDir(), nil)
+	if _, err := m.ClientFor(context.Background(), "notes.txt"); err == nil {
+		t.Error("ClientFor(notes.txt) succeeded")
+	}
+}
+
+func TestOffsetCountsUTF16(t *testing.T) {
+	content := "a😀b\nsecond"
+	tests := []struct {
+		pos  Position
+		want int
+	}{
+		{Position{0, 0}, 0},
+		{Position{0, 1}, 1},
+		{Position{0, 3}, 5}, // the emoji is two UTF-16 units and four bytes
+		{Position{0, 4}, 6},
+		{Position{0, 99}, 6}, // clamped to the end of the line
+		{Position{1, 2}, 9},
+	}
+	for _, tt := range tests {
+		got, err := Offset(content, tt.pos)
+		if err != nil || got != tt.want {
+			t.Errorf("Offset(%+v) = %d, %v; want %d", tt.pos, got, err, tt.want)
+		}
+	}
+	if _, err := Offset(content, Position{5, 0}); err == nil {
+		t.Error("Offset past the last line succeeded")
+	}
+	if got := Column("a😀b", 5); got != 3 {
+		t.Errorf("Column = %d, want 3", got)
+	}
+}
+
+func TestApplyEditsRejectsOverlaps(t *testing.T) {
+	edits := []TextEdit{
+		{Range: Range{Position{0, 0}, Position{0, 3}}, NewText: "x"},
+		{Range: Range{Position{0, 2}, Position{0, 4}}, NewText: "y"},
+	}
+	if _, err := ApplyEdits("abcdef", edits); err == nil {
+		t.Error("ApplyEdits with overlapping edits succeeded")
+	}
+}
+
+func TestFileEditsRejectsFileOperations(t *testing.T) {
+	e := &WorkspaceEdit{DocumentChanges: []json.RawMessage{
+		json.RawMessage(`{"kind":"rename","oldUri":"file:///a.go","newUri":"file:///b.go"}`),
+	}}
+	if _, err := e.FileEdits(); err == nil {
+		t.Error("FileEdits with a file rename succeeded")
+	}
+}
@@ -0,0 +1,112 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/shutdown"
+)
+
+// DefaultServers are the language servers used unless tools.lsp.servers
+// configures others under the same names.
+var DefaultServers = map[string]config.LSPServer{
+	"gopls": {
+		Command:    "gopls",
+		Extensions: []string{".go"},
+	},
+	"typescript": {
+		Command:    "typescript-language-server",
+		Args:       []string{"--stdio"},
+		Extensions: []string{".ts", ".tsx", ".mts", ".cts", ".js", ".jsx", ".mjs", ".cjs"},
+	},
+	"pyright": {
+		Command:    "pyright-langserver",
+		Args:       []string{"--stdio"},
+		Extensions: []string{".py", ".pyi"},
+	},
+}
+
+// Manager starts language servers for a project on demand, one per
+// configured server, and stops them on shutdown.
+type Manager struct {
+	root    string
+	servers map[string]config.LSPServer
+
+	mu      sync.Mutex
+	clients map[string]*Client
+}
+
+// NewManager returns a manager for the project at root using the default
+// servers and those configured in settings.
+func NewManager(root string, settings *config.LSPSettings) *Manager {
+	servers := map[string]config.LSPServer{}
+	for name, s := range DefaultServers {
+		servers[name] = s
+	}
+	if settings != nil {
+		for name, s := range settings.Servers {
+			servers[name] = s
+		}
+	}
+	return &Manager{root: root, servers: servers, clients: map[string]*Client{}}
+}
+
+// ClientFor returns the client of the server handling the file at path,
+// starting the server if needed.
+func (m *Manager) ClientFor(ctx context.Context, path string) (*Client, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	var names []string
+	for name, s := range m.servers {
+		if s.Command != "" && slices.Contains(s.Extensions, ext) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no language server handles %s files; configure one in tools.lsp.servers", ext)
+	}
+	sort.Strings(names)
+	name := names[0]
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c := m.clients[name]; c != nil {
+		select {
+		case <-c.done:
+			// The server exited; start it again.
+		default:
+			return c, nil
+		}
+	}
+	server := m.servers[name]
+	if _, err := exec.LookPath(server.Command); err != nil {
+		return nil, fmt.Errorf("the %s language server (%s) is not installed or not on PATH; configure it in tools.lsp.servers", name, server.Command)
+	}
+	c, err := Start(ctx, name, server, m.root)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.clients) == 0 {
+		shutdown.Register("stop language servers", func(context.Context) error { return m.Close() })
+	}
+	m.clients[name] = c
+	return c, nil
+}
+
+// Close stops the running servers.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	clients := m.clients
+	m.clients = map[string]*Client{}
+	m.mu.Unlock()
+	for _, c := range clients {
+		c.Close()
+	}
+	return nil
+}
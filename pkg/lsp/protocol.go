@@ -0,0 +1,217 @@
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Position is a zero-based line and UTF-16 column in a document.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a span of a document.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Location is a range in a file.
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// TextEdit replaces a range of a document.
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// WorkspaceEdit is a set of changes to files, such as a rename produces.
+type WorkspaceEdit struct {
+	Changes         map[string][]TextEdit `json:"changes,omitempty"`
+	DocumentChanges []json.RawMessage     `json:"documentChanges,omitempty"`
+}
+
+// FileEdits returns the edits of e by file path. Creating, renaming and
+// deleting files is not supported.
+func (e *WorkspaceEdit) FileEdits() (map[string][]TextEdit, error) {
+	edits := map[string][]TextEdit{}
+	for uri, changes := range e.Changes {
+		path, err := URIToPath(uri)
+		if err != nil {
+			return nil, err
+		}
+		edits[path] = append(edits[path], changes...)
+	}
+	for _, raw := range e.DocumentChanges {
+		var change struct {
+			Kind         string `json:"kind"`
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			Edits []TextEdit `json:"edits"`
+		}
+		if err := json.Unmarshal(raw, &change); err != nil {
+			return nil, err
+		}
+		if change.Kind != "" {
+			return nil, fmt.Errorf("the edit would %s a file, which is not supported", change.Kind)
+		}
+		path, err := URIToPath(change.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		edits[path] = append(edits[path], change.Edits...)
+	}
+	return edits, nil
+}
+
+// PathToURI returns the file URI of an absolute path.
+func PathToURI(path string) string {
+	path = filepath.ToSlash(path)
+	if runtime.GOOS == "windows" {
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// URIToPath returns the path of a file URI.
+func URIToPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported document URI %q", uri)
+	}
+	path := u.Path
+	if runtime.GOOS == "windows" {
+		path = strings.TrimPrefix(path, "/")
+	}
+	return filepath.FromSlash(path), nil
+}
+
+// Offset returns the byte offset in content of a position.
+func Offset(content string, pos Position) (int, error) {
+	off := 0
+	for line := 0; line < pos.Line; line++ {
+		i := strings.IndexByte(content[off:], '\n')
+		if i < 0 {
+			return 0, fmt.Errorf("line %d is past the end of the document", pos.Line+1)
+		}
+		off += i + 1
+	}
+	end := len(content)
+	if i := strings.IndexByte(content[off:], '\n'); i >= 0 {
+		end = off + i
+	}
+	for units := 0; units < pos.Character; {
+		if off >= end {
+			// Clients clamp columns past the end of the line.
+			return end, nil
+		}
+		r, size := utf8.DecodeRuneInString(content[off:])
+		units += len(utf16.Encode([]rune{r}))
+		off += size
+	}
+	return off, nil
+}
+
+// Column returns the UTF-16 column of a byte offset within line.
+func Column(line string, byteOffset int) int {
+	return len(utf16.Encode([]rune(line[:byteOffset])))
+}
+
+// ApplyEdits applies non-overlapping edits to content.
+func ApplyEdits(content string, edits []TextEdit) (string, error) {
+	type span struct {
+		start, end int
+		text       string
+	}
+	spans := make([]span, len(edits))
+	for i, e := range edits {
+		start, err := Offset(content, e.Range.Start)
+		if err != nil {
+			return "", err
+		}
+		end, err := Offset(content, e.Range.End)
+		if err != nil {
+			return "", err
+		}
+		if end < start {
+			return "", errors.New("edit range ends before it starts")
+		}
+		spans[i] = span{start, end, e.NewText}
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	var b strings.Builder
+	at := 0
+	for _, s := range spans {
+		if s.start < at {
+			return "", errors.New("edits overlap")
+		}
+		b.WriteString(content[at:s.start])
+		b.WriteString(s.text)
+		at = s.end
+	}
+	b.WriteString(content[at:])
+	return b.String(), nil
+}
+
+// locations decodes a Location, []Location or []LocationLink result.
+func locations(raw json.RawMessage) ([]Location, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	if raw[0] == '{' {
+		raw = json.RawMessage("[" + string(raw) + "]")
+	}
+	var items []struct {
+		Location
+		TargetURI            string `json:"targetUri"`
+		TargetSelectionRange Range  `json:"targetSelectionRange"`
+	}
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, err
+	}
+	var locs []Location
+	for _, it := range items {
+		if it.TargetURI != "" {
+			locs = append(locs, Location{URI: it.TargetURI, Range: it.TargetSelectionRange})
+		} else {
+			locs = append(locs, it.Location)
+		}
+	}
+	return locs, nil
+}
+
+// languageIDs maps file extensions to LSP language identifiers.
+var languageIDs = map[string]string{
+	".go":  "go",
+	".ts":  "typescript",
+	".mts": "typescript",
+	".cts": "typescript",
+	".tsx": "typescriptreact",
+	".js":  "javascript",
+	".mjs": "javascript",
+	".cjs": "javascript",
+	".jsx": "javascriptreact",
+	".py":  "python",
+	".pyi": "python",
+}
+
+func languageID(path string) string {
+	if id, ok := languageIDs[strings.ToLower(filepath.Ext(path))]; ok {
+		return id
+	}
+	return strings.TrimPrefix(filepath.Ext(path), ".")
+}
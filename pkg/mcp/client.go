@@ -0,0 +1,585 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/updatechecker"
+)
+
+const (
+	protocolVersion = "2025-06-18"
+	// defaultTimeout applies to each request when the server configures none.
+	defaultTimeout = 10 * time.Minute
+	// defaultStartupTimeout bounds starting a server and the handshake when
+	// the server configures no startupTimeout. It leaves time for commands
+	// like npx to download the server first.
+	defaultStartupTimeout = time.Minute
+	// cancelTimeout bounds telling a server that a request was cancelled.
+	cancelTimeout = 5 * time.Second
+
+	// toolsListChanged, promptsListChanged and resourcesListChanged are
+	// the notifications of a server whose tools, prompts or resources
+	// changed.
+	toolsListChanged     = "notifications/tools/list_changed"
+	promptsListChanged   = "notifications/prompts/list_changed"
+	resourcesListChanged = "notifications/resources/list_changed"
+)
+
+// Tool is a tool offered by an MCP server.
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"inputSchema,omitempty"`
+}
+
+// Prompt is a prompt template offered by an MCP server.
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+// PromptArgument is an argument a prompt is filled in with.
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// PromptMessage is a message of a prompt the server filled in.
+type PromptMessage struct {
+	Role    string  `json:"role"`
+	Content Content `json:"content"`
+}
+
+// GetPromptResult is a prompt filled in with its arguments.
+type GetPromptResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+// Resource is a resource offered by an MCP server, such as a file or a
+// database schema, which can be read as context.
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceContents is the content of a resource: Text for text, Blob
+// (base64) for binary data.
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// ServerCapabilities are the features a server declared in the handshake.
+// A nil feature is not offered.
+type ServerCapabilities struct {
+	Tools     *ListCapability `json:"tools,omitempty"`
+	Prompts   *ListCapability `json:"prompts,omitempty"`
+	Resources *ListCapability `json:"resources,omitempty"`
+}
+
+// ListCapability describes a list the server offers. ListChanged is set if
+// the server notifies the client when the list changes.
+type ListCapability struct {
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+// Content is an item of a tool result. Text is set for text content, Data
+// (base64) and MimeType for images and audio.
+type Content struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	Data     string          `json:"data,omitempty"`
+	MimeType string          `json:"mimeType,omitempty"`
+	Resource json.RawMessage `json:"resource,omitempty"`
+}
+
+// CallToolResult is the result of a tool call. IsError reports a failure of
+// the tool itself, described by Content.
+type CallToolResult struct {
+	Content           []Content       `json:"content"`
+	StructuredContent json.RawMessage `json:"structuredContent,omitempty"`
+	IsError           bool            `json:"isError,omitempty"`
+}
+
+type request struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      *int64 `json:"id,omitempty"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+// message is any incoming JSON-RPC message: a response, a request or a
+// notification from the server.
+type message struct {
+	ID     *json.RawMessage `json:"id,omitempty"`
+	Method string           `json:"method,omitempty"`
+	Result json.RawMessage  `json:"result,omitempty"`
+	Error  *RPCError        `json:"error,omitempty"`
+}
+
+// RPCError is an error returned by the server.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("MCP error %d: %s", e.Code, e.Message)
+}
+
+// conn sends JSON-RPC messages over a transport.
+type conn interface {
+	// call sends a request and waits for the response with the same ID.
+	call(ctx context.Context, req *request) (*message, error)
+	// notify sends a notification, which has no response.
+	notify(ctx context.Context, req *request) error
+	close() error
+}
+
+// Client is a connection to an MCP server.
+type Client struct {
+	Name string
+	// ServerInfo is the name and version the server reported.
+	ServerInfo struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	Capabilities ServerCapabilities
+
+	conn    conn
+	timeout time.Duration
+	nextID  atomic.Int64
+
+	mu             sync.Mutex
+	onNotification func(method string)
+	// handling is the number of notification handlers running, and idle
+	// is closed once they have all returned.
+	handling int
+	idle     chan struct{}
+}
+
+// Connect connects to the named server and performs the MCP handshake.
+// A remote server is sent the OAuth token stored for it, if any. When the
+// server requires authorization, or its settings enable OAuth and no token
+// is stored, the user is asked to authorize if ctx allows it (see
+// WithAuthorization); otherwise the error says how to.
+func Connect(ctx context.Context, name string, server config.MCPServer) (*Client, error) {
+	server, err := server.Expand(name)
+	if err != nil {
+		return nil, err
+	}
+	show := authorizationHandoff(ctx)
+	handoff := func(authURL string) { show(name, authURL) }
+	if show != nil && server.OAuth != nil && server.OAuth.Enabled && !Authorized(name, server) {
+		if err := authorize(ctx, name, server, "", handoff); err != nil {
+			return nil, fmt.Errorf("failed to authorize with MCP server %q: %w", name, err)
+		}
+	}
+	c, err := connect(ctx, name, server)
+	var unauthorized *unauthorizedError
+	if !errors.As(err, &unauthorized) {
+		return c, err
+	}
+	if show == nil {
+		return nil, fmt.Errorf("%w; run gemini mcp auth %s, or /mcp auth %s in a session, to authorize", err, name, name)
+	}
+	if err := authorize(ctx, name, server, unauthorized.resourceMetadata, handoff); err != nil {
+		return nil, fmt.Errorf("failed to authorize with MCP server %q: %w", name, err)
+	}
+	return connect(ctx, name, server)
+}
+
+// connect connects to the expanded server.
+func connect(ctx context.Context, name string, server config.MCPServer) (*Client, error) {
+	c := &Client{Name: name, timeout: defaultTimeout}
+	if server.Timeout > 0 {
+		c.timeout = time.Duration(server.Timeout) * time.Millisecond
+	}
+	startup := defaultStartupTimeout
+	if server.StartupTimeout > 0 {
+		startup = time.Duration(server.StartupTimeout) * time.Millisecond
+	}
+	startCtx, cancel := context.WithTimeout(ctx, startup)
+	defer cancel()
+
+	switch {
+	case server.Command != "":
+		t, err := StartStdio(name, server)
+		if err != nil {
+			return nil, err
+		}
+		c.conn = newStdioConn(t, c.notified)
+	case server.HTTPURL != "":
+		client := &http.Client{Transport: newOAuthTransport(name, server.HTTPURL)}
+		c.conn = newHTTPConn(server.HTTPURL, server.Headers, client, c.notified)
+	case server.URL != "":
+		client := &http.Client{Transport: newOAuthTransport(name, server.URL)}
+		c.conn = newSSEConn(name, server.URL, server.Headers, client, c.notified)
+	default:
+		return nil, fmt.Errorf("MCP server %q has no command or URL", name)
+	}
+
+	var result struct {
+		ServerInfo *struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"serverInfo"`
+		Capabilities ServerCapabilities `json:"capabilities"`
+	}
+	err := c.call(startCtx, "initialize", map[string]any{
+		"protocolVersion": protocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]string{"name": "gemini-cli-go", "version": updatechecker.CurrentVersion},
+	}, &result)
+	if err == nil {
+		if result.ServerInfo != nil {
+			c.ServerInfo = *result.ServerInfo
+		}
+		c.Capabilities = result.Capabilities
+		err = c.conn.notify(startCtx, &request{JSONRPC: "2.0", Method: "notifications/initialized"})
+	}
+	if err != nil && ctx.Err() == nil && errors.Is(startCtx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("no response within %v (see mcpServers.%s.startupTimeout)", startup, name)
+	}
+	if err != nil {
+		c.conn.close()
+		return nil, fmt.Errorf("failed to initialize MCP server %q: %w", name, err)
+	}
+	return c, nil
+}
+
+// ListTools returns all tools offered by the server, following pagination.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	return list[Tool](ctx, c, "tools/list", "tools")
+}
+
+// ListPrompts returns all prompts offered by the server, following
+// pagination.
+func (c *Client) ListPrompts(ctx context.Context) ([]Prompt, error) {
+	return list[Prompt](ctx, c, "prompts/list", "prompts")
+}
+
+// GetPrompt returns the prompt called name filled in with args.
+func (c *Client) GetPrompt(ctx context.Context, name string, args map[string]string) (*GetPromptResult, error) {
+	if args == nil {
+		args = map[string]string{}
+	}
+	var result GetPromptResult
+	if err := c.call(ctx, "prompts/get", map[string]any{"name": name, "arguments": args}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListResources returns all resources offered by the server, following
+// pagination.
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	return list[Resource](ctx, c, "resources/list", "resources")
+}
+
+// ReadResource returns the contents of the resource at uri.
+func (c *Client) ReadResource(ctx context.Context, uri string) ([]ResourceContents, error) {
+	var result struct {
+		Contents []ResourceContents `json:"contents"`
+	}
+	if err := c.call(ctx, "resources/read", map[string]any{"uri": uri}, &result); err != nil {
+		return nil, err
+	}
+	return result.Contents, nil
+}
+
+// list requests every page of a list and returns the items of each page
+// under key.
+func list[T any](ctx context.Context, c *Client, method, key string) ([]T, error) {
+	var (
+		items  []T
+		cursor string
+	)
+	for {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var page map[string]json.RawMessage
+		if err := c.call(ctx, method, params, &page); err != nil {
+			return nil, err
+		}
+		var pageItems []T
+		if data, ok := page[key]; ok {
+			if err := json.Unmarshal(data, &pageItems); err != nil {
+				return nil, fmt.Errorf("%s: invalid result: %w", method, err)
+			}
+		}
+		items = append(items, pageItems...)
+		cursor = ""
+		if data, ok := page["nextCursor"]; ok {
+			json.Unmarshal(data, &cursor)
+		}
+		if cursor == "" {
+			return items, nil
+		}
+	}
+}
+
+// CallTool invokes a tool with the given arguments.
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]any) (*CallToolResult, error) {
+	if args == nil {
+		args = map[string]any{}
+	}
+	var result CallToolResult
+	if err := c.call(ctx, "tools/call", map[string]any{"name": name, "arguments": args}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Close disconnects from the server, stopping it if it runs over stdio.
+func (c *Client) Close() error {
+	return c.conn.close()
+}
+
+// OnNotification sets f to be called with the method of each notification
+// from the server, such as notifications/tools/list_changed. f runs on its
+// own goroutine, so it may make requests to the server. A nil f ignores
+// notifications.
+func (c *Client) OnNotification(f func(method string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onNotification = f
+}
+
+func (c *Client) notified(method string) {
+	c.mu.Lock()
+	f := c.onNotification
+	if f != nil {
+		if c.handling == 0 {
+			c.idle = make(chan struct{})
+		}
+		c.handling++
+	}
+	c.mu.Unlock()
+	if f == nil {
+		return
+	}
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			if c.handling--; c.handling == 0 {
+				close(c.idle)
+			}
+			c.mu.Unlock()
+		}()
+		f(method)
+	}()
+}
+
+// Settle waits until the notifications received so far have been handled,
+// such as the tools listed again after the server announced a change, or
+// until ctx is done.
+func (c *Client) Settle(ctx context.Context) error {
+	c.mu.Lock()
+	idle := c.idle
+	if c.handling == 0 {
+		idle = nil
+	}
+	c.mu.Unlock()
+	if idle == nil {
+		return nil
+	}
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Client) call(ctx context.Context, method string, params, result any) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	id := c.nextID.Add(1)
+	msg, err := c.conn.call(ctx, &request{JSONRPC: "2.0", ID: &id, Method: method, Params: params})
+	if err != nil && ctx.Err() != nil && method != "initialize" {
+		// Tell the server to stop working on a request nobody waits for
+		// anymore. The handshake cannot be cancelled.
+		c.cancelled(id, ctx.Err())
+	}
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("%s: timed out after %v", method, c.timeout)
+		}
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	if msg.Error != nil {
+		return fmt.Errorf("%s: %w", method, msg.Error)
+	}
+	if err := json.Unmarshal(msg.Result, result); err != nil {
+		return fmt.Errorf("%s: invalid result: %w", method, err)
+	}
+	return nil
+}
+
+// cancelled notifies the server that the request with the given ID was
+// abandoned because of err.
+func (c *Client) cancelled(id int64, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cancelTimeout)
+	defer cancel()
+	c.conn.notify(ctx, &request{JSONRPC: "2.0", Method: "notifications/cancelled", Params: map[string]any{
+		"requestId": id,
+		"reason":    err.Error(),
+	}})
+}
+
+// stdioConn exchanges newline-delimited JSON-RPC messages with a stdio
+// server. A reader goroutine routes responses to the waiting calls and
+// notifications to notified.
+type stdioConn struct {
+	t        *StdioTransport
+	notified func(method string)
+
+	// writeMu keeps messages whole.
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[string]chan *message
+	readErr error
+	done    chan struct{}
+}
+
+func newStdioConn(t *StdioTransport, notified func(method string)) *stdioConn {
+	c := &stdioConn{
+		t:        t,
+		notified: notified,
+		pending:  map[string]chan *message{},
+		done:     make(chan struct{}),
+	}
+	go c.read()
+	return c
+}
+
+func (c *stdioConn) read() {
+	scanner := bufio.NewScanner(c.t.Stdout)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var msg message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			// Servers sometimes print to stdout by mistake; skip it.
+			continue
+		}
+		switch {
+		case msg.Method == "ping" && msg.ID != nil:
+			// Servers may ping the client to check it is still there.
+			c.reply(*msg.ID, struct{}{}, nil)
+		case msg.Method != "" && msg.ID != nil:
+			// A request from the server, such as sampling, which this
+			// client does not offer.
+			c.reply(*msg.ID, nil, &RPCError{Code: -32601, Message: "method not found: " + msg.Method})
+		case msg.ID != nil:
+			c.mu.Lock()
+			ch := c.pending[string(*msg.ID)]
+			delete(c.pending, string(*msg.ID))
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- &msg
+			}
+		case msg.Method != "":
+			c.notified(msg.Method)
+		}
+	}
+
+	c.mu.Lock()
+	c.readErr = scanner.Err()
+	if c.readErr == nil {
+		c.readErr = errors.New("server closed the connection")
+	}
+	c.mu.Unlock()
+	close(c.done)
+}
+
+// reply answers a request of the server with either result or rpcErr. It
+// does not wait for the write, so that a server busy writing to the client
+// cannot block reading.
+func (c *stdioConn) reply(id json.RawMessage, result any, rpcErr *RPCError) {
+	resp := map[string]any{"jsonrpc": "2.0", "id": id}
+	if rpcErr != nil {
+		resp["error"] = rpcErr
+	} else {
+		resp["result"] = result
+	}
+	go c.write(context.Background(), resp)
+}
+
+func (c *stdioConn) call(ctx context.Context, req *request) (*message, error) {
+	key := fmt.Sprint(*req.ID)
+	ch := make(chan *message, 1)
+	c.mu.Lock()
+	c.pending[key] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, key)
+		c.mu.Unlock()
+	}()
+
+	if err := c.notify(ctx, req); err != nil {
+		return nil, err
+	}
+	select {
+	case msg := <-ch:
+		return msg, nil
+	case <-c.done:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return nil, c.readErr
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *stdioConn) notify(ctx context.Context, req *request) error {
+	return c.write(ctx, req)
+}
+
+// write sends a message on its own line. It gives up when ctx is done, as
+// a server that stopped reading its stdin would block it forever; the
+// message is then still written if the server reads again.
+func (c *stdioConn) write(ctx context.Context, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	errc := make(chan error, 1)
+	go func() {
+		c.writeMu.Lock()
+		defer c.writeMu.Unlock()
+		_, err := c.t.Stdin.Write(append(data, '\n'))
+		errc <- err
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *stdioConn) close() error {
+	return c.t.Close()
+}
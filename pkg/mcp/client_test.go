@@ -0,0 +1,517 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+// TestMain lets the test binary act as a stdio MCP server for the tests.
+func TestMain(m *testing.M) {
+	if os.Getenv("MCP_TEST_SERVER") == "1" {
+		fmt.Fprintln(os.Stderr, "fake server ready")
+		scanner := bufio.NewScanner(os.Stdin)
+		enc := json.NewEncoder(os.Stdout)
+		for scanner.Scan() {
+			if resp := handleFake(scanner.Bytes()); resp != nil {
+				// Interleave a server request to check the client skips it,
+				// and a notification to check it is passed on.
+				enc.Encode(map[string]any{"jsonrpc": "2.0", "id": "srv-1", "method": "sampling/createMessage"})
+				enc.Encode(map[string]any{"jsonrpc": "2.0", "method": toolsListChanged})
+				enc.Encode(resp)
+			}
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// handleFake answers a JSON-RPC message as a server with a single echo tool.
+// Notifications and server replies get no response.
+func handleFake(data []byte) map[string]any {
+	var req struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+		Params struct {
+			Name      string         `json:"name"`
+			Arguments map[string]any `json:"arguments"`
+			Cursor    string         `json:"cursor"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil || req.ID == nil || req.Method == "" {
+		return nil
+	}
+
+	resp := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+	switch req.Method {
+	case "initialize":
+		resp["result"] = map[string]any{
+			"protocolVersion": protocolVersion,
+			"serverInfo":      map[string]string{"name": "fake", "version": "1.2.3"},
+			"capabilities":    map[string]any{"tools": map[string]any{"listChanged": true}},
+		}
+	case "tools/list":
+		// Two pages, to exercise pagination.
+		if req.Params.Cursor == "" {
+			resp["result"] = map[string]any{"tools": []Tool{{Name: "echo", Description: "Echoes its input"}}, "nextCursor": "2"}
+		} else {
+			resp["result"] = map[string]any{"tools": []Tool{{Name: "fail"}}}
+		}
+	case "tools/call":
+		if req.Params.Name == "fail" {
+			resp["result"] = CallToolResult{IsError: true, Content: []Content{{Type: "text", Text: "boom"}}}
+		} else {
+			resp["result"] = CallToolResult{Content: []Content{{Type: "text", Text: fmt.Sprint(req.Params.Arguments["message"])}}}
+		}
+	default:
+		resp["error"] = RPCError{Code: -32601, Message: "method not found"}
+	}
+	return resp
+}
+
+func testClient(t *testing.T, c *Client) {
+	t.Helper()
+	ctx := context.Background()
+	if c.ServerInfo.Name != "fake" || c.ServerInfo.Version != "1.2.3" {
+		t.Errorf("ServerInfo = %+v, want fake 1.2.3", c.ServerInfo)
+	}
+	if c.Capabilities.Tools == nil || !c.Capabilities.Tools.ListChanged || c.Capabilities.Prompts != nil {
+		t.Errorf("Capabilities = %+v, want tools with listChanged", c.Capabilities)
+	}
+	notifications := make(chan string, 10)
+	c.OnNotification(func(method string) { notifications <- method })
+
+	tools, err := c.ListTools(ctx)
+	if err != nil {
+		t.Fatalf("ListTools() failed: %v", err)
+	}
+	if len(tools) != 2 || tools[0].Name != "echo" || tools[1].Name != "fail" {
+		t.Errorf("ListTools() = %+v, want echo and fail", tools)
+	}
+
+	result, err := c.CallTool(ctx, "echo", map[string]any{"message": "hi"})
+	if err != nil {
+		t.Fatalf("CallTool() failed: %v", err)
+	}
+	if result.IsError || len(result.Content) != 1 || result.Content[0].Text != "hi" {
+		t.Errorf("CallTool(echo) = %+v, want the echoed message", result)
+	}
+
+	result, err = c.CallTool(ctx, "fail", nil)
+	if err != nil {
+		t.Fatalf("CallTool() failed: %v", err)
+	}
+	if !result.IsError {
+		t.Errorf("CallTool(fail) = %+v, want a tool error", result)
+	}
+
+	if err := c.call(ctx, "resources/list", nil, &struct{}{}); err == nil {
+		t.Error("expected an error for an unsupported method")
+	}
+
+	select {
+	case method := <-notifications:
+		if method != toolsListChanged {
+			t.Errorf("notification = %q, want %q", method, toolsListChanged)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected the server's notifications to be passed on")
+	}
+}
+
+func TestStdioClient(t *testing.T) {
+	restore := config.SetUserHomeDirForTesting(t.TempDir(), nil)
+	defer restore()
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Connect(context.Background(), "fake", config.MCPServer{
+		Command: exe,
+		Env:     map[string]string{"MCP_TEST_SERVER": "1"},
+	})
+	if err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+	testClient(t, c)
+	if err := c.Close(); err != nil {
+		t.Errorf("Close() failed: %v", err)
+	}
+
+	lines, err := Tail("fake", 10)
+	if err != nil || len(lines) < 2 || lines[1] != "fake server ready" {
+		t.Errorf("log = %q, %v; want the server's stderr", lines, err)
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	var deleted bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			deleted = r.Header.Get("Mcp-Session-Id") == "session-1"
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		resp := handleFake(body)
+		if resp == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Mcp-Session-Id", "session-1")
+		data, _ := json.Marshal(resp)
+		if r.Header.Get("Mcp-Session-Id") == "" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(data)
+			return
+		}
+		// Once the session is established, answer as an event stream with
+		// a notification first.
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":%q}\n\n", toolsListChanged)
+		fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+	}))
+	defer srv.Close()
+
+	c, err := Connect(context.Background(), "fake", config.MCPServer{
+		HTTPURL: srv.URL,
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	})
+	if err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+	testClient(t, c)
+	if err := c.Close(); err != nil {
+		t.Errorf("Close() failed: %v", err)
+	}
+	if !deleted {
+		t.Error("expected the session to be deleted on Close")
+	}
+}
+
+// TestHTTPSession checks that the client listens for the server's own
+// messages, answers its pings and starts a new session when the server
+// ends the old one.
+func TestHTTPSession(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		sessions    = map[string]bool{}
+		initialized int
+		versions    []string
+		pinged      = make(chan struct{})
+		announce    = make(chan struct{})
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		known := sessions[r.Header.Get("Mcp-Session-Id")]
+		mu.Unlock()
+		if r.Method == http.MethodGet {
+			if !known {
+				http.Error(w, "unknown session", http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.(http.Flusher).Flush()
+			select {
+			case <-announce:
+				fmt.Fprintf(w, "data: {\"jsonrpc\":\"2.0\",\"method\":%q}\n\n", toolsListChanged)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+			<-r.Context().Done()
+			return
+		}
+		if r.Method == http.MethodDelete {
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), `"id":"srv-1"`) {
+			close(pinged)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		resp := handleFake(body)
+		isInitialize := strings.Contains(string(body), `"method":"initialize"`)
+		if !isInitialize && !known {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+		if resp == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		data, _ := json.Marshal(resp)
+		if isInitialize {
+			mu.Lock()
+			initialized++
+			id := fmt.Sprintf("session-%d", initialized)
+			sessions[id] = true
+			mu.Unlock()
+			w.Header().Set("Mcp-Session-Id", id)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(data)
+			return
+		}
+		mu.Lock()
+		versions = append(versions, r.Header.Get("Mcp-Protocol-Version"))
+		first := initialized == 1
+		mu.Unlock()
+		// The server pings the client before it first answers a call.
+		w.Header().Set("Content-Type", "text/event-stream")
+		if first && strings.Contains(string(body), `"tools/call"`) {
+			fmt.Fprint(w, "data: {\"jsonrpc\":\"2.0\",\"id\":\"srv-1\",\"method\":\"ping\"}\n\n")
+		}
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}))
+	defer srv.Close()
+
+	c, err := Connect(context.Background(), "fake", config.MCPServer{HTTPURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+	defer c.Close()
+	notifications := make(chan string, 10)
+	c.OnNotification(func(method string) { notifications <- method })
+
+	close(announce)
+	select {
+	case method := <-notifications:
+		if method != toolsListChanged {
+			t.Errorf("notification = %q, want %q", method, toolsListChanged)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected the notification on the event stream to be passed on")
+	}
+
+	if _, err := c.CallTool(context.Background(), "echo", map[string]any{"message": "hi"}); err != nil {
+		t.Fatalf("CallTool() failed: %v", err)
+	}
+	select {
+	case <-pinged:
+	case <-time.After(5 * time.Second):
+		t.Error("expected the ping to be answered")
+	}
+
+	// The server forgets the session.
+	mu.Lock()
+	clear(sessions)
+	mu.Unlock()
+	result, err := c.CallTool(context.Background(), "echo", map[string]any{"message": "again"})
+	if err != nil || result.Content[0].Text != "again" {
+		t.Errorf("CallTool() in a new session = %+v, %v; want the echoed message", result, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if initialized != 2 {
+		t.Errorf("initialized %d sessions, want 2", initialized)
+	}
+	for _, v := range versions {
+		if v != protocolVersion {
+			t.Errorf("Mcp-Protocol-Version = %q, want %q after the handshake", v, protocolVersion)
+		}
+	}
+}
+
+func TestSSEClient(t *testing.T) {
+	restore := config.SetUserHomeDirForTesting(t.TempDir(), nil)
+	defer restore()
+
+	var (
+		mu sync.Mutex
+		// sessions are the events to send on the stream of each session.
+		sessions    = map[string]chan []byte{}
+		streams     int
+		initialized int
+		pinged      bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodGet {
+			mu.Lock()
+			streams++
+			id := fmt.Sprint(streams)
+			events := make(chan []byte, 10)
+			sessions[id] = events
+			mu.Unlock()
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "event: endpoint\ndata: /messages?session=%s\n\n", id)
+			w.(http.Flusher).Flush()
+			for {
+				select {
+				case data, ok := <-events:
+					if !ok {
+						return
+					}
+					fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+					w.(http.Flusher).Flush()
+				case <-r.Context().Done():
+					return
+				}
+			}
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		events := sessions[r.URL.Query().Get("session")]
+		if events == nil {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		if strings.Contains(string(body), `"id":"srv-1"`) {
+			pinged = true
+		}
+		resp := handleFake(body)
+		if resp == nil {
+			return
+		}
+		if resp["result"] != nil && strings.Contains(string(body), `"initialize"`) {
+			initialized++
+			events <- []byte(`{"jsonrpc":"2.0","id":"srv-1","method":"ping"}`)
+		}
+		data, _ := json.Marshal(resp)
+		events <- []byte(fmt.Sprintf(`{"jsonrpc":"2.0","method":%q}`, toolsListChanged))
+		events <- data
+	}))
+	defer srv.Close()
+
+	c, err := Connect(context.Background(), "fake", config.MCPServer{
+		URL:     srv.URL + "/sse",
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	})
+	if err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+	testClient(t, c)
+
+	// The stream ends: the client opens another, in which it initializes
+	// the new session before calling the tool.
+	mu.Lock()
+	close(sessions["1"])
+	delete(sessions, "1")
+	mu.Unlock()
+	result, err := c.CallTool(context.Background(), "echo", map[string]any{"message": "again"})
+	if err != nil || len(result.Content) != 1 || result.Content[0].Text != "again" {
+		t.Errorf("CallTool() after reconnecting = %+v, %v; want the echoed message", result, err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("Close() failed: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if streams != 2 || initialized != 2 || !pinged {
+		t.Errorf("streams = %d, initialized = %d, pinged = %v; want 2 sessions initialized and the ping answered", streams, initialized, pinged)
+	}
+
+	_, err = Connect(context.Background(), "fake", config.MCPServer{URL: srv.URL + "/sse"})
+	if err == nil || !strings.Contains(err.Error(), "401 Unauthorized") {
+		t.Errorf("Connect() without the token: error = %v, want the server's refusal", err)
+	}
+}
+
+func TestConnectErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices the client hanging up once the body
+		// is read.
+		io.ReadAll(r.Body)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	_, err := Connect(context.Background(), "slow", config.MCPServer{HTTPURL: srv.URL, StartupTimeout: 50})
+	if err == nil || !strings.Contains(err.Error(), "no response within 50ms (see mcpServers.slow.startupTimeout)") {
+		t.Errorf("Connect() error = %v, want the startup timeout", err)
+	}
+
+	_, err = Connect(context.Background(), "remote", config.MCPServer{
+		HTTPURL: srv.URL,
+		Headers: map[string]string{"Authorization": "Bearer $MCP_TEST_UNSET_TOKEN"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "mcpServers.remote.headers.Authorization: $MCP_TEST_UNSET_TOKEN is not set") {
+		t.Errorf("Connect() error = %v, want the unset variable", err)
+	}
+
+	_, err = Connect(context.Background(), "local", config.MCPServer{Command: "true", Cwd: filepath.Join(t.TempDir(), "missing")})
+	if err == nil || !strings.Contains(err.Error(), "mcpServers.local.cwd:") {
+		t.Errorf("Connect() error = %v, want the missing cwd", err)
+	}
+}
+
+// TestStdioServerRequests checks that the client answers pings of the
+// server, refuses the requests it does not offer and tells the server about
+// the calls it abandons.
+func TestStdioServerRequests(t *testing.T) {
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	defer serverOut.Close()
+	defer clientOut.Close()
+	c := &Client{Name: "fake", timeout: time.Minute}
+	c.conn = newStdioConn(&StdioTransport{Stdin: clientOut, Stdout: clientIn}, c.notified)
+
+	enc := json.NewEncoder(serverOut)
+	replies := bufio.NewScanner(serverIn)
+	next := func() map[string]any {
+		t.Helper()
+		if !replies.Scan() {
+			t.Fatalf("the client wrote nothing: %v", replies.Err())
+		}
+		var msg map[string]any
+		if err := json.Unmarshal(replies.Bytes(), &msg); err != nil {
+			t.Fatalf("invalid message %q: %v", replies.Bytes(), err)
+		}
+		return msg
+	}
+
+	go enc.Encode(map[string]any{"jsonrpc": "2.0", "id": 7, "method": "ping"})
+	if msg := next(); msg["id"] != 7.0 || msg["result"] == nil || msg["error"] != nil {
+		t.Errorf("reply to ping = %v, want an empty result", msg)
+	}
+	go enc.Encode(map[string]any{"jsonrpc": "2.0", "id": 8, "method": "sampling/createMessage"})
+	if msg := next(); msg["id"] != 8.0 || msg["error"] == nil {
+		t.Errorf("reply to sampling = %v, want an error", msg)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- c.call(ctx, "tools/call", map[string]any{"name": "slow"}, &struct{}{}) }()
+	req := next()
+	cancel()
+	// The notification is read first: the pipe does not buffer it.
+	msg := next()
+	params, _ := msg["params"].(map[string]any)
+	if msg["method"] != "notifications/cancelled" || params["requestId"] != req["id"] {
+		t.Errorf("after cancelling, the client sent %v, want notifications/cancelled for %v", msg, req["id"])
+	}
+	if err := <-errc; err == nil {
+		t.Error("expected a cancelled call to fail")
+	}
+
+	// A server that stops reading must not block the client.
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.conn.notify(ctx, &request{JSONRPC: "2.0", Method: "notifications/initialized"}); err == nil {
+		t.Error("expected a write nobody reads to give up")
+	}
+	serverIn.Close()
+}
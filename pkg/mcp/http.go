@@ -0,0 +1,407 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errNoStream is the refusal of a server to open an event stream for the
+// messages it sends on its own.
+var errNoStream = errors.New("the server offers no event stream")
+
+// httpConn sends JSON-RPC messages to a server using the streamable HTTP
+// transport: each message is POSTed, and the response arrives either as a
+// JSON body or as a stream of server-sent events. Once initialized, the
+// client also listens on the event stream a GET opens, if the server offers
+// one, for the notifications and requests the server sends on its own.
+// Notifications are passed to notified, and pings answered.
+//
+// A server that no longer knows the session, such as after restarting,
+// refuses its messages; the handshake is then sent again in a new session
+// and the message sent once more.
+type httpConn struct {
+	url      string
+	headers  map[string]string
+	client   *http.Client
+	notified func(method string)
+
+	// ctx ends the event stream when the connection is closed.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// renewing serializes starting new sessions.
+	renewing sync.Mutex
+
+	mu        sync.Mutex
+	sessionID string
+	// version is the protocol version the messages after the handshake
+	// carry.
+	version string
+	// handshake are the initialize request and initialized notification
+	// the client sent, sent again in new sessions.
+	handshake []*request
+	listening bool
+}
+
+func newHTTPConn(url string, headers map[string]string, client *http.Client, notified func(method string)) *httpConn {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &httpConn{url: url, headers: headers, client: client, notified: notified, ctx: ctx, cancel: cancel}
+}
+
+func (c *httpConn) call(ctx context.Context, req *request) (*message, error) {
+	if req.Method == "initialize" {
+		c.mu.Lock()
+		c.handshake = []*request{req}
+		c.mu.Unlock()
+	}
+	resp, err := c.postRenewing(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := c.response(resp, req)
+	if err == nil && req.Method == "initialize" {
+		c.mu.Lock()
+		c.version = protocolVersion
+		c.mu.Unlock()
+	}
+	return msg, err
+}
+
+// response reads the response to req from resp, which it closes.
+func (c *httpConn) response(resp *http.Response, req *request) (*message, error) {
+	defer resp.Body.Close()
+	id := fmt.Sprint(*req.ID)
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		var msg message
+		if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+			return nil, fmt.Errorf("invalid response: %w", err)
+		}
+		return &msg, nil
+	case "text/event-stream":
+		return c.readEvents(resp.Body, id)
+	default:
+		return nil, fmt.Errorf("unexpected response content type %q", mediaType)
+	}
+}
+
+func (c *httpConn) notify(ctx context.Context, req *request) error {
+	initialized := req.Method == "notifications/initialized"
+	if initialized {
+		c.mu.Lock()
+		c.handshake = append(c.handshake, req)
+		c.mu.Unlock()
+	}
+	resp, err := c.postRenewing(ctx, req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if initialized {
+		c.mu.Lock()
+		start := !c.listening
+		c.listening = true
+		c.mu.Unlock()
+		if start {
+			go c.listen()
+		}
+	}
+	return nil
+}
+
+// postRenewing posts req, in a new session if the server ended the one it
+// was sent in.
+func (c *httpConn) postRenewing(ctx context.Context, req *request) (*http.Response, error) {
+	c.mu.Lock()
+	sessionID := c.sessionID
+	c.mu.Unlock()
+	resp, err := c.post(ctx, req)
+	if !errors.Is(err, errSessionGone) || req.Method == "initialize" {
+		return resp, err
+	}
+	if err := c.renew(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	return c.post(ctx, req)
+}
+
+// renew starts a new session, unless another call already replaced the
+// stale one, by sending the handshake again.
+func (c *httpConn) renew(ctx context.Context, stale string) error {
+	c.renewing.Lock()
+	defer c.renewing.Unlock()
+	c.mu.Lock()
+	if c.sessionID != stale {
+		c.mu.Unlock()
+		return nil
+	}
+	c.sessionID = ""
+	handshake := c.handshake
+	c.mu.Unlock()
+	for _, req := range handshake {
+		resp, err := c.post(ctx, req)
+		if err == nil && req.ID != nil {
+			var msg *message
+			if msg, err = c.response(resp, req); err == nil && msg.Error != nil {
+				err = msg.Error
+			}
+		} else if err == nil {
+			resp.Body.Close()
+		}
+		if err != nil {
+			return fmt.Errorf("the server ended the session, and starting a new one failed: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *httpConn) post(ctx context.Context, req *request) (*http.Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json, text/event-stream")
+	session := c.setHeaders(httpReq)
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound && session {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: server returned %s", errSessionGone, resp.Status)
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		return nil, refused(resp)
+	}
+	if id := resp.Header.Get("Mcp-Session-Id"); id != "" {
+		c.mu.Lock()
+		c.sessionID = id
+		c.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// setHeaders sets the configured headers on req, and those of the session,
+// and reports whether it is sent in one.
+func (c *httpConn) setHeaders(req *http.Request) bool {
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.version != "" {
+		req.Header.Set("Mcp-Protocol-Version", c.version)
+	}
+	if c.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", c.sessionID)
+	}
+	return c.sessionID != ""
+}
+
+// listen keeps the event stream of the server open, opening it again with
+// backoff when it ends, until the connection is closed or the server turns
+// out to offer none.
+func (c *httpConn) listen() {
+	backoff := minBackoff
+	for {
+		opened, err := c.stream()
+		if errors.Is(err, errNoStream) || c.ctx.Err() != nil {
+			return
+		}
+		if opened {
+			backoff = minBackoff
+		}
+		select {
+		case <-time.After(backoff):
+		case <-c.ctx.Done():
+			return
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// stream opens the event stream of the server and handles its messages
+// until it ends, reporting whether it opened.
+func (c *httpConn) stream() (bool, error) {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	c.setHeaders(req)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusMethodNotAllowed {
+		return false, errNoStream
+	}
+	if resp.StatusCode/100 != 2 {
+		return false, fmt.Errorf("server returned %s", resp.Status)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		return false, errNoStream
+	}
+	return true, scanEvents(resp.Body, func(e event) bool {
+		var msg message
+		if err := json.Unmarshal([]byte(e.data), &msg); err == nil {
+			c.received(&msg)
+		}
+		return true
+	})
+}
+
+// received handles a message the server sent on its own: notifications
+// are passed to notified, pings answered and other requests refused, as a
+// stdio server's are. It reports whether msg was one.
+func (c *httpConn) received(msg *message) bool {
+	switch {
+	case msg.Method == "ping" && msg.ID != nil:
+		c.reply(*msg.ID, struct{}{}, nil)
+	case msg.Method != "" && msg.ID != nil:
+		c.reply(*msg.ID, nil, &RPCError{Code: -32601, Message: "method not found: " + msg.Method})
+	case msg.Method != "":
+		c.notified(msg.Method)
+	default:
+		return false
+	}
+	return true
+}
+
+// reply answers a request of the server with either result or rpcErr,
+// without waiting for it to be sent.
+func (c *httpConn) reply(id json.RawMessage, result any, rpcErr *RPCError) {
+	resp := map[string]any{"jsonrpc": "2.0", "id": id}
+	if rpcErr != nil {
+		resp["error"] = rpcErr
+	} else {
+		resp["result"] = result
+	}
+	go func() {
+		body, err := json.Marshal(resp)
+		if err != nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.ctx, cancelTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+		if err != nil {
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json, text/event-stream")
+		c.setHeaders(req)
+		if resp, err := c.client.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}()
+}
+
+// close ends the event stream and the session, if the server started one.
+func (c *httpConn) close() error {
+	c.cancel()
+	c.mu.Lock()
+	sessionID := c.sessionID
+	c.mu.Unlock()
+	if sessionID == "" {
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodDelete, c.url, nil)
+	if err != nil {
+		return err
+	}
+	c.setHeaders(req)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// readEvents reads the server-sent events answering a request until the
+// response with the given ID. The server's other messages are handled as
+// on the event stream.
+func (c *httpConn) readEvents(r io.Reader, id string) (*message, error) {
+	var resp *message
+	err := scanEvents(r, func(e event) bool {
+		var msg message
+		if err := json.Unmarshal([]byte(e.data), &msg); err != nil {
+			return true
+		}
+		if c.received(&msg) {
+			return true
+		}
+		if msg.ID != nil && string(*msg.ID) == id {
+			resp = &msg
+			return false
+		}
+		return true
+	})
+	if resp != nil {
+		return resp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("event stream ended without a response")
+}
+
+// event is a server-sent event. name is empty for unnamed events.
+type event struct {
+	name, id, data string
+}
+
+// scanEvents reads server-sent events from r and passes them to handle
+// until it returns false or the stream ends, which is no error.
+func scanEvents(r io.Reader, handle func(event) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	var (
+		e    event
+		data []string
+	)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if len(data) > 0 {
+				e.data = strings.Join(data, "\n")
+				if !handle(e) {
+					return nil
+				}
+			}
+			e, data = event{}, nil
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "data":
+			data = append(data, value)
+		case "event":
+			e.name = value
+		case "id":
+			e.id = value
+		}
+	}
+	return scanner.Err()
+}
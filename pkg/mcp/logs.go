@@ -0,0 +1,215 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+const (
+	// maxLogSize is the size at which a server log is rotated.
+	maxLogSize = 1 << 20
+	// maxLogBackups is the number of rotated logs kept per server, as
+	// <name>.log.1 (newest) through <name>.log.<maxLogBackups>.
+	maxLogBackups = 3
+	// followInterval is how often Follow polls the log for new output.
+	followInterval = 250 * time.Millisecond
+)
+
+// ErrNoLogs is returned by Tail for a server that has not logged anything.
+var ErrNoLogs = errors.New("no logs for MCP server")
+
+// LogPath returns the path of the log file capturing the stderr of the named
+// server.
+func LogPath(name string) (string, error) {
+	dir, err := config.LogDir()
+	if err != nil {
+		return "", err
+	}
+	// Server names come from settings; keep them from escaping the log dir.
+	safe := strings.NewReplacer("/", "_", `\`, "_", "..", "_").Replace(name)
+	return filepath.Join(dir, "mcp", safe+".log"), nil
+}
+
+// rotatingLog is an io.Writer appending to a log file that is rotated once it
+// grows past maxLogSize.
+type rotatingLog struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	size int64
+}
+
+func openLog(name string) (*rotatingLog, error) {
+	path, err := LogPath(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	l := &rotatingLog{path: path}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *rotatingLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f, l.size = f, info.Size()
+	return nil
+}
+
+func (l *rotatingLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f == nil {
+		return 0, os.ErrClosed
+	}
+	if l.size > 0 && l.size+int64(len(p)) > maxLogSize {
+		if err := l.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := l.f.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// rotate shifts <name>.log.N to <name>.log.N+1, dropping the oldest, and
+// starts a new log.
+func (l *rotatingLog) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+	for i := maxLogBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+	}
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		return err
+	}
+	return l.open()
+}
+
+func (l *rotatingLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// Tail returns the last n lines logged by the named server, none if n is
+// not positive.
+func Tail(name string, n int) ([]string, error) {
+	path, err := LogPath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w %q", ErrNoLogs, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimRight(data, "\n")
+	if len(data) == 0 {
+		return nil, nil
+	}
+	lines := strings.Split(string(data), "\n")
+	return lines[min(max(len(lines)-n, 0), len(lines)):], nil
+}
+
+// Follow copies output appended to the named server's log from now on to w
+// until ctx is done, reopening the log when it is rotated.
+func Follow(ctx context.Context, name string, w io.Writer) error {
+	path, err := LogPath(name)
+	if err != nil {
+		return err
+	}
+
+	var (
+		f      *os.File
+		offset int64
+	)
+	if f, err = os.Open(path); err == nil {
+		if offset, err = f.Seek(0, io.SeekEnd); err != nil {
+			f.Close()
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+
+	ticker := time.NewTicker(followInterval)
+	defer ticker.Stop()
+	for {
+		if f == nil {
+			if f, err = os.Open(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			offset = 0
+		}
+		if f != nil {
+			info, err := os.Stat(path)
+			switch {
+			case os.IsNotExist(err):
+				// Rotated and not yet recreated.
+			case err != nil:
+				return err
+			case info.Size() < offset || !sameFile(f, info):
+				// Rotated: drain what is left of the old file, then reopen.
+				if _, err := io.Copy(w, f); err != nil {
+					return err
+				}
+				f.Close()
+				f = nil
+				continue
+			}
+			n, err := io.Copy(w, f)
+			if err != nil {
+				return err
+			}
+			offset += n
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func sameFile(f *os.File, info os.FileInfo) bool {
+	current, err := f.Stat()
+	return err == nil && os.SameFile(current, info)
+}
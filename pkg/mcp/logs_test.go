@@ -0,0 +1,147 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+func TestStdioStderrIsLogged(t *testing.T) {
+	restore := config.SetUserHomeDirForTesting(t.TempDir(), nil)
+	defer restore()
+
+	if _, err := Tail("echo", 10); !errors.Is(err, ErrNoLogs) {
+		t.Fatalf("Tail() before any output = %v, want ErrNoLogs", err)
+	}
+
+	tr, err := StartStdio("echo", config.MCPServer{
+		Command: "sh",
+		Args:    []string{"-c", `echo "starting $GREETING" >&2; cat`},
+		Env:     map[string]string{"GREETING": "hello"},
+	})
+	if err != nil {
+		t.Fatalf("StartStdio() failed: %v", err)
+	}
+	if _, err := io.WriteString(tr.Stdin, "ping\n"); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := tr.Stdin.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	out, err := io.ReadAll(tr.Stdout)
+	if err != nil || string(out) != "ping\n" {
+		t.Fatalf("stdout = %q, %v; want %q", out, err, "ping\n")
+	}
+	if err := tr.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	lines, err := Tail("echo", 10)
+	if err != nil {
+		t.Fatalf("Tail() failed: %v", err)
+	}
+	if len(lines) != 3 || lines[1] != "starting hello" || !strings.Contains(lines[2], "exited") {
+		t.Errorf("log = %q, want the start marker, the server's stderr and the exit marker", lines)
+	}
+	if lines, err := Tail("echo", -1); err != nil || len(lines) != 0 {
+		t.Errorf("Tail(-1) = %q, %v; want no lines", lines, err)
+	}
+}
+
+func TestLogRotation(t *testing.T) {
+	restore := config.SetUserHomeDirForTesting(t.TempDir(), nil)
+	defer restore()
+
+	l, err := openLog("big")
+	if err != nil {
+		t.Fatalf("openLog() failed: %v", err)
+	}
+	defer l.Close()
+
+	line := strings.Repeat("x", 1023) + "\n"
+	for i := 0; i < (maxLogBackups+2)*maxLogSize/len(line); i++ {
+		if _, err := io.WriteString(l, line); err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+	}
+
+	path, _ := LogPath("big")
+	for i := 1; i <= maxLogBackups; i++ {
+		info, err := os.Stat(fmt.Sprintf("%s.%d", path, i))
+		if err != nil {
+			t.Fatalf("missing backup %d: %v", i, err)
+		}
+		if info.Size() > maxLogSize {
+			t.Errorf("backup %d is %d bytes, want at most %d", i, info.Size(), maxLogSize)
+		}
+	}
+	if _, err := os.Stat(fmt.Sprintf("%s.%d", path, maxLogBackups+1)); !os.IsNotExist(err) {
+		t.Errorf("expected only %d backups to be kept", maxLogBackups)
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for use by Follow's goroutine.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestFollow(t *testing.T) {
+	restore := config.SetUserHomeDirForTesting(t.TempDir(), nil)
+	defer restore()
+
+	l, err := openLog("srv")
+	if err != nil {
+		t.Fatalf("openLog() failed: %v", err)
+	}
+	defer l.Close()
+	io.WriteString(l, "old\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var out syncBuffer
+	done := make(chan error)
+	go func() { done <- Follow(ctx, "srv", &out) }()
+
+	time.Sleep(2 * followInterval)
+	io.WriteString(l, "before rotation\n")
+	l.mu.Lock()
+	if err := l.rotate(); err != nil {
+		t.Fatalf("rotate() failed: %v", err)
+	}
+	l.mu.Unlock()
+	io.WriteString(l, "after rotation\n")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(out.String(), "after rotation") && time.Now().Before(deadline) {
+		time.Sleep(followInterval)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Follow() failed: %v", err)
+	}
+
+	if got, want := out.String(), "before rotation\nafter rotation\n"; got != want {
+		t.Errorf("followed output = %q, want %q", got, want)
+	}
+}
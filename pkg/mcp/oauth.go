@@ -0,0 +1,565 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// oauthTokensFileName is the file under ~/.gemini holding the tokens
+	// of the servers, by server name.
+	oauthTokensFileName = "mcp-oauth-tokens.json"
+	defaultRedirectURI  = "http://localhost:7777/oauth/callback"
+	// authorizeTimeout bounds how long the user may take to authorize in
+	// the browser.
+	authorizeTimeout = 5 * time.Minute
+	// clientName is the name the CLI registers its OAuth clients under.
+	clientName = "Gemini CLI"
+)
+
+// ErrUnauthorized is the refusal of a server to serve a client that has no
+// valid authorization.
+var ErrUnauthorized = errors.New("the MCP server requires authorization")
+
+// unauthorizedError is the 401 response of a server. resourceMetadata is
+// where the server said its protected resource metadata is, if it did.
+type unauthorizedError struct {
+	status, body     string
+	resourceMetadata string
+}
+
+func (e *unauthorizedError) Error() string {
+	return fmt.Sprintf("server returned %s: %s", e.status, e.body)
+}
+
+func (e *unauthorizedError) Unwrap() error { return ErrUnauthorized }
+
+var resourceMetadataPattern = regexp.MustCompile(`resource_metadata="([^"]*)"`)
+
+// refused returns the error for a response that is not a success, which it
+// reads the start of the body of.
+func refused(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	body := strings.TrimSpace(string(msg))
+	if resp.StatusCode == http.StatusUnauthorized {
+		e := &unauthorizedError{status: resp.Status, body: body}
+		if m := resourceMetadataPattern.FindStringSubmatch(resp.Header.Get("WWW-Authenticate")); m != nil {
+			e.resourceMetadata = m[1]
+		}
+		return e
+	}
+	return fmt.Errorf("server returned %s: %s", resp.Status, body)
+}
+
+type authorizeKey struct{}
+
+// WithAuthorization returns a context in which Connect authorizes with the
+// servers that refuse to connect for want of authorization, and connects
+// again. show is passed the name of the server and the URL of the page the
+// user authorizes the CLI on, as the page is opened in the browser.
+func WithAuthorization(ctx context.Context, show func(server, authURL string)) context.Context {
+	return context.WithValue(ctx, authorizeKey{}, show)
+}
+
+func authorizationHandoff(ctx context.Context) func(server, authURL string) {
+	show, _ := ctx.Value(authorizeKey{}).(func(server, authURL string))
+	return show
+}
+
+// storedAuth is the authorization stored for a server: the token and the
+// client it was issued to.
+type storedAuth struct {
+	// ServerURL is the URL the token is for, so that a server pointed
+	// elsewhere is not sent it.
+	ServerURL    string        `json:"serverUrl"`
+	ClientID     string        `json:"clientId"`
+	ClientSecret string        `json:"clientSecret,omitempty"`
+	TokenURL     string        `json:"tokenUrl"`
+	Token        *oauth2.Token `json:"token"`
+}
+
+var (
+	// tokensMu serializes reading and writing the tokens file.
+	tokensMu sync.Mutex
+	// authorizing serializes authorization flows, which listen on the
+	// same redirect port.
+	authorizing sync.Mutex
+	// openBrowser opens url in the user's browser.
+	openBrowser = func(url string) error {
+		var cmd *exec.Cmd
+		switch runtime.GOOS {
+		case "darwin":
+			cmd = exec.Command("open", url)
+		case "windows":
+			cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+		default:
+			cmd = exec.Command("xdg-open", url)
+		}
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		go cmd.Wait()
+		return nil
+	}
+)
+
+func tokensPath() (string, error) {
+	dir, err := config.UserDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, oauthTokensFileName), nil
+}
+
+// readTokens returns the stored authorizations, by server name. The caller
+// holds tokensMu.
+func readTokens() (map[string]*storedAuth, error) {
+	path, err := tokensPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]*storedAuth{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tokens := map[string]*storedAuth{}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return tokens, nil
+}
+
+// updateTokens changes the stored authorizations with update and writes
+// them back, readable by the user only.
+func updateTokens(update func(map[string]*storedAuth)) error {
+	tokensMu.Lock()
+	defer tokensMu.Unlock()
+	tokens, err := readTokens()
+	if err != nil {
+		return err
+	}
+	update(tokens)
+	path, err := tokensPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), oauthTokensFileName+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadAuth returns the authorization stored for the named server at
+// serverURL, or nil if there is none.
+func loadAuth(name, serverURL string) (*storedAuth, error) {
+	tokensMu.Lock()
+	defer tokensMu.Unlock()
+	tokens, err := readTokens()
+	if err != nil {
+		return nil, err
+	}
+	if a := tokens[name]; a != nil && a.ServerURL == serverURL && a.Token != nil {
+		return a, nil
+	}
+	return nil, nil
+}
+
+// Authorized reports whether a token is stored for the named remote server.
+// It may have expired, but if so it can be refreshed.
+func Authorized(name string, server config.MCPServer) bool {
+	a, err := loadAuth(name, remoteURL(server))
+	return err == nil && a != nil && (a.Token.Valid() || a.Token.RefreshToken != "")
+}
+
+// Deauthorize forgets the token stored for the named server.
+func Deauthorize(name string) error {
+	return updateTokens(func(tokens map[string]*storedAuth) { delete(tokens, name) })
+}
+
+// remoteURL returns the URL of a remote server, or "" for a stdio one.
+func remoteURL(server config.MCPServer) string {
+	if server.HTTPURL != "" {
+		return server.HTTPURL
+	}
+	return server.URL
+}
+
+// oauthTransport sends the requests to a remote server with the token
+// stored for it, if any, refreshing the token once it expires. Requests
+// that set their own Authorization header, from the headers of the
+// server, are left alone.
+type oauthTransport struct {
+	name, serverURL string
+	base            http.RoundTripper
+
+	once   sync.Once
+	mu     sync.Mutex
+	auth   *storedAuth
+	source oauth2.TokenSource
+}
+
+func newOAuthTransport(name, serverURL string) *oauthTransport {
+	return &oauthTransport{name: name, serverURL: serverURL, base: http.DefaultTransport}
+}
+
+func (t *oauthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" {
+		return t.base.RoundTrip(req)
+	}
+	if token := t.token(); token != nil {
+		req = req.Clone(req.Context())
+		token.SetAuthHeader(req)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// token returns the token to send, refreshed if it expired, or nil if
+// there is none. A token that cannot be refreshed is forgotten, so that
+// the server refuses the request and the user is asked to authorize again.
+func (t *oauthTransport) token() *oauth2.Token {
+	t.once.Do(func() {
+		a, err := loadAuth(t.name, t.serverURL)
+		if err != nil || a == nil {
+			return
+		}
+		conf := &oauth2.Config{ClientID: a.ClientID, ClientSecret: a.ClientSecret, Endpoint: tokenEndpoint(a.TokenURL, a.ClientSecret)}
+		t.auth = a
+		t.source = oauth2.ReuseTokenSource(a.Token, conf.TokenSource(context.Background(), a.Token))
+	})
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.source == nil {
+		return nil
+	}
+	token, err := t.source.Token()
+	if err != nil {
+		t.source = nil
+		Deauthorize(t.name)
+		return nil
+	}
+	if token != t.auth.Token {
+		refreshed := *t.auth
+		refreshed.Token = token
+		t.auth = &refreshed
+		updateTokens(func(tokens map[string]*storedAuth) { tokens[t.name] = &refreshed })
+	}
+	return token
+}
+
+// tokenEndpoint returns the endpoint issuing tokens at tokenURL. Public
+// clients, which have no secret, send their ID with the parameters.
+func tokenEndpoint(tokenURL, clientSecret string) oauth2.Endpoint {
+	e := oauth2.Endpoint{TokenURL: tokenURL}
+	if clientSecret == "" {
+		e.AuthStyle = oauth2.AuthStyleInParams
+	}
+	return e
+}
+
+// authServerMetadata are the endpoints of an authorization server, as its
+// RFC 8414 metadata names them.
+type authServerMetadata struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	RegistrationEndpoint  string `json:"registration_endpoint"`
+}
+
+// Authorize runs the OAuth authorization flow for the named remote server:
+// it discovers the authorization server of the server, registers a client
+// unless the settings name one, opens the authorization page in the
+// browser, passing its URL to show too, and stores the token issued once
+// the user authorized the CLI.
+func Authorize(ctx context.Context, name string, server config.MCPServer, show func(authURL string)) error {
+	server, err := server.Expand(name)
+	if err != nil {
+		return err
+	}
+	return authorize(ctx, name, server, "", show)
+}
+
+// authorize is Authorize for an expanded server, whose protected resource
+// metadata is at resourceMetadata if it said so when refusing a request.
+func authorize(ctx context.Context, name string, server config.MCPServer, resourceMetadata string, show func(authURL string)) error {
+	serverURL := remoteURL(server)
+	if serverURL == "" {
+		return fmt.Errorf("MCP server %q runs over stdio, and needs no authorization", name)
+	}
+	settings := server.OAuth
+	if settings == nil {
+		settings = &config.MCPOAuth{}
+	}
+	meta := &authServerMetadata{AuthorizationEndpoint: settings.AuthorizationURL, TokenEndpoint: settings.TokenURL}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || settings.ClientID == "" {
+		discovered, err := discover(ctx, serverURL, resourceMetadata)
+		if err != nil {
+			return err
+		}
+		if meta.AuthorizationEndpoint == "" {
+			meta.AuthorizationEndpoint = discovered.AuthorizationEndpoint
+		}
+		if meta.TokenEndpoint == "" {
+			meta.TokenEndpoint = discovered.TokenEndpoint
+		}
+		meta.RegistrationEndpoint = discovered.RegistrationEndpoint
+	}
+
+	redirectURI := settings.RedirectURI
+	if redirectURI == "" {
+		redirectURI = defaultRedirectURI
+	}
+	redirect, err := url.Parse(redirectURI)
+	if err != nil {
+		return fmt.Errorf("invalid redirect URI %q: %w", redirectURI, err)
+	}
+
+	authorizing.Lock()
+	defer authorizing.Unlock()
+
+	clientID, clientSecret := settings.ClientID, settings.ClientSecret
+	if clientID == "" {
+		// A client registered before is used again.
+		tokensMu.Lock()
+		tokens, err := readTokens()
+		tokensMu.Unlock()
+		if err != nil {
+			return err
+		}
+		if a := tokens[name]; a != nil && a.ServerURL == serverURL && a.TokenURL == meta.TokenEndpoint {
+			clientID, clientSecret = a.ClientID, a.ClientSecret
+		} else if clientID, clientSecret, err = register(ctx, meta.RegistrationEndpoint, redirectURI); err != nil {
+			return fmt.Errorf("%w; set mcpServers.%s.oauth.clientId to use a client of your own", err, name)
+		}
+	}
+
+	listener, err := net.Listen("tcp", redirect.Host)
+	if err != nil {
+		return fmt.Errorf("failed to listen for the redirect to %s: %w", redirectURI, err)
+	}
+	conf := &oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Endpoint:     tokenEndpoint(meta.TokenEndpoint, clientSecret),
+		RedirectURL:  redirectURI,
+		Scopes:       settings.Scopes,
+	}
+	conf.Endpoint.AuthURL = meta.AuthorizationEndpoint
+	verifier := oauth2.GenerateVerifier()
+	state := oauth2.GenerateVerifier()
+	// The resource parameter (RFC 8707) binds the token to the server.
+	resource := oauth2.SetAuthURLParam("resource", serverURL)
+	authURL := conf.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), resource)
+
+	codes := make(chan string, 1)
+	errs := make(chan error, 1)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != redirect.Path {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		var err error
+		switch {
+		case q.Get("state") != state:
+			err = errors.New("the authorization server returned a different state")
+		case q.Get("error") != "":
+			err = fmt.Errorf("authorization failed: %s %s", q.Get("error"), q.Get("error_description"))
+		case q.Get("code") == "":
+			err = errors.New("the authorization server returned no code")
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err != nil {
+			fmt.Fprintf(w, "<p>%s</p>", html.EscapeString(err.Error()))
+			select {
+			case errs <- err:
+			default:
+			}
+			return
+		}
+		fmt.Fprintf(w, "<p>The Gemini CLI is authorized to use %s. You can close this window.</p>", html.EscapeString(name))
+		select {
+		case codes <- q.Get("code"):
+		default:
+		}
+	})}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	if show != nil {
+		show(authURL)
+	}
+	openBrowser(authURL)
+
+	ctx, cancel := context.WithTimeout(ctx, authorizeTimeout)
+	defer cancel()
+	var code string
+	select {
+	case code = <-codes:
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("not authorized in the browser: %w", ctx.Err())
+	}
+	token, err := conf.Exchange(ctx, code, oauth2.VerifierOption(verifier), resource)
+	if err != nil {
+		return fmt.Errorf("failed to exchange the authorization code: %w", err)
+	}
+	auth := &storedAuth{ServerURL: serverURL, ClientID: clientID, ClientSecret: clientSecret, TokenURL: meta.TokenEndpoint, Token: token}
+	return updateTokens(func(tokens map[string]*storedAuth) { tokens[name] = auth })
+}
+
+// discover returns the endpoints of the authorization server of the server
+// at serverURL. The protected resource metadata (RFC 9728) of the server
+// names its authorization server; servers without any act as their own, as
+// in the 2025-03-26 protocol. The endpoints are read from the metadata of
+// the authorization server, or are its /authorize, /token and /register
+// if it has none.
+func discover(ctx context.Context, serverURL, resourceMetadata string) (*authServerMetadata, error) {
+	candidates := []string{resourceMetadata}
+	if resourceMetadata == "" {
+		candidates = wellKnown(serverURL, "oauth-protected-resource")
+	}
+	issuer := ""
+	for _, u := range candidates {
+		var resource struct {
+			AuthorizationServers []string `json:"authorization_servers"`
+		}
+		if err := getJSON(ctx, u, &resource); err == nil && len(resource.AuthorizationServers) > 0 {
+			issuer = resource.AuthorizationServers[0]
+			break
+		}
+	}
+	if issuer == "" {
+		u, err := url.Parse(serverURL)
+		if err != nil {
+			return nil, err
+		}
+		issuer = u.Scheme + "://" + u.Host
+	}
+
+	candidates = append(wellKnown(issuer, "oauth-authorization-server"), wellKnown(issuer, "openid-configuration")...)
+	for _, u := range candidates {
+		var meta authServerMetadata
+		if err := getJSON(ctx, u, &meta); err == nil && meta.AuthorizationEndpoint != "" && meta.TokenEndpoint != "" {
+			return &meta, nil
+		}
+	}
+	base := strings.TrimSuffix(issuer, "/")
+	return &authServerMetadata{
+		AuthorizationEndpoint: base + "/authorize",
+		TokenEndpoint:         base + "/token",
+		RegistrationEndpoint:  base + "/register",
+	}, nil
+}
+
+// wellKnown returns the URLs the named metadata of base may be at: under
+// /.well-known/ with the path of base appended, then at the root.
+func wellKnown(base, name string) []string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	u.RawQuery, u.Fragment = "", ""
+	var urls []string
+	if path != "" {
+		u.Path = "/.well-known/" + name + path
+		urls = append(urls, u.String())
+	}
+	u.Path = "/.well-known/" + name
+	return append(urls, u.String())
+}
+
+// getJSON decodes the JSON document at u into v.
+func getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s returned %s", u, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// register registers the CLI as a public client of the authorization
+// server (RFC 7591), returning its client ID and secret, if it got one.
+func register(ctx context.Context, endpoint, redirectURI string) (string, string, error) {
+	if endpoint == "" {
+		return "", "", errors.New("the authorization server does not register clients")
+	}
+	body, err := json.Marshal(map[string]any{
+		"client_name":                clientName,
+		"redirect_uris":              []string{redirectURI},
+		"grant_types":                []string{"authorization_code", "refresh_token"},
+		"response_types":             []string{"code"},
+		"token_endpoint_auth_method": "none",
+	})
+	if err != nil {
+		return "", "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to register a client: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", "", fmt.Errorf("failed to register a client: %w", refused(resp))
+	}
+	var client struct {
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&client); err != nil || client.ClientID == "" {
+		return "", "", fmt.Errorf("failed to register a client: invalid response")
+	}
+	return client.ClientID, client.ClientSecret, nil
+}
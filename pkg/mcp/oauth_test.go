@@ -0,0 +1,168 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+// TestOAuth checks that a server requiring authorization is authorized with
+// through discovery, client registration and the browser, and that the
+// stored token is sent and refreshed afterwards.
+func TestOAuth(t *testing.T) {
+	restore := config.SetUserHomeDirForTesting(t.TempDir(), nil)
+	defer restore()
+
+	var (
+		mu     sync.Mutex
+		grants []string
+	)
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/oauth-protected-resource/mcp":
+			fmt.Fprintf(w, `{"resource":%q,"authorization_servers":[%q]}`, srv.URL+"/mcp", srv.URL+"/auth")
+		case "/.well-known/oauth-authorization-server/auth":
+			fmt.Fprintf(w, `{"issuer":%q,"authorization_endpoint":%q,"token_endpoint":%q,"registration_endpoint":%q}`,
+				srv.URL+"/auth", srv.URL+"/auth/authorize", srv.URL+"/auth/token", srv.URL+"/auth/register")
+		case "/auth/register":
+			fmt.Fprint(w, `{"client_id":"registered"}`)
+		case "/auth/token":
+			r.ParseForm()
+			mu.Lock()
+			grants = append(grants, r.Form.Get("grant_type"))
+			mu.Unlock()
+			switch {
+			case r.Form.Get("client_id") != "registered":
+				http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			case r.Form.Get("grant_type") == "authorization_code" && (r.Form.Get("code") != "code" || r.Form.Get("code_verifier") == ""):
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			default:
+				fmt.Fprint(w, `{"access_token":"access","token_type":"Bearer","refresh_token":"refresh","expires_in":3600}`)
+			}
+		case "/mcp":
+			if r.Header.Get("Authorization") != "Bearer access" {
+				w.Header().Set("WWW-Authenticate", `Bearer resource_metadata="`+srv.URL+`/.well-known/oauth-protected-resource/mcp"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			if resp := handleFake(body); resp != nil {
+				json.NewEncoder(w).Encode(resp)
+				return
+			}
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	// The browser is the user authorizing right away.
+	var opened string
+	defer func(orig func(string) error) { openBrowser = orig }(openBrowser)
+	openBrowser = func(authURL string) error {
+		opened = authURL
+		u, err := url.Parse(authURL)
+		if err != nil {
+			return err
+		}
+		q := u.Query()
+		if q.Get("code_challenge") == "" || q.Get("resource") != srv.URL+"/mcp" || q.Get("client_id") != "registered" {
+			return fmt.Errorf("unexpected authorization URL %s", authURL)
+		}
+		go http.Get(q.Get("redirect_uri") + "?code=code&state=" + url.QueryEscape(q.Get("state")))
+		return nil
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	server := config.MCPServer{
+		HTTPURL: srv.URL + "/mcp",
+		OAuth:   &config.MCPOAuth{RedirectURI: "http://" + l.Addr().String() + "/callback"},
+	}
+
+	_, err = Connect(context.Background(), "remote", server)
+	if !errors.Is(err, ErrUnauthorized) || !strings.Contains(err.Error(), "gemini mcp auth remote") {
+		t.Fatalf("Connect() without authorization: error = %v, want ErrUnauthorized suggesting gemini mcp auth", err)
+	}
+
+	var shown string
+	ctx := WithAuthorization(context.Background(), func(name, authURL string) { shown = name + " " + authURL })
+	c, err := Connect(ctx, "remote", server)
+	if err != nil {
+		t.Fatalf("Connect() with authorization failed: %v", err)
+	}
+	if _, err := c.ListTools(context.Background()); err != nil {
+		t.Errorf("ListTools() failed: %v", err)
+	}
+	c.Close()
+	if opened == "" || shown != "remote "+opened {
+		t.Errorf("shown = %q, want the server and the URL opened in the browser (%q)", shown, opened)
+	}
+	if !Authorized("remote", server) {
+		t.Error("Authorized() = false after authorizing")
+	}
+
+	// An expired token is refreshed, and the stored one with it.
+	err = updateTokens(func(tokens map[string]*storedAuth) {
+		tokens["remote"].Token.Expiry = time.Now().Add(-time.Hour)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = Connect(context.Background(), "remote", server)
+	if err != nil {
+		t.Fatalf("Connect() with an expired token failed: %v", err)
+	}
+	c.Close()
+	mu.Lock()
+	if want := []string{"authorization_code", "refresh_token"}; strings.Join(grants, " ") != strings.Join(want, " ") {
+		t.Errorf("grants = %v, want %v", grants, want)
+	}
+	mu.Unlock()
+	if a, _ := loadAuth("remote", server.HTTPURL); a == nil || !a.Token.Expiry.After(time.Now()) {
+		t.Errorf("stored token after refreshing = %+v, want the refreshed one", a)
+	}
+
+	// The token of the server is not sent to another one of the same name.
+	moved := server
+	moved.HTTPURL = srv.URL + "/mcp?moved"
+	if Authorized("remote", moved) {
+		t.Error("Authorized() = true for a server at another URL")
+	}
+
+	if err := Deauthorize("remote"); err != nil {
+		t.Fatal(err)
+	}
+	if Authorized("remote", server) {
+		t.Error("Authorized() = true after Deauthorize")
+	}
+}
+
+func TestWellKnown(t *testing.T) {
+	got := wellKnown("https://example.com/tenant/mcp/", "oauth-protected-resource")
+	want := []string{
+		"https://example.com/.well-known/oauth-protected-resource/tenant/mcp",
+		"https://example.com/.well-known/oauth-protected-resource",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("wellKnown() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,431 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+const (
+	// nameSeparator joins a server alias and a tool name when the tool's
+	// own name is taken.
+	nameSeparator = "__"
+	// maxNameLength is the longest function name the Gemini API accepts.
+	maxNameLength = 64
+)
+
+// invalidNameChars are the characters function names cannot contain.
+var invalidNameChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// RegisteredTool is a tool of a connected server under the name the model
+// calls it by.
+type RegisteredTool struct {
+	// Name is unique across servers: the tool's own name, or
+	// <server>__<tool> if another server or a built-in tool has it too.
+	Name   string
+	Server string
+	Tool   Tool
+}
+
+// QualifiedName names the tool with its server, as server/tool, for
+// display.
+func (t RegisteredTool) QualifiedName() string {
+	return t.Server + "/" + t.Tool.Name
+}
+
+// RegisteredPrompt is a prompt of a connected server.
+type RegisteredPrompt struct {
+	Server string
+	Prompt Prompt
+}
+
+// RegisteredResource is a resource of a connected server.
+type RegisteredResource struct {
+	Server   string
+	Resource Resource
+}
+
+// Registry holds the tools of the connected servers under names unique
+// across them and routes calls to the server that owns each tool. It lists
+// a server's tools, prompts and resources again whenever the server
+// announces that they changed.
+type Registry struct {
+	reserved func(name string) bool
+
+	mu       sync.Mutex
+	servers  map[string]*registeredServer
+	tools    map[string]RegisteredTool
+	onChange func(server string, err error)
+}
+
+type registeredServer struct {
+	client    *Client
+	server    config.MCPServer
+	tools     []Tool
+	prompts   []Prompt
+	resources []Resource
+
+	// refreshing serializes listing again, so that the latest list wins.
+	refreshing sync.Mutex
+}
+
+// NewRegistry returns an empty registry. Tools named like a reserved name,
+// such as a built-in tool, are always prefixed with their server.
+func NewRegistry(reserved func(name string) bool) *Registry {
+	if reserved == nil {
+		reserved = func(string) bool { return false }
+	}
+	return &Registry{reserved: reserved, servers: map[string]*registeredServer{}, tools: map[string]RegisteredTool{}}
+}
+
+// Add lists the tools of a connected client, keeps those server's
+// includeTools and excludeTools let through, and registers them along with
+// the server's prompts and resources. Names are resolved again, so a tool
+// may be renamed when another server offering a tool of the same name is
+// added. A client of the name of a registered one replaces it, and the old
+// one is closed.
+func (r *Registry) Add(ctx context.Context, client *Client, server config.MCPServer) error {
+	s := &registeredServer{client: client, server: server}
+	tools, err := s.listTools(ctx)
+	if err != nil {
+		return err
+	}
+	prompts, err := s.listPrompts(ctx)
+	if err != nil {
+		return err
+	}
+	resources, err := s.listResources(ctx)
+	if err != nil {
+		return err
+	}
+	s.tools, s.prompts, s.resources = tools, prompts, resources
+
+	r.mu.Lock()
+	old := r.servers[client.Name]
+	r.servers[client.Name] = s
+	r.resolve()
+	r.mu.Unlock()
+	client.OnNotification(func(method string) { r.refresh(s, method) })
+	if old != nil && old.client != client {
+		old.client.OnNotification(nil)
+		old.client.Close()
+	}
+	return nil
+}
+
+func (s *registeredServer) listTools(ctx context.Context) ([]Tool, error) {
+	tools, err := s.client.ListTools(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the tools of MCP server %q: %w", s.client.Name, err)
+	}
+	return slices.DeleteFunc(tools, func(t Tool) bool {
+		if slices.Contains(s.server.ExcludeTools, t.Name) {
+			return true
+		}
+		return len(s.server.IncludeTools) > 0 && !slices.Contains(s.server.IncludeTools, t.Name)
+	}), nil
+}
+
+// listPrompts lists the prompts of the server, if it offers any.
+func (s *registeredServer) listPrompts(ctx context.Context) ([]Prompt, error) {
+	if s.client.Capabilities.Prompts == nil {
+		return nil, nil
+	}
+	prompts, err := s.client.ListPrompts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the prompts of MCP server %q: %w", s.client.Name, err)
+	}
+	return prompts, nil
+}
+
+// listResources lists the resources of the server, if it offers any.
+func (s *registeredServer) listResources(ctx context.Context) ([]Resource, error) {
+	if s.client.Capabilities.Resources == nil {
+		return nil, nil
+	}
+	resources, err := s.client.ListResources(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the resources of MCP server %q: %w", s.client.Name, err)
+	}
+	return resources, nil
+}
+
+// refresh lists the tools, prompts or resources of s again after the
+// server announced that they changed, and reports the outcome to the
+// OnChange function. If listing fails, the previous list is kept.
+func (r *Registry) refresh(s *registeredServer, method string) {
+	s.refreshing.Lock()
+	defer s.refreshing.Unlock()
+
+	// Each request is bounded by the server's timeout.
+	ctx := context.Background()
+	var update func()
+	switch method {
+	case toolsListChanged:
+		tools, err := s.listTools(ctx)
+		if err != nil {
+			r.changed(s, err)
+			return
+		}
+		update = func() { s.tools = tools }
+	case promptsListChanged:
+		prompts, err := s.listPrompts(ctx)
+		if err != nil {
+			r.changed(s, err)
+			return
+		}
+		update = func() { s.prompts = prompts }
+	case resourcesListChanged:
+		resources, err := s.listResources(ctx)
+		if err != nil {
+			r.changed(s, err)
+			return
+		}
+		update = func() { s.resources = resources }
+	default:
+		return
+	}
+
+	r.mu.Lock()
+	if r.servers[s.client.Name] != s {
+		// Removed while listing.
+		r.mu.Unlock()
+		return
+	}
+	update()
+	r.resolve()
+	r.mu.Unlock()
+	r.changed(s, nil)
+}
+
+func (r *Registry) changed(s *registeredServer, err error) {
+	r.mu.Lock()
+	f := r.onChange
+	r.mu.Unlock()
+	if f != nil {
+		f(s.client.Name, err)
+	}
+}
+
+// OnChange sets f to be called after the tools, prompts or resources of a
+// server were listed again because the server announced a change, with the
+// error if listing them failed. Tools then returns the new tools, whose
+// declarations should be sent to the model on its next turn.
+func (r *Registry) OnChange(f func(server string, err error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onChange = f
+}
+
+// Settle waits until the changes the servers announced so far are
+// reflected by Tools, Prompts and Resources, or until ctx is done, so that
+// a tool call after which the server changed its tools is followed by the
+// new ones.
+func (r *Registry) Settle(ctx context.Context) error {
+	r.mu.Lock()
+	clients := make([]*Client, 0, len(r.servers))
+	for _, s := range r.servers {
+		clients = append(clients, s.client)
+	}
+	r.mu.Unlock()
+	for _, c := range clients {
+		if err := c.Settle(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove unregisters the tools and prompts of the named server, without
+// closing it.
+func (r *Registry) Remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.servers[name]; ok {
+		s.client.OnNotification(nil)
+	}
+	delete(r.servers, name)
+	r.resolve()
+}
+
+// resolve names the tools. A tool keeps its own name unless another server
+// has a tool of the same name or the name is reserved, in which case every
+// such tool is prefixed with its server.
+func (r *Registry) resolve() {
+	aliases := make([]string, 0, len(r.servers))
+	for alias := range r.servers {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	counts := map[string]int{}
+	for _, alias := range aliases {
+		for _, t := range r.servers[alias].tools {
+			counts[functionName(t.Name)]++
+		}
+	}
+	r.tools = map[string]RegisteredTool{}
+	for _, alias := range aliases {
+		for _, t := range r.servers[alias].tools {
+			name := functionName(t.Name)
+			if counts[name] > 1 || r.reserved(name) {
+				name = functionName(alias + nameSeparator + t.Name)
+			}
+			// Sanitizing and shortening can still make names meet.
+			unique := name
+			for i := 2; r.tools[unique].Name != "" || r.reserved(unique); i++ {
+				suffix := fmt.Sprintf("_%d", i)
+				unique = name[:min(len(name), maxNameLength-len(suffix))] + suffix
+			}
+			r.tools[unique] = RegisteredTool{Name: unique, Server: alias, Tool: t}
+		}
+	}
+}
+
+// functionName makes name a valid function name: invalid characters become
+// underscores, and names over the length limit keep their start and end.
+func functionName(name string) string {
+	name = invalidNameChars.ReplaceAllString(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') || name[0] == '.' || name[0] == '-' {
+		name = "_" + name
+	}
+	if len(name) > maxNameLength {
+		half := (maxNameLength - 3) / 2
+		name = name[:half] + "___" + name[len(name)-half:]
+	}
+	return name
+}
+
+// Tools returns the registered tools sorted by name.
+func (r *Registry) Tools() []RegisteredTool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tools := make([]RegisteredTool, 0, len(r.tools))
+	for _, t := range r.tools {
+		tools = append(tools, t)
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return tools
+}
+
+// Prompts returns the prompts of the registered servers, sorted by server
+// and name.
+func (r *Registry) Prompts() []RegisteredPrompt {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var prompts []RegisteredPrompt
+	for alias, s := range r.servers {
+		for _, p := range s.prompts {
+			prompts = append(prompts, RegisteredPrompt{Server: alias, Prompt: p})
+		}
+	}
+	sort.Slice(prompts, func(i, j int) bool {
+		if prompts[i].Server != prompts[j].Server {
+			return prompts[i].Server < prompts[j].Server
+		}
+		return prompts[i].Prompt.Name < prompts[j].Prompt.Name
+	})
+	return prompts
+}
+
+// Resources returns the resources of the registered servers, sorted by
+// server and URI.
+func (r *Registry) Resources() []RegisteredResource {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var resources []RegisteredResource
+	for alias, s := range r.servers {
+		for _, res := range s.resources {
+			resources = append(resources, RegisteredResource{Server: alias, Resource: res})
+		}
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].Server != resources[j].Server {
+			return resources[i].Server < resources[j].Server
+		}
+		return resources[i].Resource.URI < resources[j].Resource.URI
+	})
+	return resources
+}
+
+// ReadResource reads the resource at uri of the server registered as
+// server.
+func (r *Registry) ReadResource(ctx context.Context, server, uri string) ([]ResourceContents, error) {
+	r.mu.Lock()
+	s, ok := r.servers[server]
+	r.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no MCP server %q", server)
+	}
+	contents, err := s.client.ReadResource(ctx, uri)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%s: %w", server, uri, err)
+	}
+	return contents, nil
+}
+
+// Lookup returns the tool registered under name.
+func (r *Registry) Lookup(name string) (RegisteredTool, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tools[name]
+	return t, ok
+}
+
+// Call calls the tool registered under name on its server, by the name
+// the server knows it by.
+func (r *Registry) Call(ctx context.Context, name string, args map[string]any) (*CallToolResult, error) {
+	r.mu.Lock()
+	t, ok := r.tools[name]
+	var client *Client
+	if ok {
+		client = r.servers[t.Server].client
+	}
+	r.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no MCP tool %q", name)
+	}
+	result, err := client.CallTool(ctx, t.Tool.Name, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", t.QualifiedName(), err)
+	}
+	return result, nil
+}
+
+// GetPrompt fills in the prompt called name of the server registered as
+// server with args.
+func (r *Registry) GetPrompt(ctx context.Context, server, name string, args map[string]string) (*GetPromptResult, error) {
+	r.mu.Lock()
+	s, ok := r.servers[server]
+	r.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no MCP server %q", server)
+	}
+	result, err := s.client.GetPrompt(ctx, name, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s/%s: %w", server, name, err)
+	}
+	return result, nil
+}
+
+// Close disconnects from every server.
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var errs []error
+	for name, s := range r.servers {
+		s.client.OnNotification(nil)
+		if err := s.client.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("MCP server %q: %w", name, err))
+		}
+	}
+	r.servers = map[string]*registeredServer{}
+	r.tools = map[string]RegisteredTool{}
+	return errors.Join(errs...)
+}
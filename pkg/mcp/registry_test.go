@@ -0,0 +1,196 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+// toolConn answers tools/list with its tools, prompts/list with its
+// prompts and resources/list with its resources, tools/call with the name
+// of the called tool, prompts/get with the name and arguments of the
+// prompt and resources/read with the URI of the resource.
+type toolConn struct {
+	tools     []string
+	prompts   []string
+	resources []string
+}
+
+func (c *toolConn) call(ctx context.Context, req *request) (*message, error) {
+	var result any
+	switch req.Method {
+	case "tools/list":
+		var tools []Tool
+		for _, name := range c.tools {
+			tools = append(tools, Tool{Name: name})
+		}
+		result = map[string]any{"tools": tools}
+	case "prompts/list":
+		var prompts []Prompt
+		for _, name := range c.prompts {
+			prompts = append(prompts, Prompt{Name: name})
+		}
+		result = map[string]any{"prompts": prompts}
+	case "resources/list":
+		var resources []Resource
+		for _, uri := range c.resources {
+			resources = append(resources, Resource{URI: uri})
+		}
+		result = map[string]any{"resources": resources}
+	case "resources/read":
+		uri := req.Params.(map[string]any)["uri"].(string)
+		result = map[string]any{"contents": []ResourceContents{{URI: uri, Text: "contents of " + uri}}}
+	case "tools/call":
+		name := req.Params.(map[string]any)["name"].(string)
+		result = CallToolResult{Content: []Content{{Type: "text", Text: name}}}
+	case "prompts/get":
+		params := req.Params.(map[string]any)
+		text := params["name"].(string)
+		for k, v := range params["arguments"].(map[string]string) {
+			text += " " + k + "=" + v
+		}
+		result = GetPromptResult{Messages: []PromptMessage{{Role: "user", Content: Content{Type: "text", Text: text}}}}
+	}
+	data, _ := json.Marshal(result)
+	return &message{Result: data}, nil
+}
+
+func (c *toolConn) notify(ctx context.Context, req *request) error { return nil }
+func (c *toolConn) close() error                                   { return nil }
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	r := NewRegistry(func(name string) bool { return name == "read_file" })
+	add := func(name string, server config.MCPServer, tools ...string) {
+		t.Helper()
+		c := &Client{Name: name, conn: &toolConn{tools: tools}, timeout: defaultTimeout}
+		if err := r.Add(ctx, c, server); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := func() string {
+		var names []string
+		for _, tool := range r.Tools() {
+			names = append(names, tool.Name+"="+tool.QualifiedName())
+		}
+		return strings.Join(names, " ")
+	}
+
+	add("github", config.MCPServer{ExcludeTools: []string{"delete_repo"}}, "search", "create_issue", "delete_repo", "read_file")
+	if got, want := names(), "create_issue=github/create_issue github__read_file=github/read_file search=github/search"; got != want {
+		t.Errorf("tools = %s, want %s", got, want)
+	}
+
+	add("gitlab.com", config.MCPServer{IncludeTools: []string{"search", "my tool!"}}, "search", "create_mr", "my tool!")
+	want := "create_issue=github/create_issue github__read_file=github/read_file github__search=github/search " +
+		"gitlab.com__search=gitlab.com/search my_tool_=gitlab.com/my tool!"
+	if got := names(); got != want {
+		t.Errorf("tools = %s, want %s", got, want)
+	}
+
+	result, err := r.Call(ctx, "gitlab.com__search", nil)
+	if err != nil || result.Content[0].Text != "search" {
+		t.Errorf("Call(gitlab.com__search) = %+v, %v; want the server's search tool", result, err)
+	}
+	if _, err := r.Call(ctx, "search", nil); err == nil {
+		t.Error("Expected the ambiguous name to be unknown")
+	}
+
+	r.Remove("gitlab.com")
+	if _, ok := r.Lookup("search"); !ok {
+		t.Error("Expected search to get its own name back once the other server is gone")
+	}
+}
+
+func TestRegistryListChanged(t *testing.T) {
+	r := NewRegistry(nil)
+	changes := make(chan string, 1)
+	r.OnChange(func(server string, err error) {
+		if err != nil {
+			t.Errorf("Listing %s again failed: %v", server, err)
+		}
+		changes <- server
+	})
+	conn := &toolConn{tools: []string{"search"}, prompts: []string{"review"}, resources: []string{"repo://readme"}}
+	c := &Client{Name: "github", conn: conn, timeout: defaultTimeout}
+	c.Capabilities.Prompts = &ListCapability{ListChanged: true}
+	c.Capabilities.Resources = &ListCapability{ListChanged: true}
+	if err := r.Add(context.Background(), c, config.MCPServer{ExcludeTools: []string{"delete_repo"}}); err != nil {
+		t.Fatal(err)
+	}
+	if prompts := r.Prompts(); len(prompts) != 1 || prompts[0].Server != "github" || prompts[0].Prompt.Name != "review" {
+		t.Errorf("Prompts() = %+v, want github's review prompt", prompts)
+	}
+	if result, err := r.GetPrompt(context.Background(), "github", "review", map[string]string{"pr": "7"}); err != nil || result.Messages[0].Content.Text != "review pr=7" {
+		t.Errorf("GetPrompt(github, review) = %+v, %v; want the filled in prompt", result, err)
+	}
+	if _, err := r.GetPrompt(context.Background(), "gitlab", "review", nil); err == nil {
+		t.Error("Expected a prompt of an unknown server to fail")
+	}
+	if resources := r.Resources(); len(resources) != 1 || resources[0].Server != "github" || resources[0].Resource.URI != "repo://readme" {
+		t.Errorf("Resources() = %+v, want github's readme", resources)
+	}
+	if contents, err := r.ReadResource(context.Background(), "github", "repo://readme"); err != nil || contents[0].Text != "contents of repo://readme" {
+		t.Errorf("ReadResource(github, repo://readme) = %+v, %v; want its contents", contents, err)
+	}
+
+	wait := func() {
+		t.Helper()
+		select {
+		case <-changes:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for the registry to change")
+		}
+	}
+	conn.tools = []string{"search", "create_issue", "delete_repo"}
+	c.notified(toolsListChanged)
+	if err := r.Settle(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if tools := r.Tools(); len(tools) != 2 {
+		t.Errorf("Tools() after Settle = %+v, want the new list", tools)
+	}
+	wait()
+	if tools := r.Tools(); len(tools) != 2 || tools[0].Name != "create_issue" || tools[1].Name != "search" {
+		t.Errorf("Tools() = %+v, want create_issue and search", tools)
+	}
+
+	conn.prompts = nil
+	c.notified(promptsListChanged)
+	wait()
+	if prompts := r.Prompts(); len(prompts) != 0 {
+		t.Errorf("Prompts() = %+v, want none", prompts)
+	}
+
+	conn.resources = append(conn.resources, "repo://license")
+	c.notified(resourcesListChanged)
+	wait()
+	if resources := r.Resources(); len(resources) != 2 || resources[0].Resource.URI != "repo://license" {
+		t.Errorf("Resources() = %+v, want the license and the readme", resources)
+	}
+
+	r.Remove("github")
+	c.notified(toolsListChanged)
+	select {
+	case <-changes:
+		t.Error("Expected notifications of removed servers to be ignored")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestFunctionName(t *testing.T) {
+	tests := []struct{ name, want string }{
+		{"get-weather", "get-weather"},
+		{"2fa/verify", "_2fa_verify"},
+		{strings.Repeat("a", 40) + strings.Repeat("b", 40), strings.Repeat("a", 30) + "___" + strings.Repeat("b", 30)},
+	}
+	for _, tt := range tests {
+		if got := functionName(tt.name); got != tt.want {
+			t.Errorf("functionName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,444 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The wait before reconnecting to an SSE server doubles from minBackoff up
+// to maxBackoff while reconnecting fails.
+var (
+	minBackoff = 500 * time.Millisecond
+	maxBackoff = 30 * time.Second
+)
+
+// errConnectionLost fails the requests whose response was due on an event
+// stream that ended.
+var errConnectionLost = errors.New("the connection to the server was lost before it responded")
+
+// errSessionGone is the refusal of a message by a server that no longer
+// knows its session, such as after restarting.
+var errSessionGone = errors.New("the server ended the session")
+
+// sseConn sends JSON-RPC messages to a server using the HTTP+SSE transport
+// of the 2024-11-05 protocol: the client keeps an event stream open, whose
+// first event names the endpoint messages are POSTed to, and the responses
+// and notifications of the server arrive as events on the stream.
+//
+// If the stream ends once established, or the server no longer knows the
+// session, the stream is opened again with backoff. The server then starts
+// a new session, so the handshake is sent again before any other request,
+// and the requests waiting for a response on the old stream fail.
+type sseConn struct {
+	name     string
+	url      string
+	headers  map[string]string
+	client   *http.Client
+	notified func(method string)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	// done is closed once the stream is given up on, for good.
+	done chan struct{}
+
+	mu sync.Mutex
+	// endpoint is where messages are POSTed in the current session, and
+	// ready is closed once requests may be.
+	endpoint string
+	ready    chan struct{}
+	pending  map[string]chan *message
+	// handshake are the initialize request and initialized notification
+	// the client sent, replayed in new sessions.
+	handshake []*request
+	// lastEventID is the ID of the last event, which a server supporting
+	// it resumes the stream after.
+	lastEventID string
+	// err is why the stream was given up on.
+	err error
+	// stopStream ends the current stream.
+	stopStream context.CancelFunc
+}
+
+func newSSEConn(name, url string, headers map[string]string, client *http.Client, notified func(method string)) *sseConn {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &sseConn{
+		name:     name,
+		url:      url,
+		headers:  headers,
+		client:   client,
+		notified: notified,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		ready:    make(chan struct{}),
+		pending:  map[string]chan *message{},
+	}
+	go c.run()
+	return c
+}
+
+// run keeps the event stream open until the connection is closed. A stream
+// that cannot be opened the first time is given up on, so that Connect
+// reports why.
+func (c *sseConn) run() {
+	backoff := minBackoff
+	established := false
+	for {
+		opened, err := c.stream(established)
+		established = established || opened
+		c.disconnected()
+		if c.ctx.Err() != nil {
+			err = errors.New("the connection is closed")
+		}
+		if c.ctx.Err() != nil || !established {
+			c.mu.Lock()
+			c.err = err
+			c.mu.Unlock()
+			close(c.done)
+			return
+		}
+		if opened {
+			backoff = minBackoff
+		}
+		c.log("event stream ended (%v), reconnecting in %v", err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-c.ctx.Done():
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// stream opens the event stream and handles its events until it ends. It
+// reports whether the server named its endpoint on it. A reconnecting
+// stream sends the handshake again in the new session.
+func (c *sseConn) stream(reconnecting bool) (bool, error) {
+	ctx, cancel := context.WithCancel(c.ctx)
+	defer cancel()
+	c.mu.Lock()
+	c.stopStream = cancel
+	c.mu.Unlock()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	c.mu.Lock()
+	if c.lastEventID != "" {
+		req.Header.Set("Last-Event-ID", c.lastEventID)
+	}
+	c.mu.Unlock()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return false, refused(resp)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		return false, fmt.Errorf("unexpected response content type %q, want an event stream", mediaType)
+	}
+
+	opened := false
+	err = scanEvents(resp.Body, func(e event) bool {
+		if e.id != "" {
+			c.mu.Lock()
+			c.lastEventID = e.id
+			c.mu.Unlock()
+		}
+		if e.name == "endpoint" {
+			endpoint, err := url.Parse(c.url)
+			if err == nil {
+				endpoint, err = endpoint.Parse(strings.TrimSpace(e.data))
+			}
+			if err != nil {
+				c.log("invalid endpoint %q: %v", e.data, err)
+				return true
+			}
+			opened = true
+			c.mu.Lock()
+			c.endpoint = endpoint.String()
+			ready := c.ready
+			c.mu.Unlock()
+			if reconnecting {
+				go c.resume(ready)
+			} else {
+				c.markReady(ready)
+			}
+			return true
+		}
+		if e.name == "" || e.name == "message" {
+			c.received([]byte(e.data))
+		}
+		return true
+	})
+	if err == nil {
+		err = errors.New("the server closed the event stream")
+	}
+	return opened, err
+}
+
+// received routes a message of the server: responses to the requests
+// waiting for them, notifications to notified. Requests of the server are
+// answered, as a stdio server's are.
+func (c *sseConn) received(data []byte) {
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return
+	}
+	switch {
+	case msg.Method == "ping" && msg.ID != nil:
+		c.reply(*msg.ID, struct{}{}, nil)
+	case msg.Method != "" && msg.ID != nil:
+		c.reply(*msg.ID, nil, &RPCError{Code: -32601, Message: "method not found: " + msg.Method})
+	case msg.ID != nil:
+		c.mu.Lock()
+		ch := c.pending[string(*msg.ID)]
+		delete(c.pending, string(*msg.ID))
+		c.mu.Unlock()
+		if ch != nil {
+			ch <- &msg
+		}
+	case msg.Method != "":
+		c.notified(msg.Method)
+	}
+}
+
+// reply answers a request of the server with either result or rpcErr,
+// without waiting for it to be sent.
+func (c *sseConn) reply(id json.RawMessage, result any, rpcErr *RPCError) {
+	resp := map[string]any{"jsonrpc": "2.0", "id": id}
+	if rpcErr != nil {
+		resp["error"] = rpcErr
+	} else {
+		resp["result"] = result
+	}
+	c.mu.Lock()
+	endpoint := c.endpoint
+	c.mu.Unlock()
+	go func() {
+		ctx, cancel := context.WithTimeout(c.ctx, cancelTimeout)
+		defer cancel()
+		c.post(ctx, endpoint, resp)
+	}()
+}
+
+// resume sends the handshake again in the session of a new stream, and
+// lets the other requests through once the server took it.
+func (c *sseConn) resume(ready chan struct{}) {
+	c.mu.Lock()
+	handshake := c.handshake
+	c.mu.Unlock()
+	ctx, cancel := context.WithTimeout(c.ctx, defaultStartupTimeout)
+	defer cancel()
+	for _, req := range handshake {
+		var err error
+		if req.ID != nil {
+			_, err = c.send(ctx, req)
+		} else {
+			err = c.postCurrent(ctx, req)
+		}
+		if err != nil {
+			// The stream is most likely gone again; the next one resumes.
+			c.log("failed to resume the session: %v", err)
+			return
+		}
+	}
+	c.log("reconnected")
+	c.markReady(ready)
+}
+
+// markReady lets requests through in the session ready was made for,
+// unless it already ended.
+func (c *sseConn) markReady(ready chan struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ready == c.ready {
+		close(ready)
+	}
+}
+
+// disconnected fails the requests waiting for a response on the stream
+// that ended, and holds back new ones until the next stream is ready.
+func (c *sseConn) disconnected() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, ch := range c.pending {
+		ch <- nil
+		delete(c.pending, id)
+	}
+	c.endpoint = ""
+	// Those waiting for the old session move on to wait for the next.
+	select {
+	case <-c.ready:
+	default:
+		close(c.ready)
+	}
+	c.ready = make(chan struct{})
+}
+
+// waitReady waits until requests may be sent in the current session.
+func (c *sseConn) waitReady(ctx context.Context) error {
+	for {
+		c.mu.Lock()
+		ready, err := c.ready, c.err
+		c.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ready:
+			c.mu.Lock()
+			current := ready == c.ready
+			c.mu.Unlock()
+			if current {
+				return nil
+			}
+		case <-c.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *sseConn) call(ctx context.Context, req *request) (*message, error) {
+	if req.Method == "initialize" {
+		c.mu.Lock()
+		c.handshake = []*request{req}
+		c.mu.Unlock()
+	}
+	if err := c.waitReady(ctx); err != nil {
+		return nil, err
+	}
+	msg, err := c.send(ctx, req)
+	if errors.Is(err, errSessionGone) && req.Method != "initialize" {
+		// The server forgot the session before the stream ended. It did
+		// not take the request, which is sent again in a new session.
+		c.restart()
+		if err := c.waitReady(ctx); err != nil {
+			return nil, err
+		}
+		return c.send(ctx, req)
+	}
+	return msg, err
+}
+
+// restart ends the current stream, so that a new one starts a new session.
+func (c *sseConn) restart() {
+	c.mu.Lock()
+	stop := c.stopStream
+	c.mu.Unlock()
+	if stop != nil {
+		stop()
+	}
+	c.disconnected()
+}
+
+// send posts req in the current session and waits for its response on the
+// stream.
+func (c *sseConn) send(ctx context.Context, req *request) (*message, error) {
+	key := fmt.Sprint(*req.ID)
+	ch := make(chan *message, 1)
+	c.mu.Lock()
+	c.pending[key] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, key)
+		c.mu.Unlock()
+	}()
+
+	if err := c.postCurrent(ctx, req); err != nil {
+		return nil, err
+	}
+	select {
+	case msg := <-ch:
+		if msg == nil {
+			return nil, errConnectionLost
+		}
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *sseConn) notify(ctx context.Context, req *request) error {
+	if req.Method == "notifications/initialized" {
+		c.mu.Lock()
+		c.handshake = append(c.handshake, req)
+		c.mu.Unlock()
+	}
+	if err := c.waitReady(ctx); err != nil {
+		return err
+	}
+	return c.postCurrent(ctx, req)
+}
+
+// postCurrent posts v to the endpoint of the current session.
+func (c *sseConn) postCurrent(ctx context.Context, v any) error {
+	c.mu.Lock()
+	endpoint := c.endpoint
+	c.mu.Unlock()
+	if endpoint == "" {
+		return errConnectionLost
+	}
+	return c.post(ctx, endpoint, v)
+}
+
+func (c *sseConn) post(ctx context.Context, endpoint string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: server returned %s", errSessionGone, resp.Status)
+	}
+	if resp.StatusCode/100 != 2 {
+		return refused(resp)
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// log notes what happened to the connection in the server's log.
+func (c *sseConn) log(format string, args ...any) {
+	l, err := openLog(c.name)
+	if err != nil {
+		return
+	}
+	defer l.Close()
+	fmt.Fprintf(l, "--- %s: %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+}
+
+func (c *sseConn) close() error {
+	c.cancel()
+	<-c.done
+	return nil
+}
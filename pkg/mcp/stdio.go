@@ -0,0 +1,116 @@
+// Package mcp connects to Model Context Protocol servers.
+package mcp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+// stopTimeout is how long a stdio server has to exit after its stdin is
+// closed before it is killed.
+const stopTimeout = 2 * time.Second
+
+// StdioTransport is a running stdio MCP server. Messages are written to its
+// stdin and read from its stdout; its stderr goes to the server's log file.
+type StdioTransport struct {
+	Stdin  io.WriteCloser
+	Stdout io.ReadCloser
+
+	cmd  *exec.Cmd
+	done chan error
+}
+
+// StartStdio starts the named stdio server. The variables its settings
+// reference must already be expanded, see config.MCPServer.Expand.
+func StartStdio(name string, server config.MCPServer) (*StdioTransport, error) {
+	if server.Command == "" {
+		return nil, fmt.Errorf("MCP server %q has no command", name)
+	}
+	if server.Cwd != "" {
+		if info, err := os.Stat(server.Cwd); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("mcpServers.%s.cwd: %s is not a directory", name, server.Cwd)
+		}
+	}
+
+	cmd := exec.Command(server.Command, server.Args...)
+	cmd.Dir = server.Cwd
+	cmd.Env = os.Environ()
+	for k, v := range server.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+
+	log, err := openLog(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log for MCP server %q: %w", name, err)
+	}
+	fmt.Fprintf(log, "--- %s: starting %s\n", time.Now().Format(time.RFC3339),
+		strings.Join(append([]string{server.Command}, server.Args...), " "))
+	cmd.Stderr = log
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		log.Close()
+		return nil, err
+	}
+	// Not cmd.StdoutPipe: Wait closes that pipe, which would race with a
+	// client still reading the server's last messages.
+	stdout, w, err := os.Pipe()
+	if err != nil {
+		log.Close()
+		return nil, err
+	}
+	cmd.Stdout = w
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		stdout.Close()
+		log.Close()
+		return nil, fmt.Errorf("failed to start MCP server %q: %w", name, err)
+	}
+
+	t := &StdioTransport{Stdin: stdin, Stdout: stdout, cmd: cmd, done: make(chan error, 1)}
+	go func() {
+		err := cmd.Wait()
+		status := "exited"
+		if err != nil {
+			status = err.Error()
+		}
+		fmt.Fprintf(log, "--- %s: %s\n", time.Now().Format(time.RFC3339), status)
+		log.Close()
+		t.done <- err
+	}()
+	return t, nil
+}
+
+// Close stops the server, killing it if it does not exit once its stdin is
+// closed.
+func (t *StdioTransport) Close() error {
+	t.Stdin.Close()
+	select {
+	case err := <-t.done:
+		t.Stdout.Close()
+		return ignoreExit(err)
+	case <-time.After(stopTimeout):
+	}
+	t.cmd.Process.Kill()
+	err := <-t.done
+	t.Stdout.Close()
+	return ignoreExit(err)
+}
+
+// ignoreExit drops the error reported for a server that exited on its own or
+// was killed, which is how servers normally stop.
+func ignoreExit(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil
+	}
+	return err
+}
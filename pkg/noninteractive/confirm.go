@@ -0,0 +1,95 @@
+package noninteractive
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/tools"
+)
+
+// confirmer returns how a run asks the user to approve changes and
+// commands: on the terminal if stdin is one, and otherwise not, so that
+// those the approval mode does not approve are refused.
+func confirmer() tools.Confirmer {
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	return promptConfirmer(os.Stdin, os.Stderr)
+}
+
+// reportApproved returns the function telling the user on out about what
+// the approval mode approved without asking.
+func reportApproved(out io.Writer, mode tools.ApprovalMode) func(string) {
+	return func(title string) {
+		fmt.Fprintf(out, "Approved by the %s approval mode: %s\n", mode, title)
+	}
+}
+
+// promptConfirmer shows the change on out and reads the answer from in.
+// Anything but y or yes declines. Actions a tools.allowed entry could
+// approve can also be always allowed, which adds the entry to the user
+// settings.
+func promptConfirmer(in io.Reader, out io.Writer) tools.Confirmer {
+	r := bufio.NewReader(in)
+	always := map[string]bool{}
+	return func(ctx context.Context, title, details string) (bool, error) {
+		rule := tools.AllowRule(ctx)
+		if always[rule] {
+			return true, nil
+		}
+		if details != "" {
+			fmt.Fprintln(out)
+			fmt.Fprint(out, details)
+			if !strings.HasSuffix(details, "\n") {
+				fmt.Fprintln(out)
+			}
+		}
+		if rule != "" {
+			fmt.Fprintf(out, "%s [y/N/a=always] ", title)
+		} else {
+			fmt.Fprintf(out, "%s [y/N] ", title)
+		}
+		line, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y", "yes":
+			return true, nil
+		case "a", "always":
+			if rule == "" {
+				return false, nil
+			}
+			always[rule] = true
+			if err := saveAllowed(rule); err != nil {
+				fmt.Fprintf(out, "Could not save %s to tools.allowed: %v\n", rule, err)
+			} else {
+				fmt.Fprintf(out, "Added %s to tools.allowed in the user settings.\n", rule)
+			}
+			return true, nil
+		}
+		return false, nil
+	}
+}
+
+// saveAllowed adds rule to tools.allowed in the user settings.
+func saveAllowed(rule string) error {
+	user, err := config.LoadScope(config.UserScope)
+	if err != nil {
+		return err
+	}
+	if user.Tools == nil {
+		user.Tools = &config.ToolsSettings{}
+	}
+	if slices.Contains(user.Tools.Allowed, rule) {
+		return nil
+	}
+	user.Tools.Allowed = append(user.Tools.Allowed, rule)
+	return config.SaveScope(config.UserScope, user)
+}
@@ -0,0 +1,155 @@
+package noninteractive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/tools"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// DryRunOutput is the request Run would send first, as --dry-run prints it
+// with --output-format json.
+type DryRunOutput struct {
+	Parameters        Parameters                   `json:"parameters"`
+	SystemInstruction []genai.Part                 `json:"systemInstruction,omitempty"`
+	Tools             []*genai.FunctionDeclaration `json:"tools,omitempty"`
+	History           []*genai.Content             `json:"history,omitempty"`
+	Prompt            []genai.Part                 `json:"prompt"`
+	Tokens            TokenCounts                  `json:"tokens"`
+}
+
+// TokenCounts are the sizes of the parts of a request. The sections are
+// estimated; Total is counted by the API unless Estimated is set.
+type TokenCounts struct {
+	SystemInstruction int32 `json:"systemInstruction"`
+	Tools             int32 `json:"tools"`
+	History           int32 `json:"history"`
+	Prompt            int32 `json:"prompt"`
+	Total             int32 `json:"total"`
+	Estimated         bool  `json:"estimated,omitempty"`
+}
+
+// DryRun prints the request Run would send first for prompt instead of
+// sending it. The total token count comes from the countTokens endpoint,
+// which generates nothing; if it cannot be reached, the total is estimated
+// too.
+func DryRun(ctx context.Context, cfg *config.Settings, model *genai.GenerativeModel, prompt []genai.Part, outputFormat string, allowed []string) error {
+	ws, err := tools.NewWorkspace(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to set up the workspace: %w", err)
+	}
+	ws.Allowed = allowed
+	if err := ws.LoadPlugins(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not load some tool plugins: %v\n", err)
+	}
+	defer ws.MCP.Close()
+	if err := ws.ConnectMCP(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not connect to some MCP servers: %v\n", err)
+	}
+	declareTools(model, ws)
+	out := composeRequest(cfg, model, prompt)
+	resp, err := model.CountTokens(ctx, out.Prompt...)
+	if err == nil {
+		out.Tokens.Total = resp.TotalTokens
+	} else {
+		fmt.Fprintf(os.Stderr, "Could not count tokens, showing an estimate: %v\n", err)
+		out.Tokens.Total = out.Tokens.SystemInstruction + out.Tokens.Tools + out.Tokens.History + out.Tokens.Prompt
+		out.Tokens.Estimated = true
+	}
+
+	if outputFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(out); err != nil {
+			return fmt.Errorf("failed to encode JSON: %w", err)
+		}
+		return nil
+	}
+	printRequest(out)
+	return nil
+}
+
+// composeRequest assembles the first request of Converse.
+func composeRequest(cfg *config.Settings, model *genai.GenerativeModel, prompt []genai.Part) *DryRunOutput {
+	out := &DryRunOutput{
+		Parameters: parameters(cfg, model),
+		History:    model.StartChat().History,
+		Prompt:     prompt,
+	}
+	if model.SystemInstruction != nil {
+		out.SystemInstruction = model.SystemInstruction.Parts
+	}
+	for _, t := range model.Tools {
+		out.Tools = append(out.Tools, t.FunctionDeclarations...)
+	}
+	out.Tokens = TokenCounts{
+		SystemInstruction: estimateTokens(out.SystemInstruction),
+		Tools:             estimateTokens(out.Tools),
+		History:           estimateTokens(out.History),
+		Prompt:            estimateTokens(out.Prompt),
+	}
+	return out
+}
+
+// estimateTokens assumes four bytes of JSON per token, which is close
+// enough for English text and code.
+func estimateTokens(v any) int32 {
+	data, _ := json.Marshal(v)
+	if string(data) == "null" || string(data) == "[]" {
+		return 0
+	}
+	return int32((len(data) + 3) / 4)
+}
+
+func printRequest(out *DryRunOutput) {
+	w := os.Stdout
+	params, _ := json.Marshal(out.Parameters)
+	fmt.Fprintf(w, "Parameters: %s\n", params)
+
+	fmt.Fprintf(w, "\nSystem instruction (~%d tokens):\n", out.Tokens.SystemInstruction)
+	printParts(out.SystemInstruction)
+
+	fmt.Fprintf(w, "\nTools (%d declarations, ~%d tokens):\n", len(out.Tools), out.Tokens.Tools)
+	for _, d := range out.Tools {
+		fmt.Fprintf(w, "  %s: %s\n", d.Name, firstLine(d.Description))
+	}
+
+	fmt.Fprintf(w, "\nHistory (%d messages, ~%d tokens):\n", len(out.History), out.Tokens.History)
+	for _, c := range out.History {
+		fmt.Fprintf(w, "  [%s]\n", c.Role)
+		printParts(c.Parts)
+	}
+
+	fmt.Fprintf(w, "\nPrompt (~%d tokens):\n", out.Tokens.Prompt)
+	printParts(out.Prompt)
+
+	if out.Tokens.Estimated {
+		fmt.Fprintf(w, "\nTotal: ~%d tokens (estimated)\n", out.Tokens.Total)
+	} else {
+		fmt.Fprintf(w, "\nTotal: %d tokens\n", out.Tokens.Total)
+	}
+}
+
+// printParts prints text parts indented and other parts as JSON.
+func printParts(parts []genai.Part) {
+	for _, p := range parts {
+		text, ok := p.(genai.Text)
+		if !ok {
+			data, _ := json.Marshal(p)
+			text = genai.Text(fmt.Sprintf("%T %s", p, data))
+		}
+		for _, line := range strings.Split(string(text), "\n") {
+			fmt.Fprintf(os.Stdout, "  %s\n", line)
+		}
+	}
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,354 @@
+package noninteractive
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/tools"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// The codes of the errors the JSON-RPC mode replies with. Those below
+// -32000 are the protocol's own.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	// codeBusy refuses a prompt sent while another runs.
+	codeBusy = -32000
+	// codeFailed reports a prompt the conversation failed to answer.
+	codeFailed = -32001
+	// codeCancelled reports a prompt cancel stopped.
+	codeCancelled = -32800
+)
+
+// rpcRequest is a request or notification a program sent.
+type rpcRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// rpcMessage is a response or notification the CLI sends.
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  any             `json:"params,omitempty"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// rpcServer runs the conversation a program drives in the JSON-RPC mode.
+type rpcServer struct {
+	ctx   context.Context
+	cfg   *config.Settings
+	ws    *tools.Workspace
+	model *genai.GenerativeModel
+
+	writeMu sync.Mutex
+	enc     *json.Encoder
+
+	mu sync.Mutex
+	// history is the conversation up to the last answered prompt.
+	history []*genai.Content
+	// cancel stops the running prompt, if any.
+	cancel context.CancelFunc
+	// approvals are the tool calls waiting for approveTool, by the id of
+	// their approvalRequested notification.
+	approvals    map[int]chan toolApproval
+	nextApproval int
+	// always are the allow rules the program approved for good.
+	always map[string]bool
+	// closed is set once stdin is closed, after which nobody can approve
+	// anything.
+	closed  bool
+	running sync.WaitGroup
+}
+
+// toolApproval is the answer of approveTool.
+type toolApproval struct {
+	approved, always bool
+}
+
+// ServeJSONRPC lets a program drive a conversation with model through
+// JSON-RPC 2.0 messages, one per line, read from in and written to out.
+// It offers the methods
+//
+//   - sendPrompt {"prompt": "..."}, which answers the prompt in the
+//     conversation so far, streaming the text of the responses in "text"
+//     notifications, and replies with the response and its stats. Only
+//     one prompt runs at a time.
+//   - cancel, which stops the running prompt, whose request then fails.
+//   - approveTool {"id": 1, "approved": true, "always": false}, which
+//     answers the "approvalRequested" notification of a tool call that
+//     approval does not approve. always adds its rule, if it has one, to
+//     tools.allowed in the user settings.
+//   - getHistory, which replies with the conversation so far.
+//
+// It returns once in is closed and the running prompt is answered. Tool
+// calls are refused approval from then on.
+func ServeJSONRPC(ctx context.Context, cfg *config.Settings, model *genai.GenerativeModel, in io.Reader, out io.Writer, approval tools.ApprovalMode, allowed []string) error {
+	s := &rpcServer{
+		ctx:       ctx,
+		cfg:       cfg,
+		model:     model,
+		enc:       json.NewEncoder(out),
+		approvals: map[int]chan toolApproval{},
+		always:    map[string]bool{},
+	}
+	s.enc.SetEscapeHTML(false)
+	ws, err := newWorkspace(ctx, cfg, model, s.confirm, approval, allowed)
+	if err != nil {
+		return err
+	}
+	defer ws.MCP.Close()
+	s.ws = ws
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			s.handle(line)
+		}
+	}
+	s.close()
+	s.running.Wait()
+	return scanner.Err()
+}
+
+// handle answers a message of the program.
+func (s *rpcServer) handle(line []byte) {
+	var req rpcRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		s.send(rpcMessage{ID: json.RawMessage("null"), Error: &rpcError{codeParseError, fmt.Sprintf("invalid JSON: %v", err)}})
+		return
+	}
+	switch req.Method {
+	case "sendPrompt":
+		s.sendPrompt(req)
+	case "cancel":
+		s.mu.Lock()
+		cancel := s.cancel
+		s.mu.Unlock()
+		if cancel != nil {
+			cancel()
+		}
+		s.reply(req, map[string]bool{"cancelled": cancel != nil}, nil)
+	case "approveTool":
+		s.approveTool(req)
+	case "getHistory":
+		s.mu.Lock()
+		history := s.history
+		s.mu.Unlock()
+		s.reply(req, map[string]any{"history": historyJSON(history)}, nil)
+	case "":
+		s.reply(req, nil, &rpcError{codeInvalidRequest, "the message has no method"})
+	default:
+		s.reply(req, nil, &rpcError{codeMethodNotFound, fmt.Sprintf("unknown method %q", req.Method)})
+	}
+}
+
+// sendPrompt starts answering the prompt of req, unless another runs.
+func (s *rpcServer) sendPrompt(req rpcRequest) {
+	var params struct {
+		Prompt string `json:"prompt"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.Prompt == "" {
+		s.reply(req, nil, &rpcError{codeInvalidParams, `sendPrompt takes {"prompt": "..."}`})
+		return
+	}
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.mu.Unlock()
+		s.reply(req, nil, &rpcError{codeBusy, "a prompt is already running"})
+		return
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.cancel = cancel
+	history := s.history
+	s.mu.Unlock()
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		defer cancel()
+		onText := func(text string) {
+			s.send(rpcMessage{Method: "text", Params: map[string]any{"id": req.ID, "text": text}})
+		}
+		result, err := Continue(ctx, s.cfg, s.ws, s.model, history, []genai.Part{genai.Text(params.Prompt)}, onText)
+		cancelled := ctx.Err() != nil
+		s.mu.Lock()
+		s.cancel = nil
+		if err == nil {
+			s.history = result.History
+		}
+		s.mu.Unlock()
+		switch {
+		case err != nil && cancelled:
+			s.reply(req, nil, &rpcError{codeCancelled, "the prompt was cancelled"})
+		case err != nil:
+			s.reply(req, nil, &rpcError{codeFailed, err.Error()})
+		default:
+			s.reply(req, JSONOutput{Response: result.Response, Stats: result.Stats, Parameters: &result.Parameters}, nil)
+		}
+	}()
+}
+
+// approveTool passes the answer of req on to the tool call waiting for it.
+func (s *rpcServer) approveTool(req rpcRequest) {
+	var params struct {
+		ID       *int `json:"id"`
+		Approved bool `json:"approved"`
+		Always   bool `json:"always"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.ID == nil {
+		s.reply(req, nil, &rpcError{codeInvalidParams, `approveTool takes {"id": 1, "approved": true}`})
+		return
+	}
+	s.mu.Lock()
+	waiting, ok := s.approvals[*params.ID]
+	delete(s.approvals, *params.ID)
+	s.mu.Unlock()
+	if !ok {
+		s.reply(req, nil, &rpcError{codeInvalidParams, fmt.Sprintf("no tool call is waiting for approval %d", *params.ID)})
+		return
+	}
+	waiting <- toolApproval{approved: params.Approved, always: params.Always}
+	s.reply(req, struct{}{}, nil)
+}
+
+// confirm asks the program to approve a tool call with an
+// approvalRequested notification, and waits for its approveTool.
+func (s *rpcServer) confirm(ctx context.Context, title, details string) (bool, error) {
+	rule := tools.AllowRule(ctx)
+	s.mu.Lock()
+	if rule != "" && s.always[rule] {
+		s.mu.Unlock()
+		return true, nil
+	}
+	if s.closed {
+		s.mu.Unlock()
+		return false, nil
+	}
+	id := s.nextApproval
+	s.nextApproval++
+	answer := make(chan toolApproval, 1)
+	s.approvals[id] = answer
+	s.mu.Unlock()
+
+	params := map[string]any{"id": id, "title": title, "details": details}
+	if rule != "" {
+		params["rule"] = rule
+	}
+	s.send(rpcMessage{Method: "approvalRequested", Params: params})
+	select {
+	case a := <-answer:
+		if a.approved && a.always && rule != "" {
+			s.mu.Lock()
+			s.always[rule] = true
+			s.mu.Unlock()
+			if err := saveAllowed(rule); err != nil {
+				fmt.Fprintf(os.Stderr, "Could not save %s to tools.allowed: %v\n", rule, err)
+			}
+		}
+		return a.approved, nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		delete(s.approvals, id)
+		s.mu.Unlock()
+		return false, ctx.Err()
+	}
+}
+
+// close refuses the tool calls waiting for approval, and those to come.
+func (s *rpcServer) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for id, waiting := range s.approvals {
+		waiting <- toolApproval{}
+		delete(s.approvals, id)
+	}
+}
+
+// reply answers req with result or err, unless req is a notification.
+func (s *rpcServer) reply(req rpcRequest, result any, err *rpcError) {
+	if req.ID == nil {
+		return
+	}
+	s.send(rpcMessage{ID: req.ID, Result: result, Error: err})
+}
+
+func (s *rpcServer) send(msg rpcMessage) {
+	msg.JSONRPC = "2.0"
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := s.enc.Encode(msg); err != nil && !errors.Is(err, os.ErrClosed) {
+		fmt.Fprintf(os.Stderr, "Could not write a JSON-RPC message: %v\n", err)
+	}
+}
+
+// rpcContent is a turn of the conversation, as getHistory reports it.
+type rpcContent struct {
+	Role  string    `json:"role"`
+	Parts []rpcPart `json:"parts"`
+}
+
+type rpcPart struct {
+	Text             string       `json:"text,omitempty"`
+	FunctionCall     *rpcCall     `json:"functionCall,omitempty"`
+	FunctionResponse *rpcResponse `json:"functionResponse,omitempty"`
+	// MIMEType is the type of the data of a blob, which is left out.
+	MIMEType string `json:"mimeType,omitempty"`
+}
+
+type rpcCall struct {
+	Name string         `json:"name"`
+	Args map[string]any `json:"args"`
+}
+
+type rpcResponse struct {
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+// historyJSON returns history as getHistory reports it.
+func historyJSON(history []*genai.Content) []rpcContent {
+	contents := make([]rpcContent, 0, len(history))
+	for _, c := range history {
+		content := rpcContent{Role: c.Role, Parts: []rpcPart{}}
+		for _, p := range c.Parts {
+			switch v := p.(type) {
+			case genai.Text:
+				content.Parts = append(content.Parts, rpcPart{Text: string(v)})
+			case genai.FunctionCall:
+				content.Parts = append(content.Parts, rpcPart{FunctionCall: &rpcCall{v.Name, v.Args}})
+			case *genai.FunctionCall:
+				content.Parts = append(content.Parts, rpcPart{FunctionCall: &rpcCall{v.Name, v.Args}})
+			case genai.FunctionResponse:
+				content.Parts = append(content.Parts, rpcPart{FunctionResponse: &rpcResponse{v.Name, v.Response}})
+			case *genai.FunctionResponse:
+				content.Parts = append(content.Parts, rpcPart{FunctionResponse: &rpcResponse{v.Name, v.Response}})
+			case genai.Blob:
+				content.Parts = append(content.Parts, rpcPart{MIMEType: v.MIMEType})
+			}
+		}
+		contents = append(contents, content)
+	}
+	return contents
+}
@@ -5,8 +5,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 
 	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/finish"
+	"github.com/google-gemini/gemini-cli-go/pkg/generation"
+	"github.com/google-gemini/gemini-cli-go/pkg/mcp"
+	"github.com/google-gemini/gemini-cli-go/pkg/respcache"
+	"github.com/google-gemini/gemini-cli-go/pkg/startup"
+	"github.com/google-gemini/gemini-cli-go/pkg/thinking"
 	"github.com/google-gemini/gemini-cli-go/pkg/tools"
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/iterator"
@@ -14,86 +22,372 @@ import (
 
 // JSONOutput represents the structure of the JSON output.
 type JSONOutput struct {
-	Response string      `json:"response"`
-	Stats    interface{} `json:"stats"` // Placeholder for stats
+	Response string `json:"response"`
+	Stats    Stats  `json:"stats"`
+	// Parameters are those the run used, to reproduce it.
+	Parameters *Parameters `json:"parameters,omitempty"`
 }
 
-// Run executes a non-interactive prompt.
-func Run(ctx context.Context, cfg *config.Settings, model *genai.GenerativeModel, prompt string, outputFormat string) error {
-	chat := model.StartChat()
-	var responseText string
+// Parameters are the model and generation parameters of a run. Unset
+// parameters take the model's defaults.
+type Parameters struct {
+	Model            string   `json:"model"`
+	Seed             *int32   `json:"seed,omitempty"`
+	ThinkingBudget   *int32   `json:"thinkingBudget,omitempty"`
+	Temperature      *float32 `json:"temperature,omitempty"`
+	TopP             *float32 `json:"topP,omitempty"`
+	TopK             *int32   `json:"topK,omitempty"`
+	MaxOutputTokens  *int32   `json:"maxOutputTokens,omitempty"`
+	CandidateCount   *int32   `json:"candidateCount,omitempty"`
+	StopSequences    []string `json:"stopSequences,omitempty"`
+	ResponsePrefix   string   `json:"responsePrefix,omitempty"`
+	ResponseMIMEType string   `json:"responseMimeType,omitempty"`
+	MaxSessionTurns  int      `json:"maxSessionTurns"`
+}
+
+// Stats are what the responses of a run cost.
+type Stats struct {
+	Tokens TokenStats `json:"tokens"`
+}
+
+// TokenStats are the tokens of a run, summed over its requests. Responses
+// replayed from the cache count none.
+type TokenStats struct {
+	Prompt     int32 `json:"prompt"`
+	Cached     int32 `json:"cached"`
+	Candidates int32 `json:"candidates"`
+	// Thoughts are those the model spent thinking, which the total counts
+	// but the candidates do not.
+	Thoughts int32 `json:"thoughts"`
+	Total    int32 `json:"total"`
+}
 
-	currentUserParts := []genai.Part{genai.Text(prompt)}
+// add counts the usage a response reported.
+func (s *TokenStats) add(u *genai.UsageMetadata) {
+	s.Prompt += u.PromptTokenCount
+	s.Cached += u.CachedContentTokenCount
+	s.Candidates += u.CandidatesTokenCount
+	s.Total += u.TotalTokenCount
+	// The client library drops thoughtsTokenCount, so they are what the
+	// total counts beyond the prompt and candidates.
+	s.Thoughts += max(u.TotalTokenCount-u.PromptTokenCount-u.CandidatesTokenCount, 0)
+}
 
-	maxTurns := 10
-	if cfg.Model != nil && cfg.Model.MaxSessionTurns > 0 {
-		maxTurns = cfg.Model.MaxSessionTurns
+// Run executes a non-interactive prompt. The changes and commands of tools
+// that approval does not approve are asked about on the terminal, or
+// refused if there is none. If allowed is not empty, only those tools are
+// offered.
+func Run(ctx context.Context, cfg *config.Settings, model *genai.GenerativeModel, prompt []genai.Part, outputFormat string, approval tools.ApprovalMode, allowed []string) error {
+	ws, err := newWorkspace(ctx, cfg, model, confirmer(), approval, allowed)
+	if err != nil {
+		return err
 	}
+	defer ws.MCP.Close()
 
-	turnCount := 0
-	for {
-		turnCount++
-		if maxTurns >= 0 && turnCount > maxTurns {
-			return fmt.Errorf("max turns exceeded: %d", maxTurns)
+	var onText func(string)
+	if outputFormat != "json" {
+		onText = func(text string) { fmt.Fprint(os.Stdout, text) }
+	}
+	result, err := Converse(ctx, cfg, ws, model, prompt, onText)
+	if err != nil {
+		return err
+	}
+
+	if outputFormat == "json" {
+		output := JSONOutput{
+			Response:   result.Response,
+			Stats:      result.Stats,
+			Parameters: &result.Parameters,
 		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(output); err != nil {
+			return fmt.Errorf("failed to encode JSON: %w", err)
+		}
+	} else {
+		fmt.Fprintln(os.Stdout) // Final newline for text output
+	}
+	return nil
+}
 
-		iter := chat.SendMessageStream(ctx, currentUserParts...)
+// newWorkspace sets up the workspace the tools of a run use, which asks
+// confirm to approve what approval does not, and lets model know the
+// memories saved in earlier sessions. Problems loading plugins, connecting
+// to MCP servers and reading the memories are reported on stderr. The
+// caller closes its MCP registry.
+func newWorkspace(ctx context.Context, cfg *config.Settings, model *genai.GenerativeModel, confirm tools.Confirmer, approval tools.ApprovalMode, allowed []string) (*tools.Workspace, error) {
+	ws, err := tools.NewWorkspace(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up the workspace: %w", err)
+	}
+	ws.Allowed = allowed
+	ws.Confirm = confirm
+	ws.Approval = approval
+	ws.Approved = reportApproved(os.Stderr, approval)
+	ws.TodosChanged = func(todos []tools.Todo) {
+		fmt.Fprintf(os.Stderr, "Plan:\n%s\n", tools.FormatTodos(todos))
+	}
+	if err := ws.LoadPlugins(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not load some tool plugins: %v\n", err)
+	}
+	// Servers requiring authorization are authorized with in the browser
+	// when there is a user at the terminal to do it.
+	connectCtx := ctx
+	if confirm != nil {
+		connectCtx = mcp.WithAuthorization(ctx, func(server, authURL string) {
+			fmt.Fprintf(os.Stderr, "MCP server %s requires authorization. Opening your browser; if it does not open, visit:\n%s\n", server, authURL)
+		})
+	}
+	if err := ws.ConnectMCP(connectCtx); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not connect to some MCP servers: %v\n", err)
+	}
+	// What save_memory saved in earlier sessions is known in this one.
+	if memory, err := ws.UserMemory(); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not load the saved memories: %v\n", err)
+	} else if strings.TrimSpace(memory) != "" && model.SystemInstruction == nil {
+		model.SystemInstruction = genai.NewUserContent(genai.Text(memory))
+	}
+	return ws, nil
+}
 
-		var collectedFunctionCalls []genai.FunctionCall
+// Result is the outcome of a conversation.
+type Result struct {
+	// Response is the text of every model response.
+	Response   string
+	Parameters Parameters
+	Stats      Stats
+	// History is the conversation, up to the last response.
+	History []*genai.Content
+}
 
-		for {
-			resp, err := iter.Next()
-			if err == iterator.Done {
-				break
+// Converse sends prompt to model and executes the tool calls of its
+// responses in ws until it answers without any, or the turn limit is
+// reached. Empty, truncated and malformed responses are retried or
+// continued a few times, and reported on stderr if they persist. The
+// response to prompt starts with the response prefix of the settings, if
+// any. onText, if not nil, receives the response text as it streams in,
+// including that of responses which are then dropped and requested again,
+// as "Retrying." on stderr notes; the returned response leaves it out.
+// Without a workspace, as for prompts run without tools, the calls the
+// model makes anyway are answered with an error.
+func Converse(ctx context.Context, cfg *config.Settings, ws *tools.Workspace, model *genai.GenerativeModel, prompt []genai.Part, onText func(string)) (*Result, error) {
+	return Continue(ctx, cfg, ws, model, nil, prompt, onText)
+}
+
+// Continue is Converse for a prompt following history, the conversation so
+// far, which it leaves untouched.
+func Continue(ctx context.Context, cfg *config.Settings, ws *tools.Workspace, model *genai.GenerativeModel, history []*genai.Content, prompt []genai.Part, onText func(string)) (*Result, error) {
+	// Tools can change during the conversation, such as when an MCP server
+	// announces new ones, so they are declared again on every turn.
+	redeclare := model.Tools == nil && ws != nil
+	params := parameters(cfg, model)
+	var (
+		cache *respcache.Cache
+		err   error
+	)
+	if cfg.Model != nil {
+		if rc := cfg.Model.ResponseCache; rc != nil && rc.Enabled {
+			if cache, err = respcache.New(rc); err != nil {
+				return nil, err
 			}
-			if err != nil {
-				return err
+		}
+	}
+
+	chat := model.StartChat()
+	chat.History = slices.Clone(history)
+	var (
+		responseText strings.Builder
+		stats        Stats
+	)
+
+	currentUserParts := prompt
+	// prefix is the response prefix while the prompt is unanswered.
+	prefix := params.ResponsePrefix
+
+	maxTurns := params.MaxSessionTurns
+
+	turnCount, retries := 0, 0
+	for {
+		turnCount++
+		if maxTurns >= 0 && turnCount > maxTurns {
+			return nil, fmt.Errorf("max turns exceeded: %d", maxTurns)
+		}
+
+		if redeclare {
+			if ws.MCP != nil {
+				// Changes announced during the last turn are listed
+				// before the tools are declared again.
+				if err := ws.MCP.Settle(ctx); err != nil {
+					return nil, err
+				}
 			}
+			model.Tools = nil
+			declareTools(model, ws)
+		}
+		var collectedFunctionCalls []genai.FunctionCall
 
-			if len(resp.Candidates) > 0 {
-				candidate := resp.Candidates[0]
-				if candidate.Content != nil {
-					for _, part := range candidate.Content.Parts {
-						switch v := part.(type) {
-						case genai.Text:
-							if outputFormat == "json" {
-								responseText += string(v)
-							} else {
-								fmt.Fprint(os.Stdout, string(v))
-							}
-						case genai.FunctionCall:
-							collectedFunctionCalls = append(collectedFunctionCalls, v)
-						}
-					}
+		sent, written := len(chat.History), responseText.Len()
+		// The prefix comes out with the text it starts.
+		unwritten := prefix
+		resp, err := sendMessage(generation.WithPrefix(ctx, prefix), chat, cache, params, model, currentUserParts, &stats.Tokens, func(part genai.Part) {
+			switch v := part.(type) {
+			case genai.Text:
+				text := unwritten + string(v)
+				unwritten = ""
+				responseText.WriteString(text)
+				if onText != nil {
+					onText(text)
 				}
+			case genai.FunctionCall:
+				collectedFunctionCalls = append(collectedFunctionCalls, v)
 			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		if prefix != "" && len(chat.History) > sent+1 {
+			generation.AddPrefix(chat.History[len(chat.History)-1], prefix)
 		}
 
-		if len(collectedFunctionCalls) > 0 {
-			var toolResponseParts []genai.Part
-			for _, fc := range collectedFunctionCalls {
-				toolResponse := tools.ExecuteToolCall(&fc)
-				toolResponseParts = append(toolResponseParts, toolResponse)
+		switch problem, recovery := finish.Plan(resp, retries); recovery {
+		case finish.Continue:
+			retries++
+			prefix = ""
+			fmt.Fprintf(os.Stderr, "%s Asking the model to continue.\n", problem)
+			currentUserParts = []genai.Part{genai.Text(finish.ContinuePrompt)}
+			continue
+		case finish.Resend:
+			retries++
+			fmt.Fprintf(os.Stderr, "%s Retrying.\n", finish.Report(problem, resp))
+			chat.History = chat.History[:sent]
+			kept := responseText.String()[:written]
+			responseText.Reset()
+			responseText.WriteString(kept)
+			defer finish.Adjust(model, problem)()
+			continue
+		default:
+			if problem != finish.None && len(collectedFunctionCalls) == 0 {
+				fmt.Fprintln(os.Stderr, finish.Report(problem, resp))
 			}
-			currentUserParts = toolResponseParts
-		} else {
+		}
+
+		if len(collectedFunctionCalls) == 0 {
 			// End of conversation
-			if outputFormat == "json" {
-				// For now, stats are empty. This can be implemented later.
-				stats := map[string]interface{}{}
-				output := JSONOutput{
-					Response: responseText,
-					Stats:    stats,
-				}
-				encoder := json.NewEncoder(os.Stdout)
-				encoder.SetIndent("", "  ")
-				if err := encoder.Encode(output); err != nil {
-					return fmt.Errorf("failed to encode JSON: %w", err)
-				}
-			} else {
-				fmt.Fprintln(os.Stdout) // Final newline for text output
+			return &Result{Response: responseText.String(), Parameters: params, Stats: stats, History: chat.History}, nil
+		}
+		for _, fc := range collectedFunctionCalls {
+			// Printed to stderr to keep it out of the response on stdout.
+			fmt.Fprintf(os.Stderr, "Executing tool: %s with args: %v\n", fc.Name, fc.Args)
+		}
+		prefix = ""
+		if ws == nil {
+			currentUserParts = refuseCalls(collectedFunctionCalls)
+			continue
+		}
+		currentUserParts = tools.ExecuteTurn(ctx, ws, collectedFunctionCalls)
+	}
+}
+
+// refuseCalls answers calls made without any tools to run them with an
+// error each.
+func refuseCalls(calls []genai.FunctionCall) []genai.Part {
+	parts := make([]genai.Part, len(calls))
+	for i, fc := range calls {
+		parts[i] = &genai.FunctionResponse{Name: fc.Name, Response: map[string]any{"error": "no tools are available in this mode"}}
+	}
+	return parts
+}
+
+// declareTools lets model call the tools of ws, unless the caller
+// declared tools of its own.
+func declareTools(model *genai.GenerativeModel, ws *tools.Workspace) {
+	if model.Tools == nil {
+		model.Tools = []*genai.Tool{ws.Tool()}
+	}
+}
+
+// parameters returns the parameters a run with cfg and model uses.
+func parameters(cfg *config.Settings, model *genai.GenerativeModel) Parameters {
+	params := Parameters{
+		Temperature:      model.Temperature,
+		TopP:             model.TopP,
+		TopK:             model.TopK,
+		MaxOutputTokens:  model.MaxOutputTokens,
+		CandidateCount:   model.CandidateCount,
+		StopSequences:    model.StopSequences,
+		ResponseMIMEType: model.ResponseMIMEType,
+		MaxSessionTurns:  10,
+	}
+	if cfg.Model != nil {
+		params.Model = cfg.Model.Name
+		params.Seed = cfg.Model.Seed
+		params.ResponsePrefix = generation.Settings(cfg.Model).ResponsePrefix
+		if budget, ok, _ := thinking.Budget(cfg.Model); ok {
+			params.ThinkingBudget = &budget
+		}
+		if cfg.Model.MaxSessionTurns > 0 {
+			params.MaxSessionTurns = cfg.Model.MaxSessionTurns
+		}
+	}
+	return params
+}
+
+// sendMessage sends parts in chat, passes the parts of the response to
+// handle as they stream in, counting the tokens the response used in
+// tokens, and returns the whole response. With a cache, a response cached
+// for the same request is replayed instead, and new complete responses are
+// cached.
+func sendMessage(ctx context.Context, chat *genai.ChatSession, cache *respcache.Cache, params Parameters, model *genai.GenerativeModel, parts []genai.Part, tokens *TokenStats, handle func(genai.Part)) (*genai.GenerateContentResponse, error) {
+	var key string
+	if cache != nil {
+		key = respcache.Key(params, model, chat.History, parts)
+		if cached, ok := cache.Get(key); ok {
+			for _, p := range cached {
+				handle(p)
 			}
-			return nil
+			chat.History = append(chat.History,
+				&genai.Content{Role: "user", Parts: parts},
+				&genai.Content{Role: "model", Parts: cached})
+			return &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{
+				Content:      &genai.Content{Role: "model", Parts: cached},
+				FinishReason: genai.FinishReasonStop,
+			}}}, nil
 		}
 	}
-}
\ No newline at end of file
+
+	iter := chat.SendMessageStream(ctx, parts...)
+	var (
+		received []genai.Part
+		usage    *genai.UsageMetadata
+	)
+	for {
+		resp, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Every chunk reports the usage so far.
+		if resp.UsageMetadata != nil {
+			usage = resp.UsageMetadata
+		}
+		if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
+			startup.Mark("first token")
+			for _, part := range resp.Candidates[0].Content.Parts {
+				received = append(received, part)
+				handle(part)
+			}
+		}
+	}
+	if usage != nil {
+		tokens.add(usage)
+	}
+	resp := iter.MergedResponse()
+	if cache != nil && finish.Check(resp) == finish.None {
+		if err := cache.Put(key, received); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not cache the response: %v\n", err)
+		}
+	}
+	return resp, nil
+}
@@ -1,6 +1,7 @@
 package noninteractive
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -8,10 +9,15 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
+	"github.com/google-gemini/gemini-cli-go/pkg/auth"
 	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/generation"
+	"github.com/google-gemini/gemini-cli-go/pkg/tools"
 	"github.com/google/generative-ai-go/genai"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/api/option"
@@ -46,7 +52,7 @@ func TestRun_SimpleTextResponse(t *testing.T) {
 	os.Stdout = w
 
 	// 4. Run the function with default text format
-	runErr := Run(ctx, cfg, model, "Test prompt", "text")
+	runErr := Run(ctx, cfg, model, []genai.Part{genai.Text("Test prompt")}, "text", tools.ApprovalDefault, nil)
 	w.Close()
 
 	// 5. Assertions
@@ -94,7 +100,7 @@ func TestRun_SingleFunctionCall(t *testing.T) {
 	os.Stdout = w
 
 	// 4. Run
-	runErr := Run(ctx, cfg, model, "Use a tool", "text")
+	runErr := Run(ctx, cfg, model, []genai.Part{genai.Text("Use a tool")}, "text", tools.ApprovalDefault, nil)
 	w.Close()
 
 	// 5. Assertions
@@ -122,9 +128,11 @@ func TestRun_JsonOutput(t *testing.T) {
 	client, err := genai.NewClient(ctx, option.WithAPIKey("fake-api-key"), option.WithEndpoint(server.URL))
 	assert.NoError(t, err)
 	model := client.GenerativeModel("gemini-pro")
+	model.SetTemperature(0.2)
 
 	// 3. Setup config and run
-	cfg := &config.Settings{}
+	seed := int32(7)
+	cfg := &config.Settings{Model: &config.ModelSettings{Name: "gemini-pro", Seed: &seed}}
 
 	// Redirect stdout
 	r, w, _ := os.Pipe()
@@ -135,7 +143,7 @@ func TestRun_JsonOutput(t *testing.T) {
 	os.Stdout = w
 
 	// 4. Run with "json" format
-	runErr := Run(ctx, cfg, model, "Test prompt", "json")
+	runErr := Run(ctx, cfg, model, []genai.Part{genai.Text("Test prompt")}, "json", tools.ApprovalDefault, nil)
 	w.Close()
 
 	// 5. Assertions
@@ -150,4 +158,547 @@ func TestRun_JsonOutput(t *testing.T) {
 
 	assert.Equal(t, "JSON response", output.Response)
 	assert.NotNil(t, output.Stats)
-}
\ No newline at end of file
+	if assert.NotNil(t, output.Parameters) {
+		assert.Equal(t, int32(7), *output.Parameters.Seed)
+		assert.Equal(t, float32(0.2), *output.Parameters.Temperature)
+		assert.Equal(t, 10, output.Parameters.MaxSessionTurns)
+	}
+}
+
+func TestRun_ResponseCache(t *testing.T) {
+	restore := config.SetUserHomeDirForTesting(t.TempDir(), nil)
+	defer restore()
+
+	var callCount int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callCount++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(w, `[{"candidates":[{"content":{"parts":[{"text":"Cached"}]}}]}]`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey("fake-api-key"), option.WithEndpoint(server.URL))
+	assert.NoError(t, err)
+	cfg := &config.Settings{Model: &config.ModelSettings{Name: "gemini-pro", ResponseCache: &config.ResponseCacheSettings{Enabled: true}}}
+
+	run := func(prompt string) string {
+		r, w, _ := os.Pipe()
+		tmp := os.Stdout
+		os.Stdout = w
+		runErr := Run(ctx, cfg, client.GenerativeModel("gemini-pro"), []genai.Part{genai.Text(prompt)}, "text", tools.ApprovalDefault, nil)
+		w.Close()
+		os.Stdout = tmp
+		assert.NoError(t, runErr)
+		var buf strings.Builder
+		io.Copy(&buf, r)
+		return buf.String()
+	}
+
+	assert.Equal(t, "Cached\n", run("Same prompt"))
+	assert.Equal(t, "Cached\n", run("Same prompt"))
+	assert.Equal(t, 1, callCount, "Expected the second run to reuse the response")
+	run("Another prompt")
+	assert.Equal(t, 2, callCount, "Expected a different prompt to reach the model")
+}
+
+func TestDryRun(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.HasSuffix(r.URL.Path, ":countTokens") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintln(w, `{"error":{"code":400,"message":"unexpected request"}}`)
+			return
+		}
+		fmt.Fprintln(w, `{"totalTokens": 42}`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey("fake-api-key"), option.WithEndpoint(server.URL))
+	assert.NoError(t, err)
+	model := client.GenerativeModel("gemini-pro")
+	model.SystemInstruction = genai.NewUserContent(genai.Text("Be brief."))
+	model.Tools = []*genai.Tool{{FunctionDeclarations: []*genai.FunctionDeclaration{{Name: "read_file", Description: "Reads a file.\nMore details."}}}}
+	cfg := &config.Settings{Model: &config.ModelSettings{Name: "gemini-pro"}}
+
+	dryRun := func(format string) string {
+		r, w, _ := os.Pipe()
+		tmp := os.Stdout
+		os.Stdout = w
+		runErr := DryRun(ctx, cfg, model, []genai.Part{genai.Text("Test prompt")}, format, nil)
+		w.Close()
+		os.Stdout = tmp
+		assert.NoError(t, runErr)
+		var buf strings.Builder
+		io.Copy(&buf, r)
+		return buf.String()
+	}
+
+	text := dryRun("text")
+	for _, want := range []string{`"model":"gemini-pro"`, "  Be brief.\n", "Tools (1 declarations", "  read_file: Reads a file.\n", "History (0 messages", "  Test prompt\n", "Total: 42 tokens"} {
+		assert.Contains(t, text, want)
+	}
+
+	var output struct {
+		Tools  []*genai.FunctionDeclaration
+		Tokens TokenCounts
+	}
+	assert.NoError(t, json.Unmarshal([]byte(dryRun("json")), &output))
+	assert.Equal(t, int32(42), output.Tokens.Total)
+	assert.False(t, output.Tokens.Estimated)
+	assert.Positive(t, output.Tokens.Prompt)
+	assert.Equal(t, "read_file", output.Tools[0].Name)
+
+	for _, p := range paths {
+		assert.True(t, strings.HasSuffix(p, ":countTokens"), "Expected only token counts, got %s", p)
+	}
+
+	// Without the API, the total is estimated.
+	server.Close()
+	assert.Contains(t, dryRun("text"), "(estimated)")
+}
+
+func TestRun_ReadFile(t *testing.T) {
+	restore := config.SetUserHomeDirForTesting(t.TempDir(), nil)
+	defer restore()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	assert.NoError(t, os.WriteFile("notes.txt", []byte("first\nsecond\nthird\n"), 0644))
+
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.Header().Set("Content-Type", "application/json")
+		if len(bodies) == 1 {
+			fmt.Fprintln(w, `[{"candidates":[{"content":{"parts":[{"functionCall":{"name":"read_file","args":{"path":"notes.txt","offset":1,"limit":1}}}]}}]}]`)
+			return
+		}
+		fmt.Fprintln(w, `[{"candidates":[{"content":{"parts":[{"text":"It says second."}]}}]}]`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey("fake-api-key"), option.WithEndpoint(server.URL))
+	assert.NoError(t, err)
+	ws, err := tools.NewWorkspace(&config.Settings{})
+	assert.NoError(t, err)
+
+	result, err := Converse(ctx, &config.Settings{}, ws, client.GenerativeModel("gemini-pro"), []genai.Part{genai.Text("What do my notes say?")}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "It says second.", result.Response)
+	if assert.Len(t, bodies, 2) {
+		assert.Contains(t, bodies[0], `"name":"read_file"`, "Expected read_file to be declared to the model")
+		assert.Contains(t, bodies[1], `"functionResponse"`)
+		assert.Contains(t, bodies[1], "second")
+		assert.NotContains(t, bodies[1], "third")
+	}
+}
+
+func TestRun_WriteFileYolo(t *testing.T) {
+	restore := config.SetUserHomeDirForTesting(t.TempDir(), nil)
+	defer restore()
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.Header().Set("Content-Type", "application/json")
+		if len(bodies) == 1 {
+			fmt.Fprintln(w, `[{"candidates":[{"content":{"parts":[{"functionCall":{"name":"write_file","args":{"path":"out/hello.txt","content":"hello\n"}}}]}}]}]`)
+			return
+		}
+		fmt.Fprintln(w, `[{"candidates":[{"content":{"parts":[{"text":"Done."}]}}]}]`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey("fake-api-key"), option.WithEndpoint(server.URL))
+	assert.NoError(t, err)
+
+	r, w, _ := os.Pipe()
+	tmp := os.Stdout
+	defer func() {
+		os.Stdout = tmp
+	}()
+	os.Stdout = w
+	runErr := Run(ctx, &config.Settings{}, client.GenerativeModel("gemini-pro"), []genai.Part{genai.Text("Say hello in a file")}, "text", tools.ApprovalYolo, nil)
+	w.Close()
+	io.Copy(io.Discard, r)
+
+	assert.NoError(t, runErr)
+	data, err := os.ReadFile(filepath.Join("out", "hello.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", string(data))
+	if assert.Len(t, bodies, 2) {
+		assert.Contains(t, bodies[1], "created out/hello.txt")
+	}
+}
+
+func TestPromptConfirmer(t *testing.T) {
+	for answer, want := range map[string]bool{"y\n": true, "Yes\n": true, "n\n": false, "\n": false, "": false} {
+		var out strings.Builder
+		confirm := promptConfirmer(strings.NewReader(answer), &out)
+		ok, err := confirm(context.Background(), "Apply changes to 1 file(s)?", "+hello\n")
+		assert.NoError(t, err)
+		assert.Equal(t, want, ok, "answer %q", answer)
+		assert.Contains(t, out.String(), "+hello\nApply changes to 1 file(s)? [y/N] ")
+	}
+}
+
+func TestPromptConfirmerAlwaysAllow(t *testing.T) {
+	defer config.SetUserHomeDirForTesting(t.TempDir(), nil)()
+	var out strings.Builder
+	confirm := promptConfirmer(strings.NewReader("a\n"), &out)
+	ctx := tools.WithAllowRule(context.Background(), "run_shell_command(go test ./...)")
+	for range 2 {
+		// The second time, it is not asked again.
+		ok, err := confirm(ctx, "Run this command?", "go test ./...")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	}
+	assert.Equal(t, 1, strings.Count(out.String(), "Run this command? [y/N/a=always] "))
+	user, err := config.LoadScope(config.UserScope)
+	if assert.NoError(t, err) && assert.NotNil(t, user.Tools) {
+		assert.Equal(t, []string{"run_shell_command(go test ./...)"}, user.Tools.Allowed)
+	}
+}
+
+func TestConverse_RetriesEmptyAndTruncatedResponses(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.Header().Set("Content-Type", "application/json")
+		switch len(bodies) {
+		case 1:
+			fmt.Fprintln(w, `[{"candidates":[{"finishReason":"STOP"}]}]`)
+		case 2:
+			fmt.Fprintln(w, `[{"candidates":[{"content":{"parts":[{"text":"The first half"}]},"finishReason":"MAX_TOKENS"}]}]`)
+		default:
+			fmt.Fprintln(w, `[{"candidates":[{"content":{"parts":[{"text":" and the rest."}]},"finishReason":"STOP"}]}]`)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey("fake-api-key"), option.WithEndpoint(server.URL))
+	assert.NoError(t, err)
+	model := client.GenerativeModel("gemini-pro")
+	model.SetTemperature(0.7)
+	ws, err := tools.NewWorkspace(&config.Settings{})
+	assert.NoError(t, err)
+
+	result, err := Converse(ctx, &config.Settings{}, ws, model, []genai.Part{genai.Text("Tell me everything")}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "The first half and the rest.", result.Response)
+	if assert.Len(t, bodies, 3) {
+		assert.Contains(t, bodies[1], `"temperature":0`, "Expected the empty response to be retried at temperature 0")
+		assert.Equal(t, 1, strings.Count(bodies[1], "Tell me everything"), "Expected the empty response to be dropped")
+		assert.Contains(t, bodies[2], "Continue exactly where it stopped")
+	}
+	assert.Equal(t, float32(0.7), *model.Temperature, "Expected the temperature to be restored")
+}
+
+func TestConverse_DropsResentResponses(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		if requests == 1 {
+			// A malformed function call, which is dropped and requested again.
+			fmt.Fprintln(w, `[{"candidates":[{"content":{"parts":[{"text":"Let me check. "}]},"finishReason":10}]}]`)
+			return
+		}
+		fmt.Fprintln(w, `[{"candidates":[{"content":{"parts":[{"text":"All good."}]},"finishReason":"STOP"}]}]`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey("fake-api-key"), option.WithEndpoint(server.URL))
+	assert.NoError(t, err)
+	model := client.GenerativeModel("gemini-pro")
+	ws, err := tools.NewWorkspace(&config.Settings{})
+	assert.NoError(t, err)
+
+	var streamed strings.Builder
+	result, err := Converse(ctx, &config.Settings{}, ws, model, []genai.Part{genai.Text("Check it")}, func(text string) { streamed.WriteString(text) })
+	assert.NoError(t, err)
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, "All good.", result.Response, "Expected the dropped response to be left out")
+	// The dropped text was streamed before the finish reason was known.
+	assert.Equal(t, "Let me check. All good.", streamed.String())
+}
+
+func TestConverse_WithoutWorkspace(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.Header().Set("Content-Type", "application/json")
+		if len(bodies) == 1 {
+			fmt.Fprintln(w, `[{"candidates":[{"content":{"parts":[{"functionCall":{"name":"read_file","args":{"path":"a.txt"}}}]}}]}]`)
+			return
+		}
+		fmt.Fprintln(w, `[{"candidates":[{"content":{"parts":[{"text":"No tools, then."}]}}]}]`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey("fake-api-key"), option.WithEndpoint(server.URL))
+	assert.NoError(t, err)
+	model := client.GenerativeModel("gemini-pro")
+	model.Tools = []*genai.Tool{}
+
+	// As batch runs its prompts, with neither tools nor a workspace: a call
+	// the model makes anyway is refused rather than run.
+	result, err := Converse(ctx, &config.Settings{}, nil, model, []genai.Part{genai.Text("Read a.txt")}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "No tools, then.", result.Response)
+	if assert.Len(t, bodies, 2) {
+		assert.Contains(t, bodies[1], "no tools are available in this mode")
+	}
+}
+
+func TestConverse_ResponsePrefix(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.Header().Set("Content-Type", "application/json")
+		if len(bodies) == 1 {
+			fmt.Fprintln(w, `[{"candidates":[{"content":{"parts":[{"text":"a + b\n}"}]},"finishReason":"STOP"}]}]`)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey("fake-api-key"), option.WithEndpoint(server.URL),
+		auth.ClientOption("fake-api-key", generation.Transport(http.DefaultTransport)))
+	assert.NoError(t, err)
+	cfg := &config.Settings{Model: &config.ModelSettings{Generation: &config.GenerationSettings{
+		StopSequences:  []string{"\n\n"},
+		ResponsePrefix: "func add(a, b int) int {\n\treturn ",
+	}}}
+	model := client.GenerativeModel("gemini-pro")
+	generation.Apply(model, cfg.Model.Generation)
+	ws, err := tools.NewWorkspace(&config.Settings{})
+	assert.NoError(t, err)
+
+	var streamed strings.Builder
+	result, err := Converse(ctx, cfg, ws, model, []genai.Part{genai.Text("Complete the function")}, func(text string) { streamed.WriteString(text) })
+	assert.NoError(t, err)
+	assert.Equal(t, "func add(a, b int) int {\n\treturn a + b\n}", result.Response)
+	assert.Equal(t, result.Response, streamed.String())
+	assert.Equal(t, cfg.Model.Generation.ResponsePrefix, result.Parameters.ResponsePrefix)
+	assert.Equal(t, []string{"\n\n"}, result.Parameters.StopSequences)
+	if assert.Len(t, bodies, 1) {
+		assert.Contains(t, bodies[0], `"stopSequences":["\n\n"]`)
+		assert.Contains(t, bodies[0], `{"parts":[{"text":"func add(a, b int) int {\n\treturn "}],"role":"model"}`)
+	}
+}
+
+func TestConverse_MCPToolsChange(t *testing.T) {
+	// The MCP server swaps its tool for another when it is called, and
+	// announces the change in the call's event stream.
+	var mu sync.Mutex
+	tool := "first"
+	mcpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ID == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		var result any
+		switch req.Method {
+		case "initialize":
+			result = map[string]any{"capabilities": map[string]any{"tools": map[string]any{"listChanged": true}}}
+		case "tools/list":
+			result = map[string]any{"tools": []map[string]any{{"name": tool}}}
+		case "tools/call":
+			tool = "second"
+			data, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": map[string]any{"content": []map[string]any{{"type": "text", "text": "done"}}}})
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/tools/list_changed\"}\n\n")
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	defer mcpServer.Close()
+
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.Header().Set("Content-Type", "application/json")
+		if len(bodies) == 1 {
+			fmt.Fprintln(w, `[{"candidates":[{"content":{"parts":[{"functionCall":{"name":"first","args":{}}}]}}]}]`)
+		} else {
+			fmt.Fprintln(w, `[{"candidates":[{"content":{"parts":[{"text":"Done"}]}}]}]`)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey("fake-api-key"), option.WithEndpoint(server.URL))
+	assert.NoError(t, err)
+	model := client.GenerativeModel("gemini-pro")
+	cfg := &config.Settings{MCPServers: map[string]config.MCPServer{"docs": {HTTPURL: mcpServer.URL, Trust: true}}}
+	ws, err := tools.NewWorkspace(cfg)
+	assert.NoError(t, err)
+	defer ws.MCP.Close()
+	assert.NoError(t, ws.ConnectMCP(ctx))
+
+	result, err := Converse(ctx, cfg, ws, model, []genai.Part{genai.Text("Use the tool")}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "Done", result.Response)
+	if assert.Len(t, bodies, 2) {
+		assert.Contains(t, bodies[0], `"name":"first"`)
+		assert.Contains(t, bodies[1], `"output":"done"`, "Expected the tool to have been called")
+		assert.Contains(t, bodies[1], `{"name":"second"}`, "Expected the new tool to be declared")
+		assert.NotContains(t, bodies[1], `{"name":"first"}`, "Expected the removed tool not to be declared")
+	}
+}
+
+func TestServeJSONRPC(t *testing.T) {
+	restore := config.SetUserHomeDirForTesting(t.TempDir(), nil)
+	defer restore()
+	t.Chdir(t.TempDir())
+
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.Header().Set("Content-Type", "application/json")
+		if len(bodies) == 1 {
+			fmt.Fprintln(w, `[{"candidates":[{"content":{"parts":[{"functionCall":{"name":"write_file","args":{"path":"hello.txt","content":"hello\n"}}}]}}]}]`)
+			return
+		}
+		fmt.Fprintln(w, `[{"candidates":[{"content":{"parts":[{"text":"Done."}]}}]}]`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey("fake-api-key"), option.WithEndpoint(server.URL))
+	assert.NoError(t, err)
+
+	in, requests := io.Pipe()
+	responses, out := io.Pipe()
+	served := make(chan error, 1)
+	go func() {
+		served <- ServeJSONRPC(ctx, &config.Settings{}, client.GenerativeModel("gemini-pro"), in, out, tools.ApprovalDefault, nil)
+		out.Close()
+	}()
+	send := func(msg string) {
+		t.Helper()
+		if _, err := io.WriteString(requests, msg+"\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines := bufio.NewScanner(responses)
+	next := func() map[string]any {
+		t.Helper()
+		if !lines.Scan() {
+			t.Fatalf("the server wrote nothing more: %v", lines.Err())
+		}
+		var msg map[string]any
+		if err := json.Unmarshal(lines.Bytes(), &msg); err != nil {
+			t.Fatalf("invalid message %q: %v", lines.Bytes(), err)
+		}
+		return msg
+	}
+
+	send(`{"jsonrpc":"2.0","id":1,"method":"sendPrompt","params":{"prompt":"Say hello in a file"}}`)
+	msg := next()
+	params, _ := msg["params"].(map[string]any)
+	if msg["method"] != "approvalRequested" || !strings.Contains(fmt.Sprint(params["details"]), "+hello") {
+		t.Fatalf("got %v, want the write to be asked about", msg)
+	}
+	send(`{"jsonrpc":"2.0","id":2,"method":"sendPrompt","params":{"prompt":"Another"}}`)
+	if msg := next(); msg["id"] != 2.0 || msg["error"].(map[string]any)["code"] != float64(codeBusy) {
+		t.Errorf("got %v, want the second prompt refused", msg)
+	}
+	send(fmt.Sprintf(`{"jsonrpc":"2.0","id":3,"method":"approveTool","params":{"id":%v,"approved":true}}`, params["id"]))
+	if msg := next(); msg["id"] != 3.0 || msg["error"] != nil {
+		t.Errorf("got %v, want the approval acknowledged", msg)
+	}
+	if msg := next(); msg["method"] != "text" || msg["params"].(map[string]any)["text"] != "Done." {
+		t.Errorf("got %v, want the response text", msg)
+	}
+	if msg := next(); msg["id"] != 1.0 || msg["result"].(map[string]any)["response"] != "Done." {
+		t.Errorf("got %v, want the prompt answered", msg)
+	}
+	data, err := os.ReadFile("hello.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", string(data))
+
+	send(`{"jsonrpc":"2.0","id":4,"method":"getHistory"}`)
+	history, _ := next()["result"].(map[string]any)["history"].([]any)
+	if assert.Len(t, history, 4) {
+		call := history[1].(map[string]any)["parts"].([]any)[0].(map[string]any)["functionCall"].(map[string]any)
+		assert.Equal(t, "write_file", call["name"])
+		assert.Equal(t, "Done.", history[3].(map[string]any)["parts"].([]any)[0].(map[string]any)["text"])
+	}
+	send(`{"jsonrpc":"2.0","id":5,"method":"cancel"}`)
+	if msg := next(); msg["result"].(map[string]any)["cancelled"] != false {
+		t.Errorf("got %v, want nothing to cancel", msg)
+	}
+	send(`{"jsonrpc":"2.0","id":6,"method":"unknown"}`)
+	if msg := next(); msg["error"].(map[string]any)["code"] != float64(codeMethodNotFound) {
+		t.Errorf("got %v, want the method to be unknown", msg)
+	}
+	send(`not json`)
+	if msg := next(); msg["error"].(map[string]any)["code"] != float64(codeParseError) {
+		t.Errorf("got %v, want a parse error", msg)
+	}
+
+	requests.Close()
+	assert.NoError(t, <-served)
+	assert.Len(t, bodies, 2)
+}
+
+func TestREPL(t *testing.T) {
+	restore := config.SetUserHomeDirForTesting(t.TempDir(), nil)
+	defer restore()
+	t.Chdir(t.TempDir())
+
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `[{"candidates":[{"content":{"parts":[{"text":"Answer %d."}]}}]}]`+"\n", len(bodies))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey("fake-api-key"), option.WithEndpoint(server.URL))
+	assert.NoError(t, err)
+
+	in := strings.NewReader("First\nSecond \\\nline\n/unknown\n/clear\nThird\n/quit\nNever sent\n")
+	var out strings.Builder
+	err = REPL(ctx, &config.Settings{}, client.GenerativeModel("gemini-pro"), in, &out, tools.ApprovalDefault, nil)
+	assert.NoError(t, err)
+
+	assert.Contains(t, out.String(), "> Answer 1.\n")
+	assert.Contains(t, out.String(), ". Answer 2.\n")
+	assert.Contains(t, out.String(), "Unknown command /unknown")
+	if assert.Len(t, bodies, 3) {
+		assert.Contains(t, bodies[1], "First", "Expected the conversation to be continued")
+		assert.Contains(t, bodies[1], `Second \nline`)
+		assert.NotContains(t, bodies[2], "First", "Expected /clear to start a new conversation")
+	}
+}
@@ -0,0 +1,113 @@
+package noninteractive
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/tools"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// replHelp lists the commands of the plain REPL.
+const replHelp = `Commands:
+  /help   Show this help
+  /clear  Start a new conversation
+  /quit   Leave (also /exit or end of input)
+End a line with \ to continue the prompt on the next one. Ctrl-C stops
+the running prompt.
+`
+
+// REPL runs a conversation with model on plain lines of text read from in
+// and written to out, without the escape sequences of the TUI, for serial
+// consoles, editor shell buffers and the like. Each line is a prompt,
+// answered in the conversation so far; tool calls that approval does not
+// approve are asked about on the same lines. An interrupt stops the
+// running prompt, which leaves the conversation as it was. It returns
+// once in ends, or /quit or /exit is entered.
+func REPL(ctx context.Context, cfg *config.Settings, model *genai.GenerativeModel, in io.Reader, out io.Writer, approval tools.ApprovalMode, allowed []string) error {
+	r := bufio.NewReader(in)
+	ws, err := newWorkspace(ctx, cfg, model, promptConfirmer(r, out), approval, allowed)
+	if err != nil {
+		return err
+	}
+	defer ws.MCP.Close()
+
+	fmt.Fprintln(out, "Type a prompt, or /help for the commands.")
+	var history []*genai.Content
+	for {
+		prompt, err := readPrompt(r, out)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				fmt.Fprintln(out)
+				return nil
+			}
+			return err
+		}
+		switch strings.TrimSpace(prompt) {
+		case "":
+			continue
+		case "/quit", "/exit":
+			return nil
+		case "/help":
+			fmt.Fprint(out, replHelp)
+			continue
+		case "/clear":
+			history = nil
+			fmt.Fprintln(out, "Started a new conversation.")
+			continue
+		}
+		if strings.HasPrefix(prompt, "/") && !strings.ContainsAny(strings.TrimSpace(prompt), " \n") {
+			fmt.Fprintf(out, "Unknown command %s, see /help.\n", strings.TrimSpace(prompt))
+			continue
+		}
+
+		turnCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
+		result, err := Continue(turnCtx, cfg, ws, model, history, []genai.Part{genai.Text(prompt)}, func(text string) {
+			fmt.Fprint(out, text)
+		})
+		cancelled := turnCtx.Err() != nil
+		stop()
+		fmt.Fprintln(out)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil && cancelled:
+			fmt.Fprintln(out, "Cancelled.")
+		case err != nil:
+			fmt.Fprintf(out, "Error: %v\n", err)
+		default:
+			history = result.History
+		}
+	}
+}
+
+// readPrompt reads a prompt from r after writing the prompt marker to out.
+// Lines ending with a backslash are continued on the next one.
+func readPrompt(r *bufio.Reader, out io.Writer) (string, error) {
+	var lines []string
+	marker := "> "
+	for {
+		fmt.Fprint(out, marker)
+		line, err := r.ReadString('\n')
+		if err != nil && (line == "" || !errors.Is(err, io.EOF)) {
+			if len(lines) > 0 && errors.Is(err, io.EOF) {
+				return strings.Join(lines, "\n"), nil
+			}
+			return "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if cont, ok := strings.CutSuffix(line, `\`); ok {
+			lines = append(lines, cont)
+			marker = ". "
+			continue
+		}
+		return strings.Join(append(lines, line), "\n"), nil
+	}
+}
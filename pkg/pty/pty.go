@@ -0,0 +1,37 @@
+// Package pty runs commands in pseudo-terminals, so that programs that
+// prompt for input or draw on the terminal behave as they would for the
+// user.
+package pty
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// ErrUnsupported is returned on platforms without pseudo-terminals.
+var ErrUnsupported = errors.New("pseudo-terminals are not supported on this platform")
+
+// Start starts cmd in a new session with a new pseudo-terminal of rows by
+// cols as its controlling terminal, stdin, stdout and stderr. It returns
+// the terminal's master side, which reads the command's output and writes
+// its input, and which the caller must close after cmd exits.
+func Start(cmd *exec.Cmd, rows, cols int) (*os.File, error) {
+	ptmx, tty, err := open()
+	if err != nil {
+		return nil, err
+	}
+	// The command has its own copy of the terminal once started.
+	defer tty.Close()
+	if err := Resize(ptmx, rows, cols); err != nil {
+		ptmx.Close()
+		return nil, err
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = tty, tty, tty
+	setControllingTerminal(cmd)
+	if err := cmd.Start(); err != nil {
+		ptmx.Close()
+		return nil, err
+	}
+	return ptmx, nil
+}
@@ -0,0 +1,47 @@
+package pty
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// open opens a new pseudo-terminal and returns its master and slave sides.
+func open() (ptmx, tty *os.File, err error) {
+	ptmx, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	var name [128]byte
+	err = control(ptmx, func(fd int) error {
+		if err := ioctl(fd, unix.TIOCPTYGRANT, 0); err != nil {
+			return fmt.Errorf("grantpt: %w", err)
+		}
+		if err := ioctl(fd, unix.TIOCPTYUNLK, 0); err != nil {
+			return fmt.Errorf("unlockpt: %w", err)
+		}
+		if err := ioctl(fd, unix.TIOCPTYGNAME, uintptr(unsafe.Pointer(&name[0]))); err != nil {
+			return fmt.Errorf("ptsname: %w", err)
+		}
+		return nil
+	})
+	if err == nil {
+		tty, err = os.OpenFile(string(name[:bytes.IndexByte(name[:], 0)]), os.O_RDWR|syscall.O_NOCTTY, 0)
+	}
+	if err != nil {
+		ptmx.Close()
+		return nil, nil, err
+	}
+	return ptmx, tty, nil
+}
+
+func ioctl(fd int, req uint, arg uintptr) error {
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(req), arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+//go:build !linux && !darwin
+
+package pty
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Supported reports whether this platform has pseudo-terminals.
+func Supported() bool {
+	return false
+}
+
+// Resize tells the programs reading the terminal of ptmx that it has rows
+// and cols.
+func Resize(ptmx *os.File, rows, cols int) error {
+	return ErrUnsupported
+}
+
+func setControllingTerminal(cmd *exec.Cmd) {}
+
+func open() (ptmx, tty *os.File, err error) {
+	return nil, nil, ErrUnsupported
+}
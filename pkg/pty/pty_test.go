@@ -0,0 +1,43 @@
+package pty
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestStart(t *testing.T) {
+	if !Supported() {
+		t.Skip("no pseudo-terminals on this platform")
+	}
+	cmd := exec.Command("sh", "-c", `[ -t 0 ] && echo terminal; stty size; read answer; echo "got $answer"`)
+	ptmx, err := Start(cmd, 24, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ptmx.Close()
+	if _, err := io.WriteString(ptmx, "yes\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// Reading fails once the command and the terminal are gone.
+		io.Copy(&out, ptmx)
+	}()
+	if err := cmd.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	got := strings.ReplaceAll(out.String(), "\r\n", "\n")
+	for _, want := range []string{"terminal\n", "24 100\n", "got yes\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output %q does not contain %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+//go:build linux || darwin
+
+package pty
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Supported reports whether this platform has pseudo-terminals.
+func Supported() bool {
+	return true
+}
+
+// Resize tells the programs reading the terminal of ptmx that it has rows
+// and cols.
+func Resize(ptmx *os.File, rows, cols int) error {
+	return control(ptmx, func(fd int) error {
+		return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, &unix.Winsize{Row: uint16(rows), Col: uint16(cols)})
+	})
+}
+
+func setControllingTerminal(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	// The terminal is the child's stdin, descriptor 0.
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0
+}
+
+// control runs f with the descriptor of file. Unlike File.Fd, it leaves
+// the file non-blocking, so that closing it ends pending reads.
+func control(file *os.File, f func(fd int) error) error {
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var ferr error
+	if err := conn.Control(func(fd uintptr) { ferr = f(int(fd)) }); err != nil {
+		return err
+	}
+	return ferr
+}
@@ -0,0 +1,157 @@
+// Package respcache caches model responses on disk, keyed by everything
+// that goes into a request, so that scripts and tests repeating the same
+// non-interactive invocation do not spend quota on it.
+package respcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// DefaultTTL is how long responses are reused unless model.responseCache.ttl
+// says otherwise.
+const DefaultTTL = 24 * time.Hour
+
+// Cache stores responses in ~/.gemini/cache/responses.
+type Cache struct {
+	dir string
+	ttl time.Duration
+}
+
+// entry is a cached response.
+type entry struct {
+	Created time.Time `json:"created"`
+	Parts   []part    `json:"parts"`
+}
+
+// part is a genai.Part of the kinds a cached response may hold.
+type part struct {
+	Text         *string             `json:"text,omitempty"`
+	FunctionCall *genai.FunctionCall `json:"functionCall,omitempty"`
+	Blob         *genai.Blob         `json:"blob,omitempty"`
+}
+
+// New returns the cache configured by settings, which may be nil.
+func New(settings *config.ResponseCacheSettings) (*Cache, error) {
+	ttl := DefaultTTL
+	if settings != nil && settings.TTL != "" {
+		d, err := time.ParseDuration(settings.TTL)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid model.responseCache.ttl %q: expected a duration such as 12h", settings.TTL)
+		}
+		ttl = d
+	}
+	dir, err := config.UserDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{dir: filepath.Join(dir, "cache", "responses"), ttl: ttl}, nil
+}
+
+// Key identifies a request: params, which name the model and hold any
+// parameters model does not carry, the configuration of model, the
+// conversation so far and the new message.
+func Key(params any, model *genai.GenerativeModel, history []*genai.Content, parts []genai.Part) string {
+	req := struct {
+		Params            any
+		GenerationConfig  genai.GenerationConfig
+		SafetySettings    []*genai.SafetySetting
+		Tools             []*genai.Tool
+		ToolConfig        *genai.ToolConfig
+		SystemInstruction []any
+		History           [][]any
+		Parts             []any
+	}{
+		Params:           params,
+		GenerationConfig: model.GenerationConfig,
+		SafetySettings:   model.SafetySettings,
+		Tools:            model.Tools,
+		ToolConfig:       model.ToolConfig,
+		Parts:            typed(parts),
+	}
+	if model.SystemInstruction != nil {
+		req.SystemInstruction = typed(model.SystemInstruction.Parts)
+	}
+	for _, c := range history {
+		req.History = append(req.History, append([]any{c.Role}, typed(c.Parts)...))
+	}
+	data, _ := json.Marshal(req)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// typed tags parts with their type, which JSON alone would lose.
+func typed(parts []genai.Part) []any {
+	out := make([]any, len(parts))
+	for i, p := range parts {
+		out[i] = []any{fmt.Sprintf("%T", p), p}
+	}
+	return out
+}
+
+// Get returns the response cached under key, unless it has expired.
+func (c *Cache) Get(key string) ([]genai.Part, bool) {
+	path := filepath.Join(c.dir, key+".json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var e entry
+	if json.Unmarshal(data, &e) != nil || time.Since(e.Created) > c.ttl {
+		os.Remove(path)
+		return nil, false
+	}
+	var parts []genai.Part
+	for _, p := range e.Parts {
+		switch {
+		case p.Text != nil:
+			parts = append(parts, genai.Text(*p.Text))
+		case p.FunctionCall != nil:
+			parts = append(parts, *p.FunctionCall)
+		case p.Blob != nil:
+			parts = append(parts, *p.Blob)
+		}
+	}
+	return parts, true
+}
+
+// Put caches the response parts under key. Responses with parts of other
+// kinds than text, function calls and blobs are not cached.
+func (c *Cache) Put(key string, parts []genai.Part) error {
+	e := entry{Created: time.Now()}
+	for _, p := range parts {
+		switch v := p.(type) {
+		case genai.Text:
+			s := string(v)
+			e.Parts = append(e.Parts, part{Text: &s})
+		case genai.FunctionCall:
+			e.Parts = append(e.Parts, part{FunctionCall: &v})
+		case genai.Blob:
+			e.Parts = append(e.Parts, part{Blob: &v})
+		default:
+			return errors.New("the response cannot be cached")
+		}
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+	path := filepath.Join(c.dir, key+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,62 @@
+package respcache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google/generative-ai-go/genai"
+)
+
+func TestKey(t *testing.T) {
+	var client *genai.Client
+	model := client.GenerativeModel("gemini-pro")
+	parts := []genai.Part{genai.Text("hi")}
+	key := Key("gemini-pro", model, nil, parts)
+
+	if Key("gemini-pro", model, nil, []genai.Part{genai.Text("hi")}) != key {
+		t.Error("Key differs for identical requests")
+	}
+	if Key("gemini-flash", model, nil, parts) == key {
+		t.Error("Key ignores the parameters")
+	}
+	if Key("gemini-pro", model, []*genai.Content{{Role: "user", Parts: parts}}, parts) == key {
+		t.Error("Key ignores the history")
+	}
+	model.SetTemperature(0.5)
+	if Key("gemini-pro", model, nil, parts) == key {
+		t.Error("Key ignores the generation config")
+	}
+}
+
+func TestGetAndPut(t *testing.T) {
+	restore := config.SetUserHomeDirForTesting(t.TempDir(), nil)
+	defer restore()
+
+	c, err := New(&config.ResponseCacheSettings{TTL: "1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get("k"); ok {
+		t.Fatal("Get on an empty cache succeeded")
+	}
+	parts := []genai.Part{genai.Text("hello"), genai.FunctionCall{Name: "read_file", Args: map[string]any{"path": "a"}}}
+	if err := c.Put("k", parts); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := c.Get("k")
+	if !ok || !reflect.DeepEqual(got, parts) {
+		t.Errorf("Get = %#v, %v; want %#v", got, ok, parts)
+	}
+
+	c.ttl = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	if _, ok := c.Get("k"); ok {
+		t.Error("Get returned an expired response")
+	}
+
+	if _, err := New(&config.ResponseCacheSettings{TTL: "soon"}); err == nil {
+		t.Error("New accepted an invalid TTL")
+	}
+}
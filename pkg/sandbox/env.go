@@ -0,0 +1,85 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+// forwardedEnv are the variables of the CLI's environment it needs in the
+// sandbox too, if they are set: credentials and project, the model, the
+// resolved options, and how the terminal renders.
+var forwardedEnv = []string{
+	"GEMINI_API_KEY", "GOOGLE_API_KEY", "GOOGLE_CLOUD_PROJECT", "GOOGLE_CLOUD_LOCATION",
+	"GOOGLE_GENAI_USE_VERTEXAI", "GOOGLE_GENAI_USE_GCA", "GOOGLE_APPLICATION_CREDENTIALS",
+	"GEMINI_MODEL", "GEMINI_CLI_NO_RELAUNCH", OptionsEnv, "DEBUG",
+	"TERM", "COLORTERM", "NO_COLOR", "FORCE_COLOR", "LANG", "LC_ALL",
+}
+
+// cliArgs returns the run arguments giving the CLI in the sandbox what it
+// needs from the host: the forwarded variables, by name so that their
+// values stay off the command line, and the user's ~/.gemini directory at
+// its host path. Credentials are mounted read-only.
+func cliArgs() ([]string, error) {
+	var args []string
+	for _, name := range forwardedEnv {
+		if _, ok := os.LookupEnv(name); ok {
+			args = append(args, "--env", name)
+		}
+	}
+
+	userDir, err := config.UserDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(userDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", userDir, err)
+	}
+	args = append(args, "--env", "HOME="+filepath.Dir(userDir), "--volume", userDir+":"+userDir)
+
+	readOnly := []string{filepath.Join(userDir, "oauth_creds.json"), os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")}
+	if configDir, err := os.UserConfigDir(); err == nil {
+		readOnly = append(readOnly, filepath.Join(configDir, "gcloud"))
+	}
+	for _, path := range readOnly {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			args = append(args, "--volume", path+":"+path+":ro")
+		}
+	}
+	return args, nil
+}
+
+// allowNestedEnv lets a sandbox start in a container that is not one.
+const allowNestedEnv = "GEMINI_SANDBOX_ALLOW_NESTED"
+
+// containerMarker returns the file showing that the CLI runs in a
+// container, or "" if it does not. It is a variable for testing.
+var containerMarker = func() string {
+	for _, path := range []string{"/.dockerenv", "/run/.containerenv"} {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// checkNested refuses to start a container sandbox in a container, which
+// usually means the CLI is in a sandbox it does not know about, such as one
+// started by hand, where the container command is missing or talks to the
+// host's daemon.
+func checkNested(command string) error {
+	if command == "sandbox-exec" || os.Getenv(allowNestedEnv) == "true" {
+		return nil
+	}
+	if marker := containerMarker(); marker != "" {
+		return fmt.Errorf("the CLI is already running in a container (%s exists), so it will not start a %s sandbox inside it; "+
+			"turn the sandbox off with --sandbox=false or GEMINI_SANDBOX=false, or set %s=true if nesting is intended",
+			marker, command, allowNestedEnv)
+	}
+	return nil
+}
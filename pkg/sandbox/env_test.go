@@ -0,0 +1,71 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCLIArgs(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("GEMINI_API_KEY", "secret")
+	t.Setenv("TERM", "xterm-256color")
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "")
+	os.Unsetenv("GOOGLE_CLOUD_PROJECT")
+	adc := filepath.Join(home, "adc.json")
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", adc)
+	for _, path := range []string{adc, filepath.Join(home, ".gemini", "oauth_creds.json")} {
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte("{}"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	args, err := cliArgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(args, " ")
+	userDir := filepath.Join(home, ".gemini")
+	for _, want := range []string{
+		"--env GEMINI_API_KEY ",
+		"--env TERM ",
+		"--env GOOGLE_APPLICATION_CREDENTIALS ",
+		"--env HOME=" + home + " --volume " + userDir + ":" + userDir,
+		"--volume " + userDir + "/oauth_creds.json:" + userDir + "/oauth_creds.json:ro",
+		"--volume " + adc + ":" + adc + ":ro",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in %s", want, got)
+		}
+	}
+	if strings.Contains(got, "secret") || strings.Contains(got, "GOOGLE_CLOUD_PROJECT") || strings.Contains(got, "gcloud") {
+		t.Errorf("Expected only set variables and existing files, by name, got %s", got)
+	}
+}
+
+func TestCheckNested(t *testing.T) {
+	orig := containerMarker
+	t.Cleanup(func() { containerMarker = orig })
+	t.Setenv(allowNestedEnv, "")
+
+	containerMarker = func() string { return "/.dockerenv" }
+	if err := checkNested("docker"); err == nil || !strings.Contains(err.Error(), "already running in a container (/.dockerenv exists)") {
+		t.Errorf("checkNested(docker) = %v, want the nesting to be refused", err)
+	}
+	if err := checkNested("sandbox-exec"); err != nil {
+		t.Errorf("checkNested(sandbox-exec) = %v, want nil", err)
+	}
+	t.Setenv(allowNestedEnv, "true")
+	if err := checkNested("podman"); err != nil {
+		t.Errorf("checkNested(podman) = %v, want nesting to be allowed by %s", err, allowNestedEnv)
+	}
+	t.Setenv(allowNestedEnv, "")
+	containerMarker = func() string { return "" }
+	if err := checkNested("docker"); err != nil {
+		t.Errorf("checkNested(docker) = %v, want nil outside a container", err)
+	}
+}
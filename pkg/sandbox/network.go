@@ -0,0 +1,110 @@
+package sandbox
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// The tools.sandboxNetwork presets.
+const (
+	// NetworkOpen lets the sandbox reach any host, the default.
+	NetworkOpen = "open"
+	// NetworkNone cuts the sandbox off from the network.
+	NetworkNone = "none"
+	// NetworkRestricted lets the sandbox reach only the hosts in
+	// tools.sandboxAllowedHosts, through an allowlist proxy.
+	NetworkRestricted = "restricted"
+)
+
+const (
+	// internalNetwork is the network of restricted sandboxes, which has no
+	// route out; only the proxy containers attached to it do.
+	internalNetwork = "gemini-cli-sandbox"
+	// proxyPort is the port the allowlist proxy listens on.
+	proxyPort = 8877
+)
+
+// apiHosts are the hosts the CLI itself needs when it runs in a restricted
+// sandbox.
+var apiHosts = []string{"*.googleapis.com", "accounts.google.com"}
+
+// validateNetwork checks the network preset of a sandbox.
+func (c *Config) validateNetwork() error {
+	switch c.Network {
+	case "", NetworkOpen, NetworkNone:
+	case NetworkRestricted:
+		if c.Command != "docker" && c.Command != "podman" {
+			return fmt.Errorf("tools.sandboxNetwork %q needs docker or podman, not %s", c.Network, c.Command)
+		}
+	default:
+		return fmt.Errorf("invalid tools.sandboxNetwork %q: must be %s, %s or %s", c.Network, NetworkNone, NetworkRestricted, NetworkOpen)
+	}
+	if c.Network == "" {
+		return nil
+	}
+	for _, arg := range c.RunArgs {
+		name, _, _ := strings.Cut(arg, "=")
+		if name == "--network" || name == "--net" {
+			return fmt.Errorf("tools.sandboxRunArgs: %s conflicts with tools.sandboxNetwork", name)
+		}
+	}
+	return nil
+}
+
+// networkProxy is the allowlist proxy container of a restricted sandbox.
+type networkProxy struct {
+	command, name string
+}
+
+// startNetwork returns the run arguments that put a container on the
+// network of its preset. A restricted sandbox gets a network without a route
+// out and a proxy container, named after the sandbox, that forwards requests
+// to the allowed hosts; stop it when the sandbox exits.
+func (c *Config) startNetwork(sandboxName string, allowed []string) ([]string, *networkProxy, error) {
+	switch c.Network {
+	case NetworkNone:
+		return []string{"--network=none"}, nil, nil
+	case NetworkRestricted:
+	default:
+		return nil, nil, nil
+	}
+
+	if err := exec.Command(c.Command, "network", "inspect", internalNetwork).Run(); err != nil {
+		create := exec.Command(c.Command, "network", "create", "--internal", internalNetwork)
+		if out, err := create.CombinedOutput(); err != nil && !bytes.Contains(out, []byte("already exists")) {
+			return nil, nil, fmt.Errorf("failed to create the sandbox network: %w: %s", err, bytes.TrimSpace(out))
+		}
+	}
+
+	p := &networkProxy{command: c.Command, name: sandboxName + "-proxy"}
+	args := []string{"run", "--detach", "--rm", "--init", "--name", p.name, "--env", "SANDBOX=" + p.name,
+		c.Image, os.Args[0], "sandbox-proxy", fmt.Sprintf("--listen=:%d", proxyPort)}
+	for _, host := range allowed {
+		args = append(args, "--allow="+host)
+	}
+	if out, err := exec.Command(c.Command, args...).CombinedOutput(); err != nil {
+		return nil, nil, fmt.Errorf("failed to start the sandbox proxy: %w: %s", err, bytes.TrimSpace(out))
+	}
+	if out, err := exec.Command(c.Command, "network", "connect", internalNetwork, p.name).CombinedOutput(); err != nil {
+		p.stop()
+		return nil, nil, fmt.Errorf("failed to connect the sandbox proxy: %w: %s", err, bytes.TrimSpace(out))
+	}
+
+	proxy := fmt.Sprintf("http://%s:%d", p.name, proxyPort)
+	runArgs := []string{"--network", internalNetwork}
+	for _, name := range []string{"HTTPS_PROXY", "HTTP_PROXY", "https_proxy", "http_proxy"} {
+		runArgs = append(runArgs, "--env", name+"="+proxy)
+	}
+	runArgs = append(runArgs, "--env", "NO_PROXY=localhost,127.0.0.1", "--env", "no_proxy=localhost,127.0.0.1")
+	return runArgs, p, nil
+}
+
+// stop removes the proxy container.
+func (p *networkProxy) stop() {
+	if p != nil {
+		exec.Command(p.command, "rm", "--force", p.name).Run()
+	}
+}
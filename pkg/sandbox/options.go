@@ -0,0 +1,60 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// OptionsEnv passes the options the CLI resolved before starting the
+// sandbox to the CLI in it.
+const OptionsEnv = "GEMINI_SANDBOX_OPTIONS"
+
+// Options are the command line as the CLI resolved it before starting the
+// sandbox. The CLI in the sandbox continues with them instead of parsing
+// its arguments again: it is started with only the command and positional
+// arguments, and takes the flags from OptionsEnv.
+type Options struct {
+	// Command is the path of the subcommand run, without the root command,
+	// such as ["mcp", "list"].
+	Command []string `json:"command,omitempty"`
+	// Args are the positional arguments.
+	Args []string `json:"args,omitempty"`
+	// Flags are the flags set on the command line by name, with one value
+	// per item for lists.
+	Flags map[string][]string `json:"flags,omitempty"`
+}
+
+// argv returns the arguments the CLI in the sandbox is started with.
+func (o *Options) argv() []string {
+	args := append([]string{}, o.Command...)
+	if len(o.Args) > 0 {
+		args = append(args, "--")
+		args = append(args, o.Args...)
+	}
+	return args
+}
+
+// export sets OptionsEnv to the encoded options, for the sandbox to
+// inherit.
+func (o *Options) export() error {
+	data, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	return os.Setenv(OptionsEnv, string(data))
+}
+
+// InheritedOptions returns the options the CLI outside the sandbox passed
+// in, or nil if it passed none.
+func InheritedOptions() (*Options, error) {
+	value := os.Getenv(OptionsEnv)
+	if value == "" {
+		return nil, nil
+	}
+	var o Options
+	if err := json.Unmarshal([]byte(value), &o); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", OptionsEnv, err)
+	}
+	return &o, nil
+}
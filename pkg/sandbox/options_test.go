@@ -0,0 +1,42 @@
+package sandbox
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestOptionsRoundTrip(t *testing.T) {
+	t.Setenv(OptionsEnv, "")
+	opts := &Options{
+		Command: []string{"mcp", "list"},
+		Args:    []string{"--not-a-flag"},
+		Flags:   map[string][]string{"prompt-interactive": {"hi"}, "allowed-tools": {"a", "b"}},
+	}
+	if err := opts.export(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := InheritedOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, opts) {
+		t.Errorf("InheritedOptions() = %+v, want %+v", got, opts)
+	}
+	if want := []string{"mcp", "list", "--", "--not-a-flag"}; !reflect.DeepEqual(opts.argv(), want) {
+		t.Errorf("argv() = %q, want %q", opts.argv(), want)
+	}
+}
+
+func TestInheritedOptionsUnset(t *testing.T) {
+	t.Setenv(OptionsEnv, "")
+	os.Unsetenv(OptionsEnv)
+	opts, err := InheritedOptions()
+	if opts != nil || err != nil {
+		t.Errorf("InheritedOptions() = %v, %v, want nil", opts, err)
+	}
+	t.Setenv(OptionsEnv, "{")
+	if _, err := InheritedOptions(); err == nil {
+		t.Error("InheritedOptions() with invalid JSON: want error")
+	}
+}
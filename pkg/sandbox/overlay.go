@@ -0,0 +1,280 @@
+package sandbox
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/diff"
+)
+
+// overlay keeps the workspace of a read-only sandbox unchanged: the
+// workspace is the lower layer of an overlay mount, and everything written
+// in the sandbox lands in the upper directory instead, from which the
+// changes are turned into a patch when the sandbox exits.
+type overlay struct {
+	lower, upper, work string
+	// volume is the docker volume holding the mount, if one was created.
+	volume string
+}
+
+// newOverlay creates the upper and work directories for the workspace
+// lower under the project's temporary directory.
+func newOverlay(lower string) (*overlay, error) {
+	tmp, err := config.ProjectTempDir(lower)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(tmp, "sandbox-overlay", time.Now().Format("20060102-150405"))
+	o := &overlay{lower: lower, upper: filepath.Join(dir, "upper"), work: filepath.Join(dir, "work")}
+	for _, d := range []string{o.upper, o.work} {
+		if err := os.MkdirAll(d, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create the sandbox overlay: %w", err)
+		}
+	}
+	return o, nil
+}
+
+// mountArgs returns the run arguments mounting the overlay at the
+// workspace's path. Podman mounts overlays itself; docker needs a volume
+// backed by an overlay mount, created here and removed by cleanup.
+func (o *overlay) mountArgs(command string) ([]string, error) {
+	if command == "podman" {
+		return []string{"--volume", fmt.Sprintf("%s:%s:O,upperdir=%s,workdir=%s", o.lower, o.lower, o.upper, o.work)}, nil
+	}
+	o.volume = "gemini-overlay-" + filepath.Base(filepath.Dir(o.upper))
+	create := exec.Command(command, "volume", "create", "--driver", "local",
+		"--opt", "type=overlay", "--opt", "device=overlay",
+		"--opt", fmt.Sprintf("o=lowerdir=%s,upperdir=%s,workdir=%s", o.lower, o.upper, o.work),
+		o.volume)
+	if out, err := create.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("failed to create the overlay volume: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return []string{"--mount", fmt.Sprintf("type=volume,src=%s,dst=%s", o.volume, o.lower)}, nil
+}
+
+// cleanup removes the docker volume, leaving the directories for the patch.
+func (o *overlay) cleanup(command string) {
+	if o.volume != "" {
+		exec.Command(command, "volume", "rm", "--force", o.volume).Run()
+	}
+}
+
+// overlayPatch returns the changes recorded in the upper directory of an
+// overlay as a patch against lower, to apply with `git apply` or `patch
+// -p1`, and the workspace-relative names of the changed files. Deleted files
+// are whiteouts, character devices, in upper. Binary files are git binary
+// patches, which only git apply takes.
+func overlayPatch(lower, upper string) (string, []string, error) {
+	var names []string
+	err := filepath.WalkDir(upper, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			rel, _ := filepath.Rel(upper, path)
+			names = append(names, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	sort.Strings(names)
+
+	var (
+		patch   strings.Builder
+		changed []string
+	)
+	for _, rel := range names {
+		info, err := os.Lstat(filepath.Join(upper, rel))
+		if err != nil {
+			return "", nil, err
+		}
+		var (
+			old     string
+			existed bool
+		)
+		if !isDir(filepath.Join(lower, rel)) {
+			if old, existed, err = readIfExists(filepath.Join(lower, rel)); err != nil {
+				return "", nil, err
+			}
+		}
+		name := filepath.ToSlash(rel)
+		oldName, newName := "a/"+name, "b/"+name
+		var content string
+		switch {
+		case info.Mode()&fs.ModeCharDevice != 0 && isDir(filepath.Join(lower, rel)):
+			// A deleted directory: every file in it was deleted.
+			err := filepath.WalkDir(filepath.Join(lower, rel), func(path string, d fs.DirEntry, err error) error {
+				if err != nil || !d.Type().IsRegular() {
+					return err
+				}
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				rel, _ := filepath.Rel(lower, path)
+				changed = append(changed, rel)
+				writeFilePatch(&patch, "a/"+filepath.ToSlash(rel), "/dev/null", string(data), "")
+				return nil
+			})
+			if err != nil {
+				return "", nil, err
+			}
+			continue
+		case info.Mode()&fs.ModeCharDevice != 0:
+			if !existed {
+				continue
+			}
+			newName = "/dev/null"
+		case info.Mode().IsRegular():
+			data, err := os.ReadFile(filepath.Join(upper, rel))
+			if err != nil {
+				return "", nil, err
+			}
+			content = string(data)
+			if existed && content == old {
+				continue
+			}
+			if !existed {
+				oldName = "/dev/null"
+			}
+		default:
+			continue
+		}
+		changed = append(changed, rel)
+		writeFilePatch(&patch, oldName, newName, old, content)
+	}
+	return patch.String(), changed, nil
+}
+
+// writeFilePatch writes the change of a file from old to new to patch, as a
+// unified diff, or as a git binary patch if either is binary. oldName is
+// /dev/null for a new file, and newName for a deleted one.
+func writeFilePatch(patch *strings.Builder, oldName, newName, old, new string) {
+	if strings.IndexByte(old, 0) >= 0 || strings.IndexByte(new, 0) >= 0 {
+		writeBinaryPatch(patch, oldName, newName, old, new)
+		return
+	}
+	fmt.Fprintf(patch, "--- %s\n+++ %s\n%s", oldName, newName, diff.Unified(old, new, 3))
+}
+
+// writeBinaryPatch writes the change of a binary file as git writes it with
+// --binary: the whole new content, and the old for reversing. git apply
+// wants the full blob hashes of both.
+func writeBinaryPatch(patch *strings.Builder, oldName, newName, old, new string) {
+	name := strings.TrimPrefix(newName, "b/")
+	if newName == "/dev/null" {
+		name = strings.TrimPrefix(oldName, "a/")
+	}
+	oldHash, newHash := blobHash(old), blobHash(new)
+	fmt.Fprintf(patch, "diff --git a/%s b/%s\n", name, name)
+	switch {
+	case oldName == "/dev/null":
+		patch.WriteString("new file mode 100644\n")
+		oldHash = strings.Repeat("0", len(newHash))
+	case newName == "/dev/null":
+		patch.WriteString("deleted file mode 100644\n")
+		newHash = strings.Repeat("0", len(oldHash))
+	}
+	fmt.Fprintf(patch, "index %s..%s\nGIT binary patch\n", oldHash, newHash)
+	writeLiteral(patch, new)
+	writeLiteral(patch, old)
+}
+
+// blobHash returns the hash git gives a file of content.
+func blobHash(content string) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	io.WriteString(h, content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeLiteral writes a literal hunk of a git binary patch: content
+// deflated, in lines of up to 52 bytes encoded in base 85, each starting
+// with a letter for its length.
+func writeLiteral(patch *strings.Builder, content string) {
+	var deflated bytes.Buffer
+	z := zlib.NewWriter(&deflated)
+	io.WriteString(z, content)
+	z.Close()
+	fmt.Fprintf(patch, "literal %d\n", len(content))
+	data := deflated.Bytes()
+	for len(data) > 0 {
+		n := min(len(data), 52)
+		if n <= 26 {
+			patch.WriteByte(byte('A' + n - 1))
+		} else {
+			patch.WriteByte(byte('a' + n - 27))
+		}
+		line := make([]byte, (n+3)/4*4)
+		copy(line, data[:n])
+		for i := 0; i < len(line); i += 4 {
+			v := binary.BigEndian.Uint32(line[i:])
+			var group [5]byte
+			for j := 4; j >= 0; j-- {
+				group[j] = base85[v%85]
+				v /= 85
+			}
+			patch.Write(group[:])
+		}
+		patch.WriteByte('\n')
+		data = data[n:]
+	}
+	patch.WriteByte('\n')
+}
+
+// base85 are the digits of git's base 85 encoding.
+const base85 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz!#$%&()*+-;<=>?@^_`{|}~"
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func readIfExists(path string) (string, bool, error) {
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return "", false, nil
+	case err != nil:
+		return "", false, err
+	}
+	return string(data), true, nil
+}
+
+// presentPatch saves the changes of a read-only sandbox next to its overlay
+// and tells the user how to apply them.
+func (o *overlay) presentPatch() error {
+	patch, changed, err := overlayPatch(o.lower, o.upper)
+	if err != nil {
+		return fmt.Errorf("failed to collect the sandbox's changes from %s: %w", o.upper, err)
+	}
+	if len(changed) == 0 {
+		fmt.Fprintln(os.Stderr, "The sandbox made no changes to the workspace.")
+		return nil
+	}
+	path := filepath.Join(filepath.Dir(o.upper), "changes.patch")
+	if err := os.WriteFile(path, []byte(patch), 0600); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "The sandbox changed %d file(s), which were kept out of the workspace:\n", len(changed))
+	for _, name := range changed {
+		fmt.Fprintf(os.Stderr, "  %s\n", name)
+	}
+	fmt.Fprintf(os.Stderr, "Review the changes in %s and apply them with:\n  git apply %s\n", path, path)
+	return nil
+}
@@ -0,0 +1,74 @@
+package sandbox
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOverlayPatch(t *testing.T) {
+	lower, upper := t.TempDir(), t.TempDir()
+	write := func(dir, name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(lower, "a.txt", "one\ntwo\n")
+	write(lower, "same.txt", "same\n")
+	write(upper, "a.txt", "one\n2\n")
+	write(upper, "same.txt", "same\n")
+	write(upper, "sub/new.txt", "hello\n")
+	write(upper, "image.bin", "\x00\x01")
+	write(lower, "data.bin", "\x00old")
+	write(upper, "data.bin", "\x00"+strings.Repeat("new binary content ", 10))
+
+	patch, changed, err := overlayPatch(lower, upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(changed, " "); got != "a.txt data.bin image.bin "+filepath.Join("sub", "new.txt") {
+		t.Errorf("changed = %s, want a.txt, data.bin, image.bin and sub/new.txt", got)
+	}
+	for _, want := range []string{
+		"--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+2\n",
+		"diff --git a/image.bin b/image.bin\nnew file mode 100644\nindex 0000000000000000000000000000000000000000..",
+		"diff --git a/data.bin b/data.bin\nindex ",
+		"--- /dev/null\n+++ b/sub/new.txt\n@@ -0,0 +1 @@\n+hello\n",
+	} {
+		if !strings.Contains(patch, want) {
+			t.Errorf("Expected the patch to contain %q, got:\n%s", want, patch)
+		}
+	}
+	if strings.Contains(patch, "same.txt") {
+		t.Errorf("Expected unchanged copies to be left out, got:\n%s", patch)
+	}
+
+	// git apply takes the binary changes along with the others.
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	path := filepath.Join(t.TempDir(), "changes.patch")
+	if err := os.WriteFile(path, []byte(patch), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("git", "apply", path)
+	cmd.Dir = lower
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git apply failed: %v\n%s\npatch:\n%s", err, out, patch)
+	}
+	for _, name := range changed {
+		got, _ := os.ReadFile(filepath.Join(lower, name))
+		want, _ := os.ReadFile(filepath.Join(upper, name))
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s = %q after git apply, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// AllowlistProxy is the HTTP proxy of a restricted sandbox. It forwards
+// requests and CONNECT tunnels to the hosts matching Allowed, and refuses
+// the others.
+type AllowlistProxy struct {
+	// Allowed are host patterns, such as "proxy.golang.org" or
+	// "*.npmjs.org:443", matched with path.Match. A pattern without a port
+	// matches any port.
+	Allowed []string
+
+	transport http.RoundTripper
+}
+
+// ServeProxy serves an AllowlistProxy for the allowed hosts on addr until
+// ctx is done.
+func ServeProxy(ctx context.Context, addr string, allowed []string) error {
+	srv := &http.Server{Addr: addr, Handler: &AllowlistProxy{Allowed: allowed}}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (p *AllowlistProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+	if r.URL.Host == "" {
+		http.Error(w, "this is a proxy; send absolute URLs", http.StatusBadRequest)
+		return
+	}
+	if !p.allowed(r.URL.Host, r.URL.Scheme) {
+		p.refuse(w, r.URL.Host)
+		return
+	}
+
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	for _, h := range []string{"Proxy-Connection", "Proxy-Authorization", "Connection", "Keep-Alive", "Te", "Trailer", "Upgrade"} {
+		out.Header.Del(h)
+	}
+	transport := p.transport
+	if transport == nil {
+		transport = &http.Transport{DialContext: (&net.Dialer{Timeout: 30 * time.Second}).DialContext}
+	}
+	resp, err := transport.RoundTrip(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for name, values := range resp.Header {
+		for _, v := range values {
+			w.Header().Add(name, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// tunnel connects the client to the host of a CONNECT request, such as for
+// HTTPS, and copies the bytes both ways.
+func (p *AllowlistProxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	if !p.allowed(r.Host, "https") {
+		p.refuse(w, r.Host)
+		return
+	}
+	upstream, err := net.DialTimeout("tcp", r.Host, 30*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "tunnels are not supported", http.StatusInternalServerError)
+		return
+	}
+	client, buf, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+	go func() {
+		// Bytes the client sent after the request are buffered already.
+		io.Copy(upstream, buf)
+		if c, ok := upstream.(interface{ CloseWrite() error }); ok {
+			c.CloseWrite()
+		}
+	}()
+	io.Copy(client, upstream)
+	client.Close()
+	upstream.Close()
+}
+
+func (p *AllowlistProxy) refuse(w http.ResponseWriter, host string) {
+	http.Error(w, fmt.Sprintf("the sandbox may not reach %s: it is not in tools.sandboxAllowedHosts", host), http.StatusForbidden)
+}
+
+// allowed reports whether hostport, with the default port of scheme if it
+// has none, matches one of the allowed patterns.
+func (p *AllowlistProxy) allowed(hostport, scheme string) bool {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host, port = strings.Trim(hostport, "[]"), map[string]string{"http": "80", "https": "443"}[scheme]
+	}
+	host = strings.ToLower(host)
+	for _, pattern := range p.Allowed {
+		pattern = strings.ToLower(pattern)
+		patternHost, patternPort, err := net.SplitHostPort(pattern)
+		if err != nil {
+			patternHost, patternPort = strings.Trim(pattern, "[]"), ""
+		}
+		if ok, _ := path.Match(patternHost, host); ok && (patternPort == "" || patternPort == port) {
+			return true
+		}
+	}
+	return false
+}
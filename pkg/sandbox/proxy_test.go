@@ -0,0 +1,87 @@
+package sandbox
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAllowlistProxy(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "hello from %s", r.URL.Path)
+	}))
+	defer upstream.Close()
+	upstreamURL, _ := url.Parse(upstream.URL)
+
+	proxy := httptest.NewServer(&AllowlistProxy{Allowed: []string{"127.0.0.1", "*.example:8080"}})
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	resp, err := client.Get(upstream.URL + "/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "hello from /page" {
+		t.Errorf("Expected the allowed host to be reached, got %s: %s", resp.Status, body)
+	}
+
+	resp, err = client.Get("http://localhost:" + upstreamURL.Port() + "/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden || !strings.Contains(string(body), "tools.sandboxAllowedHosts") {
+		t.Errorf("Expected other hosts to be refused, got %s: %s", resp.Status, body)
+	}
+
+	// CONNECT tunnels carry HTTPS; the tunnel here carries plain HTTP.
+	conn, err := net.Dial("tcp", proxyURL.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", upstreamURL.Host, upstreamURL.Host)
+	br := bufio.NewReader(conn)
+	resp, err = http.ReadResponse(br, nil)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("CONNECT = %v, %v; want 200", resp, err)
+	}
+	fmt.Fprintf(conn, "GET /tunnel HTTP/1.1\r\nHost: %s\r\nConnection: close\r\n\r\n", upstreamURL.Host)
+	resp, err = http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	if string(body) != "hello from /tunnel" {
+		t.Errorf("Expected the tunnel to reach the host, got %q", body)
+	}
+}
+
+func TestAllowlistProxyAllowed(t *testing.T) {
+	p := &AllowlistProxy{Allowed: []string{"proxy.golang.org", "*.npmjs.org:443", "[::1]"}}
+	for _, tt := range []struct {
+		hostport, scheme string
+		want             bool
+	}{
+		{"proxy.golang.org", "https", true},
+		{"PROXY.golang.org:8080", "http", true},
+		{"registry.npmjs.org", "https", true},
+		{"registry.npmjs.org:80", "http", false},
+		{"npmjs.org.evil.io:443", "https", false},
+		{"[::1]:3000", "http", true},
+	} {
+		if got := p.allowed(tt.hostport, tt.scheme); got != tt.want {
+			t.Errorf("allowed(%s, %s) = %v, want %v", tt.hostport, tt.scheme, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/shutdown"
+)
+
+// ScopeTools is the tools.sandboxScope that runs only the tools in the
+// sandbox, leaving the CLI itself on the host.
+const ScopeTools = "tools"
+
+// Runner runs the shell commands and file changes of tools in a container
+// while the CLI itself, with its credentials and terminal, stays on the
+// host. The workspace directories are mounted at the same paths in a
+// container started on first use, and every command is run in it with exec.
+type Runner struct {
+	cfg    *Config
+	mounts []string
+
+	mu        sync.Mutex
+	container string
+	proxy     *networkProxy
+}
+
+// NewRunner returns a runner for the sandbox cfg that mounts the given
+// directories. Only docker and podman can run tools on their own.
+func NewRunner(cfg *Config, mounts []string) (*Runner, error) {
+	switch cfg.Command {
+	case "docker", "podman":
+		if err := cfg.Validate(); err != nil {
+			return nil, err
+		}
+		return &Runner{cfg: cfg, mounts: mounts}, nil
+	}
+	return nil, fmt.Errorf("sandboxing only the tools needs docker or podman, not %s", cfg.Command)
+}
+
+// Command returns the command running argv in dir inside the container. The
+// container's own environment applies, plus env as NAME=value entries.
+func (r *Runner) Command(ctx context.Context, dir string, env []string, argv ...string) (*exec.Cmd, error) {
+	container, err := r.start()
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"exec", "-i", "--workdir", dir}
+	for _, kv := range env {
+		args = append(args, "--env", kv)
+	}
+	args = append(args, container)
+	args = append(args, argv...)
+	return exec.CommandContext(ctx, r.cfg.Command, args...), nil
+}
+
+// writeScript replaces the file $1 with stdin and gives it mode $2, through
+// a temporary file so that the file is never seen half written.
+const writeScript = `set -e
+mkdir -p "$(dirname "$1")"
+tmp="$(dirname "$1")/.$(basename "$1").$$.tmp"
+trap 'rm -f "$tmp"' EXIT
+cat > "$tmp"
+chmod "$2" "$tmp"
+mv -f "$tmp" "$1"`
+
+// WriteFile replaces the file at path with content inside the container,
+// creating its directory if needed.
+func (r *Runner) WriteFile(ctx context.Context, path, content string, mode os.FileMode) error {
+	cmd, err := r.Command(ctx, "/", nil, "sh", "-c", writeScript, "sh", path, fmt.Sprintf("%o", mode.Perm()))
+	if err != nil {
+		return err
+	}
+	cmd.Stdin = strings.NewReader(content)
+	return run(cmd)
+}
+
+// Remove removes the file at path inside the container. A missing file is
+// not an error.
+func (r *Runner) Remove(ctx context.Context, path string) error {
+	cmd, err := r.Command(ctx, "/", nil, "rm", "-f", "--", path)
+	if err != nil {
+		return err
+	}
+	return run(cmd)
+}
+
+// start starts the container unless it is running already, and stops it
+// again when the CLI exits.
+func (r *Runner) start() (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.container != "" {
+		return r.container, nil
+	}
+	if err := ensureSandboxImageIsPresent(r.cfg.Command, r.cfg.Image); err != nil {
+		return "", err
+	}
+
+	args := []string{"run", "--detach", "--rm", "--init", "--env", "SANDBOX=" + r.cfg.Command}
+	if runtime.GOOS == "linux" {
+		// Files the tools create belong to the user, not to root.
+		args = append(args, "--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
+	}
+	for _, dir := range r.mounts {
+		args = append(args, "--volume", dir+":"+dir)
+	}
+	if len(r.mounts) > 0 {
+		args = append(args, "--workdir", r.mounts[0])
+	}
+	args = append(args, r.cfg.runArgs()...)
+	network, proxy, err := r.cfg.startNetwork(fmt.Sprintf("gemini-cli-tools-%d", os.Getpid()), r.cfg.AllowedHosts)
+	if err != nil {
+		return "", err
+	}
+	args = append(args, network...)
+	args = append(args, r.cfg.Image, "tail", "-f", "/dev/null")
+	out, err := exec.Command(r.cfg.Command, args...).Output()
+	if err != nil {
+		proxy.stop()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			err = fmt.Errorf("%w: %s", err, bytes.TrimSpace(exitErr.Stderr))
+		}
+		return "", fmt.Errorf("failed to start the sandbox container: %w", err)
+	}
+	r.container, r.proxy = strings.TrimSpace(string(out)), proxy
+	shutdown.Register("stop the sandbox container", func(context.Context) error { return r.Close() })
+	return r.container, nil
+}
+
+// Close stops the container, if it was started.
+func (r *Runner) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.container == "" {
+		return nil
+	}
+	err := run(exec.Command(r.cfg.Command, "rm", "--force", r.container))
+	r.proxy.stop()
+	r.container, r.proxy = "", nil
+	return err
+}
+
+// run runs cmd, returning its error output as the error if it fails.
+func run(cmd *exec.Cmd) error {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
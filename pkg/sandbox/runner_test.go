@@ -0,0 +1,135 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeDocker stands in for docker: it logs its arguments, pretends to start
+// a container and runs exec'd commands on the host.
+const fakeDocker = `#!/bin/sh
+echo "$@" >> "$FAKE_DOCKER_LOG"
+case "$1" in
+images) echo image-id ;;
+network) [ "$2" != inspect ] ;;
+run) echo container-1 ;;
+exec)
+	shift
+	while [ $# -gt 0 ]; do
+		case "$1" in
+		-i) shift ;;
+		--workdir) cd "$2"; shift 2 ;;
+		--env) export "$2"; shift 2 ;;
+		*) break ;;
+		esac
+	done
+	shift
+	exec "$@" ;;
+esac
+`
+
+func TestRunner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake docker is a shell script")
+	}
+	bin, dir := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "docker"), []byte(fakeDocker), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	log := filepath.Join(bin, "log")
+	t.Setenv("FAKE_DOCKER_LOG", log)
+
+	if _, err := NewRunner(&Config{Command: "sandbox-exec"}, nil); err == nil {
+		t.Error("Expected sandbox-exec to be refused")
+	}
+	r, err := NewRunner(&Config{Command: "docker", Image: "img"}, []string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	cmd, err := r.Command(ctx, dir, []string{"GREETING=hi"}, "sh", "-c", `echo "$GREETING from $(pwd)"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := cmd.Output()
+	if err != nil || strings.TrimSpace(string(out)) != "hi from "+dir {
+		t.Errorf("Command output = %q, %v; want the greeting from %s", out, err, dir)
+	}
+
+	path := filepath.Join(dir, "sub", "a.txt")
+	if err := r.WriteFile(ctx, path, "hello\n", 0600); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Stat(a.txt) = %v, %v; want mode 0600", info, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "hello\n" {
+		t.Errorf("a.txt = %q", data)
+	}
+	if err := r.Remove(ctx, path); err != nil {
+		t.Fatalf("Remove() failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected a.txt to be removed")
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("Close() failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(log)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if runs := strings.Count(string(data), "\nrun "); runs != 1 {
+		t.Errorf("Expected one container to be started, got %d", runs)
+	}
+	if !strings.Contains(lines[1], "--volume "+dir+":"+dir) || !strings.HasSuffix(lines[1], "img tail -f /dev/null") {
+		t.Errorf("Unexpected run command: %s", lines[1])
+	}
+	if last := lines[len(lines)-1]; last != "rm --force container-1" {
+		t.Errorf("Expected the container to be removed on Close, got %s", last)
+	}
+}
+
+func TestRunnerRestrictedNetwork(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake docker is a shell script")
+	}
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "docker"), []byte(fakeDocker), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	log := filepath.Join(bin, "log")
+	t.Setenv("FAKE_DOCKER_LOG", log)
+
+	r, err := NewRunner(&Config{Command: "docker", Image: "img", Network: NetworkRestricted, AllowedHosts: []string{"proxy.golang.org"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Command(context.Background(), "/", nil, "true"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("Close() failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(log)
+	proxy := fmt.Sprintf("gemini-cli-tools-%d-proxy", os.Getpid())
+	for _, want := range []string{
+		"network create --internal gemini-cli-sandbox\n",
+		"--name " + proxy + " --env SANDBOX=" + proxy + " img " + os.Args[0] + " sandbox-proxy --listen=:8877 --allow=proxy.golang.org\n",
+		"network connect gemini-cli-sandbox " + proxy + "\n",
+		"--network gemini-cli-sandbox --env HTTPS_PROXY=http://" + proxy + ":8877",
+		"rm --force " + proxy + "\n",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected the docker log to contain %q, got:\n%s", want, data)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// reservedRunArgs are run flags the sandbox sets itself, which extra run
+// arguments may not repeat.
+var reservedRunArgs = []string{"--rm", "--init", "--workdir", "-w", "--runtime", "--detach", "-d"}
+
+// commandOutput runs a command and returns its standard output. It is a
+// variable for testing.
+var commandOutput = func(name string, arg ...string) ([]byte, error) {
+	return exec.Command(name, arg...).Output()
+}
+
+// Validate checks that the container runtime and extra run arguments can be
+// used, so that a misconfigured sandbox fails before starting with an error
+// saying what to fix.
+func (c *Config) Validate() error {
+	if err := c.validateNetwork(); err != nil {
+		return err
+	}
+	if c.Command != "docker" && c.Command != "podman" {
+		if c.Runtime != "" || len(c.RunArgs) > 0 {
+			return fmt.Errorf("tools.sandboxRuntime and tools.sandboxRunArgs need docker or podman, not %s", c.Command)
+		}
+		return nil
+	}
+	for _, arg := range c.RunArgs {
+		if !strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("tools.sandboxRunArgs: %q is not a flag; give values as --flag=value", arg)
+		}
+		name, _, _ := strings.Cut(arg, "=")
+		if slices.Contains(reservedRunArgs, name) {
+			if name == "--runtime" {
+				return fmt.Errorf("tools.sandboxRunArgs: set the runtime with tools.sandboxRuntime instead of %s", arg)
+			}
+			return fmt.Errorf("tools.sandboxRunArgs: %s is set by the sandbox and cannot be changed", name)
+		}
+	}
+	if c.Runtime == "" {
+		return nil
+	}
+
+	switch c.Command {
+	case "docker":
+		out, err := commandOutput("docker", "info", "--format", "{{json .Runtimes}}")
+		if err != nil {
+			return fmt.Errorf("could not list the docker runtimes to check tools.sandboxRuntime: %w", err)
+		}
+		var runtimes map[string]any
+		if err := json.Unmarshal(out, &runtimes); err != nil {
+			return fmt.Errorf("could not list the docker runtimes to check tools.sandboxRuntime: %w", err)
+		}
+		if _, ok := runtimes[c.Runtime]; !ok {
+			names := make([]string, 0, len(runtimes))
+			for name := range runtimes {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			return fmt.Errorf("docker has no runtime %q (it has %s); register it in /etc/docker/daemon.json, for gVisor with `runsc install`, and restart docker",
+				c.Runtime, strings.Join(names, ", "))
+		}
+	case "podman":
+		if _, err := commandOutput("podman", "--runtime", c.Runtime, "info", "--format", "{{.Host.OCIRuntime.Name}}"); err != nil {
+			return fmt.Errorf("podman cannot use the runtime %q: %w; install it on the PATH or configure it in containers.conf", c.Runtime, err)
+		}
+	}
+	return nil
+}
+
+// runArgs returns the run flags for the runtime, the user namespace and the
+// extra run arguments. Rootless podman keeps the user's ID in the container
+// unless the extra arguments choose a user namespace, so that files the
+// sandbox writes belong to the user.
+func (c *Config) runArgs() []string {
+	var args []string
+	if c.Runtime != "" {
+		args = append(args, "--runtime", c.Runtime)
+	}
+	if c.Command == "podman" && rootlessPodman() && !slices.ContainsFunc(c.RunArgs, func(arg string) bool {
+		return arg == "--userns" || strings.HasPrefix(arg, "--userns=")
+	}) {
+		args = append(args, "--userns=keep-id")
+	}
+	return append(args, c.RunArgs...)
+}
+
+func rootlessPodman() bool {
+	out, err := commandOutput("podman", "info", "--format", "{{.Host.Security.Rootless}}")
+	return err == nil && strings.TrimSpace(string(out)) == "true"
+}
@@ -0,0 +1,82 @@
+package sandbox
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// stubCommandOutput answers the commands the runtime checks run with the
+// output registered for them, and fails the others.
+func stubCommandOutput(t *testing.T, outputs map[string]string) {
+	t.Helper()
+	orig := commandOutput
+	commandOutput = func(name string, arg ...string) ([]byte, error) {
+		out, ok := outputs[strings.Join(append([]string{name}, arg...), " ")]
+		if !ok {
+			return nil, errors.New("exit status 125")
+		}
+		return []byte(out), nil
+	}
+	t.Cleanup(func() { commandOutput = orig })
+}
+
+func TestValidate(t *testing.T) {
+	stubCommandOutput(t, map[string]string{
+		"docker info --format {{json .Runtimes}}":                       `{"runc":{},"runsc":{}}`,
+		"podman --runtime crun info --format {{.Host.OCIRuntime.Name}}": "crun\n",
+	})
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"no options", Config{Command: "docker"}, ""},
+		{"gVisor", Config{Command: "docker", Runtime: "runsc"}, ""},
+		{"podman runtime", Config{Command: "podman", Runtime: "crun"}, ""},
+		{"run args", Config{Command: "docker", RunArgs: []string{"--cap-drop=ALL", "--network=none"}}, ""},
+		{"missing docker runtime", Config{Command: "docker", Runtime: "kata"}, `docker has no runtime "kata" (it has runc, runsc)`},
+		{"missing podman runtime", Config{Command: "podman", Runtime: "runsc"}, `podman cannot use the runtime "runsc"`},
+		{"not a flag", Config{Command: "docker", RunArgs: []string{"--network", "none"}}, `"none" is not a flag`},
+		{"reserved flag", Config{Command: "podman", RunArgs: []string{"--rm"}}, "--rm is set by the sandbox"},
+		{"runtime flag", Config{Command: "docker", RunArgs: []string{"--runtime=runsc"}}, "tools.sandboxRuntime instead"},
+		{"sandbox-exec", Config{Command: "sandbox-exec", Runtime: "runsc"}, "need docker or podman"},
+		{"no network", Config{Command: "sandbox-exec", Network: NetworkNone}, ""},
+		{"restricted network", Config{Command: "podman", Network: NetworkRestricted}, ""},
+		{"restricted sandbox-exec", Config{Command: "sandbox-exec", Network: NetworkRestricted}, "needs docker or podman"},
+		{"unknown network", Config{Command: "docker", Network: "host"}, `invalid tools.sandboxNetwork "host"`},
+		{"network flag", Config{Command: "docker", Network: NetworkNone, RunArgs: []string{"--network=host"}}, "conflicts with tools.sandboxNetwork"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("Validate() = %v, want nil", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunArgs(t *testing.T) {
+	stubCommandOutput(t, map[string]string{"podman info --format {{.Host.Security.Rootless}}": "true\n"})
+	tests := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{"docker", Config{Command: "docker", Runtime: "runsc", RunArgs: []string{"--cap-drop=ALL"}}, []string{"--runtime", "runsc", "--cap-drop=ALL"}},
+		{"rootless podman", Config{Command: "podman"}, []string{"--userns=keep-id"}},
+		{"own user namespace", Config{Command: "podman", RunArgs: []string{"--userns=auto"}}, []string{"--userns=auto"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.runArgs(); !slices.Equal(got, tt.want) {
+				t.Errorf("runArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -8,8 +8,11 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"syscall"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
 )
 
 //go:embed profiles/*.sb
@@ -19,18 +22,53 @@ var profiles embed.FS
 type Config struct {
 	Command string
 	Image   string
+	// ReadOnly keeps the workspace unchanged: the container's writes go to
+	// an overlay, offered as a patch when the sandbox exits.
+	ReadOnly bool
+	// Runtime is the OCI runtime of the container, such as runsc for gVisor.
+	Runtime string
+	// RunArgs are extra flags for the run command.
+	RunArgs []string
+	// Network is the network preset: open, none or restricted to
+	// AllowedHosts.
+	Network      string
+	AllowedHosts []string
+}
+
+// ApplySettings sets the options of the container sandbox from the tools
+// settings.
+func (c *Config) ApplySettings(t *config.ToolsSettings) {
+	if t == nil {
+		return
+	}
+	c.ReadOnly = t.SandboxReadOnly
+	c.Runtime = t.SandboxRuntime
+	c.RunArgs = t.SandboxRunArgs
+	c.Network = t.SandboxNetwork
+	c.AllowedHosts = t.SandboxAllowedHosts
 }
 
-// Start starts the sandbox if it's configured.
-func Start(cfg *Config, args []string) error {
+// Start starts the sandbox if it's configured, running the CLI in it with
+// opts.
+func Start(cfg *Config, opts *Options) error {
 	if cfg == nil {
 		return nil
 	}
+	if err := opts.export(); err != nil {
+		return fmt.Errorf("failed to pass the options to the sandbox: %w", err)
+	}
+	args := opts.argv()
 
 	switch cfg.Command {
 	case "docker", "podman":
 		return startContainer(cfg, args)
 	case "sandbox-exec":
+		if cfg.ReadOnly {
+			return fmt.Errorf("a read-only workspace needs docker or podman, not sandbox-exec")
+		}
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
 		return startSandboxExec(cfg, args)
 	default:
 		return fmt.Errorf("unknown sandbox command: %s", cfg.Command)
@@ -38,9 +76,15 @@ func Start(cfg *Config, args []string) error {
 }
 
 func startContainer(cfg *Config, args []string) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
 	if err := ensureSandboxImageIsPresent(cfg.Command, cfg.Image); err != nil {
 		return err
 	}
+	if err := checkImageVersion(cfg); err != nil {
+		return err
+	}
 
 	fmt.Printf("hopping into sandbox (command: %s, image: %s) ...\n", cfg.Command, cfg.Image)
 
@@ -56,8 +100,36 @@ func startContainer(cfg *Config, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to get current working directory: %w", err)
 	}
-	cmdArgs = append(cmdArgs, "--volume", fmt.Sprintf("%s:%s", workDir, workDir))
+	var ov *overlay
+	if cfg.ReadOnly {
+		if ov, err = newOverlay(workDir); err != nil {
+			return err
+		}
+		mount, err := ov.mountArgs(cfg.Command)
+		if err != nil {
+			return err
+		}
+		defer ov.cleanup(cfg.Command)
+		cmdArgs = append(cmdArgs, mount...)
+	} else {
+		cmdArgs = append(cmdArgs, "--volume", fmt.Sprintf("%s:%s", workDir, workDir))
+	}
 	cmdArgs = append(cmdArgs, "--workdir", workDir)
+	cmdArgs = append(cmdArgs, cfg.runArgs()...)
+
+	// The CLI itself needs the API in a restricted sandbox.
+	network, proxy, err := cfg.startNetwork(fmt.Sprintf("gemini-cli-sandbox-%d", os.Getpid()), slices.Concat(apiHosts, cfg.AllowedHosts))
+	if err != nil {
+		return err
+	}
+	defer proxy.stop()
+	cmdArgs = append(cmdArgs, network...)
+
+	cli, err := cliArgs()
+	if err != nil {
+		return err
+	}
+	cmdArgs = append(cmdArgs, cli...)
 
 	// Set SANDBOX env var
 	cmdArgs = append(cmdArgs, "--env", fmt.Sprintf("SANDBOX=%s", cfg.Command))
@@ -67,11 +139,35 @@ func startContainer(cfg *Config, args []string) error {
 	cmdArgs = append(cmdArgs, os.Args[0]) // The path to the gemini executable
 	cmdArgs = append(cmdArgs, args...)
 
-	return runCommand(cfg.Command, cmdArgs...)
+	if ov == nil && proxy == nil {
+		return runCommand(cfg.Command, cmdArgs...)
+	}
+	// The CLI waits for the sandbox instead of turning into it, to collect
+	// its changes or stop its proxy afterwards.
+	runErr := runChild(cfg.Command, cmdArgs...)
+	if ov != nil {
+		if err := ov.presentPatch(); err != nil {
+			return err
+		}
+	}
+	return runErr
+}
+
+// runChild runs a command attached to the terminal and waits for it.
+var runChild = func(name string, arg ...string) error {
+	cmd := exec.Command(name, arg...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("sandbox exited: %w", err)
+	}
+	return nil
 }
 
 func startSandboxExec(cfg *Config, args []string) error {
 	profileName := os.Getenv("SEATBELT_PROFILE")
+	if profileName == "" && cfg.Network == NetworkNone {
+		profileName = "permissive-closed"
+	}
 	if profileName == "" {
 		profileName = "permissive-open"
 	}
@@ -192,14 +288,18 @@ func LoadConfig(sandboxOption any, sandboxImageOption string) (*Config, error) {
 	if command == "" {
 		return nil, nil
 	}
+	if err := checkNested(command); err != nil {
+		return nil, err
+	}
 
 	image := sandboxImageOption
 	if image == "" {
 		image = os.Getenv("GEMINI_SANDBOX_IMAGE")
 	}
 	if image == "" {
-		// Fallback to a default image name, similar to the nodejs version
-		image = "us-docker.pkg.dev/gemini-code-dev/gemini-cli/sandbox:latest"
+		// The default image is pinned to the version of this CLI, which
+		// runs itself in it.
+		image = defaultImage()
 	}
 
 	if image == "" && command != "sandbox-exec" {
@@ -4,6 +4,8 @@ import (
 	"os"
 	"strings"
 	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/updatechecker"
 )
 
 func TestGetSandboxCommand(t *testing.T) {
@@ -75,6 +77,9 @@ func TestGetSandboxCommand(t *testing.T) {
 }
 
 func TestLoadConfig(t *testing.T) {
+	originalContainerMarker := containerMarker
+	containerMarker = func() string { return "" }
+	defer func() { containerMarker = originalContainerMarker }()
 	originalCommandExists := commandExists
 	defer func() {
 		commandExists = originalCommandExists
@@ -90,7 +95,7 @@ func TestLoadConfig(t *testing.T) {
 		expectedImage      string
 		expectError        bool
 	}{
-		{"Sandbox true, no image", true, "", "", "docker", "us-docker.pkg.dev/gemini-code-dev/gemini-cli/sandbox:latest", false},
+		{"Sandbox true, no image", true, "", "", "docker", "us-docker.pkg.dev/gemini-code-dev/gemini-cli/sandbox:" + updatechecker.CurrentVersion, false},
 		{"Sandbox true, with image flag", true, "my-image", "", "docker", "my-image", false},
 		{"Sandbox true, with image env", true, "", "env-image", "docker", "env-image", false},
 		{"Image flag overrides env", true, "flag-image", "env-image", "docker", "flag-image", false},
@@ -0,0 +1,95 @@
+package sandbox
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/updatechecker"
+)
+
+// DefaultImageRepository is the repository of the sandbox images published
+// with each release of the CLI, tagged with its version.
+const DefaultImageRepository = "us-docker.pkg.dev/gemini-code-dev/gemini-cli/sandbox"
+
+// versionLabel is the image label holding the version of the CLI in it.
+const versionLabel = "org.opencontainers.image.version"
+
+// defaultImage returns the image matching the version of this CLI.
+func defaultImage() string {
+	return DefaultImageRepository + ":" + updatechecker.CurrentVersion
+}
+
+// imageVersion returns the version of the CLI in image, or "" if the image
+// does not record it.
+var imageVersion = func(command, image string) (string, error) {
+	out, err := commandOutput(command, "image", "inspect", "--format", fmt.Sprintf("{{index .Config.Labels %q}}", versionLabel), image)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect the sandbox image %s: %w", image, err)
+	}
+	version := strings.TrimSpace(string(out))
+	if version == "<no value>" {
+		version = ""
+	}
+	return version, nil
+}
+
+// confirmPull asks the user whether to pull an image, if stdin is a
+// terminal; otherwise the answer is no. It is a variable for testing.
+var confirmPull = func(question string) bool {
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	return askYes(os.Stdin, os.Stderr, question)
+}
+
+// askYes asks question on out and reads the answer from in; an empty
+// answer is yes.
+func askYes(in io.Reader, out io.Writer, question string) bool {
+	fmt.Fprintf(out, "%s [Y/n] ", question)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "" || answer == "y" || answer == "yes"
+}
+
+// checkImageVersion checks that the CLI in the sandbox image is the version
+// of this one, as the CLI runs itself in the sandbox. An image of the
+// default repository with another version is replaced with the matching
+// tag if the user agrees; for other images a mismatch is only reported.
+func checkImageVersion(cfg *Config) error {
+	version, err := imageVersion(cfg.Command, cfg.Image)
+	if err != nil {
+		return err
+	}
+	want := updatechecker.CurrentVersion
+	switch {
+	case version == want:
+		return nil
+	case version == "":
+		fmt.Fprintf(os.Stderr, "Warning: the sandbox image %s does not record its CLI version (label %s); it may not match this CLI, version %s.\n",
+			cfg.Image, versionLabel, want)
+		return nil
+	}
+
+	repo, _, _ := strings.Cut(cfg.Image, "@")
+	if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+		repo = repo[:i]
+	}
+	if repo != DefaultImageRepository {
+		fmt.Fprintf(os.Stderr, "Warning: the sandbox image %s has CLI version %s, but this CLI is version %s.\n", cfg.Image, version, want)
+		return nil
+	}
+	matching := defaultImage()
+	if !confirmPull(fmt.Sprintf("The sandbox image %s has CLI version %s, but this CLI is version %s. Use %s instead?", cfg.Image, version, want, matching)) {
+		fmt.Fprintf(os.Stderr, "Warning: using the sandbox image %s with CLI version %s; set tools.sandboxImage or --sandbox-image to %s to match this CLI.\n",
+			cfg.Image, version, matching)
+		return nil
+	}
+	if err := ensureSandboxImageIsPresent(cfg.Command, matching); err != nil {
+		return err
+	}
+	cfg.Image = matching
+	return nil
+}
@@ -0,0 +1,74 @@
+package sandbox
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/updatechecker"
+)
+
+func TestCheckImageVersion(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake docker is a shell script")
+	}
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "docker"), []byte(fakeDocker), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_DOCKER_LOG", filepath.Join(bin, "log"))
+
+	origVersion, origConfirm := imageVersion, confirmPull
+	t.Cleanup(func() { imageVersion, confirmPull = origVersion, origConfirm })
+	versions := map[string]string{
+		DefaultImageRepository + ":latest": "0.0.0",
+		defaultImage():                     updatechecker.CurrentVersion,
+		"my-image:dev":                     "0.0.0",
+	}
+	imageVersion = func(command, image string) (string, error) { return versions[image], nil }
+
+	tests := []struct {
+		name, image string
+		accept      bool
+		wantImage   string
+		wantAsked   bool
+	}{
+		{"matching", defaultImage(), true, defaultImage(), false},
+		{"pull the matching tag", DefaultImageRepository + ":latest", true, defaultImage(), true},
+		{"keep the image", DefaultImageRepository + ":latest", false, DefaultImageRepository + ":latest", true},
+		{"custom image", "my-image:dev", true, "my-image:dev", false},
+		{"no version label", "other", true, "other", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asked := false
+			confirmPull = func(question string) bool {
+				asked = true
+				return tt.accept
+			}
+			cfg := &Config{Command: "docker", Image: tt.image}
+			if err := checkImageVersion(cfg); err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Image != tt.wantImage || asked != tt.wantAsked {
+				t.Errorf("image = %s, asked = %v; want %s, %v", cfg.Image, asked, tt.wantImage, tt.wantAsked)
+			}
+		})
+	}
+}
+
+func TestAskYes(t *testing.T) {
+	for answer, want := range map[string]bool{"\n": true, "y\n": true, "Yes\n": true, "n\n": false, "no": false} {
+		var out bytes.Buffer
+		if got := askYes(strings.NewReader(answer), &out, "Pull?"); got != want {
+			t.Errorf("askYes(%q) = %v, want %v", answer, got, want)
+		}
+		if out.String() != "Pull? [Y/n] " {
+			t.Errorf("Unexpected question %q", out.String())
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// Package seed sets the sampling seed of generation requests. The API
+// accepts generationConfig.seed, but the client library does not expose it,
+// so it is added to the request bodies on their way out.
+package seed
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/apirequest"
+)
+
+// Transport returns a round tripper sending seed with every generation
+// request it passes to base.
+func Transport(base http.RoundTripper, seed int32) http.RoundTripper {
+	return &transport{base: base, seed: seed}
+}
+
+type transport struct {
+	base http.RoundTripper
+	seed int32
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !apirequest.IsGeneration(req) {
+		return t.base.RoundTrip(req)
+	}
+
+	req, err := apirequest.RewriteBody(req, func(body []byte) ([]byte, error) { return withSeed(body, t.seed) })
+	if err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// withSeed sets generationConfig.seed in a request body, leaving the rest
+// as it is.
+func withSeed(body []byte, seed int32) ([]byte, error) {
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, err
+	}
+	config := map[string]json.RawMessage{}
+	if raw, ok := request["generationConfig"]; ok {
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+	}
+	config["seed"], _ = json.Marshal(seed)
+	var err error
+	if request["generationConfig"], err = json.Marshal(config); err != nil {
+		return nil, err
+	}
+	return json.Marshal(request)
+}
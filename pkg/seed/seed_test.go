@@ -0,0 +1,54 @@
+package seed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/auth"
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+)
+
+func TestTransportSendsSeed(t *testing.T) {
+	var (
+		request map[string]any
+		apiKey  string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey = r.Header.Get("x-goog-api-key")
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &request)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(w, `{"candidates":[{"content":{"parts":[{"text":"ok"}]}}]}`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey("key"), option.WithEndpoint(server.URL),
+		auth.ClientOption("key", Transport(http.DefaultTransport, 42)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	model := client.GenerativeModel("gemini-pro")
+	model.SetTemperature(0)
+	if _, err := model.GenerateContent(ctx, genai.Text("hi")); err != nil {
+		t.Fatal(err)
+	}
+
+	if apiKey != "key" {
+		t.Errorf("x-goog-api-key = %q", apiKey)
+	}
+	config, _ := request["generationConfig"].(map[string]any)
+	if config["seed"] != float64(42) || config["temperature"] != float64(0) {
+		t.Errorf("generationConfig = %v, want the seed added to the temperature", config)
+	}
+	if request["contents"] == nil {
+		t.Errorf("Expected the rest of the request to be kept, got %v", request)
+	}
+}
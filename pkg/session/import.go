@@ -0,0 +1,197 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google/uuid"
+)
+
+// nodeContextPrefix starts the message the Node CLI opens its checkpoints
+// with to set up the environment context, which the model acknowledges.
+const nodeContextPrefix = "This is the Gemini CLI. We are setting up the context for our chat."
+
+// nodeMessage is a message of a conversation the Node CLI recorded.
+type nodeMessage struct {
+	ID        string          `json:"id"`
+	Timestamp time.Time       `json:"timestamp"`
+	Type      string          `json:"type"`
+	Content   json.RawMessage `json:"content"`
+}
+
+// nodeRecording is a conversation the Node CLI recorded in
+// ~/.gemini/tmp/<project-hash>/chats.
+type nodeRecording struct {
+	SessionID   string        `json:"sessionId"`
+	StartTime   time.Time     `json:"startTime"`
+	LastUpdated time.Time     `json:"lastUpdated"`
+	Messages    []nodeMessage `json:"messages"`
+}
+
+// nodeContent is a turn of the history of a Node CLI checkpoint, as
+// /chat save writes to ~/.gemini/tmp/<project-hash>/checkpoint-<tag>.json.
+type nodeContent struct {
+	Role  string     `json:"role"`
+	Parts []nodePart `json:"parts"`
+}
+
+type nodePart struct {
+	Text    string `json:"text"`
+	Thought bool   `json:"thought"`
+}
+
+// ImportNode converts a conversation the Node gemini-cli saved, either a
+// recorded session or a /chat save checkpoint, into a session of the
+// project rooted at projectRoot, ready to be saved. modTime stands in for
+// the times checkpoints do not record. Tool calls and notices are left
+// out, since sessions record only what was said.
+func ImportNode(data []byte, projectRoot string, modTime time.Time) (*Session, error) {
+	var s *Session
+	var err error
+	switch trimmed := strings.TrimSpace(string(data)); {
+	case strings.HasPrefix(trimmed, "["):
+		var history []nodeContent
+		if err := json.Unmarshal(data, &history); err != nil {
+			return nil, fmt.Errorf("invalid checkpoint: %w", err)
+		}
+		s = fromCheckpoint(history, modTime)
+	case strings.HasPrefix(trimmed, "{"):
+		s, err = fromObject(data, modTime)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("not a Node CLI session or checkpoint")
+	}
+	if len(s.Messages) == 0 {
+		return nil, errors.New("the conversation has no messages")
+	}
+	// Importing the same conversation again replaces the earlier import.
+	if len(s.ID) < 8 {
+		s.ID = uuid.NewSHA1(uuid.NameSpaceOID, data).String()
+	}
+	s.ProjectHash = config.ProjectHash(projectRoot)
+	s.projectRoot = projectRoot
+	s.dirty = true
+	return s, nil
+}
+
+// fromObject converts a recorded session, or a checkpoint of the newer
+// Node CLIs that keep the history under "history".
+func fromObject(data []byte, modTime time.Time) (*Session, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("invalid session: %w", err)
+	}
+	if raw, ok := fields["history"]; ok {
+		var history []nodeContent
+		if err := json.Unmarshal(raw, &history); err != nil {
+			return nil, fmt.Errorf("invalid checkpoint: %w", err)
+		}
+		return fromCheckpoint(history, modTime), nil
+	}
+	if _, ok := fields["messages"]; !ok {
+		return nil, errors.New("not a Node CLI session or checkpoint")
+	}
+
+	var r nodeRecording
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("invalid session: %w", err)
+	}
+	s := &Session{ID: r.SessionID, StartTime: r.StartTime, LastUpdated: r.LastUpdated, Messages: []Message{}}
+	for _, m := range r.Messages {
+		var t MessageType
+		switch m.Type {
+		case "user":
+			t = UserMessage
+		case "gemini":
+			t = GeminiMessage
+		case "error":
+			t = ErrorMessage
+		default:
+			continue
+		}
+		content := contentText(m.Content)
+		if content == "" {
+			continue
+		}
+		id := m.ID
+		if id == "" {
+			id = uuid.NewString()
+		}
+		ts := m.Timestamp
+		if ts.IsZero() {
+			ts = modTime
+		}
+		s.Messages = append(s.Messages, Message{ID: id, Timestamp: ts, Type: t, Content: content})
+	}
+	if s.StartTime.IsZero() {
+		s.StartTime = modTime
+		if len(s.Messages) > 0 {
+			s.StartTime = s.Messages[0].Timestamp
+		}
+	}
+	if s.LastUpdated.IsZero() {
+		s.LastUpdated = modTime
+	}
+	return s, nil
+}
+
+// contentText returns the text of the content of a recorded message,
+// which older Node CLIs record as a string and newer ones as parts.
+func contentText(raw json.RawMessage) string {
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+	var parts []json.RawMessage
+	if json.Unmarshal(raw, &parts) != nil {
+		parts = []json.RawMessage{raw}
+	}
+	var texts []string
+	for _, p := range parts {
+		var s string
+		var part nodePart
+		if json.Unmarshal(p, &s) == nil {
+			texts = append(texts, s)
+		} else if json.Unmarshal(p, &part) == nil && part.Text != "" && !part.Thought {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "")
+}
+
+// fromCheckpoint converts the history of a checkpoint, whose turns all
+// get modTime, leaving out the environment context it opens with and the
+// turns without text, such as function responses.
+func fromCheckpoint(history []nodeContent, modTime time.Time) *Session {
+	if len(history) >= 2 && history[1].Role == "model" && strings.HasPrefix(turnText(history[0]), nodeContextPrefix) {
+		history = history[2:]
+	}
+	s := &Session{StartTime: modTime, LastUpdated: modTime, Messages: []Message{}}
+	for _, c := range history {
+		t := UserMessage
+		if c.Role == "model" {
+			t = GeminiMessage
+		}
+		if text := turnText(c); text != "" {
+			s.Messages = append(s.Messages, Message{ID: uuid.NewString(), Timestamp: modTime, Type: t, Content: text})
+		}
+	}
+	return s
+}
+
+// turnText returns the text of c, without thoughts.
+func turnText(c nodeContent) string {
+	var b strings.Builder
+	for _, p := range c.Parts {
+		if !p.Thought {
+			b.WriteString(p.Text)
+		}
+	}
+	return b.String()
+}
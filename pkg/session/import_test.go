@@ -0,0 +1,101 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+func TestImportNodeRecording(t *testing.T) {
+	restore := config.SetUserHomeDirForTesting(t.TempDir(), nil)
+	defer restore()
+
+	data := []byte(`{
+  "sessionId": "8f14e45f-ceea-467f-a0e6-3f3b5f2c9a10",
+  "projectHash": "abc",
+  "startTime": "2025-09-01T10:00:00.000Z",
+  "lastUpdated": "2025-09-01T10:05:00.000Z",
+  "messages": [
+    {"id": "1", "timestamp": "2025-09-01T10:00:00.000Z", "type": "user", "content": "hello"},
+    {"id": "2", "timestamp": "2025-09-01T10:00:01.000Z", "type": "info", "content": "Switched model"},
+    {"id": "3", "timestamp": "2025-09-01T10:00:02.000Z", "type": "gemini", "content": "", "toolCalls": [{"name": "ls"}]},
+    {"id": "4", "timestamp": "2025-09-01T10:00:03.000Z", "type": "gemini", "content": [{"text": "thinking", "thought": true}, {"text": "hi "}, "there"]}
+  ]
+}`)
+	s, err := ImportNode(data, "/work/project", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ID != "8f14e45f-ceea-467f-a0e6-3f3b5f2c9a10" || s.ProjectHash != config.ProjectHash("/work/project") {
+		t.Errorf("session %s of project %s", s.ID, s.ProjectHash)
+	}
+	want := []Message{
+		{ID: "1", Timestamp: time.Date(2025, 9, 1, 10, 0, 0, 0, time.UTC), Type: UserMessage, Content: "hello"},
+		{ID: "4", Timestamp: time.Date(2025, 9, 1, 10, 0, 3, 0, time.UTC), Type: GeminiMessage, Content: "hi there"},
+	}
+	if len(s.Messages) != len(want) {
+		t.Fatalf("messages = %+v", s.Messages)
+	}
+	for i, m := range s.Messages {
+		if m.ID != want[i].ID || !m.Timestamp.Equal(want[i].Timestamp) || m.Type != want[i].Type || m.Content != want[i].Content {
+			t.Errorf("messages[%d] = %+v, want %+v", i, m, want[i])
+		}
+	}
+
+	if err := s.Save(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	path, _ := s.Path()
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Session
+	if err := json.Unmarshal(saved, &got); err != nil || len(got.Messages) != 2 {
+		t.Errorf("saved %s, %v", saved, err)
+	}
+}
+
+func TestImportNodeCheckpoint(t *testing.T) {
+	modTime := time.Date(2025, 8, 1, 9, 0, 0, 0, time.UTC)
+	history := `[
+  {"role": "user", "parts": [{"text": "This is the Gemini CLI. We are setting up the context for our chat.\nToday's date is ..."}]},
+  {"role": "model", "parts": [{"text": "Got it. Thanks for the context!"}]},
+  {"role": "user", "parts": [{"text": "list the files"}]},
+  {"role": "model", "parts": [{"functionCall": {"name": "list_directory", "args": {}}}]},
+  {"role": "user", "parts": [{"functionResponse": {"name": "list_directory", "response": {}}}]},
+  {"role": "model", "parts": [{"text": "There are two."}]}
+]`
+	for _, data := range []string{history, `{"history": ` + history + `, "authType": "oauth-personal"}`} {
+		s, err := ImportNode([]byte(data), "/work/project", modTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(s.Messages) != 2 || s.Messages[0].Content != "list the files" || s.Messages[1].Type != GeminiMessage ||
+			s.Messages[1].Content != "There are two." || !s.StartTime.Equal(modTime) {
+			t.Errorf("ImportNode(%.20q) = %+v", data, s)
+		}
+		again, _ := ImportNode([]byte(data), "/work/project", modTime)
+		if again.ID != s.ID {
+			t.Error("Expected importing a checkpoint again to keep its session ID")
+		}
+	}
+}
+
+func TestImportNodeInvalid(t *testing.T) {
+	for data, want := range map[string]string{
+		"hello":             "not a Node CLI session",
+		`{"theme": "dark"}`: "not a Node CLI session",
+		`{"messages": []}`:  "no messages",
+		`[{"role": 1}]`:     "invalid checkpoint",
+	} {
+		if _, err := ImportNode([]byte(data), "/work", time.Now()); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ImportNode(%q) = %v, want an error containing %q", data, err, want)
+		}
+	}
+}
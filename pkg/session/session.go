@@ -0,0 +1,140 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google/uuid"
+)
+
+const chatsDirName = "chats"
+
+// MessageType identifies the author of a recorded message.
+type MessageType string
+
+const (
+	UserMessage   MessageType = "user"
+	GeminiMessage MessageType = "gemini"
+	ErrorMessage  MessageType = "error"
+)
+
+// Message is a single recorded conversation message.
+type Message struct {
+	ID        string      `json:"id"`
+	Timestamp time.Time   `json:"timestamp"`
+	Type      MessageType `json:"type"`
+	Content   string      `json:"content"`
+}
+
+// Session records a conversation so it can be saved to
+// ~/.gemini/tmp/<project-hash>/chats when the CLI exits.
+type Session struct {
+	mu sync.Mutex
+
+	ID          string    `json:"sessionId"`
+	ProjectHash string    `json:"projectHash"`
+	StartTime   time.Time `json:"startTime"`
+	LastUpdated time.Time `json:"lastUpdated"`
+	Messages    []Message `json:"messages"`
+
+	projectRoot string
+	dirty       bool
+}
+
+// New starts a new session for the project rooted at projectRoot.
+func New(projectRoot string) *Session {
+	now := time.Now()
+	return &Session{
+		ID:          uuid.NewString(),
+		ProjectHash: config.ProjectHash(projectRoot),
+		StartTime:   now,
+		LastUpdated: now,
+		Messages:    []Message{},
+		projectRoot: projectRoot,
+	}
+}
+
+// Record appends a message to the session.
+func (s *Session) Record(t MessageType, content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.Messages = append(s.Messages, Message{
+		ID:        uuid.NewString(),
+		Timestamp: now,
+		Type:      t,
+		Content:   content,
+	})
+	s.LastUpdated = now
+	s.dirty = true
+}
+
+// Unsaved reports whether messages were recorded since the session was
+// last saved.
+func (s *Session) Unsaved() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dirty
+}
+
+// Discard keeps the messages recorded so far from being saved: Save writes
+// nothing until another one is recorded.
+func (s *Session) Discard() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirty = false
+}
+
+// Path returns the file the session is saved to.
+func (s *Session) Path() (string, error) {
+	dir, err := config.ProjectTempDir(s.projectRoot)
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("session-%s-%s.json", s.StartTime.Format("2006-01-02T15-04"), s.ID[:8])
+	return filepath.Join(dir, chatsDirName, name), nil
+}
+
+// Save writes the session to disk if it has unsaved messages. It has the
+// signature of a shutdown hook.
+func (s *Session) Save(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+
+	path, err := s.Path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	// Write to a temp file first so an interrupted shutdown never leaves a
+	// truncated session behind.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+
+	s.dirty = false
+	return nil
+}
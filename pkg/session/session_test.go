@@ -0,0 +1,68 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+func TestSave(t *testing.T) {
+	tempDir := t.TempDir()
+	restore := config.SetUserHomeDirForTesting(tempDir, nil)
+	defer restore()
+
+	s := New("/work/project")
+
+	// Nothing recorded yet, so nothing should be written.
+	if err := s.Save(context.Background()); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	path, err := s.Path()
+	if err != nil {
+		t.Fatalf("Path() failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Expected no session file for an empty session")
+	}
+
+	s.Record(UserMessage, "hello")
+	s.Discard()
+	if err := s.Save(context.Background()); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Expected no session file for a discarded session")
+	}
+
+	s.Record(GeminiMessage, "hi there")
+	if !s.Unsaved() {
+		t.Error("Expected the session to have unsaved messages")
+	}
+	if err := s.Save(context.Background()); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if s.Unsaved() {
+		t.Error("Expected the session to be saved")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read session file: %v", err)
+	}
+	var saved Session
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("Failed to decode session file: %v", err)
+	}
+	if saved.ID != s.ID {
+		t.Errorf("Expected session ID %q, got %q", s.ID, saved.ID)
+	}
+	if saved.ProjectHash != config.ProjectHash("/work/project") {
+		t.Errorf("Unexpected project hash %q", saved.ProjectHash)
+	}
+	if len(saved.Messages) != 2 || saved.Messages[1].Content != "hi there" {
+		t.Errorf("Unexpected messages: %+v", saved.Messages)
+	}
+}
@@ -0,0 +1,106 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Hook releases a resource when the CLI exits. Hooks must return promptly
+// once ctx is done.
+type Hook func(ctx context.Context) error
+
+type hook struct {
+	id   int
+	name string
+	fn   Hook
+}
+
+var (
+	mu     sync.Mutex
+	hooks  []hook
+	nextID int
+	ran    bool
+)
+
+// Register adds a hook to run on shutdown and returns a function that removes
+// it again, for resources released before the CLI exits.
+func Register(name string, fn Hook) (unregister func()) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	nextID++
+	id := nextID
+	hooks = append(hooks, hook{id: id, name: name, fn: fn})
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for i, h := range hooks {
+			if h.id == id {
+				hooks = append(hooks[:i], hooks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Run executes the registered hooks in reverse registration order, so that
+// resources are released in the opposite order they were acquired. The whole
+// shutdown is bounded by timeout; hooks that have not started by then are
+// skipped and reported in the returned error. Run only has an effect once.
+func Run(timeout time.Duration) error {
+	mu.Lock()
+	if ran {
+		mu.Unlock()
+		return nil
+	}
+	ran = true
+	pending := make([]hook, len(hooks))
+	copy(pending, hooks)
+	hooks = nil
+	mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(pending) - 1; i >= 0; i-- {
+		h := pending[i]
+		if ctx.Err() != nil {
+			errs = append(errs, fmt.Errorf("%s: skipped: shutdown timed out after %s", h.name, timeout))
+			continue
+		}
+		if err := runHook(ctx, h); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runHook runs a single hook, giving up on it when ctx is done even if the
+// hook itself ignores the context.
+func runHook(ctx context.Context, h hook) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- h.fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ResetForTesting clears all registered hooks and allows Run to be called
+// again.
+func ResetForTesting() {
+	mu.Lock()
+	defer mu.Unlock()
+	hooks = nil
+	ran = false
+}
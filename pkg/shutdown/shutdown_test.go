@@ -0,0 +1,88 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun_ReverseOrder(t *testing.T) {
+	ResetForTesting()
+	defer ResetForTesting()
+
+	var order []string
+	Register("first", func(ctx context.Context) error {
+		order = append(order, "first")
+		return nil
+	})
+	Register("second", func(ctx context.Context) error {
+		order = append(order, "second")
+		return nil
+	})
+
+	if err := Run(time.Second); err != nil {
+		t.Fatalf("Run() returned error: %v", err)
+	}
+	if strings.Join(order, ",") != "second,first" {
+		t.Errorf("Expected hooks to run in reverse order, got %v", order)
+	}
+
+	// A second Run must not execute the hooks again.
+	if err := Run(time.Second); err != nil {
+		t.Fatalf("second Run() returned error: %v", err)
+	}
+	if len(order) != 2 {
+		t.Errorf("Expected hooks to run once, ran %d times", len(order))
+	}
+}
+
+func TestRun_Unregister(t *testing.T) {
+	ResetForTesting()
+	defer ResetForTesting()
+
+	called := false
+	unregister := Register("removed", func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+	unregister()
+
+	if err := Run(time.Second); err != nil {
+		t.Fatalf("Run() returned error: %v", err)
+	}
+	if called {
+		t.Error("Expected unregistered hook not to run")
+	}
+}
+
+func TestRun_Timeout(t *testing.T) {
+	ResetForTesting()
+	defer ResetForTesting()
+
+	skipped := false
+	Register("skipped", func(ctx context.Context) error {
+		skipped = true
+		return nil
+	})
+	Register("stuck", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	start := time.Now()
+	err := Run(50 * time.Millisecond)
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Run() did not honor the timeout")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a deadline error, got %v", err)
+	}
+	if skipped {
+		t.Error("Expected hooks after the timeout to be skipped")
+	}
+	if !strings.Contains(err.Error(), "skipped") {
+		t.Errorf("Expected error to mention skipped hooks, got %v", err)
+	}
+}
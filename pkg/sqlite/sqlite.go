@@ -0,0 +1,434 @@
+package sqlite
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+)
+
+// magic starts every SQLite 3 database file.
+const magic = "SQLite format 3\x00"
+
+// maxDepth bounds the height of a b-tree, so that a corrupt file with a
+// cycle of pages cannot make a walk loop forever.
+const maxDepth = 40
+
+// Page types of table b-trees.
+const (
+	interiorTable = 0x05
+	leafTable     = 0x0d
+)
+
+// DB is a SQLite database file opened for reading. It reads the file
+// format directly and supports what previewing data needs: the schema and
+// the rows of ordinary (rowid) tables. Changes still in a write-ahead log
+// are not seen.
+type DB struct {
+	f        *os.File
+	pageSize int
+	usable   int
+	pages    int
+	encoding int // 1 UTF-8, 2 UTF-16le, 3 UTF-16be
+}
+
+// Object is an entry of the schema: a table, index, view or trigger.
+type Object struct {
+	Type     string
+	Name     string
+	Table    string
+	RootPage int
+	SQL      string
+}
+
+// IsDatabase reports whether header, the start of a file, is that of a
+// SQLite database.
+func IsDatabase(header []byte) bool {
+	return strings.HasPrefix(string(header), magic)
+}
+
+// Open opens the database file at path.
+func Open(path string) (*DB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	var h [100]byte
+	if _, err := io.ReadFull(f, h[:]); err != nil || !IsDatabase(h[:]) {
+		f.Close()
+		return nil, fmt.Errorf("%s is not a SQLite database", path)
+	}
+	db := &DB{f: f, pageSize: int(binary.BigEndian.Uint16(h[16:])), encoding: int(binary.BigEndian.Uint32(h[56:]))}
+	if db.pageSize == 1 {
+		db.pageSize = 65536
+	}
+	db.usable = db.pageSize - int(h[20])
+	if db.pageSize < 512 || db.usable < 480 {
+		f.Close()
+		return nil, fmt.Errorf("%s: invalid page size %d", path, db.pageSize)
+	}
+	if info, err := f.Stat(); err == nil {
+		db.pages = int(info.Size() / int64(db.pageSize))
+	}
+	if db.encoding == 0 {
+		db.encoding = 1
+	}
+	return db, nil
+}
+
+// Close closes the file.
+func (db *DB) Close() error {
+	return db.f.Close()
+}
+
+// Schema returns the objects of the database.
+func (db *DB) Schema() ([]Object, error) {
+	var objects []Object
+	err := db.Scan(1, func(rowid int64, values []any) bool {
+		if len(values) < 5 {
+			return true
+		}
+		o := Object{}
+		o.Type, _ = values[0].(string)
+		o.Name, _ = values[1].(string)
+		o.Table, _ = values[2].(string)
+		if root, ok := values[3].(int64); ok {
+			o.RootPage = int(root)
+		}
+		o.SQL, _ = values[4].(string)
+		objects = append(objects, o)
+		return true
+	})
+	return objects, err
+}
+
+// Scan calls fn with the rowid and values of each row of the table b-tree
+// rooted at page root, in rowid order, until fn returns false.
+func (db *DB) Scan(root int, fn func(rowid int64, values []any) bool) error {
+	_, err := db.walk(root, 0, func(page []byte, cell int) (bool, error) {
+		rowid, values, err := db.leafCell(page, cell)
+		if err != nil {
+			return false, err
+		}
+		return fn(rowid, values), nil
+	}, nil)
+	return err
+}
+
+// Count returns the number of rows of the table b-tree rooted at page root.
+func (db *DB) Count(root int) (int, error) {
+	n := 0
+	_, err := db.walk(root, 0, nil, func(cells int) { n += cells })
+	return n, err
+}
+
+// walk visits the leaf cells of the b-tree rooted at page n in order,
+// calling cell for each of them, or leaf with the number of cells of each
+// leaf page if cell is nil. It returns false once cell does.
+func (db *DB) walk(n, depth int, cell func(page []byte, off int) (bool, error), leaf func(cells int)) (bool, error) {
+	if depth > maxDepth {
+		return false, errors.New("b-tree is too deep; the file may be corrupt")
+	}
+	page, hdr, err := db.page(n)
+	if err != nil {
+		return false, err
+	}
+	typ := page[hdr]
+	cells := int(binary.BigEndian.Uint16(page[hdr+3:]))
+	switch typ {
+	case leafTable:
+		if cell == nil {
+			leaf(cells)
+			return true, nil
+		}
+		for i := range cells {
+			off, err := cellOffset(page, hdr+8, i)
+			if err != nil {
+				return false, err
+			}
+			if ok, err := cell(page, off); !ok || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case interiorTable:
+		for i := range cells {
+			off, err := cellOffset(page, hdr+12, i)
+			if err != nil {
+				return false, err
+			}
+			if off+4 > len(page) {
+				return false, fmt.Errorf("page %d: cell out of bounds", n)
+			}
+			child := int(binary.BigEndian.Uint32(page[off:]))
+			if ok, err := db.walk(child, depth+1, cell, leaf); !ok || err != nil {
+				return false, err
+			}
+		}
+		return db.walk(int(binary.BigEndian.Uint32(page[hdr+8:])), depth+1, cell, leaf)
+	}
+	return false, fmt.Errorf("page %d is not part of a table b-tree (type %#x); WITHOUT ROWID tables are not supported", n, typ)
+}
+
+// page reads page n, counting from 1, and returns it with the offset of
+// its b-tree header, which follows the file header on page 1.
+func (db *DB) page(n int) ([]byte, int, error) {
+	if n < 1 || db.pages > 0 && n > db.pages {
+		return nil, 0, fmt.Errorf("page %d is out of range; the file may be corrupt", n)
+	}
+	page := make([]byte, db.pageSize)
+	if _, err := db.f.ReadAt(page, int64(n-1)*int64(db.pageSize)); err != nil {
+		return nil, 0, fmt.Errorf("reading page %d: %w", n, err)
+	}
+	hdr := 0
+	if n == 1 {
+		hdr = 100
+	}
+	return page, hdr, nil
+}
+
+func cellOffset(page []byte, pointers, i int) (int, error) {
+	at := pointers + 2*i
+	if at+2 > len(page) {
+		return 0, errors.New("cell pointer out of bounds")
+	}
+	off := int(binary.BigEndian.Uint16(page[at:]))
+	if off >= len(page) {
+		return 0, errors.New("cell out of bounds")
+	}
+	return off, nil
+}
+
+// leafCell decodes the cell of a table leaf page at off, following
+// overflow pages for large rows.
+func (db *DB) leafCell(page []byte, off int) (int64, []any, error) {
+	size, n := varint(page[off:])
+	if n == 0 || size < 0 {
+		return 0, nil, errors.New("malformed cell")
+	}
+	off += n
+	rowid, n := varint(page[off:])
+	if n == 0 {
+		return 0, nil, errors.New("malformed cell")
+	}
+	off += n
+
+	local := db.localSize(int(size))
+	if off+local > len(page) {
+		return 0, nil, errors.New("cell out of bounds")
+	}
+	payload := append([]byte(nil), page[off:off+local]...)
+	if local < int(size) {
+		if off+local+4 > len(page) {
+			return 0, nil, errors.New("cell out of bounds")
+		}
+		next := int(binary.BigEndian.Uint32(page[off+local:]))
+		for hops := 0; len(payload) < int(size); hops++ {
+			if next == 0 || db.pages > 0 && hops > db.pages {
+				return 0, nil, errors.New("overflow chain is broken")
+			}
+			p, _, err := db.page(next)
+			if err != nil {
+				return 0, nil, err
+			}
+			chunk := p[4:db.usable]
+			payload = append(payload, chunk[:min(len(chunk), int(size)-len(payload))]...)
+			next = int(binary.BigEndian.Uint32(p))
+		}
+	}
+	values, err := db.record(payload)
+	return rowid, values, err
+}
+
+// localSize returns how much of a payload of the given size is stored on
+// a table leaf page, the rest going to overflow pages.
+func (db *DB) localSize(size int) int {
+	u := db.usable
+	maxLocal := u - 35
+	if size <= maxLocal {
+		return size
+	}
+	minLocal := (u-12)*32/255 - 23
+	k := minLocal + (size-minLocal)%(u-4)
+	if k <= maxLocal {
+		return k
+	}
+	return minLocal
+}
+
+// record decodes a record into int64, float64, string, []byte and nil
+// values.
+func (db *DB) record(rec []byte) ([]any, error) {
+	hdrSize, n := varint(rec)
+	if n == 0 || hdrSize < int64(n) || hdrSize > int64(len(rec)) {
+		return nil, errors.New("malformed record")
+	}
+	var types []int64
+	for at := n; at < int(hdrSize); {
+		t, n := varint(rec[at:int(hdrSize)])
+		if n == 0 {
+			return nil, errors.New("malformed record")
+		}
+		types = append(types, t)
+		at += n
+	}
+
+	values := make([]any, len(types))
+	body := rec[hdrSize:]
+	for i, t := range types {
+		size := serialSize(t)
+		if size > len(body) {
+			return nil, errors.New("record is truncated")
+		}
+		b := body[:size]
+		body = body[size:]
+		switch {
+		case t == 0:
+			values[i] = nil
+		case t <= 6:
+			v := int64(int8(b[0]))
+			for _, c := range b[1:] {
+				v = v<<8 | int64(c)
+			}
+			values[i] = v
+		case t == 7:
+			values[i] = math.Float64frombits(binary.BigEndian.Uint64(b))
+		case t == 8:
+			values[i] = int64(0)
+		case t == 9:
+			values[i] = int64(1)
+		case t >= 12 && t%2 == 0:
+			values[i] = append([]byte(nil), b...)
+		case t >= 13:
+			values[i] = db.text(b)
+		default:
+			return nil, fmt.Errorf("unknown serial type %d", t)
+		}
+	}
+	return values, nil
+}
+
+func serialSize(t int64) int {
+	switch {
+	case t >= 12:
+		return int((t - 12) / 2)
+	case t == 5:
+		return 6
+	case t == 6, t == 7:
+		return 8
+	case t >= 1 && t <= 4:
+		return int(t)
+	}
+	return 0
+}
+
+func (db *DB) text(b []byte) string {
+	if db.encoding == 1 || len(b)%2 != 0 {
+		return string(b)
+	}
+	order := binary.ByteOrder(binary.LittleEndian)
+	if db.encoding == 3 {
+		order = binary.BigEndian
+	}
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = order.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// varint decodes a SQLite variable-length integer, returning its value and
+// length, or a length of 0 if b is too short.
+func varint(b []byte) (int64, int) {
+	var v uint64
+	for i := 0; i < 8; i++ {
+		if i >= len(b) {
+			return 0, 0
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return int64(v), i + 1
+		}
+	}
+	if len(b) < 9 {
+		return 0, 0
+	}
+	return int64(v<<8 | uint64(b[8])), 9
+}
+
+var (
+	constraintPattern = regexp.MustCompile(`(?i)^(constraint|primary|unique|check|foreign)\b`)
+	rowidPattern      = regexp.MustCompile(`(?i)^\s*integer\s+primary\s+key\b`)
+)
+
+// Columns returns the column names declared by a CREATE TABLE statement,
+// and the index of the INTEGER PRIMARY KEY column, which is an alias for
+// the rowid and stored as NULL in rows, or -1 if there is none.
+func Columns(createSQL string) ([]string, int) {
+	start, end := strings.Index(createSQL, "("), strings.LastIndex(createSQL, ")")
+	if start < 0 || end < start {
+		return nil, -1
+	}
+	var (
+		names []string
+		rowid = -1
+		depth int
+		quote rune
+		from  = start + 1
+	)
+	var defs []string
+	for i, r := range createSQL[start+1 : end] {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'' || r == '`':
+			quote = r
+		case r == '[':
+			quote = ']'
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			defs = append(defs, createSQL[from:start+1+i])
+			from = start + 2 + i
+		}
+	}
+	defs = append(defs, createSQL[from:end])
+	for _, def := range defs {
+		def = strings.TrimSpace(def)
+		if def == "" || constraintPattern.MatchString(def) {
+			continue
+		}
+		name, rest := columnName(def)
+		if rowidPattern.MatchString(rest) {
+			rowid = len(names)
+		}
+		names = append(names, name)
+	}
+	return names, rowid
+}
+
+// columnName splits a column definition into the column's name, without
+// quotes, and the rest of the definition.
+func columnName(def string) (string, string) {
+	switch def[0] {
+	case '"', '`', '\'':
+		if i := strings.IndexByte(def[1:], def[0]); i >= 0 {
+			return def[1 : i+1], def[i+2:]
+		}
+	case '[':
+		if i := strings.IndexByte(def, ']'); i >= 0 {
+			return def[1:i], def[i+1:]
+		}
+	}
+	name, rest, _ := strings.Cut(def, " ")
+	return name, rest
+}
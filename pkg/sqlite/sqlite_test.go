@@ -0,0 +1,74 @@
+package sqlite
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	db, err := Open("testdata/sample.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	schema, err := db.Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var table Object
+	var types []string
+	for _, o := range schema {
+		types = append(types, o.Type+" "+o.Name)
+		if o.Name == "user list" {
+			table = o
+		}
+	}
+	if !slices.Equal(types, []string{"table user list", "table kv", "index idx_name", "view top"}) {
+		t.Fatalf("schema = %q", types)
+	}
+
+	columns, rowid := Columns(table.SQL)
+	if !slices.Equal(columns, []string{"id", "full name", "score", "data"}) || rowid != 0 {
+		t.Errorf("Columns = %q, %d", columns, rowid)
+	}
+	if n, err := db.Count(table.RootPage); n != 300 || err != nil {
+		t.Errorf("Count = %d, %v; want 300", n, err)
+	}
+
+	var rows [][]any
+	err = db.Scan(table.RootPage, func(id int64, values []any) bool {
+		if values[0] != nil || id != int64(len(rows)+1) {
+			t.Errorf("row %d: rowid %d, id column %v", len(rows)+1, id, values[0])
+		}
+		rows = append(rows, values)
+		return len(rows) < 300
+	})
+	if err != nil || len(rows) != 300 {
+		t.Fatalf("Scan read %d rows: %v", len(rows), err)
+	}
+	if rows[0][1] != "user 1" || rows[0][2] != 0.5 {
+		t.Errorf("row 1 = %v", rows[0])
+	}
+	if s, _ := rows[1][1].(string); s != strings.Repeat("x", 3000) {
+		t.Errorf("Expected the overflowing row to be read whole, got %d bytes", len(s))
+	}
+	if rows[2][2] != nil || string(rows[3][3].([]byte)) != "\x04\x04\x04" {
+		t.Errorf("rows 3-4 = %v, %v", rows[2], rows[3])
+	}
+	if rows[299][1] != "user 300" {
+		t.Errorf("row 300 = %v", rows[299])
+	}
+}
+
+func TestColumns(t *testing.T) {
+	for sql, want := range map[string][]string{
+		"CREATE TABLE t(a, b INT DEFAULT (1, 2), [c d] TEXT, PRIMARY KEY (a, b))": {"a", "b", "c d"},
+		"CREATE TABLE `x`(`a,b` TEXT, CONSTRAINT u UNIQUE (`a,b`))":               {"a,b"},
+	} {
+		if got, rowid := Columns(sql); !slices.Equal(got, want) || rowid != -1 {
+			t.Errorf("Columns(%q) = %q, %d; want %q, -1", sql, got, rowid, want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Package startup records how long the phases of starting the CLI take,
+// such as loading the settings, authenticating and creating the client, up
+// to the first token of the first response, for --profile-startup.
+package startup
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Span is a phase of starting, or an event such as the first token, which
+// takes no time.
+type Span struct {
+	Name string
+	// Start is how long after the CLI started the phase started.
+	Start    time.Duration
+	Duration time.Duration
+	// Event is set for spans that mark a moment rather than a phase.
+	Event bool
+}
+
+var (
+	mu sync.Mutex
+	// started is when the CLI started, as near as this package can tell:
+	// its variables are initialized before main runs.
+	started = time.Now()
+	spans   []Span
+	marked  = map[string]bool{}
+)
+
+// Begin starts timing the named phase and returns the function ending it.
+// A phase that never ends, such as one that replaces the process, is left
+// out.
+func Begin(name string) (end func()) {
+	begin := time.Now()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			mu.Lock()
+			defer mu.Unlock()
+			spans = append(spans, Span{Name: name, Start: begin.Sub(started), Duration: time.Since(begin)})
+		})
+	}
+}
+
+// Mark records that the named event happened. Only its first time is
+// recorded, so that later responses do not move the first token.
+func Mark(name string) {
+	now := time.Now()
+	mu.Lock()
+	defer mu.Unlock()
+	if marked[name] {
+		return
+	}
+	marked[name] = true
+	spans = append(spans, Span{Name: name, Start: now.Sub(started), Event: true})
+}
+
+// Spans returns the phases and events recorded so far, in the order they
+// started.
+func Spans() []Span {
+	mu.Lock()
+	defer mu.Unlock()
+	sorted := slices.Clone(spans)
+	slices.SortStableFunc(sorted, func(a, b Span) int { return int(a.Start - b.Start) })
+	return sorted
+}
+
+// Report writes the timing breakdown of starting command to w: when each
+// phase started and how long it took, and how long the CLI has been
+// running.
+func Report(w io.Writer, command string) {
+	total := time.Since(started)
+	fmt.Fprintf(w, "Startup profile of %s:\n", command)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "start\tduration\t\tphase")
+	for _, s := range Spans() {
+		duration := ""
+		if !s.Event {
+			duration = formatDuration(s.Duration)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t\t%s\n", formatDuration(s.Start), duration, s.Name)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "Total: %s\n", formatDuration(total))
+}
+
+// formatDuration rounds d to a tenth of a millisecond.
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+}
+
+// ResetForTesting forgets the recorded spans and starts the clock again.
+func ResetForTesting() {
+	mu.Lock()
+	defer mu.Unlock()
+	started = time.Now()
+	spans = nil
+	marked = map[string]bool{}
+}
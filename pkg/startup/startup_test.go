@@ -0,0 +1,41 @@
+package startup
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSpansAndReport(t *testing.T) {
+	ResetForTesting()
+	defer ResetForTesting()
+
+	endAuth := Begin("authenticate")
+	time.Sleep(2 * time.Millisecond)
+	endAuth()
+	endAuth()
+	Mark("first token")
+	Mark("first token")
+	never := Begin("sandbox")
+	_ = never
+
+	spans := Spans()
+	if len(spans) != 2 {
+		t.Fatalf("spans = %+v, want the ended phase and the event once", spans)
+	}
+	if spans[0].Name != "authenticate" || spans[0].Duration < 2*time.Millisecond {
+		t.Errorf("first span = %+v, want authenticate taking at least 2ms", spans[0])
+	}
+	if spans[1].Name != "first token" || !spans[1].Event || spans[1].Start < spans[0].Start+spans[0].Duration {
+		t.Errorf("second span = %+v, want the first token after authenticating", spans[1])
+	}
+
+	var out strings.Builder
+	Report(&out, "gemini")
+	report := out.String()
+	for _, want := range []string{"Startup profile of gemini:", "ms  authenticate\n", "first token\n", "Total: "} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report = %q, want it to contain %q", report, want)
+		}
+	}
+}
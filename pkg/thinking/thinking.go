@@ -0,0 +1,129 @@
+// Package thinking sets how many tokens the 2.5 series models may spend
+// thinking before they answer. The API accepts
+// generationConfig.thinkingConfig, but the client library does not expose
+// it, so it is added to the request bodies on their way out.
+package thinking
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/apirequest"
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+// Dynamic lets the model decide how much to think.
+const Dynamic = -1
+
+// efforts are the budgets of the reasoning efforts model.reasoningEffort
+// accepts. High is the most 2.5 Flash accepts.
+var efforts = map[string]int32{
+	"none":    0,
+	"low":     1024,
+	"medium":  8192,
+	"high":    24576,
+	"dynamic": Dynamic,
+}
+
+// EffortBudget returns the thinking budget of a reasoning effort.
+func EffortBudget(effort string) (int32, error) {
+	budget, ok := efforts[strings.ToLower(effort)]
+	if !ok {
+		return 0, fmt.Errorf("unknown reasoning effort %q (want none, low, medium, high or dynamic)", effort)
+	}
+	return budget, nil
+}
+
+// Budget returns the thinking budget the model settings ask for, with
+// model.thinkingBudget taking precedence over model.reasoningEffort. ok is
+// false if they leave it to the model's default.
+func Budget(m *config.ModelSettings) (budget int32, ok bool, err error) {
+	if m == nil {
+		return 0, false, nil
+	}
+	if m.ThinkingBudget != nil {
+		return *m.ThinkingBudget, true, nil
+	}
+	if m.ReasoningEffort == "" {
+		return 0, false, nil
+	}
+	budget, err = EffortBudget(m.ReasoningEffort)
+	return budget, err == nil, err
+}
+
+// Supports reports whether the model named name thinks, and so takes a
+// thinking budget: the Gemma models served by the API do not.
+func Supports(name string) bool {
+	name = strings.TrimPrefix(strings.ToLower(name), "models/")
+	return !strings.HasPrefix(name, "gemma")
+}
+
+// requestModel returns the name of the model a request path is for, as in
+// /v1beta/models/<name>:generateContent.
+func requestModel(path string) string {
+	_, rest, _ := strings.Cut(path, "/models/")
+	name, _, _ := strings.Cut(rest, ":")
+	return name
+}
+
+// Transport returns a round tripper sending budget with every generation
+// request it passes to base, except those to models that do not think.
+func Transport(base http.RoundTripper, budget int32) http.RoundTripper {
+	return &transport{base: base, budget: budget}
+}
+
+type transport struct {
+	base   http.RoundTripper
+	budget int32
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !apirequest.IsGeneration(req) || !Supports(requestModel(req.URL.Path)) {
+		return t.base.RoundTrip(req)
+	}
+
+	req, err := apirequest.RewriteBody(req, func(body []byte) ([]byte, error) { return withBudget(body, t.budget) })
+	if err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// withBudget sets generationConfig.thinkingConfig.thinkingBudget in a
+// request body, leaving the rest as it is.
+func withBudget(body []byte, budget int32) ([]byte, error) {
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, err
+	}
+	generation, err := object(request["generationConfig"])
+	if err != nil {
+		return nil, err
+	}
+	thinking, err := object(generation["thinkingConfig"])
+	if err != nil {
+		return nil, err
+	}
+	thinking["thinkingBudget"], _ = json.Marshal(budget)
+	if generation["thinkingConfig"], err = json.Marshal(thinking); err != nil {
+		return nil, err
+	}
+	if request["generationConfig"], err = json.Marshal(generation); err != nil {
+		return nil, err
+	}
+	return json.Marshal(request)
+}
+
+// object decodes a JSON object, or returns an empty one if raw is empty.
+func object(raw json.RawMessage) (map[string]json.RawMessage, error) {
+	obj := map[string]json.RawMessage{}
+	if len(raw) == 0 {
+		return obj, nil
+	}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
@@ -0,0 +1,100 @@
+package thinking
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/auth"
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+)
+
+func TestTransportSendsBudget(t *testing.T) {
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &request)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(w, `{"candidates":[{"content":{"parts":[{"text":"ok"}]}}]}`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey("key"), option.WithEndpoint(server.URL),
+		auth.ClientOption("key", Transport(http.DefaultTransport, 512)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	model := client.GenerativeModel("gemini-2.5-flash")
+	model.SetTemperature(0)
+	if _, err := model.GenerateContent(ctx, genai.Text("hi")); err != nil {
+		t.Fatal(err)
+	}
+
+	generation, _ := request["generationConfig"].(map[string]any)
+	thinking, _ := generation["thinkingConfig"].(map[string]any)
+	if thinking["thinkingBudget"] != float64(512) || generation["temperature"] != float64(0) {
+		t.Errorf("generationConfig = %v, want the budget added to the temperature", generation)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	zero := int32(0)
+	tests := []struct {
+		name     string
+		settings *config.ModelSettings
+		want     int32
+		wantOK   bool
+		wantErr  bool
+	}{
+		{name: "unset", settings: &config.ModelSettings{}},
+		{name: "nil"},
+		{name: "budget", settings: &config.ModelSettings{ThinkingBudget: &zero, ReasoningEffort: "high"}, want: 0, wantOK: true},
+		{name: "effort", settings: &config.ModelSettings{ReasoningEffort: "Medium"}, want: 8192, wantOK: true},
+		{name: "dynamic", settings: &config.ModelSettings{ReasoningEffort: "dynamic"}, want: Dynamic, wantOK: true},
+		{name: "unknown effort", settings: &config.ModelSettings{ReasoningEffort: "extreme"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := Budget(tt.settings)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Budget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Budget() = %d, %v, want %d, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestTransportSkipsModelsThatDoNotThink(t *testing.T) {
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &request)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(w, `{"candidates":[{"content":{"parts":[{"text":"ok"}]}}]}`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey("key"), option.WithEndpoint(server.URL),
+		auth.ClientOption("key", Transport(http.DefaultTransport, 512)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.GenerativeModel("gemma-3-27b-it").GenerateContent(ctx, genai.Text("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if generation, _ := request["generationConfig"].(map[string]any); generation["thinkingConfig"] != nil {
+		t.Errorf("generationConfig = %v, want no thinking budget for Gemma", generation)
+	}
+}
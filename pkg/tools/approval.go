@@ -0,0 +1,135 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ApprovalMode is which of the changes and commands of tools run without
+// asking the user, as --approval-mode sets.
+type ApprovalMode string
+
+const (
+	// ApprovalDefault asks the user about every change and command.
+	ApprovalDefault ApprovalMode = "default"
+	// ApprovalAutoEdit approves changes to files and asks about the rest.
+	ApprovalAutoEdit ApprovalMode = "auto_edit"
+	// ApprovalYolo approves everything, as --yolo does.
+	ApprovalYolo ApprovalMode = "yolo"
+)
+
+// ParseApprovalMode returns the approval mode named s. "" is the default
+// mode.
+func ParseApprovalMode(s string) (ApprovalMode, error) {
+	switch mode := ApprovalMode(s); mode {
+	case "":
+		return ApprovalDefault, nil
+	case ApprovalDefault, ApprovalAutoEdit, ApprovalYolo:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid approval mode %q (want default, auto_edit or yolo)", s)
+}
+
+// action is the kind of thing a tool asks the user to approve.
+type action int
+
+const (
+	// editAction changes files.
+	editAction action = iota
+	// executeAction runs a command.
+	executeAction
+	// networkAction sends requests to a host.
+	networkAction
+)
+
+// autoApproved reports whether the approval mode of w approves a without
+// asking the user.
+func (w *Workspace) autoApproved(a action) bool {
+	switch w.Approval {
+	case ApprovalYolo:
+		return true
+	case ApprovalAutoEdit:
+		return a == editAction
+	}
+	return false
+}
+
+// allowRuleKey is the context key of the tools.allowed entry that would
+// approve the action a Confirmer is asked about.
+type allowRuleKey struct{}
+
+// AllowRule returns the tools.allowed entry that would approve the action
+// a Confirmer is asked about with ctx from now on, such as
+// `run_shell_command("go test ./...")`, or "" if it cannot be allowed for
+// good. A Confirmer may offer to add it to the settings.
+func AllowRule(ctx context.Context) string {
+	rule, _ := ctx.Value(allowRuleKey{}).(string)
+	return rule
+}
+
+// WithAllowRule returns ctx for asking a Confirmer about an action rule
+// would approve.
+func WithAllowRule(ctx context.Context, rule string) context.Context {
+	return context.WithValue(ctx, allowRuleKey{}, rule)
+}
+
+// allowed reports whether tools.allowed approves the actions rule names:
+// it lists rule, or the whole tool, or for a shell command a prefix of it
+// (see AllowsCommand), or for a tool of an MCP server its qualified name,
+// server__tool. Command prefixes given to --allowed-tools approve
+// the commands they allow as well, so that those can run unattended.
+// Nothing approves the empty rule, which is what commands run with
+// variables get, since no entry says which variables it allows.
+func (w *Workspace) allowed(rule string) bool {
+	if rule == "" {
+		return false
+	}
+	tool, command, isCall := parsePattern(rule)
+	if exact, ok := exactCommand(command); ok {
+		command = exact
+	}
+	if tool == ShellToolName && isCall && matchesPrefix(w.Allowed, command) {
+		return true
+	}
+	if w.Settings == nil || w.Settings.Tools == nil {
+		return false
+	}
+	allowed := w.Settings.Tools.Allowed
+	if tool == ShellToolName && isCall {
+		return AllowsCommand(allowed, command)
+	}
+	if slices.Contains(allowed, rule) || slices.Contains(allowed, tool) {
+		return true
+	}
+	qualified := w.qualifiedToolName(tool)
+	return qualified != "" && slices.Contains(allowed, qualified)
+}
+
+// shellRule returns the tools.allowed entry allowing command only, with
+// the command quoted so that it is not taken for a prefix of others.
+func shellRule(command string) string {
+	return ShellToolName + "(" + strconv.Quote(strings.TrimSpace(command)) + ")"
+}
+
+// confirm asks the user to approve a, summarized by title and shown in
+// full by details, unless the approval mode or the tools.allowed entry
+// rule approves it already. Callers check that w.Confirm is set if it is
+// not approved already.
+func (w *Workspace) confirm(ctx context.Context, a action, rule, title, details string) (bool, error) {
+	if w.autoApproved(a) {
+		if w.Approved != nil {
+			w.Approved(title)
+		}
+		return true, nil
+	}
+	if w.allowed(rule) {
+		return true, nil
+	}
+	if rule != "" {
+		ctx = WithAllowRule(ctx, rule)
+	}
+	return w.Confirm(ctx, title, details)
+}
@@ -0,0 +1,122 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+func TestApprovalModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	ws := testWorkspace(t, nil)
+	ws.Confirm = nil
+	var approved []string
+	ws.Approved = func(title string) { approved = append(approved, title) }
+	var changed []string
+	ws.FilesChanged = func(paths []string) { changed = append(changed, paths...) }
+
+	// Without a way to ask, the default mode refuses commands and changes.
+	if resp := runTool(t, ws, ShellToolName, map[string]any{"command": "true"}); !strings.Contains(resp["error"].(string), "approval") {
+		t.Errorf("Expected the command to be refused, got %v", resp)
+	}
+	if resp := runTool(t, ws, WriteFileToolName, map[string]any{"path": "a.txt", "content": "a"}); resp["error"] == nil {
+		t.Errorf("Expected the change to be refused, got %v", resp)
+	}
+
+	// auto_edit approves changes, but not commands.
+	ws.Approval = ApprovalAutoEdit
+	if resp := runTool(t, ws, WriteFileToolName, map[string]any{"path": "a.txt", "content": "a"}); resp["error"] != nil {
+		t.Errorf("Expected the change to be made, got %v", resp)
+	}
+	if _, err := os.Stat(filepath.Join(ws.Roots[0], "a.txt")); err != nil {
+		t.Error(err)
+	}
+	if len(changed) != 1 || filepath.Base(changed[0]) != "a.txt" {
+		t.Errorf("changed = %q, want only the change that was made", changed)
+	}
+	if resp := runTool(t, ws, ShellToolName, map[string]any{"command": "true"}); resp["error"] == nil {
+		t.Errorf("Expected the command to be refused, got %v", resp)
+	}
+
+	// yolo approves everything.
+	ws.Approval = ApprovalYolo
+	if resp := runTool(t, ws, ShellToolName, map[string]any{"command": "echo hi"}); resp["output"] != "hi\n" {
+		t.Errorf("Expected the command to run, got %v", resp)
+	}
+	if len(approved) != 2 || approved[0] != "Apply changes to 1 file(s)?" || approved[1] != "Run this command?" {
+		t.Errorf("approved = %q, want the change and the command", approved)
+	}
+}
+
+func TestParseApprovalMode(t *testing.T) {
+	for s, want := range map[string]ApprovalMode{"": ApprovalDefault, "default": ApprovalDefault, "auto_edit": ApprovalAutoEdit, "yolo": ApprovalYolo} {
+		if got, err := ParseApprovalMode(s); err != nil || got != want {
+			t.Errorf("ParseApprovalMode(%q) = %q, %v, want %q", s, got, err, want)
+		}
+	}
+	if _, err := ParseApprovalMode("always"); err == nil {
+		t.Error("ParseApprovalMode(always): want an error")
+	}
+}
+
+func TestAllowedRules(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	ws := testWorkspace(t, &config.Settings{Tools: &config.ToolsSettings{Allowed: []string{"run_shell_command(echo hi)"}}})
+	var asked []string
+	ws.Confirm = func(ctx context.Context, title, details string) (bool, error) {
+		asked = append(asked, AllowRule(ctx))
+		return false, nil
+	}
+
+	if resp := runTool(t, ws, ShellToolName, map[string]any{"command": " echo hi "}); resp["output"] != "hi\n" {
+		t.Errorf("Expected the allowed command to run, got %v", resp)
+	}
+	if resp := runTool(t, ws, ShellToolName, map[string]any{"command": "echo hi; rm -rf sub"}); resp["error"] == nil {
+		t.Errorf("Expected the command to be refused, got %v", resp)
+	}
+	if !slices.Equal(asked, []string{`run_shell_command("echo hi; rm -rf sub")`}) {
+		t.Errorf("asked with rules %q, want only the other command's", asked)
+	}
+
+	// A command allowed for good does not allow others starting with it,
+	// as entries written as prefixes do.
+	ws.Settings.Tools.Allowed = []string{shellRule("echo hi")}
+	if !ws.allowed(shellRule(" echo hi")) {
+		t.Errorf("%s does not allow the command itself", shellRule("echo hi"))
+	}
+	for _, command := range []string{"echo hi there", "echo hi /", "echo hi; rm -rf sub"} {
+		if ws.allowed(shellRule(command)) {
+			t.Errorf("%s allows %q", shellRule("echo hi"), command)
+		}
+	}
+
+	// Prefixes do not allow commands run with variables, which may make
+	// them run anything.
+	ws.Settings.Tools.Allowed = []string{"run_shell_command(echo)"}
+	ws.Allowed = []string{"run_shell_command(echo)"}
+	ws.Confirm = nil
+	if resp := runTool(t, ws, ShellToolName, map[string]any{"command": "echo hi"}); resp["output"] != "hi\n" {
+		t.Errorf("Expected the command to run, got %v", resp)
+	}
+	if resp := runTool(t, ws, ShellToolName, map[string]any{"command": "echo hi", "env": []any{"BASH_ENV=/tmp/x.sh"}}); resp["error"] == nil {
+		t.Errorf("Expected the command with variables to be refused, got %v", resp)
+	}
+	ws.Allowed = nil
+
+	// The tool name allows all its commands, even without a way to ask.
+	ws.Settings.Tools.Allowed = []string{ShellToolName}
+	ws.Confirm = nil
+	if resp := runTool(t, ws, ShellToolName, map[string]any{"command": "echo bye"}); resp["output"] != "bye\n" {
+		t.Errorf("Expected the command to run, got %v", resp)
+	}
+}
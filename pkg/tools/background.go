@@ -0,0 +1,329 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// Names of the tools managing the commands run_shell_command started in the
+// background.
+const (
+	ListBackgroundProcessesToolName = "list_background_processes"
+	ReadBackgroundOutputToolName    = "read_background_output"
+	StopBackgroundProcessToolName   = "stop_background_process"
+)
+
+const (
+	// backgroundSettle is how long run_shell_command waits for the output
+	// of a command it starts in the background, so that the model sees
+	// whether it started well.
+	backgroundSettle = 2 * time.Second
+	// maxBackgroundOutput bounds the output kept of each background
+	// command; older output is dropped.
+	maxBackgroundOutput = 64 << 10
+	// defaultOutputLines is how many of the last lines of output
+	// read_background_output returns unless asked for more.
+	defaultOutputLines = 50
+)
+
+var backgroundTools = map[string]struct {
+	declaration *genai.FunctionDeclaration
+	run         handler
+}{
+	ListBackgroundProcessesToolName: {&genai.FunctionDeclaration{
+		Name:        ListBackgroundProcessesToolName,
+		Description: "Lists the commands run_shell_command started in the background, with their IDs, PIDs and whether they still run.",
+		Parameters:  &genai.Schema{Type: genai.TypeObject, Properties: map[string]*genai.Schema{}},
+	}, listBackgroundProcesses},
+	ReadBackgroundOutputToolName: {&genai.FunctionDeclaration{
+		Name:        ReadBackgroundOutputToolName,
+		Description: "Returns the last lines of the combined stdout and stderr of a command started in the background, and its exit code once it exited.",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"id":    {Type: genai.TypeInteger, Description: "The ID run_shell_command returned for the command."},
+				"lines": {Type: genai.TypeInteger, Description: fmt.Sprintf("How many of the last lines to return. Defaults to %d.", defaultOutputLines)},
+			},
+			Required: []string{"id"},
+		},
+	}, readBackgroundOutput},
+	StopBackgroundProcessToolName: {&genai.FunctionDeclaration{
+		Name:        StopBackgroundProcessToolName,
+		Description: "Stops a command started in the background, and the processes it started, and returns the last lines of its output.",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"id": {Type: genai.TypeInteger, Description: "The ID run_shell_command returned for the command."},
+			},
+			Required: []string{"id"},
+		},
+	}, stopBackgroundProcess},
+}
+
+// Process is a command run_shell_command started in the background.
+type Process struct {
+	// ID identifies the process to the model and the user, PID to the
+	// system.
+	ID      int
+	PID     int
+	Command string
+	// Dir is the directory it runs in, relative to the workspace root.
+	Dir     string
+	Started time.Time
+
+	cmd    *exec.Cmd
+	output tailBuffer
+	// done is closed once the process exited with exitCode.
+	done     chan struct{}
+	exitCode int
+}
+
+// Running reports whether the process has not exited yet.
+func (p *Process) Running() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// ExitCode returns the exit code of the process, once it exited.
+func (p *Process) ExitCode() int {
+	<-p.done
+	return p.exitCode
+}
+
+// Status describes whether the process runs, or how it exited.
+func (p *Process) Status() string {
+	if p.Running() {
+		return "running"
+	}
+	return fmt.Sprintf("exited with code %d", p.ExitCode())
+}
+
+// ProcessOutput returns the last lines of the combined stdout and stderr
+// of p, as run_shell_command returns output.
+func (w *Workspace) ProcessOutput(p *Process, lines int) string {
+	return w.shellOutput(p.output.tail(lines))
+}
+
+// Stop kills the process and the processes it started, and waits for it to
+// exit.
+func (p *Process) Stop() error {
+	if !p.Running() {
+		return nil
+	}
+	if err := killProcessGroup(p.cmd.Process); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	<-p.done
+	return nil
+}
+
+// tailBuffer keeps the last maxBackgroundOutput bytes written to it.
+type tailBuffer struct {
+	mu sync.Mutex
+	b  []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.b = append(t.b, p...)
+	if over := len(t.b) - maxBackgroundOutput; over > 0 {
+		t.b = slices.Delete(t.b, 0, over)
+	}
+	return len(p), nil
+}
+
+// tail returns the last n lines, the last of which need not end with a
+// newline.
+func (t *tailBuffer) tail(n int) []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	start := len(t.b)
+	if start > 0 && t.b[start-1] == '\n' {
+		start--
+	}
+	for ; n > 0 && start >= 0; n-- {
+		start = bytes.LastIndexByte(t.b[:start], '\n')
+	}
+	return slices.Clone(t.b[start+1:])
+}
+
+// Processes are the commands run_shell_command started in the background.
+// Their IDs count up from 1 for the session.
+type Processes struct {
+	mu    sync.Mutex
+	procs []*Process
+}
+
+// List returns the processes, in the order they were started.
+func (ps *Processes) List() []*Process {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return slices.Clone(ps.procs)
+}
+
+// Get returns the process with id.
+func (ps *Processes) Get(id int) (*Process, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	for _, p := range ps.procs {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("there is no background process %d", id)
+}
+
+// StopAll stops the processes still running, as the session ends.
+func (ps *Processes) StopAll(context.Context) error {
+	var errs []error
+	for _, p := range ps.List() {
+		if err := p.Stop(); err != nil {
+			errs = append(errs, fmt.Errorf("process %d: %w", p.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// start starts cmd in a process group of its own, so that stopping it
+// stops what it started too, and adds it.
+func (ps *Processes) start(cmd *exec.Cmd, command, dir string) (*Process, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	p := &Process{ID: len(ps.procs) + 1, Command: command, Dir: dir, cmd: cmd, done: make(chan struct{})}
+	cmd.Stdout, cmd.Stderr = &p.output, &p.output
+	// Processes it started that left the group may keep the output open
+	// after it exited.
+	cmd.WaitDelay = time.Second
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to run command: %w", err)
+	}
+	p.PID, p.Started = cmd.Process.Pid, time.Now()
+	go func() {
+		err := cmd.Wait()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			p.exitCode = exitErr.ExitCode()
+		}
+		close(p.done)
+	}()
+	ps.procs = append(ps.procs, p)
+	return p, nil
+}
+
+// startBackground starts command in dir like RunShell, but returns once
+// it has run for backgroundSettle or exited, leaving it to run on.
+func (w *Workspace) startBackground(dir, rel, command string, extra map[string]string) (map[string]any, error) {
+	if w.Processes == nil {
+		return nil, errors.New("commands cannot run in the background in this mode")
+	}
+	if w.Sandbox != nil {
+		return nil, errors.New("commands cannot run in the background in the sandbox")
+	}
+	// The command outlives the tool call, and is stopped with
+	// stop_background_process instead.
+	cmd := shellCommand(context.Background(), ulimitPrefix(w.limits(ShellToolName))+command)
+	cmd.Dir = dir
+	cmd.Env = shellEnv(os.Environ(), w.envAllowlist(), extra)
+	p, err := w.Processes.start(cmd, command, rel)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-p.done:
+	case <-time.After(backgroundSettle):
+	}
+	resp := w.processResponse(p, defaultOutputLines)
+	resp["command"] = command
+	resp["directory"] = rel
+	if p.Running() {
+		resp["note"] = fmt.Sprintf("The command runs in the background. Use %s to see more of its output and %s to stop it.",
+			ReadBackgroundOutputToolName, StopBackgroundProcessToolName)
+	}
+	return resp, nil
+}
+
+// processResponse describes p to the model, with the last lines of its
+// output.
+func (w *Workspace) processResponse(p *Process, lines int) map[string]any {
+	resp := map[string]any{
+		"id":     p.ID,
+		"pid":    p.PID,
+		"status": p.Status(),
+		"output": w.ProcessOutput(p, lines),
+	}
+	if !p.Running() {
+		resp["exit_code"] = p.ExitCode()
+	}
+	return resp
+}
+
+func listBackgroundProcesses(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	if ws.Processes == nil {
+		return map[string]any{"processes": []any{}}, nil
+	}
+	var procs []map[string]any
+	for _, p := range ws.Processes.List() {
+		procs = append(procs, map[string]any{
+			"id":        p.ID,
+			"pid":       p.PID,
+			"command":   p.Command,
+			"directory": p.Dir,
+			"status":    p.Status(),
+			"started":   p.Started.Format(time.RFC3339),
+		})
+	}
+	return map[string]any{"processes": procs}, nil
+}
+
+func readBackgroundOutput(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	p, err := backgroundProcess(ws, args)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := intArg(args, "lines", defaultOutputLines)
+	if err != nil {
+		return nil, err
+	}
+	return ws.processResponse(p, max(lines, 1)), nil
+}
+
+func stopBackgroundProcess(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	p, err := backgroundProcess(ws, args)
+	if err != nil {
+		return nil, err
+	}
+	wasRunning := p.Running()
+	if err := p.Stop(); err != nil {
+		return nil, fmt.Errorf("failed to stop process %d: %w", p.ID, err)
+	}
+	resp := ws.processResponse(p, defaultOutputLines)
+	resp["stopped"] = wasRunning
+	return resp, nil
+}
+
+// backgroundProcess returns the process the id argument names.
+func backgroundProcess(ws *Workspace, args map[string]any) (*Process, error) {
+	id, err := intArg(args, "id", 0)
+	if err != nil {
+		return nil, err
+	}
+	if ws.Processes == nil {
+		return nil, fmt.Errorf("there is no background process %d", id)
+	}
+	return ws.Processes.Get(id)
+}
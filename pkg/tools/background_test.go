@@ -0,0 +1,80 @@
+package tools
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackgroundProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	ws := testWorkspace(t, nil)
+	ws.Processes = &Processes{}
+	defer ws.Processes.StopAll(context.Background())
+
+	start := time.Now()
+	resp := runShell(t, ws, map[string]any{"command": "echo ready; sleep 60 & wait", "background": true})
+	if resp["status"] != "running" || resp["id"] != 1 || !strings.Contains(resp["output"].(string), "ready") {
+		t.Fatalf("resp = %v", resp)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("run_shell_command waited %s for the background command", d)
+	}
+
+	resp = runTool(t, ws, ListBackgroundProcessesToolName, nil)
+	procs := resp["processes"].([]any)
+	if len(procs) != 1 || procs[0].(map[string]any)["command"] != "echo ready; sleep 60 & wait" {
+		t.Errorf("processes = %v", procs)
+	}
+
+	resp = runTool(t, ws, StopBackgroundProcessToolName, map[string]any{"id": 1.0})
+	if resp["stopped"] != true || resp["status"] == "running" {
+		t.Errorf("stop = %v", resp)
+	}
+	resp = runTool(t, ws, ReadBackgroundOutputToolName, map[string]any{"id": 1.0, "lines": 1.0})
+	if resp["output"] != "ready\n" || resp["exit_code"] == nil {
+		t.Errorf("output = %v", resp)
+	}
+	if resp = runTool(t, ws, StopBackgroundProcessToolName, map[string]any{"id": 2.0}); resp["error"] == nil {
+		t.Errorf("Expected an error stopping an unknown process, got %v", resp)
+	}
+}
+
+func TestBackgroundCommandExits(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	ws := testWorkspace(t, nil)
+	ws.Processes = &Processes{}
+	resp := runShell(t, ws, map[string]any{"command": "echo failed; exit 4", "background": true})
+	if resp["status"] != "exited with code 4" || resp["exit_code"] != 4 || resp["output"] != "failed\n" {
+		t.Errorf("resp = %v", resp)
+	}
+	if _, ok := resp["note"]; ok {
+		t.Error("Expected no note on reading the output of a command that exited")
+	}
+
+	// Without Processes, commands cannot run in the background.
+	ws.Processes = nil
+	if resp := runShell(t, ws, map[string]any{"command": "true", "background": true}); resp["error"] == nil {
+		t.Errorf("Expected an error, got %v", resp)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	var b tailBuffer
+	b.Write([]byte("one\ntwo\nthree"))
+	for n, want := range map[int]string{1: "three", 2: "two\nthree", 5: "one\ntwo\nthree"} {
+		if got := string(b.tail(n)); got != want {
+			t.Errorf("tail(%d) = %q, want %q", n, got, want)
+		}
+	}
+	b.Write([]byte("\n" + strings.Repeat("x", maxBackgroundOutput)))
+	if got := b.tail(2); len(got) != maxBackgroundOutput {
+		t.Errorf("Expected the buffer to keep the last %d bytes, got %d", maxBackgroundOutput, len(got))
+	}
+}
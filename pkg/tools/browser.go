@@ -0,0 +1,127 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// Names of the browser tools, offered with --enable-browser-tools.
+const (
+	BrowserNavigateToolName   = "browser_navigate"
+	BrowserScreenshotToolName = "browser_screenshot"
+	BrowserReadTextToolName   = "browser_read_text"
+)
+
+// maxPageText caps the page text returned by browser_read_text.
+const maxPageText = 100 << 10
+
+// browserTools are the tools driving ws.Browser, by name.
+var browserTools = map[string]struct {
+	declaration *genai.FunctionDeclaration
+	run         handler
+}{
+	BrowserNavigateToolName: {&genai.FunctionDeclaration{
+		Name:        BrowserNavigateToolName,
+		Description: "Opens a URL in the browser, e.g. a web app served locally, and describes the loaded page.",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"url": {Type: genai.TypeString, Description: "The URL to open."},
+			},
+			Required: []string{"url"},
+		},
+	}, browserNavigate},
+	BrowserScreenshotToolName: {&genai.FunctionDeclaration{
+		Name:        BrowserScreenshotToolName,
+		Description: "Takes a screenshot of the page open in the browser and saves it to a file, whose path is returned.",
+		Parameters:  &genai.Schema{Type: genai.TypeObject},
+	}, browserScreenshot},
+	BrowserReadTextToolName: {&genai.FunctionDeclaration{
+		Name:        BrowserReadTextToolName,
+		Description: "Returns the rendered text of the page open in the browser, or of the elements matching a CSS selector.",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"selector": {Type: genai.TypeString, Description: "A CSS selector. Defaults to the whole page."},
+			},
+		},
+	}, browserReadText},
+}
+
+var errNoBrowser = errors.New("the browser tools are disabled; start the CLI with --enable-browser-tools")
+
+func browserNavigate(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	if ws.Browser == nil {
+		return nil, errNoBrowser
+	}
+	url, err := stringArg(args, "url")
+	if err != nil {
+		return nil, err
+	}
+	if url == "" {
+		return nil, errors.New("url must not be empty")
+	}
+	page, err := ws.Browser.Navigate(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"page": truncateText(page, maxPageText)}, nil
+}
+
+func browserScreenshot(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	if ws.Browser == nil {
+		return nil, errNoBrowser
+	}
+	shot, err := ws.Browser.Screenshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := config.ProjectTempDir(ws.Roots[0])
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.Join(dir, "screenshots")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	ext := ".png"
+	if exts, _ := mime.ExtensionsByType(shot.MimeType); len(exts) > 0 {
+		ext = exts[0]
+	}
+	path := filepath.Join(dir, time.Now().Format("20060102-150405.000")+ext)
+	if err := os.WriteFile(path, shot.Data, 0644); err != nil {
+		return nil, err
+	}
+	return map[string]any{"path": path, "mimeType": shot.MimeType, "size": formatSize(int64(len(shot.Data)))}, nil
+}
+
+func browserReadText(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	if ws.Browser == nil {
+		return nil, errNoBrowser
+	}
+	selector, err := stringArg(args, "selector")
+	if err != nil {
+		return nil, err
+	}
+	text, err := ws.Browser.Text(ctx, selector)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"text": truncateText(text, maxPageText)}, nil
+}
+
+// truncateText cuts s off after max bytes, saying so.
+func truncateText(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return string(trimPartialRune([]byte(s[:max]))) + fmt.Sprintf("\n... [truncated, %s in total]", formatSize(int64(len(s))))
+}
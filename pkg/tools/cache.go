@@ -0,0 +1,143 @@
+package tools
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// webCacheTTL is how long the results of the web tools are reused.
+const webCacheTTL = 15 * time.Minute
+
+// cachedTools are the tools whose results are reused when they are called
+// again with the same arguments in a session, since models often read the
+// same file or page again. The results of the file tools are reused while
+// the file or directory they name is unchanged and no other tool has run,
+// since it may have changed others; those of the web tools expire.
+var cachedTools = map[string]bool{
+	ReadFileToolName:      true,
+	ListDirectoryToolName: true,
+	InspectFileToolName:   true,
+	PreviewDataToolName:   true,
+	WebFetchToolName:      true,
+	WebSearchToolName:     true,
+}
+
+// webTools are the cached tools that read the web rather than files.
+var webTools = map[string]bool{
+	WebFetchToolName:  true,
+	WebSearchToolName: true,
+}
+
+// resultCache holds the results of the cached tools of a session.
+type resultCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedResult
+}
+
+// cachedResult is a result and what it is valid for: the state of the file
+// a file tool read, or the time a web tool ran.
+type cachedResult struct {
+	tool    string
+	resp    map[string]any
+	created time.Time
+	stamp   fileStamp
+}
+
+// fileStamp tells whether a file changed.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// cacheKey returns the key of the result of fc, or "" if it is not cached.
+func cacheKey(fc *genai.FunctionCall) string {
+	if !cachedTools[fc.Name] {
+		return ""
+	}
+	// Maps are encoded with sorted keys, so equal arguments are equal.
+	args, err := json.Marshal(fc.Args)
+	if err != nil {
+		return ""
+	}
+	return fc.Name + "\x00" + string(args)
+}
+
+// stamp returns the state of the file or directory a call of a file tool
+// names, and false if it cannot tell.
+func (w *Workspace) stamp(fc *genai.FunctionCall) (fileStamp, bool) {
+	path, err := stringArg(fc.Args, "path")
+	if err != nil {
+		return fileStamp{}, false
+	}
+	if path == "" {
+		path = "."
+	}
+	resolved, err := w.Resolve(path)
+	if err != nil {
+		return fileStamp{}, false
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return fileStamp{}, false
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, true
+}
+
+// cachingResults reports whether results are cached and reused in w now:
+// not while a turn has staged file changes the files do not show yet.
+func (w *Workspace) cachingResults() bool {
+	return w.results != nil && (w.tx == nil || w.tx.staged == 0)
+}
+
+// cachedResponse returns the cached response of fc if it is still valid,
+// and otherwise a function caching the response it gets now, or nil if it
+// is not cached. Calls of the tools that are not cached drop the results
+// of the file tools.
+func (w *Workspace) cachedResponse(fc *genai.FunctionCall) (map[string]any, func(map[string]any)) {
+	if w.results == nil {
+		return nil, nil
+	}
+	key := cacheKey(fc)
+	if key == "" {
+		w.results.mu.Lock()
+		defer w.results.mu.Unlock()
+		for k, e := range w.results.entries {
+			if !webTools[e.tool] {
+				delete(w.results.entries, k)
+			}
+		}
+		return nil, nil
+	}
+	if !w.cachingResults() {
+		return nil, nil
+	}
+
+	now := cachedResult{tool: fc.Name, created: time.Now()}
+	if !webTools[fc.Name] {
+		// The state before the call, so that a change during it is
+		// noticed the next time.
+		var ok bool
+		if now.stamp, ok = w.stamp(fc); !ok {
+			return nil, nil
+		}
+	}
+	w.results.mu.Lock()
+	e, ok := w.results.entries[key]
+	w.results.mu.Unlock()
+	if ok && (webTools[fc.Name] && now.created.Sub(e.created) < webCacheTTL || !webTools[fc.Name] && e.stamp == now.stamp) {
+		return e.resp, nil
+	}
+	return nil, func(resp map[string]any) {
+		now.resp = resp
+		w.results.mu.Lock()
+		defer w.results.mu.Unlock()
+		if w.results.entries == nil {
+			w.results.entries = map[string]cachedResult{}
+		}
+		w.results.entries[key] = now
+	}
+}
@@ -0,0 +1,84 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+func TestCachedFileResults(t *testing.T) {
+	ws := testWorkspace(t, nil)
+	path := filepath.Join(ws.Roots[0], "a.txt")
+	os.WriteFile(path, []byte("one\n"), 0644)
+	stamp := time.Now().Add(-time.Hour)
+	os.Chtimes(path, stamp, stamp)
+
+	read := func() any {
+		return runTool(t, ws, ReadFileToolName, map[string]any{"path": "a.txt"})["content"]
+	}
+	// Rewrites that leave the file looking the same reveal reuse.
+	sneakyWrite := func(content string) {
+		os.WriteFile(path, []byte(content), 0644)
+		os.Chtimes(path, stamp, stamp)
+	}
+
+	if got := read(); got != "one" {
+		t.Fatalf("read %q", got)
+	}
+	sneakyWrite("two\n")
+	if got := read(); got != "one" {
+		t.Errorf("Expected the result to be reused, read %q", got)
+	}
+
+	// A changed file is read again.
+	os.WriteFile(path, []byte("three\n"), 0644)
+	if got := read(); got != "three" {
+		t.Errorf("Expected the changed file to be read again, read %q", got)
+	}
+
+	// So is any file once another tool ran.
+	stamp = time.Now().Add(-time.Minute)
+	os.Chtimes(path, stamp, stamp)
+	read()
+	sneakyWrite("four\n")
+	runTool(t, ws, WriteFileToolName, map[string]any{"path": "b.txt", "content": "b"})
+	if got := read(); got != "four" {
+		t.Errorf("Expected the file to be read again after write_file, read %q", got)
+	}
+}
+
+func TestCachedWebResults(t *testing.T) {
+	fetched := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched++
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("page"))
+	}))
+	defer srv.Close()
+
+	ws := testWorkspace(t, &config.Settings{})
+	ws.Confirm = func(ctx context.Context, title, details string) (bool, error) { return true, nil }
+	for range 2 {
+		runTool(t, ws, WebFetchToolName, map[string]any{"url": srv.URL + "/a"})
+	}
+	runTool(t, ws, WebFetchToolName, map[string]any{"url": srv.URL + "/b"})
+	if fetched != 2 {
+		t.Errorf("fetched %d times, want 2", fetched)
+	}
+
+	// Expired results are fetched again.
+	for k, e := range ws.results.entries {
+		e.created = e.created.Add(-webCacheTTL)
+		ws.results.entries[k] = e
+	}
+	runTool(t, ws, WebFetchToolName, map[string]any{"url": srv.URL + "/a"})
+	if fetched != 3 {
+		t.Errorf("fetched %d times, want 3", fetched)
+	}
+}
@@ -0,0 +1,274 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/lsp"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// Names of the code intelligence tools, backed by language servers.
+const (
+	FindDefinitionToolName = "find_definition"
+	FindReferencesToolName = "find_references"
+	RenameSymbolToolName   = "rename_symbol"
+)
+
+// maxLocations caps the locations returned by find_references.
+const maxLocations = 200
+
+// symbolParameters locate a symbol in a file.
+var symbolParameters = map[string]*genai.Schema{
+	"path": {
+		Type:        genai.TypeString,
+		Description: "The file containing the symbol, relative to the workspace root or absolute.",
+	},
+	"line": {
+		Type:        genai.TypeInteger,
+		Description: "The 1-based line the symbol is on.",
+	},
+	"symbol": {
+		Type:        genai.TypeString,
+		Description: "The symbol, e.g. a function or variable name, as written on that line.",
+	},
+	"column": {
+		Type:        genai.TypeInteger,
+		Description: "The 1-based column of the symbol, if `symbol` is ambiguous on the line.",
+	},
+}
+
+var findDefinitionDeclaration = &genai.FunctionDeclaration{
+	Name: FindDefinitionToolName,
+	Description: "Finds where a symbol used in a file is defined, using the project's language server (gopls, " +
+		"typescript-language-server or pyright, or as configured in tools.lsp.servers). More precise than searching for the name.",
+	Parameters: &genai.Schema{Type: genai.TypeObject, Properties: symbolParameters, Required: []string{"path", "line", "symbol"}},
+}
+
+var findReferencesDeclaration = &genai.FunctionDeclaration{
+	Name:        FindReferencesToolName,
+	Description: "Finds all references to a symbol, including its declaration, using the project's language server.",
+	Parameters:  &genai.Schema{Type: genai.TypeObject, Properties: symbolParameters, Required: []string{"path", "line", "symbol"}},
+}
+
+var renameSymbolDeclaration = &genai.FunctionDeclaration{
+	Name: RenameSymbolToolName,
+	Description: "Renames a symbol and all references to it across the project, using the project's language server. " +
+		"The user reviews the changes before any file is written.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: func() map[string]*genai.Schema {
+			props := map[string]*genai.Schema{"new_name": {Type: genai.TypeString, Description: "The new name."}}
+			for k, v := range symbolParameters {
+				props[k] = v
+			}
+			return props
+		}(),
+		Required: []string{"path", "line", "symbol", "new_name"},
+	},
+}
+
+func findDefinition(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	client, path, pos, err := ws.symbolAt(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	locs, err := client.Definition(ctx, path, pos)
+	if err != nil {
+		return nil, err
+	}
+	if len(locs) == 0 {
+		return map[string]any{"message": "The language server found no definition."}, nil
+	}
+	return map[string]any{"definitions": ws.describeLocations(locs)}, nil
+}
+
+func findReferences(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	client, path, pos, err := ws.symbolAt(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	locs, err := client.References(ctx, path, pos)
+	if err != nil {
+		return nil, err
+	}
+	resp := map[string]any{"count": len(locs)}
+	if len(locs) > maxLocations {
+		resp["message"] = fmt.Sprintf("Only the first %d references are listed.", maxLocations)
+		locs = locs[:maxLocations]
+	}
+	resp["references"] = ws.describeLocations(locs)
+	return resp, nil
+}
+
+func renameSymbol(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	newName, err := stringArg(args, "new_name")
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(newName) == "" {
+		return nil, errors.New("new_name must not be empty")
+	}
+	client, path, pos, err := ws.symbolAt(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	if c, _ := ws.stagedFile(path, ws.tx.clone()); c != nil && c.new != c.old {
+		return nil, fmt.Errorf("%s has changes pending in this turn; rename before changing the file otherwise", c.rel)
+	}
+	edit, err := client.Rename(ctx, path, pos, newName)
+	if err != nil {
+		return nil, err
+	}
+	files, err := ws.stageWorkspaceEdit(edit)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"applied": true, "files": files}, nil
+}
+
+// stageWorkspaceEdit stages the edits of a language server in the turn's
+// transaction, all or none of them.
+func (w *Workspace) stageWorkspaceEdit(edit *lsp.WorkspaceEdit) ([]string, error) {
+	edits, err := edit.FileEdits()
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(edits))
+	for path := range edits {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	staged := w.tx.clone()
+	var files []string
+	for _, path := range paths {
+		resolved, err := w.Resolve(path)
+		if err != nil {
+			return nil, err
+		}
+		c, err := w.stagedFile(resolved, staged)
+		if err != nil {
+			return nil, err
+		}
+		if c.new != c.old {
+			return nil, fmt.Errorf("%s has changes pending in this turn that the language server did not see", c.rel)
+		}
+		if c.new, err = lsp.ApplyEdits(c.new, edits[path]); err != nil {
+			return nil, fmt.Errorf("%s: %w", c.rel, err)
+		}
+		files = append(files, fmt.Sprintf("%s (%d edits)", c.rel, len(edits[path])))
+	}
+	w.tx.replace(staged)
+	return files, nil
+}
+
+// symbolAt resolves the path, line, symbol and column arguments to the
+// language server handling the file and the LSP position of the symbol.
+func (w *Workspace) symbolAt(ctx context.Context, args map[string]any) (*lsp.Client, string, lsp.Position, error) {
+	var pos lsp.Position
+	if w.LSP == nil {
+		return nil, "", pos, errors.New("code intelligence is not available")
+	}
+	name, err := stringArg(args, "path")
+	if err != nil {
+		return nil, "", pos, err
+	}
+	symbol, err := stringArg(args, "symbol")
+	if err != nil {
+		return nil, "", pos, err
+	}
+	line, err := intArg(args, "line", 0)
+	if err != nil {
+		return nil, "", pos, err
+	}
+	column, err := intArg(args, "column", 0)
+	if err != nil {
+		return nil, "", pos, err
+	}
+	path, err := w.Resolve(name)
+	if err != nil {
+		return nil, "", pos, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", pos, err
+	}
+	if pos, err = symbolPosition(string(data), line, symbol, column); err != nil {
+		return nil, "", pos, fmt.Errorf("%s: %w", name, err)
+	}
+	client, err := w.LSP.ClientFor(ctx, path)
+	if err != nil {
+		return nil, "", pos, err
+	}
+	return client, path, pos, nil
+}
+
+// symbolPosition returns the position of symbol on a 1-based line of
+// content, or of the 1-based column if given.
+func symbolPosition(content string, line int, symbol string, column int) (lsp.Position, error) {
+	lines := strings.Split(content, "\n")
+	if line < 1 || line > len(lines) {
+		return lsp.Position{}, fmt.Errorf("line %d is out of range; the file has %d lines", line, len(lines))
+	}
+	text := strings.TrimSuffix(lines[line-1], "\r")
+
+	at := -1
+	switch {
+	case column > 0:
+		at = 0
+		for i := 1; i < column && at < len(text); i++ {
+			_, size := utf8.DecodeRuneInString(text[at:])
+			at += size
+		}
+	case symbol != "":
+		if loc := regexp.MustCompile(`\b` + regexp.QuoteMeta(symbol) + `\b`).FindStringIndex(text); loc != nil {
+			at = loc[0]
+		} else {
+			at = strings.Index(text, symbol)
+		}
+		if at < 0 {
+			return lsp.Position{}, fmt.Errorf("%q is not on line %d, which reads: %s", symbol, line, strings.TrimSpace(text))
+		}
+	default:
+		return lsp.Position{}, errors.New("symbol or column is required")
+	}
+	return lsp.Position{Line: line - 1, Character: lsp.Column(text, at)}, nil
+}
+
+// describeLocations formats locations as path:line:column followed by the
+// line's text, with paths relative to the workspace root where possible.
+func (w *Workspace) describeLocations(locs []lsp.Location) []string {
+	files := map[string][]string{}
+	var out []string
+	for _, l := range locs {
+		path, err := lsp.URIToPath(l.URI)
+		if err != nil {
+			out = append(out, l.URI)
+			continue
+		}
+		lines, ok := files[path]
+		if !ok {
+			data, _ := os.ReadFile(path)
+			lines = strings.Split(string(data), "\n")
+			files[path] = lines
+		}
+		display := path
+		if rel, err := filepath.Rel(w.Roots[0], path); err == nil && within(w.Roots[0], path) {
+			display = rel
+		}
+		desc := fmt.Sprintf("%s:%d:%d", display, l.Range.Start.Line+1, l.Range.Start.Character+1)
+		if n := l.Range.Start.Line; n < len(lines) {
+			desc += ": " + truncateLine(strings.TrimSpace(lines[n]))
+		}
+		out = append(out, desc)
+	}
+	return out
+}
@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/lsp"
+)
+
+func TestSymbolPosition(t *testing.T) {
+	content := "package main\n\nfunc (s *server) serve(ctx) { s.serve2() }\n// 😀 serve\n"
+	tests := []struct {
+		line   int
+		symbol string
+		column int
+		want   lsp.Position
+	}{
+		// The whole word is preferred over an earlier partial match.
+		{3, "serve", 0, lsp.Position{Line: 2, Character: 17}},
+		{3, "serve2", 0, lsp.Position{Line: 2, Character: 32}},
+		// Without a whole-word match, the first occurrence is used.
+		{3, "erve", 0, lsp.Position{Line: 2, Character: 10}},
+		{3, "", 7, lsp.Position{Line: 2, Character: 6}},
+		// Columns are in UTF-16 units.
+		{4, "serve", 0, lsp.Position{Line: 3, Character: 6}},
+	}
+	for _, tt := range tests {
+		got, err := symbolPosition(content, tt.line, tt.symbol, tt.column)
+		if err != nil || got != tt.want {
+			t.Errorf("symbolPosition(%d, %q, %d) = %+v, %v; want %+v", tt.line, tt.symbol, tt.column, got, err, tt.want)
+		}
+	}
+	for _, line := range []int{0, 99} {
+		if _, err := symbolPosition(content, line, "serve", 0); err == nil {
+			t.Errorf("symbolPosition(line %d) succeeded", line)
+		}
+	}
+	if _, err := symbolPosition(content, 1, "serve", 0); err == nil {
+		t.Error("symbolPosition found a symbol missing from the line")
+	}
+}
+
+func TestStageWorkspaceEdit(t *testing.T) {
+	ws := testWorkspace(t, nil)
+	root := ws.Roots[0]
+	a := filepath.Join(root, "a.go")
+	b := filepath.Join(root, "sub", "b.go")
+	os.WriteFile(a, []byte("func foo() {}\n"), 0644)
+	os.WriteFile(b, []byte("x := foo()\n"), 0644)
+
+	at := func(line, start, end int) lsp.Range {
+		return lsp.Range{Start: lsp.Position{Line: line, Character: start}, End: lsp.Position{Line: line, Character: end}}
+	}
+	edit := &lsp.WorkspaceEdit{Changes: map[string][]lsp.TextEdit{
+		lsp.PathToURI(a): {{Range: at(0, 5, 8), NewText: "bar"}},
+		lsp.PathToURI(b): {{Range: at(0, 5, 8), NewText: "bar"}},
+	}}
+
+	ws.tx = &Transaction{}
+	files, err := ws.stageWorkspaceEdit(edit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("files = %v", files)
+	}
+	if data, _ := os.ReadFile(a); string(data) != "func foo() {}\n" {
+		t.Errorf("a.go was written before the commit: %q", data)
+	}
+	// The edit is staged, so a second rename of the same files is refused.
+	if _, err := ws.stageWorkspaceEdit(edit); err == nil {
+		t.Error("Expected an edit on top of staged changes to be refused")
+	}
+
+	ws.Confirm = func(ctx context.Context, title, details string) (bool, error) { return true, nil }
+	if err := ws.tx.Commit(context.Background(), ws, "rename"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(a); string(data) != "func bar() {}\n" {
+		t.Errorf("a.go = %q", data)
+	}
+	if data, _ := os.ReadFile(b); string(data) != "x := bar()\n" {
+		t.Errorf("b.go = %q", data)
+	}
+}
+
+func TestStageWorkspaceEditOutsideWorkspace(t *testing.T) {
+	ws := testWorkspace(t, nil)
+	outside := filepath.Join(t.TempDir(), "c.go")
+	os.WriteFile(outside, []byte("foo\n"), 0644)
+	ws.tx = &Transaction{}
+	edit := &lsp.WorkspaceEdit{Changes: map[string][]lsp.TextEdit{
+		lsp.PathToURI(outside): {{NewText: "bar"}},
+	}}
+	if _, err := ws.stageWorkspaceEdit(edit); err == nil {
+		t.Error("Expected an edit outside the workspace to be refused")
+	}
+	if ws.tx.Len() != 0 {
+		t.Error("Expected nothing to be staged")
+	}
+}
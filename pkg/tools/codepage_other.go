@@ -0,0 +1,8 @@
+//go:build !windows
+
+package tools
+
+// systemCodePage returns 0 outside Windows, where there are no code pages.
+func systemCodePage() int {
+	return 0
+}
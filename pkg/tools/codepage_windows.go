@@ -0,0 +1,14 @@
+//go:build windows
+
+package tools
+
+import "golang.org/x/sys/windows"
+
+// systemCodePage returns the code page console programs write output in,
+// falling back to the ANSI code page when there is no console.
+func systemCodePage() int {
+	if cp, err := windows.GetConsoleOutputCP(); err == nil && cp != 0 {
+		return int(cp)
+	}
+	return int(windows.GetACP())
+}
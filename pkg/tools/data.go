@@ -0,0 +1,312 @@
+package tools
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/sqlite"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// PreviewDataToolName is the name of the tool previewing data files.
+const PreviewDataToolName = "preview_data"
+
+const (
+	defaultPreviewRows = 10
+	maxPreviewRows     = 100
+	// maxCellLength is the length at which preview_data truncates values.
+	maxCellLength = 200
+)
+
+var previewDataDeclaration = &genai.FunctionDeclaration{
+	Name: PreviewDataToolName,
+	Description: "Shows the structure and first rows of a data file in the workspace: the columns, inferred column types and row count " +
+		"of a CSV or TSV file, or the tables, views and indexes of a SQLite database with sample rows of a table.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"path": {
+				Type:        genai.TypeString,
+				Description: "The CSV (.csv), TSV (.tsv, .tab) or SQLite file, relative to the workspace root or absolute.",
+			},
+			"table": {
+				Type:        genai.TypeString,
+				Description: "For SQLite files, the table to show sample rows of. Without it, the schema is listed.",
+			},
+			"limit": {
+				Type:        genai.TypeInteger,
+				Description: fmt.Sprintf("The number of rows to show, at most %d. Defaults to %d.", maxPreviewRows, defaultPreviewRows),
+			},
+		},
+		Required: []string{"path"},
+	},
+}
+
+func previewData(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	name, err := stringArg(args, "path")
+	if err != nil {
+		return nil, err
+	}
+	table, err := stringArg(args, "table")
+	if err != nil {
+		return nil, err
+	}
+	limit, err := intArg(args, "limit", defaultPreviewRows)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxPreviewRows {
+		return nil, fmt.Errorf("limit must be between 1 and %d", maxPreviewRows)
+	}
+	if name == "" {
+		return nil, errors.New("path must not be empty")
+	}
+	path, err := ws.Resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(path); err != nil {
+		return nil, err
+	} else if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", name)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	head := make([]byte, 100)
+	n, _ := io.ReadFull(f, head)
+	if sqlite.IsDatabase(head[:n]) {
+		return previewSQLite(path, table, limit)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return previewCSV(f, ',', limit)
+	case ".tsv", ".tab":
+		return previewCSV(f, '\t', limit)
+	}
+	return nil, fmt.Errorf("%s is not a CSV, TSV or SQLite file", name)
+}
+
+func previewCSV(r io.Reader, comma rune, limit int) (map[string]any, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = comma
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return map[string]any{"format": formatName(comma), "rowCount": 0}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp := map[string]any{"format": formatName(comma), "columns": truncateCells(header)}
+
+	var rows [][]string
+	types := make([]string, len(header))
+	count := 0
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			resp["warning"] = fmt.Sprintf("Stopped counting rows at a parse error: %v", err)
+			break
+		}
+		count++
+		if len(rows) < limit {
+			rows = append(rows, truncateCells(record))
+		}
+		if count <= 1000 {
+			for i, v := range record[:min(len(record), len(types))] {
+				types[i] = widenType(types[i], v)
+			}
+		}
+	}
+	for i, t := range types {
+		if t == "" {
+			types[i] = "empty"
+		}
+	}
+	resp["columnTypes"] = types
+	resp["rows"] = rows
+	resp["rowCount"] = count
+	return resp, nil
+}
+
+func formatName(comma rune) string {
+	if comma == '\t' {
+		return "tsv"
+	}
+	return "csv"
+}
+
+func truncateCells(record []string) []string {
+	out := make([]string, len(record))
+	for i, v := range record {
+		out[i] = truncateCell(v)
+	}
+	return out
+}
+
+func truncateCell(v string) string {
+	if len(v) > maxCellLength {
+		return v[:maxCellLength] + "..."
+	}
+	return v
+}
+
+var (
+	integerPattern = regexp.MustCompile(`^[-+]?\d+$`)
+	numberPattern  = regexp.MustCompile(`^[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?$`)
+	booleanPattern = regexp.MustCompile(`(?i)^(true|false)$`)
+	datePattern    = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}([T ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?(Z|[-+]\d{2}:?\d{2})?)?$`)
+)
+
+// widenType returns the narrowest of integer, number, boolean, date and
+// text that fits both the type of the values seen so far and v. Empty
+// values fit any type.
+func widenType(seen, v string) string {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return seen
+	}
+	var t string
+	switch {
+	case integerPattern.MatchString(v):
+		t = "integer"
+	case numberPattern.MatchString(v):
+		t = "number"
+	case booleanPattern.MatchString(v):
+		t = "boolean"
+	case datePattern.MatchString(v):
+		t = "date"
+	default:
+		t = "text"
+	}
+	switch {
+	case seen == "" || seen == t:
+		return t
+	case seen == "integer" && t == "number", seen == "number" && t == "integer":
+		return "number"
+	}
+	return "text"
+}
+
+func previewSQLite(path, table string, limit int) (map[string]any, error) {
+	db, err := sqlite.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	schema, err := db.Schema()
+	if err != nil {
+		return nil, fmt.Errorf("reading the schema: %w", err)
+	}
+
+	resp := map[string]any{"format": "sqlite"}
+	if info, err := os.Stat(path + "-wal"); err == nil && info.Size() > 0 {
+		resp["warning"] = "The database has a write-ahead log; changes not yet checkpointed into the file are not shown."
+	}
+	var tables []sqlite.Object
+	var views, indexes []string
+	for _, o := range schema {
+		switch {
+		case o.Type == "table" && !strings.HasPrefix(o.Name, "sqlite_"):
+			tables = append(tables, o)
+		case o.Type == "view":
+			views = append(views, o.SQL)
+		case o.Type == "index" && o.SQL != "":
+			indexes = append(indexes, o.SQL)
+		}
+	}
+	if table == "" && len(tables) == 1 {
+		table = tables[0].Name
+	}
+
+	var summaries []map[string]any
+	for _, o := range tables {
+		columns, _ := sqlite.Columns(o.SQL)
+		s := map[string]any{"name": o.Name, "columns": columns, "sql": o.SQL}
+		if n, err := db.Count(o.RootPage); err != nil {
+			s["rowCount"] = "unknown: " + err.Error()
+		} else {
+			s["rowCount"] = n
+		}
+		if strings.EqualFold(o.Name, table) {
+			rows, err := sampleRows(db, o, limit)
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %w", o.Name, err)
+			}
+			s["rows"] = rows
+			table = o.Name
+		}
+		summaries = append(summaries, s)
+	}
+	resp["tables"] = summaries
+	resp["views"] = views
+	resp["indexes"] = indexes
+	switch {
+	case table != "" && !hasTable(tables, table):
+		return nil, fmt.Errorf("no table %q; the tables are: %s", table, tableNames(tables))
+	case table == "" && len(tables) > 1:
+		resp["message"] = "Pass `table` to see sample rows of a table."
+	}
+	return resp, nil
+}
+
+// sampleRows returns the first rows of a table, with rowid aliases filled
+// in and blobs described rather than returned.
+func sampleRows(db *sqlite.DB, o sqlite.Object, limit int) ([][]any, error) {
+	_, rowidColumn := sqlite.Columns(o.SQL)
+	var rows [][]any
+	err := db.Scan(o.RootPage, func(rowid int64, values []any) bool {
+		for i, v := range values {
+			switch v := v.(type) {
+			case string:
+				values[i] = truncateCell(v)
+			case []byte:
+				values[i] = fmt.Sprintf("<blob of %d bytes>", len(v))
+			case nil:
+				if i == rowidColumn {
+					values[i] = rowid
+				}
+			}
+		}
+		rows = append(rows, values)
+		return len(rows) < limit
+	})
+	return rows, err
+}
+
+func hasTable(tables []sqlite.Object, name string) bool {
+	for _, o := range tables {
+		if o.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func tableNames(tables []sqlite.Object) string {
+	var names []string
+	for _, o := range tables {
+		names = append(names, o.Name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,68 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPreviewCSV(t *testing.T) {
+	ws := testWorkspace(t, nil)
+	root := ws.Roots[0]
+	os.WriteFile(filepath.Join(root, "a.csv"), []byte("id,price,ok,when,name\n1,2,true,2024-01-02,\"a, b\"\n2,2.5,false,2024-01-03,c\n3,,TRUE,2024-01-04T10:00:00Z,4\n"), 0644)
+	os.WriteFile(filepath.Join(root, "a.tsv"), []byte("x\ty\n1\t2\n"), 0644)
+
+	resp := runTool(t, ws, PreviewDataToolName, map[string]any{"path": "a.csv", "limit": float64(2)})
+	if types := stringList(resp["columnTypes"]); !slices.Equal(types, []string{"integer", "number", "boolean", "date", "text"}) {
+		t.Errorf("columnTypes = %q", types)
+	}
+	rows, _ := resp["rows"].([]any)
+	if resp["rowCount"] != 3 || len(rows) != 2 || !slices.Equal(stringList(rows[0]), []string{"1", "2", "true", "2024-01-02", "a, b"}) {
+		t.Errorf("Expected 2 of 3 rows, got %v", resp)
+	}
+	resp = runTool(t, ws, PreviewDataToolName, map[string]any{"path": "a.tsv"})
+	if resp["format"] != "tsv" || !slices.Equal(stringList(resp["columns"]), []string{"x", "y"}) {
+		t.Errorf("tsv: got %v", resp)
+	}
+}
+
+func TestPreviewSQLite(t *testing.T) {
+	ws := testWorkspace(t, nil)
+	data, err := os.ReadFile("../sqlite/testdata/sample.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(ws.Roots[0], "app.db"), data, 0644)
+
+	resp := runTool(t, ws, PreviewDataToolName, map[string]any{"path": "app.db"})
+	tables, _ := resp["tables"].([]any)
+	if len(tables) != 2 || resp["message"] == nil || len(stringList(resp["views"])) != 1 {
+		t.Fatalf("Expected the schema without rows, got %v", resp)
+	}
+	users := tables[0].(map[string]any)
+	if users["name"] != "user list" || users["rowCount"] != 300 || users["rows"] != nil {
+		t.Errorf("user list = %v", users)
+	}
+
+	resp = runTool(t, ws, PreviewDataToolName, map[string]any{"path": "app.db", "table": "USER LIST", "limit": float64(4)})
+	users = resp["tables"].([]any)[0].(map[string]any)
+	rows, _ := users["rows"].([]any)
+	if len(rows) != 4 {
+		t.Fatalf("Expected 4 rows, got %v", users)
+	}
+	first, second, fourth := rows[0].([]any), rows[1].([]any), rows[3].([]any)
+	if first[0] != int64(1) || first[1] != "user 1" || !strings.HasSuffix(second[1].(string), "...") || fourth[3] != "<blob of 3 bytes>" {
+		t.Errorf("rows = %v", rows)
+	}
+
+	resp = runTool(t, ws, PreviewDataToolName, map[string]any{"path": "app.db", "table": "kv"})
+	if msg, _ := resp["error"].(string); !strings.Contains(msg, "WITHOUT ROWID") {
+		t.Errorf("Expected WITHOUT ROWID tables to be reported as unsupported, got %v", resp)
+	}
+	resp = runTool(t, ws, PreviewDataToolName, map[string]any{"path": "app.db", "table": "missing"})
+	if msg, _ := resp["error"].(string); !strings.Contains(msg, "user list, kv") {
+		t.Errorf("Expected the tables to be listed, got %v", resp)
+	}
+}
@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"bytes"
+	"regexp"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// ansiPattern matches terminal escape sequences: CSI sequences such as
+// colors and cursor movement, OSC sequences such as hyperlinks and window
+// titles, and two-byte escapes.
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// stripANSI removes terminal escape sequences from s.
+func stripANSI(s string) string {
+	return ansiPattern.ReplaceAllString(s, "")
+}
+
+// codePages maps Windows code pages to their encodings.
+var codePages = map[int]encoding.Encoding{
+	437:  charmap.CodePage437,
+	850:  charmap.CodePage850,
+	852:  charmap.CodePage852,
+	855:  charmap.CodePage855,
+	858:  charmap.CodePage858,
+	860:  charmap.CodePage860,
+	862:  charmap.CodePage862,
+	863:  charmap.CodePage863,
+	865:  charmap.CodePage865,
+	866:  charmap.CodePage866,
+	874:  charmap.Windows874,
+	932:  japanese.ShiftJIS,
+	936:  simplifiedchinese.GBK,
+	949:  korean.EUCKR,
+	950:  traditionalchinese.Big5,
+	1250: charmap.Windows1250,
+	1251: charmap.Windows1251,
+	1252: charmap.Windows1252,
+	1253: charmap.Windows1253,
+	1254: charmap.Windows1254,
+	1255: charmap.Windows1255,
+	1256: charmap.Windows1256,
+	1257: charmap.Windows1257,
+	1258: charmap.Windows1258,
+}
+
+// decodeOutput converts command output to UTF-8. Output that is already
+// valid UTF-8 is returned unchanged. Otherwise it is decoded with the
+// configured encoding if any, then by its byte order mark, then with the
+// system code page on Windows, and finally as Windows-1252, which like
+// Latin-1 maps every byte to a character and so never fails.
+func decodeOutput(out []byte, configured string) string {
+	if configured != "" {
+		if enc, err := htmlindex.Get(configured); err == nil {
+			if s, err := enc.NewDecoder().Bytes(out); err == nil {
+				return string(s)
+			}
+		}
+	}
+	if bytes.HasPrefix(out, []byte{0xff, 0xfe}) || bytes.HasPrefix(out, []byte{0xfe, 0xff}) {
+		// Windows tools such as some PowerShell cmdlets write UTF-16.
+		dec := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewDecoder()
+		if s, err := dec.Bytes(out); err == nil {
+			return string(s)
+		}
+	}
+	if utf8.Valid(out) {
+		return string(out)
+	}
+	if enc := codePages[systemCodePage()]; enc != nil {
+		if s, err := enc.NewDecoder().Bytes(out); err == nil {
+			return string(s)
+		}
+	}
+	s, _ := charmap.Windows1252.NewDecoder().Bytes(out)
+	return string(s)
+}
@@ -0,0 +1,37 @@
+package tools
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestDecodeOutput(t *testing.T) {
+	tests := []struct {
+		name       string
+		in         []byte
+		configured string
+		want       string
+	}{
+		{"utf-8", []byte("héllo ✓"), "", "héllo ✓"},
+		{"latin-1 fallback", []byte("caf\xe9 na\xefve"), "", "café naïve"},
+		{"utf-16 with BOM", []byte{0xff, 0xfe, 'o', 0, 'k', 0}, "", "ok"},
+		{"configured", []byte("\x8f\xe0\xa8\xa2\xa5\xe2"), "ibm866", "Привет"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.name == "latin-1 fallback" && runtime.GOOS == "windows" {
+				t.Skip("decoded with the system code page on Windows")
+			}
+			if got := decodeOutput(tt.in, tt.configured); got != tt.want {
+				t.Errorf("decodeOutput() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripANSI(t *testing.T) {
+	in := "\x1b[1;31merror\x1b[0m: \x1b]8;;http://example.com\x07link\x1b]8;;\x07 done\x1b[K\x1bM"
+	if got, want := stripANSI(in), "error: link done"; got != want {
+		t.Errorf("stripANSI() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,256 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// ReadFileToolName is the name of the tool reading text files.
+const ReadFileToolName = "read_file"
+
+// ListDirectoryToolName is the name of the tool listing directories.
+const ListDirectoryToolName = "list_directory"
+
+const (
+	// defaultMaxFileSize is the size of the largest file read_file reads,
+	// unless tools.maxFileSize is set.
+	defaultMaxFileSize = 20 << 20
+	// defaultReadLimit is the number of lines read_file returns when no
+	// limit is given.
+	defaultReadLimit = 2000
+	// maxLineLength is the length at which read_file truncates lines.
+	maxLineLength = 2000
+)
+
+var readFileDeclaration = &genai.FunctionDeclaration{
+	Name: ReadFileToolName,
+	Description: "Reads a text file in the workspace. Long files are returned in pages of lines; " +
+		"use `offset` and `limit` to read further. Files over the size limit return their first lines and an outline of their " +
+		"functions or headings with line numbers instead, to be read in ranges. Binary files, directories and special files such as sockets are refused.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"path": {
+				Type:        genai.TypeString,
+				Description: "The file to read, relative to the workspace root or absolute.",
+			},
+			"offset": {
+				Type:        genai.TypeInteger,
+				Description: "The 0-based line to start reading at.",
+			},
+			"limit": {
+				Type:        genai.TypeInteger,
+				Description: fmt.Sprintf("The maximum number of lines to read. Defaults to %d, or %d for files over the size limit.", defaultReadLimit, largeFilePreviewLines),
+			},
+		},
+		Required: []string{"path"},
+	},
+}
+
+var listDirectoryDeclaration = &genai.FunctionDeclaration{
+	Name: ListDirectoryToolName,
+	Description: "Lists a directory in the workspace. Directories end in /, and symbolic links are shown as `name -> target`; " +
+		"links pointing outside the workspace are marked and cannot be read. Sockets, devices and pipes are left out.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"path": {
+				Type:        genai.TypeString,
+				Description: "The directory to list, relative to the workspace root or absolute. Defaults to the root.",
+			},
+		},
+	},
+}
+
+func readFile(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	name, err := stringArg(args, "path")
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, errors.New("path must not be empty")
+	}
+	offset, err := intArg(args, "offset", 0)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := intArg(args, "limit", 0)
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 || limit < 0 {
+		return nil, errors.New("offset and limit must not be negative")
+	}
+
+	path, err := ws.Resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case info.IsDir():
+		return nil, fmt.Errorf("%s is a directory; use %s to list it", name, ListDirectoryToolName)
+	case !info.Mode().IsRegular():
+		return nil, fmt.Errorf("%s is a %s, not a regular file, and cannot be read", name, fileKind(info.Mode()))
+	case info.Size() > ws.maxFileSize():
+		return ws.readLargeFile(name, path, info.Size(), offset, limit)
+	}
+	if limit == 0 {
+		limit = defaultReadLimit
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isBinary(data) {
+		return nil, fmt.Errorf("%s appears to be a binary file (%s) and cannot be read as text; use %s to describe it",
+			name, formatSize(info.Size()), InspectFileToolName)
+	}
+	lines := strings.Split(strings.TrimSuffix(decodeOutput(data, ""), "\n"), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+	if offset > len(lines) {
+		return nil, fmt.Errorf("offset %d is past the end of %s, which has %d lines", offset, name, len(lines))
+	}
+
+	end := min(offset+limit, len(lines))
+	page := lines[offset:end]
+	shortened := false
+	for i, l := range page {
+		page[i] = truncateLine(l)
+		shortened = shortened || page[i] != l
+	}
+	resp := map[string]any{"content": strings.Join(page, "\n")}
+	if offset > 0 || end < len(lines) || shortened {
+		resp["message"] = fmt.Sprintf("Showing lines %d-%d of %d.", offset+1, end, len(lines))
+		if end < len(lines) {
+			resp["message"] = fmt.Sprintf("%s Use offset %d to read more.", resp["message"], end)
+		}
+	}
+	return resp, nil
+}
+
+func listDirectory(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	name, err := stringArg(args, "path")
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = "."
+	}
+	dir, err := ws.Resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs, files []string
+	skipped := 0
+	for _, e := range entries {
+		switch mode := e.Type(); {
+		case mode&fs.ModeSymlink != 0:
+			files = append(files, ws.describeLink(filepath.Join(dir, e.Name())))
+		case mode.IsDir():
+			dirs = append(dirs, e.Name()+"/")
+		case mode.IsRegular():
+			files = append(files, e.Name())
+		default:
+			skipped++
+		}
+	}
+	sort.Strings(dirs)
+	sort.Strings(files)
+	resp := map[string]any{"entries": append(dirs, files...)}
+	if skipped > 0 {
+		resp["message"] = fmt.Sprintf("%d socket(s), device(s) or pipe(s) were left out.", skipped)
+	}
+	return resp, nil
+}
+
+// truncateLine shortens lines longer than maxLineLength.
+func truncateLine(line string) string {
+	if len(line) <= maxLineLength {
+		return line
+	}
+	return line[:maxLineLength] + "... [line truncated]"
+}
+
+// describeLink describes the symbolic link at path as "name -> target",
+// noting targets that are outside the workspace or missing.
+func (w *Workspace) describeLink(path string) string {
+	name := filepath.Base(path)
+	target, err := os.Readlink(path)
+	if err != nil {
+		return name + " -> ?"
+	}
+	desc := name + " -> " + target
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return desc + " (broken)"
+	}
+	if !w.contains(real) {
+		return desc + " (outside the workspace, not followed)"
+	}
+	if info, err := os.Stat(real); err == nil && info.IsDir() {
+		desc += "/"
+	}
+	return desc
+}
+
+func (w *Workspace) maxFileSize() int64 {
+	if t := w.Settings.Tools; t != nil && t.MaxFileSize > 0 {
+		return t.MaxFileSize
+	}
+	return defaultMaxFileSize
+}
+
+// fileKind names the type of a file that is not regular.
+func fileKind(mode fs.FileMode) string {
+	switch {
+	case mode&fs.ModeSocket != 0:
+		return "socket"
+	case mode&fs.ModeNamedPipe != 0:
+		return "named pipe"
+	case mode&fs.ModeCharDevice != 0:
+		return "character device"
+	case mode&fs.ModeDevice != 0:
+		return "device"
+	}
+	return "special file"
+}
+
+// isBinary reports whether data looks binary, i.e. has a NUL byte early on.
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0
+}
+
+// formatSize formats a number of bytes for people, e.g. "1.5 MB".
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d bytes", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
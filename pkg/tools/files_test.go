@@ -0,0 +1,139 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google/generative-ai-go/genai"
+)
+
+func runTool(t *testing.T, ws *Workspace, name string, args map[string]any) map[string]any {
+	t.Helper()
+	part := ExecuteToolCall(context.Background(), ws, &genai.FunctionCall{Name: name, Args: args})
+	return part.(*genai.FunctionResponse).Response
+}
+
+// stringList converts a list in a tool response back to strings.
+func stringList(v any) []string {
+	list, _ := v.([]any)
+	var out []string
+	for _, s := range list {
+		str, _ := s.(string)
+		out = append(out, str)
+	}
+	return out
+}
+
+func TestReadFile(t *testing.T) {
+	ws := testWorkspace(t, &config.Settings{Tools: &config.ToolsSettings{MaxFileSize: 100}})
+	root := ws.Roots[0]
+	os.WriteFile(filepath.Join(root, "a.txt"), []byte("one\ntwo\nthree\n"), 0644)
+	os.WriteFile(filepath.Join(root, "bin"), []byte("ELF\x00\x01"), 0644)
+
+	if resp := runTool(t, ws, ReadFileToolName, map[string]any{"path": "a.txt"}); resp["content"] != "one\ntwo\nthree" || resp["message"] != nil {
+		t.Errorf("Expected the whole file, got %v", resp)
+	}
+	resp := runTool(t, ws, ReadFileToolName, map[string]any{"path": "a.txt", "offset": float64(1), "limit": float64(1)})
+	if resp["content"] != "two" || !strings.Contains(resp["message"].(string), "Use offset 2") {
+		t.Errorf("Expected line 2 and how to continue, got %v", resp)
+	}
+	for name, want := range map[string]string{
+		"bin": "binary",
+		"sub": "is a directory",
+	} {
+		resp := runTool(t, ws, ReadFileToolName, map[string]any{"path": name})
+		if msg, _ := resp["error"].(string); !strings.Contains(msg, want) {
+			t.Errorf("read_file %s: expected an error containing %q, got %v", name, want, resp)
+		}
+	}
+}
+
+func TestReadLargeFile(t *testing.T) {
+	ws := testWorkspace(t, &config.Settings{Tools: &config.ToolsSettings{MaxFileSize: 100}})
+	root := ws.Roots[0]
+	var src strings.Builder
+	src.WriteString("package big\n")
+	for i := range 300 {
+		fmt.Fprintf(&src, "\nfunc f%d() {\n\treturn\n}\n", i)
+	}
+	os.WriteFile(filepath.Join(root, "big.go"), []byte(src.String()), 0644)
+	os.WriteFile(filepath.Join(root, "big.md"), []byte("# Title\n"+strings.Repeat("text\n", 50)+"## Usage\nmore\n"), 0644)
+
+	resp := runTool(t, ws, ReadFileToolName, map[string]any{"path": "big.go"})
+	lines := strings.Split(resp["content"].(string), "\n")
+	outline := stringList(resp["outline"])
+	if len(lines) != largeFilePreviewLines || resp["totalLines"] != 1201 {
+		t.Errorf("Expected the first %d of 1201 lines, got %d of %v", largeFilePreviewLines, len(lines), resp["totalLines"])
+	}
+	if len(outline) != 300 || outline[1] != "7: func f1() {" {
+		t.Errorf("Expected an outline of the functions, got %d entries starting %q", len(outline), outline[:min(2, len(outline))])
+	}
+	if msg := resp["message"].(string); !strings.Contains(msg, "offset=200 limit=200") {
+		t.Errorf("Expected instructions for reading on, got %q", msg)
+	}
+
+	resp = runTool(t, ws, ReadFileToolName, map[string]any{"path": "big.go", "offset": float64(6), "limit": float64(3)})
+	if resp["content"] != "func f1() {\n\treturn\n}" || resp["outline"] != nil {
+		t.Errorf("Expected lines 7-9 without an outline, got %v", resp)
+	}
+
+	resp = runTool(t, ws, ReadFileToolName, map[string]any{"path": "big.md"})
+	if outline := stringList(resp["outline"]); !slices.Equal(outline, []string{"1: # Title", "52: ## Usage"}) {
+		t.Errorf("Expected the headings as the outline, got %v", resp["outline"])
+	}
+}
+
+func TestSymlinksAndSpecialFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs symbolic links and Unix sockets")
+	}
+	ws := testWorkspace(t, nil)
+	root := ws.Roots[0]
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	os.WriteFile(outside, []byte("secret\n"), 0644)
+	os.WriteFile(filepath.Join(root, "a.txt"), []byte("a\n"), 0644)
+	os.Symlink(outside, filepath.Join(root, "escape"))
+	os.Symlink("a.txt", filepath.Join(root, "inside"))
+	os.Symlink("sub", filepath.Join(root, "subdir"))
+	os.Symlink("missing", filepath.Join(root, "broken"))
+	l, err := net.Listen("unix", filepath.Join(root, "sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	resp := runTool(t, ws, ReadFileToolName, map[string]any{"path": "escape"})
+	if msg, _ := resp["error"].(string); !strings.Contains(msg, "symbolic link") || strings.Contains(msg, "secret\n") {
+		t.Errorf("Expected the escaping link to be refused, got %v", resp)
+	}
+	if resp := runTool(t, ws, ReadFileToolName, map[string]any{"path": "inside"}); resp["content"] != "a" {
+		t.Errorf("Expected links within the workspace to be followed, got %v", resp)
+	}
+	if resp := runTool(t, ws, ReadFileToolName, map[string]any{"path": "sock"}); !strings.Contains(resp["error"].(string), "socket") {
+		t.Errorf("Expected the socket to be refused, got %v", resp)
+	}
+
+	resp = runTool(t, ws, ListDirectoryToolName, nil)
+	want := []string{
+		"sub/",
+		"a.txt",
+		"broken -> missing (broken)",
+		"escape -> " + outside + " (outside the workspace, not followed)",
+		"inside -> a.txt",
+		"subdir -> sub/",
+	}
+	if got := stringList(resp["entries"]); !slices.Equal(got, want) {
+		t.Errorf("entries = %q, want %q", got, want)
+	}
+	if msg, _ := resp["message"].(string); !strings.Contains(msg, "1 socket") {
+		t.Errorf("Expected the socket to be reported as left out, got %v", resp)
+	}
+}
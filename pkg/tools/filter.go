@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+// Enabled reports whether the tool named name may be used in w. If
+// w.Allowed or the tools.core setting list tools, only those are enabled,
+// and the tools listed by tools.exclude never are, so that restricted
+// environments can turn off the shell or the tools that write files.
+// Entries such as "run_shell_command(git)" enable the shell for the
+// commands they start; see checkCommand. The tools of MCP servers may also
+// be listed as server__tool.
+func (w *Workspace) Enabled(name string) bool {
+	if len(w.Allowed) > 0 && !w.listsName(w.Allowed, name) {
+		return false
+	}
+	if w.Settings == nil || w.Settings.Tools == nil {
+		return true
+	}
+	s := w.Settings.Tools
+	if len(s.Core) > 0 && !w.listsName(s.Core, name) {
+		return false
+	}
+	if slices.Contains(s.Exclude, name) {
+		return false
+	}
+	qualified := w.qualifiedToolName(name)
+	return qualified == "" || !slices.Contains(s.Exclude, qualified)
+}
+
+// checkCommand returns an error if --allowed-tools or tools.core enable
+// run_shell_command only for commands starting with some prefixes, and
+// command is not one of them.
+func (w *Workspace) checkCommand(command string) error {
+	for _, l := range []struct {
+		source  string
+		entries []string
+	}{{"--allowed-tools", w.Allowed}, {"tools.core", w.coreTools()}} {
+		prefixes := commandPrefixes(l.entries)
+		if len(prefixes) == 0 || slices.Contains(l.entries, ShellToolName) || matchesPrefix(l.entries, command) {
+			continue
+		}
+		var names []string
+		for _, p := range prefixes {
+			names = append(names, strconv.Quote(strings.Join(p, " ")))
+		}
+		return fmt.Errorf("%s only allows commands starting with %s, and each command of a chain must", l.source, strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// coreTools returns the tools.core setting.
+func (w *Workspace) coreTools() []string {
+	if w.Settings == nil || w.Settings.Tools == nil {
+		return nil
+	}
+	return w.Settings.Tools.Core
+}
+
+// CheckToolNames returns an error naming the first of names that is not a
+// built-in, browser, background-process or plugin tool, or the tool of one
+// of the MCP servers given as server__tool, so that a misspelled
+// --allowed-tools does not turn off every tool. Only run_shell_command may
+// be given a command prefix, as in "run_shell_command(git)".
+func CheckToolNames(names []string, servers map[string]config.MCPServer) error {
+	var plugins map[string]string
+	for _, entry := range names {
+		name, prefix, ok := parsePattern(entry)
+		if ok && name != ShellToolName {
+			return fmt.Errorf("%q: only %s takes a command prefix", entry, ShellToolName)
+		}
+		if ok && prefix == "" {
+			return fmt.Errorf("%q: the command prefix is empty", entry)
+		}
+		if builtinTool(name) {
+			continue
+		}
+		if server, tool, ok := strings.Cut(name, mcpNameSeparator); ok && tool != "" {
+			if _, ok := servers[server]; ok {
+				continue
+			}
+		}
+		if plugins == nil {
+			if dir, err := PluginsDir(); err == nil {
+				plugins, _ = pluginPaths(dir)
+			}
+		}
+		if _, ok := plugins[name]; ok {
+			continue
+		}
+		return fmt.Errorf("unknown tool %q", entry)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+func TestEnabled(t *testing.T) {
+	ws := testWorkspace(t, nil)
+	ws.Settings = &config.Settings{Tools: &config.ToolsSettings{Exclude: []string{ShellToolName}}}
+	ws.Allowed = []string{ShellToolName, ReadFileToolName, ListDirectoryToolName}
+
+	var names []string
+	for _, d := range ws.Declarations() {
+		names = append(names, d.Name)
+	}
+	if want := []string{ListDirectoryToolName, ReadFileToolName}; !slices.Equal(names, want) {
+		t.Errorf("declared %q, want %q", names, want)
+	}
+
+	if resp := runTool(t, ws, ShellToolName, map[string]any{"command": "true"}); !strings.Contains(resp["error"].(string), "disabled") {
+		t.Errorf("Expected the excluded shell to be refused, got %v", resp)
+	}
+	if resp := runTool(t, ws, WriteFileToolName, map[string]any{"path": "a.txt", "content": "a"}); !strings.Contains(resp["error"].(string), "disabled") {
+		t.Errorf("Expected a tool that is not allowed to be refused, got %v", resp)
+	}
+
+	ws.Allowed = nil
+	ws.Settings.Tools.Core = []string{WriteFileToolName}
+	if !ws.Enabled(WriteFileToolName) || ws.Enabled(ReadFileToolName) {
+		t.Error("tools.core should enable only the tools it lists")
+	}
+}
+
+func TestCheckToolNames(t *testing.T) {
+	if err := CheckToolNames([]string{ShellToolName, ReadFileToolName}, nil); err != nil {
+		t.Error(err)
+	}
+	// Without these, commands started in the background could not be
+	// followed with --allowed-tools.
+	if err := CheckToolNames([]string{ListBackgroundProcessesToolName, ReadBackgroundOutputToolName, StopBackgroundProcessToolName}, nil); err != nil {
+		t.Error(err)
+	}
+	if err := CheckToolNames([]string{"run_shell"}, nil); err == nil {
+		t.Error("CheckToolNames(run_shell): want an error")
+	}
+	if err := CheckToolNames([]string{"run_shell_command(git)", "run_shell_command(npm test)"}, nil); err != nil {
+		t.Error(err)
+	}
+	for _, name := range []string{"read_file(a.txt)", "run_shell_command()"} {
+		if err := CheckToolNames([]string{name}, nil); err == nil {
+			t.Errorf("CheckToolNames(%s): want an error", name)
+		}
+	}
+}
+
+func TestCommandPrefixes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	ws := testWorkspace(t, nil)
+	ws.Allowed = []string{"run_shell_command(echo)", ReadFileToolName}
+	ws.Confirm = nil
+
+	if !ws.Enabled(ShellToolName) || ws.Enabled(WriteFileToolName) {
+		t.Error("A command prefix should enable the shell tool alone")
+	}
+	// The commands with the prefix run without a way to ask.
+	if resp := runTool(t, ws, ShellToolName, map[string]any{"command": "echo hi && echo there"}); resp["output"] != "hi\nthere\n" {
+		t.Errorf("Expected the allowed commands to run, got %v", resp)
+	}
+	resp := runTool(t, ws, ShellToolName, map[string]any{"command": "echo hi; touch x"})
+	if err, _ := resp["error"].(string); !strings.Contains(err, `--allowed-tools only allows commands starting with "echo"`) {
+		t.Errorf("Expected the other command to be refused, got %v", resp)
+	}
+
+	ws.Allowed = nil
+	ws.Settings = &config.Settings{Tools: &config.ToolsSettings{Core: []string{"run_shell_command(ls)"}}}
+	resp = runTool(t, ws, ShellToolName, map[string]any{"command": "echo hi"})
+	if err, _ := resp["error"].(string); !strings.Contains(err, "tools.core only allows") {
+		t.Errorf("Expected tools.core to refuse the command, got %v", resp)
+	}
+}
@@ -0,0 +1,246 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// HTTPRequestToolName is the name of the tool sending HTTP requests.
+const HTTPRequestToolName = "http_request"
+
+const (
+	// defaultMaxResponseBytes caps response bodies unless
+	// tools.http.maxResponseBytes is set.
+	defaultMaxResponseBytes = 100 << 10
+	httpTimeout             = 30 * time.Second
+	maxRedirects            = 10
+)
+
+var httpRequestDeclaration = &genai.FunctionDeclaration{
+	Name: HTTPRequestToolName,
+	Description: "Sends an HTTP request, e.g. to a local development server or an internal API, and returns the status, " +
+		"headers and body of the response. The user approves each host unless it is in tools.http.allowedHosts. " +
+		"Long bodies are truncated.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"method": {
+				Type:        genai.TypeString,
+				Description: "The HTTP method. Defaults to GET.",
+			},
+			"url": {
+				Type:        genai.TypeString,
+				Description: "The http or https URL to request.",
+			},
+			"headers": {
+				Type:        genai.TypeArray,
+				Description: "Request headers, as Name: value.",
+				Items:       &genai.Schema{Type: genai.TypeString},
+			},
+			"body": {
+				Type:        genai.TypeString,
+				Description: "The request body.",
+			},
+		},
+		Required: []string{"url"},
+	},
+}
+
+func httpRequest(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	rawURL, err := stringArg(args, "url")
+	if err != nil {
+		return nil, err
+	}
+	method, err := stringArg(args, "method")
+	if err != nil {
+		return nil, err
+	}
+	body, err := stringArg(args, "body")
+	if err != nil {
+		return nil, err
+	}
+	if method == "" {
+		method = http.MethodGet
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid URL %q: an absolute http or https URL is required", rawURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), u.String(), strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if err := headersArg(args["headers"], req.Header); err != nil {
+		return nil, err
+	}
+	if err := ws.approveHost(ctx, req.URL, req.Method); err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{
+		Timeout: httpTimeout,
+		CheckRedirect: func(next *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return ws.approveHost(next.Context(), next.URL, next.Method)
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	limit := ws.maxResponseBytes()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(limit)+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading the response: %w", err)
+	}
+	truncated := len(data) > limit
+	data = data[:min(len(data), limit)]
+
+	var headers []string
+	for name, values := range resp.Header {
+		for _, v := range values {
+			headers = append(headers, name+": "+v)
+		}
+	}
+	slices.Sort(headers)
+	result := map[string]any{
+		"status":  resp.Status,
+		"url":     resp.Request.URL.String(),
+		"headers": headers,
+	}
+	if isTextBody(resp.Header.Get("Content-Type"), data) {
+		if truncated {
+			data = trimPartialRune(data)
+		}
+		result["body"] = string(data)
+	} else {
+		result["body"] = fmt.Sprintf("<%s binary body>", formatSize(int64(len(data))))
+	}
+	if truncated {
+		result["truncated"] = fmt.Sprintf("The body was cut off after %s (tools.http.maxResponseBytes).", formatSize(int64(limit)))
+	}
+	return result, nil
+}
+
+// headersArg adds the "Name: value" headers of a function call argument to h.
+func headersArg(v any, h http.Header) error {
+	if v == nil {
+		return nil
+	}
+	list, ok := v.([]any)
+	if !ok {
+		return errors.New(`argument "headers" must be a list of "Name: value" strings`)
+	}
+	for _, item := range list {
+		s, _ := item.(string)
+		name, value, ok := strings.Cut(s, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return fmt.Errorf(`invalid header %q: expected "Name: value"`, s)
+		}
+		h.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return nil
+}
+
+// approveHost checks that requests may go to the host of u: it must be in
+// tools.http.allowedHosts or approved by the user, once per session.
+func (w *Workspace) approveHost(ctx context.Context, u *url.URL, method string) error {
+	host := strings.ToLower(u.Host)
+	if w.hostAllowed(u) || w.approvedHosts[host] {
+		return nil
+	}
+	if w.Confirm == nil && !w.autoApproved(networkAction) {
+		return fmt.Errorf("%s is not in tools.http.allowedHosts, and there is no way to ask the user to approve it in this mode", u.Host)
+	}
+	ok, err := w.confirm(ctx, networkAction, "", fmt.Sprintf("Allow HTTP requests to %s for this session?", u.Host), method+" "+u.String())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("the user did not approve requests to %s", u.Host)
+	}
+	if w.approvedHosts == nil {
+		w.approvedHosts = map[string]bool{}
+	}
+	w.approvedHosts[host] = true
+	return nil
+}
+
+// hostAllowed reports whether u matches tools.http.allowedHosts. Patterns
+// are matched with path.Match against the host name, and against the port
+// too if they have one.
+func (w *Workspace) hostAllowed(u *url.URL) bool {
+	t := w.Settings.Tools
+	if t == nil || t.HTTP == nil {
+		return false
+	}
+	hostname, port := strings.ToLower(u.Hostname()), u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	for _, pattern := range t.HTTP.AllowedHosts {
+		pattern = strings.ToLower(pattern)
+		patternHost, patternPort, err := net.SplitHostPort(pattern)
+		if err != nil {
+			patternHost, patternPort = strings.Trim(pattern, "[]"), ""
+		}
+		if ok, _ := path.Match(patternHost, hostname); ok && (patternPort == "" || patternPort == port) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Workspace) maxResponseBytes() int {
+	if t := w.Settings.Tools; t != nil && t.HTTP != nil && t.HTTP.MaxResponseBytes > 0 {
+		return t.HTTP.MaxResponseBytes
+	}
+	return defaultMaxResponseBytes
+}
+
+// isTextBody reports whether a response body is text, by its content type
+// or, failing that, its content.
+func isTextBody(contentType string, data []byte) bool {
+	ct := strings.ToLower(contentType)
+	for _, text := range []string{"text/", "json", "xml", "javascript", "x-www-form-urlencoded", "yaml", "graphql"} {
+		if strings.Contains(ct, text) {
+			return true
+		}
+	}
+	sample := data[:min(len(data), 8000)]
+	if len(sample) < len(data) {
+		sample = trimPartialRune(sample)
+	}
+	return bytes.IndexByte(sample, 0) < 0 && utf8.Valid(sample)
+}
+
+// trimPartialRune drops a UTF-8 sequence cut off at the end of data.
+func trimPartialRune(data []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+		if utf8.RuneStart(data[len(data)-i]) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				return data[:len(data)-i]
+			}
+			break
+		}
+	}
+	return data
+}
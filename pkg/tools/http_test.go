@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+func TestHTTPRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		switch r.URL.Path {
+		case "/echo":
+			w.Write([]byte(r.Method + " " + r.Header.Get("X-Token")))
+		case "/big":
+			w.Write([]byte(strings.Repeat("é", 100)))
+		}
+	}))
+	defer srv.Close()
+
+	ws := testWorkspace(t, &config.Settings{Tools: &config.ToolsSettings{HTTP: &config.HTTPSettings{MaxResponseBytes: 51}}})
+	var asked []string
+	approve := true
+	ws.Confirm = func(ctx context.Context, title, details string) (bool, error) {
+		asked = append(asked, details)
+		return approve, nil
+	}
+
+	resp := runTool(t, ws, HTTPRequestToolName, map[string]any{"method": "post", "url": srv.URL + "/echo", "headers": []any{"X-Token: abc"}})
+	if resp["body"] != "POST abc" || resp["status"] != "200 OK" {
+		t.Errorf("Expected the echo, got %v", resp)
+	}
+	resp = runTool(t, ws, HTTPRequestToolName, map[string]any{"url": srv.URL + "/big"})
+	if body, _ := resp["body"].(string); body != strings.Repeat("é", 25) || resp["truncated"] == nil {
+		t.Errorf("Expected the body to be truncated to whole characters, got %v", resp)
+	}
+	if len(asked) != 1 || asked[0] != "POST "+srv.URL+"/echo" {
+		t.Errorf("Expected the host to be approved once, got %q", asked)
+	}
+
+	// Another host needs its own approval.
+	other := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+	approve = false
+	if resp := runTool(t, ws, HTTPRequestToolName, map[string]any{"url": other + "/echo"}); !strings.Contains(resp["error"].(string), "did not approve") {
+		t.Errorf("Expected the rejection to be reported, got %v", resp)
+	}
+
+	// Allowlisted hosts are not asked about, even without a way to ask.
+	ws = testWorkspace(t, &config.Settings{Tools: &config.ToolsSettings{HTTP: &config.HTTPSettings{AllowedHosts: []string{"local*"}}}})
+	ws.Confirm = nil
+	if resp := runTool(t, ws, HTTPRequestToolName, map[string]any{"url": other + "/echo"}); resp["body"] != "GET " {
+		t.Errorf("Expected the allowlisted host to be reached, got %v", resp)
+	}
+	if resp := runTool(t, ws, HTTPRequestToolName, map[string]any{"url": srv.URL + "/echo"}); !strings.Contains(resp["error"].(string), "allowedHosts") {
+		t.Errorf("Expected other hosts to be refused, got %v", resp)
+	}
+}
+
+func TestHostAllowed(t *testing.T) {
+	ws := testWorkspace(t, &config.Settings{Tools: &config.ToolsSettings{HTTP: &config.HTTPSettings{
+		AllowedHosts: []string{"localhost:8080", "*.corp.example", "[::1]"},
+	}}})
+	for rawURL, want := range map[string]bool{
+		"http://localhost:8080/x":      true,
+		"http://localhost:9090/x":      false,
+		"https://api.corp.example/v1":  true,
+		"https://corp.example.evil.io": false,
+		"http://[::1]:3000/":           true,
+	} {
+		req, _ := http.NewRequest("GET", rawURL, nil)
+		if got := ws.hostAllowed(req.URL); got != want {
+			t.Errorf("hostAllowed(%s) = %v, want %v", rawURL, got, want)
+		}
+	}
+}
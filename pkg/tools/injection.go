@@ -0,0 +1,246 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// construct is a part of a shell command line that makes it do more than
+// run one program with literal arguments. A model can slip one into a
+// command that otherwise looks harmless, so the user approves each on its
+// own.
+type construct struct {
+	// kind names the construct, such as "command substitution", and text
+	// is its source.
+	kind, text string
+	// question asks the user to approve it.
+	question string
+}
+
+// confirmConstructs asks the user about each construct of command after
+// the command as a whole was approved.
+func (w *Workspace) confirmConstructs(ctx context.Context, command string) error {
+	for _, c := range shellConstructs(command) {
+		ok, err := w.Confirm(ctx, c.question, c.text)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("the user did not approve the %s %q", c.kind, c.text)
+		}
+	}
+	return nil
+}
+
+// shellConstructs returns the command and process substitutions, chained
+// commands and redirections of a shell command line, outside quotes where
+// the shell would not see them. Duplicated file descriptors, as in 2>&1,
+// and redirections to /dev/null are harmless and not reported.
+func shellConstructs(line string) []construct {
+	var (
+		found    []construct
+		runes    = []rune(line)
+		quote    rune
+		escaped  bool
+		segStart int
+		op       string
+		heredocs []heredoc
+	)
+	endSegment := func(end int) {
+		if seg := strings.TrimSpace(string(runes[segStart:end])); op != "" && seg != "" {
+			found = append(found, chained(op, seg))
+		}
+	}
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		next := func(k int) rune {
+			if i+k < len(runes) {
+				return runes[i+k]
+			}
+			return 0
+		}
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			}
+		case r == '`':
+			end := i + 1
+			for end < len(runes) && (runes[end] != '`' || runes[end-1] == '\\') {
+				end++
+			}
+			found = append(found, substitution(commandSubstitution, string(runes[i+1:min(end, len(runes))])))
+			i = end
+		case r == '$' && next(1) == '(' && next(2) != '(':
+			end := closingParen(runes, i+1)
+			found = append(found, substitution(commandSubstitution, string(runes[i+2:end])))
+			i = end
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case (r == '<' || r == '>') && next(1) == '(':
+			end := closingParen(runes, i+1)
+			found = append(found, substitution(processSubstitution, string(runes[i+2:end])))
+			i = end
+		case r == '<' || r == '>' || r == '&' && next(1) == '>':
+			start := i
+			for i+1 < len(runes) && strings.ContainsRune("<>&|-", runes[i+1]) {
+				i++
+			}
+			redirect := string(runes[start : i+1])
+			for i+1 < len(runes) && (runes[i+1] == ' ' || runes[i+1] == '\t') {
+				i++
+			}
+			targetStart := i + 1
+			for targetQuote := rune(0); i+1 < len(runes); i++ {
+				c := runes[i+1]
+				if targetQuote != 0 {
+					if c == targetQuote {
+						targetQuote = 0
+					}
+				} else if c == '\'' || c == '"' {
+					targetQuote = c
+				} else if strings.ContainsRune(" \t\n;&|<>()", c) {
+					break
+				}
+			}
+			target := string(runes[targetStart : i+1])
+			switch {
+			case strings.HasPrefix(redirect, "<<"):
+				if redirect != "<<<" {
+					heredocs = append(heredocs, heredoc{
+						delim: strings.NewReplacer(`'`, "", `"`, "", `\`, "").Replace(target),
+						// Quoting any of the delimiter keeps the body as is.
+						expands: !strings.ContainsAny(target, `'"\`),
+					})
+				}
+			case strings.HasSuffix(redirect, "&") && strings.Trim(target, "0123456789-") == "",
+				target == "/dev/null":
+			default:
+				found = append(found, construct{kind: "redirection", text: strings.TrimSpace(redirect + " " + target),
+					question: "The command redirects its input or output to a file; allow it?"})
+			}
+		case r == '\n' && len(heredocs) > 0:
+			endSegment(i)
+			// The bodies up to their delimiters are text, not commands,
+			// but for the substitutions of those that expand.
+			for _, h := range heredocs {
+				bodyStart, bodyEnd := i+1, len(runes)
+				for i+1 < len(runes) {
+					lineEnd := i + 1
+					for lineEnd < len(runes) && runes[lineEnd] != '\n' {
+						lineEnd++
+					}
+					line := strings.TrimLeft(string(runes[i+1:lineEnd]), "\t")
+					if line == h.delim {
+						bodyEnd = i + 1
+						i = lineEnd
+						break
+					}
+					i = lineEnd
+				}
+				// Without its delimiter, the body runs to the end.
+				if h.expands {
+					found = append(found, bodySubstitutions(runes[min(bodyStart, bodyEnd):bodyEnd])...)
+				}
+			}
+			heredocs = nil
+			segStart, op = min(i+1, len(runes)), "\n"
+		case r == ';' || r == '\n' || r == '&' || r == '|':
+			endSegment(i)
+			op = string(r)
+			if n := next(1); (r == '&' || r == '|') && (n == r || r == '|' && n == '&') {
+				op += string(n)
+				i++
+			}
+			segStart = i + 1
+		}
+	}
+	endSegment(len(runes))
+	return found
+}
+
+// heredoc is a here-document whose body follows the line.
+type heredoc struct {
+	delim string
+	// expands is set if the delimiter is not quoted, in which case the
+	// shell substitutes commands in the body.
+	expands bool
+}
+
+// bodySubstitutions returns the command substitutions in the body of a
+// here-document, where quotes are text but $( and backquotes are not.
+func bodySubstitutions(body []rune) []construct {
+	var found []construct
+	for i := 0; i < len(body); i++ {
+		switch {
+		case body[i] == '\\':
+			i++
+		case body[i] == '`':
+			end := i + 1
+			for end < len(body) && (body[end] != '`' || body[end-1] == '\\') {
+				end++
+			}
+			found = append(found, substitution(commandSubstitution, string(body[i+1:min(end, len(body))])))
+			i = end
+		case body[i] == '$' && i+1 < len(body) && body[i+1] == '(' && (i+2 == len(body) || body[i+2] != '('):
+			end := closingParen(body, i+1)
+			found = append(found, substitution(commandSubstitution, string(body[i+2:end])))
+			i = end
+		}
+	}
+	return found
+}
+
+// closingParen returns the index of the parenthesis closing the one at
+// open, or the end of runes if it is not closed.
+func closingParen(runes []rune, open int) int {
+	depth := 0
+	for i := open; i < len(runes); i++ {
+		switch runes[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(runes)
+}
+
+// Kinds of substitutions.
+const (
+	commandSubstitution = "command substitution"
+	processSubstitution = "process substitution"
+)
+
+func substitution(kind, command string) construct {
+	question := "The command runs another command to build its arguments; allow it?"
+	if kind == processSubstitution {
+		question = "The command runs another command in place of a file; allow it?"
+	}
+	return construct{kind: kind, text: strings.TrimSpace(command), question: question}
+}
+
+// chained returns the construct of command, which follows the operator op.
+func chained(op, command string) construct {
+	question := fmt.Sprintf("The command also runs another after %s; allow it?", op)
+	switch op {
+	case "|", "|&":
+		question = "The command pipes its output into another command; allow it?"
+	case "\n":
+		question = "The command runs another command on a line of its own; allow it?"
+	case "&":
+		question = "The command runs another while one runs in the background; allow it?"
+	}
+	return construct{kind: "chained command", text: command, question: question}
+}
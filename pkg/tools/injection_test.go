@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"context"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+func TestShellConstructs(t *testing.T) {
+	for line, want := range map[string][]string{
+		"go test ./...":                      nil,
+		`echo "a; b | c > d" 'e $(f)'`:       nil,
+		`echo a\;b \$(c)`:                    nil,
+		"make 2>&1 >/dev/null":               nil,
+		"echo $((1 + 2))":                    nil,
+		"echo $(whoami)":                     {"command substitution whoami"},
+		`echo "today is $(date)"`:            {"command substitution date"},
+		"echo `id -u`":                       {"command substitution id -u"},
+		"diff <(ls a) <(ls b)":               {"process substitution ls a", "process substitution ls b"},
+		"make && make install":               {"chained command make install"},
+		"a; b || c | d & e\nf":               {"chained command b", "chained command c", "chained command d", "chained command e", "chained command f"},
+		"sleep 1 &":                          nil,
+		"echo hi > out.txt":                  {"redirection > out.txt"},
+		"sort < in &>> log":                  {"redirection < in", "redirection &>> log"},
+		"cat <<'EOF' > f\nrm -rf /\nEOF\nls": {"redirection > f", "chained command ls"},
+		"grep x <<< 'a; b'":                  nil,
+	} {
+		var got []string
+		for _, c := range shellConstructs(line) {
+			got = append(got, c.kind+" "+c.text)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("shellConstructs(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
+
+// TestHeredocConstructs checks that the bodies of here-documents are text,
+// but for the substitutions the shell still makes when the delimiter is not
+// quoted.
+func TestHeredocConstructs(t *testing.T) {
+	for line, want := range map[string][]string{
+		"cat <<EOF\nhi\nEOF":                         nil,
+		"cat <<EOF\nhi\nEOF\n":                       nil,
+		"cat <<EOF":                                  nil,
+		"cat <<EOF\n$(touch /tmp/pwned)\nEOF\n":      {"command substitution touch /tmp/pwned"},
+		"cat <<-EOF\n\t`id` $((1+2)) \\$(no)\n\tEOF": {"command substitution id"},
+		"cat <<EOF\n$(touch /tmp/pwned)":             {"command substitution touch /tmp/pwned"},
+		"cat <<'EOF'\n$(touch /tmp/pwned)\nEOF\n":    nil,
+		"cat <<\"EOF\"\n`id`\nEOF":                   nil,
+		"cat <<\\EOF\n$(id)\nEOF\nls":                {"chained command ls"},
+	} {
+		var got []string
+		for _, c := range shellConstructs(line) {
+			got = append(got, c.kind+" "+c.text)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("shellConstructs(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
+
+func TestConfirmConstructs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	ws := testWorkspace(t, &config.Settings{Tools: &config.ToolsSettings{}})
+	var asked []string
+	answer := true
+	ws.Confirm = func(ctx context.Context, title, details string) (bool, error) {
+		asked = append(asked, details)
+		return answer || !strings.Contains(title, "pipes"), nil
+	}
+
+	if resp := runTool(t, ws, ShellToolName, map[string]any{"command": "echo hi | tr a-z A-Z"}); resp["output"] != "HI\n" {
+		t.Errorf("Expected the approved pipeline to run, got %v", resp)
+	}
+	if want := []string{"echo hi | tr a-z A-Z", "tr a-z A-Z"}; !slices.Equal(asked, want) {
+		t.Errorf("asked about %q, want %q", asked, want)
+	}
+
+	answer = false
+	resp := runTool(t, ws, ShellToolName, map[string]any{"command": "echo hi | sh"})
+	if err, _ := resp["error"].(string); !strings.Contains(err, `did not approve the chained command "sh"`) {
+		t.Errorf("Expected the pipe to be refused, got %v", resp)
+	}
+
+	// Commands approved for good, or by the approval mode, are not
+	// questioned further.
+	asked = nil
+	ws.Settings.Tools.Allowed = []string{"run_shell_command(echo hi | sh)"}
+	runTool(t, ws, ShellToolName, map[string]any{"command": "echo hi | sh"})
+	ws.Settings.Tools.Allowed = nil
+	ws.Approval = ApprovalYolo
+	runTool(t, ws, ShellToolName, map[string]any{"command": "echo $(echo hi)"})
+	if len(asked) != 0 {
+		t.Errorf("asked about %q", asked)
+	}
+}
@@ -0,0 +1,274 @@
+package tools
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// InspectFileToolName is the name of the tool describing binary files.
+const InspectFileToolName = "inspect_file"
+
+const (
+	// maxArchiveEntries caps the entries listed for an archive.
+	maxArchiveEntries = 200
+	// maxStrings caps the printable strings sampled from a binary.
+	maxStrings = 50
+	// minStringLength is the shortest run of printable bytes that counts
+	// as a string, as with strings(1) -n 6.
+	minStringLength = 6
+	// stringsScanLimit is how much of a file is scanned for strings.
+	stringsScanLimit = 4 << 20
+)
+
+var inspectFileDeclaration = &genai.FunctionDeclaration{
+	Name: InspectFileToolName,
+	Description: "Describes a binary file in the workspace without returning its bytes: its type and size, " +
+		"the entries of zip and tar archives, the dimensions of images, and for executables their format, " +
+		"architecture and a sample of the printable strings they contain. Use read_file for text files.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"path": {
+				Type:        genai.TypeString,
+				Description: "The file to inspect, relative to the workspace root or absolute.",
+			},
+		},
+		Required: []string{"path"},
+	},
+}
+
+func inspectFile(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	name, err := stringArg(args, "path")
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, errors.New("path must not be empty")
+	}
+	path, err := ws.Resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case info.IsDir():
+		return nil, fmt.Errorf("%s is a directory; use %s to list it", name, ListDirectoryToolName)
+	case !info.Mode().IsRegular():
+		return nil, fmt.Errorf("%s is a %s, not a regular file", name, fileKind(info.Mode()))
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+
+	resp := map[string]any{
+		"size":     formatSize(info.Size()),
+		"bytes":    info.Size(),
+		"mimeType": http.DetectContentType(head),
+	}
+	switch {
+	case strings.HasPrefix(string(head), "PK\x03\x04") || strings.HasPrefix(string(head), "PK\x05\x06"):
+		err = inspectZip(f, info.Size(), resp)
+	case strings.HasPrefix(string(head), "\x1f\x8b"):
+		err = inspectGzip(f, resp)
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		_, err = f.Seek(0, io.SeekStart)
+		if err == nil {
+			err = inspectTar(f, "tar", resp)
+		}
+	case strings.HasPrefix(resp["mimeType"].(string), "image/"):
+		inspectImage(f, resp)
+	default:
+		if inspectExecutable(f, resp) {
+			resp["strings"], err = sampleStrings(f)
+		}
+	}
+	if err != nil {
+		resp["warning"] = err.Error()
+	}
+	if !isBinary(head) && resp["format"] == nil {
+		resp["hint"] = "This looks like a text file; use read_file to read it."
+	}
+	return resp, nil
+}
+
+func inspectZip(f *os.File, size int64, resp map[string]any) error {
+	resp["format"] = "zip"
+	z, err := zip.NewReader(f, size)
+	if err != nil {
+		return fmt.Errorf("unreadable zip archive: %w", err)
+	}
+	var entries []string
+	for _, e := range z.File {
+		if len(entries) < maxArchiveEntries {
+			entries = append(entries, fmt.Sprintf("%s (%s)", e.Name, formatSize(int64(e.UncompressedSize64))))
+		}
+	}
+	setEntries(resp, entries, len(z.File))
+	return nil
+}
+
+func inspectGzip(f *os.File, resp map[string]any) error {
+	resp["format"] = "gzip"
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("unreadable gzip stream: %w", err)
+	}
+	defer gz.Close()
+	if gz.Name != "" {
+		resp["originalName"] = gz.Name
+	}
+	// A compressed tar archive is listed like a plain one.
+	r := bufio.NewReader(gz)
+	if head, _ := r.Peek(262); len(head) == 262 && string(head[257:262]) == "ustar" {
+		return inspectTar(r, "tar.gz", resp)
+	}
+	return nil
+}
+
+func inspectTar(r io.Reader, format string, resp map[string]any) error {
+	resp["format"] = format
+	t := tar.NewReader(r)
+	var entries []string
+	total := 0
+	for {
+		h, err := t.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			setEntries(resp, entries, total)
+			return fmt.Errorf("tar archive is truncated or corrupt after %d entries: %w", total, err)
+		}
+		total++
+		if len(entries) < maxArchiveEntries {
+			entry := h.Name
+			switch h.Typeflag {
+			case tar.TypeSymlink:
+				entry += " -> " + h.Linkname
+			case tar.TypeReg:
+				entry += fmt.Sprintf(" (%s)", formatSize(h.Size))
+			}
+			entries = append(entries, entry)
+		}
+	}
+	setEntries(resp, entries, total)
+	return nil
+}
+
+func setEntries(resp map[string]any, entries []string, total int) {
+	resp["entries"] = entries
+	resp["entryCount"] = total
+	if total > len(entries) {
+		resp["note"] = fmt.Sprintf("Only the first %d of %d entries are listed.", len(entries), total)
+	}
+}
+
+func inspectImage(f *os.File, resp map[string]any) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	if cfg, format, err := image.DecodeConfig(f); err == nil {
+		resp["format"] = format
+		resp["width"] = cfg.Width
+		resp["height"] = cfg.Height
+	}
+}
+
+// inspectExecutable records the format and architecture of ELF, Mach-O and
+// PE executables and reports whether f is one.
+func inspectExecutable(f *os.File, resp map[string]any) bool {
+	if e, err := elf.NewFile(f); err == nil {
+		resp["format"] = "ELF " + strings.TrimPrefix(e.Type.String(), "ET_")
+		resp["architecture"] = strings.TrimPrefix(e.Machine.String(), "EM_")
+		return true
+	}
+	if m, err := macho.NewFile(f); err == nil {
+		resp["format"] = "Mach-O " + strings.TrimPrefix(m.Type.String(), "Mach")
+		resp["architecture"] = m.Cpu.String()
+		return true
+	}
+	if _, err := macho.NewFatFile(f); err == nil {
+		resp["format"] = "Mach-O universal binary"
+		return true
+	}
+	if p, err := pe.NewFile(f); err == nil {
+		resp["format"] = "PE"
+		resp["architecture"] = peMachines[p.Machine]
+		return true
+	}
+	return false
+}
+
+var peMachines = map[uint16]string{
+	pe.IMAGE_FILE_MACHINE_I386:  "x86",
+	pe.IMAGE_FILE_MACHINE_AMD64: "x86-64",
+	pe.IMAGE_FILE_MACHINE_ARM:   "ARM",
+	pe.IMAGE_FILE_MACHINE_ARM64: "ARM64",
+}
+
+// sampleStrings returns printable ASCII runs from the start of f, like
+// strings(1), skipping duplicates.
+func sampleStrings(f *os.File) ([]string, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(io.LimitReader(f, stringsScanLimit))
+	var (
+		found []string
+		seen  = map[string]bool{}
+		run   []byte
+	)
+	flush := func() {
+		if len(run) >= minStringLength && !seen[string(run)] {
+			seen[string(run)] = true
+			found = append(found, truncateLine(string(run)))
+		}
+		run = run[:0]
+	}
+	for len(found) < maxStrings {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			flush()
+			break
+		}
+		if err != nil {
+			return found, err
+		}
+		if b >= 0x20 && b < 0x7f || b == '\t' {
+			run = append(run, b)
+		} else {
+			flush()
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestInspectFile(t *testing.T) {
+	ws := testWorkspace(t, nil)
+	root := ws.Roots[0]
+
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	w, _ := zw.Create("docs/readme.txt")
+	w.Write([]byte("hello"))
+	zw.Close()
+	os.WriteFile(filepath.Join(root, "a.zip"), zipped.Bytes(), 0644)
+
+	var tgz bytes.Buffer
+	gz := gzip.NewWriter(&tgz)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "bin/tool", Mode: 0755, Size: 3, Typeflag: tar.TypeReg})
+	tw.Write([]byte("abc"))
+	tw.WriteHeader(&tar.Header{Name: "latest", Linkname: "bin/tool", Typeflag: tar.TypeSymlink})
+	tw.Close()
+	gz.Close()
+	os.WriteFile(filepath.Join(root, "a.tar.gz"), tgz.Bytes(), 0644)
+
+	var img bytes.Buffer
+	png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 3, 2)))
+	os.WriteFile(filepath.Join(root, "a.png"), img.Bytes(), 0644)
+
+	resp := runTool(t, ws, InspectFileToolName, map[string]any{"path": "a.zip"})
+	if entries := stringList(resp["entries"]); resp["format"] != "zip" || !slices.Equal(entries, []string{"docs/readme.txt (5 bytes)"}) {
+		t.Errorf("zip: got %v", resp)
+	}
+	resp = runTool(t, ws, InspectFileToolName, map[string]any{"path": "a.tar.gz"})
+	if entries := stringList(resp["entries"]); resp["format"] != "tar.gz" || !slices.Equal(entries, []string{"bin/tool (3 bytes)", "latest -> bin/tool"}) {
+		t.Errorf("tar.gz: got %v", resp)
+	}
+	resp = runTool(t, ws, InspectFileToolName, map[string]any{"path": "a.png"})
+	if resp["format"] != "png" || resp["width"] != 3 || resp["height"] != 2 || resp["mimeType"] != "image/png" {
+		t.Errorf("png: got %v", resp)
+	}
+
+	if runtime.GOOS == "linux" {
+		exe, err := os.ReadFile(os.Args[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(filepath.Join(root, "exe"), exe, 0755)
+		resp = runTool(t, ws, InspectFileToolName, map[string]any{"path": "exe"})
+		if strs := stringList(resp["strings"]); resp["format"] != "ELF EXEC" && resp["format"] != "ELF DYN" || len(strs) == 0 {
+			t.Errorf("executable: got format %v, architecture %v and %d strings", resp["format"], resp["architecture"], len(strs))
+		}
+	}
+}
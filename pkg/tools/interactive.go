@@ -0,0 +1,163 @@
+package tools
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/pty"
+)
+
+const (
+	// ShellRows and ShellCols are the size of the terminal of interactive
+	// commands until someone resizes it.
+	ShellRows = 10
+	ShellCols = 80
+	// maxShellScreen bounds the lines of output a ShellSession keeps.
+	maxShellScreen = 200
+)
+
+// ShellSession is a command run_shell_command runs in a pseudo-terminal,
+// which tools.shell.enableInteractiveShell asks for, so that the user can
+// watch it and answer its prompts.
+type ShellSession struct {
+	// Command is the command line the model asked for.
+	Command string
+	// Changed, if set by Workspace.ShellStarted, is called whenever the
+	// command writes output.
+	Changed func()
+	// Exited, if set by Workspace.ShellStarted, is called once the command
+	// has exited.
+	Exited func()
+
+	pty *os.File
+
+	mu sync.Mutex
+	// lines is the tail of the output, as plain text, ending with the line
+	// being written.
+	lines []string
+	// returned is whether that line ended with a carriage return.
+	returned bool
+}
+
+// Write types p into the command's terminal.
+func (s *ShellSession) Write(p []byte) (int, error) {
+	return s.pty.Write(p)
+}
+
+// Resize tells the command its terminal has rows and cols.
+func (s *ShellSession) Resize(rows, cols int) error {
+	return pty.Resize(s.pty, rows, cols)
+}
+
+// Screen returns the last n lines of output, without terminal escapes.
+func (s *ShellSession) Screen(n int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lines := s.lines
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return append([]string(nil), lines[max(len(lines)-n, 0):]...)
+}
+
+// record adds output to the screen. Text after a carriage return replaces
+// the line, as progress bars expect, and a backspace erases a character.
+func (s *ShellSession) record(output []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.lines) == 0 {
+		s.lines = []string{""}
+	}
+	line := s.lines[len(s.lines)-1]
+	for _, r := range stripANSI(string(output)) {
+		switch r {
+		case '\n':
+			s.lines[len(s.lines)-1] = line
+			s.lines = append(s.lines, "")
+			line, s.returned = "", false
+		case '\r':
+			// Unless a newline follows, what comes next overwrites the line.
+			s.returned = true
+		case '\b':
+			if line != "" {
+				runes := []rune(line)
+				line = string(runes[:len(runes)-1])
+			}
+		case '\a':
+		default:
+			if s.returned {
+				line, s.returned = "", false
+			}
+			line += string(r)
+		}
+	}
+	s.lines[len(s.lines)-1] = line
+	if len(s.lines) > maxShellScreen {
+		s.lines = s.lines[len(s.lines)-maxShellScreen:]
+	}
+}
+
+// interactive reports whether commands run in a pseudo-terminal: the
+// setting asks for it, someone watches them, and they run on the host of a
+// platform that has pseudo-terminals.
+func (w *Workspace) interactive() bool {
+	t := w.Settings.Tools
+	return t != nil && t.Shell != nil && t.Shell.EnableInteractiveShell &&
+		w.ShellStarted != nil && w.Sandbox == nil && pty.Supported()
+}
+
+// pager is the PAGER of interactive commands, which would otherwise wait
+// for keys to page through their output: tools.shell.pager, or cat.
+func (w *Workspace) pager() string {
+	if t := w.Settings.Tools; t != nil && t.Shell != nil && t.Shell.Pager != "" {
+		return t.Shell.Pager
+	}
+	return "cat"
+}
+
+// runInTerminal runs cmd in a pseudo-terminal, writing its output to out,
+// and hands the session to ShellStarted while it runs.
+func (w *Workspace) runInTerminal(cmd *exec.Cmd, command string, out io.Writer) error {
+	ptmx, err := pty.Start(cmd, ShellRows, ShellCols)
+	if err != nil {
+		return err
+	}
+	defer ptmx.Close()
+	s := &ShellSession{Command: command, pty: ptmx}
+	w.ShellStarted(s)
+	if s.Exited != nil {
+		defer s.Exited()
+	}
+
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+		buf := make([]byte, 4096)
+		for {
+			n, err := ptmx.Read(buf)
+			if n > 0 {
+				out.Write(buf[:n])
+				s.record(buf[:n])
+				if s.Changed != nil {
+					s.Changed()
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	err = cmd.Wait()
+	// Processes the command started in the background may keep the
+	// terminal open after it exited.
+	select {
+	case <-copied:
+	case <-time.After(time.Second):
+		ptmx.Close()
+		<-copied
+	}
+	return err
+}
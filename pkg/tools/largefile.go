@@ -0,0 +1,107 @@
+package tools
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// largeFilePreviewLines is the number of lines read_file returns from a
+	// file over the size limit when no limit is given.
+	largeFilePreviewLines = 200
+	// maxOutlineEntries caps the outline of a large file.
+	maxOutlineEntries = 300
+)
+
+// definitionPattern matches lines that start a definition in common
+// programming languages: functions, methods, classes and types.
+var definitionPattern = regexp.MustCompile(`^\s*(?:` +
+	`func\s|type\s+\w+\s|` + // Go
+	`(?:async\s+)?def\s+\w+|class\s+\w+|` + // Python, Ruby and others
+	`(?:export\s+)?(?:default\s+)?(?:async\s+)?function\b|(?:export\s+)?(?:abstract\s+)?class\s+\w+|(?:export\s+)?interface\s+\w+|` + // JavaScript, TypeScript
+	`(?:pub(?:\([\w:]+\))?\s+)?(?:async\s+)?(?:fn|struct|enum|trait|impl|mod)\b|` + // Rust
+	`(?:(?:public|private|protected|internal|static|final|abstract|override|virtual)\s+)+[\w<>\[\],.? ]+\s+\w+\s*\(` + // Java, C#
+	`)`)
+
+// headingPattern matches Markdown headings.
+var headingPattern = regexp.MustCompile(`^#{1,6}\s+\S`)
+
+// readLargeFile reads a range of lines of a file over the size limit
+// without loading it whole. The first page comes with an outline of the
+// file and instructions on reading other ranges, so that the model can
+// navigate it.
+func (w *Workspace) readLargeFile(name, path string, size int64, offset, limit int) (map[string]any, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, 64<<10)
+	if head, _ := r.Peek(8000); isBinary(head) {
+		return nil, fmt.Errorf("%s appears to be a binary file (%s) and cannot be read as text; use %s to describe it",
+			name, formatSize(size), InspectFileToolName)
+	}
+
+	if limit == 0 {
+		limit = largeFilePreviewLines
+	}
+	pattern := definitionPattern
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown", ".mdx":
+		pattern = headingPattern
+	}
+
+	var (
+		page           []string
+		outline        []string
+		outlineDropped int
+		total          int
+	)
+	for ; ; total++ {
+		line, err := r.ReadString('\n')
+		if err == io.EOF && line == "" {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if total >= offset && total < offset+limit {
+			page = append(page, truncateLine(decodeOutput([]byte(line), "")))
+		}
+		if offset == 0 && pattern.MatchString(line) {
+			if len(outline) < maxOutlineEntries {
+				outline = append(outline, fmt.Sprintf("%d: %s", total+1, truncateLine(strings.TrimSpace(decodeOutput([]byte(line), "")))))
+			} else {
+				outlineDropped++
+			}
+		}
+	}
+	if offset > total {
+		return nil, fmt.Errorf("offset %d is past the end of %s, which has %d lines", offset, name, total)
+	}
+
+	end := offset + len(page)
+	message := fmt.Sprintf("%s is %s, over the %s size limit (tools.maxFileSize), so only lines %d-%d of %d are shown. "+
+		"Read other ranges with offset (the 0-based line to start at) and limit, e.g. offset=%d limit=%d.",
+		name, formatSize(size), formatSize(w.maxFileSize()), offset+1, end, total, end, limit)
+	resp := map[string]any{"content": strings.Join(page, "\n"), "totalLines": total}
+	if offset == 0 {
+		if len(outline) > 0 {
+			resp["outline"] = outline
+			message += " The outline lists the file's definitions or headings by line number; " +
+				"to read the one starting at line N, use offset N-1."
+		}
+		if outlineDropped > 0 {
+			message += fmt.Sprintf(" %d more outline entries were left out.", outlineDropped)
+		}
+	}
+	resp["message"] = message
+	return resp, nil
+}
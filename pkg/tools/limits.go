@@ -0,0 +1,135 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"runtime"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+// allTools is the key of tools.limits that applies to every tool.
+const allTools = "*"
+
+// limits returns the limits of the named tool: those configured for it,
+// falling back field by field to those for every tool.
+func (w *Workspace) limits(tool string) config.LimitSettings {
+	t := w.Settings.Tools
+	if t == nil {
+		return config.LimitSettings{}
+	}
+	l, all := t.Limits[tool], t.Limits[allTools]
+	if l.Timeout == 0 {
+		l.Timeout = all.Timeout
+	}
+	if l.MaxOutputBytes == 0 {
+		l.MaxOutputBytes = all.MaxOutputBytes
+	}
+	if l.OutputBytes == 0 {
+		l.OutputBytes = all.OutputBytes
+	}
+	if l.OutputTokens == 0 {
+		l.OutputTokens = all.OutputTokens
+	}
+	if l.OutputBytes == 0 && l.OutputTokens == 0 && t.EnableToolOutputTruncation {
+		l.OutputBytes = t.TruncateToolOutputThreshold
+	}
+	if l.CPUSeconds == 0 {
+		l.CPUSeconds = all.CPUSeconds
+	}
+	if l.MemoryMB == 0 {
+		l.MemoryMB = all.MemoryMB
+	}
+	return l
+}
+
+// limitError reports a tool call that exceeded one of its limits, named
+// after its setting.
+type limitError struct {
+	limit string
+	value any
+	msg   string
+}
+
+func (e *limitError) Error() string { return e.msg }
+
+// response describes the violation to the model, along with what the tool
+// returned before it was stopped, such as the output of a command.
+func (e *limitError) response(name string, partial map[string]any) map[string]any {
+	resp := map[string]any{}
+	for k, v := range partial {
+		resp[k] = v
+	}
+	resp["error"] = fmt.Sprintf("%s exceeded its limit: %s", name, e.msg)
+	resp["limit"] = e.limit
+	resp["limit_value"] = e.value
+	return resp
+}
+
+// runLimited runs a tool within the timeout and output limits of l,
+// truncating its response to the output budget.
+func runLimited(ctx context.Context, ws *Workspace, run handler, args map[string]any, l config.LimitSettings) (map[string]any, error) {
+	if l.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(l.Timeout)*time.Millisecond)
+		defer cancel()
+	}
+	resp, err := run(ctx, ws, args)
+	if l.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		timeout := time.Duration(l.Timeout) * time.Millisecond
+		return resp, &limitError{"timeout", l.Timeout, fmt.Sprintf("stopped after %v", timeout)}
+	}
+	if err != nil {
+		return resp, err
+	}
+	resp = truncateResponse(resp, outputBudget(l))
+	if l.MaxOutputBytes <= 0 {
+		return resp, nil
+	}
+	data, _ := json.Marshal(plainValue(resp))
+	if len(data) > l.MaxOutputBytes {
+		return nil, &limitError{"maxOutputBytes", l.MaxOutputBytes,
+			fmt.Sprintf("the response of %d bytes is larger than %d bytes; ask for less, such as a smaller range or a filtered command", len(data), l.MaxOutputBytes)}
+	}
+	return resp, nil
+}
+
+// ulimitPrefix returns the shell commands that apply the CPU and memory
+// limits of l to a command, on Linux.
+func ulimitPrefix(l config.LimitSettings) string {
+	if runtime.GOOS != "linux" {
+		return ""
+	}
+	var prefix string
+	if l.CPUSeconds > 0 {
+		prefix += fmt.Sprintf("ulimit -t %d; ", l.CPUSeconds)
+	}
+	if l.MemoryMB > 0 {
+		prefix += fmt.Sprintf("ulimit -v %d; ", l.MemoryMB*1024)
+	}
+	return prefix
+}
+
+// outOfMemory matches the messages of programs failing to allocate memory.
+var outOfMemory = regexp.MustCompile(`(?i)cannot allocate memory|out of memory|MemoryError|bad_alloc|memory exhausted`)
+
+// shellLimitError returns the limit a shell command that exited with
+// exitCode and output was stopped by, if any: CPU time ends it with SIGXCPU
+// or SIGKILL, and running out of memory makes it fail to allocate.
+func shellLimitError(l config.LimitSettings, exitCode int, output string) error {
+	if ulimitPrefix(l) == "" || exitCode == 0 {
+		return nil
+	}
+	const sigkill, sigxcpu = 9, 24
+	if l.CPUSeconds > 0 && (exitCode == -1 || exitCode == 128+sigkill || exitCode == 128+sigxcpu) {
+		return &limitError{"cpuSeconds", l.CPUSeconds, fmt.Sprintf("the command was stopped after %d seconds of CPU time", l.CPUSeconds)}
+	}
+	if l.MemoryMB > 0 && outOfMemory.MatchString(output) {
+		return &limitError{"memoryMB", l.MemoryMB, fmt.Sprintf("the command ran out of its %d MB of memory", l.MemoryMB)}
+	}
+	return nil
+}
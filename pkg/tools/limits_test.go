@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+func TestLimitsFallBack(t *testing.T) {
+	ws := testWorkspace(t, &config.Settings{Tools: &config.ToolsSettings{Limits: map[string]config.LimitSettings{
+		"*":           {Timeout: 1000, MaxOutputBytes: 100},
+		ShellToolName: {Timeout: 5000},
+	}}})
+	if l := ws.limits(ShellToolName); l.Timeout != 5000 || l.MaxOutputBytes != 100 {
+		t.Errorf("limits(%s) = %+v, want its own timeout and the default output limit", ShellToolName, l)
+	}
+	if l := ws.limits(ReadFileToolName); l.Timeout != 1000 {
+		t.Errorf("limits(%s) = %+v, want the default timeout", ReadFileToolName, l)
+	}
+}
+
+func TestShellLimits(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	limits := map[string]config.LimitSettings{}
+	ws := testWorkspace(t, &config.Settings{Tools: &config.ToolsSettings{Limits: limits}})
+
+	limits[ShellToolName] = config.LimitSettings{Timeout: 200}
+	resp := runShell(t, ws, map[string]any{"command": "echo started; sleep 10"})
+	if resp["limit"] != "timeout" || !strings.Contains(resp["output"].(string), "started") {
+		t.Errorf("Expected a timeout with the output so far, got %v", resp)
+	}
+
+	limits[ShellToolName] = config.LimitSettings{MaxOutputBytes: 1000}
+	resp = runShell(t, ws, map[string]any{"command": "head -c 5000 /dev/zero | tr '\\0' x"})
+	if resp["limit"] != "maxOutputBytes" || resp["output"] != nil {
+		t.Errorf("Expected the output limit to be reported, got %v", resp)
+	}
+	if resp = runShell(t, ws, map[string]any{"command": "echo small"}); resp["limit"] != nil {
+		t.Errorf("Expected small output to pass, got %v", resp)
+	}
+
+	if runtime.GOOS != "linux" {
+		return
+	}
+	limits[ShellToolName] = config.LimitSettings{CPUSeconds: 1}
+	resp = runShell(t, ws, map[string]any{"command": "while :; do :; done"})
+	if resp["limit"] != "cpuSeconds" {
+		t.Errorf("Expected the CPU limit to be reported, got %v", resp)
+	}
+}
+
+func TestOutputBudget(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	ws := testWorkspace(t, &config.Settings{Tools: &config.ToolsSettings{Limits: map[string]config.LimitSettings{
+		"*": {OutputTokens: 250, MaxOutputBytes: 2000},
+	}}})
+	resp := runShell(t, ws, map[string]any{"command": "seq 1 2000"})
+	output, _ := resp["output"].(string)
+	if !strings.HasPrefix(output, "1\n2\n") || !strings.HasSuffix(output, "1999\n2000\n") {
+		t.Errorf("Expected the head and tail of the output, got %q", output)
+	}
+	if !strings.Contains(output, "lines) elided ...]") {
+		t.Errorf("Expected a marker for the elided lines, got %q", output)
+	}
+	if data, _ := json.Marshal(resp); len(data) > 1000 || resp["limit"] != nil {
+		t.Errorf("Expected the response to fit the budget of 1000 bytes, got %d bytes: %v", len(data), resp)
+	}
+}
+
+func TestElide(t *testing.T) {
+	s := strings.Repeat("é", 500)
+	got := elide(s, 300)
+	if !utf8.ValidString(got) || len(got) > len(s)-300 {
+		t.Errorf("elide() = %d bytes of valid UTF-8 %v, want at most %d", len(got), utf8.ValidString(got), len(s)-300)
+	}
+	if short := "short"; elide(short, 2) != short {
+		t.Error("elide() should keep strings the marker would not shorten")
+	}
+}
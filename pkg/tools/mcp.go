@@ -0,0 +1,491 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"mime"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/mcp"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// MCPServers returns the servers of the mcpServers setting that mcp.allowed
+// and mcp.excluded let through, by name.
+func MCPServers(cfg *config.Settings) map[string]config.MCPServer {
+	if cfg == nil {
+		return nil
+	}
+	servers := map[string]config.MCPServer{}
+	for name, server := range cfg.MCPServers {
+		if cfg.MCP != nil {
+			if len(cfg.MCP.Allowed) > 0 && !slices.Contains(cfg.MCP.Allowed, name) {
+				continue
+			}
+			if slices.Contains(cfg.MCP.Excluded, name) {
+				continue
+			}
+		}
+		servers[name] = server
+	}
+	return servers
+}
+
+// ConnectMCP connects to the MCP servers of the settings, all at once, and
+// offers their tools in w as each connects, so it may run while w is in
+// use. Servers that fail to connect are left out, and reported by the
+// error. Workspaces not made by NewWorkspace have no MCP registry and
+// connect to none.
+func (w *Workspace) ConnectMCP(ctx context.Context) error {
+	if w.MCP == nil {
+		return nil
+	}
+	servers := MCPServers(w.Settings)
+	names := make([]string, 0, len(servers))
+	for name := range servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = w.connectMCPServer(ctx, name, servers[name])
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// ConnectMCPServer connects to the named MCP server of the settings, again
+// if it is connected already, such as after the user authorized with it,
+// and offers its tools in w.
+func (w *Workspace) ConnectMCPServer(ctx context.Context, name string) error {
+	if w.MCP == nil {
+		return nil
+	}
+	server, ok := MCPServers(w.Settings)[name]
+	if !ok {
+		return fmt.Errorf("no MCP server named %q is configured", name)
+	}
+	return w.connectMCPServer(ctx, name, server)
+}
+
+func (w *Workspace) connectMCPServer(ctx context.Context, name string, server config.MCPServer) error {
+	client, err := mcp.Connect(ctx, name, server)
+	if err != nil {
+		return err
+	}
+	if err := w.MCP.Add(ctx, client, server); err != nil {
+		client.Close()
+		return err
+	}
+	return nil
+}
+
+// reservedToolName reports whether name is taken by a tool of the CLI or a
+// plugin, so that an MCP tool of the same name is prefixed with its server.
+func (w *Workspace) reservedToolName(name string) bool {
+	return builtinTool(name) || w.plugins[name] != nil
+}
+
+// mcpDeclarations returns the declarations of the tools of the connected
+// MCP servers, under their registered names. Tools whose input schema
+// cannot be declared are left out.
+func (w *Workspace) mcpDeclarations() []*genai.FunctionDeclaration {
+	if w.MCP == nil {
+		return nil
+	}
+	var decls []*genai.FunctionDeclaration
+	for _, t := range w.MCP.Tools() {
+		if decl, err := mcpDeclaration(t); err == nil {
+			decls = append(decls, decl)
+		}
+	}
+	return decls
+}
+
+// mcpDeclaration declares the tool t of an MCP server.
+func mcpDeclaration(t mcp.RegisteredTool) (*genai.FunctionDeclaration, error) {
+	decl := &genai.FunctionDeclaration{Name: t.Name, Description: t.Tool.Description}
+	if len(t.Tool.InputSchema) == 0 {
+		return decl, nil
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(t.Tool.InputSchema, &schema); err != nil {
+		return nil, fmt.Errorf("%s: invalid input schema: %w", t.QualifiedName(), err)
+	}
+	schema, err := mcpSchema(schema, schemaDefs(schema), nil, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", t.QualifiedName(), err)
+	}
+	params, err := jsonSchema(schema)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", t.QualifiedName(), err)
+	}
+	// The API refuses objects without properties; such tools take none.
+	if len(params.Properties) > 0 {
+		decl.Parameters = params
+	}
+	return decl, nil
+}
+
+// maxSchemaDepth bounds the nesting of the input schemas of MCP tools,
+// which recursive references would otherwise make endless.
+const maxSchemaDepth = 10
+
+// supportedFormats are the formats the API accepts, by type. It refuses
+// requests declaring others, such as uri or email.
+var supportedFormats = map[string][]string{
+	"string":  {"enum", "date-time"},
+	"number":  {"float", "double"},
+	"integer": {"int32", "int64"},
+}
+
+// schemaDefs returns the definitions local references of schema point to.
+func schemaDefs(schema map[string]any) map[string]any {
+	defs, _ := schema["$defs"].(map[string]any)
+	if defs == nil {
+		defs, _ = schema["definitions"].(map[string]any)
+	}
+	return defs
+}
+
+// mcpSchema rewrites the JSON schema v of the input of an MCP tool, whose
+// generators commonly use constructs the API has no equivalent of, into
+// the subset jsonSchema declares: local references are inlined, anyOf and
+// oneOf of a type and null make it nullable and otherwise keep their first
+// alternative, allOf merges its parts, const becomes a single-value enum
+// and missing types are inferred. Formats the API does not accept are
+// dropped, and so are optional properties that cannot be declared, such as
+// recursive ones. expanding are the references being inlined.
+func mcpSchema(v map[string]any, defs map[string]any, expanding []string, depth int) (map[string]any, error) {
+	if depth > maxSchemaDepth {
+		return nil, errors.New("the schema is nested too deeply")
+	}
+	if ref, ok := v["$ref"].(string); ok {
+		name, found := strings.CutPrefix(ref, "#/$defs/")
+		if !found {
+			name, found = strings.CutPrefix(ref, "#/definitions/")
+		}
+		def, _ := defs[name].(map[string]any)
+		if !found || def == nil {
+			return nil, fmt.Errorf("unsupported reference %q", ref)
+		}
+		if slices.Contains(expanding, ref) {
+			return nil, fmt.Errorf("recursive reference %q", ref)
+		}
+		resolved, err := mcpSchema(def, defs, append(expanding, ref), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		if desc, ok := v["description"].(string); ok {
+			resolved["description"] = desc
+		}
+		return resolved, nil
+	}
+
+	out := map[string]any{}
+	for _, key := range []string{"type", "description", "format", "nullable", "enum", "required"} {
+		if value, ok := v[key]; ok {
+			out[key] = value
+		}
+	}
+	if c, ok := v["const"]; ok {
+		out["enum"] = []any{c}
+	}
+
+	for _, key := range []string{"anyOf", "oneOf"} {
+		alternatives, _ := v[key].([]any)
+		var chosen map[string]any
+		for _, a := range alternatives {
+			am, _ := a.(map[string]any)
+			if am["type"] == "null" {
+				out["nullable"] = true
+				continue
+			}
+			if chosen == nil && am != nil {
+				chosen = am
+			}
+		}
+		if chosen != nil {
+			resolved, err := mcpSchema(chosen, defs, expanding, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			out = mergeSchemas(resolved, out)
+		}
+	}
+	if parts, ok := v["allOf"].([]any); ok {
+		for _, p := range parts {
+			pm, _ := p.(map[string]any)
+			if pm == nil {
+				continue
+			}
+			resolved, err := mcpSchema(pm, defs, expanding, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			out = mergeSchemas(out, resolved)
+		}
+	}
+
+	if props, ok := v["properties"].(map[string]any); ok {
+		required, _ := out["required"].([]any)
+		properties, _ := out["properties"].(map[string]any)
+		if properties == nil {
+			properties = map[string]any{}
+		}
+		for name, p := range props {
+			pm, _ := p.(map[string]any)
+			if pm == nil {
+				pm = map[string]any{}
+			}
+			resolved, err := mcpSchema(pm, defs, expanding, depth+1)
+			if err == nil {
+				_, err = jsonSchema(resolved)
+			}
+			if err != nil {
+				if slices.Contains(required, any(name)) {
+					return nil, fmt.Errorf("property %q: %w", name, err)
+				}
+				continue
+			}
+			properties[name] = resolved
+		}
+		out["properties"] = properties
+	}
+	if items, ok := v["items"].(map[string]any); ok {
+		resolved, err := mcpSchema(items, defs, expanding, depth+1)
+		if err != nil {
+			return nil, fmt.Errorf("items: %w", err)
+		}
+		out["items"] = resolved
+	}
+
+	typeName, _ := out["type"].(string)
+	if types, ok := out["type"].([]any); ok {
+		// jsonSchema takes the first type that is not null.
+		for _, t := range types {
+			if name, ok := t.(string); ok && name != "null" {
+				typeName = name
+				break
+			}
+		}
+	}
+	if typeName == "" {
+		switch {
+		case out["properties"] != nil:
+			typeName = "object"
+		case out["items"] != nil:
+			typeName = "array"
+		case out["enum"] != nil:
+			typeName = "string"
+		default:
+			return nil, errors.New("a schema has no type")
+		}
+		out["type"] = typeName
+	}
+	if props, ok := out["properties"].(map[string]any); ok {
+		// Properties left out are no longer required.
+		required, _ := out["required"].([]any)
+		out["required"] = slices.DeleteFunc(slices.Clone(required), func(r any) bool {
+			name, _ := r.(string)
+			return props[name] == nil
+		})
+	}
+	if props, _ := out["properties"].(map[string]any); typeName == "object" && len(props) == 0 && depth > 0 {
+		// The API refuses objects without properties.
+		return nil, errors.New("an object has no properties")
+	}
+	if typeName == "array" && out["items"] == nil {
+		// The API needs the type of the items; strings take any value.
+		out["items"] = map[string]any{"type": "string"}
+	}
+	if enum, ok := out["enum"].([]any); ok && typeName != "string" {
+		// The API only has enums of strings.
+		delete(out, "enum")
+		if len(enum) > 0 {
+			desc, _ := out["description"].(string)
+			out["description"] = strings.TrimSpace(fmt.Sprintf("%s (one of %v)", desc, enum))
+		}
+	}
+	if format, ok := out["format"].(string); ok && !slices.Contains(supportedFormats[typeName], format) {
+		delete(out, "format")
+	}
+	return out, nil
+}
+
+// mergeSchemas returns the schema combining a and b: the properties and
+// required properties of both, and the other keywords of b where both set
+// them.
+func mergeSchemas(a, b map[string]any) map[string]any {
+	out := maps.Clone(a)
+	for key, value := range b {
+		switch key {
+		case "properties":
+			props, _ := out["properties"].(map[string]any)
+			props = maps.Clone(props)
+			if props == nil {
+				props = map[string]any{}
+			}
+			maps.Copy(props, value.(map[string]any))
+			out[key] = props
+		case "required":
+			required, _ := out["required"].([]any)
+			more, _ := value.([]any)
+			out[key] = append(slices.Clone(required), more...)
+		default:
+			out[key] = value
+		}
+	}
+	return out
+}
+
+// qualifiedToolName returns the name of an MCP tool prefixed with its
+// server, as server__tool, which settings and --allowed-tools may name it
+// by whether or not its registered name is prefixed. It returns "" for the
+// other tools.
+func (w *Workspace) qualifiedToolName(name string) string {
+	if w.MCP == nil {
+		return ""
+	}
+	t, ok := w.MCP.Lookup(name)
+	if !ok {
+		return ""
+	}
+	return t.Server + mcpNameSeparator + t.Tool.Name
+}
+
+// mcpNameSeparator joins a server and a tool name in qualified names.
+const mcpNameSeparator = "__"
+
+// listsName reports whether entries list the tool name, by the name the
+// model calls it or, for MCP tools, by its qualified name.
+func (w *Workspace) listsName(entries []string, name string) bool {
+	if listsTool(entries, name) {
+		return true
+	}
+	qualified := w.qualifiedToolName(name)
+	return qualified != "" && qualified != name && listsTool(entries, qualified)
+}
+
+// mcpHandler returns the handler calling the MCP tool t on its server.
+// Calls are confirmed unless the server is trusted.
+func mcpHandler(t mcp.RegisteredTool) handler {
+	return func(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+		if !MCPServers(ws.Settings)[t.Server].Trust {
+			if ws.Confirm == nil && !ws.autoApproved(executeAction) && !ws.allowed(t.Name) {
+				return nil, errors.New("running the tools of MCP servers needs the user's approval, and there is no way to ask for it in this mode")
+			}
+			details, _ := json.MarshalIndent(args, "", "  ")
+			title := fmt.Sprintf("Run %s of MCP server %s?", t.Tool.Name, t.Server)
+			ok, err := ws.confirm(ctx, executeAction, t.Name, title, string(details))
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, errors.New("the user did not approve running the tool")
+			}
+		}
+		result, err := ws.MCP.Call(ctx, t.Name, args)
+		if err != nil {
+			return nil, err
+		}
+		return ws.mcpResponse(result)
+	}
+}
+
+// mcpResponse converts the result of an MCP tool to the response for the
+// model: its text as "output", its images and audio saved to the files
+// listed in "files", its embedded resources and its structured content. A
+// result the tool reports as a failure is an error.
+func (w *Workspace) mcpResponse(result *mcp.CallToolResult) (map[string]any, error) {
+	var (
+		text      []string
+		files     []string
+		resources []any
+	)
+	for _, c := range result.Content {
+		switch c.Type {
+		case "text":
+			text = append(text, c.Text)
+		case "image", "audio":
+			path, err := w.saveMCPContent(c)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, path)
+		case "resource":
+			var resource any
+			if err := json.Unmarshal(c.Resource, &resource); err == nil {
+				resources = append(resources, resource)
+			}
+		}
+	}
+	output := strings.Join(text, "\n")
+	if result.IsError {
+		if output == "" {
+			output = "the tool reported an error"
+		}
+		return nil, errors.New(output)
+	}
+	resp := map[string]any{"output": output}
+	if len(files) > 0 {
+		resp["files"] = files
+	}
+	if len(resources) > 0 {
+		resp["resources"] = resources
+	}
+	if len(result.StructuredContent) > 0 {
+		var structured any
+		if err := json.Unmarshal(result.StructuredContent, &structured); err == nil {
+			resp["structuredContent"] = structured
+		}
+	}
+	return resp, nil
+}
+
+// saveMCPContent saves image or audio content of an MCP tool result to the
+// project's temporary directory and returns its path.
+func (w *Workspace) saveMCPContent(c mcp.Content) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(c.Data)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s data: %w", c.Type, err)
+	}
+	dir, err := config.ProjectTempDir(w.Roots[0])
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "mcp")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	ext := ".bin"
+	if exts, _ := mime.ExtensionsByType(c.MimeType); len(exts) > 0 {
+		ext = exts[0]
+	}
+	f, err := os.CreateTemp(dir, time.Now().Format("20060102-150405-*")+ext)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
@@ -0,0 +1,219 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/mcp"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// fakeMCPServer serves the tools search, which echoes its query, read_file
+// and fail, which reports an error, over the streamable HTTP transport.
+func fakeMCPServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params struct {
+				Name      string         `json:"name"`
+				Arguments map[string]any `json:"arguments"`
+			} `json:"params"`
+		}
+		json.Unmarshal(body, &req)
+		if req.ID == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		var result any
+		switch req.Method {
+		case "initialize":
+			result = map[string]any{"protocolVersion": "2025-06-18", "capabilities": map[string]any{"tools": map[string]any{}}}
+		case "tools/list":
+			result = map[string]any{"tools": []mcp.Tool{
+				{Name: "search", Description: "Searches the docs", InputSchema: json.RawMessage(`{"type":"object","properties":{"query":{"type":"string"}},"required":["query"]}`)},
+				{Name: "read_file", InputSchema: json.RawMessage(`{"type":"object"}`)},
+				{Name: "fail"},
+			}}
+		case "tools/call":
+			switch req.Params.Name {
+			case "fail":
+				result = mcp.CallToolResult{IsError: true, Content: []mcp.Content{{Type: "text", Text: "boom"}}}
+			default:
+				result = mcp.CallToolResult{
+					Content:           []mcp.Content{{Type: "text", Text: req.Params.Name + ": " + req.Params.Arguments["query"].(string)}},
+					StructuredContent: json.RawMessage(`{"hits":1}`),
+				}
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// mcpWorkspace returns a workspace connected to the servers of cfg.
+func mcpWorkspace(t *testing.T, cfg *config.Settings) *Workspace {
+	t.Helper()
+	ws := testWorkspace(t, cfg)
+	ws.MCP = mcp.NewRegistry(ws.reservedToolName)
+	t.Cleanup(func() { ws.MCP.Close() })
+	if err := ws.ConnectMCP(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return ws
+}
+
+func TestMCPTools(t *testing.T) {
+	srv := fakeMCPServer(t)
+	cfg := &config.Settings{
+		MCPServers: map[string]config.MCPServer{
+			"docs": {HTTPURL: srv.URL},
+			// Never connected to, since it is excluded.
+			"off": {HTTPURL: "http://127.0.0.1:1"},
+		},
+		MCP:   &config.MCPSettings{Excluded: []string{"off"}},
+		Tools: &config.ToolsSettings{},
+	}
+	ws := mcpWorkspace(t, cfg)
+	var asked []string
+	ws.Confirm = func(ctx context.Context, title, details string) (bool, error) {
+		asked = append(asked, title)
+		return true, nil
+	}
+
+	decls := map[string]bool{}
+	for _, d := range ws.Declarations() {
+		decls[d.Name] = true
+		if d.Name == "search" && (d.Parameters == nil || d.Parameters.Properties["query"] == nil) {
+			t.Errorf("search declared with %+v, want its query parameter", d.Parameters)
+		}
+	}
+	// read_file is taken by the built-in tool.
+	if !decls["search"] || !decls["docs__read_file"] || !decls["fail"] || !decls[ReadFileToolName] {
+		t.Errorf("declarations = %v, want the server's tools next to the built-in ones", decls)
+	}
+
+	resp := runTool(t, ws, "search", map[string]any{"query": "go"})
+	if resp["output"] != "search: go" || resp["structuredContent"].(map[string]any)["hits"] != float64(1) {
+		t.Errorf("search = %v, want the server's result", resp)
+	}
+	if len(asked) != 1 || asked[0] != "Run search of MCP server docs?" {
+		t.Errorf("asked %q, want to run search", asked)
+	}
+	if resp := runTool(t, ws, "fail", map[string]any{}); resp["error"] != "boom" {
+		t.Errorf("fail = %v, want the tool's error", resp)
+	}
+
+	// Settings name the tools as server__tool.
+	asked = nil
+	cfg.Tools.Allowed = []string{"docs__search"}
+	runTool(t, ws, "search", map[string]any{"query": "go"})
+	if len(asked) != 0 {
+		t.Errorf("asked %q, want docs__search allowed", asked)
+	}
+	cfg.Tools.Exclude = []string{"docs__search"}
+	if ws.Enabled("search") || !ws.Enabled("docs__read_file") {
+		t.Error("want docs__search excluded, and only it")
+	}
+	cfg.Tools.Exclude = nil
+	ws.Allowed = []string{"docs__read_file"}
+	if ws.Enabled("search") || !ws.Enabled("docs__read_file") {
+		t.Error("want only docs__read_file enabled by --allowed-tools")
+	}
+	ws.Allowed = nil
+
+	// The tools of trusted servers run without asking, and the others
+	// cannot run without a way to ask.
+	cfg.Tools.Allowed = nil
+	ws.Confirm = nil
+	if resp := runTool(t, ws, "search", map[string]any{"query": "go"}); resp["error"] == nil {
+		t.Errorf("search = %v, want an error without confirmation", resp)
+	}
+	cfg.MCPServers["docs"] = config.MCPServer{HTTPURL: srv.URL, Trust: true}
+	if resp := runTool(t, ws, "search", map[string]any{"query": "go"}); resp["output"] != "search: go" {
+		t.Errorf("search = %v, want the trusted server's result", resp)
+	}
+}
+
+func TestCheckMCPToolNames(t *testing.T) {
+	servers := map[string]config.MCPServer{"docs": {}}
+	if err := CheckToolNames([]string{"docs__search", ReadFileToolName}, servers); err != nil {
+		t.Error(err)
+	}
+	for _, name := range []string{"other__search", "docs__", "search"} {
+		if err := CheckToolNames([]string{name}, servers); err == nil {
+			t.Errorf("CheckToolNames(%s): want an error", name)
+		}
+	}
+}
+
+// TestMCPSchema checks that input schemas using constructs the API lacks,
+// as generated for many MCP servers, are still declared.
+func TestMCPSchema(t *testing.T) {
+	tool := mcp.RegisteredTool{Name: "create", Server: "tracker", Tool: mcp.Tool{Name: "create", InputSchema: json.RawMessage(`{
+		"type": "object",
+		"$defs": {
+			"Priority": {"type": "string", "enum": ["low", "high"]},
+			"Node": {"type": "object", "properties": {"child": {"$ref": "#/$defs/Node"}}}
+		},
+		"properties": {
+			"title": {"type": "string", "format": "uri"},
+			"priority": {"$ref": "#/$defs/Priority", "description": "How urgent"},
+			"assignee": {"anyOf": [{"type": "string"}, {"type": "null"}]},
+			"kind": {"const": "issue"},
+			"labels": {"type": "array"},
+			"due": {"type": "string", "format": "date-time"},
+			"meta": {"allOf": [{"properties": {"a": {"type": "integer"}}}, {"properties": {"b": {"type": "boolean"}}, "required": ["b"]}]},
+			"anything": {},
+			"tree": {"$ref": "#/$defs/Node"}
+		},
+		"required": ["title", "priority"]
+	}`)}}
+	decl, err := mcpDeclaration(tool)
+	if err != nil {
+		t.Fatalf("mcpDeclaration() failed: %v", err)
+	}
+	props := decl.Parameters.Properties
+	if p := props["title"]; p == nil || p.Format != "" {
+		t.Errorf("title = %+v, want a string without the unsupported format", p)
+	}
+	if p := props["priority"]; p == nil || p.Type != genai.TypeString || len(p.Enum) != 2 || p.Description != "How urgent" {
+		t.Errorf("priority = %+v, want the referenced enum with its own description", p)
+	}
+	if p := props["assignee"]; p == nil || p.Type != genai.TypeString || !p.Nullable {
+		t.Errorf("assignee = %+v, want a nullable string", p)
+	}
+	if p := props["kind"]; p == nil || p.Type != genai.TypeString || len(p.Enum) != 1 || p.Enum[0] != "issue" {
+		t.Errorf("kind = %+v, want a single-value enum", p)
+	}
+	if p := props["labels"]; p == nil || p.Items == nil || p.Items.Type != genai.TypeString {
+		t.Errorf("labels = %+v, want an array of strings", p)
+	}
+	if p := props["due"]; p == nil || p.Format != "date-time" {
+		t.Errorf("due = %+v, want its date-time format", p)
+	}
+	if p := props["meta"]; p == nil || p.Type != genai.TypeObject || len(p.Properties) != 2 || len(p.Required) != 1 {
+		t.Errorf("meta = %+v, want the merged properties", p)
+	}
+	if props["anything"] != nil || props["tree"] != nil {
+		t.Errorf("anything = %+v, tree = %+v, want the undeclarable optional properties left out", props["anything"], props["tree"])
+	}
+	if len(decl.Parameters.Required) != 2 {
+		t.Errorf("required = %v, want title and priority", decl.Parameters.Required)
+	}
+
+	// A required property that cannot be declared leaves the tool out.
+	tool.Tool.InputSchema = json.RawMessage(`{"type": "object", "properties": {"x": {}}, "required": ["x"]}`)
+	if _, err := mcpDeclaration(tool); err == nil {
+		t.Error("mcpDeclaration() with an undeclarable required property: want an error")
+	}
+}
@@ -0,0 +1,161 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/diff"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// SaveMemoryToolName is the name of the tool remembering facts across
+// sessions.
+const SaveMemoryToolName = "save_memory"
+
+// memorySectionHeader heads the facts saved in the user's GEMINI.md, as the
+// Node CLI names it.
+const memorySectionHeader = "## Gemini Added Memories"
+
+var saveMemoryDeclaration = &genai.FunctionDeclaration{
+	Name: SaveMemoryToolName,
+	Description: "Saves a specific fact about the user or their preferences to long-term memory, so that it is known in future sessions. " +
+		"Use it when the user asks you to remember something, or states a clear, concise fact worth keeping, such as their preferred tools. " +
+		"Do not use it for conversational context only relevant to this session.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"fact": {
+				Type:        genai.TypeString,
+				Description: "The fact to remember, as a clear, self-contained statement.",
+			},
+		},
+		Required: []string{"fact"},
+	},
+}
+
+func saveMemory(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	fact, err := stringArg(args, "fact")
+	if err != nil {
+		return nil, err
+	}
+	fact = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(fact), "-"))
+	if fact == "" {
+		return nil, errors.New("fact must not be empty")
+	}
+	path, err := ws.memoryFile()
+	if err != nil {
+		return nil, err
+	}
+	old, _, err := readIfExists(path)
+	if err != nil {
+		return nil, err
+	}
+	content := addMemory(old, fact)
+
+	if ws.Confirm == nil && !ws.autoApproved(editAction) && !ws.allowed(SaveMemoryToolName) {
+		return nil, fmt.Errorf("saving to %s needs the user's approval, and there is no way to ask for it in this mode", path)
+	}
+	ok, err := ws.confirm(ctx, editAction, SaveMemoryToolName, "Save this to memory in "+path+"?", diff.Unified(old, content, 3))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return map[string]any{"applied": false, "message": "The user did not want this remembered."}, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return nil, err
+	}
+	return map[string]any{"applied": true, "message": fmt.Sprintf("Okay, I've remembered that: %q", fact)}, nil
+}
+
+// UserMemory returns the content of the user-level context file that
+// save_memory adds to, or "" if there is none.
+func (w *Workspace) UserMemory() (string, error) {
+	path, err := w.memoryFile()
+	if err != nil {
+		return "", err
+	}
+	content, _, err := readIfExists(path)
+	return content, err
+}
+
+// memoryFile returns the user-level context file facts are saved to,
+// ~/.gemini/GEMINI.md unless context.fileName names another.
+func (w *Workspace) memoryFile() (string, error) {
+	dir, err := config.UserDir()
+	if err != nil {
+		return "", err
+	}
+	name := "GEMINI.md"
+	if c := w.Settings.Context; c != nil {
+		switch v := c.FileName.(type) {
+		case string:
+			if v != "" {
+				name = v
+			}
+		case []any:
+			if len(v) > 0 {
+				if s, ok := v[0].(string); ok && s != "" {
+					name = s
+				}
+			}
+		case []string:
+			if len(v) > 0 && v[0] != "" {
+				name = v[0]
+			}
+		}
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// readIfExists returns the content of the file at path, if it exists.
+func readIfExists(path string) (string, bool, error) {
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return "", false, nil
+	case err != nil:
+		return "", false, err
+	}
+	return string(data), true, nil
+}
+
+// addMemory adds fact as a list item at the end of the memory section of
+// content, creating the section at the end if there is none.
+func addMemory(content, fact string) string {
+	item := "- " + fact
+	start := strings.Index(content, memorySectionHeader)
+	if start < 0 {
+		separator := ""
+		switch {
+		case content == "":
+		case strings.HasSuffix(content, "\n\n"):
+		case strings.HasSuffix(content, "\n"):
+			separator = "\n"
+		default:
+			separator = "\n\n"
+		}
+		return content + separator + memorySectionHeader + "\n" + item + "\n"
+	}
+
+	// The section ends at the next "## " heading.
+	bodyStart := start + len(memorySectionHeader)
+	end := len(content)
+	if i := strings.Index(content[bodyStart:], "\n## "); i >= 0 {
+		end = bodyStart + i + 1
+	}
+	section := strings.TrimRight(content[start:end], "\n\t ")
+	rest := content[end:]
+	if rest != "" {
+		rest = "\n" + rest
+	}
+	return content[:start] + section + "\n" + item + "\n" + rest
+}
@@ -0,0 +1,65 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+func TestAddMemory(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{"empty file", "", "## Gemini Added Memories\n- fact\n"},
+		{"no section", "# Notes\nUse tabs.", "# Notes\nUse tabs.\n\n## Gemini Added Memories\n- fact\n"},
+		{"no section, trailing newline", "# Notes\n", "# Notes\n\n## Gemini Added Memories\n- fact\n"},
+		{"last section", "# Notes\n\n## Gemini Added Memories\n- old\n\n", "# Notes\n\n## Gemini Added Memories\n- old\n- fact\n"},
+		{"section before another", "## Gemini Added Memories\n- old\n\n## Other\ntext\n", "## Gemini Added Memories\n- old\n- fact\n\n## Other\ntext\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := addMemory(tt.content, "fact"); got != tt.want {
+				t.Errorf("addMemory(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveMemory(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	path := filepath.Join(home, ".gemini", "MEMORY.md")
+
+	ws := testWorkspace(t, &config.Settings{Context: &config.ContextSettings{FileName: []any{"MEMORY.md", "GEMINI.md"}}})
+	ws.Confirm = nil
+	resp := runTool(t, ws, SaveMemoryToolName, map[string]any{"fact": "x"})
+	if !strings.Contains(resp["error"].(string), "approval") {
+		t.Errorf("Expected the memory not to be saved without a way to ask, got %v", resp)
+	}
+
+	var details string
+	ws.Confirm = func(ctx context.Context, title, d string) (bool, error) {
+		details = d
+		return true, nil
+	}
+	for _, fact := range []string{"- The user prefers tabs.", "Their name is Sam."} {
+		if resp := runTool(t, ws, SaveMemoryToolName, map[string]any{"fact": fact}); resp["applied"] != true {
+			t.Fatalf("Expected %q to be saved, got %v", fact, resp)
+		}
+	}
+	data, _ := os.ReadFile(path)
+	if want := "## Gemini Added Memories\n- The user prefers tabs.\n- Their name is Sam.\n"; string(data) != want {
+		t.Errorf("%s = %q, want %q", path, data, want)
+	}
+	if memory, err := ws.UserMemory(); err != nil || memory != string(data) {
+		t.Errorf("UserMemory() = %q, %v; want the saved memories", memory, err)
+	}
+	if !strings.Contains(details, "+- Their name is Sam.") {
+		t.Errorf("Expected the change to be shown, got %q", details)
+	}
+}
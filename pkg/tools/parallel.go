@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// maxParallelCalls bounds how many calls of a turn run at the same time.
+const maxParallelCalls = 4
+
+// parallelTools are the tools that only read files or the web without
+// asking the user anything, so that their calls can run at the same time.
+var parallelTools = map[string]bool{
+	ReadFileToolName:      true,
+	ReadManyFilesToolName: true,
+	ListDirectoryToolName: true,
+	InspectFileToolName:   true,
+	PreviewDataToolName:   true,
+	WebSearchToolName:     true,
+}
+
+// parallel reports whether fc can run alongside the other calls of its
+// turn: its tool must only read, and must not need its declaration loaded
+// first.
+func (w *Workspace) parallel(fc *genai.FunctionCall) bool {
+	if !parallelTools[fc.Name] {
+		return false
+	}
+	_, pruned := w.pruned[fc.Name]
+	return !pruned || w.loaded[fc.Name]
+}
+
+// executeParallel executes calls at the same time, at most
+// maxParallelCalls at once, and puts their results in parts, in order.
+func executeParallel(ctx context.Context, ws *Workspace, calls []genai.FunctionCall, parts []genai.Part) {
+	slots := make(chan struct{}, maxParallelCalls)
+	var wg sync.WaitGroup
+	for i := range calls {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			parts[i] = ExecuteToolCall(ctx, ws, &calls[i])
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// barrierSearcher answers searches once n of them are in flight at the same
+// time, and fails those that wait too long for the others.
+type barrierSearcher struct {
+	n       int
+	mu      sync.Mutex
+	waiting int
+	all     chan struct{}
+}
+
+func (s *barrierSearcher) Search(ctx context.Context, query string) (*SearchResult, error) {
+	s.mu.Lock()
+	if s.waiting++; s.waiting == s.n {
+		close(s.all)
+	}
+	s.mu.Unlock()
+	select {
+	case <-s.all:
+		return &SearchResult{Answer: "about " + query}, nil
+	case <-time.After(5 * time.Second):
+		return nil, context.DeadlineExceeded
+	}
+}
+
+func TestExecuteTurnRunsReadsInParallel(t *testing.T) {
+	ws := testWorkspace(t, nil)
+	ws.Searcher = &barrierSearcher{n: 3, all: make(chan struct{})}
+	if err := os.WriteFile(filepath.Join(ws.Roots[0], "in.txt"), []byte("input"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := []genai.FunctionCall{
+		{Name: WebSearchToolName, Args: map[string]any{"query": "a"}},
+		{Name: WebSearchToolName, Args: map[string]any{"query": "b"}},
+		{Name: WebSearchToolName, Args: map[string]any{"query": "c"}},
+		{Name: ReadFileToolName, Args: map[string]any{"path": "in.txt"}},
+		{Name: WriteFileToolName, Args: map[string]any{"path": "out.txt", "content": "done"}},
+	}
+	parts := ExecuteTurn(context.Background(), ws, calls)
+	for i, query := range []string{"a", "b", "c"} {
+		resp := parts[i].(*genai.FunctionResponse)
+		if resp.Name != WebSearchToolName || resp.Response["error"] != nil {
+			t.Fatalf("result %d = %v, want a search result", i, resp.Response)
+		}
+		if answer, _ := resp.Response["answer"].(string); answer != "about "+query {
+			t.Errorf("result %d answers %q, want the answer for %q in order", i, answer, query)
+		}
+	}
+	if resp := parts[3].(*genai.FunctionResponse).Response; resp["content"] != "input" {
+		t.Errorf("read_file = %v, want the file's content", resp)
+	}
+	if data, err := os.ReadFile(filepath.Join(ws.Roots[0], "out.txt")); err != nil || string(data) != "done" {
+		t.Errorf("out.txt = %q, %v, want the write to follow the reads", data, err)
+	}
+}
@@ -0,0 +1,111 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/diff"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// PatchToolName is the name of the tool applying unified diffs.
+const PatchToolName = "apply_patch"
+
+// patchFuzz is the number of context lines at either end of a hunk that may
+// fail to match, as with patch -F2.
+const patchFuzz = 2
+
+var patchDeclaration = &genai.FunctionDeclaration{
+	Name: PatchToolName,
+	Description: "Applies a unified diff (as produced by `diff -u` or `git diff`) to one or more files in the workspace. " +
+		"Use /dev/null as the old name to create a file and as the new name to delete one. " +
+		"Hunks are located even if their line numbers are off, but their context lines must match the current content; the response notes the hunks that applied elsewhere than stated. " +
+		"The user reviews all changes at once; either every file is changed or none is.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"patch": {
+				Type:        genai.TypeString,
+				Description: "The unified diff, with ---/+++ headers for each file followed by its @@ hunks.",
+			},
+		},
+		Required: []string{"patch"},
+	},
+}
+
+func applyPatch(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	text, err := stringArg(args, "patch")
+	if err != nil {
+		return nil, err
+	}
+	patches, err := diff.Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid patch: %w", err)
+	}
+
+	// Apply every hunk to a copy of the turn's staged changes, so that
+	// nothing is staged unless the whole patch applies.
+	staged := ws.tx.clone()
+	var (
+		touched  []*fileChange
+		failures []string
+		notes    []string
+	)
+	for _, p := range patches {
+		c, fileNotes, err := ws.patchFile(p, staged)
+		for _, n := range fileNotes {
+			notes = append(notes, p.Name()+": "+n)
+		}
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		if !slices.Contains(touched, c) {
+			touched = append(touched, c)
+		}
+	}
+	if len(failures) > 0 {
+		return nil, fmt.Errorf("the patch was not applied:\n%s", strings.Join(failures, "\n"))
+	}
+	ws.tx.replace(staged)
+
+	var summary []string
+	for _, c := range touched {
+		summary = append(summary, c.summary())
+	}
+	resp := map[string]any{"applied": true, "files": summary}
+	if len(notes) > 0 {
+		resp["notes"] = notes
+	}
+	return resp, nil
+}
+
+// patchFile applies p on top of the content staged for its file, and
+// returns the notes on hunks that did not apply as stated.
+func (w *Workspace) patchFile(p *diff.FilePatch, tx *Transaction) (*fileChange, []string, error) {
+	name := p.Name()
+	c, err := w.stagedFile(name, tx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	exists := !c.delete && (c.existed || c.new != "")
+	switch {
+	case p.OldName == diff.DevNull && exists:
+		return nil, nil, fmt.Errorf("%s: cannot create the file, it already exists", name)
+	case p.OldName != diff.DevNull && !exists:
+		return nil, nil, fmt.Errorf("%s: no such file", name)
+	}
+	result, notes, err := p.ApplyNotes(c.new, patchFuzz)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.delete = p.NewName == diff.DevNull
+	if c.delete && result != "" {
+		return nil, nil, fmt.Errorf("%s: the deletion does not remove all of the file's content", name)
+	}
+	c.new = result
+	return c, notes, nil
+}
@@ -0,0 +1,123 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+func runPatch(t *testing.T, ws *Workspace, patch string) map[string]any {
+	t.Helper()
+	part := ExecuteToolCall(context.Background(), ws, &genai.FunctionCall{Name: PatchToolName, Args: map[string]any{"patch": patch}})
+	return part.(*genai.FunctionResponse).Response
+}
+
+func TestApplyPatch(t *testing.T) {
+	ws := testWorkspace(t, nil)
+	root := ws.Roots[0]
+	os.WriteFile(filepath.Join(root, "a.txt"), []byte("one\ntwo\nthree\n"), 0644)
+	os.WriteFile(filepath.Join(root, "old.txt"), []byte("bye\n"), 0644)
+
+	var previews []string
+	ws.Confirm = func(ctx context.Context, title, details string) (bool, error) {
+		previews = append(previews, details)
+		return true, nil
+	}
+	patch := "--- a/a.txt\n+++ b/a.txt\n@@ -1,3 +1,3 @@\n one\n-two\n+2\n three\n" +
+		"--- /dev/null\n+++ b/sub/new.txt\n@@ -0,0 +1 @@\n+hello\n" +
+		"--- a/old.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-bye\n"
+	resp := runPatch(t, ws, patch)
+	if resp["applied"] != true {
+		t.Fatalf("Expected the patch to apply, got %v", resp)
+	}
+	if len(previews) != 1 || !strings.Contains(previews[0], "+2") || !strings.Contains(previews[0], "+++ "+filepath.Join("sub", "new.txt")) {
+		t.Errorf("Expected one preview of all files, got %q", previews)
+	}
+	if resp["notes"] != nil {
+		t.Errorf("Expected no notes for hunks that apply as stated, got %v", resp["notes"])
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "a.txt")); string(data) != "one\n2\nthree\n" {
+		t.Errorf("a.txt = %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "sub", "new.txt")); string(data) != "hello\n" {
+		t.Errorf("sub/new.txt = %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(root, "old.txt")); !os.IsNotExist(err) {
+		t.Error("Expected old.txt to be deleted")
+	}
+}
+
+func TestApplyPatchIsAllOrNothing(t *testing.T) {
+	ws := testWorkspace(t, nil)
+	root := ws.Roots[0]
+	os.WriteFile(filepath.Join(root, "a.txt"), []byte("one\ntwo\n"), 0644)
+	confirmed := false
+	ws.Confirm = func(ctx context.Context, title, details string) (bool, error) {
+		confirmed = true
+		return true, nil
+	}
+
+	// The second file does not match, so the first must not be changed.
+	resp := runPatch(t, ws, "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-one\n+1\n"+
+		"--- a/a.txt\n+++ b/a.txt\n@@ -2 +2 @@\n-missing\n+2\n")
+	if msg, _ := resp["error"].(string); !strings.Contains(msg, "hunk 1") || confirmed {
+		t.Errorf("Expected the mismatch to be reported before confirmation, got %v", resp)
+	}
+
+	ws.Confirm = func(ctx context.Context, title, details string) (bool, error) { return false, nil }
+	if resp := runPatch(t, ws, "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-one\n+1\n"); resp["applied"] != false {
+		t.Errorf("Expected a rejected patch not to apply, got %v", resp)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "a.txt")); string(data) != "one\ntwo\n" {
+		t.Errorf("a.txt changed to %q", data)
+	}
+
+	ws.Confirm = nil
+	if resp := runPatch(t, ws, "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-one\n+1\n"); resp["error"] == nil {
+		t.Error("Expected an error without a way to confirm")
+	}
+	if resp := runPatch(t, ws, "--- a/../x.txt\n+++ b/../x.txt\n@@ -0,0 +1 @@\n+x\n"); resp["error"] == nil {
+		t.Error("Expected paths outside the workspace to be rejected")
+	}
+}
+
+func TestCommitChangesRollsBack(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("relies on rename failing over a directory")
+	}
+	ws := testWorkspace(t, nil)
+	root := ws.Roots[0]
+	a := filepath.Join(root, "a.txt")
+	os.WriteFile(a, []byte("old\n"), 0644)
+
+	// Replacing the non-empty directory sub fails after a.txt was written.
+	err := commitChanges(context.Background(), hostFiles{}, []*fileChange{
+		{path: a, rel: "a.txt", existed: true, old: "old\n", new: "new\n", mode: 0644},
+		{path: filepath.Join(root, "created.txt"), rel: "created.txt", new: "x\n", mode: 0644},
+		{path: filepath.Join(root, "sub"), rel: "sub", new: "b\n", mode: 0644},
+	})
+	if err == nil {
+		t.Fatal("Expected replacing a directory to fail")
+	}
+	if data, _ := os.ReadFile(a); string(data) != "old\n" {
+		t.Errorf("Expected a.txt to be restored, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(root, "created.txt")); !os.IsNotExist(err) {
+		t.Error("Expected created.txt to be removed again")
+	}
+}
+
+func TestApplyPatchNotesOffsets(t *testing.T) {
+	ws := testWorkspace(t, nil)
+	os.WriteFile(filepath.Join(ws.Roots[0], "a.txt"), []byte("header\nextra\none\ntwo\n"), 0644)
+
+	resp := runPatch(t, ws, "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+2\n")
+	if notes := stringList(resp["notes"]); len(notes) != 1 || notes[0] != "a.txt: hunk 1 applied at line 3 (offset 2 lines)" {
+		t.Errorf("notes = %q, want the offset of the hunk", notes)
+	}
+}
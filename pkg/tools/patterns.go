@@ -0,0 +1,143 @@
+package tools
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// parsePattern splits a tool list entry such as "run_shell_command(git
+// status)" into the tool name and the command prefix in parentheses, if
+// it has one.
+func parsePattern(entry string) (name, prefix string, ok bool) {
+	name, rest, ok := strings.Cut(entry, "(")
+	if !ok || !strings.HasSuffix(rest, ")") {
+		return entry, "", false
+	}
+	return name, strings.TrimSpace(strings.TrimSuffix(rest, ")")), true
+}
+
+// listsTool reports whether entries list the tool named name, alone or
+// with a command prefix.
+func listsTool(entries []string, name string) bool {
+	return slices.ContainsFunc(entries, func(e string) bool {
+		n, _, _ := parsePattern(e)
+		return n == name
+	})
+}
+
+// commandPrefixes returns the command prefixes of the run_shell_command
+// entries of entries, split into words. Entries for an exact command are
+// not prefixes.
+func commandPrefixes(entries []string) [][]string {
+	var prefixes [][]string
+	for _, e := range entries {
+		if name, prefix, ok := parsePattern(e); ok && name == ShellToolName && prefix != "" {
+			if _, exact := exactCommand(prefix); !exact {
+				prefixes = append(prefixes, strings.Fields(prefix))
+			}
+		}
+	}
+	return prefixes
+}
+
+// exactCommand returns the command of a run_shell_command entry quoted in
+// full, as in run_shell_command("go test ./..."), which answering "always
+// allow" saves: it allows that command only, not those it starts.
+func exactCommand(prefix string) (string, bool) {
+	if !strings.HasPrefix(prefix, `"`) {
+		return "", false
+	}
+	command, err := strconv.Unquote(prefix)
+	return command, err == nil
+}
+
+// AllowsCommand reports whether entries, such as tools.allowed, allow the
+// shell command command: they list run_shell_command alone, or the
+// command in full, quoted or not, or each of the commands it chains starts
+// with the words of a "run_shell_command(git)" entry. Commands that
+// substitute the output of others or redirect to files match no prefix.
+func AllowsCommand(entries []string, command string) bool {
+	return slices.Contains(entries, ShellToolName) || matchesPrefix(entries, command)
+}
+
+// matchesPrefix is AllowsCommand without the entry for the whole tool.
+func matchesPrefix(entries []string, command string) bool {
+	command = strings.TrimSpace(command)
+	for _, e := range entries {
+		if name, prefix, ok := parsePattern(e); ok && name == ShellToolName {
+			if exact, ok := exactCommand(prefix); prefix == command || ok && exact == command {
+				return true
+			}
+		}
+	}
+	prefixes := commandPrefixes(entries)
+	if len(prefixes) == 0 {
+		return false
+	}
+	segments, ok := splitCommands(command)
+	if !ok || len(segments) == 0 {
+		return false
+	}
+	for _, segment := range segments {
+		words := strings.Fields(segment)
+		if !slices.ContainsFunc(prefixes, func(p []string) bool {
+			return len(words) >= len(p) && slices.Equal(words[:len(p)], p)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitCommands splits a shell command line into the commands it chains
+// with ;, &, &&, |, || and newlines, outside quotes. It reports false for
+// command lines too involved to judge by their commands' words: those
+// with command or process substitution, or redirections.
+func splitCommands(line string) ([]string, bool) {
+	var (
+		segments []string
+		current  strings.Builder
+		quote    rune
+		escaped  bool
+	)
+	flush := func() {
+		if s := strings.TrimSpace(current.String()); s != "" {
+			segments = append(segments, s)
+		}
+		current.Reset()
+	}
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			}
+		case r == '`', r == '$' && i+1 < len(runes) && runes[i+1] == '(':
+			return nil, false
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '<' || r == '>':
+			return nil, false
+		case r == ';' || r == '&' || r == '|' || r == '\n':
+			flush()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	if quote != 0 || escaped {
+		return nil, false
+	}
+	flush()
+	return segments, true
+}
@@ -0,0 +1,65 @@
+package tools
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAllowsCommand(t *testing.T) {
+	entries := []string{"run_shell_command(git)", "run_shell_command(npm test)", "run_shell_command(make build && make test)"}
+	for command, want := range map[string]bool{
+		"git status":                true,
+		"  git   log --oneline":     true,
+		"gitk":                      false,
+		"npm test -- --watch":       true,
+		"npm install":               false,
+		"git add . && git commit":   true,
+		"git status; rm -rf /":      false,
+		"git log | less":            false,
+		"git log 'a;b' | git apply": true,
+		"git log $(rm -rf /)":       false,
+		"git log `rm -rf /`":        false,
+		"git log > ~/.bashrc":       false,
+		"git log '>' x":             true,
+		"make build && make test":   true,
+		"make build":                false,
+		"":                          false,
+	} {
+		if got := AllowsCommand(entries, command); got != want {
+			t.Errorf("AllowsCommand(%q) = %v, want %v", command, got, want)
+		}
+	}
+	// Quoted, the entry allows the command alone.
+	exact := []string{`run_shell_command("go test ./...")`, `run_shell_command("echo \"a b\"")`}
+	for command, want := range map[string]bool{
+		"go test ./...":                true,
+		"go test ./... -exec /tmp/bad": false,
+		"go test":                      false,
+		`echo "a b"`:                   true,
+		`echo "a b" c`:                 false,
+	} {
+		if got := AllowsCommand(exact, command); got != want {
+			t.Errorf("AllowsCommand(%q, %q) = %v, want %v", exact, command, got, want)
+		}
+	}
+	if !AllowsCommand([]string{ShellToolName}, "anything $(at all)") {
+		t.Error("The shell tool itself should allow every command")
+	}
+}
+
+func TestSplitCommands(t *testing.T) {
+	for line, want := range map[string][]string{
+		"a && b || c; d | e & f\ng": {"a", "b", "c", "d", "e", "f", "g"},
+		`echo "a; b" 'c && d' e\;f`: {`echo "a; b" 'c && d' e\;f`},
+		`echo '$(x)'`:               {`echo '$(x)'`},
+	} {
+		if got, ok := splitCommands(line); !ok || !slices.Equal(got, want) {
+			t.Errorf("splitCommands(%q) = %q, %v, want %q", line, got, ok, want)
+		}
+	}
+	for _, line := range []string{`echo "$(x)"`, "echo `x`", "cat <(ls)", "echo 'open"} {
+		if _, ok := splitCommands(line); ok {
+			t.Errorf("splitCommands(%q) should give up", line)
+		}
+	}
+}
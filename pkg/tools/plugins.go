@@ -0,0 +1,362 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// pluginsDirName is the directory under ~/.gemini holding tool plugins.
+const pluginsDirName = "tools"
+
+// pluginSchemaTimeout bounds the --schema handshake of a plugin.
+const pluginSchemaTimeout = 10 * time.Second
+
+// pluginNamePattern matches the names the API accepts for functions.
+var pluginNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.-]{0,63}$`)
+
+// Plugin is a tool implemented by an executable in ~/.gemini/tools and
+// named after it, without MCP. Run with --schema, it prints its
+// declaration as JSON:
+//
+//	{"description": "Looks up a ticket", "parameters": {"type": "object",
+//	 "properties": {"id": {"type": "string"}}, "required": ["id"]}}
+//
+// To call it, the CLI runs it in the working directory with the arguments
+// as a JSON object on stdin, once the user approves, and gives the model
+// what it prints: a JSON object, or text as "output". It fails by exiting
+// with a non-zero status, telling why on stderr. Executables run on the
+// host, with the environment of shell commands.
+//
+// Plugins compiled to WebAssembly for WASI, named name.wasm, follow the
+// same protocol in a sandbox instead, without network access and without
+// files unless their schema asks for the workspace with "filesystem":
+// "read" or "write". Those that do not write run without asking.
+type Plugin struct {
+	Path        string
+	declaration *genai.FunctionDeclaration
+	// wasm runs the plugin if it is compiled to WebAssembly.
+	wasm *wasmModule
+}
+
+// PluginsDir returns the directory holding tool plugins, ~/.gemini/tools.
+func PluginsDir() (string, error) {
+	dir, err := config.UserDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, pluginsDirName), nil
+}
+
+// LoadPlugins asks the plugins in ~/.gemini/tools for their declarations
+// and offers them in w. Those that fail to answer are left out, and
+// reported by the error.
+func (w *Workspace) LoadPlugins(ctx context.Context) error {
+	dir, err := PluginsDir()
+	if err != nil {
+		return err
+	}
+	plugins, err := w.loadPlugins(ctx, dir)
+	w.plugins = plugins
+	return err
+}
+
+// loadPlugins loads the plugins in dir, by name.
+func (w *Workspace) loadPlugins(ctx context.Context, dir string) (map[string]*Plugin, error) {
+	paths, err := pluginPaths(dir)
+	if err != nil {
+		return nil, err
+	}
+	wasmOnly := w.Settings.Tools != nil && w.Settings.Tools.Plugins != nil && w.Settings.Tools.Plugins.WASMOnly
+	plugins := map[string]*Plugin{}
+	var errs []error
+	for name, path := range paths {
+		if builtinTool(name) {
+			errs = append(errs, fmt.Errorf("plugin %s: %s is a built-in tool", path, name))
+			continue
+		}
+		p := &Plugin{Path: path}
+		var err error
+		if strings.HasSuffix(path, ".wasm") {
+			p.wasm, err = compileWASM(ctx, path, w.limits(name).MemoryMB)
+		} else if wasmOnly {
+			continue
+		}
+		if err == nil {
+			p.declaration, err = p.schema(ctx, name)
+		}
+		if err != nil {
+			if p.wasm != nil {
+				p.wasm.runtime.Close(ctx)
+			}
+			errs = append(errs, fmt.Errorf("plugin %s: %w", path, err))
+			continue
+		}
+		plugins[name] = p
+	}
+	return plugins, errors.Join(errs...)
+}
+
+// pluginPaths returns the executables and WebAssembly modules in dir by
+// tool name: their file names, less the extension of modules, and of
+// executables on Windows.
+func pluginPaths(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the plugins: %w", err)
+	}
+	paths := map[string]string{}
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if wasmName, ok := strings.CutSuffix(name, ".wasm"); ok {
+			name = wasmName
+		} else if runtime.GOOS == "windows" {
+			ext := filepath.Ext(name)
+			if !slices.Contains([]string{".exe", ".bat", ".cmd", ".com"}, strings.ToLower(ext)) {
+				continue
+			}
+			name = strings.TrimSuffix(name, ext)
+		} else if info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		if pluginNamePattern.MatchString(name) {
+			paths[name] = path
+		}
+	}
+	return paths, nil
+}
+
+// builtinTool reports whether name is the name of a tool of the CLI.
+func builtinTool(name string) bool {
+	_, builtin := builtins[name]
+	_, browser := browserTools[name]
+	_, background := backgroundTools[name]
+	return builtin || browser || background
+}
+
+// schema runs the plugin with --schema and returns the declaration it
+// prints for the tool name.
+func (p *Plugin) schema(ctx context.Context, name string) (*genai.FunctionDeclaration, error) {
+	ctx, cancel := context.WithTimeout(ctx, pluginSchemaTimeout)
+	defer cancel()
+	var out, stderr []byte
+	var err error
+	if p.wasm != nil {
+		out, stderr, err = p.wasm.run(ctx, []string{name, "--schema"}, nil, nil, nil)
+	} else {
+		var errBuf bytes.Buffer
+		cmd := exec.CommandContext(ctx, p.Path, "--schema")
+		cmd.Stderr = &errBuf
+		out, err = cmd.Output()
+		stderr = errBuf.Bytes()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("--schema failed: %w%s", err, stderrSuffix(stderr))
+	}
+	var schema struct {
+		Description string         `json:"description"`
+		Parameters  map[string]any `json:"parameters"`
+		Filesystem  string         `json:"filesystem"`
+	}
+	if err := json.Unmarshal(out, &schema); err != nil {
+		return nil, fmt.Errorf("invalid --schema output: %w", err)
+	}
+	if p.wasm != nil {
+		switch schema.Filesystem {
+		case "", "none":
+		case wasmReadFiles, wasmWriteFiles:
+			p.wasm.filesystem = schema.Filesystem
+		default:
+			return nil, fmt.Errorf("invalid filesystem %q: expected none, read or write", schema.Filesystem)
+		}
+	}
+	if strings.TrimSpace(schema.Description) == "" {
+		return nil, errors.New("the schema has no description")
+	}
+	decl := &genai.FunctionDeclaration{Name: name, Description: schema.Description}
+	if schema.Parameters != nil {
+		if decl.Parameters, err = jsonSchema(schema.Parameters); err != nil {
+			return nil, fmt.Errorf("invalid parameters: %w", err)
+		}
+		if decl.Parameters.Type != genai.TypeObject {
+			return nil, errors.New("invalid parameters: they must be an object")
+		}
+	}
+	return decl, nil
+}
+
+// jsonSchema converts a JSON schema to the subset the API understands.
+func jsonSchema(v map[string]any) (*genai.Schema, error) {
+	s := &genai.Schema{}
+	typeName, _ := v["type"].(string)
+	if types, ok := v["type"].([]any); ok {
+		// ["string", "null"] is a nullable string.
+		for _, t := range types {
+			if t == "null" {
+				s.Nullable = true
+			} else if name, ok := t.(string); ok && typeName == "" {
+				typeName = name
+			}
+		}
+	}
+	if typeName == "" && v["properties"] != nil {
+		typeName = "object"
+	}
+	switch typeName {
+	case "string":
+		s.Type = genai.TypeString
+	case "number":
+		s.Type = genai.TypeNumber
+	case "integer":
+		s.Type = genai.TypeInteger
+	case "boolean":
+		s.Type = genai.TypeBoolean
+	case "array":
+		s.Type = genai.TypeArray
+	case "object":
+		s.Type = genai.TypeObject
+	case "":
+		return nil, errors.New("a schema has no type")
+	default:
+		return nil, fmt.Errorf("unsupported type %q", typeName)
+	}
+	s.Description, _ = v["description"].(string)
+	s.Format, _ = v["format"].(string)
+	if nullable, ok := v["nullable"].(bool); ok {
+		s.Nullable = s.Nullable || nullable
+	}
+	if enum, ok := v["enum"].([]any); ok {
+		for _, e := range enum {
+			s.Enum = append(s.Enum, fmt.Sprint(e))
+		}
+	}
+	if items, ok := v["items"].(map[string]any); ok {
+		var err error
+		if s.Items, err = jsonSchema(items); err != nil {
+			return nil, err
+		}
+	} else if s.Type == genai.TypeArray {
+		return nil, errors.New("an array has no items")
+	}
+	if props, ok := v["properties"].(map[string]any); ok {
+		s.Properties = map[string]*genai.Schema{}
+		for name, p := range props {
+			pm, ok := p.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("property %q is not a schema", name)
+			}
+			ps, err := jsonSchema(pm)
+			if err != nil {
+				return nil, fmt.Errorf("property %q: %w", name, err)
+			}
+			s.Properties[name] = ps
+		}
+	}
+	if required, ok := v["required"].([]any); ok {
+		for _, r := range required {
+			if name, ok := r.(string); ok {
+				s.Required = append(s.Required, name)
+			}
+		}
+	}
+	return s, nil
+}
+
+// pluginDeclarations returns the declarations of the plugins of w.
+func (w *Workspace) pluginDeclarations() []*genai.FunctionDeclaration {
+	var decls []*genai.FunctionDeclaration
+	for _, p := range w.plugins {
+		decls = append(decls, p.declaration)
+	}
+	sort.Slice(decls, func(i, j int) bool { return decls[i].Name < decls[j].Name })
+	return decls
+}
+
+// run calls the plugin with args.
+func (p *Plugin) run(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	name := p.declaration.Name
+	if args == nil {
+		args = map[string]any{}
+	}
+	input, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+	// Sandboxed plugins that cannot change anything need no approval: the
+	// workspace they may read is mounted so that they cannot leave it.
+	if p.wasm == nil || p.wasm.filesystem == wasmWriteFiles {
+		if ws.Confirm == nil && !ws.autoApproved(executeAction) && !ws.allowed(name) {
+			return nil, errors.New("running plugins needs the user's approval, and there is no way to ask for it in this mode")
+		}
+		details, _ := json.MarshalIndent(args, "", "  ")
+		ok, err := ws.confirm(ctx, executeAction, name, fmt.Sprintf("Run the %s plugin?", name), string(details))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errors.New("the user did not approve running the plugin")
+		}
+	}
+
+	env := shellEnv(os.Environ(), ws.envAllowlist(), nil)
+	var stdout, stderr []byte
+	if p.wasm != nil {
+		stdout, stderr, err = p.wasm.run(ctx, []string{name}, input, env, ws.Roots)
+	} else {
+		var outBuf, errBuf bytes.Buffer
+		cmd := exec.CommandContext(ctx, p.Path)
+		cmd.Dir = ws.Roots[0]
+		cmd.Env = env
+		cmd.Stdin = bytes.NewReader(input)
+		cmd.Stdout = &outBuf
+		cmd.Stderr = &errBuf
+		cmd.WaitDelay = time.Second
+		err = cmd.Run()
+		stdout, stderr = outBuf.Bytes(), errBuf.Bytes()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("the plugin failed: %w%s", err, stderrSuffix(stderr))
+	}
+	out := bytes.TrimSpace(stdout)
+	var resp map[string]any
+	if bytes.HasPrefix(out, []byte("{")) && json.Unmarshal(out, &resp) == nil {
+		return resp, nil
+	}
+	return map[string]any{"output": strings.ToValidUTF8(string(out), "�")}, nil
+}
+
+// stderrSuffix returns what a program wrote to stderr to follow an error
+// about it, or "" if it wrote nothing.
+func stderrSuffix(stderr []byte) string {
+	text := strings.TrimSpace(strings.ToValidUTF8(string(stderr), "�"))
+	if text == "" {
+		return ""
+	}
+	return ": " + text
+}
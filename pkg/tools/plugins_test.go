@@ -0,0 +1,196 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// writePlugin writes a shell script plugin to dir.
+func writePlugin(t *testing.T, dir, name, script string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell script plugins")
+	}
+	home := t.TempDir()
+	defer config.SetUserHomeDirForTesting(home, nil)()
+	dir := filepath.Join(home, ".gemini", "tools")
+	os.MkdirAll(dir, 0755)
+	writePlugin(t, dir, "ticket", `if [ "$1" = --schema ]; then
+  echo '{"description": "Looks up a ticket", "parameters": {"type": "object", "properties": {"id": {"type": "string"}, "tags": {"type": "array", "items": {"type": "string"}}}, "required": ["id"]}}'
+  exit
+fi
+printf '{"ticket": %s, "dir": "%s"}' "$(cat)" "$(pwd)"
+`)
+	writePlugin(t, dir, "greet", `[ "$1" = --schema ] && { echo '{"description": "Greets"}'; exit; }
+echo hello; echo oops >&2; [ -z "$FAIL" ]`)
+	writePlugin(t, dir, "broken", `echo 'not json'`)
+	writePlugin(t, dir, ReadFileToolName, `echo '{"description": "shadows"}'`)
+	os.WriteFile(filepath.Join(dir, "README"), []byte("not a plugin"), 0644)
+
+	ws := testWorkspace(t, nil)
+	err := ws.LoadPlugins(t.Context())
+	if err == nil || !strings.Contains(err.Error(), "broken: invalid --schema output") || !strings.Contains(err.Error(), "read_file is a built-in tool") {
+		t.Errorf("LoadPlugins() = %v, want the broken and shadowing plugins reported", err)
+	}
+
+	var ticket *genai.FunctionDeclaration
+	for _, d := range ws.Declarations() {
+		if d.Name == "ticket" {
+			ticket = d
+		}
+		if d.Name == "broken" || d.Name == "README" {
+			t.Errorf("declared %s", d.Name)
+		}
+	}
+	if ticket == nil || ticket.Description != "Looks up a ticket" || ticket.Parameters.Properties["tags"].Items.Type != genai.TypeString ||
+		len(ticket.Parameters.Required) != 1 {
+		t.Fatalf("ticket declaration = %+v", ticket)
+	}
+
+	resp := runTool(t, ws, "ticket", map[string]any{"id": "T-1"})
+	if got, _ := resp["ticket"].(map[string]any); got["id"] != "T-1" || resp["dir"] != ws.Roots[0] {
+		t.Errorf("ticket response = %v", resp)
+	}
+	if resp := runTool(t, ws, "greet", nil); resp["output"] != "hello" {
+		t.Errorf("greet response = %v", resp)
+	}
+
+	ws.Confirm = nil
+	if resp := runTool(t, ws, "greet", nil); !strings.Contains(resp["error"].(string), "approval") {
+		t.Errorf("Expected the plugin to need approval, got %v", resp)
+	}
+	ws.Settings.Tools = &config.ToolsSettings{Allowed: []string{"greet"}}
+	if resp := runTool(t, ws, "greet", nil); resp["output"] != "hello" {
+		t.Errorf("Expected tools.allowed to approve the plugin, got %v", resp)
+	}
+	t.Setenv("FAIL", "1")
+	ws.Settings.Tools.Shell = &config.ShellSettings{EnvAllowlist: []string{"FAIL"}}
+	if resp := runTool(t, ws, "greet", nil); !strings.Contains(resp["error"].(string), "exit status 1: oops") {
+		t.Errorf("Expected the failure with stderr, got %v", resp)
+	}
+
+	if err := CheckToolNames([]string{"greet", ReadFileToolName}, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	s, err := jsonSchema(map[string]any{
+		"properties": map[string]any{
+			"n":    map[string]any{"type": []any{"integer", "null"}, "description": "a count"},
+			"mode": map[string]any{"type": "string", "enum": []any{"fast", "slow"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Type != genai.TypeObject || !s.Properties["n"].Nullable || s.Properties["n"].Type != genai.TypeInteger ||
+		len(s.Properties["mode"].Enum) != 2 {
+		t.Errorf("jsonSchema() = %+v", s)
+	}
+	for _, v := range []map[string]any{{}, {"type": "array"}, {"type": "tuple"}, {"type": "object", "properties": map[string]any{"a": 1}}} {
+		if _, err := jsonSchema(v); err == nil {
+			t.Errorf("jsonSchema(%v): want an error", v)
+		}
+	}
+}
+
+// buildWASMPlugin builds testdata/wasmplugin for WASI.
+func buildWASMPlugin(t *testing.T) []byte {
+	t.Helper()
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("needs the go command to build a WASM plugin")
+	}
+	out := filepath.Join(t.TempDir(), "plugin.wasm")
+	cmd := exec.Command(goTool, "build", "-o", out, "./testdata/wasmplugin")
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm", "CGO_ENABLED=0")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("building the WASM plugin: %v\n%s", err, output)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestWASMPlugins(t *testing.T) {
+	module := buildWASMPlugin(t)
+	home := t.TempDir()
+	defer config.SetUserHomeDirForTesting(home, nil)()
+	dir := filepath.Join(home, ".gemini", "tools")
+	os.MkdirAll(dir, 0755)
+	for _, name := range []string{"reader", "writer", "isolated"} {
+		os.WriteFile(filepath.Join(dir, name+".wasm"), module, 0644)
+	}
+	writePlugin(t, dir, "native", `echo '{"description": "Runs natively"}'`)
+
+	ws := testWorkspace(t, &config.Settings{Tools: &config.ToolsSettings{Plugins: &config.PluginSettings{WASMOnly: true}}})
+	if err := ws.LoadPlugins(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if ws.plugins["native"] != nil {
+		t.Error("Expected tools.plugins.wasmOnly to leave out the executable")
+	}
+	root := ws.Roots[0]
+	os.WriteFile(filepath.Join(root, "a.txt"), []byte("hello"), 0644)
+	asked := 0
+	ws.Confirm = func(ctx context.Context, title, details string) (bool, error) {
+		asked++
+		return true, nil
+	}
+
+	// Plugins that only read run without asking, seeing the workspace.
+	if resp := runTool(t, ws, "reader", map[string]any{"path": "a.txt"}); resp["content"] != "hello" {
+		t.Errorf("reader response = %v", resp)
+	}
+	if resp := runTool(t, ws, "reader", map[string]any{"path": filepath.Join(root, "a.txt"), "content": "changed"}); resp["error"] == nil {
+		t.Errorf("Expected the reader to be refused writing, got %v", resp)
+	}
+	if resp := runTool(t, ws, "isolated", map[string]any{"path": filepath.Join(root, "a.txt")}); resp["error"] == nil {
+		t.Errorf("Expected the isolated plugin to see no files, got %v", resp)
+	}
+	if asked != 0 {
+		t.Errorf("asked %d times about sandboxed plugins that do not write", asked)
+	}
+
+	// Those that write are asked about.
+	if resp := runTool(t, ws, "writer", map[string]any{"path": "b.txt", "content": "written"}); resp["content"] != "written" || asked != 1 {
+		t.Errorf("writer response = %v, asked %d times", resp, asked)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "b.txt")); string(data) != "written" {
+		t.Errorf("b.txt = %q", data)
+	}
+
+	// Nothing outside the workspace can be reached, by .. or by links.
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644)
+	os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(root, "link.txt"))
+	os.Symlink(outside, filepath.Join(root, "linkdir"))
+	for _, path := range []string{"../../etc/passwd", "/etc/passwd", "link.txt", "linkdir/secret.txt"} {
+		if resp := runTool(t, ws, "reader", map[string]any{"path": path}); resp["error"] == nil {
+			t.Errorf("Expected the reader to be refused %s, got %v", path, resp)
+		}
+	}
+	if resp := runTool(t, ws, "writer", map[string]any{"path": "linkdir/new.txt", "content": "x"}); resp["error"] == nil {
+		t.Errorf("Expected the writer to be refused writing through a link, got %v", resp)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "new.txt")); err == nil {
+		t.Error("the writer created a file outside the workspace")
+	}
+}
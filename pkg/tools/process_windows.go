@@ -0,0 +1,21 @@
+//go:build windows
+
+package tools
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// setProcessGroup does nothing on Windows, where taskkill finds the
+// processes a command started by their parent.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills p and the processes it started.
+func killProcessGroup(p *os.Process) error {
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(p.Pid)).Run(); err != nil {
+		return p.Kill()
+	}
+	return nil
+}
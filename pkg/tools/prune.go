@@ -0,0 +1,158 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// prunedHint ends the description of a tool declared without its
+// parameters.
+const prunedHint = " Its parameters are omitted to save context; call it with no arguments to get them."
+
+// declarationBudget returns the number of tokens the tool declarations may
+// take before they are pruned, or 0 for no limit.
+func (w *Workspace) declarationBudget() int {
+	if t := w.Settings.Tools; t != nil {
+		return t.DeclarationBudget
+	}
+	return 0
+}
+
+// prune fits decls into budget tokens. First, every description is cut to
+// its first sentence and parameter descriptions are dropped. If that is not
+// enough, the largest tools are declared without parameters until the rest
+// fits; calling such a tool returns its full declaration instead, and the
+// tool is declared in full from then on. Tools already loaded that way are
+// never pruned.
+func (w *Workspace) prune(decls []*genai.FunctionDeclaration, budget int) []*genai.FunctionDeclaration {
+	w.pruned = nil
+	if budget <= 0 || declarationTokens(decls) <= budget {
+		return decls
+	}
+
+	summarized := make([]*genai.FunctionDeclaration, len(decls))
+	for i, d := range decls {
+		if w.loaded[d.Name] {
+			summarized[i] = d
+			continue
+		}
+		summarized[i] = &genai.FunctionDeclaration{
+			Name:        d.Name,
+			Description: firstSentence(d.Description),
+			Parameters:  withoutDescriptions(d.Parameters),
+		}
+	}
+	total := declarationTokens(summarized)
+	if total <= budget {
+		return summarized
+	}
+
+	// Drop the largest parameter schemas first.
+	order := make([]int, 0, len(summarized))
+	for i, d := range summarized {
+		if d.Parameters != nil && !w.loaded[d.Name] {
+			order = append(order, i)
+		}
+	}
+	size := func(i int) int { return schemaTokens(summarized[i].Parameters) }
+	sort.SliceStable(order, func(a, b int) bool { return size(order[a]) > size(order[b]) })
+	w.pruned = map[string]*genai.FunctionDeclaration{}
+	for _, i := range order {
+		if total <= budget {
+			break
+		}
+		d := summarized[i]
+		total -= size(i) - len(prunedHint)/4
+		w.pruned[d.Name] = decls[i]
+		summarized[i] = &genai.FunctionDeclaration{Name: d.Name, Description: d.Description + prunedHint}
+	}
+	return summarized
+}
+
+// loadDeclaration answers a call of a tool declared without its parameters
+// with its full declaration, which is declared from then on. It returns nil
+// if the tool was declared in full.
+func (w *Workspace) loadDeclaration(fc *genai.FunctionCall) *genai.FunctionResponse {
+	d, ok := w.pruned[fc.Name]
+	if !ok || w.loaded[fc.Name] {
+		return nil
+	}
+	w.loaded[fc.Name] = true
+	return &genai.FunctionResponse{Name: fc.Name, Response: map[string]any{
+		"message":     fmt.Sprintf("The tool was not run. Call %s again with these parameters.", fc.Name),
+		"description": d.Description,
+		"parameters":  schemaValue(d.Parameters),
+	}}
+}
+
+// declarationTokens estimates the tokens decls take in a request, at four
+// characters per token.
+func declarationTokens(decls []*genai.FunctionDeclaration) int {
+	data, _ := json.Marshal(decls)
+	return len(data) / 4
+}
+
+func schemaTokens(s *genai.Schema) int {
+	data, _ := json.Marshal(s)
+	return len(data) / 4
+}
+
+// firstSentence returns text up to the end of its first sentence.
+func firstSentence(text string) string {
+	if i := strings.Index(text, ". "); i >= 0 {
+		return text[:i+1]
+	}
+	return text
+}
+
+// withoutDescriptions returns a copy of s without descriptions.
+func withoutDescriptions(s *genai.Schema) *genai.Schema {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	c.Description = ""
+	c.Items = withoutDescriptions(s.Items)
+	if s.Properties != nil {
+		c.Properties = make(map[string]*genai.Schema, len(s.Properties))
+		for name, p := range s.Properties {
+			c.Properties[name] = withoutDescriptions(p)
+		}
+	}
+	return &c
+}
+
+// schemaValue renders s as JSON Schema for a function response.
+func schemaValue(s *genai.Schema) map[string]any {
+	if s == nil {
+		return map[string]any{"type": "object"}
+	}
+	v := map[string]any{"type": strings.ToLower(strings.TrimPrefix(s.Type.String(), "Type"))}
+	if s.Description != "" {
+		v["description"] = s.Description
+	}
+	if s.Format != "" {
+		v["format"] = s.Format
+	}
+	if len(s.Enum) > 0 {
+		v["enum"] = s.Enum
+	}
+	if s.Items != nil {
+		v["items"] = schemaValue(s.Items)
+	}
+	if len(s.Properties) > 0 {
+		props := map[string]any{}
+		for name, p := range s.Properties {
+			props[name] = schemaValue(p)
+		}
+		v["properties"] = props
+	}
+	if len(s.Required) > 0 {
+		v["required"] = s.Required
+	}
+	return v
+}
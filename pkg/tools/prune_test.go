@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google/generative-ai-go/genai"
+)
+
+func declaration(decls []*genai.FunctionDeclaration, name string) *genai.FunctionDeclaration {
+	for _, d := range decls {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+func TestDeclarationsWithinBudget(t *testing.T) {
+	full := Declarations()
+	total := declarationTokens(full)
+
+	ws := testWorkspace(t, &config.Settings{Tools: &config.ToolsSettings{DeclarationBudget: total}})
+	if got := ws.Declarations(); declaration(got, PatchToolName) != declaration(full, PatchToolName) {
+		t.Error("Expected declarations within the budget to be kept")
+	}
+
+	// Summarizing descriptions is enough for a slightly smaller budget.
+	ws.Settings.Tools.DeclarationBudget = total - 10
+	decls := ws.Declarations()
+	patch := declaration(decls, PatchToolName)
+	if patch.Description != "Applies a unified diff (as produced by `diff -u` or `git diff`) to one or more files in the workspace." {
+		t.Errorf("Description = %q, want its first sentence", patch.Description)
+	}
+	if p := patch.Parameters.Properties["patch"]; p == nil || p.Description != "" || p.Type != genai.TypeString {
+		t.Errorf("Expected the parameter without its description, got %+v", p)
+	}
+	if declaration(full, PatchToolName).Parameters.Properties["patch"].Description == "" {
+		t.Error("Expected the built-in declaration not to be modified")
+	}
+	if declarationTokens(decls) > total-10 {
+		t.Errorf("Declarations take %d tokens, over the budget of %d", declarationTokens(decls), total-10)
+	}
+}
+
+func TestDeclarationsLoadedOnFirstUse(t *testing.T) {
+	ws := testWorkspace(t, &config.Settings{Tools: &config.ToolsSettings{DeclarationBudget: 1}})
+	d := declaration(ws.Declarations(), ReplaceToolName)
+	if d.Parameters != nil || !strings.HasSuffix(d.Description, prunedHint) {
+		t.Fatalf("Expected replace to be declared without parameters, got %+v", d)
+	}
+
+	resp := runTool(t, ws, ReplaceToolName, map[string]any{})
+	params, _ := resp["parameters"].(map[string]any)
+	if props, _ := params["properties"].(map[string]any); props["old_string"] == nil {
+		t.Fatalf("Expected the full parameters, got %v", resp)
+	}
+
+	decls := ws.Declarations()
+	if d := declaration(decls, ReplaceToolName); d.Parameters == nil || d.Parameters.Properties["old_string"].Description == "" {
+		t.Errorf("Expected replace to be declared in full once loaded, got %+v", d)
+	}
+	if d := declaration(decls, PatchToolName); d.Parameters != nil {
+		t.Errorf("Expected other tools to stay pruned, got %+v", d)
+	}
+	if resp := runTool(t, ws, ReplaceToolName, map[string]any{}); resp["parameters"] != nil {
+		t.Errorf("Expected a loaded tool to run, got %v", resp)
+	}
+}
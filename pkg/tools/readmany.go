@@ -0,0 +1,277 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// ReadManyFilesToolName is the name of the tool reading several files at
+// once.
+const ReadManyFilesToolName = "read_many_files"
+
+const (
+	// maxManyFileBytes caps the content of each file read_many_files
+	// returns.
+	maxManyFileBytes = 64 << 10
+	// maxManyTotalBytes caps the content of all files it returns.
+	maxManyTotalBytes = 512 << 10
+)
+
+// skippedDirs are not searched for files matching a pattern.
+var skippedDirs = map[string]bool{".git": true, "node_modules": true, "vendor": true, "dist": true, "build": true, "target": true}
+
+var readManyFilesDeclaration = &genai.FunctionDeclaration{
+	Name: ReadManyFilesToolName,
+	Description: "Reads several text files in one call and returns their contents, each after a `--- path ---` header. " +
+		"Paths may be files, directories, which are read recursively, or glob patterns such as `src/**/*.go`. " +
+		fmt.Sprintf("Binary files are skipped, files are cut off after %s and the whole result after %s; use read_file for the rest.",
+			formatSize(maxManyFileBytes), formatSize(maxManyTotalBytes)),
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"paths": {
+				Type:        genai.TypeArray,
+				Description: "Files, directories or glob patterns, relative to the workspace root or absolute.",
+				Items:       &genai.Schema{Type: genai.TypeString},
+			},
+			"exclude": {
+				Type:        genai.TypeArray,
+				Description: "Glob patterns of files to leave out, such as `**/*_test.go`.",
+				Items:       &genai.Schema{Type: genai.TypeString},
+			},
+		},
+		Required: []string{"paths"},
+	},
+}
+
+func readManyFiles(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	patterns, err := stringListArg(args, "paths")
+	if err != nil {
+		return nil, err
+	}
+	if len(patterns) == 0 {
+		return nil, errors.New("paths must not be empty")
+	}
+	exclude, err := stringListArg(args, "exclude")
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		names   []string
+		seen    = map[string]bool{}
+		skipped []string
+	)
+	for _, p := range patterns {
+		matches, err := ws.expandPath(p)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s (%v)", p, err))
+			continue
+		}
+		if len(matches) == 0 {
+			skipped = append(skipped, p+" (no matches)")
+		}
+		for _, m := range matches {
+			if !seen[m] && !matchesAny(exclude, ws.relative(m)) {
+				seen[m] = true
+				names = append(names, m)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	var (
+		content strings.Builder
+		read    []string
+	)
+	for i, name := range names {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		rel := ws.relative(name)
+		if content.Len() >= maxManyTotalBytes {
+			skipped = append(skipped, fmt.Sprintf("%d more file(s) after the %s limit, starting with %s", len(names)-i, formatSize(maxManyTotalBytes), rel))
+			break
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s (%v)", rel, err))
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			skipped = append(skipped, fmt.Sprintf("%s (%s)", rel, fileKind(info.Mode())))
+			continue
+		}
+		if info.Size() > ws.maxFileSize() {
+			skipped = append(skipped, fmt.Sprintf("%s (%s, over the size limit; use %s)", rel, formatSize(info.Size()), ReadFileToolName))
+			continue
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s (%v)", rel, err))
+			continue
+		}
+		if isBinary(data) {
+			skipped = append(skipped, fmt.Sprintf("%s (binary, %s)", rel, formatSize(info.Size())))
+			continue
+		}
+		text := decodeOutput(data, "")
+		limit := min(maxManyFileBytes, maxManyTotalBytes-content.Len())
+		fmt.Fprintf(&content, "--- %s ---\n%s", rel, truncateText(text, limit))
+		if !strings.HasSuffix(content.String(), "\n") {
+			content.WriteString("\n")
+		}
+		read = append(read, rel)
+	}
+
+	resp := map[string]any{"content": content.String(), "files": read}
+	if len(skipped) > 0 {
+		resp["skipped"] = skipped
+	}
+	return resp, nil
+}
+
+// expandPath returns the files a path of read_many_files names: the file
+// itself, the files in a directory, or the files matching a glob pattern.
+// Every file lies in the workspace.
+func (w *Workspace) expandPath(p string) ([]string, error) {
+	if !strings.ContainsAny(p, "*?[") {
+		resolved, err := w.Resolve(p)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(resolved)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return []string{resolved}, nil
+		}
+		return w.walkFiles(resolved, func(string) bool { return true })
+	}
+
+	pattern := p
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(w.Roots[0], pattern)
+	}
+	pattern = filepath.ToSlash(filepath.Clean(pattern))
+	// Walk from the longest directory without wildcards.
+	base := pattern
+	for strings.ContainsAny(base, "*?[") {
+		base = path.Dir(base)
+	}
+	root, err := w.Resolve(filepath.FromSlash(base))
+	if err != nil {
+		return nil, err
+	}
+	rest := strings.TrimPrefix(strings.TrimPrefix(pattern, base), "/")
+	return w.walkFiles(root, func(rel string) bool { return matchGlob(rest, rel) })
+}
+
+// walkFiles returns the regular files under dir whose slash-separated path
+// relative to dir satisfies match, leaving out hidden and dependency
+// directories.
+func (w *Workspace) walkFiles(dir string, match func(rel string) bool) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if p != dir && (skippedDirs[d.Name()] || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err == nil && match(filepath.ToSlash(rel)) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// relative returns path relative to the working directory, if it is in it.
+func (w *Workspace) relative(p string) string {
+	if rel, err := filepath.Rel(w.Roots[0], p); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return p
+}
+
+// matchGlob reports whether the slash-separated name matches pattern, in
+// which ** matches any number of directories.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchesAny reports whether name matches one of patterns. Patterns without
+// a slash match the base name in any directory.
+func matchesAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		p = filepath.ToSlash(p)
+		if !strings.Contains(p, "/") {
+			p = "**/" + p
+		}
+		if matchGlob(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// stringListArg returns the list of strings argument name, or nil if it is
+// absent.
+func stringListArg(args map[string]any, name string) ([]string, error) {
+	v, ok := args[name]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	list, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("argument %q must be a list of strings", name)
+	}
+	out := make([]string, len(list))
+	for i, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("argument %q must be a list of strings", name)
+		}
+		out[i] = s
+	}
+	return out, nil
+}
@@ -0,0 +1,75 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+func TestReadManyFiles(t *testing.T) {
+	ws := testWorkspace(t, &config.Settings{Tools: &config.ToolsSettings{MaxFileSize: 1000}})
+	root := ws.Roots[0]
+	for name, content := range map[string]string{
+		"main.go":             "package main\n",
+		"pkg/a/a.go":          "package a",
+		"pkg/a/a_test.go":     "package a_test\n",
+		"pkg/b/b.go":          "package b\n",
+		"pkg/b/logo.png":      "\x89PNG\x00",
+		"node_modules/x/x.go": "package x\n",
+		"docs/big.md":         strings.Repeat("x", 2000),
+		"docs/guide/intro.md": "# Intro\n",
+		".hidden/secret.go":   "package secret\n",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp := runTool(t, ws, ReadManyFilesToolName, map[string]any{
+		"paths":   []any{"**/*.go", "main.go", "pkg/b", "docs", "missing/*.txt"},
+		"exclude": []any{"*_test.go"},
+	})
+	if got := strings.Join(stringList(resp["files"]), " "); got != "docs/guide/intro.md main.go pkg/a/a.go pkg/b/b.go" {
+		t.Errorf("files = %s", got)
+	}
+	want := "--- docs/guide/intro.md ---\n# Intro\n--- main.go ---\npackage main\n--- pkg/a/a.go ---\npackage a\n--- pkg/b/b.go ---\npackage b\n"
+	if resp["content"] != want {
+		t.Errorf("content = %q, want %q", resp["content"], want)
+	}
+	skipped := strings.Join(stringList(resp["skipped"]), "\n")
+	for _, s := range []string{"docs/big.md (2.0 KB, over the size limit", "pkg/b/logo.png (binary", "missing/*.txt ("} {
+		if !strings.Contains(skipped, s) {
+			t.Errorf("Expected %q to be skipped, got:\n%s", s, skipped)
+		}
+	}
+
+	os.WriteFile(filepath.Join(filepath.Dir(root), "outside.txt"), []byte("secret\n"), 0644)
+	resp = runTool(t, ws, ReadManyFilesToolName, map[string]any{"paths": []any{"../outside.txt"}})
+	if len(stringList(resp["files"])) > 0 || !strings.HasPrefix(strings.Join(stringList(resp["skipped"]), ""), "../outside.txt (") {
+		t.Errorf("Expected files outside the workspace to be refused, got %v", resp)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "pkg/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "pkg/a/main.go", true},
+		{"pkg/**", "pkg/a/b.txt", true},
+		{"pkg/**/b.txt", "pkg/b.txt", true},
+		{"pkg/*/b.txt", "pkg/a/c/b.txt", false},
+	} {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/diff"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// ReplaceToolName is the name of the tool replacing text in a file.
+const ReplaceToolName = "replace"
+
+var replaceDeclaration = &genai.FunctionDeclaration{
+	Name: ReplaceToolName,
+	Description: "Replaces text in a file in the workspace and returns the resulting diff. " +
+		"Either give old_string, the exact text to replace including whitespace and indentation, with enough surrounding lines to match only once, " +
+		"or give start_line and end_line to replace that range of lines. " +
+		"An empty old_string with no range creates a new file containing new_string. " +
+		"The user reviews the diff before the file is changed.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"path": {
+				Type:        genai.TypeString,
+				Description: "The path of the file, relative to the workspace root or absolute.",
+			},
+			"old_string": {
+				Type:        genai.TypeString,
+				Description: "The exact text to replace.",
+			},
+			"new_string": {
+				Type:        genai.TypeString,
+				Description: "The text to replace it with.",
+			},
+			"expected_replacements": {
+				Type:        genai.TypeInteger,
+				Description: "The number of occurrences of old_string to replace. Defaults to 1; the edit fails if old_string occurs a different number of times.",
+			},
+			"start_line": {
+				Type:        genai.TypeInteger,
+				Description: "The first line (1-based) of the range to replace, instead of old_string.",
+			},
+			"end_line": {
+				Type:        genai.TypeInteger,
+				Description: "The last line of the range to replace, inclusive. Defaults to start_line.",
+			},
+		},
+		Required: []string{"path", "new_string"},
+	},
+}
+
+func replace(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	name, err := stringArg(args, "path")
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, errors.New("path must not be empty")
+	}
+	oldString, err := stringArg(args, "old_string")
+	if err != nil {
+		return nil, err
+	}
+	newString, err := stringArg(args, "new_string")
+	if err != nil {
+		return nil, err
+	}
+	expected, err := intArg(args, "expected_replacements", 1)
+	if err != nil {
+		return nil, err
+	}
+	start, err := intArg(args, "start_line", 0)
+	if err != nil {
+		return nil, err
+	}
+	end, err := intArg(args, "end_line", start)
+	if err != nil {
+		return nil, err
+	}
+
+	staged := ws.tx.clone()
+	c, err := ws.stagedFile(name, staged)
+	if err != nil {
+		return nil, err
+	}
+	exists := !c.delete && (c.existed || c.new != "")
+	before := c.new
+	var after string
+	switch {
+	case start > 0:
+		if !exists {
+			return nil, fmt.Errorf("%s: no such file", name)
+		}
+		if oldString != "" {
+			return nil, errors.New("give either old_string or a line range, not both")
+		}
+		if after, err = replaceLines(before, start, end, newString); err != nil {
+			return nil, err
+		}
+	case oldString == "":
+		if exists {
+			return nil, fmt.Errorf("%s: the file already exists; give old_string or a line range to edit it", name)
+		}
+		after = newString
+	default:
+		if !exists {
+			return nil, fmt.Errorf("%s: no such file", name)
+		}
+		if after, err = replaceString(before, oldString, newString, expected); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	c.new, c.delete = after, false
+	ws.tx.replace(staged)
+	return map[string]any{"applied": true, "files": []string{c.summary()}, "diff": diff.Unified(before, after, 3)}, nil
+}
+
+// replaceString replaces the expected number of occurrences of old in
+// content. Text the model copied from a file with Windows line endings is
+// matched with them.
+func replaceString(content, old, new string, expected int) (string, error) {
+	if expected < 1 {
+		return "", errors.New("expected_replacements must be at least 1")
+	}
+	if !strings.Contains(content, old) && strings.Contains(content, "\r\n") && !strings.Contains(old, "\r\n") {
+		old = strings.ReplaceAll(old, "\n", "\r\n")
+		new = strings.ReplaceAll(new, "\n", "\r\n")
+	}
+	switch n := strings.Count(content, old); {
+	case n == 0:
+		return "", errors.New("old_string was not found; read the file again and copy the text exactly, including whitespace")
+	case n != expected:
+		return "", fmt.Errorf("old_string occurs %d times, but expected_replacements is %d; include more surrounding lines to pick one occurrence", n, expected)
+	}
+	return strings.ReplaceAll(content, old, new), nil
+}
+
+// replaceLines replaces lines start to end (1-based, inclusive) of content
+// with text, which ends with a newline if the replaced lines did.
+func replaceLines(content string, start, end int, text string) (string, error) {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if end < start || end > len(lines) {
+		return "", fmt.Errorf("invalid line range %d-%d; the file has %d lines", start, end, len(lines))
+	}
+	if text != "" && strings.HasSuffix(lines[end-1], "\n") && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return strings.Join(lines[:start-1], "") + text + strings.Join(lines[end:], ""), nil
+}
@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplace(t *testing.T) {
+	ws := testWorkspace(t, nil)
+	root := ws.Roots[0]
+	os.WriteFile(filepath.Join(root, "a.go"), []byte("package a\n\nfunc A() int {\n\treturn 1\n}\n"), 0644)
+	var previews []string
+	ws.Confirm = func(ctx context.Context, title, details string) (bool, error) {
+		previews = append(previews, details)
+		return true, nil
+	}
+
+	resp := runTool(t, ws, ReplaceToolName, map[string]any{"path": "a.go", "old_string": "\treturn 1\n", "new_string": "\treturn 2\n"})
+	if resp["applied"] != true || !strings.Contains(resp["diff"].(string), "-\treturn 1\n+\treturn 2\n") {
+		t.Fatalf("Expected the diff of the replacement, got %v", resp)
+	}
+	if len(previews) != 1 || !strings.Contains(previews[0], "+\treturn 2") {
+		t.Errorf("Expected the change to be previewed, got %q", previews)
+	}
+
+	resp = runTool(t, ws, ReplaceToolName, map[string]any{"path": "a.go", "start_line": 3, "end_line": 5, "new_string": "func A() int { return 3 }"})
+	if resp["applied"] != true {
+		t.Fatalf("Expected the range to be replaced, got %v", resp)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "a.go")); string(data) != "package a\n\nfunc A() int { return 3 }\n" {
+		t.Errorf("a.go = %q", data)
+	}
+
+	resp = runTool(t, ws, ReplaceToolName, map[string]any{"path": "new.txt", "new_string": "hello\n"})
+	if data, _ := os.ReadFile(filepath.Join(root, "new.txt")); string(data) != "hello\n" {
+		t.Errorf("Expected new.txt to be created, got %q (%v)", data, resp)
+	}
+}
+
+func TestReplaceErrors(t *testing.T) {
+	ws := testWorkspace(t, nil)
+	root := ws.Roots[0]
+	os.WriteFile(filepath.Join(root, "a.txt"), []byte("x\nx\ny\n"), 0644)
+	ws.Confirm = func(ctx context.Context, title, details string) (bool, error) {
+		t.Error("Expected nothing to be confirmed")
+		return false, nil
+	}
+
+	tests := []struct {
+		args map[string]any
+		want string
+	}{
+		{map[string]any{"path": "a.txt", "old_string": "z", "new_string": "w"}, "not found"},
+		{map[string]any{"path": "a.txt", "old_string": "x", "new_string": "w"}, "occurs 2 times"},
+		{map[string]any{"path": "a.txt", "new_string": "w"}, "already exists"},
+		{map[string]any{"path": "a.txt", "start_line": 2, "end_line": 4, "new_string": "w"}, "invalid line range"},
+		{map[string]any{"path": "missing.txt", "old_string": "x", "new_string": "w"}, "no such file"},
+	}
+	for _, tt := range tests {
+		resp := runTool(t, ws, ReplaceToolName, tt.args)
+		if msg, _ := resp["error"].(string); !strings.Contains(msg, tt.want) {
+			t.Errorf("replace(%v) = %v, want an error containing %q", tt.args, resp, tt.want)
+		}
+	}
+
+	ws.Confirm = func(ctx context.Context, title, details string) (bool, error) { return true, nil }
+	runTool(t, ws, ReplaceToolName, map[string]any{"path": "a.txt", "old_string": "x", "new_string": "w", "expected_replacements": 2})
+	if data, _ := os.ReadFile(filepath.Join(root, "a.txt")); string(data) != "w\nw\ny\n" {
+		t.Errorf("Expected both occurrences to be replaced, got %q", data)
+	}
+}
@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/index"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// SemanticSearchToolName is the name of the tool querying the semantic index.
+const SemanticSearchToolName = "semantic_search"
+
+const (
+	defaultSearchResults = 8
+	maxSearchResults     = 30
+)
+
+var semanticSearchDeclaration = &genai.FunctionDeclaration{
+	Name: SemanticSearchToolName,
+	Description: "Finds the code and text in the project most relevant to a natural-language description, such as " +
+		"\"where are retries with backoff implemented\", using the project's semantic index. " +
+		"Prefer it to text search when the exact names are unknown. The index is built with `gemini index build`.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"query": {
+				Type:        genai.TypeString,
+				Description: "What to look for, described in words.",
+			},
+			"limit": {
+				Type:        genai.TypeInteger,
+				Description: fmt.Sprintf("The number of snippets to return (default %d, at most %d).", defaultSearchResults, maxSearchResults),
+			},
+		},
+		Required: []string{"query"},
+	},
+}
+
+func semanticSearch(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	query, err := stringArg(args, "query")
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(query) == "" {
+		return nil, errors.New("query must not be empty")
+	}
+	limit, err := intArg(args, "limit", defaultSearchResults)
+	if err != nil {
+		return nil, err
+	}
+	limit = min(max(limit, 1), maxSearchResults)
+	if ws.Embedder == nil {
+		return nil, errors.New("semantic search is not available")
+	}
+
+	ix, err := index.Load(ws.Roots[0])
+	if err != nil {
+		return nil, err
+	}
+	if ix.Model != ws.Embedder.Model() {
+		return nil, fmt.Errorf("the semantic index was built with %s, but %s is configured; rebuild it with `gemini index build`",
+			ix.Model, ws.Embedder.Model())
+	}
+	vector, err := ws.Embedder.EmbedQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []any
+	for _, r := range ix.Search(vector, limit) {
+		results = append(results, map[string]any{
+			"location": fmt.Sprintf("%s:%d-%d", r.Path, r.StartLine, r.EndLine),
+			"score":    fmt.Sprintf("%.3f", r.Score),
+			"content":  r.Text,
+		})
+	}
+	if len(results) == 0 {
+		return map[string]any{"message": "The semantic index is empty."}, nil
+	}
+	return map[string]any{
+		"results": results,
+		"message": fmt.Sprintf("The index was built at %s; files changed since may differ from the snippets.",
+			ix.Built.Format("2006-01-02 15:04")),
+	}, nil
+}
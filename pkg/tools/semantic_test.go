@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/index"
+)
+
+// keywordEmbedder embeds texts by whether they mention "cache".
+type keywordEmbedder struct{ model string }
+
+func (e keywordEmbedder) Model() string { return e.model }
+
+func (e keywordEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	var vectors [][]float32
+	for _, t := range texts {
+		v, _ := e.EmbedQuery(ctx, t)
+		vectors = append(vectors, v)
+	}
+	return vectors, nil
+}
+
+func (e keywordEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	if strings.Contains(text, "cache") {
+		return []float32{1, 0}, nil
+	}
+	return []float32{0, 1}, nil
+}
+
+func TestSemanticSearch(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := testWorkspace(t, nil)
+	root := ws.Roots[0]
+	ws.Embedder = keywordEmbedder{"kw"}
+
+	if resp := runTool(t, ws, SemanticSearchToolName, map[string]any{"query": "cache"}); !strings.Contains(resp["error"].(string), "gemini index build") {
+		t.Errorf("Expected a hint to build the index, got %v", resp)
+	}
+
+	ix := &index.Index{Model: "kw", Chunks: []index.Chunk{
+		{Path: "a.go", StartLine: 1, EndLine: 3, Text: "func render() {}", Vector: []float32{0, 1}},
+		{Path: "sub/b.go", StartLine: 10, EndLine: 20, Text: "type cache struct{}", Vector: []float32{1, 0}},
+	}}
+	if err := ix.Save(root); err != nil {
+		t.Fatal(err)
+	}
+	resp := runTool(t, ws, SemanticSearchToolName, map[string]any{"query": "where is the cache", "limit": float64(1)})
+	results, _ := resp["results"].([]any)
+	if len(results) != 1 || results[0].(map[string]any)["location"] != "sub/b.go:10-20" {
+		t.Errorf("results = %v", resp)
+	}
+
+	ws.Embedder = keywordEmbedder{"other"}
+	if resp := runTool(t, ws, SemanticSearchToolName, map[string]any{"query": "cache"}); resp["error"] == nil {
+		t.Errorf("Expected an index of another model to be refused, got %v", resp)
+	}
+}
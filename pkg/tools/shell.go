@@ -0,0 +1,358 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// ShellToolName is the name of the tool running shell commands.
+const ShellToolName = "run_shell_command"
+
+var shellDeclaration = &genai.FunctionDeclaration{
+	Name: ShellToolName,
+	Description: "Runs a shell command and returns its combined stdout and stderr and its exit code. " +
+		"Use `directory` instead of `cd dir &&` to run the command elsewhere in the workspace. " +
+		"Set `background` for commands that keep running, such as dev servers and watchers: " +
+		"the command is left running after its first output is returned, along with an ID for " +
+		ReadBackgroundOutputToolName + " and " + StopBackgroundProcessToolName + ".",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"command": {
+				Type:        genai.TypeString,
+				Description: "The command to run with bash -c (cmd.exe /c on Windows).",
+			},
+			"description": {
+				Type:        genai.TypeString,
+				Description: "A short description of what the command does, shown to the user.",
+			},
+			"directory": {
+				Type:        genai.TypeString,
+				Description: "Directory to run the command in, relative to the workspace root. Must be inside the workspace.",
+			},
+			"background": {
+				Type:        genai.TypeBoolean,
+				Description: "Whether to leave the command running in the background instead of waiting for it to exit.",
+			},
+			"env": {
+				Type:        genai.TypeArray,
+				Description: "Environment variables to set for the command, as NAME=value.",
+				Items:       &genai.Schema{Type: genai.TypeString},
+			},
+		},
+		Required: []string{"command"},
+	},
+}
+
+// defaultEnvAllowlist are the host environment variables passed to shell
+// commands. Everything else, notably API keys, is withheld unless allowed in
+// tools.shell.envAllowlist. A trailing * matches any suffix.
+var defaultEnvAllowlist = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TERM", "COLORTERM", "LANG", "LANGUAGE", "LC_*", "TZ",
+	"TMPDIR", "TEMP", "TMP",
+	"GOPATH", "GOROOT", "GOCACHE", "GOMODCACHE", "GOFLAGS", "GOPROXY", "GOPRIVATE",
+	"NODE_PATH", "NVM_DIR", "VIRTUAL_ENV", "PYENV_ROOT", "JAVA_HOME", "CARGO_HOME", "RUSTUP_HOME",
+	"SSH_AUTH_SOCK",
+	// Needed for programs to run at all on Windows.
+	"SYSTEMROOT", "SYSTEMDRIVE", "WINDIR", "COMSPEC", "PATHEXT", "USERPROFILE", "APPDATA", "LOCALAPPDATA",
+	"PROGRAMFILES", "PROGRAMFILES(X86)", "PROGRAMDATA",
+}
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func runShellCommand(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	command, err := stringArg(args, "command")
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(command) == "" {
+		return nil, errors.New("command must not be empty")
+	}
+
+	dir := ws.Roots[0]
+	if d, err := stringArg(args, "directory"); err != nil {
+		return nil, err
+	} else if d != "" {
+		if dir, err = ws.Resolve(d); err != nil {
+			return nil, fmt.Errorf("invalid directory: %w", err)
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("invalid directory: %s is not a directory", d)
+		}
+	}
+
+	extra, err := envArg(args["env"])
+	if err != nil {
+		return nil, err
+	}
+	background, err := boolArg(args, "background")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ws.checkCommand(command); err != nil {
+		return nil, err
+	}
+	rel, _ := filepath.Rel(ws.Roots[0], dir)
+	rule := shellRule(command)
+	if len(extra) > 0 {
+		// Variables such as BASH_ENV or LD_PRELOAD can make any command
+		// run anything, so no rule approves a command run with them, and
+		// neither is it offered to be allowed for good.
+		rule = ""
+	}
+	if ws.Confirm == nil && !ws.autoApproved(executeAction) && !ws.allowed(rule) {
+		return nil, errors.New("running commands needs the user's approval, and there is no way to ask for it in this mode")
+	}
+	title := "Run this command?"
+	switch {
+	case background && rel != ".":
+		title = fmt.Sprintf("Run this command in the background in %s?", rel)
+	case background:
+		title = "Run this command in the background?"
+	case rel != ".":
+		title = fmt.Sprintf("Run this command in %s?", rel)
+	}
+	ok, err := ws.confirm(ctx, executeAction, rule, title, shellDetails(command, rel, extra))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("the user did not approve running the command")
+	}
+	// Unless the user approved the command for good, or everything, its
+	// substitutions, chains and redirections are approved one by one.
+	if !ws.autoApproved(executeAction) && !ws.allowed(rule) {
+		if err := ws.confirmConstructs(ctx, command); err != nil {
+			return nil, err
+		}
+	}
+
+	if background {
+		return ws.startBackground(dir, rel, command, extra)
+	}
+	output, exitCode, err := RunShell(ctx, ws, dir, command, extra)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"command":   command,
+		"directory": rel,
+		"output":    output,
+		"exit_code": exitCode,
+	}, shellLimitError(ws.limits(ShellToolName), exitCode, output)
+}
+
+// RunShell runs command in dir, which must already be validated, with the
+// allowlisted host environment plus extra. In the sandbox, the container's
+// environment takes the place of the host's. It returns the combined stdout
+// and stderr as UTF-8 text and the exit code; err is only set if the command
+// could not be run at all. The CPU, memory and output limits of
+// run_shell_command apply. With tools.shell.enableInteractiveShell, the
+// command runs in a pseudo-terminal that ws.ShellStarted is given, where
+// stdout and stderr are one and the same.
+func RunShell(ctx context.Context, ws *Workspace, dir, command string, extra map[string]string) (string, int, error) {
+	limits := ws.limits(ShellToolName)
+	line := command
+	command = ulimitPrefix(limits) + command
+	interactive := ws.interactive()
+	if _, ok := extra["PAGER"]; interactive && !ok {
+		extra = maps.Clone(extra)
+		if extra == nil {
+			extra = map[string]string{}
+		}
+		extra["PAGER"] = ws.pager()
+	}
+	var cmd *exec.Cmd
+	if ws.Sandbox != nil {
+		var err error
+		cmd, err = ws.Sandbox.Command(ctx, dir, shellEnv(nil, nil, extra), "sh", "-c", sandboxShell, "sh", command)
+		if err != nil {
+			return "", 0, err
+		}
+	} else {
+		cmd = shellCommand(ctx, command)
+		cmd.Dir = dir
+		cmd.Env = shellEnv(os.Environ(), ws.envAllowlist(), extra)
+	}
+	// Output beyond the limit would be refused anyway; keeping one byte
+	// more lets the limit notice.
+	out := cappedBuffer{max: limits.MaxOutputBytes + 1}
+	var err error
+	if interactive {
+		// The pipe of other commands fills the buffer with ReadFrom,
+		// which keeps all of the output for the output budget to
+		// truncate; so does the terminal.
+		err = ws.runInTerminal(cmd, line, &out.Buffer)
+	} else {
+		cmd.Stdout = &out
+		cmd.Stderr = &out
+		// Processes the command started in the background may keep the
+		// output open after it was stopped.
+		cmd.WaitDelay = time.Second
+		err = cmd.Run()
+	}
+
+	exitCode := 0
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return "", 0, fmt.Errorf("failed to run command: %w", err)
+		}
+		exitCode = exitErr.ExitCode()
+	}
+	output := ws.shellOutput(out.Bytes())
+	if interactive {
+		// Terminals end lines with CRLF.
+		output = strings.ReplaceAll(output, "\r\n", "\n")
+	}
+	return output, exitCode, nil
+}
+
+// cappedBuffer keeps the first max bytes written to it, or everything if
+// max is not positive, and discards the rest.
+type cappedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.max > 0 && b.Len()+len(p) > b.max {
+		b.Buffer.Write(p[:max(b.max-b.Len(), 0)])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// shellOutput converts command output to UTF-8 text for the model, removing
+// terminal escapes unless tools.shell.showColor is set.
+func (w *Workspace) shellOutput(out []byte) string {
+	var settings config.ShellSettings
+	if t := w.Settings.Tools; t != nil && t.Shell != nil {
+		settings = *t.Shell
+	}
+	text := decodeOutput(out, settings.OutputEncoding)
+	if !settings.ShowColor {
+		text = stripANSI(text)
+	}
+	return text
+}
+
+// sandboxShell runs the command $1 with bash if the sandbox image has it,
+// and with sh otherwise.
+const sandboxShell = `if command -v bash >/dev/null 2>&1; then exec bash -c "$1"; fi; exec sh -c "$1"`
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd.exe", "/c", command)
+	}
+	if _, err := exec.LookPath("bash"); err == nil {
+		return exec.CommandContext(ctx, "bash", "-c", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// shellDetails shows what running command is approving: the command, and
+// the directory and variables it runs with, if any.
+func shellDetails(command, rel string, extra map[string]string) string {
+	details := command
+	if rel != "." {
+		details += "\n\nDirectory: " + rel
+	}
+	if len(extra) > 0 {
+		details += "\n\nEnvironment:"
+		for _, name := range slices.Sorted(maps.Keys(extra)) {
+			details += "\n  " + name + "=" + extra[name]
+		}
+	}
+	return details
+}
+
+// envArg parses the env argument, accepting NAME=value strings or, from
+// models that ignore the schema, an object.
+func envArg(v any) (map[string]string, error) {
+	env := map[string]string{}
+	switch v := v.(type) {
+	case nil:
+	case []any:
+		for _, item := range v {
+			s, ok := item.(string)
+			name, value, found := strings.Cut(s, "=")
+			if !ok || !found {
+				return nil, fmt.Errorf("env entries must be NAME=value strings, got %v", item)
+			}
+			env[name] = value
+		}
+	case map[string]any:
+		for name, value := range v {
+			env[name] = fmt.Sprint(value)
+		}
+	default:
+		return nil, fmt.Errorf("env must be a list of NAME=value strings")
+	}
+	for name := range env {
+		if !envNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid environment variable name %q", name)
+		}
+	}
+	return env, nil
+}
+
+func (w *Workspace) envAllowlist() []string {
+	allow := defaultEnvAllowlist
+	if t := w.Settings.Tools; t != nil && t.Shell != nil {
+		allow = append(allow[:len(allow):len(allow)], t.Shell.EnvAllowlist...)
+	}
+	return allow
+}
+
+// shellEnv returns the allowlisted variables of host, overridden by extra,
+// plus GEMINI_CLI=1 so scripts can tell they are run by the CLI.
+func shellEnv(host, allow []string, extra map[string]string) []string {
+	env := map[string]string{}
+	for _, kv := range host {
+		name, value, _ := strings.Cut(kv, "=")
+		if envAllowed(name, allow) {
+			env[name] = value
+		}
+	}
+	for name, value := range extra {
+		env[name] = value
+	}
+	env["GEMINI_CLI"] = "1"
+
+	out := make([]string, 0, len(env))
+	for name, value := range env {
+		out = append(out, name+"="+value)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func envAllowed(name string, allow []string) bool {
+	for _, pattern := range allow {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if strings.EqualFold(name, pattern) && (runtime.GOOS == "windows" || name == pattern) {
+			return true
+		}
+	}
+	return false
+}
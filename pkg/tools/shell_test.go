@@ -0,0 +1,167 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/pty"
+	"github.com/google/generative-ai-go/genai"
+)
+
+func testWorkspace(t *testing.T, cfg *config.Settings) *Workspace {
+	t.Helper()
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "sub", "pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if cfg == nil {
+		cfg = &config.Settings{}
+	}
+	// Tests that are about confirmations set Confirm themselves.
+	approve := func(context.Context, string, string) (bool, error) { return true, nil }
+	return &Workspace{Roots: []string{root}, Settings: cfg, Confirm: approve}
+}
+
+func runShell(t *testing.T, ws *Workspace, args map[string]any) map[string]any {
+	t.Helper()
+	part := ExecuteToolCall(context.Background(), ws, &genai.FunctionCall{Name: ShellToolName, Args: args})
+	return part.(*genai.FunctionResponse).Response
+}
+
+func TestShellDirectory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	ws := testWorkspace(t, nil)
+
+	resp := runShell(t, ws, map[string]any{"command": "pwd", "directory": "sub/pkg"})
+	if got, want := strings.TrimSpace(resp["output"].(string)), filepath.Join(ws.Roots[0], "sub", "pkg"); got != want {
+		t.Errorf("pwd = %q, want %q", got, want)
+	}
+	if resp["directory"] != filepath.Join("sub", "pkg") {
+		t.Errorf("directory = %v, want sub/pkg", resp["directory"])
+	}
+
+	resp = runShell(t, ws, map[string]any{"command": "exit 3"})
+	if resp["exit_code"] != 3 {
+		t.Errorf("exit_code = %v, want 3", resp["exit_code"])
+	}
+
+	for _, dir := range []string{"..", "/", "missing"} {
+		resp := runShell(t, ws, map[string]any{"command": "pwd", "directory": dir})
+		if _, ok := resp["error"]; !ok {
+			t.Errorf("directory %q: expected an error, got %v", dir, resp)
+		}
+	}
+
+	// A symlink pointing out of the workspace must not be followed.
+	if err := os.Symlink(os.TempDir(), filepath.Join(ws.Roots[0], "escape")); err != nil {
+		t.Fatal(err)
+	}
+	resp = runShell(t, ws, map[string]any{"command": "pwd", "directory": "escape"})
+	if _, ok := resp["error"]; !ok {
+		t.Errorf("expected an error for a symlink escaping the workspace, got %v", resp)
+	}
+}
+
+func TestShellEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	t.Setenv("GEMINI_API_KEY", "secret")
+	t.Setenv("LC_TEST_LOCALE", "C")
+	t.Setenv("MY_TOOL_HOME", "/opt/tool")
+	ws := testWorkspace(t, &config.Settings{Tools: &config.ToolsSettings{
+		Shell: &config.ShellSettings{EnvAllowlist: []string{"MY_TOOL_HOME"}},
+	}})
+
+	resp := runShell(t, ws, map[string]any{
+		"command": `echo "$GEMINI_API_KEY|$LC_TEST_LOCALE|$MY_TOOL_HOME|$GREETING|$GEMINI_CLI|$PATH"`,
+		"env":     []any{"GREETING=hello world"},
+	})
+	fields := strings.Split(strings.TrimSpace(resp["output"].(string)), "|")
+	if len(fields) != 6 {
+		t.Fatalf("unexpected output %q", resp["output"])
+	}
+	want := []string{"", "C", "/opt/tool", "hello world", "1"}
+	for i, w := range want {
+		if fields[i] != w {
+			t.Errorf("field %d = %q, want %q", i, fields[i], w)
+		}
+	}
+	if fields[5] == "" {
+		t.Error("PATH should be passed through")
+	}
+
+	resp = runShell(t, ws, map[string]any{"command": "true", "env": []any{"1BAD=x"}})
+	if _, ok := resp["error"]; !ok {
+		t.Errorf("expected an error for an invalid variable name, got %v", resp)
+	}
+
+	// A command allowed for good is asked about again with variables, which
+	// are shown along with the directory.
+	ws.Settings.Tools.Allowed = []string{shellRule("go test ./...")}
+	var details, rule string
+	ws.Confirm = func(ctx context.Context, title, d string) (bool, error) {
+		details, rule = d, AllowRule(ctx)
+		return false, nil
+	}
+	resp = runShell(t, ws, map[string]any{"command": "go test ./...", "directory": "sub", "env": []any{"BASH_ENV=/tmp/x.sh"}})
+	if _, ok := resp["error"]; !ok {
+		t.Errorf("expected the command with variables to need approval, got %v", resp)
+	}
+	if want := "go test ./...\n\nDirectory: sub\n\nEnvironment:\n  BASH_ENV=/tmp/x.sh"; details != want {
+		t.Errorf("details = %q, want %q", details, want)
+	}
+	if rule != "" {
+		t.Errorf("offered to allow %s for good, want no rule for a command with variables", rule)
+	}
+}
+
+func TestShellInteractive(t *testing.T) {
+	if !pty.Supported() {
+		t.Skip("no pseudo-terminals on this platform")
+	}
+	ws := testWorkspace(t, &config.Settings{Tools: &config.ToolsSettings{Shell: &config.ShellSettings{EnableInteractiveShell: true}}})
+	var session *ShellSession
+	exited := false
+	ws.ShellStarted = func(s *ShellSession) {
+		session = s
+		s.Exited = func() { exited = true }
+		// The user answers the prompt.
+		s.Write([]byte("Ada\n"))
+	}
+
+	resp := runShell(t, ws, map[string]any{"command": `[ -t 0 ] && printf 'Name? ' && read name && echo "Hello, $name" && echo "$PAGER"`})
+	if session == nil || session.Command == "" || !exited {
+		t.Fatalf("session = %+v, exited = %v", session, exited)
+	}
+	if resp["exit_code"] != 0 {
+		t.Fatalf("resp = %v", resp)
+	}
+	output := resp["output"].(string)
+	if !strings.Contains(output, "Hello, Ada\ncat\n") || strings.Contains(output, "\r") {
+		t.Errorf("output = %q", output)
+	}
+	if screen := session.Screen(1); !reflect.DeepEqual(screen, []string{"cat"}) {
+		t.Errorf("Screen(1) = %q", screen)
+	}
+}
+
+func TestShellSessionRecord(t *testing.T) {
+	s := &ShellSession{}
+	s.record([]byte("\x1b[32mone\x1b[0m\r\nprogress 10%"))
+	s.record([]byte("\rprogress 100%\nab\bc"))
+	if got, want := s.Screen(10), []string{"one", "progress 100%", "ac"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Screen = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,248 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/diff"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// SmartEditToolName is the name of the tool replacing text in a file that
+// it locates even if it differs a little from the text given.
+const SmartEditToolName = "smart_edit"
+
+const (
+	// minConfidence is the least similarity to old_string a region needs
+	// to be edited when old_string does not occur exactly.
+	minConfidence = 0.8
+	// ambiguityMargin is how much the best region must be more similar
+	// than any other for the edit to go ahead.
+	ambiguityMargin = 0.05
+)
+
+var smartEditDeclaration = &genai.FunctionDeclaration{
+	Name: SmartEditToolName,
+	Description: "Replaces a region of a file in the workspace and returns the resulting diff, with the confidence that the right region was edited. " +
+		"Unlike replace, old_string need not match exactly: if it does not occur as given, the lines most similar to it are edited, ignoring differences in whitespace and blank lines, " +
+		"and new_string is re-indented to match. Give a few lines of context around the change so that the region is unique. " +
+		"If no region is similar enough, or several are, the edit fails and reports the closest region; read the file and retry with more context. " +
+		"The user reviews the diff before the file is changed.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"path": {
+				Type:        genai.TypeString,
+				Description: "The path of the file, relative to the workspace root or absolute.",
+			},
+			"old_string": {
+				Type:        genai.TypeString,
+				Description: "The text to replace, as close to the file's text as possible.",
+			},
+			"new_string": {
+				Type:        genai.TypeString,
+				Description: "The text to replace it with.",
+			},
+		},
+		Required: []string{"path", "old_string", "new_string"},
+	},
+}
+
+func smartEdit(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	name, err := stringArg(args, "path")
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, errors.New("path must not be empty")
+	}
+	oldString, err := stringArg(args, "old_string")
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(oldString) == "" {
+		return nil, errors.New("old_string must not be empty; use write_file to create a file")
+	}
+	newString, err := stringArg(args, "new_string")
+	if err != nil {
+		return nil, err
+	}
+
+	staged := ws.tx.clone()
+	c, err := ws.stagedFile(name, staged)
+	if err != nil {
+		return nil, err
+	}
+	if c.delete || (!c.existed && c.new == "") {
+		return nil, fmt.Errorf("%s: no such file", name)
+	}
+	before := c.new
+	after, m, err := fuzzyReplace(before, oldString, newString)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	c.new, c.delete = after, false
+	ws.tx.replace(staged)
+	return map[string]any{
+		"applied":    true,
+		"files":      []string{c.summary()},
+		"diff":       diff.Unified(before, after, 3),
+		"match":      m.kind,
+		"confidence": math.Round(m.confidence*100) / 100,
+		"lines":      fmt.Sprintf("%d-%d", m.start+1, m.end),
+	}, nil
+}
+
+// fuzzyMatch is the region of a file an edit replaces: lines start to end
+// (0-based, exclusive), and how similar they are to old_string.
+type fuzzyMatch struct {
+	start, end int
+	confidence float64
+	// kind is "exact" if old_string occurs as given, and "fuzzy" otherwise.
+	kind string
+}
+
+// fuzzyReplace replaces old in content with new. If old does not occur
+// exactly, the lines most similar to it are replaced instead, with new
+// re-indented by the difference in indentation between them.
+func fuzzyReplace(content, old, new string) (string, fuzzyMatch, error) {
+	if n := strings.Count(content, old); n == 1 {
+		start := strings.Count(content[:strings.Index(content, old)], "\n")
+		m := fuzzyMatch{start: start, end: start + strings.Count(strings.TrimSuffix(old, "\n"), "\n") + 1, confidence: 1, kind: "exact"}
+		return strings.Replace(content, old, new, 1), m, nil
+	} else if n > 1 {
+		return "", fuzzyMatch{}, fmt.Errorf("old_string occurs %d times; include more surrounding lines to pick one occurrence", n)
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	oldLines := strings.Split(strings.Trim(old, "\r\n"), "\n")
+	m, err := locate(lines, oldLines)
+	if err != nil {
+		return "", fuzzyMatch{}, err
+	}
+	new = reindent(new, indentation(firstNonBlank(oldLines)), indentation(lines[m.start]))
+	if strings.Contains(content, "\r\n") && !strings.Contains(new, "\r\n") {
+		new = strings.ReplaceAll(new, "\n", "\r\n")
+	}
+	after, err := replaceLines(content, m.start+1, m.end, new)
+	return after, m, err
+}
+
+// locate finds the lines most similar to oldLines. Blank lines are skipped
+// on both sides, and each pair of lines is compared without whitespace.
+func locate(lines, oldLines []string) (fuzzyMatch, error) {
+	var want []bigrams
+	for _, l := range oldLines {
+		if strings.TrimSpace(l) != "" {
+			want = append(want, newBigrams(l))
+		}
+	}
+	var (
+		have    []bigrams
+		indexes []int
+	)
+	for i, l := range lines {
+		if strings.TrimSpace(l) != "" {
+			have = append(have, newBigrams(l))
+			indexes = append(indexes, i)
+		}
+	}
+	if len(want) > len(have) {
+		return fuzzyMatch{}, errors.New("old_string was not found; the file has fewer lines than it")
+	}
+
+	scores := make([]float64, len(have)-len(want)+1)
+	best := 0
+	for i := range scores {
+		for j, w := range want {
+			scores[i] += have[i+j].similarity(w)
+		}
+		scores[i] /= float64(len(want))
+		if scores[i] > scores[best] {
+			best = i
+		}
+	}
+	m := fuzzyMatch{start: indexes[best], end: indexes[best+len(want)-1] + 1, confidence: scores[best], kind: "fuzzy"}
+	if m.confidence < minConfidence {
+		return fuzzyMatch{}, fmt.Errorf("old_string was not found; the closest region, lines %d-%d, matches it with confidence %.2f, below %.2f. Read the file again and retry with its exact text and a few more lines of context",
+			m.start+1, m.end, m.confidence, minConfidence)
+	}
+	for i, score := range scores {
+		if (i <= best-len(want) || i >= best+len(want)) && score > m.confidence-ambiguityMargin {
+			return fuzzyMatch{}, fmt.Errorf("old_string is ambiguous: lines %d-%d and %d-%d match it with confidence %.2f and %.2f; retry with more surrounding lines to pick one",
+				m.start+1, m.end, indexes[i]+1, indexes[i+len(want)-1]+1, m.confidence, score)
+		}
+	}
+	return m, nil
+}
+
+// bigrams are the pairs of adjacent characters of a line without its
+// whitespace, counted, for comparing lines by their Dice
+// coefficient.
+type bigrams struct {
+	line   string
+	counts map[[2]rune]int
+	total  int
+}
+
+func newBigrams(line string) bigrams {
+	b := bigrams{line: strings.Join(strings.Fields(line), ""), counts: map[[2]rune]int{}}
+	runes := []rune(b.line)
+	for i := 1; i < len(runes); i++ {
+		b.counts[[2]rune{runes[i-1], runes[i]}]++
+		b.total++
+	}
+	return b
+}
+
+// similarity is 1 for lines equal but for whitespace, and otherwise the
+// share of bigrams they have in common.
+func (b bigrams) similarity(o bigrams) float64 {
+	if b.line == o.line {
+		return 1
+	}
+	if b.total == 0 || o.total == 0 {
+		return 0
+	}
+	common := 0
+	for k, n := range b.counts {
+		common += min(n, o.counts[k])
+	}
+	return 2 * float64(common) / float64(b.total+o.total)
+}
+
+// reindent moves the lines of text indented with from to be indented with
+// to instead.
+func reindent(text, from, to string) string {
+	if from == to {
+		return text
+	}
+	lines := strings.SplitAfter(text, "\n")
+	for i, l := range lines {
+		if rest, ok := strings.CutPrefix(l, from); ok && strings.TrimSpace(l) != "" {
+			lines[i] = to + rest
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// indentation returns the leading whitespace of line.
+func indentation(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// firstNonBlank returns the first line that is not blank, or "".
+func firstNonBlank(lines []string) string {
+	for _, l := range lines {
+		if strings.TrimSpace(l) != "" {
+			return l
+		}
+	}
+	return ""
+}
@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSmartEdit(t *testing.T) {
+	ws := testWorkspace(t, nil)
+	root := ws.Roots[0]
+	path := filepath.Join(root, "a.go")
+	os.WriteFile(path, []byte("package a\n\nfunc A() int {\n\tx := 1\n\n\treturn x + offset(y)\n}\n"), 0644)
+
+	// Indented with spaces, without the blank line, and misremembered.
+	resp := runTool(t, ws, SmartEditToolName, map[string]any{
+		"path":       "a.go",
+		"old_string": "    x:=1\n    return x+offset(z)\n",
+		"new_string": "    x := 2\n    return x\n",
+	})
+	if resp["applied"] != true || resp["match"] != "fuzzy" || resp["lines"] != "4-6" {
+		t.Fatalf("Expected a fuzzy match of lines 4-6, got %v", resp)
+	}
+	if c, _ := resp["confidence"].(float64); c < minConfidence || c >= 1 {
+		t.Errorf("confidence = %v", resp["confidence"])
+	}
+	if data, _ := os.ReadFile(path); string(data) != "package a\n\nfunc A() int {\n\tx := 2\n\treturn x\n}\n" {
+		t.Errorf("a.go = %q", data)
+	}
+
+	resp = runTool(t, ws, SmartEditToolName, map[string]any{"path": "a.go", "old_string": "x := 2", "new_string": "x := 3"})
+	if resp["match"] != "exact" || resp["confidence"] != 1.0 || resp["lines"] != "4-4" {
+		t.Errorf("Expected an exact match of line 4, got %v", resp)
+	}
+}
+
+func TestSmartEditErrors(t *testing.T) {
+	ws := testWorkspace(t, nil)
+	root := ws.Roots[0]
+	os.WriteFile(filepath.Join(root, "a.txt"), []byte("alpha()\nbeta()\n\nalpha()\nbeta()\ngamma()\n"), 0644)
+
+	tests := []struct {
+		old, want string
+	}{
+		{"delta(1, 2)\nepsilon()", "closest region"},
+		{"alpha()\nbeta()", "occurs 2 times"},
+		{"alpha( )\nbeta( )", "ambiguous"},
+		{"a\nb\nc\nd\ne\nf\ng", "fewer lines"},
+	}
+	for _, tt := range tests {
+		resp := runTool(t, ws, SmartEditToolName, map[string]any{"path": "a.txt", "old_string": tt.old, "new_string": "x"})
+		if msg, _ := resp["error"].(string); !strings.Contains(msg, tt.want) {
+			t.Errorf("smart_edit(%q) = %v, want an error containing %q", tt.old, resp, tt.want)
+		}
+	}
+}
+
+func TestReindent(t *testing.T) {
+	if got := reindent("    a\n      b\n\nc\n", "    ", "\t"); got != "\ta\n\t  b\n\nc\n" {
+		t.Errorf("reindent = %q", got)
+	}
+}
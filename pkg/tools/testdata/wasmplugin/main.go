@@ -0,0 +1,40 @@
+// Command wasmplugin is a tool plugin for the tests of WASM plugins, built
+// with GOOS=wasip1 GOARCH=wasm. It reads the file it is given, and writes
+// it if its arguments have content. Installed as reader or writer, it asks
+// for the workspace to read or write it; otherwise, for no files.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "--schema" {
+		filesystem := map[string]string{"reader": "read", "writer": "write"}[os.Args[0]]
+		fmt.Printf(`{"description": "Reads or writes a file", "filesystem": %q,
+"parameters": {"type": "object", "properties": {"path": {"type": "string"}, "content": {"type": "string"}}}}`, filesystem)
+		return
+	}
+	var args struct {
+		Path    string  `json:"path"`
+		Content *string `json:"content"`
+	}
+	if err := json.NewDecoder(os.Stdin).Decode(&args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if args.Content != nil {
+		if err := os.WriteFile(args.Path, []byte(*args.Content), 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	data, err := os.ReadFile(args.Path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	json.NewEncoder(os.Stdout).Encode(map[string]string{"content": string(data)})
+}
@@ -0,0 +1,130 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// WriteTodosToolName is the name of the tool keeping the plan of a
+// multi-step task.
+const WriteTodosToolName = "write_todos"
+
+// TodoStatus is how far a step of the plan is.
+type TodoStatus string
+
+const (
+	TodoPending    TodoStatus = "pending"
+	TodoInProgress TodoStatus = "in_progress"
+	TodoCompleted  TodoStatus = "completed"
+	TodoCancelled  TodoStatus = "cancelled"
+)
+
+// Todo is a step of the plan write_todos keeps.
+type Todo struct {
+	Description string
+	Status      TodoStatus
+}
+
+// Done reports whether the step needs no more work.
+func (t Todo) Done() bool {
+	return t.Status == TodoCompleted || t.Status == TodoCancelled
+}
+
+var writeTodosDeclaration = &genai.FunctionDeclaration{
+	Name: WriteTodosToolName,
+	Description: "Writes the plan of the current task as a list of steps, replacing the previous list, so that the user can follow your progress. " +
+		"Use it for tasks of three or more steps: write the plan before starting, then call it again whenever a step starts, is completed or is dropped. " +
+		"Only one step may be in progress at a time. Do not use it for simple requests.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"todos": {
+				Type:        genai.TypeArray,
+				Description: "The whole plan, in order.",
+				Items: &genai.Schema{
+					Type: genai.TypeObject,
+					Properties: map[string]*genai.Schema{
+						"description": {Type: genai.TypeString, Description: "What the step does."},
+						"status": {
+							Type:        genai.TypeString,
+							Description: "How far the step is.",
+							Enum:        []string{string(TodoPending), string(TodoInProgress), string(TodoCompleted), string(TodoCancelled)},
+						},
+					},
+					Required: []string{"description", "status"},
+				},
+			},
+		},
+		Required: []string{"todos"},
+	},
+}
+
+func writeTodos(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	todos, err := todosArg(args["todos"])
+	if err != nil {
+		return nil, err
+	}
+	if ws.TodosChanged != nil {
+		ws.TodosChanged(todos)
+	}
+	done := 0
+	for _, t := range todos {
+		if t.Done() {
+			done++
+		}
+	}
+	return map[string]any{
+		"message": fmt.Sprintf("Updated the plan: %d of %d steps done.", done, len(todos)),
+		"plan":    FormatTodos(todos),
+	}, nil
+}
+
+// todosArg parses the todos argument of write_todos.
+func todosArg(v any) ([]Todo, error) {
+	list, ok := v.([]any)
+	if !ok {
+		return nil, errors.New("todos must be a list")
+	}
+	todos := make([]Todo, 0, len(list))
+	inProgress := 0
+	for i, item := range list {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("todo %d must be an object", i+1)
+		}
+		description, _ := m["description"].(string)
+		if strings.TrimSpace(description) == "" {
+			return nil, fmt.Errorf("todo %d needs a description", i+1)
+		}
+		status, _ := m["status"].(string)
+		switch TodoStatus(status) {
+		case TodoPending, TodoCompleted, TodoCancelled:
+		case TodoInProgress:
+			inProgress++
+		default:
+			return nil, fmt.Errorf("todo %d has the invalid status %q (want pending, in_progress, completed or cancelled)", i+1, status)
+		}
+		todos = append(todos, Todo{Description: strings.TrimSpace(description), Status: TodoStatus(status)})
+	}
+	if inProgress > 1 {
+		return nil, fmt.Errorf("only one todo may be in progress, not %d", inProgress)
+	}
+	return todos, nil
+}
+
+// FormatTodos renders a plan as a checklist, e.g. "[x] Write the tests".
+func FormatTodos(todos []Todo) string {
+	var b strings.Builder
+	for i, t := range todos {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		mark := map[TodoStatus]string{TodoPending: "[ ]", TodoInProgress: "[>]", TodoCompleted: "[x]", TodoCancelled: "[-]"}[t.Status]
+		fmt.Fprintf(&b, "%s %s", mark, t.Description)
+	}
+	return b.String()
+}
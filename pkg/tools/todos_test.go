@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteTodos(t *testing.T) {
+	ws := testWorkspace(t, nil)
+	var got []Todo
+	ws.TodosChanged = func(todos []Todo) { got = todos }
+
+	resp := runTool(t, ws, WriteTodosToolName, map[string]any{"todos": []any{
+		map[string]any{"description": "Read the code", "status": "completed"},
+		map[string]any{"description": " Write the fix ", "status": "in_progress"},
+		map[string]any{"description": "Run the tests", "status": "pending"},
+	}})
+	want := []Todo{
+		{"Read the code", TodoCompleted},
+		{"Write the fix", TodoInProgress},
+		{"Run the tests", TodoPending},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TodosChanged got %v, want %v", got, want)
+	}
+	if msg, _ := resp["message"].(string); msg != "Updated the plan: 1 of 3 steps done." {
+		t.Errorf("message = %q", msg)
+	}
+	if plan, _ := resp["plan"].(string); plan != "[x] Read the code\n[>] Write the fix\n[ ] Run the tests" {
+		t.Errorf("plan = %q", plan)
+	}
+}
+
+func TestWriteTodos_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		todos any
+		want  string
+	}{
+		{"not a list", "x", "must be a list"},
+		{"no description", []any{map[string]any{"description": " ", "status": "pending"}}, "needs a description"},
+		{"bad status", []any{map[string]any{"description": "a", "status": "done"}}, "invalid status"},
+		{"two in progress", []any{
+			map[string]any{"description": "a", "status": "in_progress"},
+			map[string]any{"description": "b", "status": "in_progress"},
+		}, "only one todo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := testWorkspace(t, nil)
+			ws.TodosChanged = func([]Todo) { t.Error("TodosChanged called for an invalid plan") }
+			resp := runTool(t, ws, WriteTodosToolName, map[string]any{"todos": tt.todos})
+			if msg, _ := resp["error"].(string); !strings.Contains(msg, tt.want) {
+				t.Errorf("error = %q, want it to contain %q", msg, tt.want)
+			}
+		})
+	}
+}
@@ -1,20 +1,259 @@
 package tools
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/google-gemini/gemini-cli-go/pkg/mcp"
+	"github.com/google-gemini/gemini-cli-go/pkg/usage"
 	"github.com/google/generative-ai-go/genai"
 )
 
-// ExecuteToolCall executes a function call and returns the result.
-// This is a placeholder and will be expanded to handle real tools.
-func ExecuteToolCall(fc *genai.FunctionCall) genai.Part {
-	// Print debug information to stderr to avoid interfering with stdout.
-	fmt.Fprintf(os.Stderr, "Executing tool: %s with args: %v\n", fc.Name, fc.Args)
-	// For now, just return a dummy response.
-	return &genai.FunctionResponse{
-		Name:     fc.Name,
-		Response: map[string]any{"status": "ok", "message": "tool executed successfully"},
-	}
-}
\ No newline at end of file
+// handler runs a tool with the arguments of a function call and returns the
+// response for the model.
+type handler func(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error)
+
+// builtins are the tools implemented by the CLI, by name.
+var builtins = map[string]struct {
+	declaration *genai.FunctionDeclaration
+	run         handler
+}{
+	ShellToolName:          {shellDeclaration, runShellCommand},
+	PatchToolName:          {patchDeclaration, applyPatch},
+	WriteFileToolName:      {writeFileDeclaration, writeFile},
+	ReplaceToolName:        {replaceDeclaration, replace},
+	SmartEditToolName:      {smartEditDeclaration, smartEdit},
+	ReadFileToolName:       {readFileDeclaration, readFile},
+	ReadManyFilesToolName:  {readManyFilesDeclaration, readManyFiles},
+	ListDirectoryToolName:  {listDirectoryDeclaration, listDirectory},
+	InspectFileToolName:    {inspectFileDeclaration, inspectFile},
+	PreviewDataToolName:    {previewDataDeclaration, previewData},
+	HTTPRequestToolName:    {httpRequestDeclaration, httpRequest},
+	WebFetchToolName:       {webFetchDeclaration, webFetch},
+	WebSearchToolName:      {webSearchDeclaration, webSearch},
+	SaveMemoryToolName:     {saveMemoryDeclaration, saveMemory},
+	WriteTodosToolName:     {writeTodosDeclaration, writeTodos},
+	FindDefinitionToolName: {findDefinitionDeclaration, findDefinition},
+	FindReferencesToolName: {findReferencesDeclaration, findReferences},
+	RenameSymbolToolName:   {renameSymbolDeclaration, renameSymbol},
+	SemanticSearchToolName: {semanticSearchDeclaration, semanticSearch},
+}
+
+// Declarations returns the function declarations of the built-in tools.
+func Declarations() []*genai.FunctionDeclaration {
+	var decls []*genai.FunctionDeclaration
+	for _, b := range builtins {
+		decls = append(decls, b.declaration)
+	}
+	sort.Slice(decls, func(i, j int) bool { return decls[i].Name < decls[j].Name })
+	return decls
+}
+
+// Declarations returns the function declarations of the tools available in
+// w: the built-in tools, the browser tools if they are enabled, the
+// background process tools if commands can run in the background and the
+// plugins LoadPlugins loaded, less those Enabled rules out. They are
+// pruned to fit tools.declarationBudget, if set.
+func (w *Workspace) Declarations() []*genai.FunctionDeclaration {
+	decls := Declarations()
+	if w.Browser != nil {
+		for _, b := range browserTools {
+			decls = append(decls, b.declaration)
+		}
+	}
+	if w.Processes != nil {
+		for _, b := range backgroundTools {
+			decls = append(decls, b.declaration)
+		}
+	}
+	decls = append(decls, w.pluginDeclarations()...)
+	decls = append(decls, w.mcpDeclarations()...)
+	sort.Slice(decls, func(i, j int) bool { return decls[i].Name < decls[j].Name })
+	decls = slices.DeleteFunc(decls, func(d *genai.FunctionDeclaration) bool { return !w.Enabled(d.Name) })
+	return w.prune(decls, w.declarationBudget())
+}
+
+// Tool returns the tools available in w as they are declared to the model.
+func (w *Workspace) Tool() *genai.Tool {
+	return &genai.Tool{FunctionDeclarations: w.Declarations()}
+}
+
+// ExecuteToolCall executes a function call and returns the result. Failures
+// are reported to the model in the response rather than returned. File
+// changes are committed before it returns, unless it runs as part of
+// ExecuteTurn.
+func ExecuteToolCall(ctx context.Context, ws *Workspace, fc *genai.FunctionCall) genai.Part {
+	if ws.tx == nil {
+		return ExecuteTurn(ctx, ws, []genai.FunctionCall{*fc})[0]
+	}
+
+	if !ws.Enabled(fc.Name) {
+		return errorResponse(fc.Name, fmt.Errorf("the %s tool is disabled in this environment", fc.Name))
+	}
+	if resp := ws.loadDeclaration(fc); resp != nil {
+		return resp
+	}
+
+	b, ok := builtins[fc.Name]
+	if !ok && ws.Browser != nil {
+		b, ok = browserTools[fc.Name]
+	}
+	if !ok && ws.Processes != nil {
+		b, ok = backgroundTools[fc.Name]
+	}
+	if p := ws.plugins[fc.Name]; !ok && p != nil {
+		b.declaration, b.run, ok = p.declaration, p.run, true
+	}
+	if ws.MCP != nil && !ok {
+		var t mcp.RegisteredTool
+		if t, ok = ws.MCP.Lookup(fc.Name); ok {
+			b.run = mcpHandler(t)
+		}
+	}
+	if !ok {
+		return errorResponse(fc.Name, fmt.Errorf("unknown tool %q", fc.Name))
+	}
+	start := time.Now()
+	cached, cache := ws.cachedResponse(fc)
+	if cached != nil {
+		ws.recordUsage(fc, cached, nil, time.Since(start))
+		return &genai.FunctionResponse{Name: fc.Name, Response: cached}
+	}
+	resp, err := runLimited(ctx, ws, b.run, fc.Args, ws.limits(fc.Name))
+	ws.recordUsage(fc, resp, err, time.Since(start))
+	var limitErr *limitError
+	if errors.As(err, &limitErr) {
+		return &genai.FunctionResponse{Name: fc.Name, Response: plainValue(limitErr.response(fc.Name, resp)).(map[string]any)}
+	}
+	if err != nil {
+		return errorResponse(fc.Name, err)
+	}
+	response := plainValue(resp).(map[string]any)
+	if cache != nil {
+		cache(response)
+	}
+	return &genai.FunctionResponse{Name: fc.Name, Response: response}
+}
+
+// recordUsage records a tool call for /insights. Shell commands that exit
+// with a non-zero status count as failures, with the end of their output as
+// the error.
+func (w *Workspace) recordUsage(fc *genai.FunctionCall, resp map[string]any, err error, d time.Duration) {
+	if w.Usage == nil {
+		return
+	}
+	e := usage.Event{Time: time.Now(), Tool: fc.Name, Args: usage.ArgsKey(fc.Args), DurationMS: d.Milliseconds()}
+	if fc.Name == ShellToolName {
+		command, _ := stringArg(fc.Args, "command")
+		e.Program = shellProgram(command)
+	}
+	switch {
+	case err != nil:
+		e.Error = err.Error()
+	case resp["exit_code"] != nil && resp["exit_code"] != 0:
+		output, _ := resp["output"].(string)
+		output = strings.TrimSpace(output)
+		e.Error = fmt.Sprintf("exit code %v: %s", resp["exit_code"], strings.ToValidUTF8(output[max(len(output)-200, 0):], ""))
+	}
+	w.Usage.Record(e)
+}
+
+// shellProgram returns the name of the program a command line starts with,
+// skipping variable assignments.
+func shellProgram(command string) string {
+	for _, word := range strings.Fields(command) {
+		if strings.Contains(word, "=") {
+			continue
+		}
+		return filepath.Base(word)
+	}
+	return ""
+}
+
+func errorResponse(name string, err error) *genai.FunctionResponse {
+	return &genai.FunctionResponse{Name: name, Response: map[string]any{"error": err.Error()}}
+}
+
+// stringArg returns the string argument name, or "" if it is absent.
+func stringArg(args map[string]any, name string) (string, error) {
+	v, ok := args[name]
+	if !ok || v == nil {
+		return "", nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("argument %q must be a string", name)
+	}
+	return s, nil
+}
+
+// boolArg returns the boolean argument name, or false if it is absent.
+func boolArg(args map[string]any, name string) (bool, error) {
+	v, ok := args[name]
+	if !ok || v == nil {
+		return false, nil
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("argument %q must be a boolean", name)
+	}
+	return b, nil
+}
+
+// intArg returns the integer argument name, or def if it is absent. JSON
+// numbers arrive as float64.
+func intArg(args map[string]any, name string, def int) (int, error) {
+	v, ok := args[name]
+	if !ok || v == nil {
+		return def, nil
+	}
+	switch n := v.(type) {
+	case float64:
+		if n == float64(int(n)) {
+			return int(n), nil
+		}
+	case int:
+		return n, nil
+	case int64:
+		return int(n), nil
+	}
+	return 0, fmt.Errorf("argument %q must be an integer", name)
+}
+
+// plainValue converts typed slices and maps in a response, such as a
+// []string, to []any and map[string]any, the only collections the API
+// client can encode; it panics on others.
+func plainValue(v any) any {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice:
+		if _, ok := v.([]byte); ok {
+			return v
+		}
+		if rv.IsNil() {
+			return nil
+		}
+		out := make([]any, rv.Len())
+		for i := range out {
+			out[i] = plainValue(rv.Index(i).Interface())
+		}
+		return out
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return v
+		}
+		out := make(map[string]any, rv.Len())
+		for it := rv.MapRange(); it.Next(); {
+			out[it.Key().String()] = plainValue(it.Value().Interface())
+		}
+		return out
+	}
+	return v
+}
@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/browser"
+	"github.com/google-gemini/gemini-cli-go/pkg/usage"
+)
+
+func TestPlainValue(t *testing.T) {
+	got := plainValue(map[string]any{"list": []string{"a"}, "none": []string(nil), "nested": map[string][]int{"n": {1}}, "n": 1})
+	want := map[string]any{"list": []any{"a"}, "none": nil, "nested": map[string]any{"n": []any{1}}, "n": 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("plainValue = %#v, want %#v", got, want)
+	}
+}
+
+func TestBrowserToolsNeedTheFlag(t *testing.T) {
+	ws := testWorkspace(t, nil)
+	if resp := runTool(t, ws, BrowserNavigateToolName, map[string]any{"url": "http://localhost"}); resp["error"] != `unknown tool "browser_navigate"` {
+		t.Errorf("Expected the browser tools to be unavailable, got %v", resp)
+	}
+	if len(ws.Declarations()) != len(Declarations()) {
+		t.Error("Expected no browser tools to be declared")
+	}
+
+	ws.Browser = browser.New(nil)
+	var names []string
+	for _, d := range ws.Declarations() {
+		names = append(names, d.Name)
+	}
+	if !slices.Contains(names, BrowserScreenshotToolName) {
+		t.Errorf("Expected the browser tools to be declared, got %q", names)
+	}
+}
+
+func TestExecuteToolCallRecordsUsage(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	t.Setenv("HOME", t.TempDir())
+	ws := testWorkspace(t, nil)
+	rec, err := usage.NewRecorder(ws.Roots[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.Usage = rec
+
+	runTool(t, ws, ShellToolName, map[string]any{"command": "LC_ALL=C definitely-not-a-command --version"})
+	runTool(t, ws, ReadFileToolName, map[string]any{"path": "missing.txt"})
+	runTool(t, ws, ShellToolName, map[string]any{"command": "true"})
+
+	events, err := usage.Load(ws.Roots[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("events = %+v", events)
+	}
+	if e := events[0]; e.Program != "definitely-not-a-command" || !strings.Contains(e.Error, "exit code 127") {
+		t.Errorf("shell event = %+v", e)
+	}
+	if !events[1].Failed() || events[2].Failed() {
+		t.Errorf("events = %+v", events)
+	}
+}
@@ -0,0 +1,351 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/checkpoint"
+	"github.com/google-gemini/gemini-cli-go/pkg/diff"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// Transaction stages the file changes of one model turn. They are shown in
+// a single confirmation and applied all or nothing when the turn's tool
+// calls have run.
+type Transaction struct {
+	changes []*fileChange
+	// staged counts replace calls, so that a turn can tell which tool calls
+	// changed files.
+	staged int
+}
+
+// fileChange is the staged content of a file.
+type fileChange struct {
+	path    string // absolute
+	rel     string // relative to the working directory, for display
+	existed bool
+	old     string
+	new     string
+	delete  bool
+	mode    os.FileMode
+}
+
+// clone returns a copy of tx whose changes can be modified without
+// affecting tx.
+func (tx *Transaction) clone() *Transaction {
+	c := &Transaction{staged: tx.staged}
+	for _, ch := range tx.changes {
+		copied := *ch
+		c.changes = append(c.changes, &copied)
+	}
+	return c
+}
+
+// replace stages the changes of staged, a modified clone of tx.
+func (tx *Transaction) replace(staged *Transaction) {
+	tx.changes = staged.changes
+	tx.staged++
+}
+
+// stagedFile returns the staged change of the file at name, starting one
+// from the file's current content if there is none.
+func (w *Workspace) stagedFile(name string, tx *Transaction) (*fileChange, error) {
+	path, err := w.ResolveNew(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range tx.changes {
+		if c.path == path {
+			return c, nil
+		}
+	}
+
+	c := &fileChange{path: path, mode: 0644}
+	c.rel, _ = filepath.Rel(w.Roots[0], path)
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && !info.Mode().IsRegular():
+		return nil, fmt.Errorf("%s is not a regular file", name)
+	case err == nil:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		c.existed, c.old, c.mode = true, string(data), info.Mode().Perm()
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+	c.new = c.old
+	tx.changes = append(tx.changes, c)
+	return c, nil
+}
+
+func (c *fileChange) summary() string {
+	switch {
+	case c.delete:
+		return "deleted " + c.rel
+	case !c.existed:
+		return "created " + c.rel
+	}
+	return "modified " + c.rel
+}
+
+// pending returns the staged changes that would change a file.
+func (tx *Transaction) pending() []*fileChange {
+	var changes []*fileChange
+	for _, c := range tx.changes {
+		switch {
+		case c.delete && c.existed, !c.delete && !c.existed, c.new != c.old:
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// Len returns the number of files the staged changes would change.
+func (tx *Transaction) Len() int {
+	return len(tx.pending())
+}
+
+// Preview renders the staged changes as a unified diff.
+func (tx *Transaction) Preview() string {
+	var b strings.Builder
+	for _, c := range tx.pending() {
+		fmt.Fprintf(&b, "--- %s\n+++ %s\n%s", c.rel, c.rel, diff.Unified(c.old, c.new, 3))
+	}
+	return b.String()
+}
+
+// errRejected is returned by commit when the user declines the changes.
+var errRejected = errors.New("the user rejected the changes; no files were changed")
+
+// Commit is commit for changes staged outside of a turn.
+func (tx *Transaction) Commit(ctx context.Context, ws *Workspace, description string) error {
+	return tx.commit(ctx, ws, description)
+}
+
+// commit asks the user to confirm the staged changes and applies them. If
+// checkpointing is enabled, the previous content of the files is saved as a
+// checkpoint for /restore first.
+func (tx *Transaction) commit(ctx context.Context, ws *Workspace, description string) error {
+	changes := tx.pending()
+	if len(changes) == 0 {
+		return nil
+	}
+	if ws.Confirm == nil && !ws.autoApproved(editAction) {
+		return errors.New("changing files needs the user's confirmation, which is not available in this mode")
+	}
+	ok, err := ws.confirm(ctx, editAction, "", fmt.Sprintf("Apply changes to %d file(s)?", len(changes)), tx.Preview())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errRejected
+	}
+
+	var cp *checkpoint.Checkpoint
+	if ws.checkpointing() {
+		var files []checkpoint.File
+		for _, c := range changes {
+			files = append(files, checkpoint.File{Path: c.path, Existed: c.existed, Content: c.old, Mode: uint32(c.mode)})
+		}
+		cp = checkpoint.New(description, files)
+		if err := checkpoint.Save(ws.Roots[0], cp); err != nil {
+			return fmt.Errorf("could not save a checkpoint, no files were changed: %w", err)
+		}
+	}
+	if err := commitChanges(ctx, ws.files(), changes); err != nil {
+		if cp != nil {
+			checkpoint.Delete(ws.Roots[0], cp.ID)
+		}
+		return err
+	}
+	if ws.FilesChanged != nil {
+		paths := make([]string, len(changes))
+		for i, c := range changes {
+			paths[i] = c.rel
+		}
+		ws.FilesChanged(paths)
+	}
+	return nil
+}
+
+func (w *Workspace) checkpointing() bool {
+	g := w.Settings.General
+	return g != nil && g.Checkpointing != nil && g.Checkpointing.Enabled
+}
+
+// ExecuteTurn executes the function calls of one model turn and returns
+// their results in order. Consecutive calls of tools that only read run
+// concurrently. File changes made by the calls are staged in a
+// transaction and committed once all calls have run; if the user rejects
+// them or they cannot be applied, the results of the calls that made them
+// say so.
+func ExecuteTurn(ctx context.Context, ws *Workspace, calls []genai.FunctionCall) []genai.Part {
+	// Shared with the turn's copy, so that approvals, loaded declarations
+	// and cached results last the session.
+	if ws.approvedHosts == nil {
+		ws.approvedHosts = map[string]bool{}
+	}
+	if ws.loaded == nil {
+		ws.loaded = map[string]bool{}
+	}
+	if ws.results == nil {
+		ws.results = &resultCache{}
+	}
+	turn := *ws
+	turn.tx = &Transaction{}
+
+	parts := make([]genai.Part, len(calls))
+	var changed []int
+	for i := 0; i < len(calls); {
+		// Consecutive calls that only read run at the same time.
+		j := i
+		for j < len(calls) && turn.parallel(&calls[j]) {
+			j++
+		}
+		if j-i > 1 {
+			executeParallel(ctx, &turn, calls[i:j], parts[i:j])
+			i = j
+			continue
+		}
+		before := turn.tx.staged
+		parts[i] = ExecuteToolCall(ctx, &turn, &calls[i])
+		if turn.tx.staged != before {
+			changed = append(changed, i)
+		}
+		i++
+	}
+	if len(changed) == 0 {
+		return parts
+	}
+
+	var names []string
+	for _, i := range changed {
+		names = append(names, calls[i].Name)
+	}
+	err := turn.tx.commit(ctx, &turn, strings.Join(names, ", "))
+	for _, i := range changed {
+		switch {
+		case errors.Is(err, errRejected):
+			parts[i] = &genai.FunctionResponse{Name: calls[i].Name, Response: map[string]any{
+				"applied": false,
+				"message": "The user rejected the file changes of this turn; no files were changed.",
+			}}
+		case err != nil:
+			parts[i] = errorResponse(calls[i].Name, err)
+		}
+	}
+	return parts
+}
+
+// RestoreCheckpoint stages the changes that return the files recorded in cp
+// to their state at that time.
+func RestoreCheckpoint(ws *Workspace, cp *checkpoint.Checkpoint) (*Transaction, error) {
+	tx := &Transaction{}
+	for _, f := range cp.Files {
+		c, err := ws.stagedFile(f.Path, tx)
+		if err != nil {
+			return nil, err
+		}
+		c.new, c.delete = f.Content, !f.Existed
+		if f.Existed {
+			c.mode = os.FileMode(f.Mode)
+		}
+	}
+	return tx, nil
+}
+
+// fileWriter writes the files of committed changes.
+type fileWriter interface {
+	WriteFile(ctx context.Context, path, content string, mode os.FileMode) error
+	Remove(ctx context.Context, path string) error
+}
+
+// hostFiles writes files directly.
+type hostFiles struct{}
+
+func (hostFiles) WriteFile(ctx context.Context, path, content string, mode os.FileMode) error {
+	return writeFileAtomic(path, content, mode)
+}
+
+func (hostFiles) Remove(ctx context.Context, path string) error {
+	return os.Remove(path)
+}
+
+// files returns how w writes files: inside the sandbox if the tools run in
+// one, and otherwise directly.
+func (w *Workspace) files() fileWriter {
+	if w.Sandbox != nil {
+		return w.Sandbox
+	}
+	return hostFiles{}
+}
+
+// commitChanges writes all changes with files, restoring the files already
+// written if one fails. Each file is replaced atomically by renaming a
+// temporary file over it.
+func commitChanges(ctx context.Context, files fileWriter, changes []*fileChange) error {
+	for i, c := range changes {
+		if err := c.apply(ctx, files); err != nil {
+			err = fmt.Errorf("could not write %s: %w", c.rel, err)
+			for _, done := range changes[:i] {
+				if rbErr := done.revert(ctx, files); rbErr != nil {
+					err = fmt.Errorf("%w; restoring %s also failed: %v", err, done.rel, rbErr)
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *fileChange) apply(ctx context.Context, files fileWriter) error {
+	if c.delete {
+		if !c.existed {
+			return nil
+		}
+		return files.Remove(ctx, c.path)
+	}
+	return files.WriteFile(ctx, c.path, c.new, c.mode)
+}
+
+func (c *fileChange) revert(ctx context.Context, files fileWriter) error {
+	if !c.existed {
+		err := files.Remove(ctx, c.path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return files.WriteFile(ctx, c.path, c.old, c.mode)
+}
+
+// writeFileAtomic replaces path with content, creating its directory if
+// needed.
+func writeFileAtomic(path, content string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/checkpoint"
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google/generative-ai-go/genai"
+)
+
+func patchCall(patch string) genai.FunctionCall {
+	return genai.FunctionCall{Name: PatchToolName, Args: map[string]any{"patch": patch}}
+}
+
+func TestExecuteTurnCommitsOnce(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := testWorkspace(t, &config.Settings{General: &config.GeneralSettings{Checkpointing: &config.Checkpointing{Enabled: true}}})
+	root := ws.Roots[0]
+	a := filepath.Join(root, "a.txt")
+	os.WriteFile(a, []byte("one\ntwo\n"), 0644)
+
+	var previews []string
+	ws.Confirm = func(ctx context.Context, title, details string) (bool, error) {
+		previews = append(previews, details)
+		return true, nil
+	}
+	// The second call builds on the first, and a failing call in between
+	// does not affect the others.
+	parts := ExecuteTurn(context.Background(), ws, []genai.FunctionCall{
+		patchCall("--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-one\n+1\n"),
+		patchCall("--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-missing\n+x\n"),
+		patchCall("--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n 1\n-two\n+2\n"),
+	})
+	if len(previews) != 1 || !strings.Contains(previews[0], "+1") || !strings.Contains(previews[0], "+2") {
+		t.Errorf("Expected one combined confirmation, got %q", previews)
+	}
+	if resp := parts[1].(*genai.FunctionResponse).Response; resp["error"] == nil {
+		t.Errorf("Expected the mismatched patch to fail, got %v", resp)
+	}
+	if data, _ := os.ReadFile(a); string(data) != "1\n2\n" {
+		t.Fatalf("a.txt = %q", data)
+	}
+
+	list, err := checkpoint.List(root)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("Expected one checkpoint, got %v (%v)", list, err)
+	}
+	tx, err := RestoreCheckpoint(ws, list[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(context.Background(), ws, "restore"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(a); string(data) != "one\ntwo\n" {
+		t.Errorf("Expected a.txt to be restored, got %q", data)
+	}
+}
+
+func TestExecuteTurnRejected(t *testing.T) {
+	ws := testWorkspace(t, nil)
+	root := ws.Roots[0]
+	os.WriteFile(filepath.Join(root, "a.txt"), []byte("one\n"), 0644)
+	ws.Confirm = func(ctx context.Context, title, details string) (bool, error) { return false, nil }
+
+	parts := ExecuteTurn(context.Background(), ws, []genai.FunctionCall{
+		patchCall("--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-one\n+1\n"),
+		patchCall("--- /dev/null\n+++ b/b.txt\n@@ -0,0 +1 @@\n+b\n"),
+	})
+	for i, p := range parts {
+		if resp := p.(*genai.FunctionResponse).Response; resp["applied"] != false {
+			t.Errorf("Expected call %d to report the rejection, got %v", i, resp)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "a.txt")); string(data) != "one\n" {
+		t.Errorf("a.txt changed to %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(root, "b.txt")); !os.IsNotExist(err) {
+		t.Error("Expected b.txt not to be created")
+	}
+}
@@ -0,0 +1,136 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+// bytesPerToken converts token budgets to bytes.
+const bytesPerToken = 4
+
+// outputBudget returns the size in bytes responses under l are truncated
+// to, the smaller of its byte and token budgets, or 0 for no budget.
+func outputBudget(l config.LimitSettings) int {
+	budget := l.OutputBytes
+	if tokens := l.OutputTokens * bytesPerToken; tokens > 0 && (budget <= 0 || tokens < budget) {
+		budget = tokens
+	}
+	return max(budget, 0)
+}
+
+// truncateResponse returns resp cut down to about budget bytes of JSON by
+// eliding the middle of its longest strings, or resp itself if it fits.
+func truncateResponse(resp map[string]any, budget int) map[string]any {
+	if budget <= 0 || resp == nil {
+		return resp
+	}
+	plain := plainValue(resp).(map[string]any)
+	data, err := json.Marshal(plain)
+	if err != nil || len(data) <= budget {
+		return resp
+	}
+
+	var fields []stringField
+	collectStrings(plain, &fields)
+	sort.SliceStable(fields, func(i, j int) bool { return len(fields[i].value) > len(fields[j].value) })
+	excess := len(data) - budget
+	for _, f := range fields {
+		if excess <= 0 {
+			break
+		}
+		// Escapes make strings longer in JSON, such as line breaks, so the
+		// bytes to remove are scaled down by how much.
+		encoded := jsonSize(f.value)
+		elided := elide(f.value, excess*len(f.value)/encoded+1)
+		excess -= encoded - jsonSize(elided)
+		f.set(elided)
+	}
+	return plain
+}
+
+// jsonSize returns the length of s encoded as a JSON string.
+func jsonSize(s string) int {
+	data, _ := json.Marshal(s)
+	return len(data)
+}
+
+// stringField is a string in a response, and how to replace it.
+type stringField struct {
+	value string
+	set   func(string)
+}
+
+// collectStrings appends the strings in v, a plain value, to fields.
+func collectStrings(v any, fields *[]stringField) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if s, ok := e.(string); ok {
+				*fields = append(*fields, stringField{s, func(s string) { v[k] = s }})
+				continue
+			}
+			collectStrings(e, fields)
+		}
+	case []any:
+		for i, e := range v {
+			if s, ok := e.(string); ok {
+				*fields = append(*fields, stringField{s, func(s string) { v[i] = s }})
+				continue
+			}
+			collectStrings(e, fields)
+		}
+	}
+}
+
+// elide removes at least n bytes from the middle of s, keeping equal parts
+// of its head and tail, and puts a marker saying how much was removed in
+// their place. The cuts fall on line breaks near them, if there are any.
+// s is returned as it is if the marker would not make it shorter.
+func elide(s string, n int) string {
+	// The marker is at most this long.
+	const markerSize = 64
+	keep := len(s) - n - markerSize
+	if keep < 0 {
+		keep = 0
+	}
+	headEnd := lineCut(s, keep/2, true)
+	tailStart := lineCut(s, len(s)-(keep-keep/2), false)
+	if tailStart < headEnd {
+		tailStart = headEnd
+	}
+	removed := s[headEnd:tailStart]
+	marker := fmt.Sprintf("\n[... %d bytes (%d lines) elided ...]\n", len(removed), strings.Count(removed, "\n"))
+	if len(marker) >= len(removed) {
+		return s
+	}
+	return s[:headEnd] + marker + s[tailStart:]
+}
+
+// lineCut returns a position in s near i that falls on a rune boundary,
+// moving it back (for the end of the head) or forward (for the start of
+// the tail) to a line break within a short distance.
+func lineCut(s string, i int, head bool) int {
+	const reach = 200
+	i = min(max(i, 0), len(s))
+	if head {
+		if j := strings.LastIndexByte(s[max(i-reach, 0):i], '\n'); j >= 0 {
+			return max(i-reach, 0) + j + 1
+		}
+		for i > 0 && i < len(s) && !utf8.RuneStart(s[i]) {
+			i--
+		}
+		return i
+	}
+	if j := strings.IndexByte(s[i:min(i+reach, len(s))], '\n'); j >= 0 {
+		return i + j + 1
+	}
+	for i < len(s) && !utf8.RuneStart(s[i]) {
+		i++
+	}
+	return i
+}
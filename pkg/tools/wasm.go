@@ -0,0 +1,288 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/tetratelabs/wazero"
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/experimental/sysfs"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// The access to the workspace a WASM plugin may ask for with "filesystem"
+// in its schema. Without it, it sees no files.
+const (
+	wasmReadFiles  = "read"
+	wasmWriteFiles = "write"
+)
+
+// maxWASMOutput caps what a WASM plugin may write to stdout or stderr.
+const maxWASMOutput = 16 << 20
+
+// wasmModule is a plugin compiled to WebAssembly for WASI, which wazero
+// runs in a sandbox: without network access, and without files but the
+// workspace's, if its schema asks for them.
+type wasmModule struct {
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	// filesystem is the access to the workspace the plugin asked for.
+	filesystem string
+}
+
+// wasmCompilationCache returns the cache of compiled modules in
+// ~/.gemini/cache/wasm, which spares compiling the plugins at each start.
+func wasmCompilationCache() (wazero.CompilationCache, error) {
+	dir, err := config.UserDir()
+	if err != nil {
+		return nil, err
+	}
+	cache, err := wazero.NewCompilationCacheWithDir(filepath.Join(dir, "cache", "wasm"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the cache of compiled plugins: %w", err)
+	}
+	return cache, nil
+}
+
+// compileWASM compiles the plugin at path, whose memory memoryMB bounds
+// unless it is 0.
+func compileWASM(ctx context.Context, path string, memoryMB int) (*wasmModule, error) {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := wasmCompilationCache()
+	if err != nil {
+		return nil, err
+	}
+	cfg := wazero.NewRuntimeConfig().WithCloseOnContextDone(true).WithCompilationCache(cache)
+	if memoryMB > 0 {
+		// Pages are 64 KiB.
+		cfg = cfg.WithMemoryLimitPages(uint32(memoryMB * 16))
+	}
+	r := wazero.NewRuntimeWithConfig(ctx, cfg)
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r); err != nil {
+		r.Close(ctx)
+		return nil, err
+	}
+	compiled, err := r.CompileModule(ctx, code)
+	if err != nil {
+		r.Close(ctx)
+		return nil, fmt.Errorf("invalid WebAssembly module: %w", err)
+	}
+	return &wasmModule{runtime: r, compiled: compiled}, nil
+}
+
+// run runs the module with args, the program name first, and env, feeding
+// it stdin, and returns what it wrote to stdout and stderr. The workspace
+// roots are mounted at their own paths if the module asked for them, the
+// first as its working directory. A non-zero exit status is an error.
+func (m *wasmModule) run(ctx context.Context, args []string, stdin []byte, env, roots []string) ([]byte, []byte, error) {
+	stdout := cappedBuffer{max: maxWASMOutput}
+	stderr := cappedBuffer{max: maxWASMOutput}
+	// Unnamed, so that calls can run at the same time.
+	cfg := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs(args...).
+		WithStdin(bytes.NewReader(stdin)).
+		WithStdout(&stdout).
+		WithStderr(&stderr).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithRandSource(rand.Reader)
+	for _, kv := range env {
+		name, value, _ := strings.Cut(kv, "=")
+		cfg = cfg.WithEnv(name, value)
+	}
+	if m.filesystem != "" && len(roots) > 0 {
+		mounts := wazero.NewFSConfig()
+		for _, root := range roots {
+			root, err := filepath.EvalSymlinks(root)
+			if err != nil {
+				return nil, nil, err
+			}
+			var rootFS experimentalsys.FS = &scopedFS{FS: sysfs.DirFS(root), root: root}
+			if m.filesystem != wasmWriteFiles {
+				rootFS = &sysfs.ReadFS{FS: rootFS}
+			}
+			mounts = mounts.(sysfs.FSConfig).WithSysFSMount(rootFS, guestPath(root))
+		}
+		cfg = cfg.WithFSConfig(mounts).WithEnv("PWD", guestPath(roots[0]))
+	}
+
+	mod, err := m.runtime.InstantiateModule(ctx, m.compiled, cfg)
+	if mod != nil {
+		mod.Close(ctx)
+	}
+	var exitErr *sys.ExitError
+	if errors.As(err, &exitErr) {
+		switch code := exitErr.ExitCode(); code {
+		case 0:
+			err = nil
+		case sys.ExitCodeContextCanceled, sys.ExitCodeDeadlineExceeded:
+			err = ctx.Err()
+		default:
+			err = fmt.Errorf("exit status %d", code)
+		}
+	}
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// guestPath returns the path a plugin sees the host directory dir at: the
+// same, with forward slashes and without a drive letter on Windows.
+func guestPath(dir string) string {
+	return filepath.ToSlash(strings.TrimPrefix(dir, filepath.VolumeName(dir)))
+}
+
+// scopedFS is a workspace root as a plugin sees it. The mounts of wazero
+// let paths with .. and symbolic links lead out of the directory mounted,
+// so every path is resolved on the host first, and refused unless it stays
+// within the root.
+type scopedFS struct {
+	// FS is the root, which is given the resolved paths.
+	experimentalsys.FS
+	// root is the directory mounted, without symbolic links.
+	root string
+}
+
+// resolve returns path, relative to the root, with .. and symbolic links
+// resolved, following a link in its last element only if follow is set.
+func (s *scopedFS) resolve(path string, follow bool) (string, experimentalsys.Errno) {
+	host := filepath.Join(s.root, filepath.FromSlash(path))
+	if !within(s.root, host) {
+		return "", experimentalsys.EPERM
+	}
+	dir, name := host, ""
+	if !follow && host != s.root {
+		dir, name = filepath.Dir(host), filepath.Base(host)
+	}
+	real, err := evalExisting(dir)
+	if err != nil {
+		return "", experimentalsys.UnwrapOSError(err)
+	}
+	real = filepath.Join(real, name)
+	if !within(s.root, real) {
+		return "", experimentalsys.EPERM
+	}
+	rel, err := filepath.Rel(s.root, real)
+	if err != nil {
+		return "", experimentalsys.EPERM
+	}
+	return filepath.ToSlash(rel), 0
+}
+
+func (s *scopedFS) OpenFile(path string, flag experimentalsys.Oflag, perm fs.FileMode) (experimentalsys.File, experimentalsys.Errno) {
+	// A link in the last element is followed unless the plugin asks not to.
+	path, errno := s.resolve(path, flag&experimentalsys.O_NOFOLLOW == 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	return s.FS.OpenFile(path, flag, perm)
+}
+
+func (s *scopedFS) Lstat(path string) (sys.Stat_t, experimentalsys.Errno) {
+	path, errno := s.resolve(path, false)
+	if errno != 0 {
+		return sys.Stat_t{}, errno
+	}
+	return s.FS.Lstat(path)
+}
+
+func (s *scopedFS) Stat(path string) (sys.Stat_t, experimentalsys.Errno) {
+	path, errno := s.resolve(path, true)
+	if errno != 0 {
+		return sys.Stat_t{}, errno
+	}
+	return s.FS.Stat(path)
+}
+
+func (s *scopedFS) Mkdir(path string, perm fs.FileMode) experimentalsys.Errno {
+	path, errno := s.resolve(path, false)
+	if errno != 0 {
+		return errno
+	}
+	return s.FS.Mkdir(path, perm)
+}
+
+func (s *scopedFS) Chmod(path string, perm fs.FileMode) experimentalsys.Errno {
+	path, errno := s.resolve(path, true)
+	if errno != 0 {
+		return errno
+	}
+	return s.FS.Chmod(path, perm)
+}
+
+func (s *scopedFS) Rename(from, to string) experimentalsys.Errno {
+	from, errno := s.resolve(from, false)
+	if errno != 0 {
+		return errno
+	}
+	to, errno = s.resolve(to, false)
+	if errno != 0 {
+		return errno
+	}
+	return s.FS.Rename(from, to)
+}
+
+func (s *scopedFS) Rmdir(path string) experimentalsys.Errno {
+	path, errno := s.resolve(path, false)
+	if errno != 0 {
+		return errno
+	}
+	return s.FS.Rmdir(path)
+}
+
+func (s *scopedFS) Unlink(path string) experimentalsys.Errno {
+	path, errno := s.resolve(path, false)
+	if errno != 0 {
+		return errno
+	}
+	return s.FS.Unlink(path)
+}
+
+func (s *scopedFS) Link(oldPath, newPath string) experimentalsys.Errno {
+	oldPath, errno := s.resolve(oldPath, true)
+	if errno != 0 {
+		return errno
+	}
+	newPath, errno = s.resolve(newPath, false)
+	if errno != 0 {
+		return errno
+	}
+	return s.FS.Link(oldPath, newPath)
+}
+
+// Symlink creates links anywhere within the root. Links to the outside
+// can be made, but not followed.
+func (s *scopedFS) Symlink(oldPath, linkName string) experimentalsys.Errno {
+	linkName, errno := s.resolve(linkName, false)
+	if errno != 0 {
+		return errno
+	}
+	return s.FS.Symlink(oldPath, linkName)
+}
+
+func (s *scopedFS) Readlink(path string) (string, experimentalsys.Errno) {
+	path, errno := s.resolve(path, false)
+	if errno != 0 {
+		return "", errno
+	}
+	return s.FS.Readlink(path)
+}
+
+func (s *scopedFS) Utimens(path string, atim, mtim int64) experimentalsys.Errno {
+	path, errno := s.resolve(path, true)
+	if errno != 0 {
+		return errno
+	}
+	return s.FS.Utimens(path, atim, mtim)
+}
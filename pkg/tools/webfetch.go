@@ -0,0 +1,428 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// WebFetchToolName is the name of the tool fetching web pages.
+const WebFetchToolName = "web_fetch"
+
+const (
+	// defaultMaxPageLength caps the text of a page unless
+	// tools.webFetch.maxLength is set.
+	defaultMaxPageLength = 100 << 10
+	// maxPageBytes caps the HTML read before it is converted.
+	maxPageBytes = 5 << 20
+)
+
+var webFetchDeclaration = &genai.FunctionDeclaration{
+	Name: WebFetchToolName,
+	Description: "Fetches a web page, such as documentation or an issue, and returns its content as markdown. " +
+		"The user approves each host unless it is in tools.http.allowedHosts. Long pages are truncated.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"url": {
+				Type:        genai.TypeString,
+				Description: "The http or https URL of the page.",
+			},
+		},
+		Required: []string{"url"},
+	},
+}
+
+func webFetch(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	rawURL, err := stringArg(args, "url")
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid URL %q: an absolute http or https URL is required", rawURL)
+	}
+	client, err := ws.webClient()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain;q=0.9,*/*;q=0.5")
+	if err := ws.approveHost(ctx, req.URL, req.Method); err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("fetching %s: %s", resp.Request.URL, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPageBytes))
+	if err != nil {
+		return nil, fmt.Errorf("reading the page: %w", err)
+	}
+
+	result := map[string]any{"url": resp.Request.URL.String()}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	var content string
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml" || (mediaType == "" && isHTML(data)):
+		title, md, err := htmlToMarkdown(strings.NewReader(string(data)), resp.Request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("converting the page: %w", err)
+		}
+		if title != "" {
+			result["title"] = title
+		}
+		content = md
+	case isTextBody(mediaType, data):
+		content = string(trimPartialRune(data))
+	default:
+		return nil, fmt.Errorf("%s is not a web page but %s", resp.Request.URL, mediaType)
+	}
+	result["content"] = truncateText(content, ws.maxPageLength())
+	return result, nil
+}
+
+// webClient returns the client fetching pages, through tools.webFetch.proxy
+// or the proxy of the environment. Every redirect needs the approval of its
+// host too.
+func (w *Workspace) webClient() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if t := w.Settings.Tools; t != nil && t.WebFetch != nil && t.WebFetch.Proxy != "" {
+		proxy, err := url.Parse(t.WebFetch.Proxy)
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid tools.webFetch.proxy %q", t.WebFetch.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   httpTimeout,
+		CheckRedirect: func(next *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return w.approveHost(next.Context(), next.URL, next.Method)
+		},
+	}, nil
+}
+
+func (w *Workspace) maxPageLength() int {
+	if t := w.Settings.Tools; t != nil && t.WebFetch != nil && t.WebFetch.MaxLength > 0 {
+		return t.WebFetch.MaxLength
+	}
+	return defaultMaxPageLength
+}
+
+// isHTML reports whether a body without a content type looks like HTML.
+func isHTML(data []byte) bool {
+	return strings.HasPrefix(http.DetectContentType(data), "text/html")
+}
+
+// htmlToMarkdown converts an HTML page to markdown, keeping its headings,
+// paragraphs, lists, links, code and tables, and dropping scripts, styles
+// and other markup. Links are resolved against base.
+func htmlToMarkdown(r io.Reader, base *url.URL) (title, text string, err error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", "", err
+	}
+	c := &markdownConverter{base: base}
+	c.convert(doc)
+	return c.title, tidyMarkdown(c.out.String()), nil
+}
+
+// skippedElements are not part of the text of a page.
+var skippedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Svg: true, atom.Iframe: true, atom.Canvas: true, atom.Object: true,
+	atom.Button: true, atom.Select: true, atom.Input: true, atom.Textarea: true,
+}
+
+// blockElements start and end on a line of their own.
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true,
+	atom.Main: true, atom.Header: true, atom.Footer: true, atom.Nav: true,
+	atom.Aside: true, atom.Figure: true, atom.Figcaption: true, atom.Table: true,
+	atom.Dl: true, atom.Dt: true, atom.Dd: true, atom.Details: true,
+	atom.Summary: true, atom.Form: true, atom.Address: true, atom.Body: true,
+}
+
+type markdownConverter struct {
+	out   strings.Builder
+	base  *url.URL
+	title string
+	// lists holds the next number of each enclosing ordered list, or 0 for
+	// an unordered one.
+	lists []int
+}
+
+func (c *markdownConverter) convert(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		c.text(n.Data)
+		return
+	case html.DocumentNode:
+		c.children(n)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	switch {
+	case n.DataAtom == atom.Title:
+		if c.title == "" {
+			c.title = strings.Join(strings.Fields(textContent(n)), " ")
+		}
+	case n.DataAtom == atom.Head:
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.DataAtom == atom.Title {
+				c.convert(child)
+			}
+		}
+	case skippedElements[n.DataAtom]:
+	case headingLevel(n) > 0:
+		c.block()
+		c.out.WriteString(strings.Repeat("#", headingLevel(n)) + " ")
+		c.children(n)
+		c.block()
+	case n.DataAtom == atom.Br:
+		c.out.WriteString("\n")
+	case n.DataAtom == atom.Hr:
+		c.block()
+		c.out.WriteString("---")
+		c.block()
+	case n.DataAtom == atom.Pre:
+		c.block()
+		c.out.WriteString("```\n" + strings.TrimRight(textContent(n), "\n") + "\n```")
+		c.block()
+	case n.DataAtom == atom.Code || n.DataAtom == atom.Kbd || n.DataAtom == atom.Samp:
+		if code := textContent(n); strings.TrimSpace(code) != "" {
+			c.out.WriteString("`" + code + "`")
+		}
+	case n.DataAtom == atom.Strong || n.DataAtom == atom.B:
+		c.wrap(n, "**")
+	case n.DataAtom == atom.Em || n.DataAtom == atom.I:
+		c.wrap(n, "_")
+	case n.DataAtom == atom.A:
+		c.link(n)
+	case n.DataAtom == atom.Img:
+		if alt := strings.TrimSpace(attr(n, "alt")); alt != "" {
+			c.out.WriteString("![" + alt + "](" + c.resolve(attr(n, "src")) + ")")
+		}
+	case n.DataAtom == atom.Ul || n.DataAtom == atom.Ol:
+		next := 0
+		if n.DataAtom == atom.Ol {
+			next = 1
+		}
+		c.lists = append(c.lists, next)
+		c.children(n)
+		c.lists = c.lists[:len(c.lists)-1]
+		c.block()
+	case n.DataAtom == atom.Li:
+		c.listItem(n)
+	case n.DataAtom == atom.Blockquote:
+		sub := &markdownConverter{base: c.base}
+		sub.children(n)
+		c.block()
+		for i, line := range strings.Split(tidyMarkdown(sub.out.String()), "\n") {
+			if i > 0 {
+				c.out.WriteString("\n")
+			}
+			c.out.WriteString(strings.TrimRight("> "+line, " "))
+		}
+		c.block()
+	case n.DataAtom == atom.Tr:
+		c.row(n)
+	case blockElements[n.DataAtom]:
+		c.block()
+		c.children(n)
+		c.block()
+	default:
+		c.children(n)
+	}
+}
+
+func (c *markdownConverter) children(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.convert(child)
+	}
+}
+
+// text writes the text of a text node with its whitespace collapsed.
+func (c *markdownConverter) text(s string) {
+	s = whitespace.ReplaceAllString(s, " ")
+	if s == " " || strings.HasPrefix(s, " ") {
+		if c.atLineStart() || strings.HasSuffix(c.out.String(), " ") {
+			s = strings.TrimPrefix(s, " ")
+		}
+	}
+	c.out.WriteString(s)
+}
+
+var whitespace = regexp.MustCompile(`\s+`)
+
+// block ends the current line, separating what follows with a blank line,
+// or with a line break inside a list.
+func (c *markdownConverter) block() {
+	if c.out.Len() == 0 {
+		return
+	}
+	if len(c.lists) > 0 {
+		if !c.atLineStart() {
+			c.out.WriteString("\n")
+		}
+		return
+	}
+	if !strings.HasSuffix(c.out.String(), "\n\n") {
+		c.out.WriteString(strings.Repeat("\n", 2-trailingNewlines(c.out.String())))
+	}
+}
+
+func (c *markdownConverter) atLineStart() bool {
+	return c.out.Len() == 0 || strings.HasSuffix(c.out.String(), "\n")
+}
+
+// wrap writes the content of n between marks, if it has any.
+func (c *markdownConverter) wrap(n *html.Node, mark string) {
+	if inner := c.inline(n); inner != "" {
+		c.out.WriteString(mark + inner + mark)
+	}
+}
+
+// inline returns the markdown of the content of n, on its own.
+func (c *markdownConverter) inline(n *html.Node) string {
+	sub := &markdownConverter{base: c.base}
+	sub.children(n)
+	return strings.TrimSpace(sub.out.String())
+}
+
+func (c *markdownConverter) link(n *html.Node) {
+	text := c.inline(n)
+	href := strings.TrimSpace(attr(n, "href"))
+	switch {
+	case text == "":
+	case href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:"):
+		c.text(text)
+	default:
+		c.out.WriteString("[" + text + "](" + c.resolve(href) + ")")
+	}
+}
+
+func (c *markdownConverter) listItem(n *html.Node) {
+	if !c.atLineStart() {
+		c.out.WriteString("\n")
+	}
+	depth := max(len(c.lists), 1)
+	c.out.WriteString(strings.Repeat("  ", depth-1))
+	if len(c.lists) > 0 && c.lists[depth-1] > 0 {
+		fmt.Fprintf(&c.out, "%d. ", c.lists[depth-1])
+		c.lists[depth-1]++
+	} else {
+		c.out.WriteString("- ")
+	}
+	c.children(n)
+	if !c.atLineStart() {
+		c.out.WriteString("\n")
+	}
+}
+
+// row writes a table row as a markdown table row, followed by the
+// separator of a header row if its cells are headers.
+func (c *markdownConverter) row(n *html.Node) {
+	var cells []string
+	header := false
+	for cell := n.FirstChild; cell != nil; cell = cell.NextSibling {
+		if cell.Type != html.ElementNode || (cell.DataAtom != atom.Td && cell.DataAtom != atom.Th) {
+			continue
+		}
+		header = header || cell.DataAtom == atom.Th
+		cells = append(cells, strings.ReplaceAll(strings.Join(strings.Fields(c.inline(cell)), " "), "|", `\|`))
+	}
+	if len(cells) == 0 {
+		return
+	}
+	if !c.atLineStart() {
+		c.out.WriteString("\n")
+	}
+	c.out.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	if header {
+		c.out.WriteString("|" + strings.Repeat(" --- |", len(cells)) + "\n")
+	}
+}
+
+func (c *markdownConverter) resolve(ref string) string {
+	u, err := url.Parse(ref)
+	if err != nil || c.base == nil {
+		return ref
+	}
+	return c.base.ResolveReference(u).String()
+}
+
+func headingLevel(n *html.Node) int {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		return int(n.Data[1] - '0')
+	}
+	return 0
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		if n.Type == html.ElementNode && n.DataAtom == atom.Br {
+			b.WriteString("\n")
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+func trailingNewlines(s string) int {
+	return len(s) - len(strings.TrimRight(s, "\n"))
+}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// tidyMarkdown trims trailing spaces from lines and collapses runs of blank
+// lines.
+func tidyMarkdown(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
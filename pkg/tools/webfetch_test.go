@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+func TestWebFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(`<html><head><title>Docs</title><script>alert(1)</script></head>
+<body><h1>Install</h1><p>Run <code>make</code>, then see <a href="/next">the next page</a>.</p></body></html>`))
+		case "/long":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(strings.Repeat("x", 100)))
+		case "/moved":
+			http.Redirect(w, r, "/page", http.StatusFound)
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("\x89PNG\r\n\x1a\n\x00"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ws := testWorkspace(t, &config.Settings{Tools: &config.ToolsSettings{WebFetch: &config.WebFetchSettings{MaxLength: 10}}})
+	asked := 0
+	ws.Confirm = func(ctx context.Context, title, details string) (bool, error) {
+		asked++
+		return true, nil
+	}
+
+	resp := runTool(t, ws, WebFetchToolName, map[string]any{"url": srv.URL + "/moved"})
+	if resp["title"] != "Docs" || resp["url"] != srv.URL+"/page" {
+		t.Errorf("Expected the redirected page, got %v", resp)
+	}
+	if content, _ := resp["content"].(string); !strings.HasPrefix(content, "# Install\n") || !strings.Contains(content, "truncated") {
+		t.Errorf("Expected the markdown to be truncated, got %q", content)
+	}
+	if asked != 1 {
+		t.Errorf("Expected the host to be approved once, was asked %d times", asked)
+	}
+
+	resp = runTool(t, ws, WebFetchToolName, map[string]any{"url": srv.URL + "/long"})
+	if content, _ := resp["content"].(string); !strings.HasPrefix(content, "xxxxxxxxxx\n... [truncated") {
+		t.Errorf("Expected plain text to be truncated, got %q", content)
+	}
+	for path, want := range map[string]string{"/image": "not a web page", "/missing": "404"} {
+		if resp := runTool(t, ws, WebFetchToolName, map[string]any{"url": srv.URL + path}); !strings.Contains(resp["error"].(string), want) {
+			t.Errorf("Expected %s to fail with %q, got %v", path, want, resp)
+		}
+	}
+}
+
+func TestWebFetchProxy(t *testing.T) {
+	proxied := false
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.Host == "docs.example"
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("via proxy"))
+	}))
+	defer proxy.Close()
+
+	ws := testWorkspace(t, &config.Settings{Tools: &config.ToolsSettings{
+		HTTP:     &config.HTTPSettings{AllowedHosts: []string{"docs.example"}},
+		WebFetch: &config.WebFetchSettings{Proxy: proxy.URL},
+	}})
+	resp := runTool(t, ws, WebFetchToolName, map[string]any{"url": "http://docs.example/guide"})
+	if resp["content"] != "via proxy" || !proxied {
+		t.Errorf("Expected the page to be fetched through the proxy, got %v", resp)
+	}
+}
+
+func TestHTMLToMarkdown(t *testing.T) {
+	page := `<html><head><title> The   Title </title><style>p { color: red }</style></head><body>
+<nav><a href="#main">Skip</a></nav>
+<h2>Usage</h2>
+<p>Some <strong>bold</strong> and <em>emphasized</em>
+   text with a <a href="guide.html">relative link</a>.</p>
+<ul><li>one</li><li>two<ol><li>nested</li></ol></li></ul>
+<pre>func main() {
+	fmt.Println("hi")
+}</pre>
+<blockquote><p>Quoted</p></blockquote>
+<table><tr><th>Name</th><th>Value</th></tr><tr><td>a|b</td><td>1</td></tr></table>
+<img src="/logo.png" alt="Logo"><img src="/spacer.gif">
+</body></html>`
+	base, _ := url.Parse("https://example.com/docs/index.html")
+	title, md, err := htmlToMarkdown(strings.NewReader(page), base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title != "The Title" {
+		t.Errorf("title = %q, want %q", title, "The Title")
+	}
+	want := "Skip\n\n" +
+		"## Usage\n\n" +
+		"Some **bold** and _emphasized_ text with a [relative link](https://example.com/docs/guide.html).\n\n" +
+		"- one\n- two\n  1. nested\n\n" +
+		"```\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n```\n\n" +
+		"> Quoted\n\n" +
+		"| Name | Value |\n| --- | --- |\n| a\\|b | 1 |\n\n" +
+		"![Logo](https://example.com/logo.png)"
+	if md != want {
+		t.Errorf("markdown =\n%s\nwant\n%s", md, want)
+	}
+}
@@ -0,0 +1,253 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/auth"
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// WebSearchToolName is the name of the tool searching the web.
+const WebSearchToolName = "google_web_search"
+
+// defaultSearchModel answers searches unless tools.webSearch.model is set.
+// Grounding with Google Search needs Gemini 2.0 or later.
+const defaultSearchModel = "gemini-2.5-flash"
+
+// searchEndpoint is the base URL of the Gemini API. It is a variable for
+// testing.
+var searchEndpoint = "https://generativelanguage.googleapis.com/v1beta"
+
+var webSearchDeclaration = &genai.FunctionDeclaration{
+	Name: WebSearchToolName,
+	Description: "Searches the web with Google Search and returns an answer grounded in the results, for questions about " +
+		"current events, recent releases or anything else that may have changed since training. Claims in the answer " +
+		"are marked with the numbers of their sources, such as [1]; cite the sources listed when using them.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"query": {
+				Type:        genai.TypeString,
+				Description: "The search query.",
+			},
+		},
+		Required: []string{"query"},
+	},
+}
+
+// WebSearcher answers queries from the web.
+type WebSearcher interface {
+	Search(ctx context.Context, query string) (*SearchResult, error)
+}
+
+// SearchResult is an answer to a search with its sources. Citations in
+// Answer, such as [1], refer to Sources by position from 1.
+type SearchResult struct {
+	Answer  string
+	Sources []SearchSource
+}
+
+// SearchSource is a web page an answer is grounded in.
+type SearchSource struct {
+	Title string
+	URI   string
+}
+
+func webSearch(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	query, err := stringArg(args, "query")
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(query) == "" {
+		return nil, errors.New("query must not be empty")
+	}
+	if ws.Searcher == nil {
+		return nil, errors.New("web search is not available")
+	}
+	res, err := ws.Searcher.Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(res.Answer) == "" {
+		return map[string]any{"answer": fmt.Sprintf("No results were found for %q.", query)}, nil
+	}
+	sources := make([]string, len(res.Sources))
+	for i, s := range res.Sources {
+		sources[i] = fmt.Sprintf("[%d] %s (%s)", i+1, s.Title, s.URI)
+	}
+	result := map[string]any{"answer": res.Answer}
+	if len(sources) > 0 {
+		result["sources"] = sources
+	}
+	return result, nil
+}
+
+// GeminiSearcher answers searches with a Gemini model grounded with Google
+// Search. It authenticates on first use, with the configured authentication
+// type.
+type GeminiSearcher struct {
+	settings *config.Settings
+	model    string
+
+	mu    sync.Mutex
+	token string
+}
+
+// NewGeminiSearcher returns a searcher using the model configured in
+// settings.
+func NewGeminiSearcher(settings *config.Settings) *GeminiSearcher {
+	model := defaultSearchModel
+	if settings != nil && settings.Tools != nil && settings.Tools.WebSearch != nil && settings.Tools.WebSearch.Model != "" {
+		model = settings.Tools.WebSearch.Model
+	}
+	return &GeminiSearcher{settings: settings, model: model}
+}
+
+func (g *GeminiSearcher) Search(ctx context.Context, query string) (*SearchResult, error) {
+	token, err := g.apiKey()
+	if err != nil {
+		return nil, err
+	}
+	reqBody, _ := json.Marshal(map[string]any{
+		"contents": []any{map[string]any{"role": "user", "parts": []any{map[string]any{"text": query}}}},
+		"tools":    []any{map[string]any{"google_search": map[string]any{}}},
+	})
+	endpoint := fmt.Sprintf("%s/models/%s:generateContent", searchEndpoint, url.PathEscape(g.model))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", token)
+	resp, err := (&http.Client{Timeout: httpTimeout}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("web search failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("web search failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error.Message != "" {
+			return nil, fmt.Errorf("web search failed: %s: %s", resp.Status, apiErr.Error.Message)
+		}
+		return nil, fmt.Errorf("web search failed: %s", resp.Status)
+	}
+	var body groundedResponse
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("web search failed: %w", err)
+	}
+	return body.result(), nil
+}
+
+func (g *GeminiSearcher) apiKey() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.token != "" {
+		return g.token, nil
+	}
+	authType := "oauth2"
+	if s := g.settings; s != nil && s.Security != nil && s.Security.Auth != nil && s.Security.Auth.SelectedType != "" {
+		authType = s.Security.Auth.SelectedType
+	}
+	authenticator, _, err := auth.NewAuthenticator(authType)
+	if err != nil {
+		return "", err
+	}
+	token, err := authenticator.GetToken()
+	if err != nil {
+		return "", fmt.Errorf("authentication failed: %w", err)
+	}
+	g.token = token
+	return token, nil
+}
+
+// groundedResponse is the part of a generateContent response grounded with
+// Google Search that the search needs.
+type groundedResponse struct {
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"content"`
+		GroundingMetadata struct {
+			GroundingChunks []struct {
+				Web *struct {
+					URI   string `json:"uri"`
+					Title string `json:"title"`
+				} `json:"web"`
+			} `json:"groundingChunks"`
+			GroundingSupports []struct {
+				Segment struct {
+					EndIndex int `json:"endIndex"`
+				} `json:"segment"`
+				GroundingChunkIndices []int `json:"groundingChunkIndices"`
+			} `json:"groundingSupports"`
+		} `json:"groundingMetadata"`
+	} `json:"candidates"`
+}
+
+// result returns the answer of the first candidate with the sources of each
+// grounded segment cited at its end.
+func (r *groundedResponse) result() *SearchResult {
+	if len(r.Candidates) == 0 {
+		return &SearchResult{}
+	}
+	c := r.Candidates[0]
+	var answer strings.Builder
+	for _, p := range c.Content.Parts {
+		answer.WriteString(p.Text)
+	}
+	res := &SearchResult{}
+	for _, chunk := range c.GroundingMetadata.GroundingChunks {
+		var s SearchSource
+		if chunk.Web != nil {
+			s = SearchSource{Title: chunk.Web.Title, URI: chunk.Web.URI}
+		}
+		res.Sources = append(res.Sources, s)
+	}
+
+	// Segment offsets are in bytes of the UTF-8 text. Citations are inserted
+	// from the end, so that the offsets before them stay valid.
+	type citation struct {
+		at     int
+		marker string
+	}
+	var citations []citation
+	for _, s := range c.GroundingMetadata.GroundingSupports {
+		var marker strings.Builder
+		for _, i := range s.GroundingChunkIndices {
+			if i >= 0 && i < len(res.Sources) {
+				fmt.Fprintf(&marker, "[%d]", i+1)
+			}
+		}
+		if marker.Len() > 0 {
+			citations = append(citations, citation{min(max(s.Segment.EndIndex, 0), answer.Len()), marker.String()})
+		}
+	}
+	sort.SliceStable(citations, func(i, j int) bool { return citations[i].at > citations[j].at })
+	text := answer.String()
+	for _, c := range citations {
+		text = text[:c.at] + c.marker + text[c.at:]
+	}
+	res.Answer = text
+	return res
+}
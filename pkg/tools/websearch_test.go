@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGeminiSearcher(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.URL.Path != "/models/test-model:generateContent" || r.Header.Get("x-goog-api-key") != "key" || body["tools"] == nil {
+			t.Errorf("Unexpected request %s with key %q and body %v", r.URL.Path, r.Header.Get("x-goog-api-key"), body)
+		}
+		w.Write([]byte(`{"candidates": [{
+			"content": {"parts": [{"text": "Go 1.25 is out. Café opens at noon."}]},
+			"groundingMetadata": {
+				"groundingChunks": [
+					{"web": {"uri": "https://go.dev/blog", "title": "go.dev"}},
+					{"web": {"uri": "https://cafe.example", "title": "cafe.example"}}
+				],
+				"groundingSupports": [
+					{"segment": {"endIndex": 15}, "groundingChunkIndices": [0]},
+					{"segment": {"endIndex": 36}, "groundingChunkIndices": [1, 0]}
+				]
+			}
+		}]}`))
+	}))
+	defer srv.Close()
+	orig := searchEndpoint
+	searchEndpoint = srv.URL
+	t.Cleanup(func() { searchEndpoint = orig })
+
+	ws := testWorkspace(t, nil)
+	ws.Searcher = &GeminiSearcher{model: "test-model", token: "key"}
+	resp := runTool(t, ws, WebSearchToolName, map[string]any{"query": "go release"})
+	if want := "Go 1.25 is out.[1] Café opens at noon.[2][1]"; resp["answer"] != want {
+		t.Errorf("answer = %q, want %q", resp["answer"], want)
+	}
+	if got := strings.Join(stringList(resp["sources"]), "\n"); got != "[1] go.dev (https://go.dev/blog)\n[2] cafe.example (https://cafe.example)" {
+		t.Errorf("sources = %q", got)
+	}
+
+	if resp := runTool(t, ws, WebSearchToolName, map[string]any{"query": " "}); resp["error"] == nil {
+		t.Errorf("Expected an empty query to be refused, got %v", resp)
+	}
+}
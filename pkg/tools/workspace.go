@@ -0,0 +1,249 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/browser"
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/index"
+	"github.com/google-gemini/gemini-cli-go/pkg/lsp"
+	"github.com/google-gemini/gemini-cli-go/pkg/mcp"
+	"github.com/google-gemini/gemini-cli-go/pkg/sandbox"
+	"github.com/google-gemini/gemini-cli-go/pkg/usage"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// Workspace is the environment tools run in: the directories they may touch
+// and the settings that configure them.
+type Workspace struct {
+	// Roots are absolute, symlink-free directories. Roots[0] is the working
+	// directory, against which relative paths are resolved.
+	Roots    []string
+	Settings *config.Settings
+	// Confirm asks the user to approve a change or command. Tools that
+	// modify files or run commands refuse to without it, unless Approval
+	// lets them.
+	Confirm Confirmer
+	// Approval is which changes and commands run without asking Confirm.
+	// The zero value asks about all of them.
+	Approval ApprovalMode
+	// Approved, if set, is told about each action Approval approved
+	// without asking, by the title Confirm would have been asked with.
+	Approved func(title string)
+	// Allowed, if not empty, are the only tools that may be used, as
+	// --allowed-tools sets. Entries such as "run_shell_command(git)" also
+	// let the commands they allow run without asking. See Enabled.
+	Allowed []string
+	// TodosChanged, if set, is told about each new plan write_todos
+	// writes.
+	TodosChanged func([]Todo)
+	// ShellStarted, if set, is given each command run_shell_command runs
+	// in a pseudo-terminal, as tools.shell.enableInteractiveShell asks
+	// for, before its output is read. Without it, commands never run in
+	// one, since nobody could answer their prompts.
+	ShellStarted func(*ShellSession)
+	// FilesChanged, if set, is told the files each change of the tools
+	// wrote, relative to the working directory, once they were written.
+	FilesChanged func(paths []string)
+	// Browser drives the browser for the browser tools, which are only
+	// offered if it is set.
+	Browser *browser.Session
+	// LSP runs the language servers behind the code intelligence tools.
+	LSP *lsp.Manager
+	// Embedder embeds queries for semantic_search.
+	Embedder index.Embedder
+	// Searcher answers google_web_search.
+	Searcher WebSearcher
+	// Usage records the tool calls for /insights, if set.
+	Usage *usage.Recorder
+	// Processes, if set, are the commands run_shell_command started in the
+	// background, which it only offers to do with them.
+	Processes *Processes
+	// Sandbox, if set, runs shell commands and file changes in a container
+	// instead of on the host.
+	Sandbox *sandbox.Runner
+	// MCP holds the tools of the MCP servers ConnectMCP connected to,
+	// which are offered next to the others.
+	MCP *mcp.Registry
+
+	// tx stages the file changes of the current turn.
+	tx *Transaction
+	// approvedHosts are the hosts the user has allowed HTTP requests to.
+	approvedHosts map[string]bool
+	// plugins are the tools of the executables in ~/.gemini/tools, by
+	// name, once LoadPlugins loaded them.
+	plugins map[string]*Plugin
+	// results are the results of read-only tools reused in the session.
+	results *resultCache
+	// pruned are the full declarations of the tools last declared without
+	// their parameters, and loaded the tools whose full declaration the
+	// model has asked for since.
+	pruned map[string]*genai.FunctionDeclaration
+	loaded map[string]bool
+}
+
+// Confirmer asks the user whether to go ahead with a change, summarized by
+// title and shown in full by details, such as a diff.
+type Confirmer func(ctx context.Context, title, details string) (bool, error)
+
+// NewWorkspace returns the workspace rooted at the working directory and the
+// configured include directories.
+func NewWorkspace(cfg *config.Settings) (*Workspace, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return NewWorkspaceAt(wd, cfg)
+}
+
+// NewWorkspaceAt returns the workspace rooted at dir and the configured
+// include directories, which are relative to dir.
+func NewWorkspaceAt(dir string, cfg *config.Settings) (*Workspace, error) {
+	if cfg == nil {
+		cfg = &config.Settings{}
+	}
+	dirs := []string{dir}
+	if cfg.Context != nil {
+		dirs = append(dirs, cfg.Context.IncludeDirectories...)
+	}
+
+	// Copies of the workspace, such as the one a request of the TUI runs
+	// tools in, share the approvals, loaded declarations and cached
+	// results of the session.
+	ws := &Workspace{
+		Settings:      cfg,
+		approvedHosts: map[string]bool{},
+		loaded:        map[string]bool{},
+		results:       &resultCache{},
+	}
+	ws.MCP = mcp.NewRegistry(ws.reservedToolName)
+	var lspSettings *config.LSPSettings
+	if cfg.Tools != nil {
+		lspSettings = cfg.Tools.LSP
+	}
+	if cfg.Tools != nil && cfg.Tools.Browser != nil && cfg.Tools.Browser.Enabled {
+		ws.Browser = browser.New(cfg.Tools.Browser)
+	}
+	for _, d := range dirs {
+		if !filepath.IsAbs(d) {
+			d = filepath.Join(dir, d)
+		}
+		abs, err := filepath.Abs(d)
+		if err != nil {
+			return nil, err
+		}
+		if real, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = real
+		}
+		ws.Roots = append(ws.Roots, abs)
+	}
+	if cfg.Tools != nil && cfg.Tools.SandboxScope == sandbox.ScopeTools {
+		// As GEMINI_SANDBOX does for tools.sandbox, GEMINI_SANDBOX_IMAGE
+		// overrides tools.sandboxImage, so that --sandbox-image can.
+		image := os.Getenv("GEMINI_SANDBOX_IMAGE")
+		if image == "" {
+			image = cfg.Tools.SandboxImage
+		}
+		sandboxCfg, err := sandbox.LoadConfig(cfg.Tools.Sandbox, image)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load sandbox config: %w", err)
+		}
+		if sandboxCfg != nil {
+			sandboxCfg.ApplySettings(cfg.Tools)
+			if ws.Sandbox, err = sandbox.NewRunner(sandboxCfg, ws.Roots); err != nil {
+				return nil, err
+			}
+		}
+	}
+	ws.LSP = lsp.NewManager(ws.Roots[0], lspSettings)
+	ws.Embedder = index.NewGeminiEmbedder(cfg)
+	ws.Searcher = NewGeminiSearcher(cfg)
+	var err error
+	if ws.Usage, err = usage.NewRecorder(ws.Roots[0]); err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
+
+// Resolve returns the absolute path of path, which may be relative to the
+// working directory, and checks that it lies within a workspace root once
+// symlinks are resolved.
+func (w *Workspace) Resolve(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(w.Roots[0], path)
+	}
+	path = filepath.Clean(path)
+
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	if w.contains(real) {
+		return real, nil
+	}
+	if w.contains(path) {
+		// The path is inside, but a symbolic link along it leads out.
+		return "", fmt.Errorf("%s is a symbolic link to %s, which is outside the workspace (%s); links leaving the workspace are not followed",
+			path, real, strings.Join(w.Roots, ", "))
+	}
+	return "", fmt.Errorf("%s is outside the workspace (%s)", path, strings.Join(w.Roots, ", "))
+}
+
+// contains reports whether path lies within a workspace root.
+func (w *Workspace) contains(path string) bool {
+	for _, root := range w.Roots {
+		if within(root, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveNew is Resolve for a file that may not exist yet: the nearest
+// existing ancestor must lie within a workspace root.
+func (w *Workspace) ResolveNew(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(w.Roots[0], path)
+	}
+	path = filepath.Clean(path)
+
+	real, err := evalExisting(path)
+	if err != nil {
+		return "", err
+	}
+	if w.contains(real) {
+		return real, nil
+	}
+	return "", fmt.Errorf("%s is outside the workspace (%s)", path, strings.Join(w.Roots, ", "))
+}
+
+// evalExisting evaluates the symbolic links of the nearest existing
+// ancestor of path, which may not exist yet, and returns path through it.
+func evalExisting(path string) (string, error) {
+	dir, rest := path, ""
+	for {
+		if _, err := os.Lstat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = parent
+	}
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(real, rest), nil
+}
+
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
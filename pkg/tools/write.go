@@ -0,0 +1,55 @@
+package tools
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// WriteFileToolName is the name of the tool creating or overwriting a file.
+const WriteFileToolName = "write_file"
+
+var writeFileDeclaration = &genai.FunctionDeclaration{
+	Name: WriteFileToolName,
+	Description: "Writes content to a file in the workspace, creating it and its parent directories if needed, or replacing its entire content if it exists. " +
+		"The user reviews the new content before it is written. " +
+		"Prefer apply_patch for small changes to existing files.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"path": {
+				Type:        genai.TypeString,
+				Description: "The path of the file, relative to the workspace root or absolute.",
+			},
+			"content": {
+				Type:        genai.TypeString,
+				Description: "The complete new content of the file.",
+			},
+		},
+		Required: []string{"path", "content"},
+	},
+}
+
+func writeFile(ctx context.Context, ws *Workspace, args map[string]any) (map[string]any, error) {
+	name, err := stringArg(args, "path")
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, errors.New("path must not be empty")
+	}
+	content, err := stringArg(args, "content")
+	if err != nil {
+		return nil, err
+	}
+
+	staged := ws.tx.clone()
+	c, err := ws.stagedFile(name, staged)
+	if err != nil {
+		return nil, err
+	}
+	c.new, c.delete = content, false
+	ws.tx.replace(staged)
+	return map[string]any{"applied": true, "files": []string{c.summary()}}, nil
+}
@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	ws := testWorkspace(t, nil)
+	root := ws.Roots[0]
+	os.WriteFile(filepath.Join(root, "a.txt"), []byte("old\n"), 0600)
+
+	var previews []string
+	ws.Confirm = func(ctx context.Context, title, details string) (bool, error) {
+		previews = append(previews, details)
+		return true, nil
+	}
+	resp := runTool(t, ws, WriteFileToolName, map[string]any{"path": "a.txt", "content": "new\n"})
+	if resp["applied"] != true {
+		t.Fatalf("Expected the file to be written, got %v", resp)
+	}
+	resp = runTool(t, ws, WriteFileToolName, map[string]any{"path": "sub/b.txt", "content": "hello\n"})
+	if files := stringList(resp["files"]); len(files) != 1 || files[0] != "created "+filepath.Join("sub", "b.txt") {
+		t.Errorf("Expected b.txt to be reported as created, got %v", resp)
+	}
+	if len(previews) != 2 || !strings.Contains(previews[0], "-old\n+new\n") || !strings.Contains(previews[1], "+hello\n") {
+		t.Errorf("Expected a preview of each change, got %q", previews)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(root, "a.txt")); string(data) != "new\n" {
+		t.Errorf("a.txt = %q", data)
+	}
+	if info, _ := os.Stat(filepath.Join(root, "a.txt")); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the mode of a.txt to be kept, got %v", info.Mode())
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "sub", "b.txt")); string(data) != "hello\n" {
+		t.Errorf("sub/b.txt = %q", data)
+	}
+}
+
+func TestWriteFileRejected(t *testing.T) {
+	ws := testWorkspace(t, nil)
+	root := ws.Roots[0]
+	ws.Confirm = func(ctx context.Context, title, details string) (bool, error) { return false, nil }
+
+	resp := runTool(t, ws, WriteFileToolName, map[string]any{"path": "a.txt", "content": "new\n"})
+	if resp["applied"] != false {
+		t.Errorf("Expected the file not to be written, got %v", resp)
+	}
+	if _, err := os.Stat(filepath.Join(root, "a.txt")); !os.IsNotExist(err) {
+		t.Error("Expected a.txt not to be created")
+	}
+
+	ws.Confirm = nil
+	resp = runTool(t, ws, WriteFileToolName, map[string]any{"path": "a.txt", "content": "new\n"})
+	if _, ok := resp["error"]; !ok {
+		t.Errorf("Expected an error without confirmation, got %v", resp)
+	}
+	resp = runTool(t, ws, WriteFileToolName, map[string]any{"path": "../outside.txt", "content": "new\n"})
+	if _, ok := resp["error"]; !ok {
+		t.Errorf("Expected paths outside the workspace to be refused, got %v", resp)
+	}
+}
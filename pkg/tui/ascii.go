@@ -31,4 +31,4 @@ const (
  ███░      ░░█████████
 ░░░         ░░░░░░░░░
 `
-)
\ No newline at end of file
+)
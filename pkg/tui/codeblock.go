@@ -0,0 +1,265 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/lexers"
+	chromastyles "github.com/alecthomas/chroma/v2/styles"
+	"github.com/atotto/clipboard"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/google-gemini/gemini-cli-go/pkg/i18n"
+	"github.com/muesli/termenv"
+)
+
+// codeBlock is a fenced code block in a model response.
+type codeBlock struct {
+	index    int // 1-based number shown in the block header
+	lang     string
+	filename string // filename named by the block, if any
+	code     string
+}
+
+// fileHintPattern matches a leading comment naming the file a block belongs
+// to, such as "// main.go" or "# file: scripts/build.py".
+var fileHintPattern = regexp.MustCompile(`^\s*(?://|#|--|/\*)\s*(?:file(?:name)?:\s*)?([\w./-]+\.\w+)\s*(?:\*/)?\s*$`)
+
+// parseCodeBlocks extracts the fenced code blocks in text, including a final
+// unterminated one.
+func parseCodeBlocks(text string) []*codeBlock {
+	var (
+		blocks  []*codeBlock
+		current *codeBlock
+		lines   []string
+	)
+	finish := func() {
+		current.code = strings.Join(lines, "\n")
+		if current.filename == "" && len(lines) > 0 {
+			if m := fileHintPattern.FindStringSubmatch(lines[0]); m != nil {
+				current.filename = m[1]
+			}
+		}
+		if current.lang == "" {
+			if lexer := lexers.Analyse(current.code); lexer != nil {
+				current.lang = strings.ToLower(lexer.Config().Name)
+			}
+		}
+		blocks = append(blocks, current)
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "```") {
+			if current != nil {
+				lines = append(lines, line)
+			}
+			continue
+		}
+		if current != nil {
+			finish()
+			current, lines = nil, nil
+			continue
+		}
+
+		current = &codeBlock{}
+		for i, field := range strings.Fields(strings.TrimPrefix(trimmed, "```")) {
+			switch {
+			case strings.HasPrefix(field, "title="):
+				current.filename = strings.Trim(strings.TrimPrefix(field, "title="), `"'`)
+			case i == 0:
+				current.lang = strings.ToLower(field)
+			case strings.Contains(field, "."):
+				current.filename = field
+			}
+		}
+	}
+	if current != nil {
+		finish()
+	}
+	return blocks
+}
+
+// suggestedName returns the filename used when saving the block.
+func (b *codeBlock) suggestedName() string {
+	if b.filename != "" {
+		return b.filename
+	}
+	ext := ".txt"
+	if lexer := lexers.Get(b.lang); lexer != nil {
+		for _, pattern := range lexer.Config().Filenames {
+			if strings.HasPrefix(pattern, "*.") {
+				ext = pattern[1:]
+				break
+			}
+		}
+	}
+	return fmt.Sprintf("snippet-%d%s", b.index, ext)
+}
+
+// highlightCode syntax-highlights code for the terminal. Highlighting is
+// skipped when the terminal has no color support.
+func highlightCode(code, lang string) string {
+	if lipgloss.ColorProfile() == termenv.Ascii {
+		return code
+	}
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err != nil {
+		return code
+	}
+	var buf bytes.Buffer
+	if err := formatters.TTY256.Format(&buf, chromastyles.Get("monokai"), iterator); err != nil {
+		return code
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// renderCodeBlock renders a code block with a numbered header, syntax
+// highlighting and line numbers. Long lines are hard-wrapped, preserving
+// indentation, and all lines are padded to the width of the longest one.
+func renderCodeBlock(b *codeBlock, lines []string, width int, s styles) []string {
+	header := fmt.Sprintf("[%d] %s", b.index, tern(b.lang != "", b.lang, "text"))
+	if b.filename != "" {
+		header += " · " + b.filename
+	}
+
+	code := strings.ReplaceAll(strings.Join(lines, "\n"), "\t", "    ")
+	highlighted := strings.Split(highlightCode(code, b.lang), "\n")
+
+	gutterWidth := len(fmt.Sprint(len(lines)))
+	codeWidth := max(width-gutterWidth-3, 1)
+
+	var out []string
+	blockWidth := 0
+	for i, line := range highlighted {
+		for j, part := range strings.Split(wrapCode(line, codeWidth), "\n") {
+			num := fmt.Sprintf("%*d", gutterWidth, i+1)
+			if j > 0 {
+				num = strings.Repeat(" ", gutterWidth)
+			}
+			rendered := s.lineNumber.Render(num+" │ ") + part
+			blockWidth = max(blockWidth, lipgloss.Width(rendered))
+			out = append(out, rendered)
+		}
+	}
+	for i, line := range out {
+		out[i] = line + s.code.Render(strings.Repeat(" ", blockWidth-lipgloss.Width(line)))
+	}
+	return append([]string{s.codeHeader.Render(header)}, out...)
+}
+
+func wrapCode(line string, width int) string {
+	if lipgloss.Width(line) <= width {
+		return line
+	}
+	return wrapHard(line, width)
+}
+
+// blockSelection is the code block selected with Ctrl+B.
+type blockSelection struct {
+	selected int // index into conversation.blocks
+}
+
+// handleBlockKey handles keys while a code block is selected: c copies it,
+// s saves it under its suggested name, a applies it as a file in the
+// workspace after confirmation, up/down select another block and Esc leaves
+// block selection.
+func (m model) handleBlockKey(msg tea.KeyMsg) (model, bool) {
+	if m.blockSelection == nil {
+		return m, false
+	}
+	blocks := m.convo.blocks
+	block := blocks[m.blockSelection.selected]
+
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.blockSelection = nil
+		return m, true
+	case tea.KeyUp:
+		m.blockSelection.selected = max(m.blockSelection.selected-1, 0)
+		return m.scrollToBlock(), true
+	case tea.KeyDown:
+		m.blockSelection.selected = min(m.blockSelection.selected+1, len(blocks)-1)
+		return m.scrollToBlock(), true
+	case tea.KeyRunes:
+	default:
+		return m, true
+	}
+
+	switch string(msg.Runes) {
+	case "c":
+		if err := clipboard.WriteAll(block.code); err != nil {
+			m.convo.add(errorEntry, fmt.Sprintf("Could not copy block %d: %v", block.index, err))
+		} else {
+			m.convo.add(infoEntry, fmt.Sprintf("Copied block %d to the clipboard.", block.index))
+		}
+		m.blockSelection = nil
+	case "s":
+		path, err := saveBlock(block)
+		if err != nil {
+			m.convo.add(errorEntry, fmt.Sprintf("Could not save block %d: %v", block.index, err))
+		} else {
+			m.convo.add(infoEntry, fmt.Sprintf("Saved block %d to %s.", block.index, path))
+		}
+		m.blockSelection = nil
+	case "a":
+		m.blockSelection = nil
+		return m.confirmWriteFile(block.suggestedName(), block.code), true
+	}
+	return m, true
+}
+
+// startBlockSelection selects the most recent code block.
+func (m model) startBlockSelection() model {
+	if len(m.convo.blocks) == 0 {
+		return m
+	}
+	m.blockSelection = &blockSelection{selected: len(m.convo.blocks) - 1}
+	return m.scrollToBlock()
+}
+
+func (m model) scrollToBlock() model {
+	entryIdx := m.convo.blockEntry[m.blockSelection.selected]
+	m.scrollOffset = m.convo.offsetOf(entryIdx, m.viewport.Width, m.viewport.Height, m.styles)
+	return m
+}
+
+// blockStatus is shown in the footer while a code block is selected.
+func (m model) blockStatus() string {
+	b := m.convo.blocks[m.blockSelection.selected]
+	return i18n.T("Block [%d] %s: c copy, s save as %s, a apply to workspace, ↑/↓ select, Esc cancel",
+		b.index, b.lang, b.suggestedName())
+}
+
+// saveBlock writes a block to its suggested name in the working directory,
+// adding a numeric suffix instead of overwriting an existing file.
+func saveBlock(b *codeBlock) (string, error) {
+	name := filepath.Base(b.suggestedName())
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			name = fmt.Sprintf("%s-%d%s", stem, i, ext)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		if _, err := f.WriteString(b.code + "\n"); err != nil {
+			return "", err
+		}
+		return name, nil
+	}
+}
@@ -0,0 +1,138 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google-gemini/gemini-cli-go/pkg/commands"
+	"github.com/google-gemini/gemini-cli-go/pkg/i18n"
+	"github.com/google-gemini/gemini-cli-go/pkg/tools"
+)
+
+// customPromptMsg carries the expanded prompt of a custom command.
+type customPromptMsg struct {
+	display string
+	prompt  string
+}
+
+// customCommand returns the custom command called name, e.g. "/git:commit".
+func (m model) customCommand(name string) *commands.Command {
+	for _, c := range m.commands {
+		if "/"+c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// runCustomCommand sends the prompt of a custom command. If the prompt
+// embeds !{...} shell blocks, they only run once the user confirms, unless
+// the shell tool is allowed in tools.allowed.
+func (m model) runCustomCommand(c *commands.Command, args string) (model, tea.Cmd) {
+	m.textarea.Reset()
+	if !m.inConversation {
+		m.inConversation = true
+	}
+	display := strings.TrimSpace("/" + c.Name + " " + args)
+
+	inv, err := c.Prepare(args)
+	if err != nil {
+		m.convo.add(errorEntry, fmt.Sprintf("/%s: %v", c.Name, err))
+		return m, nil
+	}
+	expand := func(m model) (model, tea.Cmd) {
+		ctx, cancel := context.WithCancel(context.Background())
+		m.cancelRequest = cancel
+		ws := m.workspace
+		return m, safeCmd(func() tea.Msg {
+			prompt, err := inv.Expand(ctx, func(ctx context.Context, command string) (string, int, error) {
+				return tools.RunShell(ctx, ws, ws.Roots[0], command, nil)
+			})
+			if err != nil {
+				return errMsg(fmt.Errorf("/%s: %w", c.Name, err))
+			}
+			return customPromptMsg{display: display, prompt: prompt}
+		})
+	}
+	if len(inv.Shell) == 0 || m.shellAllowed(inv.Shell) {
+		return expand(m)
+	}
+
+	m.convo.add(infoEntry, fmt.Sprintf("/%s wants to run:\n  %s", c.Name, strings.Join(inv.Shell, "\n  ")))
+	m.confirm = &confirmation{
+		prompt: i18n.T("Run %d shell command(s) for /%s? (y/n)", len(inv.Shell), c.Name),
+		onYes:  expand,
+	}
+	return m, nil
+}
+
+// shellAllowed reports whether the shell commands of a custom command may
+// run without confirmation.
+func (m model) shellAllowed(commands []string) bool {
+	if m.settings.Tools == nil {
+		return false
+	}
+	for _, c := range commands {
+		if !tools.AllowsCommand(m.settings.Tools.Allowed, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// customCommandsHelp lists the custom commands for /help.
+func (m model) customCommandsHelp() string {
+	if len(m.commands) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\n" + i18n.T("Custom Commands:"))
+	for _, c := range m.commands {
+		fmt.Fprintf(&b, "\n  /%-9s %s", c.Name, c.Description)
+	}
+	return b.String()
+}
+
+// toolsCommand runs /tools, listing the available tools, or /tools desc
+// <name>, describing one.
+func (m model) toolsCommand(args string) model {
+	m.textarea.Reset()
+	if !m.inConversation {
+		m.inConversation = true
+	}
+
+	sub, name, _ := strings.Cut(strings.TrimSpace(args), " ")
+	name = strings.TrimSpace(name)
+	var b strings.Builder
+	switch {
+	case sub == "":
+		b.WriteString("Available tools:")
+		for _, d := range m.workspace.Declarations() {
+			b.WriteString("\n  " + m.toolLabel(d.Name))
+		}
+	case sub == "desc" && name == "":
+		for i, d := range m.workspace.Declarations() {
+			if i > 0 {
+				b.WriteString("\n\n")
+			}
+			fmt.Fprintf(&b, "%s\n  %s", m.toolLabel(d.Name), d.Description)
+		}
+	case sub == "desc":
+		for _, d := range m.workspace.Declarations() {
+			if m.namesTool(name, d.Name) {
+				fmt.Fprintf(&b, "%s\n  %s", m.toolLabel(d.Name), d.Description)
+			}
+		}
+		if b.Len() == 0 {
+			m.convo.add(errorEntry, fmt.Sprintf("Unknown tool %q.", name))
+			return m
+		}
+	default:
+		m.convo.add(errorEntry, "Usage: /tools [desc [name]]")
+		return m
+	}
+	m.convo.add(infoEntry, b.String())
+	return m
+}
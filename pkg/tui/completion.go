@@ -0,0 +1,230 @@
+package tui
+
+import (
+	"cmp"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google-gemini/gemini-cli-go/pkg/i18n"
+)
+
+// maxSuggestions is the number of suggestions shown at once.
+const maxSuggestions = 8
+
+// slashCommand describes a built-in slash command for completion.
+type slashCommand struct {
+	name        string
+	description string
+	// complete returns the candidates for the last word of args, given the
+	// words before it.
+	complete func(m model, words []string) []suggestion
+}
+
+// slashCommands are the built-in commands offered by completion.
+var slashCommands = []slashCommand{
+	{name: "/help", description: "Show help"},
+	{name: "/find", description: "Search the conversation"},
+	{name: "/compress", description: "Replace the history with a summary"},
+	{name: "/context", description: "Show what uses the context"},
+	{name: "/retry", description: "Regenerate the last response"},
+	{name: "/mcp", description: "Inspect MCP servers", complete: completeMCP},
+	{name: "/tools", description: "List the available tools", complete: completeTools},
+	{name: "/settings", description: "Edit settings"},
+	{name: "/restore", description: "Undo file changes"},
+	{name: "/insights", description: "Show tool usage insights"},
+	{name: "/model", description: "Switch the model"},
+	{name: "/debug-api", description: "Log the API traffic", complete: completeDebugAPI},
+	{name: "/speak", description: "Speak the responses", complete: completeSpeak},
+	{name: "/ps", description: "List the background commands", complete: completePS},
+	{name: "/quit", description: "Exit the application"},
+}
+
+// suggestion is a completion candidate.
+type suggestion struct {
+	value       string
+	description string
+}
+
+// completion is the popup of suggestions for the word being typed.
+type completion struct {
+	items    []suggestion
+	selected int
+	// from is the offset in the input of the word the suggestions replace.
+	from int
+}
+
+// suggest returns the completions for input: command names after "/",
+// command arguments, and paths after "@".
+func (m model) suggest(input string) *completion {
+	var (
+		items []suggestion
+		from  int
+		word  string
+	)
+	switch name, args, hasArgs := strings.Cut(input, " "); {
+	case strings.HasPrefix(input, "/") && !hasArgs:
+		word = name
+		for _, c := range slashCommands {
+			items = append(items, suggestion{value: c.name, description: i18n.T(c.description)})
+		}
+		for _, c := range m.commands {
+			items = append(items, suggestion{value: "/" + c.Name, description: c.Description})
+		}
+		for _, p := range m.mcpPrompts() {
+			items = append(items, suggestion{value: promptCommand(p), description: p.Prompt.Description})
+		}
+	case strings.HasPrefix(input, "/"):
+		words := strings.Split(args, " ")
+		word = words[len(words)-1]
+		from = len(input) - len(word)
+		for _, c := range slashCommands {
+			if c.name == name && c.complete != nil {
+				items = c.complete(m, words[:len(words)-1])
+			}
+		}
+	default:
+		at := strings.LastIndex(input, "@")
+		if at < 0 || strings.ContainsAny(input[at:], " \t") {
+			return nil
+		}
+		from = at + 1
+		word = input[from:]
+		items = completePath(word)
+		if m.workspace.MCP != nil {
+			for _, r := range m.workspace.MCP.Resources() {
+				items = append(items, suggestion{value: resourceRef(r), description: cmp.Or(r.Resource.Name, r.Resource.Description)})
+			}
+		}
+	}
+
+	var matches []suggestion
+	for _, s := range items {
+		if strings.HasPrefix(s.value, word) && s.value != word {
+			matches = append(matches, s)
+		}
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+	return &completion{items: matches, from: from}
+}
+
+// handleCompletionKey handles Tab to accept the selected suggestion, up/down
+// to select and Esc to close the popup.
+func (m model) handleCompletionKey(msg tea.KeyMsg) (model, bool) {
+	if m.completion == nil {
+		return m, false
+	}
+	c := m.completion
+	switch msg.Type {
+	case tea.KeyTab:
+		value := m.textarea.Value()[:c.from] + c.items[c.selected].value
+		if !strings.HasSuffix(value, "/") {
+			value += " "
+		}
+		m.textarea.SetValue(value)
+		m.textarea.CursorEnd()
+		m.completion = m.suggest(value)
+	case tea.KeyUp:
+		c.selected = (c.selected - 1 + len(c.items)) % len(c.items)
+	case tea.KeyDown:
+		c.selected = (c.selected + 1) % len(c.items)
+	case tea.KeyEsc:
+		m.completion = nil
+	default:
+		return m, false
+	}
+	return m, true
+}
+
+// renderCompletion renders the popup, scrolled to keep the selection visible.
+func (m model) renderCompletion() string {
+	c := m.completion
+	start := max(c.selected-maxSuggestions+1, 0)
+	end := min(start+maxSuggestions, len(c.items))
+
+	width := 0
+	for _, s := range c.items[start:end] {
+		width = max(width, len(s.value))
+	}
+	var lines []string
+	for i := start; i < end; i++ {
+		s := c.items[i]
+		line := s.value + strings.Repeat(" ", width-len(s.value))
+		if s.description != "" {
+			line += "  " + s.description
+		}
+		if i == c.selected {
+			line = m.styles.highlight.Render(line)
+		}
+		lines = append(lines, "  "+line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// completePath lists the files and directories matching a partial path.
+// Hidden entries are only offered once a "." has been typed.
+func completePath(partial string) []suggestion {
+	dir, base := filepath.Split(partial)
+	entries, err := os.ReadDir(filepath.Join(".", dir))
+	if err != nil {
+		return nil
+	}
+	var items []suggestion
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		value := dir + e.Name()
+		if e.IsDir() {
+			value += "/"
+		}
+		items = append(items, suggestion{value: value})
+	}
+	return items
+}
+
+func completeMCP(m model, words []string) []suggestion {
+	switch len(words) {
+	case 0:
+		return []suggestion{
+			{value: "logs", description: "Show a server's stderr output"},
+			{value: "auth", description: "Authorize with a server that requires OAuth"},
+			{value: "resources", description: "List the resources of the servers"},
+		}
+	case 1:
+		if words[0] != "logs" && words[0] != "auth" && words[0] != "resources" {
+			return nil
+		}
+		var items []suggestion
+		for name, server := range m.settings.MCPServers {
+			if words[0] == "auth" && server.Command != "" {
+				continue
+			}
+			items = append(items, suggestion{value: name, description: server.Description})
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].value < items[j].value })
+		return items
+	}
+	return nil
+}
+
+func completeTools(m model, words []string) []suggestion {
+	switch len(words) {
+	case 0:
+		return []suggestion{{value: "desc", description: "Describe a tool"}}
+	case 1:
+		if words[0] != "desc" {
+			return nil
+		}
+		var items []suggestion
+		for _, d := range m.workspace.Declarations() {
+			items = append(items, suggestion{value: d.Name})
+		}
+		return items
+	}
+	return nil
+}
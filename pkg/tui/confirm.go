@@ -0,0 +1,203 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/diff"
+	"github.com/google-gemini/gemini-cli-go/pkg/i18n"
+	"github.com/google-gemini/gemini-cli-go/pkg/tools"
+)
+
+// confirmation is a yes/no question shown in place of the footer. While it
+// is pending, y, n and Esc are captured instead of being typed, as are m
+// and a if the question offers to modify what is proposed or to always
+// allow it.
+type confirmation struct {
+	prompt   string
+	onYes    func(model) (model, tea.Cmd)
+	onModify func(model) (model, tea.Cmd)
+	onAlways func(model) (model, tea.Cmd)
+	// onNo, if set, is called instead of reporting the cancellation.
+	onNo func(model) (model, tea.Cmd)
+}
+
+// handleConfirmKey answers a pending confirmation. Other keys are swallowed
+// so that the question cannot be skipped accidentally.
+func (m model) handleConfirmKey(msg tea.KeyMsg) (model, tea.Cmd, bool) {
+	if m.confirm == nil {
+		return m, nil, false
+	}
+	c := m.confirm
+	switch strings.ToLower(msg.String()) {
+	case "y":
+		m.confirm = nil
+		m, cmd := c.onYes(m)
+		return m, cmd, true
+	case "m":
+		if c.onModify != nil {
+			m.confirm = nil
+			m, cmd := c.onModify(m)
+			return m, cmd, true
+		}
+	case "a":
+		if c.onAlways != nil {
+			m.confirm = nil
+			m, cmd := c.onAlways(m)
+			return m, cmd, true
+		}
+	case "n", "esc":
+		m.confirm = nil
+		if c.onNo != nil {
+			m, cmd := c.onNo(m)
+			return m, cmd, true
+		}
+		m.convo.add(infoEntry, i18n.T("Cancelled."))
+	}
+	return m, nil, true
+}
+
+// toolConfirmMsg asks the user to approve a change or command of a tool
+// the model called. The request waits for the answer on answer. rule, if
+// set, is the tools.allowed entry that would approve it from now on.
+type toolConfirmMsg struct {
+	title, details, rule string
+	answer               chan<- bool
+}
+
+// confirmTool is the tools.Confirmer of the TUI. It runs in the goroutine
+// of the request and asks the user through the program.
+func confirmTool(ctx context.Context, title, details string) (bool, error) {
+	answer := make(chan bool, 1)
+	if !term.send(toolConfirmMsg{title: title, details: details, rule: tools.AllowRule(ctx), answer: answer}) {
+		return false, errors.New("there is no terminal to ask the user on")
+	}
+	select {
+	case ok := <-answer:
+		return ok, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// confirmToolCall shows what a tool is about to do and asks the user to
+// approve it.
+func (m model) confirmToolCall(msg toolConfirmMsg) model {
+	m.inConversation = true
+	if msg.details != "" {
+		kind := infoEntry
+		if strings.HasPrefix(msg.details, "--- ") {
+			kind = diffEntry
+		}
+		m.convo.add(kind, strings.TrimSuffix(msg.details, "\n"))
+	}
+	m.scrollOffset = 0
+	m.confirm = &confirmation{
+		prompt: msg.title + " (y/n)",
+		onYes: func(m model) (model, tea.Cmd) {
+			msg.answer <- true
+			return m, nil
+		},
+		onNo: func(m model) (model, tea.Cmd) {
+			msg.answer <- false
+			return m, nil
+		},
+	}
+	if msg.rule != "" {
+		m.confirm.prompt = msg.title + " " + i18n.T("(y/n, a to always allow)")
+		m.confirm.onAlways = func(m model) (model, tea.Cmd) {
+			m = m.alwaysAllow(msg.rule)
+			msg.answer <- true
+			return m, nil
+		}
+	}
+	return m
+}
+
+// alwaysAllow adds rule to tools.allowed in the user settings, so that
+// what it approves is not asked about again, in this session or later
+// ones. The tools only offer rules covering all the user was asked about,
+// so commands run with variables come without one.
+func (m model) alwaysAllow(rule string) model {
+	user, err := config.LoadScope(config.UserScope)
+	if err == nil {
+		t := toolsSettings(user)
+		if !slices.Contains(t.Allowed, rule) {
+			t.Allowed = append(t.Allowed, rule)
+		}
+		err = config.SaveScope(config.UserScope, user)
+	}
+	if err != nil {
+		m.convo.add(errorEntry, i18n.T("Could not save %s to tools.allowed: %v", rule, err))
+		return m
+	}
+	if t := toolsSettings(m.settings); !slices.Contains(t.Allowed, rule) {
+		t.Allowed = append(t.Allowed, rule)
+	}
+	m.convo.add(infoEntry, i18n.T("Added %s to tools.allowed in the user settings.", rule))
+	return m
+}
+
+// confirmWriteFile asks before writing content to path, which must be inside
+// the workspace. Changes to an existing file are shown as a diff. The
+// content can first be modified in the preferred editor, opened on a diff
+// against the existing file where the editor supports it.
+func (m model) confirmWriteFile(path, content string) model {
+	abs, shown, err := m.workspacePath(path)
+	if err != nil {
+		m.convo.add(errorEntry, err.Error())
+		return m
+	}
+
+	question, original := "Create %s (%d lines)? (y/n, m to modify)", ""
+	if existing, err := os.ReadFile(abs); err == nil {
+		question, original = "Overwrite %s (%d lines)? (y/n, m to modify)", abs
+		if hunks := diff.Unified(string(existing), content+"\n", 3); hunks != "" {
+			m.inConversation = true
+			m.convo.add(diffEntry, hunks)
+		}
+	}
+	lines := strings.Count(content, "\n") + 1
+	m.confirm = &confirmation{
+		prompt: i18n.T(question, shown, lines),
+		onModify: func(m model) (model, tea.Cmd) {
+			return m.editContent(path, content+"\n", original, func(m model, content string) (model, tea.Cmd) {
+				return m.confirmWriteFile(path, strings.TrimSuffix(content, "\n")), nil
+			})
+		},
+		onYes: func(m model) (model, tea.Cmd) {
+			if err := os.MkdirAll(filepath.Dir(abs), 0755); err != nil {
+				m.convo.add(errorEntry, fmt.Sprintf("Could not write %s: %v", shown, err))
+				return m, nil
+			}
+			if err := os.WriteFile(abs, []byte(content+"\n"), 0644); err != nil {
+				m.convo.add(errorEntry, fmt.Sprintf("Could not write %s: %v", shown, err))
+				return m, nil
+			}
+			m.convo.add(infoEntry, fmt.Sprintf("Wrote %s.", shown))
+			return m, nil
+		},
+	}
+	return m
+}
+
+// workspacePath resolves path the way the file tools do, following
+// symbolic links, and returns it along with the name to show for it,
+// relative to the main workspace root if it lies within it.
+func (m model) workspacePath(path string) (abs, shown string, err error) {
+	if abs, err = m.workspace.ResolveNew(path); err != nil {
+		return "", "", fmt.Errorf("refusing to write %s: %v", path, err)
+	}
+	shown = abs
+	if rel, err := filepath.Rel(m.workspace.Roots[0], abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		shown = rel
+	}
+	return abs, shown, nil
+}
@@ -0,0 +1,230 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/google-gemini/gemini-cli-go/pkg/i18n"
+	"github.com/google/generative-ai-go/genai"
+)
+
+const (
+	// Context usage at or above these percentages is shown in yellow and red.
+	contextWarnPercent = 60
+	contextHighPercent = 85
+
+	// defaultTokenLimit is assumed when the model's input token limit cannot
+	// be looked up.
+	defaultTokenLimit = 1_048_576
+
+	// compressKeepPercent is the share of the history's tokens /compress
+	// keeps verbatim, taken from the most recent turns.
+	compressKeepPercent = 30
+
+	compressPrompt = "Summarize our conversation so far so that it can replace the full history. " +
+		"Keep every fact, decision, file name and open question needed to continue the work, " +
+		"and leave out pleasantries."
+)
+
+// contextUsage is the estimated number of tokens the chat history uses out
+// of the model's input token limit.
+type contextUsage struct {
+	used  int32
+	limit int32
+}
+
+// contextUsageMsg carries a new token count for the chat history.
+type contextUsageMsg contextUsage
+
+// compressedMsg carries the summary that replaces the older part of the
+// chat history, and the recent part kept after it.
+type compressedMsg struct {
+	summary string
+	kept    []*genai.Content
+}
+
+// countTokens estimates the size of the chat history with CountTokens. The
+// model's token limit is looked up the first time.
+func (m model) countTokens() tea.Cmd {
+	if m.client == nil || m.chat == nil {
+		return nil
+	}
+	var parts []genai.Part
+	for _, c := range m.chat.History {
+		parts = append(parts, c.Parts...)
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	client, limit := m.client, m.usage.limit
+
+	return func() tea.Msg {
+		ctx := context.Background()
+		resp, err := client.CountTokens(ctx, parts...)
+		if err != nil {
+			// The gauge is best effort; keep showing the last estimate.
+			return nil
+		}
+		if limit == 0 {
+			limit = defaultTokenLimit
+			if info, err := client.Info(ctx); err == nil && info.InputTokenLimit > 0 {
+				limit = info.InputTokenLimit
+			}
+		}
+		return contextUsageMsg{used: resp.TotalTokens, limit: limit}
+	}
+}
+
+// compress runs /compress: it asks the model to summarize the conversation
+// up to its most recent turns and replaces that part of the chat history
+// with the summary.
+func (m model) compress() (model, tea.Cmd) {
+	m.textarea.Reset()
+	if !m.inConversation {
+		m.inConversation = true
+	}
+	if m.client == nil || m.chat == nil || len(m.chat.History) == 0 {
+		m.convo.add(infoEntry, "Nothing to compress.")
+		return m, nil
+	}
+
+	m.convo.add(infoEntry, "Compressing the conversation...")
+	client, history := m.client, slices.Clone(m.chat.History)
+	cut := splitHistory(history, compressKeepPercent)
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelRequest = cancel
+
+	return m, func() tea.Msg {
+		summary, err := summarize(ctx, client, history[:cut])
+		if err != nil {
+			return errMsg(fmt.Errorf("failed to compress conversation: %w", err))
+		}
+		return compressedMsg{summary: summary, kept: history[cut:]}
+	}
+}
+
+// splitHistory returns where the most recent turns of history that take up
+// at most percent of its estimated tokens start. Turns start at prompts, so
+// a tool call is never separated from its response. It returns
+// len(history) if even the last turn is larger than that, and never 0, so
+// that there is always something to summarize.
+func splitHistory(history []*genai.Content, percent int64) int {
+	var total int64
+	for _, c := range history {
+		total += int64(contentTokens(c))
+	}
+	budget := total * percent / 100
+
+	cut := len(history)
+	var kept int64
+	for i := len(history) - 1; i > 0; i-- {
+		kept += int64(contentTokens(history[i]))
+		if kept > budget {
+			break
+		}
+		if startsTurn(history[i]) {
+			cut = i
+		}
+	}
+	return cut
+}
+
+// startsTurn reports whether c is a prompt of the user rather than the
+// answer to a tool call.
+func startsTurn(c *genai.Content) bool {
+	if c.Role != "user" {
+		return false
+	}
+	for _, p := range c.Parts {
+		switch p.(type) {
+		case genai.FunctionResponse, *genai.FunctionResponse:
+			return false
+		}
+	}
+	return true
+}
+
+// summarize asks model for a summary of history that can replace it.
+func summarize(ctx context.Context, model *genai.GenerativeModel, history []*genai.Content) (string, error) {
+	cs := model.StartChat()
+	cs.History = history
+	resp, err := cs.SendMessage(ctx, genai.Text(compressPrompt))
+	if err != nil {
+		return "", err
+	}
+	var summary strings.Builder
+	for _, cand := range resp.Candidates {
+		if cand.Content == nil {
+			continue
+		}
+		for _, part := range cand.Content.Parts {
+			if text, ok := part.(genai.Text); ok {
+				summary.WriteString(string(text))
+			}
+		}
+	}
+	return summary.String(), nil
+}
+
+// summaryHistory is the chat history that replaces the conversation
+// summary summarizes.
+func summaryHistory(summary string) []*genai.Content {
+	return []*genai.Content{
+		{Role: "user", Parts: []genai.Part{genai.Text("Summary of our conversation so far:\n\n" + summary)}},
+		{Role: "model", Parts: []genai.Part{genai.Text("Got it. Let's continue from there.")}},
+	}
+}
+
+// applyCompression replaces the older part of the chat history with the
+// summary of msg.
+func (m model) applyCompression(msg compressedMsg) model {
+	m.cancelRequest = nil
+	if m.chat == nil || strings.TrimSpace(msg.summary) == "" {
+		m.convo.add(errorEntry, "Could not compress the conversation: the summary was empty.")
+		return m
+	}
+	m.chat.History = append(summaryHistory(msg.summary), msg.kept...)
+	if len(msg.kept) > 0 {
+		m.convo.add(infoEntry, fmt.Sprintf("Conversation compressed, keeping the %d most recent message(s).", len(msg.kept)))
+		return m
+	}
+	m.convo.add(infoEntry, "Conversation compressed.")
+	return m
+}
+
+// contextGauge renders the context usage shown in the footer, or "" before
+// the first estimate.
+func (m model) contextGauge() string {
+	u := m.usage
+	if u.limit <= 0 {
+		return ""
+	}
+	percent := int(int64(u.used) * 100 / int64(u.limit))
+	gauge := i18n.T("context: %d%% used (%s/%s tokens)", percent, formatTokens(u.used), formatTokens(u.limit))
+
+	color := "2"
+	switch {
+	case percent >= contextHighPercent:
+		color = "1"
+		gauge += i18n.T(" - run /compress")
+	case percent >= contextWarnPercent:
+		color = "3"
+	}
+	return lipgloss.NewStyle().Foreground(lipgloss.Color(color)).Render(gauge)
+}
+
+// formatTokens abbreviates a token count, e.g. 41k or 1.0M.
+func formatTokens(n int32) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%dk", n/1_000)
+	default:
+		return fmt.Sprint(n)
+	}
+}
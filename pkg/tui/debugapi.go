@@ -0,0 +1,42 @@
+package tui
+
+import (
+	"github.com/google-gemini/gemini-cli-go/pkg/i18n"
+)
+
+// debugAPICommand runs /debug-api: "on" and "off" start and stop logging
+// the API traffic, and no argument toggles it.
+func (m model) debugAPICommand(args string) model {
+	m.textarea.Reset()
+	if !m.inConversation {
+		m.inConversation = true
+	}
+	switch args {
+	case "":
+		m.apiLog.SetEnabled(!m.apiLog.Enabled())
+	case "on":
+		m.apiLog.SetEnabled(true)
+	case "off":
+		m.apiLog.SetEnabled(false)
+	default:
+		m.convo.add(errorEntry, i18n.T("Usage: /debug-api [on|off]"))
+		return m
+	}
+	if m.apiLog.Enabled() {
+		m.convo.add(infoEntry, i18n.T("Logging the API traffic to %s.", m.apiLog.Path()))
+	} else {
+		m.convo.add(infoEntry, i18n.T("Stopped logging the API traffic."))
+	}
+	return m
+}
+
+// completeDebugAPI offers the /debug-api arguments.
+func completeDebugAPI(m model, words []string) []suggestion {
+	if len(words) > 0 {
+		return nil
+	}
+	return []suggestion{
+		{value: "on", description: "Start logging"},
+		{value: "off", description: "Stop logging"},
+	}
+}
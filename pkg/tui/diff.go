@@ -0,0 +1,92 @@
+package tui
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/diff"
+)
+
+// renderDiff renders unified diff hunks, optionally preceded by ---/+++
+// file headers. Runs of removed lines followed by
+// added lines are paired up line by line: the words that changed within a
+// pair are highlighted, and pairs that differ only in whitespace are dimmed
+// so that the substantive changes stand out.
+func renderDiff(text string, width int, s styles) string {
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	var out []string
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			out = append(out, s.codeHeader.Render(line))
+			i++
+			continue
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			out = append(out, s.codeHeader.Render(line), s.codeHeader.Render(lines[i+1]))
+			i += 2
+			continue
+		case !strings.HasPrefix(line, "-") && !strings.HasPrefix(line, "+"):
+			// Styled lines have their tabs expanded by lipgloss.
+			out = append(out, strings.ReplaceAll(line, "\t", "    "))
+			i++
+			continue
+		}
+
+		var removed, added []string
+		for ; i < len(lines) && strings.HasPrefix(lines[i], "-"); i++ {
+			removed = append(removed, lines[i][1:])
+		}
+		for ; i < len(lines) && strings.HasPrefix(lines[i], "+"); i++ {
+			added = append(added, lines[i][1:])
+		}
+		paired := min(len(removed), len(added))
+		for j, r := range removed {
+			if j < paired {
+				out = append(out, renderChangedLine(diff.Delete, r, added[j], s))
+			} else {
+				out = append(out, s.diffDelete.Render("-"+r))
+			}
+		}
+		for j, a := range added {
+			if j < paired {
+				out = append(out, renderChangedLine(diff.Insert, removed[j], a, s))
+			} else {
+				out = append(out, s.diffInsert.Render("+"+a))
+			}
+		}
+	}
+
+	for i, line := range out {
+		out[i] = wrapHard(line, width)
+	}
+	return strings.Join(out, "\n")
+}
+
+// renderChangedLine renders one side of a changed line, the old one for
+// diff.Delete and the new one for diff.Insert.
+func renderChangedLine(side diff.Op, old, new string, s styles) string {
+	base, word, marker := s.diffDelete, s.diffDeleteWord, "-"
+	if side == diff.Insert {
+		base, word, marker = s.diffInsert, s.diffInsertWord, "+"
+	}
+	if slices.Equal(strings.Fields(old), strings.Fields(new)) {
+		text := old
+		if side == diff.Insert {
+			text = new
+		}
+		return s.diffDim.Render(marker + text)
+	}
+
+	var b strings.Builder
+	b.WriteString(base.Render(marker))
+	for _, e := range diff.Words(old, new) {
+		switch e.Op {
+		case diff.Equal:
+			b.WriteString(base.Render(e.Text))
+		case side:
+			b.WriteString(word.Render(e.Text))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/editor"
+)
+
+// editedMsg is sent when the external editor exits.
+type editedMsg struct {
+	path string
+	err  error
+	done func(m model, content string) (model, tea.Cmd)
+}
+
+// preferredEditor resolves general.preferredEditor. An editor detected on
+// first use is saved to the user settings, so the choice stays stable and
+// shows up in /settings.
+func (m model) preferredEditor() (model, *editor.Editor, error) {
+	var preferred string
+	if m.settings.General != nil {
+		preferred = m.settings.General.PreferredEditor
+	}
+	e, err := editor.Resolve(preferred)
+	if err != nil || !e.Detected {
+		return m, e, err
+	}
+
+	m.inConversation = true
+	user, err := config.LoadScope(config.UserScope)
+	if err == nil {
+		general(user).PreferredEditor = e.Name
+		err = config.SaveScope(config.UserScope, user)
+	}
+	if err != nil {
+		m.convo.add(errorEntry, fmt.Sprintf("Could not save the preferred editor: %v", err))
+	} else {
+		general(m.settings).PreferredEditor = e.Name
+		m.convo.add(infoEntry, fmt.Sprintf("Using %s as the editor. Change general.preferredEditor with /settings.", e.Name))
+	}
+	return m, e, nil
+}
+
+// editContent opens content in the preferred editor, suspending the TUI, and
+// calls done with the edited content. If original is set, the editor shows
+// the content as a diff against that file where it can.
+func (m model) editContent(name, content, original string, done func(model, string) (model, tea.Cmd)) (model, tea.Cmd) {
+	m, e, err := m.preferredEditor()
+	if err != nil {
+		m.inConversation = true
+		m.convo.add(errorEntry, err.Error())
+		return m, nil
+	}
+	path, err := editor.TempFile(name, content)
+	if err != nil {
+		m.inConversation = true
+		m.convo.add(errorEntry, fmt.Sprintf("Could not create a file to edit: %v", err))
+		return m, nil
+	}
+
+	cmd := e.Edit(path)
+	if original != "" {
+		cmd = e.Diff(original, path)
+	}
+	return m, tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return editedMsg{path: path, err: err, done: done}
+	})
+}
+
+// finishEdit reads back the edited file.
+func (m model) finishEdit(msg editedMsg) (model, tea.Cmd) {
+	defer os.Remove(msg.path)
+	if msg.err != nil {
+		m.inConversation = true
+		m.convo.add(errorEntry, fmt.Sprintf("Editor failed: %v", msg.err))
+		return m, nil
+	}
+	content, err := os.ReadFile(msg.path)
+	if err != nil {
+		m.inConversation = true
+		m.convo.add(errorEntry, fmt.Sprintf("Could not read the edited file: %v", err))
+		return m, nil
+	}
+	return msg.done(m, string(content))
+}
+
+// editPrompt runs on Ctrl+X: it opens the input in the preferred editor and
+// replaces it with the result.
+func (m model) editPrompt() (model, tea.Cmd) {
+	input := m.expandPastes(m.textarea.Value())
+	return m.editContent("prompt.md", input, "", func(m model, content string) (model, tea.Cmd) {
+		m.pastes = nil
+		m.textarea.Reset()
+		content = strings.TrimRight(content, "\n")
+		if strings.Contains(content, "\n") {
+			return m.insertBlock(content), nil
+		}
+		m.textarea.SetValue(content)
+		return m, nil
+	})
+}
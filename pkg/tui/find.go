@@ -0,0 +1,111 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google-gemini/gemini-cli-go/pkg/i18n"
+)
+
+// search is the state of a /find in the conversation.
+type search struct {
+	query   string
+	matches []int // indices of matching entries
+	current int
+}
+
+func (s *search) active() bool {
+	return s != nil && s.query != ""
+}
+
+// startFind runs /find <text>, searching the conversation case-insensitively
+// and jumping to the most recent match.
+func (m model) startFind(query string) model {
+	m.textarea.Reset()
+	if !m.inConversation {
+		m.inConversation = true
+	}
+
+	query = strings.TrimSpace(query)
+	if query == "" {
+		m.convo.add(errorEntry, "Usage: /find <text>")
+		return m
+	}
+
+	var matches []int
+	lower := strings.ToLower(query)
+	for i, e := range m.convo.entries {
+		if e.kind != imageEntry && strings.Contains(strings.ToLower(e.content()), lower) {
+			matches = append(matches, i)
+		}
+	}
+	if len(matches) == 0 {
+		m.search = nil
+		m.convo.setHighlight("")
+		m.convo.add(infoEntry, fmt.Sprintf("No matches for %q.", query))
+		return m
+	}
+
+	m.search = &search{query: query, matches: matches, current: len(matches) - 1}
+	m.convo.setHighlight(query)
+	return m.jumpToMatch()
+}
+
+// handleFindKey handles n/N navigation and Esc while a search is active and
+// the input is empty. It reports whether the key was consumed.
+func (m model) handleFindKey(msg tea.KeyMsg) (model, bool) {
+	if !m.search.active() {
+		return m, false
+	}
+	if msg.Type == tea.KeyEsc {
+		return m.endFind(), true
+	}
+	if m.textarea.Value() != "" || msg.Type != tea.KeyRunes || len(msg.Runes) != 1 {
+		return m, false
+	}
+
+	n := len(m.search.matches)
+	switch msg.Runes[0] {
+	case 'n':
+		m.search.current = (m.search.current + 1) % n
+	case 'N':
+		m.search.current = (m.search.current - 1 + n) % n
+	default:
+		return m, false
+	}
+	return m.jumpToMatch(), true
+}
+
+func (m model) endFind() model {
+	m.search = nil
+	m.convo.setHighlight("")
+	m.scrollOffset = 0
+	return m
+}
+
+// jumpToMatch scrolls so the current match is at the top of the viewport.
+func (m model) jumpToMatch() model {
+	idx := m.search.matches[m.search.current]
+	m.scrollOffset = m.convo.offsetOf(idx, m.viewport.Width, m.viewport.Height, m.styles)
+	return m
+}
+
+// findStatus is shown in the footer while a search is active.
+func (m model) findStatus() string {
+	return i18n.T("Match %d of %d for %q (n/N to navigate, Esc to close)",
+		m.search.current+1, len(m.search.matches), m.search.query)
+}
+
+// highlightMatches wraps case-insensitive occurrences of query in text with
+// the highlight style.
+func highlightMatches(text, query string, s styles) string {
+	if query == "" {
+		return text
+	}
+	re := regexp.MustCompile("(?i)" + regexp.QuoteMeta(query))
+	return re.ReplaceAllStringFunc(text, func(match string) string {
+		return s.highlight.Render(match)
+	})
+}
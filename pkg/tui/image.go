@@ -0,0 +1,256 @@
+package tui
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"mime"
+	"os"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// imageRows is the height, in terminal rows, reserved for an inline image.
+const imageRows = 15
+
+// sixelCellWidth and sixelCellHeight guess the size of a terminal cell in
+// pixels. Sixel images are sized in pixels rather than rows, so they are
+// scaled to fit that many cells. The guess errs small so that the image
+// stays within its rows.
+const (
+	sixelCellWidth  = 8
+	sixelCellHeight = 16
+)
+
+// imageProtocol is a terminal graphics protocol.
+type imageProtocol int
+
+const (
+	noImageProtocol imageProtocol = iota
+	kittyProtocol
+	iTermProtocol
+	sixelProtocol
+)
+
+// For testing purposes
+var getenv = os.Getenv
+
+// detectImageProtocol guesses the graphics protocol supported by the terminal
+// from the environment, since querying the terminal would race with
+// bubbletea's input reader.
+func detectImageProtocol() imageProtocol {
+	term := getenv("TERM")
+	switch {
+	case getenv("KITTY_WINDOW_ID") != "" || term == "xterm-kitty":
+		return kittyProtocol
+	case getenv("TERM_PROGRAM") == "iTerm.app" || getenv("TERM_PROGRAM") == "WezTerm":
+		return iTermProtocol
+	case strings.Contains(term, "sixel") || strings.HasPrefix(term, "foot") || strings.HasPrefix(term, "mlterm"):
+		return sixelProtocol
+	}
+	return noImageProtocol
+}
+
+// imagesMsg carries the rendered images of a response.
+type imagesMsg []string
+
+// renderImages returns the command rendering the images of a response for
+// a conversation cols wide, which takes a while for large ones.
+func renderImages(images []genai.Blob, inline bool, cols int) tea.Cmd {
+	if len(images) == 0 {
+		return nil
+	}
+	return safeCmd(func() tea.Msg {
+		rendered := make(imagesMsg, len(images))
+		for i, img := range images {
+			rendered[i] = renderImage(img, inline, cols)
+		}
+		return rendered
+	})
+}
+
+// renderImage returns the text shown in the conversation for an image:
+// the image itself if inline images are enabled and supported, otherwise
+// the path of a temp file the image was saved to.
+func renderImage(blob genai.Blob, inline bool, cols int) string {
+	if inline {
+		if seq, ok := InlineImage(blob.Data, cols); ok {
+			return seq
+		}
+	}
+
+	path, err := saveImage(blob)
+	if err != nil {
+		return fmt.Sprintf("Received an image (%s) but could not save it: %v", blob.MIMEType, err)
+	}
+	return fmt.Sprintf("Image saved to %s", path)
+}
+
+// InlineImage returns the escape sequence drawing an image in the
+// terminal, followed by newlines reserving the rows it is drawn over, or
+// false if the terminal supports no graphics protocol or the image cannot
+// be encoded. The image is drawn at most cols wide; cols <= 0 leaves the
+// width unbounded.
+func InlineImage(data []byte, cols int) (string, bool) {
+	proto := detectImageProtocol()
+	if proto == noImageProtocol {
+		return "", false
+	}
+	seq, err := encodeInlineImage(proto, data, cols)
+	if err != nil {
+		return "", false
+	}
+	return seq + strings.Repeat("\n", imageRows-1), true
+}
+
+// saveImage writes an image to a temp file and returns its path.
+func saveImage(blob genai.Blob) (string, error) {
+	ext := ".bin"
+	if exts, _ := mime.ExtensionsByType(blob.MIMEType); len(exts) > 0 {
+		ext = exts[len(exts)-1]
+	}
+	f, err := os.CreateTemp("", "gemini-image-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(blob.Data); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func encodeInlineImage(proto imageProtocol, data []byte, cols int) (string, error) {
+	switch proto {
+	case kittyProtocol:
+		return encodeKitty(data)
+	case iTermProtocol:
+		return encodeITerm(data), nil
+	case sixelProtocol:
+		return encodeSixel(data, cols)
+	default:
+		return "", fmt.Errorf("unsupported image protocol")
+	}
+}
+
+// encodeKitty encodes an image with the kitty graphics protocol, which only
+// accepts PNG, sent in base64 chunks of at most 4096 bytes.
+func encodeKitty(data []byte) (string, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	if format != "png" {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return "", fmt.Errorf("failed to encode image: %w", err)
+		}
+		data = buf.Bytes()
+	}
+
+	payload := base64.StdEncoding.EncodeToString(data)
+	var b strings.Builder
+	for first := true; len(payload) > 0; first = false {
+		chunk := payload[:min(4096, len(payload))]
+		payload = payload[len(chunk):]
+		more := 0
+		if len(payload) > 0 {
+			more = 1
+		}
+		if first {
+			fmt.Fprintf(&b, "\x1b_Gf=100,a=T,r=%d,m=%d;%s\x1b\\", imageRows, more, chunk)
+		} else {
+			fmt.Fprintf(&b, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+		}
+	}
+	return b.String(), nil
+}
+
+// encodeITerm encodes an image with the iTerm2 inline image protocol.
+func encodeITerm(data []byte) string {
+	return fmt.Sprintf("\x1b]1337;File=inline=1;size=%d;height=%d;preserveAspectRatio=1:%s\a",
+		len(data), imageRows, base64.StdEncoding.EncodeToString(data))
+}
+
+// encodeSixel scales an image to fit imageRows rows and cols columns,
+// quantizes it to a 256 color palette and encodes it as sixels, one 6-pixel
+// band at a time.
+func encodeSixel(data []byte, cols int) (string, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	src = fitImage(src, cols*sixelCellWidth, imageRows*sixelCellHeight)
+	bounds := src.Bounds()
+	img := image.NewPaletted(bounds, palette.Plan9)
+	draw.FloydSteinberg.Draw(img, bounds, src, bounds.Min)
+
+	w, h := bounds.Dx(), bounds.Dy()
+	var b strings.Builder
+	fmt.Fprintf(&b, "\x1bPq\"1;1;%d;%d", w, h)
+	for i, c := range img.Palette {
+		r, g, bl, _ := c.RGBA()
+		fmt.Fprintf(&b, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, bl*100/0xffff)
+	}
+
+	// sixels holds the sixels of each color in the band, nil for the
+	// colors the band does not use.
+	sixels := make([][]byte, len(img.Palette))
+	for y := 0; y < h; y += 6 {
+		var used []uint8
+		for dy := 0; dy < 6 && y+dy < h; dy++ {
+			for x := 0; x < w; x++ {
+				idx := img.ColorIndexAt(bounds.Min.X+x, bounds.Min.Y+y+dy)
+				if sixels[idx] == nil {
+					sixels[idx] = make([]byte, w)
+					used = append(used, idx)
+				}
+				sixels[idx][x] |= 1 << dy
+			}
+		}
+		slices.Sort(used)
+		for _, idx := range used {
+			fmt.Fprintf(&b, "#%d", idx)
+			for _, bits := range sixels[idx] {
+				b.WriteByte(63 + bits)
+			}
+			b.WriteByte('$')
+			sixels[idx] = nil
+		}
+		b.WriteByte('-')
+	}
+	b.WriteString("\x1b\\")
+	return b.String(), nil
+}
+
+// fitImage scales img down to fit within w by h pixels, keeping its aspect
+// ratio; w <= 0 leaves the width unbounded. Images that fit are returned as
+// they are.
+func fitImage(img image.Image, w, h int) image.Image {
+	bounds := img.Bounds()
+	scale := float64(h) / float64(bounds.Dy())
+	if w > 0 {
+		scale = min(scale, float64(w)/float64(bounds.Dx()))
+	}
+	if scale >= 1 {
+		return img
+	}
+	dw := max(int(float64(bounds.Dx())*scale), 1)
+	dh := max(int(float64(bounds.Dy())*scale), 1)
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			dst.Set(x, y, img.At(bounds.Min.X+x*bounds.Dx()/dw, bounds.Min.Y+y*bounds.Dy()/dh))
+		}
+	}
+	return dst
+}
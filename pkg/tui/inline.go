@@ -0,0 +1,36 @@
+package tui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// WithInline runs the UI without the alternate screen, as --inline does:
+// the conversation is printed into the terminal's scrollback as it grows,
+// where tmux copy-mode and the terminal's own search reach it, and only the
+// input and what is still changing are drawn below it.
+func (m model) WithInline() model {
+	m.inline = true
+	return m
+}
+
+// printNew prints the entries added to the conversation since the last
+// call above the UI, in inline mode, after cmd.
+func (m model) printNew(cmd tea.Cmd) (model, tea.Cmd) {
+	text := m.unprinted()
+	if text == "" {
+		return m, cmd
+	}
+	m.printed = len(m.convo.entries)
+	return m, tea.Sequence(tea.Println(text), cmd)
+}
+
+// unprinted renders the entries printNew has not printed yet.
+func (m model) unprinted() string {
+	var out []string
+	for i := m.printed; i < len(m.convo.entries); i++ {
+		out = append(out, m.convo.renderEntry(i, m.viewport.Width, m.styles))
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,58 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/i18n"
+	"github.com/google-gemini/gemini-cli-go/pkg/usage"
+)
+
+// maxInsightTools is the number of tools listed by /insights.
+const maxInsightTools = 15
+
+// insightsCommand runs /insights, summarizing the tool calls recorded in the
+// project: how often each tool ran and failed, and the patterns worth a
+// configuration change.
+func (m model) insightsCommand() model {
+	m.textarea.Reset()
+	if !m.inConversation {
+		m.inConversation = true
+	}
+	events, err := usage.Load(m.workspace.Roots[0])
+	if err != nil {
+		m.convo.add(errorEntry, fmt.Sprintf("Could not load the tool usage: %v", err))
+		return m
+	}
+	if len(events) == 0 {
+		m.convo.add(infoEntry, i18n.T("No tool usage recorded for this project yet."))
+		return m
+	}
+	s := usage.Summarize(events)
+
+	var b strings.Builder
+	b.WriteString(i18n.T("Tool usage since %s (%d calls):", s.Since.Format("2006-01-02"), s.Calls))
+	width := 0
+	for _, t := range s.Tools[:min(len(s.Tools), maxInsightTools)] {
+		width = max(width, len(t.Name))
+	}
+	for _, t := range s.Tools[:min(len(s.Tools), maxInsightTools)] {
+		fmt.Fprintf(&b, "\n  %-*s %5d %s", width, t.Name, t.Calls, i18n.T("calls"))
+		if t.Failures > 0 {
+			fmt.Fprintf(&b, ", %d %s", t.Failures, i18n.T("failed"))
+		}
+		if t.Retries > 0 {
+			fmt.Fprintf(&b, ", %d %s", t.Retries, i18n.T("retried"))
+		}
+	}
+	if len(s.Findings) == 0 {
+		b.WriteString("\n\n" + i18n.T("No recurring problems found."))
+	} else {
+		b.WriteString("\n\n" + i18n.T("Suggestions:"))
+		for _, f := range s.Findings {
+			fmt.Fprintf(&b, "\n  • %s\n    → %s", f.Problem, f.Suggestion)
+		}
+	}
+	m.convo.add(infoEntry, b.String())
+	return m
+}
@@ -0,0 +1,205 @@
+package tui
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google-gemini/gemini-cli-go/pkg/i18n"
+	"github.com/google-gemini/gemini-cli-go/pkg/mcp"
+)
+
+// mcpLogLines is the number of log lines /mcp logs shows.
+const mcpLogLines = 50
+
+// mcpCommand runs /mcp <subcommand>.
+func (m model) mcpCommand(args string) (model, tea.Cmd) {
+	m.textarea.Reset()
+	if !m.inConversation {
+		m.inConversation = true
+	}
+
+	sub, name, _ := strings.Cut(strings.TrimSpace(args), " ")
+	name = strings.TrimSpace(name)
+	switch {
+	case sub == "auth" && name == "":
+		return m.showMCPAuth(), nil
+	case sub == "auth":
+		return m.authorizeMCP(name)
+	case sub == "resources":
+		return m.showMCPResources(name), nil
+	case sub != "logs" || name == "":
+		m.convo.add(errorEntry, "Usage: /mcp logs <name> | /mcp auth [name] | /mcp resources [name]")
+		return m, nil
+	}
+
+	lines, err := mcp.Tail(name, mcpLogLines)
+	if err != nil {
+		m.convo.add(errorEntry, err.Error())
+		return m, nil
+	}
+	path, _ := mcp.LogPath(name)
+	if len(lines) == 0 {
+		m.convo.add(infoEntry, fmt.Sprintf("%s is empty.", path))
+		return m, nil
+	}
+	m.convo.add(infoEntry, fmt.Sprintf("Last %d lines of %s:\n%s", len(lines), path, strings.Join(lines, "\n")))
+	return m, nil
+}
+
+// showMCPResources lists the resources of the MCP servers, or of the one
+// called name, as the @server:uri references that add them to a prompt.
+func (m model) showMCPResources(name string) model {
+	var resources []mcp.RegisteredResource
+	if m.workspace.MCP != nil {
+		resources = m.workspace.MCP.Resources()
+	}
+	var b strings.Builder
+	for _, r := range resources {
+		if name != "" && r.Server != name {
+			continue
+		}
+		b.WriteString("\n  @" + resourceRef(r))
+		if desc := cmp.Or(r.Resource.Description, r.Resource.Name); desc != "" {
+			b.WriteString(" - " + desc)
+		}
+	}
+	switch {
+	case b.Len() > 0:
+		m.convo.add(infoEntry, i18n.T("MCP resources (add one to a prompt with @server:uri):")+b.String())
+	case name != "":
+		m.convo.add(infoEntry, i18n.T("MCP server %s offers no resources.", name))
+	default:
+		m.convo.add(infoEntry, i18n.T("No MCP server offers resources."))
+	}
+	return m
+}
+
+// remoteMCPServers returns the names of the configured servers reached over
+// HTTP or SSE, which may require authorization, sorted.
+func (m model) remoteMCPServers() []string {
+	var names []string
+	for name, server := range m.settings.MCPServers {
+		if server.Command == "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// showMCPAuth lists the remote servers and whether a token is stored for
+// them.
+func (m model) showMCPAuth() model {
+	names := m.remoteMCPServers()
+	if len(names) == 0 {
+		m.convo.add(infoEntry, i18n.T("No remote MCP servers are configured."))
+		return m
+	}
+	var b strings.Builder
+	b.WriteString(i18n.T("Remote MCP servers (authorize with /mcp auth <name>):"))
+	for _, name := range names {
+		status := i18n.T("not authorized")
+		if mcp.Authorized(name, m.settings.MCPServers[name]) {
+			status = i18n.T("authorized")
+		}
+		fmt.Fprintf(&b, "\n  %s: %s", name, status)
+	}
+	m.convo.add(infoEntry, b.String())
+	return m
+}
+
+// mcpAuthURLMsg carries the page the user authorizes the CLI on with an MCP
+// server, shown in case the browser does not open.
+type mcpAuthURLMsg struct{ server, url string }
+
+// mcpAuthorizedMsg reports the outcome of /mcp auth.
+type mcpAuthorizedMsg struct {
+	server string
+	err    error
+}
+
+// showMCPAuthURL passes the authorization page of a server to the program,
+// for the conversation to show it.
+func showMCPAuthURL(server, authURL string) {
+	term.send(mcpAuthURLMsg{server: server, url: authURL})
+}
+
+// authorizeMCP authorizes with the named server in the browser, and
+// connects to it again with the new token.
+func (m model) authorizeMCP(name string) (model, tea.Cmd) {
+	server, ok := m.settings.MCPServers[name]
+	if !ok {
+		m.convo.add(errorEntry, i18n.T("No MCP server named %q is configured.", name))
+		return m, nil
+	}
+	ws := m.workspace
+	return m, func() tea.Msg {
+		ctx := context.Background()
+		err := mcp.Authorize(ctx, name, server, func(authURL string) { showMCPAuthURL(name, authURL) })
+		if err == nil {
+			err = ws.ConnectMCPServer(ctx, name)
+		}
+		return mcpAuthorizedMsg{server: name, err: err}
+	}
+}
+
+// showMCPAuthorized reports the outcome of /mcp auth.
+func (m model) showMCPAuthorized(msg mcpAuthorizedMsg) model {
+	if msg.err != nil {
+		m.convo.add(errorEntry, i18n.T("Could not authorize with MCP server %s: %v", msg.server, msg.err))
+		return m
+	}
+	m.convo.add(infoEntry, i18n.T("Authorized with MCP server %s.", msg.server))
+	return m
+}
+
+// mcpConnectedMsg reports that the configured MCP servers were connected
+// to, and err the ones that could not be.
+type mcpConnectedMsg struct{ err error }
+
+// connectMCP connects to the configured MCP servers, whose tools the model
+// is offered from the next request on.
+func (m model) connectMCP() tea.Msg {
+	// Servers requiring authorization ask the user to authorize in the
+	// browser.
+	ctx := mcp.WithAuthorization(context.Background(), showMCPAuthURL)
+	return mcpConnectedMsg{err: m.workspace.ConnectMCP(ctx)}
+}
+
+// showMCPConnected reports the MCP servers that could not be connected to.
+func (m model) showMCPConnected(msg mcpConnectedMsg) model {
+	if msg.err != nil {
+		m.convo.add(errorEntry, i18n.T("Could not connect to some MCP servers: %v", msg.err))
+	}
+	return m
+}
+
+// toolLabel names the tool name for /tools, with the MCP server it comes
+// from if it is the tool of one.
+func (m model) toolLabel(name string) string {
+	if m.workspace.MCP == nil {
+		return name
+	}
+	if t, ok := m.workspace.MCP.Lookup(name); ok {
+		return fmt.Sprintf("%s (MCP server %s)", name, t.Server)
+	}
+	return name
+}
+
+// namesTool reports whether name, as given to /tools desc, names the tool
+// declared as declared: by that name or, for the tool of an MCP server, as
+// server__tool.
+func (m model) namesTool(name, declared string) bool {
+	if name == declared {
+		return true
+	}
+	if m.workspace.MCP == nil {
+		return false
+	}
+	t, ok := m.workspace.MCP.Lookup(declared)
+	return ok && name == t.Server+"__"+t.Tool.Name
+}
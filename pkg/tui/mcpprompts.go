@@ -0,0 +1,299 @@
+package tui
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google-gemini/gemini-cli-go/pkg/i18n"
+	"github.com/google-gemini/gemini-cli-go/pkg/mcp"
+)
+
+// promptArguments is an MCP prompt waiting for the user to type the
+// required arguments its command left out, one at a time.
+type promptArguments struct {
+	prompt  mcp.RegisteredPrompt
+	display string
+	args    map[string]string
+	// missing are the arguments still to be typed, the first one next.
+	missing []mcp.PromptArgument
+}
+
+// promptCommand is the slash command of an MCP prompt, e.g.
+// "/github:review".
+func promptCommand(p mcp.RegisteredPrompt) string {
+	return "/" + p.Server + ":" + p.Prompt.Name
+}
+
+// mcpPrompts returns the prompts of the connected MCP servers.
+func (m model) mcpPrompts() []mcp.RegisteredPrompt {
+	if m.workspace.MCP == nil {
+		return nil
+	}
+	return m.workspace.MCP.Prompts()
+}
+
+// mcpPrompt returns the MCP prompt whose command is name.
+func (m model) mcpPrompt(name string) (mcp.RegisteredPrompt, bool) {
+	for _, p := range m.mcpPrompts() {
+		if promptCommand(p) == name {
+			return p, true
+		}
+	}
+	return mcp.RegisteredPrompt{}, false
+}
+
+// runMCPPrompt fills in p with args and sends it. Required arguments args
+// leaves out are asked for first.
+func (m model) runMCPPrompt(p mcp.RegisteredPrompt, args string) (model, tea.Cmd) {
+	m.textarea.Reset()
+	if !m.inConversation {
+		m.inConversation = true
+	}
+	values, err := parsePromptArgs(p.Prompt, args)
+	if err != nil {
+		m.convo.add(errorEntry, fmt.Sprintf("%s: %v\n%s", promptCommand(p), err, promptUsage(p)))
+		return m, nil
+	}
+	var missing []mcp.PromptArgument
+	for _, a := range p.Prompt.Arguments {
+		if _, ok := values[a.Name]; !ok && a.Required {
+			missing = append(missing, a)
+		}
+	}
+	display := strings.TrimSpace(promptCommand(p) + " " + args)
+	if len(missing) == 0 {
+		return m.getMCPPrompt(p, display, values)
+	}
+	m.promptArgs = &promptArguments{prompt: p, display: display, args: values, missing: missing}
+	return m, nil
+}
+
+// handlePromptArgumentKey takes the input as the argument being asked for
+// on Enter and cancels the prompt on Esc. Other keys are typed as usual.
+func (m model) handlePromptArgumentKey(msg tea.KeyMsg) (model, tea.Cmd, bool) {
+	if m.promptArgs == nil {
+		return m, nil, false
+	}
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.promptArgs = nil
+		m.textarea.Reset()
+		m.convo.add(infoEntry, i18n.T("Cancelled."))
+		return m, nil, true
+	case tea.KeyEnter:
+		value := strings.TrimSpace(m.expandPastes(m.textarea.Value()))
+		if value == "" {
+			return m, nil, true
+		}
+		m.textarea.Reset()
+		m.pastes = nil
+		m.completion = nil
+
+		// The pending prompt is shared with earlier copies of the model.
+		p := *m.promptArgs
+		p.args = maps.Clone(p.args)
+		p.args[p.missing[0].Name] = value
+		p.display += " --" + p.missing[0].Name + "=" + quoteArg(value)
+		p.missing = p.missing[1:]
+		if len(p.missing) > 0 {
+			m.promptArgs = &p
+			return m, nil, true
+		}
+		m.promptArgs = nil
+		m, cmd := m.getMCPPrompt(p.prompt, p.display, p.args)
+		return m, cmd, true
+	}
+	return m, nil, false
+}
+
+// promptArgumentStatus is shown in the footer while an argument is asked
+// for.
+func (m model) promptArgumentStatus() string {
+	a := m.promptArgs.missing[0]
+	status := i18n.T("Enter %s for %s", a.Name, promptCommand(m.promptArgs.prompt))
+	if a.Description != "" {
+		status += " (" + a.Description + ")"
+	}
+	return status + i18n.T("; Esc to cancel")
+}
+
+// getMCPPrompt returns the command that has the server fill in p with args
+// and sends the result, shown as display.
+func (m model) getMCPPrompt(p mcp.RegisteredPrompt, display string, args map[string]string) (model, tea.Cmd) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelRequest = cancel
+	registry := m.workspace.MCP
+	return m, safeCmd(func() tea.Msg {
+		result, err := registry.GetPrompt(ctx, p.Server, p.Prompt.Name, args)
+		if err != nil {
+			return errMsg(err)
+		}
+		prompt, err := promptText(result)
+		if err != nil {
+			return errMsg(fmt.Errorf("%s: %w", promptCommand(p), err))
+		}
+		return customPromptMsg{display: display, prompt: prompt}
+	})
+}
+
+// promptText joins the text of the messages of a filled in prompt,
+// including that of embedded text resources. Images and audio are left out.
+func promptText(result *mcp.GetPromptResult) (string, error) {
+	var texts []string
+	for _, msg := range result.Messages {
+		switch c := msg.Content; c.Type {
+		case "text":
+			texts = append(texts, c.Text)
+		case "resource":
+			var resource struct {
+				Text string `json:"text"`
+			}
+			if json.Unmarshal(c.Resource, &resource) == nil && resource.Text != "" {
+				texts = append(texts, resource.Text)
+			}
+		}
+	}
+	if len(texts) == 0 {
+		return "", errors.New("the server returned no text")
+	}
+	return strings.Join(texts, "\n\n"), nil
+}
+
+// parsePromptArgs parses the arguments of the command of prompt p. Named
+// arguments are given as --name=value; the other words fill the arguments
+// not named in order, the last one taking the rest of them. Values may be
+// quoted.
+func parsePromptArgs(p mcp.Prompt, args string) (map[string]string, error) {
+	words, err := splitArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	var positional []string
+	for _, w := range words {
+		name, value, named := strings.Cut(strings.TrimPrefix(w.text, "--"), "=")
+		if !named || w.quoted || !strings.HasPrefix(w.text, "--") {
+			positional = append(positional, w.text)
+			continue
+		}
+		if !slices.ContainsFunc(p.Arguments, func(a mcp.PromptArgument) bool { return a.Name == name }) {
+			return nil, fmt.Errorf("unknown argument %q", name)
+		}
+		values[name] = value
+	}
+
+	var unnamed []string
+	for _, a := range p.Arguments {
+		if _, ok := values[a.Name]; !ok {
+			unnamed = append(unnamed, a.Name)
+		}
+	}
+	if len(positional) > 0 && len(unnamed) == 0 {
+		return nil, errors.New("too many arguments")
+	}
+	for i, name := range unnamed {
+		if i >= len(positional) {
+			break
+		}
+		if i == len(unnamed)-1 {
+			values[name] = strings.Join(positional[i:], " ")
+		} else {
+			values[name] = positional[i]
+		}
+	}
+	return values, nil
+}
+
+// word is a word of a command's arguments; quoted is set if it started
+// with a quote.
+type word struct {
+	text   string
+	quoted bool
+}
+
+// splitArgs splits args into words at spaces, keeping spaces within single
+// or double quotes.
+func splitArgs(args string) ([]word, error) {
+	var (
+		words   []word
+		current strings.Builder
+		inWord  bool
+		quoted  bool
+		quote   rune
+	)
+	for _, r := range args {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			// A quoted word is never a --name=value argument, while
+			// --name="a value" is.
+			if !inWord {
+				quoted = true
+			}
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word{current.String(), quoted})
+				current.Reset()
+				inWord, quoted = false, false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inWord {
+		words = append(words, word{current.String(), quoted})
+	}
+	return words, nil
+}
+
+// quoteArg quotes value if it would not be read back as one word.
+func quoteArg(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n\"'") {
+		return value
+	}
+	return strconv.Quote(value)
+}
+
+// promptUsage shows how to give the arguments of p.
+func promptUsage(p mcp.RegisteredPrompt) string {
+	usage := i18n.T("Usage:") + " " + promptCommand(p)
+	for _, a := range p.Prompt.Arguments {
+		if a.Required {
+			usage += " --" + a.Name + "=<value>"
+		} else {
+			usage += " [--" + a.Name + "=<value>]"
+		}
+	}
+	return usage
+}
+
+// mcpPromptsHelp lists the prompts of the MCP servers for /help.
+func (m model) mcpPromptsHelp() string {
+	prompts := m.mcpPrompts()
+	if len(prompts) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\n" + i18n.T("MCP Prompts:"))
+	for _, p := range prompts {
+		fmt.Fprintf(&b, "\n  %-10s %s", promptCommand(p), p.Prompt.Description)
+	}
+	return b.String()
+}
@@ -0,0 +1,122 @@
+package tui
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/mcp"
+	"github.com/google-gemini/gemini-cli-go/pkg/uploads"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// mentions returns the files prompt names with @path, once each. Words
+// after "@" that name no file, such as "@someone", are left alone.
+func (m model) mentions(prompt string) ([]string, error) {
+	var paths []string
+	for _, word := range strings.Fields(prompt) {
+		path, ok := strings.CutPrefix(word, "@")
+		path = strings.TrimRight(path, ",.;:!?)")
+		if !ok || path == "" {
+			continue
+		}
+		resolved, err := m.workspace.Resolve(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if info, err := os.Stat(resolved); err != nil || info.IsDir() {
+			continue
+		}
+		if !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// attachFiles returns the files at paths as parts of a prompt. Files too
+// large to send inline go through the Files API, reusing earlier uploads.
+func (m model) attachFiles(ctx context.Context, paths []string) ([]genai.Part, error) {
+	var uploader *uploads.Uploader
+	if m.api != nil {
+		var err error
+		if uploader, err = uploads.New(m.api); err != nil {
+			return nil, err
+		}
+	}
+	var parts []genai.Part
+	for _, path := range paths {
+		resolved, err := m.workspace.Resolve(path)
+		if err != nil {
+			return nil, err
+		}
+		attached, _, err := uploader.Attach(ctx, resolved)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, attached...)
+	}
+	return parts, nil
+}
+
+// resourceRef is how prompts name an MCP resource after "@": server:uri.
+func resourceRef(r mcp.RegisteredResource) string {
+	return r.Server + ":" + r.Resource.URI
+}
+
+// resourceMentions returns the MCP resources prompt names with
+// @server:uri, once each.
+func (m model) resourceMentions(prompt string) []mcp.RegisteredResource {
+	if m.workspace.MCP == nil {
+		return nil
+	}
+	resources := m.workspace.MCP.Resources()
+	var found []mcp.RegisteredResource
+	for _, word := range strings.Fields(prompt) {
+		ref, ok := strings.CutPrefix(word, "@")
+		if !ok {
+			continue
+		}
+		// Punctuation after the reference may also end a URI.
+		trimmed := strings.TrimRight(ref, ",.;:!?)")
+		for _, r := range resources {
+			if (ref == resourceRef(r) || trimmed == resourceRef(r)) && !slices.Contains(found, r) {
+				found = append(found, r)
+			}
+		}
+	}
+	return found
+}
+
+// readResources returns the contents of resources as parts of a prompt.
+func readResources(ctx context.Context, registry *mcp.Registry, resources []mcp.RegisteredResource) ([]genai.Part, error) {
+	var parts []genai.Part
+	for _, r := range resources {
+		contents, err := registry.ReadResource(ctx, r.Server, r.Resource.URI)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range contents {
+			if c.Blob == "" {
+				parts = append(parts, genai.Text(fmt.Sprintf("Content of @%s:\n%s", resourceRef(r), c.Text)))
+				continue
+			}
+			data, err := base64.StdEncoding.DecodeString(c.Blob)
+			if err != nil {
+				return nil, fmt.Errorf("@%s: invalid blob: %w", resourceRef(r), err)
+			}
+			mimeType := cmp.Or(c.MimeType, r.Resource.MimeType, "application/octet-stream")
+			parts = append(parts, genai.Text(fmt.Sprintf("Content of @%s:", resourceRef(r))), genai.Blob{MIMEType: mimeType, Data: data})
+		}
+	}
+	return parts, nil
+}
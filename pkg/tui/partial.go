@@ -0,0 +1,91 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google-gemini/gemini-cli-go/pkg/finish"
+	"github.com/google-gemini/gemini-cli-go/pkg/i18n"
+	"github.com/google-gemini/gemini-cli-go/pkg/session"
+	"github.com/google-gemini/gemini-cli-go/pkg/startup"
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
+)
+
+// interruptedMsg carries a response whose stream failed part way, with the
+// text that came in before it did.
+type interruptedMsg struct {
+	responseMsg
+	err error
+}
+
+// sendStreaming is cs.SendMessage, but streams the response so that the
+// text that came in before an error is returned along with it.
+func sendStreaming(ctx context.Context, cs *genai.ChatSession, parts ...genai.Part) (*genai.GenerateContentResponse, string, error) {
+	iter := cs.SendMessageStream(ctx, parts...)
+	var (
+		partial strings.Builder
+		usage   *genai.UsageMetadata
+	)
+	for {
+		resp, err := iter.Next()
+		if err == iterator.Done {
+			merged := iter.MergedResponse()
+			// The merged response keeps the usage of the first chunk, while
+			// that of the last one counts the whole response.
+			if merged != nil && usage != nil {
+				merged.UsageMetadata = usage
+			}
+			return merged, "", nil
+		}
+		if err != nil {
+			return nil, partial.String(), err
+		}
+		if resp.UsageMetadata != nil {
+			usage = resp.UsageMetadata
+		}
+		if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+			continue
+		}
+		startup.Mark("first token")
+		for _, p := range resp.Candidates[0].Content.Parts {
+			if text, ok := p.(genai.Text); ok {
+				partial.WriteString(string(text))
+			}
+		}
+	}
+}
+
+// keepPartial records the partial text of an interrupted response as the
+// model's answer in the chat history, which only gets complete responses,
+// so that the model can be asked to continue it.
+func keepPartial(cs *genai.ChatSession, partial string) {
+	cs.History = append(cs.History, &genai.Content{Role: "model", Parts: []genai.Part{genai.Text(partial)}})
+}
+
+// showInterrupted shows the partial response of msg marked as incomplete,
+// and offers to continue it unless the user cancelled it.
+func (m model) showInterrupted(msg interruptedMsg) (model, tea.Cmd) {
+	m.cancelRequest = nil
+	m.stats.tokens += msg.tokens
+	m.applyChat(msg.history, msg.tools)
+	for _, a := range msg.activity {
+		m.convo.addActivity(a)
+	}
+	m.convo.add(geminiEntry, msg.text)
+	m.convo.add(errorEntry, i18n.T("The response is incomplete: %v", msg.err))
+	m.scrollOffset = 0
+	m.session.Record(session.GeminiMessage, msg.text)
+	if !errors.Is(msg.err, context.Canceled) {
+		m.confirm = &confirmation{
+			prompt: i18n.T("Continue the incomplete response? (y/n)"),
+			onYes: func(m model) (model, tea.Cmd) {
+				return m.submit(i18n.T("Continue"), finish.ContinuePrompt)
+			},
+			onNo: func(m model) (model, tea.Cmd) { return m, nil },
+		}
+	}
+	return m, safeCmd(m.countTokens())
+}
@@ -0,0 +1,63 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pastedBlock is multi-line text pasted into the input. The textarea only
+// shows a placeholder for it; the content is substituted back on submit.
+type pastedBlock struct {
+	placeholder string
+	content     string
+}
+
+// isMultiLinePaste reports whether msg is a bracketed paste spanning several
+// lines, which would otherwise be submitted at its first newline.
+func isMultiLinePaste(msg tea.KeyMsg) bool {
+	return msg.Paste && strings.ContainsAny(string(msg.Runes), "\r\n")
+}
+
+// handlePaste stores pasted text and inserts a placeholder for it at the
+// cursor.
+func (m model) handlePaste(msg tea.KeyMsg) model {
+	content := strings.ReplaceAll(string(msg.Runes), "\r\n", "\n")
+	content = strings.ReplaceAll(content, "\r", "\n")
+	return m.insertBlock(strings.TrimSuffix(content, "\n"))
+}
+
+// insertBlock stores multi-line content and inserts a placeholder for it at
+// the cursor.
+func (m model) insertBlock(content string) model {
+	lines := strings.Count(content, "\n") + 1
+	placeholder := fmt.Sprintf("[Pasted text #%d: %d lines]", len(m.pastes)+1, lines)
+	m.pastes = append(m.pastes, pastedBlock{placeholder: placeholder, content: content})
+	m.textarea.InsertString(placeholder)
+	return m
+}
+
+// expandPastes replaces paste placeholders in input with their content.
+// Placeholders the user deleted are simply dropped.
+func (m model) expandPastes(input string) string {
+	for _, p := range m.pastes {
+		input = strings.Replace(input, p.placeholder, p.content, 1)
+	}
+	return input
+}
+
+// renderPastes renders the pasted blocks still referenced by the input, shown
+// when the user expands them with Ctrl+O.
+func (m model) renderPastes() string {
+	var b strings.Builder
+	value := m.textarea.Value()
+	for _, p := range m.pastes {
+		if !strings.Contains(value, p.placeholder) {
+			continue
+		}
+		b.WriteString("\n" + m.styles.sender.Render(p.placeholder) + "\n")
+		b.WriteString(p.content + "\n")
+	}
+	return b.String()
+}
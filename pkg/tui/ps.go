@@ -0,0 +1,100 @@
+package tui
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/i18n"
+	"github.com/google-gemini/gemini-cli-go/pkg/tools"
+)
+
+// psOutputLines is how many of the last lines of output /ps output shows.
+const psOutputLines = 30
+
+// psCommand runs /ps [output|kill <id>]: it lists the commands the shell
+// tool started in the background, shows the end of the output of one, or
+// stops one.
+func (m model) psCommand(args string) model {
+	m.textarea.Reset()
+	if !m.inConversation {
+		m.inConversation = true
+	}
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		procs := m.workspace.Processes.List()
+		if len(procs) == 0 {
+			m.convo.add(infoEntry, i18n.T("No commands run in the background."))
+			return m
+		}
+		lines := []string{i18n.T("Background commands:")}
+		for _, p := range procs {
+			lines = append(lines, "  "+describeProcess(p))
+		}
+		m.convo.add(infoEntry, strings.Join(lines, "\n"))
+		return m
+	}
+
+	var id int
+	if len(fields) == 2 {
+		id, _ = strconv.Atoi(fields[1])
+	}
+	if len(fields) != 2 || id < 1 || (fields[0] != "output" && fields[0] != "kill") {
+		m.convo.add(errorEntry, i18n.T("Usage: /ps [output|kill <id>]"))
+		return m
+	}
+	p, err := m.workspace.Processes.Get(id)
+	if err != nil {
+		m.convo.add(errorEntry, i18n.T("There is no background command %d.", id))
+		return m
+	}
+	switch fields[0] {
+	case "output":
+		output := strings.TrimRight(m.workspace.ProcessOutput(p, psOutputLines), "\n")
+		if output == "" {
+			output = i18n.T("(no output)")
+		}
+		m.convo.add(infoEntry, describeProcess(p)+"\n"+output)
+	case "kill":
+		if !p.Running() {
+			m.convo.add(infoEntry, i18n.T("Background command %d already exited with code %d.", p.ID, p.ExitCode()))
+		} else if err := p.Stop(); err != nil {
+			m.convo.add(errorEntry, i18n.T("Could not stop background command %d: %v", p.ID, err))
+		} else {
+			m.convo.add(infoEntry, i18n.T("Stopped background command %d.", p.ID))
+		}
+	}
+	return m
+}
+
+// describeProcess summarizes p on one line: its ID, PID, status and
+// command.
+func describeProcess(p *tools.Process) string {
+	status := i18n.T("running for %s", time.Since(p.Started).Round(time.Second))
+	if !p.Running() {
+		status = i18n.T("exited with code %d", p.ExitCode())
+	}
+	return i18n.T("%d: PID %d, %s: %s", p.ID, p.PID, status, strings.ReplaceAll(p.Command, "\n", " "))
+}
+
+// completePS offers the /ps arguments, and the IDs of the background
+// commands.
+func completePS(m model, words []string) []suggestion {
+	switch len(words) {
+	case 0:
+		return []suggestion{
+			{value: "output", description: "Show the end of a command's output"},
+			{value: "kill", description: "Stop a command"},
+		}
+	case 1:
+		var items []suggestion
+		for _, p := range m.workspace.Processes.List() {
+			if words[0] == "kill" && !p.Running() {
+				continue
+			}
+			items = append(items, suggestion{value: strconv.Itoa(p.ID), description: p.Command})
+		}
+		return items
+	}
+	return nil
+}
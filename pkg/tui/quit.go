@@ -0,0 +1,173 @@
+package tui
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/i18n"
+)
+
+// summaryLogName is the file under ~/.gemini/logs the summaries of the
+// sessions /quit ended are appended to.
+const summaryLogName = "sessions.log"
+
+// sessionStats count what the session did, for the summary /quit prints.
+type sessionStats struct {
+	// turns are the prompts sent to the model.
+	turns int
+	// tokens are those the responses reported using.
+	tokens int32
+	// files are the files the tools changed, in the order they were first
+	// changed.
+	files []string
+}
+
+// filesChangedMsg carries the files a change of the tools wrote.
+type filesChangedMsg []string
+
+// filesChanged is the tools.Workspace.FilesChanged of the TUI. It runs in
+// the goroutine of the request and passes the files to the program.
+func filesChanged(paths []string) {
+	term.send(filesChangedMsg(paths))
+}
+
+// addChangedFiles counts the files of msg as changed by the session.
+func (m model) addChangedFiles(msg filesChangedMsg) model {
+	for _, path := range msg {
+		if !slices.Contains(m.stats.files, path) {
+			m.stats.files = append(m.stats.files, path)
+		}
+	}
+	return m
+}
+
+// quitCommand runs /quit. If the session has messages that were not
+// saved yet, it asks whether to save them first.
+func (m model) quitCommand() (model, tea.Cmd) {
+	m.textarea.Reset()
+	if !m.session.Unsaved() {
+		return m.quit(false)
+	}
+	if !m.inConversation {
+		m.inConversation = true
+	}
+	m.confirm = &confirmation{
+		prompt: i18n.T("Save this session before quitting? (y/n)"),
+		onYes:  func(m model) (model, tea.Cmd) { return m.quit(true) },
+		onNo:   func(m model) (model, tea.Cmd) { return m.quit(false) },
+	}
+	return m, nil
+}
+
+// quit ends the program, saving the session's unsaved messages if save is
+// set and discarding them otherwise. The summary of the session is logged
+// and kept for Start to print once the screen is restored.
+func (m model) quit(save bool) (model, tea.Cmd) {
+	var saved string
+	switch {
+	case !m.session.Unsaved():
+	case !save:
+		m.session.Discard()
+		saved = i18n.T("The session was not saved.")
+	default:
+		path, err := m.session.Path()
+		if err == nil {
+			err = m.session.Save(context.Background())
+		}
+		if err != nil {
+			saved = i18n.T("Could not save the session: %v", err)
+		} else {
+			saved = i18n.T("The session was saved to %s.", path)
+		}
+	}
+
+	m.summary = m.sessionSummary(time.Now())
+	if saved != "" {
+		m.summary += "\n" + saved
+	}
+	if err := logSummary(m.session.ID, m.summary); err != nil {
+		m.summary += "\n" + i18n.T("Could not log the summary: %v", err)
+	}
+	return m, tea.Quit
+}
+
+// sessionSummary describes the session up to now: how long it took, the
+// prompts and tokens it used, and the tools and files it touched.
+func (m model) sessionSummary(now time.Time) string {
+	none := i18n.T("none")
+	files := cmp.Or(strings.Join(m.stats.files, ", "), none)
+	rows := [][2]string{
+		{i18n.T("Duration:"), now.Sub(m.session.StartTime).Round(time.Second).String()},
+		{i18n.T("Turns:"), fmt.Sprint(m.stats.turns)},
+		{i18n.T("Tokens:"), formatTokens(m.stats.tokens)},
+		{i18n.T("Tools used:"), cmp.Or(m.toolsUsed(), none)},
+		{i18n.T("Files changed:"), files},
+	}
+	width := 0
+	for _, r := range rows {
+		width = max(width, len([]rune(r[0])))
+	}
+	var b strings.Builder
+	b.WriteString(i18n.T("Session summary:"))
+	for _, r := range rows {
+		fmt.Fprintf(&b, "\n  %-*s %s", width, r[0], r[1])
+	}
+	return b.String()
+}
+
+// toolsUsed lists the tools the model called in the conversation, with how
+// often each was and how many of those calls failed, most called first.
+func (m model) toolsUsed() string {
+	calls, failed := map[string]int{}, map[string]int{}
+	for _, e := range m.convo.entries {
+		if e.call == nil {
+			continue
+		}
+		calls[e.call.name]++
+		if e.call.failed {
+			failed[e.call.name]++
+		}
+	}
+	names := slices.SortedFunc(maps.Keys(calls), func(a, b string) int {
+		return cmp.Or(calls[b]-calls[a], strings.Compare(a, b))
+	})
+	used := make([]string, len(names))
+	for i, name := range names {
+		if failed[name] > 0 {
+			used[i] = i18n.T("%s (%d, %d failed)", name, calls[name], failed[name])
+		} else {
+			used[i] = fmt.Sprintf("%s (%d)", name, calls[name])
+		}
+	}
+	return strings.Join(used, ", ")
+}
+
+// logSummary appends the summary of the session id to
+// ~/.gemini/logs/sessions.log.
+func logSummary(id, summary string) error {
+	dir, err := config.LogDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, summaryLogName), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "%s session %s\n%s\n\n", time.Now().Format(time.RFC3339), id, summary)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
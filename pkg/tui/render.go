@@ -0,0 +1,291 @@
+package tui
+
+import (
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/wordwrap"
+	"github.com/muesli/reflow/wrap"
+)
+
+// entryKind identifies how a conversation entry is rendered.
+type entryKind int
+
+const (
+	userEntry entryKind = iota
+	geminiEntry
+	errorEntry
+	infoEntry
+	// imageEntry holds terminal graphics escapes, which must not be wrapped.
+	imageEntry
+	// diffEntry holds unified diff hunks.
+	diffEntry
+)
+
+// entry is a single conversation message together with its rendering for
+// the last width it was drawn at. Entries are rendered lazily, when they
+// first scroll into view.
+type entry struct {
+	kind entryKind
+	text string
+	// time is when the entry was added.
+	time time.Time
+	// call is the tool call an info entry reports, if any.
+	call *toolCall
+
+	// blocks are the code blocks in a model response.
+	blocks []*codeBlock
+
+	// tokens is the size of a message, an estimate until counted is set.
+	tokens  int32
+	counted bool
+
+	rendered        string
+	cachedWidth     int
+	cachedHighlight string
+	cachedLabel     string
+
+	// lastDrawn is the frame the entry was last rendered in.
+	lastDrawn int
+	// spilled is set once text was written to spool at offset, after
+	// which text is empty unless the entry is loaded again.
+	spilled bool
+	spool   *spool
+	offset  int64
+	length  int
+}
+
+// toolCall records a call the model made to a tool.
+type toolCall struct {
+	name string
+	// failed is set if the tool responded with an error.
+	failed bool
+}
+
+// activityLine is a line reporting what happened while the model answered,
+// with the tool call it reports, if any.
+type activityLine struct {
+	text string
+	call *toolCall
+}
+
+// conversation holds the conversation entries. It is shared by pointer so
+// that render caches survive bubbletea's model copies.
+type conversation struct {
+	entries []*entry
+	// highlight is the /find query highlighted in rendered entries.
+	highlight string
+	// blocks numbers the code blocks of all responses; blockEntry holds the
+	// index of the entry each block belongs to.
+	blocks     []*codeBlock
+	blockEntry []int
+	// showTokens labels messages with their token counts.
+	showTokens bool
+
+	// spool holds the entries that spilled to disk to keep the resident
+	// ones within maxResident bytes.
+	spool       spool
+	maxResident int
+	// frame counts the renderings of the conversation.
+	frame int
+}
+
+func newConversation() *conversation {
+	return &conversation{maxResident: maxResidentBytes}
+}
+
+// add appends an entry to the conversation.
+func (c *conversation) add(kind entryKind, text string) {
+	e := &entry{kind: kind, text: text, time: time.Now()}
+	if e.isMessage() {
+		e.tokens = estimateTokens(text)
+	}
+	if kind == geminiEntry {
+		e.blocks = parseCodeBlocks(text)
+		for _, b := range e.blocks {
+			c.blocks = append(c.blocks, b)
+			c.blockEntry = append(c.blockEntry, len(c.entries))
+			b.index = len(c.blocks)
+		}
+	}
+	e.lastDrawn = c.frame
+	c.entries = append(c.entries, e)
+	c.trim()
+}
+
+// addActivity appends an info entry reporting a.
+func (c *conversation) addActivity(a activityLine) {
+	c.add(infoEntry, a.text)
+	c.entries[len(c.entries)-1].call = a.call
+}
+
+// setHighlight sets the text highlighted in rendered entries.
+func (c *conversation) setHighlight(query string) {
+	c.highlight = query
+}
+
+// renderEntry renders entry i with the conversation's highlight and token
+// labels.
+func (c *conversation) renderEntry(i, width int, s styles) string {
+	e := c.entries[i]
+	e.load()
+	e.lastDrawn = c.frame
+	var label string
+	if c.showTokens {
+		label = e.tokenLabel()
+	}
+	return e.render(width, s, c.highlight, label)
+}
+
+// lineCount returns the number of rendered lines of entry i.
+func (c *conversation) lineCount(i, width int, s styles) int {
+	return strings.Count(c.renderEntry(i, width, s), "\n") + 1
+}
+
+// offsetOf returns the scroll offset that places the first line of entry i at
+// the top of a viewport of the given height.
+func (c *conversation) offsetOf(i, width, height int, s styles) int {
+	lines := 0
+	for j := i; j < len(c.entries); j++ {
+		lines += c.lineCount(j, width, s)
+	}
+	return max(lines-height, 0)
+}
+
+// visible renders the window of height lines that ends offset lines above
+// the bottom of the conversation. Only the entries intersecting the window
+// are rendered, so the cost does not grow with the length of the session.
+// The offset is clamped to the top of the conversation and returned.
+// Entries that spilled to disk are read back as they scroll into view.
+func (c *conversation) visible(width, height, offset int, s styles) (string, int) {
+	if offset < 0 {
+		offset = 0
+	}
+	c.frame++
+	defer c.trim()
+
+	var lines []string
+	for i := len(c.entries) - 1; i >= 0 && len(lines) < height+offset; i-- {
+		lines = append(strings.Split(c.renderEntry(i, width, s), "\n"), lines...)
+	}
+
+	if maxOffset := len(lines) - height; offset > maxOffset {
+		offset = max(maxOffset, 0)
+	}
+	end := len(lines) - offset
+	start := max(end-height, 0)
+	return strings.Join(lines[start:end], "\n"), offset
+}
+
+// styles are the lipgloss styles used to render conversation entries.
+type styles struct {
+	sender     lipgloss.Style
+	response   lipgloss.Style
+	err        lipgloss.Style
+	code       lipgloss.Style
+	codeHeader lipgloss.Style
+	lineNumber lipgloss.Style
+	highlight  lipgloss.Style
+
+	diffInsert     lipgloss.Style
+	diffDelete     lipgloss.Style
+	diffInsertWord lipgloss.Style
+	diffDeleteWord lipgloss.Style
+	diffDim        lipgloss.Style
+}
+
+// render renders e at width, highlighting highlight and prefixing the
+// sender with label if it is set.
+func (e *entry) render(width int, s styles, highlight, label string) string {
+	if e.rendered != "" && e.cachedWidth == width && e.cachedHighlight == highlight && e.cachedLabel == label {
+		return e.rendered
+	}
+	e.cachedHighlight = highlight
+	e.cachedLabel = label
+	if label != "" {
+		label = s.lineNumber.Render("["+label+"]") + " "
+	}
+
+	text := highlightMatches(e.text, highlight, s)
+
+	var out string
+	switch e.kind {
+	case imageEntry:
+		e.rendered = e.text
+		e.cachedWidth = width
+		return e.rendered
+	case diffEntry:
+		e.rendered = renderDiff(e.text, width, s)
+		e.cachedWidth = width
+		return e.rendered
+	case userEntry:
+		out = label + s.sender.Render("You: ") + text
+	case geminiEntry:
+		out = label + s.response.Render("Gemini: ") + text
+	case errorEntry:
+		out = s.err.Render(text)
+	default:
+		out = text
+	}
+
+	e.rendered = renderMarkdownBlocks(out, width, s, e.blocks)
+	e.cachedWidth = width
+	return e.rendered
+}
+
+// renderMarkdownBlocks wraps prose at word boundaries, breaking tokens longer
+// than the width (such as URLs), and renders fenced code blocks with
+// renderCodeBlock. The k-th fence in text is described by blocks[k].
+func renderMarkdownBlocks(text string, width int, s styles, blocks []*codeBlock) string {
+	if width <= 0 {
+		return text
+	}
+
+	var (
+		out     []string
+		code    []string
+		inFence bool
+		k       int
+	)
+	block := func() *codeBlock {
+		if k < len(blocks) {
+			return blocks[k]
+		}
+		return &codeBlock{}
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if inFence {
+				out = append(out, renderCodeBlock(block(), code, width, s)...)
+				code = nil
+				k++
+			}
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			code = append(code, line)
+			continue
+		}
+		out = append(out, wrapLine(line, width))
+	}
+	// An unterminated fence, e.g. while a response is still streaming.
+	if inFence {
+		out = append(out, renderCodeBlock(block(), code, width, s)...)
+	}
+	return strings.Join(out, "\n")
+}
+
+// wrapLine word-wraps line and hard-wraps any word that still does not fit.
+func wrapLine(line string, width int) string {
+	if lipgloss.Width(line) <= width {
+		return line
+	}
+	return wrapHard(wordwrap.String(line, width), width)
+}
+
+// wrapHard breaks lines at exactly width cells, ignoring word boundaries.
+func wrapHard(s string, width int) string {
+	return wrap.String(s, width)
+}
@@ -0,0 +1,74 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google-gemini/gemini-cli-go/pkg/checkpoint"
+	"github.com/google-gemini/gemini-cli-go/pkg/i18n"
+	"github.com/google-gemini/gemini-cli-go/pkg/tools"
+)
+
+// restoreCommand runs /restore, listing the checkpoints taken before file
+// changes were applied, or /restore <id>, which returns the files of a
+// checkpoint to their earlier state once the user confirms the diff.
+func (m model) restoreCommand(args string) model {
+	m.textarea.Reset()
+	if !m.inConversation {
+		m.inConversation = true
+	}
+	root := m.workspace.Roots[0]
+
+	id := strings.TrimSpace(args)
+	if id == "" {
+		list, err := checkpoint.List(root)
+		if err != nil {
+			m.convo.add(errorEntry, fmt.Sprintf("Could not list checkpoints: %v", err))
+			return m
+		}
+		if len(list) == 0 {
+			m.convo.add(infoEntry, i18n.T("No checkpoints. They are saved before file changes are applied when general.checkpointing.enabled is set."))
+			return m
+		}
+		var b strings.Builder
+		b.WriteString(i18n.T("Checkpoints (restore one with /restore <id>):"))
+		for _, c := range list {
+			fmt.Fprintf(&b, "\n  %s  %s (%d files)", c.ID, c.Description, len(c.Files))
+		}
+		m.convo.add(infoEntry, b.String())
+		return m
+	}
+
+	cp, err := checkpoint.Load(root, id)
+	if err != nil {
+		m.convo.add(errorEntry, err.Error())
+		return m
+	}
+	tx, err := tools.RestoreCheckpoint(m.workspace, cp)
+	if err != nil {
+		m.convo.add(errorEntry, fmt.Sprintf("Could not restore %s: %v", id, err))
+		return m
+	}
+	if tx.Len() == 0 {
+		m.convo.add(infoEntry, i18n.T("The files already match checkpoint %s.", id))
+		return m
+	}
+	m.convo.add(diffEntry, tx.Preview())
+	m.confirm = &confirmation{
+		prompt: i18n.T("Restore %d file(s) to checkpoint %s? (y/n)", tx.Len(), id),
+		onYes: func(m model) (model, tea.Cmd) {
+			// The user has just confirmed the diff.
+			ws := *m.workspace
+			ws.Confirm = func(context.Context, string, string) (bool, error) { return true, nil }
+			if err := tx.Commit(context.Background(), &ws, "restore "+id); err != nil {
+				m.convo.add(errorEntry, fmt.Sprintf("Could not restore %s: %v", id, err))
+				return m, nil
+			}
+			m.convo.add(infoEntry, i18n.T("Restored checkpoint %s.", id))
+			return m, nil
+		},
+	}
+	return m
+}
@@ -0,0 +1,188 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google-gemini/gemini-cli-go/pkg/generation"
+	"github.com/google-gemini/gemini-cli-go/pkg/i18n"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// maxVariants bounds the number of responses /retry generates to choose
+// from.
+const maxVariants = 8
+
+// variantsMsg carries the responses generated by /retry <n>.
+type variantsMsg struct {
+	// prompt is the prompt the responses answer, and sent the length of
+	// the chat history before it.
+	prompt []genai.Part
+	sent   int
+	// histories are the chat histories ending with each response.
+	histories [][]*genai.Content
+	responses []*genai.GenerateContentResponse
+}
+
+// retryCommand runs /retry [n]: it drops the last response, and generates
+// a new one for the prompt it answered, or n of them to choose from with
+// the number keys.
+func (m model) retryCommand(args string) (model, tea.Cmd) {
+	m.textarea.Reset()
+	if !m.inConversation {
+		m.inConversation = true
+	}
+	n := 1
+	if args = strings.TrimSpace(args); args != "" {
+		var err error
+		if n, err = strconv.Atoi(args); err != nil || n < 1 || n > maxVariants {
+			m.convo.add(errorEntry, i18n.T("Usage: /retry [number of responses, 1-%d]", maxVariants))
+			return m, nil
+		}
+	}
+	if m.client == nil || m.chat == nil {
+		m.convo.add(errorEntry, i18n.T("Cannot retry before the client is initialized."))
+		return m, nil
+	}
+	p := lastPrompt(m.chat.History)
+	if p < 0 {
+		m.convo.add(infoEntry, i18n.T("Nothing to retry."))
+		return m, nil
+	}
+
+	prompt := m.chat.History[p].Parts
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelRequest = cancel
+	if n == 1 {
+		m.chat.History = m.chat.History[:p]
+		m.convo.add(infoEntry, i18n.T("Regenerating the last response..."))
+		return m, safeCmd(m.converse(ctx, prompt, nil))
+	}
+	m.convo.add(infoEntry, i18n.T("Generating %d responses...", n))
+	return m, safeCmd(m.generateVariants(ctx, slices.Clone(m.chat.History[:p]), prompt, n))
+}
+
+// lastPrompt returns the index in history of the last prompt of the user,
+// or -1 if there is none.
+func lastPrompt(history []*genai.Content) int {
+	for i := len(history) - 1; i >= 0; i-- {
+		if startsTurn(history[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// generateVariants returns the command that sends prompt after history n
+// times at once, in chats of their own.
+func (m model) generateVariants(ctx context.Context, history []*genai.Content, prompt []genai.Part, n int) tea.Cmd {
+	client := m.client
+	if capabilitiesOf(m.modelName).functions {
+		client.Tools = []*genai.Tool{m.workspace.Tool()}
+	}
+	prefix := m.responsePrefix()
+	return func() tea.Msg {
+		msg := variantsMsg{
+			prompt:    prompt,
+			sent:      len(history),
+			histories: make([][]*genai.Content, n),
+			responses: make([]*genai.GenerateContentResponse, n),
+		}
+		errs := make([]error, n)
+		var wg sync.WaitGroup
+		for i := range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				cs := client.StartChat()
+				cs.History = slices.Clone(history)
+				msg.responses[i], errs[i] = cs.SendMessage(generation.WithPrefix(ctx, prefix), prompt...)
+				if errs[i] == nil && prefix != "" && len(cs.History) > len(history)+1 {
+					generation.AddPrefix(cs.History[len(cs.History)-1], prefix)
+					generation.AddPrefix(msg.responses[i].Candidates[0].Content, prefix)
+				}
+				msg.histories[i] = cs.History
+			}()
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				return errMsg(fmt.Errorf("failed to generate content: %w", err))
+			}
+		}
+		return msg
+	}
+}
+
+// showVariants lists the responses of msg and waits for the user to pick
+// one.
+func (m model) showVariants(msg variantsMsg) model {
+	m.cancelRequest = nil
+	for i, resp := range msg.responses {
+		m.convo.add(infoEntry, i18n.T("Response %d of %d:", i+1, len(msg.responses))+"\n"+variantText(resp))
+	}
+	m.variants = &msg
+	m.scrollOffset = 0
+	return m
+}
+
+// variantText describes resp for choosing among variants: its text, and
+// the tools it calls.
+func variantText(resp *genai.GenerateContentResponse) string {
+	var b strings.Builder
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return i18n.T("(empty response)")
+	}
+	for _, p := range resp.Candidates[0].Content.Parts {
+		switch v := p.(type) {
+		case genai.Text:
+			b.WriteString(string(v))
+		case genai.FunctionCall:
+			fmt.Fprintf(&b, "\n%s", i18n.T("(calls %s)", v.Name))
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// handleVariantKey picks a response with the number keys while they are
+// offered. Esc keeps the previous response.
+func (m model) handleVariantKey(msg tea.KeyMsg) (model, tea.Cmd, bool) {
+	if m.variants == nil {
+		return m, nil, false
+	}
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.variants = nil
+		m.convo.add(infoEntry, i18n.T("Kept the previous response."))
+		return m, nil, true
+	case tea.KeyRunes:
+		if i, err := strconv.Atoi(string(msg.Runes)); err == nil && i >= 1 && i <= len(m.variants.responses) {
+			m, cmd := m.pickVariant(i - 1)
+			return m, cmd, true
+		}
+	}
+	return m, nil, true
+}
+
+// pickVariant continues the conversation with response i of the offered
+// ones, replacing the previous response in the chat history, and runs its
+// tool calls.
+func (m model) pickVariant(i int) (model, tea.Cmd) {
+	v := m.variants
+	m.variants = nil
+	m.chat.History = v.histories[i]
+	m.convo.add(infoEntry, i18n.T("Continuing with response %d.", i+1))
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelRequest = cancel
+	return m, safeCmd(m.converse(ctx, v.prompt, &pending{resp: v.responses[i], sent: v.sent}))
+}
+
+// variantStatus is the footer while responses are offered.
+func (m model) variantStatus() string {
+	return i18n.T("Pick a response with 1-%d, or Esc to keep the previous one", len(m.variants.responses))
+}
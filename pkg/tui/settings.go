@@ -0,0 +1,334 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/i18n"
+)
+
+// settingKind determines how a setting is edited.
+type settingKind int
+
+const (
+	boolSetting settingKind = iota
+	enumSetting
+	stringSetting
+	intSetting
+)
+
+// settingDef is a setting editable in the /settings dialog.
+type settingDef struct {
+	category string
+	key      string
+	kind     settingKind
+	options  []string // for enumSetting; "" means unset
+	get      func(*config.Settings) string
+	set      func(*config.Settings, string)
+	// restart is set for settings only read at startup.
+	restart bool
+}
+
+// editableSettings are the settings shown in /settings, grouped by category.
+var editableSettings = []settingDef{
+	{
+		category: "General", key: "general.preferredEditor", kind: stringSetting,
+		get: func(s *config.Settings) string { return general(s).PreferredEditor },
+		set: func(s *config.Settings, v string) { general(s).PreferredEditor = v },
+	},
+	{
+		category: "General", key: "general.disableUpdateNag", kind: boolSetting, restart: true,
+		get: func(s *config.Settings) string { return strconv.FormatBool(general(s).DisableUpdateNag) },
+		set: func(s *config.Settings, v string) { general(s).DisableUpdateNag = v == "true" },
+	},
+	{
+		category: "UI", key: "ui.hideTips", kind: boolSetting,
+		get: func(s *config.Settings) string { return strconv.FormatBool(ui(s).HideTips) },
+		set: func(s *config.Settings, v string) { ui(s).HideTips = v == "true" },
+	},
+	{
+		category: "UI", key: "ui.locale", kind: enumSetting,
+		options: []string{"", "en", "de", "es", "fr", "ja"},
+		get:     func(s *config.Settings) string { return ui(s).Locale },
+		set:     func(s *config.Settings, v string) { ui(s).Locale = v },
+	},
+	{
+		category: "UI", key: "ui.inlineImages", kind: boolSetting,
+		get: func(s *config.Settings) string { return strconv.FormatBool(ui(s).InlineImages) },
+		set: func(s *config.Settings, v string) { ui(s).InlineImages = v == "true" },
+	},
+	{
+		category: "UI", key: "ui.footer.hideCWD", kind: boolSetting,
+		get: func(s *config.Settings) string { return strconv.FormatBool(footer(s).HideCWD) },
+		set: func(s *config.Settings, v string) { footer(s).HideCWD = v == "true" },
+	},
+	{
+		category: "UI", key: "ui.footer.hideSandboxStatus", kind: boolSetting,
+		get: func(s *config.Settings) string { return strconv.FormatBool(footer(s).HideSandboxStatus) },
+		set: func(s *config.Settings, v string) { footer(s).HideSandboxStatus = v == "true" },
+	},
+	{
+		category: "UI", key: "ui.footer.hideModelInfo", kind: boolSetting,
+		get: func(s *config.Settings) string { return strconv.FormatBool(footer(s).HideModelInfo) },
+		set: func(s *config.Settings, v string) { footer(s).HideModelInfo = v == "true" },
+	},
+	{
+		category: "Model", key: "model.name", kind: stringSetting, restart: true,
+		get: func(s *config.Settings) string { return modelSettings(s).Name },
+		set: func(s *config.Settings, v string) { modelSettings(s).Name = v },
+	},
+	{
+		category: "Model", key: "model.maxSessionTurns", kind: intSetting,
+		get: func(s *config.Settings) string { return strconv.Itoa(modelSettings(s).MaxSessionTurns) },
+		set: func(s *config.Settings, v string) { modelSettings(s).MaxSessionTurns, _ = strconv.Atoi(v) },
+	},
+	{
+		category: "Tools", key: "tools.sandbox", kind: enumSetting, restart: true,
+		options: []string{"", "docker", "podman", "sandbox-exec"},
+		get: func(s *config.Settings) string {
+			if s.Tools == nil || s.Tools.Sandbox == nil {
+				return ""
+			}
+			return fmt.Sprint(s.Tools.Sandbox)
+		},
+		set: func(s *config.Settings, v string) {
+			if v == "" {
+				toolsSettings(s).Sandbox = nil
+			} else {
+				toolsSettings(s).Sandbox = v
+			}
+		},
+	},
+	{
+		category: "Tools", key: "tools.shell.showColor", kind: boolSetting,
+		get: func(s *config.Settings) string { return strconv.FormatBool(shell(s).ShowColor) },
+		set: func(s *config.Settings, v string) { shell(s).ShowColor = v == "true" },
+	},
+	{
+		category: "Tools", key: "tools.shell.outputEncoding", kind: stringSetting,
+		get: func(s *config.Settings) string { return shell(s).OutputEncoding },
+		set: func(s *config.Settings, v string) { shell(s).OutputEncoding = v },
+	},
+}
+
+// The accessors below return a settings section, creating it if needed.
+
+func general(s *config.Settings) *config.GeneralSettings {
+	if s.General == nil {
+		s.General = &config.GeneralSettings{}
+	}
+	return s.General
+}
+
+func ui(s *config.Settings) *config.UISettings {
+	if s.UI == nil {
+		s.UI = &config.UISettings{}
+	}
+	return s.UI
+}
+
+func footer(s *config.Settings) *config.FooterSettings {
+	u := ui(s)
+	if u.Footer == nil {
+		u.Footer = &config.FooterSettings{}
+	}
+	return u.Footer
+}
+
+func modelSettings(s *config.Settings) *config.ModelSettings {
+	if s.Model == nil {
+		s.Model = &config.ModelSettings{}
+	}
+	return s.Model
+}
+
+func toolsSettings(s *config.Settings) *config.ToolsSettings {
+	if s.Tools == nil {
+		s.Tools = &config.ToolsSettings{}
+	}
+	return s.Tools
+}
+
+func shell(s *config.Settings) *config.ShellSettings {
+	t := toolsSettings(s)
+	if t.Shell == nil {
+		t.Shell = &config.ShellSettings{}
+	}
+	return t.Shell
+}
+
+// settingsDialog is the /settings editor, shown in place of the
+// conversation while open.
+type settingsDialog struct {
+	scope    config.Scope
+	values   *config.Settings // the settings of scope
+	selected int
+	editing  bool
+	input    textinput.Model
+	status   string
+}
+
+// openSettings runs /settings.
+func (m model) openSettings() model {
+	m.textarea.Reset()
+	d := &settingsDialog{scope: config.UserScope}
+	if err := d.load(); err != nil {
+		m.convo.add(errorEntry, fmt.Sprintf("Could not load settings: %v", err))
+		return m
+	}
+	m.settingsDialog = d
+	return m
+}
+
+func (d *settingsDialog) load() error {
+	values, err := config.LoadScope(d.scope)
+	if err != nil {
+		return err
+	}
+	d.values = values
+	return nil
+}
+
+// handleSettingsKey handles all keys while the dialog is open: up/down
+// select a setting, Enter or Space toggles booleans, cycles enums and edits
+// strings and numbers, Tab switches between user and workspace scope and Esc
+// closes the dialog.
+func (m model) handleSettingsKey(msg tea.KeyMsg) (model, bool) {
+	d := m.settingsDialog
+	if d == nil {
+		return m, false
+	}
+	def := editableSettings[d.selected]
+
+	if d.editing {
+		switch msg.Type {
+		case tea.KeyEnter:
+			value := strings.TrimSpace(d.input.Value())
+			if def.kind == intSetting {
+				if _, err := strconv.Atoi(value); err != nil {
+					d.status = fmt.Sprintf("%s must be a whole number.", def.key)
+					return m, true
+				}
+			}
+			d.editing = false
+			return m.applySetting(def, value), true
+		case tea.KeyEsc:
+			d.editing = false
+			d.status = ""
+		default:
+			d.input, _ = d.input.Update(msg)
+		}
+		return m, true
+	}
+
+	switch msg.String() {
+	case "up":
+		d.selected = (d.selected - 1 + len(editableSettings)) % len(editableSettings)
+	case "down":
+		d.selected = (d.selected + 1) % len(editableSettings)
+	case "tab":
+		d.scope = 1 - d.scope
+		if err := d.load(); err != nil {
+			d.status = fmt.Sprintf("Could not load %s settings: %v", d.scope, err)
+		}
+	case "esc":
+		m.settingsDialog = nil
+	case "enter", " ":
+		current := def.get(d.values)
+		switch def.kind {
+		case boolSetting:
+			return m.applySetting(def, strconv.FormatBool(current != "true")), true
+		case enumSetting:
+			next := def.options[0]
+			for i, o := range def.options {
+				if o == current {
+					next = def.options[(i+1)%len(def.options)]
+				}
+			}
+			return m.applySetting(def, next), true
+		default:
+			d.input = textinput.New()
+			d.input.SetValue(current)
+			d.input.CursorEnd()
+			d.input.Focus()
+			d.editing = true
+		}
+	}
+	return m, true
+}
+
+// applySetting saves a setting to the dialog's scope and reloads the merged
+// settings, so settings read while running take effect at once.
+func (m model) applySetting(def settingDef, value string) model {
+	d := m.settingsDialog
+	def.set(d.values, value)
+	if err := config.SaveScope(d.scope, d.values); err != nil {
+		d.status = fmt.Sprintf("Could not save %s: %v", def.key, err)
+		return m
+	}
+	if merged, err := config.Load(); err == nil {
+		// Update in place: the workspace shares this pointer.
+		*m.settings = *merged
+		i18n.SetLocale(ui(m.settings).Locale)
+		m.textarea.Placeholder = i18n.T(inputPlaceholder)
+	}
+	d.status = fmt.Sprintf("Saved %s = %q to %s settings.", def.key, value, d.scope)
+	if def.restart {
+		d.status += " Restart to apply it."
+	}
+	return m
+}
+
+// renderSettings renders the dialog and returns the line of the selected
+// setting, so that the view can keep it visible.
+func (m model) renderSettings() (string, int) {
+	d := m.settingsDialog
+	var b strings.Builder
+	b.WriteString(i18n.T("Settings (%s scope, Tab to switch)", i18n.T(d.scope.String())) + "\n")
+
+	width := 0
+	for _, def := range editableSettings {
+		width = max(width, len(def.key))
+	}
+	category, selectedLine := "", 0
+	for i, def := range editableSettings {
+		if def.category != category {
+			category = def.category
+			b.WriteString("\n" + m.styles.codeHeader.Render(category) + "\n")
+		}
+		value := def.get(d.values)
+		switch {
+		case i == d.selected && d.editing:
+			value = d.input.View()
+		case def.kind == enumSetting && value == "":
+			value = "(unset)"
+		case def.kind == stringSetting:
+			value = fmt.Sprintf("%q", value)
+		}
+		line := fmt.Sprintf("  %-*s  %s", width, def.key, value)
+		if i == d.selected {
+			line = m.styles.highlight.Render(line)
+			selectedLine = strings.Count(b.String(), "\n")
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String(), selectedLine
+}
+
+// settingsStatus is shown in the footer while the dialog is open: the
+// result of the last change, followed by the keys.
+func (m model) settingsStatus() string {
+	d := m.settingsDialog
+	keys := i18n.T("↑/↓ select, Enter/Space change, Tab switch scope, Esc close")
+	if d.editing {
+		keys = i18n.T("Enter to save, Esc to cancel")
+	}
+	if d.status == "" {
+		return keys
+	}
+	return m.styles.highlight.Render(d.status) + "  " + keys
+}
@@ -0,0 +1,115 @@
+package tui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google-gemini/gemini-cli-go/pkg/i18n"
+	"github.com/google-gemini/gemini-cli-go/pkg/tools"
+	"github.com/muesli/reflow/truncate"
+)
+
+// shellStartedMsg carries a command the shell tool started in a
+// pseudo-terminal, shellOutputMsg tells that it wrote output, and
+// shellExitedMsg that it exited.
+type (
+	shellStartedMsg struct{ session *tools.ShellSession }
+	shellOutputMsg  struct{}
+	shellExitedMsg  struct{ session *tools.ShellSession }
+)
+
+// shellStarted is the tools.Workspace.ShellStarted of the TUI. It runs in
+// the goroutine of the request and passes the command to the program.
+func shellStarted(s *tools.ShellSession) {
+	s.Changed = func() { term.send(shellOutputMsg{}) }
+	s.Exited = func() { term.send(shellExitedMsg{s}) }
+	term.send(shellStartedMsg{s})
+}
+
+// showShell shows the output of the command of msg above the input until
+// it exits.
+func (m model) showShell(msg shellStartedMsg) model {
+	m.shell, m.shellFocused = msg.session, false
+	m.shell.Resize(tools.ShellRows, max(m.viewport.Width, 1))
+	return m
+}
+
+// endShell stops showing the command of msg, which exited.
+func (m model) endShell(msg shellExitedMsg) model {
+	if m.shell == msg.session {
+		m.shell, m.shellFocused = nil, false
+	}
+	return m
+}
+
+// handleShellKey switches with Ctrl+F between typing into the running
+// command and into the input, and types keys into the command while it
+// has the focus.
+func (m model) handleShellKey(msg tea.KeyMsg) (model, bool) {
+	if m.shell == nil {
+		return m, false
+	}
+	if msg.Type == tea.KeyCtrlF {
+		m.shellFocused = !m.shellFocused
+		return m, true
+	}
+	if !m.shellFocused {
+		return m, false
+	}
+	if b := keyBytes(msg); len(b) > 0 {
+		m.shell.Write(b)
+	}
+	return m, true
+}
+
+// keyEscapes are the sequences terminals send for keys that are not
+// characters.
+var keyEscapes = map[tea.KeyType]string{
+	tea.KeyUp:       "\x1b[A",
+	tea.KeyDown:     "\x1b[B",
+	tea.KeyRight:    "\x1b[C",
+	tea.KeyLeft:     "\x1b[D",
+	tea.KeyHome:     "\x1b[H",
+	tea.KeyEnd:      "\x1b[F",
+	tea.KeyShiftTab: "\x1b[Z",
+	tea.KeyInsert:   "\x1b[2~",
+	tea.KeyDelete:   "\x1b[3~",
+	tea.KeyPgUp:     "\x1b[5~",
+	tea.KeyPgDown:   "\x1b[6~",
+	tea.KeySpace:    " ",
+}
+
+// keyBytes returns what a terminal sends to a program for msg, or nothing
+// for keys it has no sequence for.
+func keyBytes(msg tea.KeyMsg) []byte {
+	var b []byte
+	if msg.Alt {
+		b = append(b, '\x1b')
+	}
+	switch {
+	case msg.Type == tea.KeyRunes:
+		return append(b, string(msg.Runes)...)
+	case keyEscapes[msg.Type] != "":
+		return append(b, keyEscapes[msg.Type]...)
+	case msg.Type >= 0 && msg.Type < 32 || msg.Type == tea.KeyBackspace:
+		// Control keys, Enter, Tab and Esc are their control codes.
+		return append(b, byte(msg.Type))
+	}
+	return nil
+}
+
+// renderShell renders the last lines of the running command's output
+// above the input.
+func (m model) renderShell() string {
+	hint := i18n.T("Ctrl+F to type into it")
+	if m.shellFocused {
+		hint = i18n.T("Ctrl+F to return to the input")
+	}
+	header := i18n.T("Running: %s", strings.ReplaceAll(m.shell.Command, "\n", " "))
+	lines := []string{m.styles.codeHeader.Render(truncate.StringWithTail(header, uint(max(m.viewport.Width-len(hint)-3, 10)), "…")) +
+		"  " + m.styles.lineNumber.Render(hint)}
+	for _, line := range m.shell.Screen(tools.ShellRows) {
+		lines = append(lines, truncate.String(line, uint(max(m.viewport.Width, 1))))
+	}
+	return strings.Join(lines, "\n")
+}
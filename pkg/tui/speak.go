@@ -0,0 +1,75 @@
+package tui
+
+import (
+	"context"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google-gemini/gemini-cli-go/pkg/audio"
+	"github.com/google-gemini/gemini-cli-go/pkg/i18n"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// playedMsg reports that playing a spoken response ended.
+type playedMsg struct{ err error }
+
+// speakCommand runs /speak [on|off|<voice>]: it asks for the responses to
+// be spoken, in voice if given, or stops asking.
+func (m model) speakCommand(args string) model {
+	m.textarea.Reset()
+	if !m.inConversation {
+		m.inConversation = true
+	}
+	switch args = strings.TrimSpace(args); args {
+	case "":
+		if m.voice == "" {
+			m.voice = audio.DefaultVoice
+		} else {
+			m.voice = ""
+		}
+	case "on":
+		m.voice = audio.DefaultVoice
+	case "off":
+		m.voice = ""
+	default:
+		m.voice = args
+	}
+	if m.voice == "" {
+		m.convo.add(infoEntry, i18n.T("Spoken responses are off."))
+		return m
+	}
+	m.convo.add(infoEntry, i18n.T("Spoken responses are on, in the %s voice. They need a model that can speak, such as %s (see /model).", m.voice, "gemini-2.5-flash-preview-tts"))
+	return m
+}
+
+// completeSpeak offers the /speak arguments.
+func completeSpeak(m model, words []string) []suggestion {
+	if len(words) > 0 {
+		return nil
+	}
+	return []suggestion{
+		{value: "on", description: "Speak the responses"},
+		{value: "off", description: "Stop speaking them"},
+	}
+}
+
+// showAudio saves the audio of a response and shows where, and returns the
+// command that plays it if the responses are spoken.
+func (m model) showAudio(blobs []genai.Blob) (model, tea.Cmd) {
+	var cmds []tea.Cmd
+	for _, blob := range blobs {
+		path, err := audio.Save(blob)
+		if err != nil {
+			m.convo.add(errorEntry, i18n.T("Received audio (%s) but could not save it: %v", blob.MIMEType, err))
+			continue
+		}
+		m.convo.add(infoEntry, i18n.T("Audio saved to %s", path))
+		if m.voice != "" {
+			cmds = append(cmds, func() tea.Msg {
+				return playedMsg{audio.Play(context.Background(), path)}
+			})
+		}
+	}
+	// One after the other, not all at once.
+	return m, tea.Sequence(cmds...)
+}
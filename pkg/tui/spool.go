@@ -0,0 +1,159 @@
+package tui
+
+import (
+	"context"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/shutdown"
+)
+
+const (
+	// maxResidentBytes bounds the text and renderings of the conversation
+	// entries kept in memory. Beyond it, the entries drawn least recently
+	// spill to disk and are read back when they scroll into view again.
+	maxResidentBytes = 8 << 20
+	// largeEntryBytes is the size beyond which an entry, such as a long
+	// tool output, spills as soon as it is no longer on screen.
+	largeEntryBytes = 64 << 10
+	// residentEntries is the number of latest entries that never spill.
+	residentEntries = 20
+)
+
+// spool is the temporary file conversation entries spill to. Entries are
+// appended once and read back by offset; the file is removed when the CLI
+// exits.
+type spool struct {
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// write appends text to the spool, creating its file on first use, and
+// returns the offset it starts at.
+func (s *spool) write(text string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		// CreateTemp makes the file readable by the user only.
+		f, err := os.CreateTemp("", "gemini-conversation-*")
+		if err != nil {
+			return 0, err
+		}
+		s.f = f
+		shutdown.Register("remove the conversation spool", func(context.Context) error {
+			return s.close()
+		})
+	}
+	off := s.size
+	n, err := s.f.WriteAt([]byte(text), off)
+	s.size += int64(n)
+	return off, err
+}
+
+// read returns the n bytes at off.
+func (s *spool) read(off int64, n int) (string, error) {
+	buf := make([]byte, n)
+	s.mu.Lock()
+	f := s.f
+	s.mu.Unlock()
+	if f == nil {
+		return "", os.ErrClosed
+	}
+	if _, err := f.ReadAt(buf, off); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// close removes the spool file.
+func (s *spool) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	s.f.Close()
+	err := os.Remove(s.f.Name())
+	s.f = nil
+	return err
+}
+
+// content returns the text of e, reading it back from the spool if it
+// spilled. The text read back is not kept, except by load.
+func (e *entry) content() string {
+	if !e.spilled || e.text != "" {
+		return e.text
+	}
+	text, err := e.spool.read(e.offset, e.length)
+	if err != nil {
+		return "[" + err.Error() + "]"
+	}
+	return text
+}
+
+// load keeps the text of e in memory again, for rendering.
+func (e *entry) load() {
+	if e.spilled && e.text == "" && e.length > 0 {
+		e.text = e.content()
+	}
+}
+
+// residentSize is the memory the text and rendering of e take.
+func (e *entry) residentSize() int {
+	return len(e.text) + len(e.rendered)
+}
+
+// spill writes the text of e to the spool, unless it was written before,
+// and drops it and its rendering from memory.
+func (e *entry) spill(s *spool) error {
+	if !e.spilled {
+		off, err := s.write(e.text)
+		if err != nil {
+			return err
+		}
+		e.spool, e.offset, e.length, e.spilled = s, off, len(e.text), true
+	}
+	e.text, e.rendered, e.cachedWidth = "", "", 0
+	return nil
+}
+
+// trim spills large entries that are off screen, then the entries drawn
+// least recently until the resident ones fit in maxResident. The latest
+// entries and those on screen stay. Should the spool fail, the entries
+// stay in memory.
+func (c *conversation) trim() {
+	total := 0
+	var candidates []*entry
+	for i, e := range c.entries {
+		size := e.residentSize()
+		if size == 0 {
+			continue
+		}
+		if i >= len(c.entries)-residentEntries || e.lastDrawn == c.frame {
+			total += size
+			continue
+		}
+		if size > largeEntryBytes {
+			if e.spill(&c.spool) == nil {
+				continue
+			}
+		}
+		total += size
+		candidates = append(candidates, e)
+	}
+	if total <= c.maxResident {
+		return
+	}
+	slices.SortStableFunc(candidates, func(a, b *entry) int { return int(a.lastDrawn - b.lastDrawn) })
+	for _, e := range candidates {
+		size := e.residentSize()
+		if e.spill(&c.spool) != nil {
+			return
+		}
+		if total -= size; total <= c.maxResident {
+			return
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google-gemini/gemini-cli-go/pkg/i18n"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// modelSwitchedMsg carries the model the conversation moved to, with the
+// chat history re-encoded for it.
+type modelSwitchedMsg struct {
+	name    string
+	model   *genai.GenerativeModel
+	history []*genai.Content
+	// omitted is the number of parts the model could not take, which were
+	// replaced by notes saying what they were.
+	omitted int
+	// summarized is set if the history did not fit the model's context
+	// window and was replaced by a summary.
+	summarized bool
+}
+
+// modelCommand runs /model: without an argument it shows the model in use,
+// and with one it switches to that model, keeping the conversation.
+func (m model) modelCommand(args string) (model, tea.Cmd) {
+	m.textarea.Reset()
+	if !m.inConversation {
+		m.inConversation = true
+	}
+	name := strings.TrimSpace(args)
+	switch {
+	case name == "":
+		m.convo.add(infoEntry, i18n.T("Using %s. Switch with /model <name>.", m.modelName))
+		return m, nil
+	case name == m.modelName:
+		m.convo.add(infoEntry, i18n.T("Already using %s.", name))
+		return m, nil
+	case m.api == nil || m.chat == nil:
+		m.convo.add(errorEntry, i18n.T("Cannot switch models before the client is initialized."))
+		return m, nil
+	}
+	m.convo.add(infoEntry, i18n.T("Switching to %s...", name))
+	return m, safeCmd(m.switchModel(name))
+}
+
+// switchModel returns the command that moves the conversation to the model
+// named name. Parts of the history the model cannot take are replaced by
+// notes, and a history that does not fit its context window is summarized
+// by the current model first, which still holds all of it.
+func (m model) switchModel(name string) tea.Cmd {
+	current, history := m.client, slices.Clone(m.chat.History)
+	next := configureLike(m.api.GenerativeModel(name), current, capabilitiesOf(name))
+
+	return func() tea.Msg {
+		ctx := context.Background()
+		info, err := next.Info(ctx)
+		if err != nil {
+			return errMsg(fmt.Errorf("could not switch to %s: %w", name, err))
+		}
+		reencoded, omitted := reencodeHistory(history, capabilitiesOf(name))
+		switched := modelSwitchedMsg{name: name, model: next, history: reencoded, omitted: omitted}
+		if len(reencoded) == 0 || info.InputTokenLimit <= 0 {
+			return switched
+		}
+
+		var parts []genai.Part
+		for _, c := range reencoded {
+			parts = append(parts, c.Parts...)
+		}
+		resp, err := next.CountTokens(ctx, parts...)
+		if err != nil {
+			return errMsg(fmt.Errorf("could not switch to %s: %w", name, err))
+		}
+		// Leave room to continue the conversation.
+		if int64(resp.TotalTokens)*100 < int64(info.InputTokenLimit)*contextHighPercent {
+			return switched
+		}
+		summary, err := summarize(ctx, current, history)
+		if err != nil {
+			return errMsg(fmt.Errorf("could not summarize the conversation for %s: %w", name, err))
+		}
+		if strings.TrimSpace(summary) == "" {
+			return errMsg(fmt.Errorf("could not summarize the conversation for %s: the summary was empty", name))
+		}
+		switched.history, switched.omitted, switched.summarized = summaryHistory(summary), 0, true
+		return switched
+	}
+}
+
+// configureLike configures next, a model with caps, as current is, except
+// for the tools if it cannot call functions. It returns next.
+func configureLike(next, current *genai.GenerativeModel, caps modelCapabilities) *genai.GenerativeModel {
+	next.GenerationConfig = current.GenerationConfig
+	next.SafetySettings = current.SafetySettings
+	next.SystemInstruction = current.SystemInstruction
+	if caps.functions {
+		next.Tools = current.Tools
+		next.ToolConfig = current.ToolConfig
+	}
+	return next
+}
+
+// applyModelSwitch continues the conversation with the model of msg.
+func (m model) applyModelSwitch(msg modelSwitchedMsg) (model, tea.Cmd) {
+	m.client = msg.model
+	m.chat = msg.model.StartChat()
+	m.chat.History = msg.history
+	m.modelName = msg.name
+	// The gauge looks the limit of the new model up again.
+	m.usage = contextUsage{}
+
+	switch {
+	case msg.summarized:
+		m.convo.add(infoEntry, i18n.T("Switched to %s. The conversation did not fit its context window and was summarized.", msg.name))
+	case msg.omitted > 0:
+		m.convo.add(infoEntry, i18n.T("Switched to %s. %d part(s) of the conversation it cannot take were replaced by notes.", msg.name, msg.omitted))
+	default:
+		m.convo.add(infoEntry, i18n.T("Switched to %s.", msg.name))
+	}
+	return m, safeCmd(m.countTokens())
+}
+
+// modelCapabilities are the kinds of parts a model takes in its history.
+type modelCapabilities struct {
+	media     bool
+	functions bool
+}
+
+// capabilitiesOf returns the capabilities of the model named name, going by
+// its family: the Gemma models served by the API take text only, and the
+// Gemini models take everything the CLI sends. Neither do Gemma models
+// think, so the thinking budget is not sent to them (see
+// thinking.Supports).
+func capabilitiesOf(name string) modelCapabilities {
+	name = strings.TrimPrefix(strings.ToLower(name), "models/")
+	if strings.HasPrefix(name, "gemma") {
+		return modelCapabilities{}
+	}
+	return modelCapabilities{media: true, functions: true}
+}
+
+// reencodeHistory returns history with the parts a model with caps cannot
+// take replaced by text notes describing them, and how many were replaced.
+// history is not modified.
+func reencodeHistory(history []*genai.Content, caps modelCapabilities) ([]*genai.Content, int) {
+	var (
+		out      []*genai.Content
+		replaced int
+	)
+	for _, c := range history {
+		parts := make([]genai.Part, 0, len(c.Parts))
+		for _, p := range c.Parts {
+			if note, ok := partNote(p, caps); ok {
+				parts = append(parts, genai.Text(note))
+				replaced++
+				continue
+			}
+			parts = append(parts, p)
+		}
+		out = append(out, &genai.Content{Role: c.Role, Parts: parts})
+	}
+	return out, replaced
+}
+
+// partNote returns the note replacing p for a model with caps, and whether
+// p needs replacing.
+func partNote(p genai.Part, caps modelCapabilities) (string, bool) {
+	switch v := p.(type) {
+	case genai.Blob:
+		return fmt.Sprintf("[%s attachment omitted]", v.MIMEType), !caps.media
+	case genai.FileData:
+		return fmt.Sprintf("[%s file %s omitted]", v.MIMEType, v.URI), !caps.media
+	case genai.FunctionCall:
+		return fmt.Sprintf("[called the %s tool with %v]", v.Name, v.Args), !caps.functions
+	case genai.FunctionResponse:
+		return fmt.Sprintf("[the %s tool returned %v]", v.Name, v.Response), !caps.functions
+	case *genai.FunctionResponse:
+		return fmt.Sprintf("[the %s tool returned %v]", v.Name, v.Response), !caps.functions
+	}
+	return "", false
+}
@@ -0,0 +1,137 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"sync"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// killTimeout bounds how long a graceful quit may take after a termination
+// signal before the program is killed outright.
+const killTimeout = 3 * time.Second
+
+// interruptMsg is delivered when SIGINT arrives while stdin is not in raw
+// mode. It is handled exactly like a Ctrl+C key press.
+type interruptMsg struct{}
+
+// terminal owns the running program so that every exit path (normal quit,
+// signals and panics in command goroutines) restores the terminal the same way.
+type terminal struct {
+	mu      sync.Mutex
+	program *tea.Program
+	once    sync.Once
+}
+
+var term = &terminal{}
+
+func (t *terminal) attach(p *tea.Program) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.program = p
+	t.once = sync.Once{}
+}
+
+func (t *terminal) detach() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.program = nil
+}
+
+// send delivers msg to the program, and reports whether one is running.
+func (t *terminal) send(msg tea.Msg) bool {
+	t.mu.Lock()
+	p := t.program
+	t.mu.Unlock()
+	if p == nil {
+		return false
+	}
+	p.Send(msg)
+	return true
+}
+
+// restore kills the program, which makes bubbletea leave the alternate screen
+// and raw mode, and waits for the teardown to finish. It is safe to call
+// multiple times and from any goroutine.
+func (t *terminal) restore() {
+	t.mu.Lock()
+	p := t.program
+	t.mu.Unlock()
+	if p == nil {
+		return
+	}
+	t.once.Do(func() {
+		p.Kill()
+		p.Wait()
+	})
+}
+
+// handleSignals routes termination signals through the program: SIGINT is
+// treated like Ctrl+C, while SIGTERM and SIGHUP request a graceful quit and
+// fall back to killing the program if it does not exit in time.
+func (t *terminal) handleSignals(p *tea.Program) (stop func()) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case sig := <-sigs:
+				if sig == syscall.SIGINT {
+					p.Send(interruptMsg{})
+					continue
+				}
+				p.Quit()
+				select {
+				case <-done:
+				case <-time.After(killTimeout):
+					t.restore()
+				}
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
+
+// safeCmd wraps a command so that a panic inside its goroutine restores the
+// terminal before the process crashes. bubbletea only recovers panics raised
+// in its own event loop.
+func safeCmd(cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		defer func() {
+			if r := recover(); r != nil {
+				term.restore()
+				fmt.Fprintf(os.Stderr, "Caught panic:\n\n%v\n\n%s", r, debug.Stack())
+				os.Exit(2)
+			}
+		}()
+		return cmd()
+	}
+}
+
+// NewProgram creates the interactive program with the options used by the
+// CLI. Signal handling is done by Start rather than by bubbletea. The UI
+// takes over the alternate screen, unless it runs inline.
+func NewProgram(m tea.Model, opts ...tea.ProgramOption) *tea.Program {
+	base := []tea.ProgramOption{tea.WithoutSignalHandler()}
+	if m, ok := m.(model); !ok || !m.inline {
+		base = append(base, tea.WithAltScreen())
+	}
+	return tea.NewProgram(m, append(base, opts...)...)
+}
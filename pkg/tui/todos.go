@@ -0,0 +1,49 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/i18n"
+	"github.com/google-gemini/gemini-cli-go/pkg/tools"
+)
+
+// todosMsg carries the plan write_todos wrote.
+type todosMsg []tools.Todo
+
+// todosChanged is the tools.Workspace.TodosChanged of the TUI. It runs in
+// the goroutine of the request and passes the plan to the program.
+func todosChanged(todos []tools.Todo) {
+	term.send(todosMsg(todos))
+}
+
+// planActive reports whether the plan has steps left, and is shown.
+func (m model) planActive() bool {
+	for _, t := range m.todos {
+		if !t.Done() {
+			return true
+		}
+	}
+	return false
+}
+
+// renderPlan renders the plan above the input, with the step in progress
+// highlighted.
+func (m model) renderPlan() string {
+	done := 0
+	for _, t := range m.todos {
+		if t.Done() {
+			done++
+		}
+	}
+	lines := []string{m.styles.codeHeader.Render(i18n.T("Plan (%d of %d done)", done, len(m.todos)))}
+	for i, line := range strings.Split(tools.FormatTodos(m.todos), "\n") {
+		switch m.todos[i].Status {
+		case tools.TodoInProgress:
+			line = m.styles.response.Render(line)
+		case tools.TodoCompleted, tools.TodoCancelled:
+			line = m.styles.lineNumber.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,197 @@
+package tui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google-gemini/gemini-cli-go/pkg/i18n"
+	"github.com/google/generative-ai-go/genai"
+)
+
+const (
+	// charsPerToken is the average length of a token, used to estimate the
+	// size of text until it is counted.
+	charsPerToken = 4
+	// mediaTokens is what an image or other attachment is assumed to take.
+	mediaTokens = 258
+
+	// contextLargest is how many of the largest messages /context lists.
+	contextLargest = 5
+)
+
+// entryTokensMsg carries the exact token counts of conversation entries.
+type entryTokensMsg struct {
+	counts map[*entry]int32
+	// report shows /context once the counts are in.
+	report bool
+}
+
+// estimateTokens estimates the number of tokens of text.
+func estimateTokens(text string) int32 {
+	return int32((utf8.RuneCountInString(text) + charsPerToken - 1) / charsPerToken)
+}
+
+// partTokens estimates the number of tokens of p.
+func partTokens(p genai.Part) int32 {
+	switch v := p.(type) {
+	case genai.Text:
+		return estimateTokens(string(v))
+	case genai.Blob, genai.FileData:
+		return mediaTokens
+	}
+	// Function calls and responses are sent as JSON.
+	data, err := json.Marshal(p)
+	if err != nil {
+		return 0
+	}
+	return estimateTokens(string(data))
+}
+
+// contentTokens estimates the number of tokens of c.
+func contentTokens(c *genai.Content) int32 {
+	var n int32
+	for _, p := range c.Parts {
+		n += partTokens(p)
+	}
+	return n
+}
+
+// isMessage reports whether e is a prompt or a response, the entries that
+// take up the model's context.
+func (e *entry) isMessage() bool {
+	return e.kind == userEntry || e.kind == geminiEntry
+}
+
+// tokenLabel describes the size of e, with a ~ while it is an estimate.
+func (e *entry) tokenLabel() string {
+	if !e.isMessage() {
+		return ""
+	}
+	if e.counted {
+		return i18n.T("%s tokens", formatTokens(e.tokens))
+	}
+	return i18n.T("~%s tokens", formatTokens(e.tokens))
+}
+
+// toggleTokens shows or hides the token counts of the messages, counting
+// those that only have estimates when they are shown.
+func (m model) toggleTokens() (model, tea.Cmd) {
+	m.convo.showTokens = !m.convo.showTokens
+	if !m.convo.showTokens {
+		return m, nil
+	}
+	return m, safeCmd(m.countEntryTokens(false))
+}
+
+// countEntryTokens counts the tokens of the messages that only have
+// estimates with CountTokens. Counting stops at the first error, keeping the
+// estimates of the rest.
+func (m model) countEntryTokens(report bool) tea.Cmd {
+	// The texts are read here, as entries may spill while counting.
+	var (
+		pending []*entry
+		texts   []string
+	)
+	for _, e := range m.convo.entries {
+		if e.isMessage() && !e.counted {
+			pending = append(pending, e)
+			texts = append(texts, e.content())
+		}
+	}
+	if m.client == nil || len(pending) == 0 {
+		if report {
+			return func() tea.Msg { return entryTokensMsg{report: true} }
+		}
+		return nil
+	}
+	client := m.client
+
+	return func() tea.Msg {
+		ctx := context.Background()
+		counts := make(map[*entry]int32, len(pending))
+		for i, e := range pending {
+			resp, err := client.CountTokens(ctx, genai.Text(texts[i]))
+			if err != nil {
+				break
+			}
+			counts[e] = resp.TotalTokens
+		}
+		return entryTokensMsg{counts: counts, report: report}
+	}
+}
+
+// applyEntryTokens records the counts of msg, and shows /context if it
+// asked for them.
+func (m model) applyEntryTokens(msg entryTokensMsg) model {
+	for e, n := range msg.counts {
+		e.tokens, e.counted = n, true
+	}
+	if msg.report {
+		m.convo.add(infoEntry, m.contextReport())
+	}
+	return m
+}
+
+// contextCommand runs /context: it counts the messages and reports how much
+// of the context window they use, and which are the largest.
+func (m model) contextCommand() (model, tea.Cmd) {
+	m.textarea.Reset()
+	if !m.inConversation {
+		m.inConversation = true
+	}
+	return m, safeCmd(m.countEntryTokens(true))
+}
+
+// contextReport describes the context usage and lists the largest messages,
+// the first candidates for /compress to drop.
+func (m model) contextReport() string {
+	var (
+		messages []int
+		total    int32
+	)
+	for i, e := range m.convo.entries {
+		if e.isMessage() {
+			messages = append(messages, i)
+			total += e.tokens
+		}
+	}
+	if len(messages) == 0 {
+		return i18n.T("No messages in the context yet.")
+	}
+
+	lines := []string{i18n.T("%d message(s), %s tokens.", len(messages), formatTokens(total))}
+	if m.usage.limit > 0 {
+		lines[0] += " " + i18n.T("The history uses %s of %s tokens.", formatTokens(m.usage.used), formatTokens(m.usage.limit))
+	}
+	slices.SortStableFunc(messages, func(a, b int) int {
+		return int(m.convo.entries[b].tokens - m.convo.entries[a].tokens)
+	})
+	lines = append(lines, i18n.T("Largest messages:"))
+	for _, i := range messages[:min(len(messages), contextLargest)] {
+		e := m.convo.entries[i]
+		sender := i18n.T("You")
+		if e.kind == geminiEntry {
+			sender = "Gemini"
+		}
+		lines = append(lines, fmt.Sprintf("  %-14s %s: %s", e.tokenLabel(), sender, preview(e.content(), 50)))
+	}
+	lines = append(lines, i18n.T("/compress summarizes the older messages and keeps the most recent ones."))
+	return strings.Join(lines, "\n")
+}
+
+// preview returns the first line of text, cut to n runes.
+func preview(text string, n int) string {
+	line, _, more := strings.Cut(strings.TrimSpace(text), "\n")
+	if r := []rune(line); len(r) > n {
+		line, more = string(r[:n]), true
+	}
+	if more {
+		line += "..."
+	}
+	return line
+}
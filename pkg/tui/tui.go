@@ -2,38 +2,75 @@ package tui
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/google-gemini/gemini-cli-go/pkg/apilog"
+	"github.com/google-gemini/gemini-cli-go/pkg/audio"
 	"github.com/google-gemini/gemini-cli-go/pkg/auth"
+	"github.com/google-gemini/gemini-cli-go/pkg/browser"
+	"github.com/google-gemini/gemini-cli-go/pkg/commands"
 	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/finish"
+	"github.com/google-gemini/gemini-cli-go/pkg/generation"
+	"github.com/google-gemini/gemini-cli-go/pkg/i18n"
+	"github.com/google-gemini/gemini-cli-go/pkg/index"
+	"github.com/google-gemini/gemini-cli-go/pkg/session"
+	"github.com/google-gemini/gemini-cli-go/pkg/shutdown"
+	"github.com/google-gemini/gemini-cli-go/pkg/startup"
+	"github.com/google-gemini/gemini-cli-go/pkg/thinking"
+	"github.com/google-gemini/gemini-cli-go/pkg/tools"
 	"github.com/google-gemini/gemini-cli-go/pkg/updatechecker"
-	"github.comcom/google/generative-ai-go/genai"
+	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/option"
 )
 
 type (
 	errMsg             error
-	responseMsg        string
-	conversation       []string
 	UpdateAvailableMsg *updatechecker.ReleaseInfo
 )
 
+// responseMsg carries a model response: its text, any images it
+// contained, and what its tool calls did on the way. history is the chat
+// history that led to it, and tools the tools last declared.
+type responseMsg struct {
+	text     string
+	images   []genai.Blob
+	audio    []genai.Blob
+	activity []activityLine
+	history  []*genai.Content
+	tools    []*genai.Tool
+	// tokens are those the requests of the prompt used.
+	tokens int32
+}
+
+// failedMsg carries the error a request ended with, and the chat history,
+// tools and tokens as of the turns that went through before it.
+type failedMsg struct {
+	err     error
+	history []*genai.Content
+	tools   []*genai.Tool
+	tokens  int32
+}
+
 type model struct {
 	viewport             viewport.Model
 	textarea             textarea.Model
-	senderStyle          lipgloss.Style
-	responseStyle        lipgloss.Style
-	errorStyle           lipgloss.Style
+	styles               styles
+	api                  *genai.Client
 	client               *genai.GenerativeModel
-	convo                conversation
+	chat                 *genai.ChatSession
+	convo                *conversation
 	err                  error
 	updateInfo           *updatechecker.ReleaseInfo
 	geminiMdFileCount    int
@@ -42,11 +79,63 @@ type model struct {
 	modelName            string
 	inConversation       bool
 	credentialsLoadedMsg string
+	// cancelRequest cancels the in-flight model request, if any. The first
+	// Ctrl+C cancels the request; only a second press quits.
+	cancelRequest context.CancelFunc
+	session       *session.Session
+	// pastes holds multi-line pastes represented by placeholders in the
+	// textarea until the input is submitted.
+	pastes         []pastedBlock
+	pastesExpanded bool
+	// scrollOffset is the number of lines the conversation is scrolled up
+	// from the bottom.
+	scrollOffset int
+	// settings are loaded once at startup for UI preferences.
+	settings       *config.Settings
+	search         *search
+	blockSelection *blockSelection
+	confirm        *confirmation
+	// promptArgs is the MCP prompt whose arguments are being asked for.
+	promptArgs *promptArguments
+	// usage is the last estimate of the chat history's size.
+	usage contextUsage
+	// commands are the custom commands loaded at startup.
+	commands   []*commands.Command
+	workspace  *tools.Workspace
+	completion *completion
+	// settingsDialog is the open /settings editor, if any.
+	settingsDialog *settingsDialog
+	// apiLog logs the API traffic while /debug-api is on.
+	apiLog *apilog.Logger
+	// variants are the responses /retry offers to choose from, if any.
+	variants *variantsMsg
+	// todos is the plan the model last wrote with write_todos.
+	todos []tools.Todo
+	// attachments are files --audio attached to the next prompt.
+	attachments []genai.Part
+	// voice speaks the responses while /speak is on.
+	voice string
+	// shell is the command the shell tool runs in a terminal, if any, and
+	// shellFocused whether keys are typed into it.
+	shell        *tools.ShellSession
+	shellFocused bool
+	// indexError is the last failure to update the semantic index shown.
+	indexError string
+	// inline is set if the UI runs without the alternate screen, and
+	// printed is the number of entries it printed to the scrollback.
+	inline  bool
+	printed int
+	// stats count what the session did, and summary is what Start prints
+	// once /quit ended the program.
+	stats   sessionStats
+	summary string
 }
 
+// inputPlaceholder is shown in the empty input.
+const inputPlaceholder = "Type your message or @path/to/file"
+
 func InitialModel() model {
 	ta := textarea.New()
-	ta.Placeholder = "Type your message or @path/to/file"
 	ta.Focus()
 	ta.Prompt = "> "
 	ta.CharLimit = 0
@@ -68,82 +157,374 @@ func InitialModel() model {
 		log.Printf("could not get working directory: %v", err)
 	}
 
+	settings, err := config.Load()
+	if err != nil {
+		log.Printf("could not load settings: %v", err)
+		settings = &config.Settings{}
+	}
+	if settings.UI != nil {
+		i18n.SetLocale(settings.UI.Locale)
+	} else {
+		i18n.SetLocale("")
+	}
+	ta.Placeholder = i18n.T(inputPlaceholder)
+
+	ws, err := tools.NewWorkspace(settings)
+	if err != nil {
+		log.Printf("could not set up the workspace: %v", err)
+		ws = &tools.Workspace{Roots: []string{wd}, Settings: settings}
+	}
+	ws.Confirm = confirmTool
+	ws.TodosChanged = todosChanged
+	ws.ShellStarted = shellStarted
+	ws.FilesChanged = filesChanged
+	ws.Processes = &tools.Processes{}
+	if err := ws.LoadPlugins(context.Background()); err != nil {
+		log.Printf("could not load some tool plugins: %v", err)
+	}
+
+	cmds, err := commands.Load(wd)
+	if err != nil {
+		log.Printf("could not load custom commands: %v", err)
+	}
+
+	logPath, err := apilog.DefaultPath()
+	if err != nil {
+		log.Printf("could not locate the API log: %v", err)
+	}
+
 	return model{
-		textarea:       ta,
-		viewport:       vp,
-		senderStyle:    lipgloss.NewStyle().Foreground(lipgloss.Color("5")),
-		responseStyle:  lipgloss.NewStyle().Foreground(lipgloss.Color("2")),
-		errorStyle:     lipgloss.NewStyle().Foreground(lipgloss.Color("1")),
-		convo:          make(conversation, 0),
+		textarea: ta,
+		viewport: vp,
+		styles: styles{
+			sender:     lipgloss.NewStyle().Foreground(lipgloss.Color("5")),
+			response:   lipgloss.NewStyle().Foreground(lipgloss.Color("2")),
+			err:        lipgloss.NewStyle().Foreground(lipgloss.Color("1")),
+			code:       lipgloss.NewStyle().Background(lipgloss.Color("236")),
+			highlight:  lipgloss.NewStyle().Background(lipgloss.Color("11")).Foreground(lipgloss.Color("0")),
+			codeHeader: lipgloss.NewStyle().Foreground(lipgloss.Color("63")).Bold(true),
+			lineNumber: lipgloss.NewStyle().Foreground(lipgloss.Color("240")),
+
+			diffInsert:     lipgloss.NewStyle().Foreground(lipgloss.Color("2")),
+			diffDelete:     lipgloss.NewStyle().Foreground(lipgloss.Color("1")),
+			diffInsertWord: lipgloss.NewStyle().Foreground(lipgloss.Color("15")).Background(lipgloss.Color("22")),
+			diffDeleteWord: lipgloss.NewStyle().Foreground(lipgloss.Color("15")).Background(lipgloss.Color("52")),
+			diffDim:        lipgloss.NewStyle().Foreground(lipgloss.Color("240")),
+		},
+		convo:          newConversation(),
 		projectName:    filepath.Base(wd),
+		session:        session.New(wd),
 		sandboxActive:  false,
 		modelName:      "gemini-pro",
 		inConversation: false,
+		settings:       settings,
+		commands:       cmds,
+		workspace:      ws,
+		apiLog:         apilog.New(logPath),
+	}
+}
+
+// WithBrowserTools enables the browser tools, as --enable-browser-tools
+// does.
+func (m model) WithBrowserTools() model {
+	if m.workspace.Browser == nil {
+		var settings *config.BrowserSettings
+		if m.settings.Tools != nil {
+			settings = m.settings.Tools.Browser
+		}
+		m.workspace.Browser = browser.New(settings)
 	}
+	return m
+}
+
+// WithApprovalMode sets which changes and commands of tools run without
+// asking, as --approval-mode and --yolo do.
+func (m model) WithApprovalMode(mode tools.ApprovalMode) model {
+	m.workspace.Approval = mode
+	return m
+}
+
+// WithAllowedTools limits the tools to names, as --allowed-tools does.
+func (m model) WithAllowedTools(names []string) model {
+	m.workspace.Allowed = names
+	return m
+}
+
+// WithAllowedMCPServers limits the MCP servers connected to to names, as
+// --allowed-mcp-server-names does.
+func (m model) WithAllowedMCPServers(names []string) model {
+	if len(names) > 0 {
+		if m.settings.MCP == nil {
+			m.settings.MCP = &config.MCPSettings{}
+		}
+		m.settings.MCP.Allowed = names
+	}
+	return m
+}
+
+// WithGeneration sets the generation settings, such as the stop sequences
+// and response prefix --stop and --response-prefix set.
+func (m model) WithGeneration(g *config.GenerationSettings) model {
+	if m.settings.Model == nil {
+		m.settings.Model = &config.ModelSettings{}
+	}
+	m.settings.Model.Generation = g
+	return m
+}
+
+// WithThinkingBudget sets the thinking budget --thinking-budget sets, which
+// takes precedence over model.thinkingBudget and model.reasoningEffort.
+func (m model) WithThinkingBudget(budget int32) model {
+	if m.settings.Model == nil {
+		m.settings.Model = &config.ModelSettings{}
+	}
+	m.settings.Model.ThinkingBudget = &budget
+	return m
+}
+
+// WithAttachments sends parts, such as the audio files --audio attaches,
+// with the first prompt.
+func (m model) WithAttachments(parts []genai.Part) model {
+	m.attachments = parts
+	return m
+}
+
+// WithAPIDebug logs the API traffic from the start, as --debug-api does.
+func (m model) WithAPIDebug() model {
+	m.apiLog.SetEnabled(true)
+	return m
+}
+
+// indexFailedMsg reports that updating the semantic index failed.
+type indexFailedMsg struct{ err error }
+
+// showIndexFailed shows the failure of msg, unless it is the one shown
+// last: updates are retried every few minutes.
+func (m model) showIndexFailed(msg indexFailedMsg) model {
+	if msg.err.Error() == m.indexError {
+		return m
+	}
+	m.indexError = msg.err.Error()
+	m.convo.add(errorEntry, i18n.T("Could not update the semantic index: %v", msg.err))
+	return m
+}
+
+// startIndexUpdates keeps the project's semantic index, if it has one, up
+// to date in the background for the rest of the session.
+func (m model) startIndexUpdates() {
+	if m.workspace.Embedder == nil {
+		return
+	}
+	if s := m.settings.Tools; s != nil && s.Index != nil && s.Index.DisableAutoUpdate {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	// Failures are shown in the conversation: logging them would write
+	// over the screen.
+	go index.Watch(ctx, m.workspace.Roots[0], m.workspace.Embedder, index.DefaultUpdateInterval, func(err error) {
+		term.send(indexFailedMsg{err})
+	})
+	shutdown.Register("stop index updates", func(context.Context) error {
+		cancel()
+		return nil
+	})
+}
+
+// inlineImages reports whether images should be drawn in the terminal
+// rather than saved to temp files.
+func (m model) inlineImages() bool {
+	return m.settings.UI != nil && m.settings.UI.InlineImages
 }
 
 func (m model) Init() tea.Cmd {
-	return tea.Batch(textarea.Blink, m.initClient, m.loadGeminiMdFiles)
+	shutdown.Register("save session", m.session.Save)
+	shutdown.Register("stop background commands", m.workspace.Processes.StopAll)
+	if m.workspace.MCP != nil {
+		shutdown.Register("disconnect MCP servers", func(context.Context) error { return m.workspace.MCP.Close() })
+	}
+	m.startIndexUpdates()
+	return tea.Batch(textarea.Blink, safeCmd(m.initClient), safeCmd(m.loadGeminiMdFiles), safeCmd(m.connectMCP))
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := m.update(msg)
+	if m, ok := next.(model); ok && m.inline {
+		return m.printNew(cmd)
+	}
+	return next, cmd
+}
+
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var (
 		tiCmd tea.Cmd
 		vpCmd tea.Cmd
 	)
 
+	if key, ok := msg.(tea.KeyMsg); ok {
+		if sm, handled := m.handleShellKey(key); handled {
+			return sm, nil
+		}
+		if isMultiLinePaste(key) {
+			return m.handlePaste(key), nil
+		}
+		if cm, cmd, handled := m.handleConfirmKey(key); handled {
+			return cm, cmd
+		}
+		if pm, cmd, handled := m.handlePromptArgumentKey(key); handled {
+			return pm, cmd
+		}
+		if vm, cmd, handled := m.handleVariantKey(key); handled {
+			return vm, cmd
+		}
+		if sm, handled := m.handleSettingsKey(key); handled {
+			return sm, nil
+		}
+		if bm, handled := m.handleBlockKey(key); handled {
+			return bm, nil
+		}
+		if fm, handled := m.handleFindKey(key); handled {
+			return fm, nil
+		}
+		if cm, handled := m.handleCompletionKey(key); handled {
+			return cm, nil
+		}
+	}
+
 	m.textarea, tiCmd = m.textarea.Update(msg)
 	m.viewport, vpCmd = m.viewport.Update(msg)
+	if _, ok := msg.(tea.KeyMsg); ok {
+		m.completion = m.suggest(m.textarea.Value())
+	}
 
 	switch msg := msg.(type) {
+	case interruptMsg:
+		return m.interrupt()
 	case tea.KeyMsg:
 		switch msg.Type {
-		case tea.KeyCtrlC, tea.KeyEsc:
+		case tea.KeyCtrlC:
+			return m.interrupt()
+		case tea.KeyEsc:
 			return m, tea.Quit
+		case tea.KeyCtrlO:
+			m.pastesExpanded = !m.pastesExpanded
+			return m, nil
+		case tea.KeyCtrlB:
+			return m.startBlockSelection(), nil
+		case tea.KeyCtrlX:
+			return m.editPrompt()
+		case tea.KeyCtrlT:
+			return m.toggleTokens()
+		case tea.KeyPgUp:
+			// Clamp to the top so PgDn responds immediately afterwards.
+			_, m.scrollOffset = m.convo.visible(m.viewport.Width, m.viewport.Height, m.scrollOffset+max(m.viewport.Height/2, 1), m.styles)
+			return m, nil
+		case tea.KeyPgDown:
+			m.scrollOffset = max(m.scrollOffset-max(m.viewport.Height/2, 1), 0)
+			return m, nil
 		case tea.KeyEnter:
-			userInput := m.textarea.Value()
+			userInput := m.expandPastes(m.textarea.Value())
 			if userInput == "" {
 				return m, nil
 			}
+			m.pastes = nil
+			m.pastesExpanded = false
+			m.completion = nil
 			if strings.HasPrefix(userInput, "/") {
-				return m.handleCommand(userInput), nil
+				return m.handleCommand(userInput)
 			}
-			if strings.HasPrefix(userInput, "@") {
-				if !m.inConversation {
-					m.inConversation = true
-				}
-				m.convo = append(m.convo, "Adding files via @ is not yet implemented.")
-				m.textarea.Reset()
-				return m, nil
-			}
-
-			if !m.inConversation {
-				m.inConversation = true
-			}
-
-			m.convo = append(m.convo, m.senderStyle.Render("You: ")+userInput)
 			m.textarea.Reset()
-			return m, m.send(userInput)
+			return m.submit(userInput, userInput)
 		}
 	case tea.WindowSizeMsg:
 		m.viewport.Width = msg.Width
 		m.viewport.Height = msg.Height - m.textarea.Height() - 2
 		m.textarea.SetWidth(msg.Width)
+		if m.shell != nil {
+			m.shell.Resize(tools.ShellRows, max(msg.Width, 1))
+		}
 		if m.updateInfo != nil {
 			m.viewport.Height--
 		}
 		return m, nil
 	case responseMsg:
-		m.convo = append(m.convo, m.responseStyle.Render("Gemini: ")+string(msg))
+		m.cancelRequest = nil
+		m.applyChat(msg.history, msg.tools)
+		m.stats.tokens += msg.tokens
+		for _, a := range msg.activity {
+			m.convo.addActivity(a)
+		}
+		m.convo.add(geminiEntry, msg.text)
+		m, play := m.showAudio(msg.audio)
+		m.scrollOffset = 0
+		m.session.Record(session.GeminiMessage, msg.text)
+		return m, tea.Batch(safeCmd(m.countTokens()), play, renderImages(msg.images, m.inlineImages(), m.viewport.Width))
+	case imagesMsg:
+		for _, img := range msg {
+			m.convo.add(imageEntry, img)
+		}
+		m.scrollOffset = 0
+		return m, nil
+	case modelSwitchedMsg:
+		return m.applyModelSwitch(msg)
+	case variantsMsg:
+		return m.showVariants(msg), nil
+	case interruptedMsg:
+		return m.showInterrupted(msg)
+	case indexFailedMsg:
+		return m.showIndexFailed(msg), nil
+	case mcpConnectedMsg:
+		return m.showMCPConnected(msg), nil
+	case mcpAuthURLMsg:
+		m.convo.add(infoEntry, i18n.T("MCP server %s requires authorization. Opening your browser; if it does not open, visit:\n%s", msg.server, msg.url))
+		return m, nil
+	case mcpAuthorizedMsg:
+		return m.showMCPAuthorized(msg), nil
+	case playedMsg:
+		if msg.err != nil {
+			m.convo.add(errorEntry, i18n.T("Could not play the audio: %v", msg.err))
+		}
+		return m, nil
+	case todosMsg:
+		m.todos = msg
+		return m, nil
+	case shellStartedMsg:
+		return m.showShell(msg), nil
+	case shellOutputMsg:
 		return m, nil
+	case shellExitedMsg:
+		return m.endShell(msg), nil
+	case toolConfirmMsg:
+		return m.confirmToolCall(msg), nil
+	case contextUsageMsg:
+		m.usage = contextUsage(msg)
+		return m, nil
+	case entryTokensMsg:
+		return m.applyEntryTokens(msg), nil
+	case editedMsg:
+		return m.finishEdit(msg)
+	case customPromptMsg:
+		m.cancelRequest = nil
+		return m.submit(msg.display, msg.prompt)
+	case compressedMsg:
+		m = m.applyCompression(msg)
+		return m, safeCmd(m.countTokens())
 	case UpdateAvailableMsg:
 		m.updateInfo = msg
 		m.viewport.Height--
 		return m, nil
-	case errMsg:
-		m.err = msg
-		m.convo = append(m.convo, m.errorStyle.Render("Error: "+msg.Error()))
+	case failedMsg:
+		m.applyChat(msg.history, msg.tools)
+		m.stats.tokens += msg.tokens
+		return m.showError(msg.err), nil
+	case filesChangedMsg:
+		return m.addChangedFiles(msg), nil
+	case initDoneMsg:
+		return m.applyInit(msg), nil
+	case geminiMdFilesMsg:
+		m.geminiMdFileCount = int(msg)
 		return m, nil
+	case errMsg:
+		return m.showError(msg), nil
 	default:
 		return m, nil
 	}
@@ -151,34 +532,99 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(tiCmd, vpCmd)
 }
 
+// showError shows err, which ended a request.
+func (m model) showError(err error) model {
+	m.cancelRequest = nil
+	if errors.Is(err, context.Canceled) {
+		// The user already saw the cancellation notice.
+		return m
+	}
+	m.err = err
+	m.convo.add(errorEntry, "Error: "+err.Error())
+	m.session.Record(session.ErrorMessage, err.Error())
+	return m
+}
+
+// applyChat takes on the chat history and tools a request ended with.
+func (m *model) applyChat(history []*genai.Content, tools []*genai.Tool) {
+	if m.chat == nil {
+		return
+	}
+	m.chat.History = history
+	m.client.Tools = tools
+}
+
 func (m model) View() string {
-	if m.err != nil {
-		m.viewport.SetContent(strings.Join(m.convo, "\n"))
+	if m.err != nil && !m.inline {
+		content, _ := m.convo.visible(m.viewport.Width, m.viewport.Height, m.scrollOffset, m.styles)
+		m.viewport.SetContent(content)
 		return m.viewport.View()
 	}
 
+	// The completion popup takes its rows from the conversation.
+	var popup string
+	if m.completion != nil {
+		popup = m.renderCompletion()
+		m.viewport.Height = max(m.viewport.Height-strings.Count(popup, "\n")-1, 1)
+	}
+	// So does the plan while it has steps left.
+	var plan string
+	if m.planActive() {
+		plan = m.renderPlan()
+		m.viewport.Height = max(m.viewport.Height-strings.Count(plan, "\n")-1, 1)
+	}
+	// And the output of a command running in a terminal.
+	var shell string
+	if m.shell != nil {
+		shell = m.renderShell()
+		m.viewport.Height = max(m.viewport.Height-strings.Count(shell, "\n")-1, 1)
+	}
+
+	if m.settingsDialog != nil {
+		content, selected := m.renderSettings()
+		m.viewport.SetContent(content)
+		m.viewport.SetYOffset(max(selected-m.viewport.Height+1, 0))
+		return fmt.Sprintf("%s\n%s\n%s", m.viewport.View(), m.textarea.View(), m.renderFooter())
+	}
+
 	var viewContent string
 	if !m.inConversation {
 		viewContent = m.renderInitialContent(m.viewport.Width)
-	} else {
-		viewContent = strings.Join(m.convo, "\n")
+	} else if !m.inline {
+		viewContent, _ = m.convo.visible(m.viewport.Width, m.viewport.Height, m.scrollOffset, m.styles)
+	}
+	if m.pastesExpanded {
+		viewContent += "\n" + m.renderPastes()
 	}
 	m.viewport.SetContent(viewContent)
 	m.viewport.GotoBottom()
 
 	footer := m.renderFooter()
-	mainView := fmt.Sprintf(
-		"%s\n%s\n%s",
-		m.viewport.View(),
-		m.textarea.View(),
-		footer,
-	)
+	conversation := m.viewport.View()
+	if m.inline {
+		// The conversation is in the scrollback, above what is drawn.
+		conversation = strings.TrimPrefix(viewContent, "\n")
+	}
+	if plan != "" {
+		conversation += "\n" + plan
+	}
+	if shell != "" {
+		conversation += "\n" + shell
+	}
+	if popup != "" {
+		conversation += "\n" + popup
+	}
+	mainView := fmt.Sprintf("%s\n%s", m.textarea.View(), footer)
+	if conversation != "" {
+		mainView = conversation + "\n" + mainView
+	}
 
 	if m.updateInfo != nil {
-		updateMessage := fmt.Sprintf(
-			"Update available! %s -> %s. To update, run: go install github.com/google-gemini/gemini-cli-go@latest",
+		updateMessage := i18n.T(
+			"Update available! %s -> %s. To update, run: %s",
 			updatechecker.CurrentVersion,
 			m.updateInfo.Version,
+			"go install github.com/google-gemini/gemini-cli-go@latest",
 		)
 		mainView += "\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Render(updateMessage)
 	}
@@ -186,8 +632,24 @@ func (m model) View() string {
 	return mainView
 }
 
-func (m *model) initClient() tea.Msg {
+// initDoneMsg carries the client initClient created, with the settings it
+// read along the way.
+type initDoneMsg struct {
+	api           *genai.Client
+	model         *genai.GenerativeModel
+	modelName     string
+	sandboxActive bool
+	// credentialsLoaded is the notice shown above the logo, if any.
+	credentialsLoaded string
+}
+
+// initClient loads the settings, authenticates and creates the client. It
+// runs as a command, on a copy of the model, so it changes nothing itself:
+// Update takes the client on with applyInit.
+func (m model) initClient() tea.Msg {
+	endLoad := startup.Begin("load settings")
 	cfg, err := config.Load()
+	endLoad()
 	if err != nil {
 		return errMsg(fmt.Errorf("failed to load config: %w", err))
 	}
@@ -197,80 +659,354 @@ func (m *model) initClient() tea.Msg {
 		authType = cfg.Security.Auth.SelectedType
 	}
 
+	endAuth := startup.Begin("authenticate")
+	defer endAuth()
 	authenticator, hasCachedToken, err := auth.NewAuthenticator(authType)
 	if err != nil {
 		return errMsg(err)
 	}
 
+	done := initDoneMsg{modelName: m.modelName}
 	if hasCachedToken {
-		m.credentialsLoadedMsg = "Loaded cached credentials."
+		done.credentialsLoaded = "Loaded cached credentials."
 	}
 
 	token, err := authenticator.GetToken()
 	if err != nil {
 		return errMsg(fmt.Errorf("authentication failed: %w", err))
 	}
+	endAuth()
 
 	if cfg.Model != nil && cfg.Model.Name != "" {
-		m.modelName = cfg.Model.Name
+		done.modelName = cfg.Model.Name
 	}
 	if cfg.Tools != nil && cfg.Tools.Sandbox != nil {
 		if val, ok := cfg.Tools.Sandbox.(bool); ok {
-			m.sandboxActive = val
+			done.sandboxActive = val
 		} else if val, ok := cfg.Tools.Sandbox.(string); ok {
-			m.sandboxActive = val != ""
+			done.sandboxActive = val != ""
 		}
 	}
 
+	transport := m.apiLog.Transport(http.DefaultTransport)
+	// m.settings holds flags such as --thinking-budget over the settings.
+	budget, think, err := thinking.Budget(m.settings.Model)
+	if err != nil {
+		return errMsg(err)
+	}
+	if think {
+		transport = thinking.Transport(transport, budget)
+	}
+	transport = audio.Transport(transport)
+	generationSettings := generation.Settings(m.settings.Model)
+	if generationSettings.ResponsePrefix != "" {
+		transport = generation.Transport(transport)
+	}
+
 	ctx := context.Background()
-	client, err := genai.NewClient(ctx, option.WithAPIKey(token))
+	endClient := startup.Begin("create client")
+	client, err := genai.NewClient(ctx, option.WithAPIKey(token), auth.ClientOption(token, transport))
+	endClient()
 	if err != nil {
 		return errMsg(fmt.Errorf("failed to create client: %w", err))
 	}
 
-	m.client = client.GenerativeModel(m.modelName)
-	return nil
+	done.api = client
+	done.model = client.GenerativeModel(done.modelName)
+	generation.Apply(done.model, generationSettings)
+	return done
 }
 
-func (m *model) send(prompt string) tea.Cmd {
+// applyInit takes on the client of msg and starts the chat.
+func (m model) applyInit(msg initDoneMsg) model {
+	m.api = msg.api
+	m.client = msg.model
+	m.chat = msg.model.StartChat()
+	m.modelName = msg.modelName
+	m.sandboxActive = msg.sandboxActive
+	m.credentialsLoadedMsg = msg.credentialsLoaded
+	return m
+}
+
+// submit shows display as the user's message and sends prompt to the model.
+func (m model) submit(display, prompt string) (model, tea.Cmd) {
+	if !m.inConversation {
+		m.inConversation = true
+	}
+	m.convo.add(userEntry, display)
+	m.scrollOffset = 0
+	m.session.Record(session.UserMessage, prompt)
+	m.stats.turns++
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelRequest = cancel
+	cmd := m.send(ctx, prompt)
+	return m, safeCmd(cmd)
+}
+
+// interrupt handles Ctrl+C: it cancels the in-flight request if there is
+// one, and quits otherwise.
+func (m model) interrupt() (tea.Model, tea.Cmd) {
+	if m.cancelRequest == nil {
+		return m, tea.Quit
+	}
+	m.cancelRequest()
+	m.cancelRequest = nil
+	m.convo.add(errorEntry, i18n.T("Request cancelled. Press Ctrl+C again to quit."))
+	return m, nil
+}
+
+// send sends prompt, with the attachments waiting for it, the files it
+// names with @path and the MCP resources it names with @server:uri, and
+// answers the tool calls of the responses until the model is done.
+func (m *model) send(ctx context.Context, prompt string) tea.Cmd {
+	parts := append([]genai.Part{genai.Text(prompt)}, m.attachments...)
+	m.attachments = nil
+	paths, err := m.mentions(prompt)
+	if err != nil {
+		return func() tea.Msg { return errMsg(err) }
+	}
+	resources := m.resourceMentions(prompt)
+	if len(paths) == 0 && len(resources) == 0 {
+		return m.converse(ctx, parts, nil)
+	}
+	run := m.converser(ctx, nil)
+	// The method value copies the model, which Update goes on changing.
+	attach := m.attachFiles
+	registry := m.workspace.MCP
 	return func() tea.Msg {
-		if m.client == nil {
-			return errMsg(fmt.Errorf("client not initialized"))
+		// Reading, and perhaps uploading, the files may take a while.
+		files, err := attach(ctx, paths)
+		if err != nil {
+			return errMsg(err)
 		}
-
-		ctx := context.Background()
-		resp, err := m.client.GenerateContent(ctx, genai.Text(prompt))
+		contents, err := readResources(ctx, registry, resources)
 		if err != nil {
-			return errMsg(fmt.Errorf("failed to generate content: %w", err))
+			return errMsg(err)
 		}
+		return run(slices.Concat(parts, files, contents))
+	}
+}
+
+// pending is a response to a request already in the chat history, which
+// was sent items long when it was made.
+type pending struct {
+	resp *genai.GenerateContentResponse
+	sent int
+}
 
-		var responseText strings.Builder
-		for _, cand := range resp.Candidates {
-			for _, part := range cand.Content.Parts {
-				if txt, ok := part.(genai.Text); ok {
-					responseText.WriteString(string(txt))
+// converse sends parts and answers the tool calls of the responses until
+// the model is done. With first, the request was already sent and first
+// is its response, which is handled as if it had just come in.
+func (m model) converse(ctx context.Context, parts []genai.Part, first *pending) tea.Cmd {
+	run := m.converser(ctx, first)
+	return func() tea.Msg { return run(parts) }
+}
+
+// converser returns the function converse runs to send the parts given to
+// it. It works on copies of the model, the chat and the workspace taken
+// now, so that it shares nothing with Update and View while it runs, and
+// returns the chat history and tools they should take on with its message.
+func (m model) converser(ctx context.Context, first *pending) func(parts []genai.Part) tea.Msg {
+	if m.voice != "" {
+		ctx = audio.WithSpeech(ctx, m.voice)
+	}
+	if m.chat == nil {
+		return func([]genai.Part) tea.Msg { return errMsg(fmt.Errorf("client not initialized")) }
+	}
+	client := *m.client
+	chat := client.StartChat()
+	chat.History = slices.Clone(m.chat.History)
+	ws := *m.workspace
+	functions := capabilitiesOf(m.modelName).functions
+	maxTurns := m.maxTurns()
+	var prefix string
+	if first == nil {
+		// prefix is the response prefix while the prompt is unanswered.
+		prefix = m.responsePrefix()
+	}
+	return func(parts []genai.Part) tea.Msg {
+		var (
+			responseText strings.Builder
+			images       []genai.Blob
+			sounds       []genai.Blob
+			activity     []activityLine
+			tokens       int32
+		)
+		ws.Approved = func(title string) {
+			activity = append(activity, activityLine{text: i18n.T("Approved by the %s approval mode: %s", ws.Approval, title)})
+		}
+		// failed reports err with the turns that went through before it.
+		failed := func(err error) tea.Msg {
+			return failedMsg{err: err, history: chat.History, tools: client.Tools, tokens: tokens}
+		}
+		retries := 0
+		for turn := 1; ; turn++ {
+			if turn > maxTurns {
+				return failed(fmt.Errorf("max turns exceeded: %d", maxTurns))
+			}
+			// Tools can change during the conversation, such as when an
+			// MCP server announces new ones.
+			if ws.MCP != nil {
+				if err := ws.MCP.Settle(ctx); err != nil {
+					return failed(err)
 				}
 			}
+			if functions {
+				client.Tools = []*genai.Tool{ws.Tool()}
+			}
+			sent := len(chat.History)
+			var (
+				resp    *genai.GenerateContentResponse
+				partial string
+				err     error
+			)
+			if first != nil {
+				resp, sent, first = first.resp, first.sent, nil
+			} else if resp, partial, err = sendStreaming(generation.WithPrefix(ctx, prefix), chat, parts...); err != nil {
+				if partial == "" {
+					return failed(fmt.Errorf("failed to generate content: %w", err))
+				}
+				partial = prefix + partial
+				keepPartial(chat, partial)
+				responseText.WriteString(partial)
+				return interruptedMsg{responseMsg{text: responseText.String(), images: images, activity: activity, history: chat.History, tools: client.Tools, tokens: tokens}, err}
+			}
+
+			if resp.UsageMetadata != nil {
+				tokens += resp.UsageMetadata.TotalTokenCount
+			}
+
+			if prefix != "" && len(chat.History) > sent+1 {
+				generation.AddPrefix(chat.History[len(chat.History)-1], prefix)
+			}
+
+			problem, recovery := finish.Plan(resp, retries)
+			switch recovery {
+			case finish.Resend:
+				retries++
+				activity = append(activity, activityLine{text: finish.Report(problem, resp) + " Retrying."})
+				chat.History = chat.History[:sent]
+				defer finish.Adjust(&client, problem)()
+				continue
+			case finish.Continue:
+				retries++
+				activity = append(activity, activityLine{text: problem.String() + " Asking the model to continue."})
+			}
+
+			responseText.WriteString(prefix)
+			var calls []genai.FunctionCall
+			for _, cand := range resp.Candidates {
+				if cand.Content == nil {
+					continue
+				}
+				for _, part := range cand.Content.Parts {
+					switch v := part.(type) {
+					case genai.Text:
+						responseText.WriteString(string(v))
+					case genai.Blob:
+						if strings.HasPrefix(v.MIMEType, "image/") {
+							images = append(images, v)
+						} else if audio.IsAudio(v) {
+							sounds = append(sounds, v)
+						}
+					case genai.FunctionCall:
+						calls = append(calls, v)
+					}
+				}
+			}
+			prefix = ""
+			if recovery == finish.Continue {
+				parts = []genai.Part{genai.Text(finish.ContinuePrompt)}
+				continue
+			}
+			if len(calls) == 0 {
+				if problem != finish.None {
+					activity = append(activity, activityLine{text: finish.Report(problem, resp)})
+				}
+				break
+			}
+			parts = tools.ExecuteTurn(ctx, &ws, calls)
+			for i, fc := range calls {
+				call := &toolCall{name: fc.Name, failed: failedCall(parts[i])}
+				activity = append(activity, activityLine{text: i18n.T("Ran %s.", fc.Name), call: call})
+			}
 		}
 
-		return responseMsg(responseText.String())
+		return responseMsg{text: responseText.String(), images: images, audio: sounds, activity: activity, history: chat.History, tools: client.Tools, tokens: tokens}
+	}
+}
+
+// failedCall reports whether the response of a tool is an error.
+func failedCall(part genai.Part) bool {
+	resp, ok := part.(*genai.FunctionResponse)
+	return ok && resp.Response["error"] != nil
+}
+
+// responsePrefix is the text the responses to the user's prompts start
+// with, if any.
+func (m model) responsePrefix() string {
+	return generation.Settings(m.settings.Model).ResponsePrefix
+}
+
+// maxTurns is how many requests a prompt may take, answering the model's
+// tool calls in between.
+func (m model) maxTurns() int {
+	if m.settings.Model != nil && m.settings.Model.MaxSessionTurns > 0 {
+		return m.settings.Model.MaxSessionTurns
 	}
+	return 10
 }
 
-func (m model) handleCommand(input string) model {
-	switch input {
-	case "/quit":
+func (m model) handleCommand(input string) (model, tea.Cmd) {
+	name, args, _ := strings.Cut(input, " ")
+	switch name {
+	case "/find":
+		return m.startFind(args), nil
+	case "/mcp":
+		return m.mcpCommand(args)
+	case "/tools":
+		return m.toolsCommand(args), nil
+	case "/settings":
+		return m.openSettings(), nil
+	case "/restore":
+		return m.restoreCommand(args), nil
+	case "/insights":
+		return m.insightsCommand(), nil
+	case "/model":
+		return m.modelCommand(args)
+	case "/debug-api":
+		return m.debugAPICommand(args), nil
+	case "/compress":
+		m, cmd := m.compress()
+		return m, safeCmd(cmd)
+	case "/context":
+		return m.contextCommand()
+	case "/retry":
+		return m.retryCommand(args)
+	case "/speak":
+		return m.speakCommand(args), nil
+	case "/ps":
+		return m.psCommand(args), nil
+	case "/quit", "/exit":
+		return m.quitCommand()
 	case "/help":
 		if !m.inConversation {
 			m.inConversation = true
 		}
-		m.convo = append(m.convo, getHelpText())
+		m.convo.add(infoEntry, getHelpText()+m.customCommandsHelp()+m.mcpPromptsHelp())
 		m.textarea.Reset()
+	default:
+		if c := m.customCommand(name); c != nil {
+			return m.runCustomCommand(c, args)
+		}
+		if p, ok := m.mcpPrompt(name); ok {
+			return m.runMCPPrompt(p, args)
+		}
 	}
-	return m
+	return m, nil
 }
 
-func (m *model) renderInitialContent(width int) string {
+func (m model) renderInitialContent(width int) string {
 	var logo string
 	if width >= 100 {
 		logo = longAsciiLogo
@@ -280,14 +1016,17 @@ func (m *model) renderInitialContent(width int) string {
 		logo = tinyAsciiLogo
 	}
 
-	tips := "Tips for getting started:\n" +
-		"1. Ask questions, edit files, or run commands.\n" +
-		"2. Be specific for the best results.\n" +
-		"3. /help for more information."
+	tips := i18n.T("Tips for getting started:") + "\n" +
+		i18n.T("1. Ask questions, edit files, or run commands.") + "\n" +
+		i18n.T("2. Be specific for the best results.") + "\n" +
+		i18n.T("3. /help for more information.") + "\n"
+	if m.settings.UI != nil && m.settings.UI.HideTips {
+		tips = ""
+	}
 
-	geminiFiles := fmt.Sprintf("Using: %d GEMINI.md files", m.geminiMdFileCount)
+	geminiFiles := i18n.T("Using: %d GEMINI.md files", m.geminiMdFileCount)
 
-	return fmt.Sprintf("%s\n\n%s\n\n%s\n%s",
+	return fmt.Sprintf("%s\n\n%s\n\n%s%s",
 		m.credentialsLoadedMsg,
 		logo,
 		tips,
@@ -295,21 +1034,57 @@ func (m *model) renderInitialContent(width int) string {
 	)
 }
 
-func (m *model) renderFooter() string {
-	project := fmt.Sprintf("Project: %s", m.projectName)
-	sandbox := fmt.Sprintf("Sandbox: %s", tern(m.sandboxActive, "Active", "Inactive"))
-	modelInfo := fmt.Sprintf("Model: %s", m.modelName)
+func (m model) renderFooter() string {
+	switch {
+	case m.shellFocused:
+		return m.styles.highlight.Render(i18n.T("Typing into the command; Ctrl+F returns to the input"))
+	case m.confirm != nil:
+		return m.styles.highlight.Render(m.confirm.prompt)
+	case m.promptArgs != nil:
+		return m.styles.highlight.Render(m.promptArgumentStatus())
+	case m.variants != nil:
+		return m.styles.highlight.Render(m.variantStatus())
+	case m.blockSelection != nil:
+		return m.blockStatus()
+	case m.search.active():
+		return m.findStatus()
+	case m.settingsDialog != nil:
+		return m.settingsStatus()
+	}
 
-	return lipgloss.JoinHorizontal(lipgloss.Top,
-		project,
-		"  |  ",
-		sandbox,
-		"  |  ",
-		modelInfo,
-	)
+	var hide config.FooterSettings
+	if m.settings.UI != nil && m.settings.UI.Footer != nil {
+		hide = *m.settings.UI.Footer
+	}
+	var items []string
+	if !hide.HideCWD {
+		items = append(items, i18n.T("Project: %s", m.projectName))
+	}
+	if !hide.HideSandboxStatus {
+		items = append(items, i18n.T("Sandbox: %s", i18n.T(tern(m.sandboxActive, "Active", "Inactive"))))
+	}
+	if !hide.HideModelInfo {
+		items = append(items, i18n.T("Model: %s", m.modelName))
+	}
+	if gauge := m.contextGauge(); gauge != "" {
+		items = append(items, gauge)
+	}
+
+	var parts []string
+	for i, item := range items {
+		if i > 0 {
+			parts = append(parts, "  |  ")
+		}
+		parts = append(parts, item)
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, parts...)
 }
 
-func (m *model) loadGeminiMdFiles() tea.Msg {
+// geminiMdFilesMsg is the number of GEMINI.md files in the project.
+type geminiMdFilesMsg int
+
+// loadGeminiMdFiles counts the GEMINI.md files in the project.
+func (m model) loadGeminiMdFiles() tea.Msg {
 	var count int
 	err := filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -323,15 +1098,44 @@ func (m *model) loadGeminiMdFiles() tea.Msg {
 	if err != nil {
 		return errMsg(fmt.Errorf("error counting GEMINI.md files: %w", err))
 	}
-	m.geminiMdFileCount = count
-	return nil
+	return geminiMdFilesMsg(count)
+}
+
+// helpEntries are the commands and keys listed by /help.
+var helpEntries = []struct{ key, description string }{
+	{"/help", "Show this help message"},
+	{"/find", "Search the conversation (n/N to navigate)"},
+	{"/compress", "Replace the conversation history with a summary"},
+	{"/context", "Show the token counts of the largest messages"},
+	{"/retry [n]", "Regenerate the last response, or n of them to pick from"},
+	{"/mcp logs <name>", "Show the stderr output of an MCP server"},
+	{"/mcp resources [name]", "List the resources of MCP servers"},
+	{"/tools", "List the available tools (/tools desc <name> to describe one)"},
+	{"/settings", "Edit user and workspace settings"},
+	{"/restore", "List checkpoints (/restore <id> to undo file changes)"},
+	{"/insights", "Summarize tool usage and suggest configuration fixes"},
+	{"/model [name]", "Show the model or switch to another, keeping the conversation"},
+	{"/debug-api [on|off]", "Log the raw API traffic to a file"},
+	{"/speak [on|off|voice]", "Have the responses spoken (experimental)"},
+	{"/ps [output|kill <id>]", "List, inspect or stop the commands running in the background"},
+	{"/quit", "Exit the application"},
+	{"Ctrl+O", "Show or hide pasted text"},
+	{"Ctrl+B", "Select a code block to copy, save or apply"},
+	{"Ctrl+X", "Edit the input in your preferred editor"},
+	{"Ctrl+T", "Show or hide the token counts of messages"},
+	{"Ctrl+F", "Type into the shell command running in a terminal"},
+	{"PgUp/PgDn", "Scroll the conversation"},
+	{"Tab", "Complete commands, arguments and @paths"},
+	{"@<file>", "Add a file to the context"},
+	{"@<server>:<uri>", "Add an MCP resource to the context"},
 }
 
 func getHelpText() string {
-	return "Available Commands:\n" +
-		"  /help      Show this help message\n" +
-		"  /quit      Exit the application\n" +
-		"  @<file>   Add a file to the context"
+	lines := []string{i18n.T("Available Commands:")}
+	for _, e := range helpEntries {
+		lines = append(lines, fmt.Sprintf("  %-10s %s", e.key, i18n.T(e.description)))
+	}
+	return strings.Join(lines, "\n")
 }
 
 func tern(cond bool, a, b string) string {
@@ -341,8 +1145,20 @@ func tern(cond bool, a, b string) string {
 	return b
 }
 
+// Start runs the program and makes sure the terminal is restored however the
+// program exits.
 func Start(p *tea.Program) {
-	if _, err := p.Run(); err != nil {
+	term.attach(p)
+	defer term.detach()
+
+	stop := term.handleSignals(p)
+	defer stop()
+
+	final, err := p.Run()
+	if err != nil && !errors.Is(err, tea.ErrProgramKilled) {
 		log.Fatalf("Alas, there's been an error: %v", err)
 	}
-}
\ No newline at end of file
+	if m, ok := final.(model); ok && m.summary != "" {
+		fmt.Println(m.summary)
+	}
+}
@@ -1,12 +1,46 @@
 package tui
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/google-gemini/gemini-cli-go/pkg/audio"
+	"github.com/google-gemini/gemini-cli-go/pkg/auth"
+	"github.com/google-gemini/gemini-cli-go/pkg/commands"
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google-gemini/gemini-cli-go/pkg/mcp"
+	"github.com/google-gemini/gemini-cli-go/pkg/pty"
+	"github.com/google-gemini/gemini-cli-go/pkg/thinking"
+	"github.com/google-gemini/gemini-cli-go/pkg/tools"
+	"github.com/google/generative-ai-go/genai"
+	"github.com/muesli/termenv"
+	"google.golang.org/api/option"
 )
 
+// TestMain pins the UI language, which otherwise follows the environment.
+func TestMain(m *testing.M) {
+	os.Setenv("LC_ALL", "C")
+	os.Exit(m.Run())
+}
+
 // TestInitialView verifies the TUI starts with the correct initial state.
 func TestInitialView(t *testing.T) {
 	m := InitialModel()
@@ -21,6 +55,44 @@ func TestInitialView(t *testing.T) {
 	}
 }
 
+// TestInitDone verifies that Update takes on the client initClient
+// created, which the command itself cannot set on the model.
+func TestInitDone(t *testing.T) {
+	m := InitialModel()
+	next, _ := m.Update(initDoneMsg{
+		model:             &genai.GenerativeModel{},
+		modelName:         "gemini-2.5-flash",
+		sandboxActive:     true,
+		credentialsLoaded: "Loaded cached credentials.",
+	})
+	m = next.(model)
+	if m.client == nil || m.chat == nil {
+		t.Fatal("Expected the client and chat to be set")
+	}
+	if m.modelName != "gemini-2.5-flash" || !m.sandboxActive {
+		t.Errorf("Expected the settings initClient read, got %s with sandbox %v", m.modelName, m.sandboxActive)
+	}
+	if m.credentialsLoadedMsg != "Loaded cached credentials." {
+		t.Errorf("Expected the credentials notice, got %q", m.credentialsLoadedMsg)
+	}
+
+	next, _ = m.Update(geminiMdFilesMsg(3))
+	if view := next.View(); !strings.Contains(view, "Using: 3 GEMINI.md files") {
+		t.Errorf("Expected the GEMINI.md count, got %q", view)
+	}
+}
+
+// TestWithThinkingBudget ensures --thinking-budget takes precedence over
+// the reasoning effort of the settings initClient reads the budget from.
+func TestWithThinkingBudget(t *testing.T) {
+	m := InitialModel()
+	m.settings.Model = &config.ModelSettings{ReasoningEffort: "high"}
+	m = m.WithThinkingBudget(0)
+	if budget, ok, err := thinking.Budget(m.settings.Model); budget != 0 || !ok || err != nil {
+		t.Errorf("thinking.Budget() = %d, %v, %v; want 0 from the flag", budget, ok, err)
+	}
+}
+
 // TestUserInputAndDisplay tests that the view transitions correctly
 // from the initial screen to the conversation view.
 func TestUserInputAndDisplay(t *testing.T) {
@@ -66,4 +138,1542 @@ func TestQuitMessage(t *testing.T) {
 	if cmd == nil {
 		t.Errorf("Expected a quit command, but got nil")
 	}
-}
\ No newline at end of file
+}
+
+// TestQuitCommand verifies that /quit offers to save an unsaved session,
+// and quits with a summary of it, which is also logged.
+func TestQuitCommand(t *testing.T) {
+	home := t.TempDir()
+	defer config.SetUserHomeDirForTesting(home, nil)()
+
+	m := InitialModel()
+	m.workspace.Roots = []string{t.TempDir()}
+	m, _ = m.submit("Hello", "Hello")
+	next, _ := m.Update(responseMsg{text: "Hi", tokens: 2500, activity: []activityLine{
+		{text: "Ran read_file.", call: &toolCall{name: "read_file"}},
+		{text: "Ran write_file.", call: &toolCall{name: "write_file"}},
+		{text: "Ran read_file.", call: &toolCall{name: "read_file", failed: true}},
+	}})
+	next, _ = next.Update(filesChangedMsg{"main.go", "go.mod"})
+	next, _ = next.Update(filesChangedMsg{"main.go"})
+	m = next.(model)
+
+	m.textarea.SetValue("/quit")
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(model)
+	if cmd != nil || m.confirm == nil {
+		t.Fatal("Expected /quit to offer to save the session first")
+	}
+	next, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	m = next.(model)
+	if cmd == nil {
+		t.Fatal("Expected /quit to quit")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Error("Expected a tea.QuitMsg")
+	}
+	for _, want := range []string{"Turns:         1", "Tokens:        2k", "Files changed: main.go, go.mod", "Tools used:    read_file (2, 1 failed), write_file (1)", "The session was saved to"} {
+		if !strings.Contains(m.summary, want) {
+			t.Errorf("Expected %q in the summary, got:\n%s", want, m.summary)
+		}
+	}
+	if m.session.Unsaved() {
+		t.Error("Expected the session to be saved")
+	}
+	data, err := os.ReadFile(filepath.Join(home, ".gemini", "logs", summaryLogName))
+	if err != nil || !strings.Contains(string(data), m.summary) {
+		t.Errorf("Expected the summary to be logged, got %q, %v", data, err)
+	}
+
+	// Without unsaved messages, /quit quits at once.
+	m = InitialModel()
+	m, cmd = m.handleCommand("/quit")
+	if cmd == nil || m.confirm != nil || strings.Contains(m.summary, "session was") {
+		t.Errorf("Expected an empty session to quit at once, got summary:\n%s", m.summary)
+	}
+}
+
+// TestCtrlCCancelsInFlightRequest ensures the first Ctrl+C cancels a running
+// request and only the second one quits.
+func TestCtrlCCancelsInFlightRequest(t *testing.T) {
+	m := InitialModel()
+	cancelled := false
+	m.cancelRequest = func() { cancelled = true }
+
+	newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
+	m = newModel.(model)
+	if !cancelled {
+		t.Fatal("Expected the in-flight request to be cancelled")
+	}
+	if cmd != nil {
+		t.Fatal("Expected no quit command on the first Ctrl+C")
+	}
+	if m.cancelRequest != nil {
+		t.Error("Expected cancelRequest to be cleared")
+	}
+
+	_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
+	if cmd == nil {
+		t.Fatal("Expected a quit command on the second Ctrl+C")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Errorf("Expected a tea.QuitMsg")
+	}
+}
+
+// TestInterruptSignalMatchesCtrlC ensures SIGINT delivered as interruptMsg
+// behaves like Ctrl+C.
+func TestInterruptSignalMatchesCtrlC(t *testing.T) {
+	m := InitialModel()
+	_, cmd := m.Update(interruptMsg{})
+	if cmd == nil {
+		t.Fatal("Expected a quit command")
+	}
+}
+
+// TestMultiLinePaste ensures a multi-line paste is kept as a single input
+// block behind a placeholder and expanded on submit.
+func TestMultiLinePaste(t *testing.T) {
+	m := InitialModel()
+	pasted := "line one\r\nline two\r\nline three"
+
+	newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(pasted), Paste: true})
+	m = newModel.(model)
+
+	if got := m.textarea.Value(); got != "[Pasted text #1: 3 lines]" {
+		t.Fatalf("Expected paste placeholder in textarea, got %q", got)
+	}
+	if m.inConversation {
+		t.Fatal("Paste should not submit the input")
+	}
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newModel.(model)
+
+	if len(m.convo.entries) != 1 || m.convo.entries[0].text != "line one\nline two\nline three" {
+		t.Errorf("Expected the pasted text to be submitted as one message")
+	}
+	if len(m.pastes) != 0 {
+		t.Errorf("Expected pastes to be cleared after submit")
+	}
+}
+
+// TestRenderWrapsLongTokens ensures long tokens such as URLs are broken to fit
+// the viewport and that renders are cached per width.
+func TestRenderWrapsLongTokens(t *testing.T) {
+	c := newConversation()
+	url := "https://example.com/" + strings.Repeat("a", 80)
+	c.add(infoEntry, url)
+
+	out, _ := c.visible(40, 10, 0, styles{})
+	for _, line := range strings.Split(out, "\n") {
+		if len(line) > 40 {
+			t.Errorf("Line exceeds width: %q", line)
+		}
+	}
+	if strings.ReplaceAll(out, "\n", "") != url {
+		t.Errorf("Wrapping changed the content: %q", out)
+	}
+
+	e := c.entries[0]
+	e.text = "changed"
+	if got, _ := c.visible(40, 10, 0, styles{}); got != out {
+		t.Errorf("Expected cached render at the same width")
+	}
+	if got, _ := c.visible(60, 10, 0, styles{}); got != "changed" {
+		t.Errorf("Expected re-render at a new width, got %q", got)
+	}
+}
+
+// TestRenderCodeBlocks ensures code blocks get a numbered header, line
+// numbers and lines padded to the same width.
+func TestRenderCodeBlocks(t *testing.T) {
+	c := newConversation()
+	c.add(geminiEntry, "Here:\n```go\nfunc main() {\n}\n```")
+
+	out, _ := c.visible(80, 10, 0, styles{})
+	lines := strings.Split(out, "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected 4 lines, got %d: %q", len(lines), lines)
+	}
+	if lines[1] != "[1] go" {
+		t.Errorf("Unexpected block header %q", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], "1 │ func main() {") || !strings.HasPrefix(lines[3], "2 │ }") {
+		t.Errorf("Expected numbered code lines, got %q", lines[2:])
+	}
+	if len(lines[2]) != len(lines[3]) {
+		t.Errorf("Expected code lines to be padded to the same width: %q", lines[2:])
+	}
+}
+
+// TestConversationSpills ensures old and large entries leave memory for the
+// spool once they are off screen, and are read back when they scroll into
+// view or are searched.
+func TestConversationSpills(t *testing.T) {
+	c := newConversation()
+	defer c.spool.close()
+	c.maxResident = 1000
+	large := strings.Repeat(strings.Repeat("x", 39)+"\n", largeEntryBytes/40+1)
+	c.add(infoEntry, large)
+	for i := range 100 {
+		c.add(infoEntry, fmt.Sprintf("entry %d %s", i, strings.Repeat("y", 50)))
+	}
+	c.visible(80, 5, 0, styles{})
+
+	if e := c.entries[0]; !e.spilled || e.text != "" || e.rendered != "" {
+		t.Errorf("Expected the large entry to spill, got spilled = %v with %d bytes resident", e.spilled, e.residentSize())
+	}
+	resident := 0
+	for _, e := range c.entries {
+		resident += e.residentSize()
+	}
+	if resident > c.maxResident+residentEntries*100 {
+		t.Errorf("Expected at most about %d resident bytes, got %d", c.maxResident, resident)
+	}
+	if e := c.entries[len(c.entries)-1]; e.spilled {
+		t.Error("Expected the latest entry to stay in memory")
+	}
+
+	if got := c.entries[1].content(); !strings.HasPrefix(got, "entry 0 ") {
+		t.Errorf("content() of a spilled entry = %q", got)
+	}
+	// Scrolling to the top reads the first entries back.
+	out, _ := c.visible(80, 5, 1<<20, styles{})
+	if !strings.HasPrefix(out, "xxxx") {
+		t.Errorf("Expected the large entry at the top, got %q", out)
+	}
+}
+
+// TestParseCodeBlocks ensures languages and filenames are picked up from the
+// fence info string or a leading comment.
+func TestParseCodeBlocks(t *testing.T) {
+	blocks := parseCodeBlocks("```python title=tools/run.py\nprint(1)\n```\ntext\n```go\n// cmd/main.go\npackage main\n```\n```\nplain")
+	if len(blocks) != 3 {
+		t.Fatalf("Expected 3 blocks, got %d", len(blocks))
+	}
+	if blocks[0].lang != "python" || blocks[0].filename != "tools/run.py" || blocks[0].code != "print(1)" {
+		t.Errorf("Unexpected first block %+v", blocks[0])
+	}
+	if blocks[1].filename != "cmd/main.go" {
+		t.Errorf("Expected filename from leading comment, got %q", blocks[1].filename)
+	}
+	blocks[2].index = 3
+	if blocks[2].code != "plain" || !strings.HasPrefix(blocks[2].suggestedName(), "snippet-3.") {
+		t.Errorf("Unexpected unterminated block %+v (%s)", blocks[2], blocks[2].suggestedName())
+	}
+}
+
+// TestCodeBlockSaveAndApply ensures s saves the selected block and a writes
+// it to the workspace only after confirmation.
+func TestCodeBlockSaveAndApply(t *testing.T) {
+	dir := t.TempDir()
+	originalWd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Failed to change dir: %v", err)
+	}
+	defer os.Chdir(originalWd)
+
+	m := InitialModel()
+	m.convo.add(geminiEntry, "```go\n// pkg/hello.go\npackage hello\n```")
+
+	newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlB})
+	m = newModel.(model)
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	m = newModel.(model)
+	if data, err := os.ReadFile(filepath.Join(dir, "hello.go")); err != nil || !strings.Contains(string(data), "package hello") {
+		t.Fatalf("Expected block to be saved to hello.go: %v", err)
+	}
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlB})
+	m = newModel.(model)
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	m = newModel.(model)
+	if m.confirm == nil {
+		t.Fatal("Expected a confirmation before applying the block")
+	}
+	target := filepath.Join(dir, "pkg", "hello.go")
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Fatal("Expected nothing to be written before confirmation")
+	}
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	m = newModel.(model)
+	if _, err := os.Stat(target); err != nil {
+		t.Errorf("Expected block to be applied to %s: %v", target, err)
+	}
+}
+
+// TestVisibleRendersOnlyWindow ensures only entries in view are rendered and
+// that scrolling is clamped to the top of the conversation.
+func TestVisibleRendersOnlyWindow(t *testing.T) {
+	c := newConversation()
+	for i := 0; i < 1000; i++ {
+		c.add(infoEntry, fmt.Sprintf("message %d", i))
+	}
+
+	out, offset := c.visible(80, 3, 0, styles{})
+	if out != "message 997\nmessage 998\nmessage 999" || offset != 0 {
+		t.Errorf("Unexpected window %q (offset %d)", out, offset)
+	}
+	if c.entries[0].rendered != "" {
+		t.Errorf("Expected entries outside the window not to be rendered")
+	}
+
+	out, _ = c.visible(80, 3, 2, styles{})
+	if out != "message 995\nmessage 996\nmessage 997" {
+		t.Errorf("Unexpected scrolled window %q", out)
+	}
+
+	out, offset = c.visible(80, 3, 5000, styles{})
+	if out != "message 0\nmessage 1\nmessage 2" || offset != 997 {
+		t.Errorf("Expected offset to clamp at the top, got %q (offset %d)", out, offset)
+	}
+}
+
+// TestRenderImage ensures images are drawn inline only when enabled and
+// supported, and are otherwise saved to a temp file.
+func TestRenderImage(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 7))); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+	blob := genai.Blob{MIMEType: "image/png", Data: buf.Bytes()}
+
+	env := map[string]string{}
+	originalGetenv := getenv
+	getenv = func(key string) string { return env[key] }
+	defer func() { getenv = originalGetenv }()
+
+	out := renderImage(blob, true, 80)
+	if !strings.HasPrefix(out, "Image saved to ") {
+		t.Fatalf("Expected a temp file fallback without terminal support, got %q", out)
+	}
+	path := strings.TrimPrefix(out, "Image saved to ")
+	defer os.Remove(path)
+	if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, blob.Data) {
+		t.Errorf("Expected the image to be saved to %s", path)
+	}
+
+	env["TERM"] = "xterm-kitty"
+	if out := renderImage(blob, true, 80); !strings.HasPrefix(out, "\x1b_Gf=100,a=T") {
+		t.Errorf("Expected a kitty graphics sequence, got %q", out)
+	}
+
+	env["TERM"] = "foot"
+	if out := renderImage(blob, true, 80); !strings.HasPrefix(out, "\x1bPq\"1;1;4;7") {
+		t.Errorf("Expected a sixel sequence, got %q", out)
+	}
+
+	// Sixel images are scaled to fit the rows reserved for them and the
+	// width of the conversation.
+	buf.Reset()
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2000, 1000))); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+	large := genai.Blob{MIMEType: "image/png", Data: buf.Bytes()}
+	msg, ok := renderImages([]genai.Blob{large}, true, 60)().(imagesMsg)
+	if !ok || len(msg) != 1 || !strings.HasPrefix(msg[0], "\x1bPq\"1;1;480;240") {
+		t.Errorf("Expected the large image scaled to 480x240 sixels, got %.20q", msg)
+	}
+
+	out = renderImage(blob, false, 80)
+	if !strings.HasPrefix(out, "Image saved to ") {
+		t.Errorf("Expected inline images to be disabled, got %q", out)
+	}
+	os.Remove(strings.TrimPrefix(out, "Image saved to "))
+}
+
+// TestFind ensures /find jumps between matching entries with n/N and that
+// Esc closes the search instead of quitting.
+func TestFind(t *testing.T) {
+	m := InitialModel()
+	m.viewport.Height = 2
+	for i := 0; i < 20; i++ {
+		text := fmt.Sprintf("filler %d", i)
+		if i == 3 || i == 12 {
+			text = fmt.Sprintf("the Needle %d", i)
+		}
+		m.convo.add(infoEntry, text)
+	}
+
+	m.textarea.SetValue("/find needle")
+	newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newModel.(model)
+
+	if !m.search.active() || len(m.search.matches) != 2 {
+		t.Fatalf("Expected two matches, got %+v", m.search)
+	}
+	if !strings.Contains(m.View(), "the Needle 12") {
+		t.Errorf("Expected the view to show the most recent match")
+	}
+	if !strings.Contains(m.View(), "Match 2 of 2") {
+		t.Errorf("Expected the footer to show the match position")
+	}
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("N")})
+	m = newModel.(model)
+	if !strings.Contains(m.View(), "the Needle 3") {
+		t.Errorf("Expected N to jump to the previous match")
+	}
+	if m.textarea.Value() != "" {
+		t.Errorf("Expected navigation keys not to be typed into the input")
+	}
+
+	newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = newModel.(model)
+	if cmd != nil || m.search.active() {
+		t.Errorf("Expected Esc to close the search without quitting")
+	}
+}
+
+// TestContextGauge verifies the footer gauge and that /compress replaces the
+// chat history with the summary.
+func TestContextGauge(t *testing.T) {
+	m := InitialModel()
+	if strings.Contains(m.renderFooter(), "context:") {
+		t.Error("footer should not show a gauge before the first estimate")
+	}
+
+	newModel, _ := m.Update(contextUsageMsg{used: 41_500, limit: 128_000})
+	m = newModel.(model)
+	footer := m.renderFooter()
+	if !strings.Contains(footer, "context: 32% used (41k/128k tokens)") {
+		t.Errorf("footer = %q, want the context gauge", footer)
+	}
+	if strings.Contains(footer, "/compress") {
+		t.Error("footer should not suggest /compress at low usage")
+	}
+
+	newModel, _ = m.Update(contextUsageMsg{used: 900_000, limit: 1_048_576})
+	m = newModel.(model)
+	if footer := m.renderFooter(); !strings.Contains(footer, "85% used (900k/1.0M tokens) - run /compress") {
+		t.Errorf("footer = %q, want a hint to run /compress", footer)
+	}
+
+	m.chat = (&genai.GenerativeModel{}).StartChat()
+	m.chat.History = []*genai.Content{
+		{Role: "user", Parts: []genai.Part{genai.Text("first question")}},
+		{Role: "model", Parts: []genai.Part{genai.Text("first answer")}},
+		{Role: "user", Parts: []genai.Part{genai.Text("second question")}},
+	}
+	newModel, _ = m.Update(compressedMsg{summary: "We discussed two questions."})
+	m = newModel.(model)
+	if len(m.chat.History) != 2 {
+		t.Fatalf("history has %d entries after compression, want 2", len(m.chat.History))
+	}
+	if text := fmt.Sprint(m.chat.History[0].Parts[0]); !strings.Contains(text, "We discussed two questions.") {
+		t.Errorf("compressed history starts with %q, want the summary", text)
+	}
+}
+
+// TestMCPPrompt verifies the arguments of the slash commands of MCP
+// prompts, and that required arguments left out are asked for.
+func TestMCPPrompt(t *testing.T) {
+	p := mcp.RegisteredPrompt{Server: "github", Prompt: mcp.Prompt{Name: "review", Arguments: []mcp.PromptArgument{
+		{Name: "pr", Required: true},
+		{Name: "focus", Description: "What to look at"},
+	}}}
+	for _, tt := range []struct {
+		args, want string
+	}{
+		{"", ""},
+		{"7", "pr=7"},
+		{"7 error handling", "focus=error handling pr=7"},
+		{`--focus="error handling" 7`, "focus=error handling pr=7"},
+		{`"--focus=x" 7`, "focus=7 pr=--focus=x"},
+		{"--pr=7 --focus=tests", "focus=tests pr=7"},
+		{"--author=me", "error"},
+		{"--pr=7 --focus=tests extra", "error"},
+		{`"7`, "error"},
+	} {
+		values, err := parsePromptArgs(p.Prompt, tt.args)
+		got := "error"
+		if err == nil {
+			var pairs []string
+			for _, k := range slices.Sorted(maps.Keys(values)) {
+				pairs = append(pairs, k+"="+values[k])
+			}
+			got = strings.Join(pairs, " ")
+		}
+		if got != tt.want {
+			t.Errorf("parsePromptArgs(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+
+	m := InitialModel()
+	m, cmd := m.runMCPPrompt(p, "--focus=tests")
+	if cmd != nil || m.promptArgs == nil {
+		t.Fatal("Expected the required argument to be asked for")
+	}
+	if status := m.renderFooter(); !strings.Contains(status, "Enter pr for /github:review") {
+		t.Errorf("Expected the footer to ask for pr, got %q", status)
+	}
+	m.textarea.SetValue("7")
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(model)
+	if cmd == nil || m.promptArgs != nil || m.cancelRequest == nil {
+		t.Error("Expected the prompt to be requested once its arguments are complete")
+	}
+
+	text, err := promptText(&mcp.GetPromptResult{Messages: []mcp.PromptMessage{
+		{Role: "user", Content: mcp.Content{Type: "text", Text: "Review PR 7."}},
+		{Role: "user", Content: mcp.Content{Type: "image", Data: "AA=="}},
+		{Role: "user", Content: mcp.Content{Type: "resource", Resource: json.RawMessage(`{"uri":"file:///diff","text":"+ a line"}`)}},
+	}})
+	if err != nil || text != "Review PR 7.\n\n+ a line" {
+		t.Errorf("promptText() = %q, %v; want the text and the resource", text, err)
+	}
+}
+
+// TestCustomCommandShellConfirmation verifies that !{...} blocks in custom
+// commands only run after confirmation and that their output is sent.
+func TestCustomCommandShellConfirmation(t *testing.T) {
+	m := InitialModel()
+	m.commands = []*commands.Command{{Name: "greet", Prompt: "Say hi to !{echo {{args}}}"}}
+
+	m.textarea.SetValue("/greet world")
+	newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newModel.(model)
+	if m.confirm == nil || cmd != nil {
+		t.Fatal("Expected a confirmation before running the shell block")
+	}
+
+	newModel, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	m = newModel.(model)
+	if cmd == nil {
+		t.Fatal("Expected the shell block to run after confirmation")
+	}
+	msg, ok := cmd().(customPromptMsg)
+	if !ok || msg.prompt != "Say hi to world" || msg.display != "/greet world" {
+		t.Fatalf("Unexpected expansion %+v", msg)
+	}
+
+	newModel, _ = m.Update(msg)
+	m = newModel.(model)
+	last := m.convo.entries[len(m.convo.entries)-1]
+	if last.kind != userEntry || last.text != "/greet world" {
+		t.Errorf("Expected the command to be shown as the user message, got %q", last.text)
+	}
+}
+
+func typeText(m model, text string) model {
+	for _, r := range text {
+		newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		m = newModel.(model)
+	}
+	return m
+}
+
+// TestCompletion covers command name, argument and @path completion.
+func TestCompletion(t *testing.T) {
+	dir := t.TempDir()
+	originalWd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Failed to change dir: %v", err)
+	}
+	defer os.Chdir(originalWd)
+	os.MkdirAll(filepath.Join(dir, "pkg", "tui"), 0755)
+	os.WriteFile(filepath.Join(dir, "pkg", "tui", "view.go"), nil, 0644)
+
+	m := InitialModel()
+	m.settings.MCPServers = map[string]config.MCPServer{"github": {}, "linear": {}}
+	tab := tea.KeyMsg{Type: tea.KeyTab}
+
+	m = typeText(m, "/mc")
+	if m.completion == nil || m.completion.items[0].value != "/mcp" {
+		t.Fatalf("Expected /mcp to be suggested, got %+v", m.completion)
+	}
+	if !strings.Contains(m.View(), "Inspect MCP servers") {
+		t.Error("Expected the popup to be rendered")
+	}
+	newModel, _ := m.Update(tab)
+	m = newModel.(model)
+	newModel, _ = m.Update(tab)
+	m = newModel.(model)
+	if got := m.textarea.Value(); got != "/mcp logs " {
+		t.Fatalf("After completing twice, input = %q", got)
+	}
+	if m.completion == nil || len(m.completion.items) != 2 {
+		t.Fatalf("Expected server names to be suggested, got %+v", m.completion)
+	}
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m = newModel.(model)
+	newModel, _ = m.Update(tab)
+	m = newModel.(model)
+	if got := m.textarea.Value(); got != "/mcp logs linear " {
+		t.Errorf("Expected the selected server to be completed, got %q", got)
+	}
+
+	m.textarea.Reset()
+	m = typeText(m, "explain @pk")
+	newModel, _ = m.Update(tab)
+	m = newModel.(model)
+	newModel, _ = m.Update(tab)
+	m = newModel.(model)
+	newModel, _ = m.Update(tab)
+	m = newModel.(model)
+	if got := m.textarea.Value(); got != "explain @pkg/tui/view.go " {
+		t.Errorf("Expected the path to be completed, got %q", got)
+	}
+
+	m = typeText(m, "@p")
+	if m.completion == nil {
+		t.Fatal("Expected a suggestion for @p")
+	}
+	newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if newModel.(model).completion != nil || cmd != nil {
+		t.Error("Expected Esc to close the popup without quitting")
+	}
+}
+
+// TestSettingsDialog verifies that /settings saves to the selected scope and
+// applies the change to the running session.
+func TestSettingsDialog(t *testing.T) {
+	home, dir := t.TempDir(), t.TempDir()
+	restore := config.SetUserHomeDirForTesting(home, nil)
+	defer restore()
+	originalWd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Failed to change dir: %v", err)
+	}
+	defer os.Chdir(originalWd)
+
+	press := func(m model, keys ...tea.KeyMsg) model {
+		for _, k := range keys {
+			newModel, _ := m.Update(k)
+			m = newModel.(model)
+		}
+		return m
+	}
+	down := tea.KeyMsg{Type: tea.KeyDown}
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+
+	m := InitialModel()
+	m.textarea.SetValue("/settings")
+	m = press(m, enter)
+	if m.settingsDialog == nil || !strings.Contains(m.View(), "ui.hideTips") {
+		t.Fatal("Expected /settings to open the dialog")
+	}
+
+	// Toggle ui.hideTips in the user scope.
+	m = press(m, down, down, enter)
+	if m.settings.UI == nil || !m.settings.UI.HideTips {
+		t.Error("Expected ui.hideTips to apply immediately")
+	}
+	if data, _ := os.ReadFile(filepath.Join(home, ".gemini", "settings.toml")); !strings.Contains(string(data), "HideTips = true") {
+		t.Errorf("Expected ui.hideTips in the user settings, got:\n%s", data)
+	}
+
+	// Edit model.maxSessionTurns in the workspace scope.
+	m = press(m, tea.KeyMsg{Type: tea.KeyTab}, down, down, down, down, down, down, down, enter)
+	m = typeText(m, "x")
+	m = press(m, enter)
+	if !m.settingsDialog.editing || !strings.Contains(m.settingsDialog.status, "whole number") {
+		t.Fatalf("Expected a non-numeric value to be rejected, status %q", m.settingsDialog.status)
+	}
+	m = press(m, tea.KeyMsg{Type: tea.KeyCtrlU})
+	m = typeText(m, "20")
+	m = press(m, enter)
+	if m.settings.Model == nil || m.settings.Model.MaxSessionTurns != 20 {
+		t.Errorf("Expected model.maxSessionTurns to be 20, got %+v", m.settings.Model)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".gemini", "settings.toml")); err != nil {
+		t.Errorf("Expected workspace settings to be written: %v", err)
+	}
+
+	m = press(m, tea.KeyMsg{Type: tea.KeyEsc})
+	if m.settingsDialog != nil {
+		t.Error("Expected Esc to close the dialog")
+	}
+}
+
+// TestModifyBeforeWrite verifies that a proposed file can be opened in the
+// preferred editor before it is written.
+func TestModifyBeforeWrite(t *testing.T) {
+	dir := t.TempDir()
+	originalWd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Failed to change dir: %v", err)
+	}
+	defer os.Chdir(originalWd)
+
+	m := InitialModel()
+	m.settings.General = &config.GeneralSettings{PreferredEditor: "true"}
+	m = m.confirmWriteFile("main.go", "package main")
+	if m.confirm == nil || !strings.Contains(m.confirm.prompt, "m to modify") {
+		t.Fatal("Expected the confirmation to offer modifying the file")
+	}
+	newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("m")})
+	m = newModel.(model)
+	if m.confirm != nil || cmd == nil {
+		t.Fatal("Expected m to open the editor")
+	}
+}
+
+// TestWriteFileThroughSymlink verifies that files are written only where
+// the file tools would write them, and that the confirmation names the
+// file a symbolic link leads to.
+func TestWriteFileThroughSymlink(t *testing.T) {
+	dir, outside := t.TempDir(), t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "out")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("src", filepath.Join(dir, "lib")); err != nil {
+		t.Fatal(err)
+	}
+	originalWd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Failed to change dir: %v", err)
+	}
+	defer os.Chdir(originalWd)
+
+	m := InitialModel()
+	m.workspace.Roots = []string{dir}
+	m = m.confirmWriteFile("out/main.go", "package main")
+	if m.confirm != nil {
+		t.Fatal("Expected no confirmation for a link leading out of the workspace")
+	}
+	if last := m.convo.entries[len(m.convo.entries)-1]; last.kind != errorEntry || !strings.Contains(last.text, "outside the workspace") {
+		t.Errorf("Expected an error about the workspace, got %+v", last)
+	}
+
+	m = m.confirmWriteFile("lib/main.go", "package main")
+	if m.confirm == nil || !strings.Contains(m.confirm.prompt, filepath.Join("src", "main.go")) {
+		t.Fatalf("Expected the confirmation to name the linked file, got %+v", m.confirm)
+	}
+	newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	m = newModel.(model)
+	if _, err := os.Stat(filepath.Join(dir, "src", "main.go")); err != nil {
+		t.Errorf("Expected src/main.go to be written: %v", err)
+	}
+}
+
+// TestRenderDiff verifies that changed words are highlighted within changed
+// lines and that whitespace-only changes are dimmed.
+func TestRenderDiff(t *testing.T) {
+	r := lipgloss.NewRenderer(io.Discard)
+	r.SetColorProfile(termenv.ANSI)
+	s := styles{
+		diffInsertWord: r.NewStyle().Underline(true),
+		diffDeleteWord: r.NewStyle().Reverse(true),
+		diffDim:        r.NewStyle().Faint(true),
+	}
+	hunks := "@@ -1,3 +1,3 @@\n" +
+		"-\treturn x + 1, nil\n" +
+		"-if  ok {\n" +
+		"+\treturn x + 2, err\n" +
+		"+if ok {\n" +
+		" }\n"
+	lines := strings.Split(renderDiff(hunks, 80, s), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("Expected 6 lines, got %q", lines)
+	}
+	want := "-    return x + " + s.diffDeleteWord.Render("1") + ", " + s.diffDeleteWord.Render("nil")
+	if lines[1] != want {
+		t.Errorf("Removed line = %q, want %q", lines[1], want)
+	}
+	want = "+    return x + " + s.diffInsertWord.Render("2") + ", " + s.diffInsertWord.Render("err")
+	if lines[3] != want {
+		t.Errorf("Added line = %q, want %q", lines[3], want)
+	}
+	if lines[2] != s.diffDim.Render("-if  ok {") || lines[4] != s.diffDim.Render("+if ok {") {
+		t.Errorf("Expected the whitespace-only change to be dimmed, got %q and %q", lines[2], lines[4])
+	}
+}
+
+// TestSendRunsToolCalls verifies that the model is told about the tools and
+// that its tool calls are answered before its response is shown.
+func TestSendRunsToolCalls(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("buy milk\n"), 0644)
+
+	var requests []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]any
+		json.NewDecoder(r.Body).Decode(&request)
+		requests = append(requests, request)
+		w.Header().Set("Content-Type", "application/json")
+		if len(requests) == 1 {
+			fmt.Fprintln(w, `[{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"read_file","args":{"path":"notes.txt"}}}]}}]}]`)
+			return
+		}
+		fmt.Fprintln(w, `[{"candidates":[{"content":{"role":"model","parts":[{"text":"Buy milk."}]}}]}]`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey("key"), option.WithEndpoint(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	m := InitialModel()
+	if m.workspace, err = tools.NewWorkspaceAt(dir, m.settings); err != nil {
+		t.Fatal(err)
+	}
+	m.client = client.GenerativeModel("gemini-pro")
+	m.chat = m.client.StartChat()
+
+	result := m.send(ctx, "What do my notes say?")()
+	msg, ok := result.(responseMsg)
+	if !ok {
+		t.Fatalf("send() = %v, want a response", result)
+	}
+	if msg.text != "Buy milk." || len(msg.activity) != 1 || msg.activity[0].text != "Ran read_file." {
+		t.Errorf("send() = %+v, want the answer after a read_file call", msg)
+	} else if call := msg.activity[0].call; call == nil || call.name != "read_file" || call.failed {
+		t.Errorf("activity call = %+v, want the successful read_file call", call)
+	}
+	if len(requests) != 2 || requests[0]["tools"] == nil {
+		t.Fatalf("Expected two requests declaring the tools, got %v", requests)
+	}
+	if !strings.Contains(fmt.Sprint(requests[1]["contents"]), "buy milk") {
+		t.Errorf("Expected the file content to be sent back, got %v", requests[1]["contents"])
+	}
+
+	// The request ran on copies, which Update takes the new state from, so
+	// that Update and View never race with it.
+	if len(m.chat.History) != 0 || m.client.Tools != nil || m.workspace.Approved != nil {
+		t.Errorf("Expected the model to be left alone until Update, got history %v", m.chat.History)
+	}
+	newModel, _ := m.Update(msg)
+	m = newModel.(model)
+	if len(m.chat.History) != 4 || m.client.Tools == nil {
+		t.Errorf("history = %v, want the prompt, the call, its result and the answer", m.chat.History)
+	}
+	var calls []*entry
+	for _, e := range m.convo.entries {
+		if e.time.IsZero() {
+			t.Errorf("Expected the entry %q to record when it was added", e.text)
+		}
+		if e.call != nil {
+			calls = append(calls, e)
+		}
+	}
+	if len(calls) != 1 || calls[0].text != "Ran read_file." || calls[0].call.name != "read_file" {
+		t.Errorf("Expected one entry reporting the read_file call, got %v", calls)
+	}
+}
+
+// TestToolConfirmation verifies that a tool's request for approval is shown
+// and answered with y or n.
+func TestToolConfirmation(t *testing.T) {
+	for key, want := range map[string]bool{"y": true, "n": false} {
+		m := InitialModel()
+		answer := make(chan bool, 1)
+		newModel, _ := m.Update(toolConfirmMsg{title: "Run this command?", details: "make test", answer: answer})
+		m = newModel.(model)
+		if m.confirm == nil || !strings.Contains(m.renderFooter(), "Run this command? (y/n)") {
+			t.Fatal("Expected the question in the footer")
+		}
+		if !strings.Contains(m.convo.entries[len(m.convo.entries)-1].text, "make test") {
+			t.Error("Expected the command to be shown")
+		}
+		newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		if m = newModel.(model); m.confirm != nil {
+			t.Errorf("%s: expected the question to be answered", key)
+		}
+		if got := <-answer; got != want {
+			t.Errorf("%s: answer = %v, want %v", key, got, want)
+		}
+	}
+}
+
+// TestAlwaysAllow verifies that answering a saves the rule the tool offers
+// to tools.allowed.
+func TestAlwaysAllow(t *testing.T) {
+	defer config.SetUserHomeDirForTesting(t.TempDir(), nil)()
+	m := InitialModel()
+	answer := make(chan bool, 1)
+	newModel, _ := m.Update(toolConfirmMsg{title: "Run this command?", details: "go test ./...", rule: "run_shell_command(go test ./...)", answer: answer})
+	m = newModel.(model)
+	if !strings.Contains(m.renderFooter(), "Run this command? (y/n, a to always allow)") {
+		t.Fatalf("Expected the question to offer always allowing, got %q", m.renderFooter())
+	}
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	m = newModel.(model)
+	if !<-answer {
+		t.Error("Expected the command to be approved")
+	}
+	user, err := config.LoadScope(config.UserScope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []*config.Settings{user, m.settings} {
+		if s.Tools == nil || !slices.Equal(s.Tools.Allowed, []string{"run_shell_command(go test ./...)"}) {
+			t.Errorf("Expected the rule in tools.allowed, got %+v", s.Tools)
+		}
+	}
+
+	// A command run with variables is not offered, since the rule would
+	// not cover them.
+	ws, err := tools.NewWorkspaceAt(t.TempDir(), m.settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg toolConfirmMsg
+	ws.Confirm = func(ctx context.Context, title, details string) (bool, error) {
+		msg = toolConfirmMsg{title: title, details: details, rule: tools.AllowRule(ctx), answer: answer}
+		return false, nil
+	}
+	tools.ExecuteToolCall(context.Background(), ws, &genai.FunctionCall{Name: tools.ShellToolName, Args: map[string]any{
+		"command": "go test ./...",
+		"env":     []any{"BASH_ENV=/tmp/x.sh"},
+	}})
+	newModel, _ = m.Update(msg)
+	m = newModel.(model)
+	if footer := m.renderFooter(); !strings.Contains(footer, "Run this command? (y/n)") || !strings.Contains(m.convo.entries[len(m.convo.entries)-1].text, "BASH_ENV=/tmp/x.sh") {
+		t.Fatalf("Expected the command to be asked about with its variables only once, got %q", footer)
+	}
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	if m = newModel.(model); m.confirm == nil {
+		t.Error("Expected a not to answer the question")
+	}
+}
+
+// TestReencodeHistory verifies that the parts a model cannot take are
+// replaced by notes, and that the rest of the history is kept as it is.
+func TestReencodeHistory(t *testing.T) {
+	history := []*genai.Content{
+		{Role: "user", Parts: []genai.Part{genai.Text("What is in this picture?"), genai.Blob{MIMEType: "image/png", Data: []byte{1}}}},
+		{Role: "model", Parts: []genai.Part{genai.FunctionCall{Name: "read_file", Args: map[string]any{"path": "a.txt"}}}},
+		{Role: "user", Parts: []genai.Part{&genai.FunctionResponse{Name: "read_file", Response: map[string]any{"content": "a"}}}},
+	}
+
+	same, omitted := reencodeHistory(history, capabilitiesOf("gemini-2.5-pro"))
+	if omitted != 0 || len(same) != 3 || len(same[0].Parts) != 2 {
+		t.Errorf("Expected Gemini models to take the whole history, got %d omitted", omitted)
+	}
+
+	text, omitted := reencodeHistory(history, capabilitiesOf("models/gemma-3-27b-it"))
+	if omitted != 3 {
+		t.Fatalf("omitted = %d, want 3", omitted)
+	}
+	want := []string{"What is in this picture?", "[image/png attachment omitted]", "[called the read_file tool with map[path:a.txt]]", "[the read_file tool returned map[content:a]]"}
+	var got []string
+	for _, c := range text {
+		for _, p := range c.Parts {
+			got = append(got, fmt.Sprint(p))
+		}
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("parts = %q, want %q", got, want)
+	}
+	if _, ok := history[0].Parts[1].(genai.Blob); !ok || text[2].Role != "user" {
+		t.Error("Expected a re-encoded copy with the roles kept")
+	}
+}
+
+// TestModelSwitch verifies that /model continues the conversation with the
+// new model.
+func TestModelSwitch(t *testing.T) {
+	m := InitialModel()
+	m, _ = m.modelCommand("")
+	if last := m.convo.entries[len(m.convo.entries)-1].text; !strings.Contains(last, "Using gemini-pro") {
+		t.Errorf("Expected the model in use to be shown, got %q", last)
+	}
+
+	history := []*genai.Content{{Role: "user", Parts: []genai.Part{genai.Text("hi")}}}
+	m, _ = m.applyModelSwitch(modelSwitchedMsg{name: "gemma-3-27b-it", model: &genai.GenerativeModel{}, history: history, omitted: 1})
+	if m.modelName != "gemma-3-27b-it" || m.chat == nil || len(m.chat.History) != 1 {
+		t.Errorf("Expected the conversation to continue with the new model, got %s with %d contents", m.modelName, len(m.chat.History))
+	}
+	if last := m.convo.entries[len(m.convo.entries)-1].text; !strings.Contains(last, "1 part(s)") {
+		t.Errorf("Expected the omitted parts to be reported, got %q", last)
+	}
+}
+
+// TestTokenCounts verifies the token labels of messages and the /context
+// report.
+func TestTokenCounts(t *testing.T) {
+	m := InitialModel()
+	m.inConversation = true
+	m.convo.add(userEntry, strings.Repeat("word ", 40))
+	m.convo.add(infoEntry, "not a message")
+	m.convo.add(geminiEntry, "short answer")
+	if got := m.convo.entries[0].tokens; got != 50 {
+		t.Errorf("estimated %d tokens, want 50", got)
+	}
+
+	newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlT})
+	m = newModel.(model)
+	view, _ := m.convo.visible(80, 20, 0, m.styles)
+	if !strings.Contains(view, "[~50 tokens]") || !strings.Contains(view, "[~3 tokens]") {
+		t.Errorf("view = %q, want estimated labels", view)
+	}
+
+	newModel, _ = m.Update(entryTokensMsg{counts: map[*entry]int32{m.convo.entries[0]: 41}, report: true})
+	m = newModel.(model)
+	view, _ = m.convo.visible(80, 20, 0, m.styles)
+	if !strings.Contains(view, "[41 tokens]") {
+		t.Errorf("view = %q, want the counted label", view)
+	}
+	report := m.convo.entries[len(m.convo.entries)-1].text
+	if !strings.Contains(report, "2 message(s), 44 tokens.") || strings.Index(report, "41 tokens") > strings.Index(report, "~3 tokens") {
+		t.Errorf("report = %q, want the messages largest first", report)
+	}
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlT})
+	m = newModel.(model)
+	if view, _ := m.convo.visible(80, 20, 0, m.styles); strings.Contains(view, "tokens]") {
+		t.Errorf("view = %q, want the labels hidden", view)
+	}
+}
+
+// TestSplitHistory verifies that /compress keeps the most recent turns and
+// never separates a tool call from its response.
+func TestSplitHistory(t *testing.T) {
+	text := func(role string, n int) *genai.Content {
+		return &genai.Content{Role: role, Parts: []genai.Part{genai.Text(strings.Repeat("x", n*charsPerToken))}}
+	}
+	history := []*genai.Content{
+		text("user", 100), text("model", 100),
+		text("user", 100), text("model", 100),
+		text("user", 10),
+		{Role: "model", Parts: []genai.Part{genai.FunctionCall{Name: "read_file"}}},
+		{Role: "user", Parts: []genai.Part{genai.FunctionResponse{Name: "read_file"}}},
+		text("model", 10),
+	}
+	if got := splitHistory(history, 30); got != 4 {
+		t.Errorf("splitHistory(30%%) = %d, want the last turn kept from 4", got)
+	}
+	if got := splitHistory(history, 60); got != 2 {
+		t.Errorf("splitHistory(60%%) = %d, want 2", got)
+	}
+	if got := splitHistory(history, 1); got != len(history) {
+		t.Errorf("splitHistory(1%%) = %d, want everything summarized", got)
+	}
+	if got := splitHistory(history, 100); got != 2 {
+		t.Errorf("splitHistory(100%%) = %d, want the first turn summarized", got)
+	}
+}
+
+// TestRetry verifies that /retry replaces the last response, and that
+// /retry <n> offers n responses to pick from with the number keys.
+func TestRetry(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		n := requests
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `[{"candidates":[{"content":{"role":"model","parts":[{"text":"answer %d"}]}}]}]`+"\n", n)
+	}))
+	defer server.Close()
+
+	client, err := genai.NewClient(context.Background(), option.WithAPIKey("key"), option.WithEndpoint(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	m := InitialModel()
+	m.workspace = &tools.Workspace{Roots: []string{t.TempDir()}, Settings: m.settings}
+	m.client = client.GenerativeModel("gemini-pro")
+	m.chat = m.client.StartChat()
+	m.chat.History = []*genai.Content{
+		{Role: "user", Parts: []genai.Part{genai.Text("question")}},
+		{Role: "model", Parts: []genai.Part{genai.Text("old answer")}},
+	}
+	lastAnswer := func() string {
+		h := m.chat.History
+		if len(h) != 2 || fmt.Sprint(h[0].Parts[0]) != "question" {
+			t.Fatalf("history = %v, want the question and one answer", h)
+		}
+		return fmt.Sprint(h[1].Parts[0])
+	}
+
+	m, cmd := m.retryCommand("")
+	msg, ok := cmd().(responseMsg)
+	if !ok || msg.text != "answer 1" {
+		t.Fatalf("/retry = %v, want a new response", msg)
+	}
+	newModel, _ := m.Update(msg)
+	m = newModel.(model)
+	if got := lastAnswer(); got != "answer 1" {
+		t.Errorf("history ends with %q, want the new answer", got)
+	}
+
+	m, cmd = m.retryCommand("2")
+	newModel, _ = m.Update(cmd())
+	m = newModel.(model)
+	if m.variants == nil || !strings.Contains(m.renderFooter(), "Pick a response with 1-2") {
+		t.Fatal("Expected two responses to pick from")
+	}
+	picked := variantText(m.variants.responses[1])
+	newModel, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("2")})
+	m = newModel.(model)
+	if msg, ok = cmd().(responseMsg); !ok || msg.text != picked {
+		t.Fatalf("picking = %v, want response 2, %q", msg, picked)
+	}
+	newModel, _ = m.Update(msg)
+	m = newModel.(model)
+	if got := lastAnswer(); got != picked {
+		t.Errorf("history ends with %q, want the picked response %q", got, picked)
+	}
+	if requests != 3 {
+		t.Errorf("made %d requests, want 3", requests)
+	}
+}
+
+// TestPartialResponse verifies that the text of a response whose stream
+// fails is kept, marked as incomplete, and can be continued with one key.
+func TestPartialResponse(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, string(body))
+		w.Header().Set("Content-Type", "application/json")
+		if len(requests) > 1 {
+			fmt.Fprintln(w, `[{"candidates":[{"content":{"role":"model","parts":[{"text":" the rest."}]}}]}]`)
+			return
+		}
+		fmt.Fprint(w, `[{"candidates":[{"content":{"role":"model","parts":[{"text":"The first half"}]}}]}`)
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}))
+	defer server.Close()
+
+	client, err := genai.NewClient(context.Background(), option.WithAPIKey("key"), option.WithEndpoint(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	m := InitialModel()
+	m.workspace = &tools.Workspace{Roots: []string{t.TempDir()}, Settings: m.settings}
+	m.client = client.GenerativeModel("gemini-pro")
+	m.chat = m.client.StartChat()
+
+	result := m.send(context.Background(), "Tell me")()
+	if _, ok := result.(interruptedMsg); !ok {
+		t.Fatalf("send() = %v, want an interrupted response", result)
+	}
+	newModel, _ := m.Update(result)
+	m = newModel.(model)
+	n := len(m.convo.entries)
+	if m.convo.entries[n-2].text != "The first half" || !strings.Contains(m.convo.entries[n-1].text, "incomplete") {
+		t.Errorf("Expected the partial text marked as incomplete, got %q and %q", m.convo.entries[n-2].text, m.convo.entries[n-1].text)
+	}
+	if m.confirm == nil {
+		t.Fatal("Expected to be offered to continue")
+	}
+
+	newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	m = newModel.(model)
+	if msg, ok := cmd().(responseMsg); !ok || msg.text != " the rest." {
+		t.Fatalf("continuing = %v, want the rest of the response", msg)
+	}
+	if len(requests) != 2 || !strings.Contains(requests[1], "The first half") {
+		t.Errorf("Expected the partial text to be sent back with the request to continue, got %q", requests)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	m := InitialModel()
+	m.inConversation = true
+	newModel, _ := m.Update(todosMsg{
+		{Description: "Read the code", Status: tools.TodoCompleted},
+		{Description: "Write the fix", Status: tools.TodoInProgress},
+	})
+	m = newModel.(model)
+	view := m.View()
+	for _, want := range []string{"Plan (1 of 2 done)", "[x] Read the code", "[>] Write the fix"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected the view to show %q, got:\n%s", want, view)
+		}
+	}
+
+	newModel, _ = m.Update(todosMsg{
+		{Description: "Read the code", Status: tools.TodoCompleted},
+		{Description: "Write the fix", Status: tools.TodoCompleted},
+	})
+	m = newModel.(model)
+	if strings.Contains(m.View(), "Plan (") {
+		t.Error("Expected the plan to be hidden once every step is done")
+	}
+}
+
+func TestSpeak(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = nil
+		json.NewDecoder(r.Body).Decode(&request)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(w, `[{"candidates":[{"content":{"role":"model","parts":[{"inlineData":{"mimeType":"audio/L16;codec=pcm;rate=24000","data":"AAAA"}}]},"finishReason":"STOP"}]}]`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey("key"), option.WithEndpoint(server.URL),
+		auth.ClientOption("key", audio.Transport(http.DefaultTransport)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	m := InitialModel().WithAttachments([]genai.Part{genai.Blob{MIMEType: "audio/wav", Data: []byte("RIFF")}})
+	m.client = client.GenerativeModel("gemini-2.5-flash-preview-tts")
+	m.chat = m.client.StartChat()
+	m = m.speakCommand("Puck")
+	if m.voice != "Puck" {
+		t.Fatalf("voice = %q after /speak Puck", m.voice)
+	}
+
+	result := m.send(ctx, "Read this aloud")()
+	msg, ok := result.(responseMsg)
+	if !ok || len(msg.audio) != 1 {
+		t.Fatalf("send() = %v, want an audio response", result)
+	}
+	if m.attachments != nil {
+		t.Error("Expected the attachments to be sent only once")
+	}
+	contents, _ := json.Marshal(request["contents"])
+	if !strings.Contains(string(contents), `"mimeType":"audio/wav"`) {
+		t.Errorf("contents = %s, want the attached audio", contents)
+	}
+	if config, _ := request["generationConfig"].(map[string]any); config["speechConfig"] == nil {
+		t.Errorf("generationConfig = %v, want a speech config", config)
+	}
+
+	newModel, _ := m.Update(msg)
+	m = newModel.(model)
+	last := m.convo.entries[len(m.convo.entries)-1].text
+	path, ok := strings.CutPrefix(last, "Audio saved to ")
+	if !ok {
+		t.Fatalf("Expected where the audio was saved, got %q", last)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data[:4]) != "RIFF" {
+		t.Errorf("Expected a WAV file at %s, got %q (%v)", path, data, err)
+	}
+
+	if m = m.speakCommand(""); m.voice != "" {
+		t.Errorf("Expected /speak to turn spoken responses off, voice = %q", m.voice)
+	}
+}
+
+func TestShellTakeOver(t *testing.T) {
+	if !pty.Supported() {
+		t.Skip("no pseudo-terminals on this platform")
+	}
+	ws := &tools.Workspace{Roots: []string{t.TempDir()}, Settings: &config.Settings{
+		Tools: &config.ToolsSettings{Shell: &config.ShellSettings{EnableInteractiveShell: true}},
+	}}
+	started := make(chan *tools.ShellSession, 1)
+	ws.ShellStarted = func(s *tools.ShellSession) { started <- s }
+	type result struct {
+		output string
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		output, _, err := tools.RunShell(context.Background(), ws, ws.Roots[0], `printf 'Name? '; read name; echo "Hello, $name"`, nil)
+		done <- result{output, err}
+	}()
+
+	m := InitialModel()
+	m.inConversation = true
+	newModel, _ := m.Update(shellStartedMsg{<-started})
+	m = newModel.(model)
+	if view := m.View(); !strings.Contains(view, "Running: printf") || !strings.Contains(view, "Ctrl+F to type into it") {
+		t.Errorf("Expected the view to show the running command, got:\n%s", view)
+	}
+
+	for _, key := range []tea.KeyMsg{
+		{Type: tea.KeyCtrlF},
+		{Type: tea.KeyRunes, Runes: []rune("Ada")},
+		{Type: tea.KeyEnter},
+	} {
+		newModel, _ = m.Update(key)
+		m = newModel.(model)
+	}
+	if m.textarea.Value() != "" {
+		t.Errorf("Expected the keys to go to the command, but the input has %q", m.textarea.Value())
+	}
+	if !strings.Contains(m.renderFooter(), "Typing into the command") {
+		t.Errorf("Expected the footer to say keys go to the command, got %q", m.renderFooter())
+	}
+	r := <-done
+	if r.err != nil || !strings.Contains(r.output, "Hello, Ada\n") {
+		t.Errorf("RunShell = %q, %v", r.output, r.err)
+	}
+
+	newModel, _ = m.Update(shellExitedMsg{m.shell})
+	m = newModel.(model)
+	if m.shell != nil || m.shellFocused || strings.Contains(m.View(), "Running:") {
+		t.Error("Expected the command to be hidden once it exited")
+	}
+}
+
+func TestKeyBytes(t *testing.T) {
+	tests := []struct {
+		key  tea.KeyMsg
+		want string
+	}{
+		{tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("é")}, "é"},
+		{tea.KeyMsg{Type: tea.KeyEnter}, "\r"},
+		{tea.KeyMsg{Type: tea.KeyCtrlC}, "\x03"},
+		{tea.KeyMsg{Type: tea.KeyBackspace}, "\x7f"},
+		{tea.KeyMsg{Type: tea.KeyUp}, "\x1b[A"},
+		{tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("b"), Alt: true}, "\x1bb"},
+		{tea.KeyMsg{Type: tea.KeyF1}, ""},
+	}
+	for _, tt := range tests {
+		if got := string(keyBytes(tt.key)); got != tt.want {
+			t.Errorf("keyBytes(%v) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestPS(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	m := InitialModel()
+	m.workspace.Roots = []string{t.TempDir()}
+	m.workspace.Confirm = func(context.Context, string, string) (bool, error) { return true, nil }
+	defer m.workspace.Processes.StopAll(context.Background())
+	last := func() string { return m.convo.entries[len(m.convo.entries)-1].text }
+
+	m = m.psCommand("")
+	if last() != "No commands run in the background." {
+		t.Errorf("Expected no background commands, got %q", last())
+	}
+
+	part := tools.ExecuteToolCall(context.Background(), m.workspace, &genai.FunctionCall{
+		Name: tools.ShellToolName,
+		Args: map[string]any{"command": "echo serving; sleep 60", "background": true},
+	})
+	if resp := part.(*genai.FunctionResponse).Response; resp["error"] != nil {
+		t.Fatalf("Failed to start the command: %v", resp["error"])
+	}
+	m = m.psCommand("")
+	if !strings.Contains(last(), "1: PID") || !strings.Contains(last(), "running for") || !strings.Contains(last(), "echo serving; sleep 60") {
+		t.Errorf("Expected the command to be listed, got %q", last())
+	}
+	m = m.psCommand("output 1")
+	if !strings.HasSuffix(last(), "\nserving") {
+		t.Errorf("Expected the output of the command, got %q", last())
+	}
+	m = m.psCommand("kill 1")
+	if last() != "Stopped background command 1." {
+		t.Errorf("Expected the command to be stopped, got %q", last())
+	}
+	if items := completePS(m, []string{"kill"}); len(items) != 0 {
+		t.Errorf("Expected no commands to kill, got %v", items)
+	}
+	m = m.psCommand("kill 2")
+	if last() != "There is no background command 2." {
+		t.Errorf("Expected an unknown command to be reported, got %q", last())
+	}
+}
+
+func TestMentions(t *testing.T) {
+	m := InitialModel()
+	m.workspace.Roots = []string{t.TempDir()}
+	if err := os.WriteFile(filepath.Join(m.workspace.Roots[0], "notes.txt"), []byte("buy milk"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	paths, err := m.mentions("Summarize @notes.txt, then ask @someone about @notes.txt.")
+	if err != nil || !slices.Equal(paths, []string{"notes.txt"}) {
+		t.Fatalf("mentions = %v, %v; want [notes.txt]", paths, err)
+	}
+	parts, err := m.attachFiles(context.Background(), paths)
+	if err != nil || len(parts) != 1 || !strings.Contains(string(parts[0].(genai.Text)), "buy milk") {
+		t.Errorf("attachFiles = %v, %v", parts, err)
+	}
+	if _, err := m.mentions("@" + os.Args[0]); err == nil {
+		t.Error("Expected an error for a file outside the workspace")
+	}
+}
+
+// TestResourceMentions verifies that @server:uri adds the contents of an
+// MCP resource to the prompt and that /mcp resources lists them.
+func TestResourceMentions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params struct {
+				URI string `json:"uri"`
+			} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ID == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		var result any
+		switch req.Method {
+		case "initialize":
+			result = map[string]any{"protocolVersion": "2025-06-18", "capabilities": map[string]any{"resources": map[string]any{}}}
+		case "tools/list":
+			result = map[string]any{"tools": []mcp.Tool{}}
+		case "resources/list":
+			result = map[string]any{"resources": []mcp.Resource{
+				{URI: "file:///guide.md", Description: "The guide"},
+				{URI: "file:///logo.png", MimeType: "image/png"},
+			}}
+		case "resources/read":
+			contents := mcp.ResourceContents{URI: req.Params.URI, Text: "# Guide"}
+			if strings.HasSuffix(req.Params.URI, ".png") {
+				contents = mcp.ResourceContents{URI: req.Params.URI, Blob: "iVBO"}
+			}
+			result = map[string]any{"contents": []mcp.ResourceContents{contents}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	server := config.MCPServer{HTTPURL: srv.URL}
+	c, err := mcp.Connect(ctx, "docs", server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := InitialModel()
+	m.workspace.MCP = mcp.NewRegistry(nil)
+	defer m.workspace.MCP.Close()
+	if err := m.workspace.MCP.Add(ctx, c, server); err != nil {
+		t.Fatal(err)
+	}
+
+	resources := m.resourceMentions("Compare @docs:file:///guide.md, @docs:file:///logo.png and @docs:file:///guide.md. Ask @docs:missing.")
+	if len(resources) != 2 {
+		t.Fatalf("resourceMentions = %+v, want the guide and the logo", resources)
+	}
+	parts, err := readResources(ctx, m.workspace.MCP, resources)
+	if err != nil || len(parts) != 3 {
+		t.Fatalf("readResources = %v, %v", parts, err)
+	}
+	if text := parts[0].(genai.Text); text != "Content of @docs:file:///guide.md:\n# Guide" {
+		t.Errorf("Expected the text of the guide, got %q", text)
+	}
+	if blob, ok := parts[2].(genai.Blob); !ok || blob.MIMEType != "image/png" || len(blob.Data) != 3 {
+		t.Errorf("Expected the logo as a PNG blob, got %v", parts[2])
+	}
+
+	m, _ = m.mcpCommand("resources")
+	if last := m.convo.entries[len(m.convo.entries)-1].text; !strings.Contains(last, "@docs:file:///guide.md - The guide") {
+		t.Errorf("Expected the resources to be listed, got %q", last)
+	}
+	if c := m.suggest("Read @docs:file:///g"); c == nil || c.items[0].value != "docs:file:///guide.md" {
+		t.Errorf("Expected the guide to be suggested, got %+v", c)
+	}
+}
+
+// TestIndexFailures verifies that failures to update the semantic index
+// are shown in the conversation, once each.
+func TestIndexFailures(t *testing.T) {
+	m := InitialModel()
+	n := len(m.convo.entries)
+	for _, err := range []error{errors.New("quota exceeded"), errors.New("quota exceeded"), errors.New("offline")} {
+		newModel, _ := m.Update(indexFailedMsg{err})
+		m = newModel.(model)
+	}
+	var shown []string
+	for _, e := range m.convo.entries[n:] {
+		shown = append(shown, e.text)
+	}
+	if len(shown) != 2 || !strings.Contains(shown[0], "quota exceeded") || !strings.Contains(shown[1], "offline") {
+		t.Errorf("shown %q, want each failure once", shown)
+	}
+}
+
+// TestModelSwitchToGemma verifies that the tools and the thinking budget
+// are not sent to a model that can use neither.
+func TestModelSwitchToGemma(t *testing.T) {
+	var requests []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			fmt.Fprintln(w, `{"name":"models/gemma-3-27b-it","inputTokenLimit":8192}`)
+			return
+		}
+		var request map[string]any
+		json.NewDecoder(r.Body).Decode(&request)
+		requests = append(requests, request)
+		fmt.Fprintln(w, `[{"candidates":[{"content":{"role":"model","parts":[{"text":"Hi."}]}}]}]`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey("key"), option.WithEndpoint(server.URL),
+		auth.ClientOption("key", thinking.Transport(http.DefaultTransport, 512)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	m := InitialModel()
+	m.workspace = &tools.Workspace{Roots: []string{t.TempDir()}, Settings: m.settings}
+	m.api = client
+	m.client = client.GenerativeModel("gemini-2.5-pro")
+	m.client.Tools = []*genai.Tool{m.workspace.Tool()}
+	m.client.ToolConfig = &genai.ToolConfig{}
+	m.client.SetTemperature(0.5)
+	m.chat = m.client.StartChat()
+	m.modelName = "gemini-2.5-pro"
+
+	msg, ok := m.switchModel("gemma-3-27b-it")().(modelSwitchedMsg)
+	if !ok {
+		t.Fatal("Expected to switch to gemma-3-27b-it")
+	}
+	if msg.model.Tools != nil || msg.model.ToolConfig != nil || *msg.model.Temperature != 0.5 {
+		t.Errorf("gemma-3-27b-it configured with tools %v and temperature %v, want no tools and the temperature kept", msg.model.Tools, msg.model.Temperature)
+	}
+	m, _ = m.applyModelSwitch(msg)
+
+	if _, ok := m.send(ctx, "Hello")().(responseMsg); !ok {
+		t.Fatal("Expected a response")
+	}
+	if len(requests) != 1 {
+		t.Fatalf("made %d requests, want 1", len(requests))
+	}
+	generation, _ := requests[0]["generationConfig"].(map[string]any)
+	if requests[0]["tools"] != nil || generation["thinkingConfig"] != nil {
+		t.Errorf("request = %v, want neither tools nor a thinking budget", requests[0])
+	}
+}
+
+// TestInline verifies that the inline UI prints each entry of the
+// conversation once, and draws only the input below it.
+func TestInline(t *testing.T) {
+	m := InitialModel().WithInline()
+	if !strings.Contains(m.View(), "Tips for getting started:") {
+		t.Error("want the tips drawn before the conversation starts")
+	}
+
+	m.textarea.SetValue("Hello, Gemini!")
+	if got := m.unprinted(); got != "" {
+		t.Fatalf("unprinted() = %q before anything was said", got)
+	}
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(model)
+	if m.printed != len(m.convo.entries) || m.printed == 0 {
+		t.Errorf("printed %d of %d entries, want all of them", m.printed, len(m.convo.entries))
+	}
+	if view := m.View(); strings.Contains(view, "You: Hello, Gemini!") || strings.Contains(view, "Tips for getting started:") {
+		t.Errorf("view = %q, want the conversation left to the scrollback", view)
+	}
+
+	m.convo.add(geminiEntry, "Hi!")
+	if got := m.unprinted(); !strings.Contains(got, "Gemini: Hi!") || strings.Contains(got, "You:") {
+		t.Errorf("unprinted() = %q, want only the response", got)
+	}
+	next, cmd := m.Update(todosMsg(nil))
+	if m = next.(model); m.printed != len(m.convo.entries) || cmd == nil {
+		t.Error("want the response printed on the next update")
+	}
+	if _, cmd := m.Update(todosMsg(nil)); cmd != nil {
+		t.Error("want nothing printed twice")
+	}
+}
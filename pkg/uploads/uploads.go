@@ -0,0 +1,291 @@
+// Package uploads sends files too large to go inline in a request through
+// the Gemini Files API, which keeps uploads for 48 hours, and remembers what
+// it uploaded in ~/.gemini/uploads.json so that attaching the same file
+// again, in this session or another, reuses the upload.
+package uploads
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+	"github.com/google/generative-ai-go/genai"
+)
+
+const (
+	// InlineLimit is the size of the largest file sent inline; larger ones
+	// are uploaded. Requests are capped at 20 MB in all.
+	InlineLimit = 10 << 20
+	// expiryMargin is how long before it expires an upload is no longer
+	// reused, so that it does not expire during the conversation.
+	expiryMargin = time.Hour
+	// pollInterval is how often Upload checks whether the API finished
+	// processing an upload, as it does videos before they can be used.
+	pollInterval = 2 * time.Second
+)
+
+// Service is the part of the Files API Uploader uses, which *genai.Client
+// implements.
+type Service interface {
+	UploadFile(ctx context.Context, name string, r io.Reader, opts *genai.UploadFileOptions) (*genai.File, error)
+	GetFile(ctx context.Context, name string) (*genai.File, error)
+}
+
+// Record is an upload Uploader remembers.
+type Record struct {
+	// Name is the name of the file in the Files API, as "files/abc123".
+	Name     string    `json:"name"`
+	URI      string    `json:"uri"`
+	MIMEType string    `json:"mimeType"`
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Expires  time.Time `json:"expires"`
+}
+
+// Uploader uploads files, reusing earlier uploads of the same content.
+type Uploader struct {
+	Service Service
+	// Path is the file the uploads are remembered in.
+	Path string
+
+	mu sync.Mutex
+}
+
+// New returns an Uploader remembering its uploads in ~/.gemini/uploads.json.
+func New(service Service) (*Uploader, error) {
+	dir, err := config.UserDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Uploader{Service: service, Path: filepath.Join(dir, "uploads.json")}, nil
+}
+
+// Upload uploads the file at path, unless an upload of the same content is
+// still available, and returns the upload and whether it was reused.
+func (u *Uploader) Upload(ctx context.Context, path string) (Record, bool, error) {
+	hash, size, err := hashFile(path)
+	if err != nil {
+		return Record{}, false, err
+	}
+	records, err := u.load()
+	if err != nil {
+		return Record{}, false, err
+	}
+	if r, ok := records[hash]; ok && (r.Expires.IsZero() || time.Until(r.Expires) > expiryMargin) {
+		// The upload may have been deleted, with files delete or elsewhere.
+		if f, err := u.Service.GetFile(ctx, r.Name); err == nil && f.State == genai.FileStateActive {
+			return r, true, nil
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return Record{}, false, err
+	}
+	defer f.Close()
+	mimeType, err := detectType(path, f)
+	if err != nil {
+		return Record{}, false, err
+	}
+	file, err := u.Service.UploadFile(ctx, "", f, &genai.UploadFileOptions{DisplayName: filepath.Base(path), MIMEType: mimeType})
+	if err != nil {
+		return Record{}, false, fmt.Errorf("failed to upload %s: %w", path, err)
+	}
+	if file, err = u.awaitProcessing(ctx, file); err != nil {
+		return Record{}, false, fmt.Errorf("failed to upload %s: %w", path, err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	r := Record{Name: file.Name, URI: file.URI, MIMEType: file.MIMEType, Path: abs, Size: size, Expires: file.ExpirationTime}
+	if r.MIMEType == "" {
+		r.MIMEType = mimeType
+	}
+	return r, false, u.update(func(records map[string]Record) { records[hash] = r })
+}
+
+// awaitProcessing waits until the API finished processing file.
+func (u *Uploader) awaitProcessing(ctx context.Context, file *genai.File) (*genai.File, error) {
+	for file.State == genai.FileStateProcessing {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+		var err error
+		if file, err = u.Service.GetFile(ctx, file.Name); err != nil {
+			return nil, err
+		}
+	}
+	if file.State == genai.FileStateFailed {
+		if file.Error != nil {
+			return nil, fmt.Errorf("processing failed: %s", file.Error.Error())
+		}
+		return nil, errors.New("processing failed")
+	}
+	return file, nil
+}
+
+// Forget stops reusing the upload named name, which was deleted.
+func (u *Uploader) Forget(name string) error {
+	return u.update(func(records map[string]Record) {
+		for hash, r := range records {
+			if r.Name == name {
+				delete(records, hash)
+			}
+		}
+	})
+}
+
+// Records returns the uploads remembered, by name.
+func (u *Uploader) Records() (map[string]Record, error) {
+	records, err := u.load()
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]Record, len(records))
+	for _, r := range records {
+		byName[r.Name] = r
+	}
+	return byName, nil
+}
+
+// Attach returns the file at path as parts of a prompt: text files as text
+// and other files as inline data, or as a reference to their upload if
+// they are larger than InlineLimit. uploaded reports whether it did so.
+func (u *Uploader) Attach(ctx context.Context, path string) (parts []genai.Part, uploaded bool, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if info.IsDir() {
+		return nil, false, fmt.Errorf("%s is a directory", path)
+	}
+	label := genai.Text(fmt.Sprintf("Content of %s:", path))
+	if info.Size() > InlineLimit {
+		if u == nil || u.Service == nil {
+			return nil, false, fmt.Errorf("%s is larger than %d MB and cannot be uploaded", path, InlineLimit>>20)
+		}
+		r, _, err := u.Upload(ctx, path)
+		if err != nil {
+			return nil, false, err
+		}
+		return []genai.Part{label, genai.FileData{MIMEType: r.MIMEType, URI: r.URI}}, true, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	mimeType := http.DetectContentType(data)
+	if t := mime.TypeByExtension(filepath.Ext(path)); t != "" {
+		mimeType = t
+	}
+	if utf8.Valid(data) && !isMedia(mimeType) {
+		return []genai.Part{genai.Text(fmt.Sprintf("Content of %s:\n%s", path, data))}, false, nil
+	}
+	return []genai.Part{label, genai.Blob{MIMEType: mimeType, Data: data}}, false, nil
+}
+
+// isMedia reports whether mimeType is of an image, audio, video or PDF,
+// which the model reads as such rather than as text.
+func isMedia(mimeType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(mimeType)
+	switch kind, _, _ := strings.Cut(mediaType, "/"); kind {
+	case "image", "audio", "video":
+		return true
+	}
+	return mediaType == "application/pdf"
+}
+
+// detectType returns the MIME type of the file f at path, by its extension
+// or else by its content.
+func detectType(path string, f *os.File) (string, error) {
+	if t := mime.TypeByExtension(filepath.Ext(path)); t != "" {
+		return t, nil
+	}
+	head := make([]byte, 512)
+	n, err := f.Read(head)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// hashFile returns the SHA-256 of the file at path, and its size.
+func hashFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// load reads the uploads remembered, by the SHA-256 of their content.
+func (u *Uploader) load() (map[string]Record, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.read()
+}
+
+func (u *Uploader) read() (map[string]Record, error) {
+	records := map[string]Record{}
+	data, err := os.ReadFile(u.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", u.Path, err)
+	}
+	return records, nil
+}
+
+// update applies change to the uploads remembered, dropping those that
+// expired.
+func (u *Uploader) update(change func(map[string]Record)) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	records, err := u.read()
+	if err != nil {
+		return err
+	}
+	change(records)
+	for hash, r := range records {
+		if !r.Expires.IsZero() && r.Expires.Before(time.Now()) {
+			delete(records, hash)
+		}
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(u.Path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(u.Path, data, 0o600)
+}
@@ -0,0 +1,122 @@
+package uploads
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// fakeService keeps uploads in memory.
+type fakeService struct {
+	files   map[string]*genai.File
+	uploads int
+}
+
+func (s *fakeService) UploadFile(ctx context.Context, name string, r io.Reader, opts *genai.UploadFileOptions) (*genai.File, error) {
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return nil, err
+	}
+	s.uploads++
+	name = fmt.Sprintf("files/upload-%d", s.uploads)
+	f := &genai.File{Name: name, URI: "https://files.test/" + name, MIMEType: opts.MIMEType, DisplayName: opts.DisplayName,
+		State: genai.FileStateActive, ExpirationTime: time.Now().Add(48 * time.Hour)}
+	if s.files == nil {
+		s.files = map[string]*genai.File{}
+	}
+	s.files[name] = f
+	return f, nil
+}
+
+func (s *fakeService) GetFile(ctx context.Context, name string) (*genai.File, error) {
+	if f, ok := s.files[name]; ok {
+		return f, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func TestUploadReuses(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "talk.pdf")
+	if err := os.WriteFile(path, []byte("%PDF"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	service := &fakeService{}
+	u := &Uploader{Service: service, Path: filepath.Join(dir, "uploads.json")}
+
+	r, reused, err := u.Upload(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reused || r.Name != "files/upload-1" || r.MIMEType != "application/pdf" || r.Size != 4 {
+		t.Errorf("Upload = %+v, reused %v", r, reused)
+	}
+
+	// The same content is not uploaded again, even by another Uploader
+	// or from another path.
+	copied := filepath.Join(dir, "copy.pdf")
+	if err := os.WriteFile(copied, []byte("%PDF"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	u = &Uploader{Service: service, Path: u.Path}
+	if r, reused, err = u.Upload(context.Background(), copied); err != nil || !reused || r.Name != "files/upload-1" {
+		t.Errorf("Upload = %+v, reused %v, %v; want the first upload reused", r, reused, err)
+	}
+
+	// Uploads deleted elsewhere are uploaded again.
+	delete(service.files, "files/upload-1")
+	if r, reused, err = u.Upload(context.Background(), path); err != nil || reused || r.Name != "files/upload-2" {
+		t.Errorf("Upload = %+v, reused %v, %v; want a new upload", r, reused, err)
+	}
+
+	if err := u.Forget("files/upload-2"); err != nil {
+		t.Fatal(err)
+	}
+	if records, err := u.Records(); err != nil || len(records) != 0 {
+		t.Errorf("Records = %v, %v; want none", records, err)
+	}
+}
+
+func TestAttach(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	service := &fakeService{}
+	u := &Uploader{Service: service, Path: filepath.Join(dir, "uploads.json")}
+	ctx := context.Background()
+
+	text := write("notes.txt", []byte("hello"))
+	parts, uploaded, err := u.Attach(ctx, text)
+	if err != nil || uploaded || len(parts) != 1 || parts[0] != genai.Text("Content of "+text+":\nhello") {
+		t.Errorf("Attach(text) = %v, %v, %v", parts, uploaded, err)
+	}
+
+	image := write("dot.png", []byte("\x89PNG\r\n\x1a\n"))
+	parts, uploaded, err = u.Attach(ctx, image)
+	if blob, ok := parts[len(parts)-1].(genai.Blob); err != nil || uploaded || !ok || blob.MIMEType != "image/png" {
+		t.Errorf("Attach(image) = %v, %v, %v", parts, uploaded, err)
+	}
+
+	large := write("large.pdf", bytes.Repeat([]byte("x"), InlineLimit+1))
+	parts, uploaded, err = u.Attach(ctx, large)
+	if data, ok := parts[len(parts)-1].(genai.FileData); err != nil || !uploaded || !ok || data.URI != "https://files.test/files/upload-1" || data.MIMEType != "application/pdf" {
+		t.Errorf("Attach(large) = %v, %v, %v", parts, uploaded, err)
+	}
+
+	var none *Uploader
+	if _, _, err := none.Attach(ctx, large); err == nil {
+		t.Error("Expected an error attaching a large file without the Files API")
+	}
+}
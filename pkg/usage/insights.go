@@ -0,0 +1,230 @@
+package usage
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// minRuleMatches is how often an error must recur to be reported.
+	minRuleMatches = 2
+	// minLoopLength is the number of identical consecutive calls that make
+	// a retry loop.
+	minLoopLength = 3
+	// A tool failing in at least failureRate of at least minCalls calls is
+	// reported even without a known cause.
+	minCalls    = 5
+	failureRate = 0.5
+)
+
+// ToolStats summarizes the calls of a tool, or of a program run through the
+// shell tool.
+type ToolStats struct {
+	Name     string
+	Calls    int
+	Failures int
+	// Retries counts calls repeating a failed call with the same arguments.
+	Retries  int
+	Duration time.Duration
+}
+
+// Finding is a pattern in the usage and what to do about it.
+type Finding struct {
+	Problem    string
+	Suggestion string
+}
+
+// Summary describes the recorded usage.
+type Summary struct {
+	Since    time.Time
+	Calls    int
+	Tools    []ToolStats
+	Findings []Finding
+}
+
+// rule recognizes the errors of a known cause.
+type rule struct {
+	pattern *regexp.Regexp
+	// problem and suggestion are formatted with the first submatch, if the
+	// pattern has one, and the number of matching calls.
+	problem, suggestion string
+}
+
+var missingProgram = regexp.MustCompile(`(?:^|\n|: )([\w.+-]+): (?:command )?not found|'([\w.+-]+)' is not recognized as an internal or external command|exec: "([\w.+-]+)": executable file not found`)
+
+var rules = []rule{
+	{
+		pattern:    regexp.MustCompile(`is outside the workspace`),
+		problem:    "%[1]d calls were refused because the path is outside the workspace.",
+		suggestion: "If the agent needs those directories, add them to context.includeDirectories.",
+	},
+	{
+		pattern:    regexp.MustCompile(`the (\S+) language server \(\S+\) is not installed`),
+		problem:    "The %[2]s language server is not installed, so %[1]d code intelligence calls failed.",
+		suggestion: "Install it, or point tools.lsp.servers.%[2]s at its command.",
+	},
+	{
+		pattern:    regexp.MustCompile(`has no semantic index`),
+		problem:    "semantic_search failed %[1]d times because the project has no index.",
+		suggestion: "Run `gemini index build` in the project.",
+	},
+	{
+		pattern:    regexp.MustCompile(`the user did not approve requests to (\S+)`),
+		problem:    "Requests to %[2]s were declined %[1]d times.",
+		suggestion: "Add %[2]s to tools.http.allowedHosts if it is trusted, or tell the agent in GEMINI.md not to use it.",
+	},
+	{
+		pattern:    regexp.MustCompile(`(\S+) is not in tools.http.allowedHosts`),
+		problem:    "%[1]d requests to %[2]s failed outside interactive sessions.",
+		suggestion: "Add %[2]s to tools.http.allowedHosts if it is trusted.",
+	},
+}
+
+// Summarize finds the patterns in events, which are ordered oldest first.
+func Summarize(events []Event) Summary {
+	s := Summary{Calls: len(events)}
+	if len(events) > 0 {
+		s.Since = events[0].Time
+	}
+
+	stats := map[string]*ToolStats{}
+	missing := map[string]int{}
+	matches := map[int]map[string]int{} // by rule, then submatch
+	var loops []string
+	run := 1
+	for i, e := range events {
+		name := statsName(e)
+		st := stats[name]
+		if st == nil {
+			st = &ToolStats{Name: name}
+			stats[name] = st
+		}
+		st.Calls++
+		st.Duration += time.Duration(e.DurationMS) * time.Millisecond
+
+		repeat := i > 0 && events[i-1].Tool == e.Tool && events[i-1].Args == e.Args
+		if repeat && events[i-1].Failed() {
+			st.Retries++
+		}
+		if repeat {
+			run++
+		} else {
+			run = 1
+		}
+		if run == minLoopLength {
+			loops = append(loops, name)
+		}
+
+		if !e.Failed() {
+			continue
+		}
+		st.Failures++
+		if m := missingProgram.FindStringSubmatch(e.Error); m != nil {
+			missing[strings.Join(m[1:], "")]++
+			continue
+		}
+		for r, ru := range rules {
+			if m := ru.pattern.FindStringSubmatch(e.Error); m != nil {
+				if matches[r] == nil {
+					matches[r] = map[string]int{}
+				}
+				matches[r][strings.Join(m[1:], "")]++
+			}
+		}
+	}
+
+	for _, st := range stats {
+		s.Tools = append(s.Tools, *st)
+	}
+	sort.Slice(s.Tools, func(i, j int) bool {
+		if s.Tools[i].Calls != s.Tools[j].Calls {
+			return s.Tools[i].Calls > s.Tools[j].Calls
+		}
+		return s.Tools[i].Name < s.Tools[j].Name
+	})
+
+	explained := map[string]bool{}
+	for _, program := range sortedKeys(missing) {
+		if missing[program] < minRuleMatches {
+			continue
+		}
+		explained["run_shell_command "+program] = true
+		s.Findings = append(s.Findings, missingProgramFinding(program, missing[program]))
+	}
+	for r, ru := range rules {
+		for _, sub := range sortedKeys(matches[r]) {
+			if n := matches[r][sub]; n >= minRuleMatches {
+				s.Findings = append(s.Findings, Finding{
+					Problem:    sprintf(ru.problem, n, sub),
+					Suggestion: sprintf(ru.suggestion, n, sub),
+				})
+			}
+		}
+	}
+	for _, st := range s.Tools {
+		if !explained[st.Name] && st.Calls >= minCalls && float64(st.Failures) >= failureRate*float64(st.Calls) {
+			s.Findings = append(s.Findings, Finding{
+				Problem:    fmt.Sprintf("%s failed in %d of %d calls.", st.Name, st.Failures, st.Calls),
+				Suggestion: "Look at its errors in a recent session; a note in GEMINI.md on how to use it in this project may help.",
+			})
+		}
+	}
+	loopCounts := map[string]int{}
+	for _, name := range loops {
+		loopCounts[name]++
+	}
+	for _, name := range sortedKeys(loopCounts) {
+		s.Findings = append(s.Findings, Finding{
+			Problem: fmt.Sprintf("The agent called %s with the same arguments %d or more times in a row, %d time(s).",
+				name, minLoopLength, loopCounts[name]),
+			Suggestion: "Repeating a call rarely changes its outcome. If the calls failed, fix the cause above; " +
+				"a lower model.maxSessionTurns limits how long such loops run.",
+		})
+	}
+	return s
+}
+
+func missingProgramFinding(program string, n int) Finding {
+	f := Finding{
+		Problem:    fmt.Sprintf("%d shell commands failed because %s is not installed or not on PATH.", n, program),
+		Suggestion: fmt.Sprintf("Install %s, or tell the agent in GEMINI.md which command to use instead.", program),
+	}
+	switch program {
+	case "rg":
+		f.Suggestion = "Install ripgrep (rg), or tell the agent in GEMINI.md to search with grep instead."
+	case "fd":
+		f.Suggestion = "Install fd, or tell the agent in GEMINI.md to use find instead."
+	case "python":
+		f.Suggestion = "Only python3 may be installed: tell the agent in GEMINI.md to use python3."
+	}
+	return f
+}
+
+// statsName names the tool of e, with the program for shell commands.
+func statsName(e Event) string {
+	if e.Program != "" {
+		return e.Tool + " " + e.Program
+	}
+	return e.Tool
+}
+
+// sprintf formats a rule message with the count and submatch, dropping
+// what the message does not use.
+func sprintf(format string, n int, sub string) string {
+	if strings.Contains(format, "%[2]") {
+		return fmt.Sprintf(format, n, sub)
+	}
+	return fmt.Sprintf(format, n)
+}
+
+func sortedKeys(m map[string]int) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
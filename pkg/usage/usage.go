@@ -0,0 +1,159 @@
+// Package usage records how the agent uses tools in a project, so that
+// /insights can point out tools that keep failing and what to configure
+// about it. The records stay on the machine, in
+// ~/.gemini/tmp/<project-hash>/tool-usage.jsonl.
+package usage
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google-gemini/gemini-cli-go/pkg/config"
+)
+
+const (
+	logFileName = "tool-usage.jsonl"
+	// maxLogBytes is the size at which the log is compacted to its last
+	// keepEvents events.
+	maxLogBytes = 2 << 20
+	keepEvents  = 2000
+	// maxErrorLen caps the error text kept per event.
+	maxErrorLen = 300
+)
+
+// Event is one tool call.
+type Event struct {
+	Time time.Time `json:"time"`
+	Tool string    `json:"tool"`
+	// Program is the program a shell command ran, such as "rg".
+	Program string `json:"program,omitempty"`
+	// Args identifies the arguments, to recognize repeated calls.
+	Args       string `json:"args"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"durationMs"`
+}
+
+// Failed reports whether the call failed.
+func (e Event) Failed() bool { return e.Error != "" }
+
+// Recorder appends events to a project's log.
+type Recorder struct {
+	path string
+	mu   sync.Mutex
+}
+
+func logPath(projectRoot string) (string, error) {
+	tmp, err := config.ProjectTempDir(projectRoot)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(tmp, logFileName), nil
+}
+
+// NewRecorder returns the recorder of the project at projectRoot.
+func NewRecorder(projectRoot string) (*Recorder, error) {
+	path, err := logPath(projectRoot)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{path: path}, nil
+}
+
+// ArgsKey returns a short hash identifying a call's arguments.
+func ArgsKey(args map[string]any) string {
+	data, _ := json.Marshal(args) // map keys are sorted
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
+
+// Record appends e to the log.
+func (r *Recorder) Record(e Event) error {
+	if len(e.Error) > maxErrorLen {
+		e.Error = e.Error[:maxErrorLen]
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(r.path); err == nil && info.Size() > maxLogBytes {
+		return compact(r.path)
+	}
+	return nil
+}
+
+// compact rewrites the log with its last keepEvents events.
+func compact(path string) error {
+	events, err := readLog(path)
+	if err != nil {
+		return err
+	}
+	events = events[max(len(events)-keepEvents, 0):]
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range events {
+		enc.Encode(e)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load returns the recorded events of the project at projectRoot, oldest
+// first.
+func Load(projectRoot string) ([]Event, error) {
+	path, err := logPath(projectRoot)
+	if err != nil {
+		return nil, err
+	}
+	events, err := readLog(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return events, err
+}
+
+func readLog(path string) ([]Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var e Event
+		// Skip lines cut short by a crash.
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			events = append(events, e)
+		}
+	}
+	return events, scanner.Err()
+}
@@ -0,0 +1,81 @@
+package usage
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordAndLoad(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	if events, err := Load(root); err != nil || events != nil {
+		t.Fatalf("Load without a log = %v, %v", events, err)
+	}
+	r, err := NewRecorder(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Record(Event{Tool: "read_file", Args: ArgsKey(map[string]any{"path": "a"})}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Record(Event{Tool: "read_file", Error: strings.Repeat("x", 1000)}); err != nil {
+		t.Fatal(err)
+	}
+	events, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Failed() || len(events[1].Error) != maxErrorLen {
+		t.Errorf("events = %+v", events)
+	}
+	if ArgsKey(map[string]any{"a": 1, "b": 2}) != ArgsKey(map[string]any{"b": 2, "a": 1}) {
+		t.Error("ArgsKey depends on the key order")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	at := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	var events []Event
+	add := func(e Event) {
+		e.Time = at
+		events = append(events, e)
+	}
+	for i := 0; i < 3; i++ {
+		// The same failing search, repeated.
+		add(Event{Tool: "run_shell_command", Program: "rg", Args: "same", Error: "exit code 127: sh: 1: rg: not found"})
+	}
+	add(Event{Tool: "read_file", Args: "1", Error: "/etc/passwd is outside the workspace (/src)"})
+	add(Event{Tool: "read_file", Args: "2", Error: "/etc/hosts is outside the workspace (/src)"})
+	add(Event{Tool: "read_file", Args: "3"})
+	add(Event{Tool: "find_definition", Args: "4", Error: "the pyright language server (pyright-langserver) is not installed or not on PATH"})
+
+	s := Summarize(events)
+	if s.Calls != 7 || !s.Since.Equal(at) {
+		t.Errorf("Calls = %d, Since = %v", s.Calls, s.Since)
+	}
+	if len(s.Tools) != 3 || s.Tools[0].Name != "read_file" || s.Tools[0].Failures != 2 {
+		t.Errorf("Tools = %+v", s.Tools)
+	}
+	if rg := s.Tools[1]; rg.Name != "run_shell_command rg" || rg.Retries != 2 {
+		t.Errorf("rg stats = %+v", rg)
+	}
+
+	var text []string
+	for _, f := range s.Findings {
+		text = append(text, f.Problem+" "+f.Suggestion)
+	}
+	all := strings.Join(text, "\n")
+	for _, want := range []string{"Install ripgrep", "context.includeDirectories", "same arguments 3 or more times"} {
+		if !strings.Contains(all, want) {
+			t.Errorf("Expected a finding mentioning %q, got:\n%s", want, all)
+		}
+	}
+	// A single failure is not a pattern.
+	if strings.Contains(all, "pyright") {
+		t.Errorf("Expected the single language server failure to be ignored, got:\n%s", all)
+	}
+	if len(s.Findings) != 3 {
+		t.Errorf("Expected 3 findings, got:\n%s", all)
+	}
+}